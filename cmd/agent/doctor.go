@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/anurag/saviour/internal/agent"
+	"github.com/anurag/saviour/internal/config"
+)
+
+// runDoctor implements "saviour-agent doctor": check everything the agent
+// depends on and explain how to fix what's broken
+func runDoctor(args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configPath := flags.String("config", "agent.yaml", "path to configuration file")
+	flags.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Checking %s (agent %q)\n\n", *configPath, cfg.Agent.Name)
+
+	results := []agent.CheckResult{{Name: "Configuration", Status: agent.CheckOK, Detail: "valid"}}
+	if err := cfg.Validate(); err != nil {
+		results[0] = agent.CheckResult{Name: "Configuration", Status: agent.CheckFail, Detail: err.Error()}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	results = append(results, agent.Diagnose(ctx, cfg)...)
+
+	failed := 0
+	for _, r := range results {
+		icon := map[string]string{
+			agent.CheckOK:   "✓",
+			agent.CheckWarn: "⚠️ ",
+			agent.CheckFail: "✗",
+			agent.CheckSkip: "-",
+		}[r.Status]
+
+		fmt.Printf("%s %-24s %s\n", icon, r.Name, r.Detail)
+		if r.Fix != "" {
+			fmt.Printf("  → %s\n", r.Fix)
+		}
+		if r.Status == agent.CheckFail {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("\n%d check(s) failed", failed)
+	}
+	fmt.Println("\nAll checks passed")
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/internal/docker"
+	"github.com/anurag/saviour/internal/setup"
+)
+
+// runInit implements "saviour-agent init": write a starter agent.yaml,
+// asking for each value on a terminal unless -y is given
+func runInit(args []string) error {
+	hostname, _ := os.Hostname()
+	_, socketErr := os.Stat(docker.SocketPath(config.DefaultDockerSocket))
+
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	output := flags.String("output", "agent.yaml", "file to write")
+	name := flags.String("name", hostname, "agent name")
+	serverURL := flags.String("server-url", "http://localhost:8080", "Saviour server URL")
+	apiKey := flags.String("api-key", "", "agent API key from the server config (generated if empty)")
+	cloud := flags.String("cloud-provider", "auto", "auto, ecs, aws, gcp, azure or none")
+	docker := flags.Bool("docker", socketErr == nil, "monitor Docker containers")
+	socket := flags.String("docker-socket", config.DefaultDockerSocket, "Docker socket path or npipe:// URL")
+	yes := flags.Bool("y", false, "don't prompt; use flag values and defaults")
+	force := flags.Bool("force", false, "overwrite an existing file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	p := setup.NewPrompter(os.Stdin, os.Stdout, !*yes && setup.IsTerminal(os.Stdin))
+
+	opts := setup.AgentOptions{
+		Name:          p.String("Agent name", *name),
+		ServerURL:     p.String("Server URL", *serverURL),
+		APIKey:        p.String("Agent API key (empty to generate one)", *apiKey),
+		CloudProvider: p.String("Cloud provider (auto, ecs, aws, gcp, azure, none)", *cloud),
+		Docker:        p.Bool("Monitor Docker containers?", *docker),
+		DockerSocket:  *socket,
+	}
+	if opts.Docker {
+		opts.DockerSocket = p.String("Docker socket", *socket)
+	}
+
+	generated := opts.APIKey == ""
+	if generated {
+		key, err := setup.GenerateAPIKey()
+		if err != nil {
+			return err
+		}
+		opts.APIKey = key
+	}
+
+	data, err := setup.AgentConfig(opts)
+	if err != nil {
+		return err
+	}
+	if err := setup.WriteConfig(*output, data, *force); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Wrote %s\n", *output)
+	if generated {
+		fmt.Println("\nGenerated a new API key. Add it under auth.api_keys in the server config:")
+		fmt.Printf("- key: %q\n", opts.APIKey)
+		fmt.Printf("  name: %q\n", opts.Name)
+		fmt.Println(`  scopes: ["metrics:write", "heartbeat:write"]`)
+	}
+	fmt.Printf("\nStart the agent with: saviour-agent -config %s\n", *output)
+	return nil
+}
@@ -3,39 +3,80 @@ package main
 import (
 	"context"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/anurag/saviour/internal/agent"
 	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/setup"
+	"github.com/anurag/saviour/internal/version"
 )
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "init":
+			if err := runInit(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		case "doctor":
+			if err := runDoctor(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		case "install", "uninstall":
+			if err := runServiceCommand(os.Args[1], os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	// Parse command line flags
 	configPath := flag.String("config", "agent.yaml", "path to configuration file")
+	showVersion := flag.Bool("version", false, "print version and exit")
+	checkOnly := flag.Bool("check-config", false, "validate the config, print it with defaults applied and exit (0 = valid, 1 = invalid)")
 	flag.Parse()
 
-	// Set up logger
-	logger := log.New(os.Stdout, "[saviour-agent] ", log.LstdFlags)
+	if *showVersion {
+		fmt.Println(version.String("saviour-agent"))
+		return
+	}
+	if *checkOnly {
+		os.Exit(checkConfig(*configPath))
+	}
 
 	// Load configuration
-	logger.Printf("Loading configuration from: %s", *configPath)
+	slog.Info("Loading configuration", "path", *configPath)
 	cfg, err := config.Load(*configPath)
 	if err != nil {
-		logger.Fatalf("Failed to load config: %v", err)
+		fatal("Failed to load config", err)
 	}
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
-		logger.Fatalf("Invalid configuration: %v", err)
+		fatal("Invalid configuration", err)
 	}
 
+	// Set up logger, tagging every record with the agent
+	if err := logging.Setup(os.Stdout, cfg.Logging, "agent", cfg.Agent.Name); err != nil {
+		fatal("Invalid configuration", err)
+	}
+	logger := logging.Component("agent")
+
 	// Create agent
 	a, err := agent.New(cfg, logger)
 	if err != nil {
-		logger.Fatalf("Failed to create agent: %v", err)
+		fatal("Failed to create agent", err)
 	}
 
 	// Set up context for graceful shutdown
@@ -48,15 +89,40 @@ func main() {
 
 	go func() {
 		sig := <-sigChan
-		logger.Printf("Received signal: %v", sig)
+		logger.Info("Received signal", "signal", sig.String())
 		cancel()
 	}()
 
 	// Run agent
-	logger.Println("Starting Saviour Agent...")
-	if err := a.Run(ctx); err != nil && err != context.Canceled {
-		logger.Fatalf("Agent error: %v", err)
+	logger.Info("Starting Saviour Agent", "version", version.Version)
+	if err := runAgent(ctx, a.Run); err != nil && err != context.Canceled {
+		fatal("Agent failed", err)
+	}
+
+	logger.Info("Agent stopped")
+}
+
+// checkConfig loads and validates the config at path and prints the
+// effective configuration, for CI and config management pre-checks. It
+// returns the exit code: 0 if the config is valid, 1 if not.
+func checkConfig(path string) int {
+	cfg, err := config.Load(path)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err == nil {
+		err = setup.PrintConfig(os.Stdout, cfg)
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: invalid configuration: %v\n", path, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%s: configuration OK\n", path)
+	return 0
+}
 
-	logger.Println("Agent stopped")
+// fatal logs an error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, logging.Err(err))
+	os.Exit(1)
 }
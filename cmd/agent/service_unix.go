@@ -0,0 +1,31 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+
+	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/internal/setup"
+)
+
+// runServiceCommand installs or removes the agent's systemd unit
+func runServiceCommand(command string, args []string) error {
+	return setup.RunServiceCommand(agentService(), command, args, config.DefaultConfigPath)
+}
+
+// agentService describes the agent's systemd unit. The agent joins the
+// docker group, when there is one, to read the socket without root.
+func agentService() *setup.Service {
+	svc := setup.NewService("saviour-agent", "Saviour Monitoring Agent")
+	if setup.GroupExists("docker") {
+		svc.Groups = []string{"docker"}
+		svc.After = []string{"docker.service"}
+	}
+	return svc
+}
+
+// runAgent runs the agent until ctx is cancelled
+func runAgent(ctx context.Context, run func(context.Context) error) error {
+	return run(ctx)
+}
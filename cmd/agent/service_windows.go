@@ -0,0 +1,25 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+
+	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/internal/setup"
+)
+
+// serviceName is the agent's Windows service
+const serviceName = "saviour-agent"
+
+// runServiceCommand registers or removes the agent's Windows service
+func runServiceCommand(command string, args []string) error {
+	svc := setup.NewService(serviceName, "Saviour Monitoring Agent")
+	return setup.RunWindowsServiceCommand(svc, command, args, config.DefaultConfigPath)
+}
+
+// runAgent runs the agent until ctx is cancelled, or the service is stopped
+// when started by the service control manager
+func runAgent(ctx context.Context, run func(context.Context) error) error {
+	return setup.RunAsService(ctx, serviceName, run)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+// Images assigned to simulated containers
+var simImages = []string{
+	"nginx:1.27", "redis:7.2", "postgres:16", "example/api:2.4.1",
+	"example/worker:2.4.1", "grafana/grafana:11.2.0", "memcached:1.6",
+}
+
+// simAgent generates plausible metrics for one simulated host. Values drift
+// around a per-host baseline so the server sees realistic, varied data.
+type simAgent struct {
+	name string
+	rng  *rand.Rand
+
+	cpuBase   float64
+	memBase   float64
+	diskUsed  float64
+	memTotal  uint64
+	bytesSent uint64
+	bytesRecv uint64
+	startedAt time.Time
+
+	containers []metrics.ContainerMetrics
+
+	// Time after which the agent stops reporting (zero = never)
+	goesOfflineAt time.Time
+}
+
+// newSimAgent creates a simulated agent with the given number of containers
+func newSimAgent(name string, containers int, seed int64) *simAgent {
+	rng := rand.New(rand.NewSource(seed))
+	a := &simAgent{
+		name:      name,
+		rng:       rng,
+		cpuBase:   10 + rng.Float64()*40,
+		memBase:   30 + rng.Float64()*40,
+		diskUsed:  20 + rng.Float64()*50,
+		memTotal:  uint64(4+rng.Intn(4)*4) << 30,
+		startedAt: time.Now().Add(-time.Duration(rng.Intn(30*24)) * time.Hour),
+	}
+
+	for i := 0; i < containers; i++ {
+		image := simImages[rng.Intn(len(simImages))]
+		a.containers = append(a.containers, metrics.ContainerMetrics{
+			ID:          fmt.Sprintf("%012x", rng.Int63()&0xffffffffffff),
+			Name:        fmt.Sprintf("%s-c%d", name, i),
+			Image:       image,
+			ImageID:     fmt.Sprintf("%012x", rng.Int63()&0xffffffffffff),
+			State:       "running",
+			Status:      "running",
+			Health:      "healthy",
+			Created:     a.startedAt,
+			StartedAt:   a.startedAt,
+			MemoryLimit: 512 << 20,
+		})
+	}
+
+	return a
+}
+
+// online reports whether the agent is still reporting at t
+func (a *simAgent) online(t time.Time) bool {
+	return a.goesOfflineAt.IsZero() || t.Before(a.goesOfflineAt)
+}
+
+// nextMetrics produces the next sample. With probability failureRate a
+// fault is injected: a CPU, memory or disk spike on the host, or a
+// container that stops, turns unhealthy or is OOM-killed.
+func (a *simAgent) nextMetrics(failureRate float64) *metrics.SystemMetrics {
+	now := time.Now()
+
+	cpu := clamp(a.cpuBase + a.rng.NormFloat64()*5)
+	mem := clamp(a.memBase + a.rng.NormFloat64()*3)
+	disk := a.diskUsed
+
+	// Containers recover from earlier faults over a few cycles
+	for i := range a.containers {
+		c := &a.containers[i]
+		if c.State != "running" || c.Health == "unhealthy" {
+			if a.rng.Float64() < 0.3 {
+				c.State, c.Status, c.Health = "running", "running", "healthy"
+				c.ExitCode, c.OOMKilled = 0, false
+				c.RestartCount++
+				c.StartedAt = now
+			}
+		}
+	}
+
+	if a.rng.Float64() < failureRate {
+		switch a.rng.Intn(6) {
+		case 0:
+			cpu = 92 + a.rng.Float64()*8
+		case 1:
+			mem = 92 + a.rng.Float64()*8
+		case 2:
+			disk = 93 + a.rng.Float64()*6
+		default:
+			if len(a.containers) > 0 {
+				a.injectContainerFault(&a.containers[a.rng.Intn(len(a.containers))], now)
+			}
+		}
+	}
+
+	a.bytesSent += uint64(a.rng.Intn(5 << 20))
+	a.bytesRecv += uint64(a.rng.Intn(20 << 20))
+
+	m := &metrics.SystemMetrics{
+		Timestamp: now,
+		AgentName: a.name,
+		CPU: metrics.CPUMetrics{
+			UsagePercent: cpu,
+			LoadAvg1:     cpu / 25,
+			LoadAvg5:     a.cpuBase / 25,
+			LoadAvg15:    a.cpuBase / 25,
+		},
+		Memory: metrics.MemoryMetrics{
+			Total:       a.memTotal,
+			Used:        uint64(float64(a.memTotal) * mem / 100),
+			Available:   uint64(float64(a.memTotal) * (100 - mem) / 100),
+			UsedPercent: mem,
+		},
+		Disk: []metrics.DiskMetrics{{
+			MountPoint:  "/",
+			Device:      "/dev/nvme0n1p1",
+			FSType:      "ext4",
+			Total:       100 << 30,
+			Used:        uint64(float64(100<<30) * disk / 100),
+			Free:        uint64(float64(100<<30) * (100 - disk) / 100),
+			UsedPercent: disk,
+		}},
+		Network: metrics.NetworkMetrics{
+			BytesSent: a.bytesSent,
+			BytesRecv: a.bytesRecv,
+		},
+		SystemInfo: metrics.SystemInfo{
+			Hostname:      a.name,
+			OS:            "linux",
+			Platform:      "ubuntu",
+			KernelVersion: "6.8.0",
+			Uptime:        uint64(now.Sub(a.startedAt).Seconds()),
+		},
+	}
+
+	m.Containers = make([]metrics.ContainerMetrics, len(a.containers))
+	for i, c := range a.containers {
+		if c.State == "running" {
+			c.CPUPercent = clamp(a.rng.Float64() * cpu / 2)
+			c.MemoryUsage = uint64(a.rng.Int63n(int64(c.MemoryLimit)))
+			c.MemoryPercent = float64(c.MemoryUsage) / float64(c.MemoryLimit) * 100
+			c.PIDs = uint64(1 + a.rng.Intn(40))
+		}
+		m.Containers[i] = c
+	}
+
+	return m
+}
+
+// injectContainerFault stops, fails or OOM-kills a container
+func (a *simAgent) injectContainerFault(c *metrics.ContainerMetrics, now time.Time) {
+	switch a.rng.Intn(3) {
+	case 0:
+		c.State, c.Status = "exited", "exited"
+		c.ExitCode = 1
+		c.FinishedAt = now
+	case 1:
+		c.Health = "unhealthy"
+		c.HealthCheckExitCode = 1
+		c.HealthCheckOutput = "connection refused"
+	case 2:
+		c.State, c.Status = "exited", "exited"
+		c.ExitCode = 137
+		c.OOMKilled = true
+		c.FinishedAt = now
+	}
+}
+
+func clamp(percent float64) float64 {
+	if percent < 0 {
+		return 0
+	}
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
@@ -0,0 +1,257 @@
+// Command loadgen simulates a fleet of agents pushing metrics and
+// heartbeats to a Saviour server, for capacity testing the server and the
+// alerting engine before scaling the real fleet.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/internal/version"
+)
+
+// options holds the load test parameters
+type options struct {
+	serverURL         string
+	apiKey            string
+	agents            int
+	containers        int
+	pushInterval      time.Duration
+	heartbeatInterval time.Duration
+	duration          time.Duration
+	reportInterval    time.Duration
+	prefix            string
+	failureRate       float64
+	offlineFraction   float64
+	gzip              bool
+	seed              int64
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.serverURL, "server", "http://localhost:8080", "server URL")
+	flag.StringVar(&opts.apiKey, "api-key", os.Getenv("SAVIOUR_API_KEY"), "API key with metrics:write and heartbeat:write scopes (env SAVIOUR_API_KEY)")
+	flag.IntVar(&opts.agents, "agents", 100, "number of simulated agents")
+	flag.IntVar(&opts.containers, "containers", 10, "containers per agent")
+	flag.DurationVar(&opts.pushInterval, "push-interval", 30*time.Second, "metrics push interval per agent")
+	flag.DurationVar(&opts.heartbeatInterval, "heartbeat-interval", 15*time.Second, "heartbeat interval per agent")
+	flag.DurationVar(&opts.duration, "duration", 5*time.Minute, "how long to run (0 = until interrupted)")
+	flag.DurationVar(&opts.reportInterval, "report", 10*time.Second, "how often to print throughput and latency")
+	flag.StringVar(&opts.prefix, "prefix", "loadgen", "agent name prefix")
+	flag.Float64Var(&opts.failureRate, "failure-rate", 0.02, "probability per push of injecting a host or container fault")
+	flag.Float64Var(&opts.offlineFraction, "offline-fraction", 0, "fraction of agents that stop reporting partway through")
+	flag.BoolVar(&opts.gzip, "gzip", true, "gzip metrics payloads like the real agent")
+	flag.Int64Var(&opts.seed, "seed", time.Now().UnixNano(), "random seed, for repeatable runs")
+	showVersion := flag.Bool("version", false, "print version and exit")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(version.String("saviour-loadgen"))
+		return
+	}
+
+	if opts.agents <= 0 || opts.pushInterval <= 0 || opts.heartbeatInterval <= 0 || opts.reportInterval <= 0 {
+		log.Fatal("agents and intervals must be positive")
+	}
+	if opts.failureRate < 0 || opts.failureRate > 1 || opts.offlineFraction < 0 || opts.offlineFraction > 1 {
+		log.Fatal("failure-rate and offline-fraction must be between 0 and 1")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if opts.duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	run(ctx, opts)
+}
+
+// run starts all simulated agents and reports until ctx is done
+func run(ctx context.Context, opts options) {
+	g := &generator{
+		opts: opts,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				MaxIdleConns:        opts.agents * 2,
+				MaxIdleConnsPerHost: opts.agents * 2,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+		push:      newEndpointStats("push"),
+		heartbeat: newEndpointStats("heartbeat"),
+	}
+
+	log.Printf("Simulating %d agents x %d containers against %s (push every %v, heartbeat every %v, failure rate %.0f%%)",
+		opts.agents, opts.containers, opts.serverURL, opts.pushInterval, opts.heartbeatInterval, opts.failureRate*100)
+
+	rng := rand.New(rand.NewSource(opts.seed))
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.agents; i++ {
+		agent := newSimAgent(fmt.Sprintf("%s-%04d", opts.prefix, i), opts.containers, rng.Int63())
+		if rng.Float64() < opts.offlineFraction {
+			// Go silent somewhere in the first half of the run
+			window := opts.duration
+			if window == 0 {
+				window = 10 * opts.pushInterval
+			}
+			agent.goesOfflineAt = start.Add(time.Duration(rng.Int63n(int64(window/2) + 1)))
+		}
+
+		// Spread agents over the interval so load is steady, not bursty
+		offset := time.Duration(rng.Int63n(int64(opts.pushInterval)))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.runAgent(ctx, agent, offset)
+		}()
+	}
+
+	ticker := time.NewTicker(opts.reportInterval)
+	defer ticker.Stop()
+	last := time.Now()
+
+report:
+	for {
+		select {
+		case <-ctx.Done():
+			break report
+		case now := <-ticker.C:
+			elapsed := now.Sub(last)
+			last = now
+			log.Printf("%s | %s", g.push.interval(elapsed), g.heartbeat.interval(elapsed))
+		}
+	}
+
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	fmt.Printf("\nRan %d agents for %v\n", opts.agents, elapsed.Round(time.Second))
+	fmt.Println(g.push.summary(elapsed))
+	fmt.Println(g.heartbeat.summary(elapsed))
+}
+
+// generator sends simulated agent traffic
+type generator struct {
+	opts      options
+	client    *http.Client
+	push      *endpointStats
+	heartbeat *endpointStats
+}
+
+// runAgent pushes metrics and heartbeats for one agent until ctx is done
+// or the agent goes offline
+func (g *generator) runAgent(ctx context.Context, agent *simAgent, offset time.Duration) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(offset):
+	}
+
+	pushTicker := time.NewTicker(g.opts.pushInterval)
+	defer pushTicker.Stop()
+	heartbeatTicker := time.NewTicker(g.opts.heartbeatInterval)
+	defer heartbeatTicker.Stop()
+
+	g.sendMetrics(ctx, agent)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-pushTicker.C:
+			if !agent.online(now) {
+				return
+			}
+			g.sendMetrics(ctx, agent)
+		case now := <-heartbeatTicker.C:
+			if !agent.online(now) {
+				return
+			}
+			g.post(ctx, g.heartbeat, "/api/v1/heartbeat", server.HeartbeatPayload{
+				AgentName: agent.name,
+				Timestamp: now,
+				Status:    "online",
+			}, false)
+		}
+	}
+}
+
+func (g *generator) sendMetrics(ctx context.Context, agent *simAgent) {
+	m := agent.nextMetrics(g.opts.failureRate)
+	g.post(ctx, g.push, "/api/v1/metrics/push", server.MetricsPushPayload{
+		AgentName:     agent.name,
+		Timestamp:     m.Timestamp,
+		SystemMetrics: *m,
+	}, g.opts.gzip)
+}
+
+// post sends one JSON request and records its outcome
+func (g *generator) post(ctx context.Context, stats *endpointStats, path string, payload interface{}, compress bool) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to marshal payload: %v", err)
+		return
+	}
+
+	if compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(data)
+		gz.Close()
+		data = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(g.opts.serverURL, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Failed to create request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if g.opts.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.opts.apiKey)
+	}
+	req.Header.Set("User-Agent", "saviour-loadgen/1.0")
+
+	start := time.Now()
+	resp, err := g.client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			stats.record(time.Since(start), 0)
+		}
+		return
+	}
+	// Drain so the connection is reused
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	stats.record(time.Since(start), resp.StatusCode)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpointStats records request outcomes and latencies for one endpoint
+type endpointStats struct {
+	name string
+
+	mu        sync.Mutex
+	requests  int
+	failures  int             // Transport errors and non-2xx responses
+	statuses  map[int]int     // Response status code counts
+	latencies []time.Duration // Since the last interval report
+	total     []time.Duration // Whole run
+}
+
+func newEndpointStats(name string) *endpointStats {
+	return &endpointStats{
+		name:     name,
+		statuses: make(map[int]int),
+	}
+}
+
+// record adds one request. status is 0 for transport errors.
+func (s *endpointStats) record(latency time.Duration, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if status < 200 || status >= 300 {
+		s.failures++
+	}
+	s.statuses[status]++
+	s.latencies = append(s.latencies, latency)
+	s.total = append(s.total, latency)
+}
+
+// interval returns a one-line summary of the requests since the last call
+func (s *endpointStats) interval(elapsed time.Duration) string {
+	s.mu.Lock()
+	latencies := s.latencies
+	s.latencies = nil
+	s.mu.Unlock()
+
+	rate := float64(len(latencies)) / elapsed.Seconds()
+	return fmt.Sprintf("%-10s %7.1f req/s  %s", s.name, rate, percentiles(latencies))
+}
+
+// summary returns the totals for the whole run
+func (s *endpointStats) summary(elapsed time.Duration) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	codes := make([]int, 0, len(s.statuses))
+	for code := range s.statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		label := fmt.Sprint(code)
+		if code == 0 {
+			label = "error"
+		}
+		parts[i] = fmt.Sprintf("%s=%d", label, s.statuses[code])
+	}
+
+	return fmt.Sprintf("%-10s %d requests (%.1f req/s), %d failed [%s]\n           %s",
+		s.name, s.requests, float64(s.requests)/elapsed.Seconds(), s.failures,
+		strings.Join(parts, " "), percentiles(s.total))
+}
+
+// percentiles formats p50/p95/p99/max of a latency sample
+func percentiles(latencies []time.Duration) string {
+	if len(latencies) == 0 {
+		return "no requests"
+	}
+
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	at := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))].Round(time.Microsecond)
+	}
+	return fmt.Sprintf("p50=%v p95=%v p99=%v max=%v", at(0.50), at(0.95), at(0.99), sorted[len(sorted)-1].Round(time.Microsecond))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Status codes returned for injected server errors
+var injectedErrors = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// faults injects failures and latency into the agent endpoints, so the
+// agent's retry and backoff behaviour can be exercised in development
+type faults struct {
+	errorRate     float64       // Fraction of requests answered with a random 5xx
+	rateLimitRate float64       // Fraction answered with 429 Too Many Requests
+	retryAfter    time.Duration // Retry-After sent with 429s
+	dropRate      float64       // Fraction whose connection is closed without a response
+	latency       time.Duration // Added before every response
+	jitter        time.Duration // Random extra latency, up to this much
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// validate checks the rates are usable together
+func (f *faults) validate() error {
+	for name, rate := range map[string]float64{"error-rate": f.errorRate, "rate-limit-rate": f.rateLimitRate, "drop-rate": f.dropRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %v", name, rate)
+		}
+	}
+	if f.errorRate+f.rateLimitRate+f.dropRate > 1 {
+		return fmt.Errorf("error-rate, rate-limit-rate and drop-rate add up to more than 1")
+	}
+	if f.latency < 0 || f.jitter < 0 {
+		return fmt.Errorf("latency and jitter must not be negative")
+	}
+	return nil
+}
+
+// enabled reports whether any fault is configured
+func (f *faults) enabled() bool {
+	return f.errorRate > 0 || f.rateLimitRate > 0 || f.dropRate > 0 || f.latency > 0 || f.jitter > 0
+}
+
+// String describes the configured faults for the startup log
+func (f *faults) String() string {
+	return fmt.Sprintf("%.0f%% 5xx, %.0f%% 429 (Retry-After %v), %.0f%% dropped, latency %v +%v",
+		f.errorRate*100, f.rateLimitRate*100, f.retryAfter, f.dropRate*100, f.latency, f.jitter)
+}
+
+// roll returns a random number in [0,1) and a random jitter
+func (f *faults) roll() (float64, time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var jitter time.Duration
+	if f.jitter > 0 {
+		jitter = time.Duration(f.rng.Int63n(int64(f.jitter)))
+	}
+	return f.rng.Float64(), jitter
+}
+
+// wrap injects the configured faults in front of next
+func (f *faults) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, jitter := f.roll()
+
+		if delay := f.latency + jitter; delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		switch {
+		case n < f.dropRate:
+			log.Printf("💥 Dropping connection for %s", r.URL.Path)
+			drop(w)
+
+		case n < f.dropRate+f.rateLimitRate:
+			log.Printf("🚦 Injected 429 for %s", r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(int(f.retryAfter.Seconds())))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+
+		case n < f.dropRate+f.rateLimitRate+f.errorRate:
+			code := injectedErrors[int(n*1000)%len(injectedErrors)]
+			log.Printf("💥 Injected %d for %s", code, r.URL.Path)
+			http.Error(w, http.StatusText(code), code)
+
+		default:
+			next(w, r)
+		}
+	}
+}
+
+// drop closes the connection without writing a response
+func drop(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		// HTTP/2 can't be hijacked; aborting the handler resets the stream
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
@@ -2,28 +2,87 @@ package main
 
 import (
 	"compress/gzip"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
+
+	"github.com/anurag/saviour/internal/version"
 )
 
 func main() {
-	http.HandleFunc("/api/v1/metrics/push", handleMetricsPush)
-	http.HandleFunc("/api/v1/heartbeat", handleHeartbeat)
+	addr := flag.String("addr", ":8080", "listen address")
+	f := &faults{}
+	flag.Float64Var(&f.errorRate, "error-rate", 0, "fraction of agent requests answered with a random 5xx")
+	flag.Float64Var(&f.rateLimitRate, "rate-limit-rate", 0, "fraction of agent requests answered with 429")
+	flag.DurationVar(&f.retryAfter, "retry-after", 5*time.Second, "Retry-After sent with 429 responses")
+	flag.Float64Var(&f.dropRate, "drop-rate", 0, "fraction of agent requests whose connection is closed without a response")
+	flag.DurationVar(&f.latency, "latency", 0, "latency added to every agent request")
+	flag.DurationVar(&f.jitter, "jitter", 0, "random extra latency, up to this much")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed, for repeatable fault sequences")
+	recordPath := flag.String("record", "", "append received agent payloads to this NDJSON file")
+	replayPath := flag.String("replay", "", "replay an NDJSON recording against -target instead of serving")
+	target := flag.String("target", "http://localhost:8080", "server to replay against")
+	apiKey := flag.String("api-key", os.Getenv("SAVIOUR_API_KEY"), "API key for replay (env SAVIOUR_API_KEY)")
+	speed := flag.Float64("speed", 1, "replay speed multiplier (0 = no delays)")
+	showVersion := flag.Bool("version", false, "print version and exit")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(version.String("saviour-mockserver"))
+		return
+	}
+
+	if *replayPath != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := replay(ctx, *replayPath, *target, *apiKey, *speed); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := f.validate(); err != nil {
+		log.Fatal(err)
+	}
+	f.rng = rand.New(rand.NewSource(*seed))
+
+	// Faults only apply to agent endpoints, health stays reliable
+	metricsHandler, heartbeatHandler := f.wrap(handleMetricsPush), f.wrap(handleHeartbeat)
+	if *recordPath != "" {
+		rec, err := newRecorder(*recordPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer rec.Close()
+		metricsHandler, heartbeatHandler = rec.wrap(metricsHandler), rec.wrap(heartbeatHandler)
+	}
+	http.HandleFunc("/api/v1/metrics/push", metricsHandler)
+	http.HandleFunc("/api/v1/heartbeat", heartbeatHandler)
 	http.HandleFunc("/api/v1/health", handleHealth)
 
-	addr := ":8080"
-	log.Printf("🚀 Mock server starting on %s", addr)
-	log.Printf("   Metrics endpoint: http://localhost%s/api/v1/metrics/push", addr)
-	log.Printf("   Heartbeat endpoint: http://localhost%s/api/v1/heartbeat", addr)
-	log.Printf("   Health endpoint: http://localhost%s/api/v1/health", addr)
+	log.Printf("🚀 Mock server starting on %s", *addr)
+	log.Printf("   Metrics endpoint: http://localhost%s/api/v1/metrics/push", *addr)
+	log.Printf("   Heartbeat endpoint: http://localhost%s/api/v1/heartbeat", *addr)
+	log.Printf("   Health endpoint: http://localhost%s/api/v1/health", *addr)
+	if f.enabled() {
+		log.Printf("   Injecting faults: %s", f)
+	}
+	if *recordPath != "" {
+		log.Printf("   Recording payloads to %s", *recordPath)
+	}
 	log.Println()
 
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	if err := http.ListenAndServe(*addr, nil); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// record is one agent request, stored as a line of NDJSON
+type record struct {
+	Time      time.Time       `json:"time"`
+	Path      string          `json:"path"`
+	UserAgent string          `json:"user_agent,omitempty"`
+	Body      json.RawMessage `json:"body"` // Decompressed JSON payload
+}
+
+// recorder appends incoming agent payloads to an NDJSON file
+type recorder struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// newRecorder opens path for appending, so recordings can span restarts
+func newRecorder(path string) (*recorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	return &recorder{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (rec *recorder) Close() error {
+	return rec.file.Close()
+}
+
+// wrap records each request's payload before passing it on to next. The
+// body is handed on decompressed.
+func (rec *recorder) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reader io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, "Failed to decompress", http.StatusBadRequest)
+				return
+			}
+			defer gz.Close()
+			reader = gz
+		}
+
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			http.Error(w, "Failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		if json.Valid(body) {
+			rec.mu.Lock()
+			err := rec.enc.Encode(record{
+				Time:      time.Now(),
+				Path:      r.URL.Path,
+				UserAgent: r.UserAgent(),
+				Body:      body,
+			})
+			rec.mu.Unlock()
+			if err != nil {
+				log.Printf("⚠️  Failed to record payload: %v", err)
+			}
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.Header.Del("Content-Encoding")
+		next(w, r)
+	}
+}
+
+// replay sends recorded payloads to a real server. Gaps between records
+// are kept, divided by speed; speed 0 sends them back to back.
+func replay(ctx context.Context, path, target, apiKey string, speed float64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	target = strings.TrimSuffix(target, "/")
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 1<<20), 64<<20) // Payloads with many containers are large
+
+	var previous time.Time
+	sent, failed := 0, 0
+	for line := 1; scanner.Scan(); line++ {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+
+		if speed > 0 && !previous.IsZero() {
+			if gap := rec.Time.Sub(previous); gap > 0 {
+				select {
+				case <-time.After(time.Duration(float64(gap) / speed)):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		previous = rec.Time
+
+		status, err := send(ctx, client, target+rec.Path, apiKey, rec.Body)
+		sent++
+		switch {
+		case err != nil:
+			failed++
+			log.Printf("✗ %s (recorded %s): %v", rec.Path, rec.Time.Format(time.RFC3339), err)
+		case status < 200 || status >= 300:
+			failed++
+			log.Printf("✗ %s (recorded %s): HTTP %d", rec.Path, rec.Time.Format(time.RFC3339), status)
+		default:
+			log.Printf("✓ %s (recorded %s)", rec.Path, rec.Time.Format(time.RFC3339))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	log.Printf("Replayed %d requests, %d failed", sent, failed)
+	return nil
+}
+
+// send posts one recorded payload, gzipping it above 1KB like the agent
+func send(ctx context.Context, client *http.Client, url, apiKey string, body []byte) (int, error) {
+	compress := len(body) > 1024
+	if compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(body)
+		gz.Close()
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
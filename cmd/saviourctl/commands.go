@@ -0,0 +1,952 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anurag/saviour/internal/api"
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/internal/setup"
+	"github.com/anurag/saviour/internal/version"
+)
+
+// cli runs saviourctl commands against the server API
+type cli struct {
+	api  *apiClient
+	json bool // Print raw JSON instead of tables
+	out  io.Writer
+}
+
+// run dispatches a command line (without global flags)
+func (c *cli) run(args []string) error {
+	switch args[0] {
+	case "health":
+		return c.health()
+	case "version":
+		return c.version()
+	case "agents":
+		return c.agents(args[1:])
+	case "alerts":
+		return c.alerts(args[1:])
+	case "alerting":
+		return c.alerting(args[1:])
+	case "containers":
+		return c.containers(args[1:])
+	case "images":
+		return c.images(args[1:])
+	case "metrics":
+		return c.metrics(args[1:])
+	case "silences":
+		return c.silences(args[1:])
+	case "keys":
+		return c.keys(args[1:])
+	case "rules":
+		return c.rules(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q (run saviourctl -h for usage)", args[0])
+	}
+}
+
+func (c *cli) health() error {
+	var health struct {
+		Status         string `json:"status"`
+		AgentsOnline   int    `json:"agents_online"`
+		AgentsDegraded int    `json:"agents_degraded"`
+		AgentsOffline  int    `json:"agents_offline"`
+		ActiveAlerts   int    `json:"active_alerts"`
+		HealthScore    *int   `json:"health_score"` // Unset while no agent reports
+
+		Channels []server.NotificationChannelStatus `json:"notification_channels"`
+	}
+	if err := c.api.get("/api/v1/health", &health); err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(health)
+	}
+
+	score := "-"
+	if health.HealthScore != nil {
+		score = strconv.Itoa(*health.HealthScore)
+	}
+	w := c.table("STATUS", "ONLINE", "DEGRADED", "OFFLINE", "ACTIVE ALERTS", "HEALTH")
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", health.Status, health.AgentsOnline, health.AgentsDegraded, health.AgentsOffline, health.ActiveAlerts, score)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(health.Channels) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(c.out)
+	w = c.table("CHANNEL", "HEALTHY", "LAST CHECK", "FAILING SINCE", "ERROR")
+	for _, channel := range health.Channels {
+		lastCheck, failingSince := "-", "-"
+		if channel.LastCheck != nil {
+			lastCheck = ago(*channel.LastCheck)
+		}
+		if channel.FailingSince != nil {
+			failingSince = ago(*channel.FailingSince)
+		}
+		fmt.Fprintf(w, "%s\t%t\t%s\t%s\t%s\n", channel.Channel, channel.Healthy, lastCheck, failingSince, orDash(channel.LastError))
+	}
+	return w.Flush()
+}
+
+func (c *cli) version() error {
+	var serverVersion version.Info
+	if err := c.api.get("/api/v1/version", &serverVersion); err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(map[string]version.Info{"client": version.Get(), "server": serverVersion})
+	}
+
+	w := c.table("", "VERSION", "COMMIT", "BUILT", "GO")
+	for _, row := range []struct {
+		name string
+		info version.Info
+	}{{"client", version.Get()}, {"server", serverVersion}} {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", row.name, row.info.Version, orDash(row.info.Commit), orDash(row.info.BuildDate), row.info.GoVersion)
+	}
+	return w.Flush()
+}
+
+func (c *cli) agents(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: saviourctl agents list|get|delete|refresh|maintenance|uptime|trends|diff")
+	}
+
+	switch args[0] {
+	case "list":
+		var tags stringList
+		flags := flag.NewFlagSet("agents list", flag.ContinueOnError)
+		flags.Var(&tags, "tag", "cloud tag filter, key or key=value (repeatable)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		query := url.Values{}
+		for _, tag := range tags {
+			query.Add("tag", tag)
+		}
+		path := "/api/v1/agents"
+		if len(query) > 0 {
+			path += "?" + query.Encode()
+		}
+
+		var agents []server.ServerState
+		if err := c.api.get(path, &agents); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(agents)
+		}
+
+		w := c.table("NAME", "STATUS", "HEALTH", "VERSION", "LAST SEEN", "CPU", "MEMORY", "CONTAINERS", "ALERTS", "INSTANCE")
+		for _, agent := range agents {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%.1f%%\t%.1f%%\t%d\t%d\t%s\n",
+				agent.AgentName, agent.Status, agent.HealthScore, orDash(agent.AgentVersion), ago(agent.LastSeen),
+				agent.SystemMetrics.CPU.UsagePercent, agent.SystemMetrics.Memory.UsedPercent,
+				len(agent.Containers), len(agent.ActiveAlerts), instance(agent.Cloud))
+		}
+		return w.Flush()
+
+	case "get":
+		name, err := oneArg("agents get <name>", args[1:])
+		if err != nil {
+			return err
+		}
+
+		var agent server.ServerState
+		if err := c.api.get("/api/v1/agents/"+url.PathEscape(name), &agent); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(agent)
+		}
+		return c.printAgent(&agent)
+
+	case "delete":
+		name, err := oneArg("agents delete <name>", args[1:])
+		if err != nil {
+			return err
+		}
+		if err := c.api.do("DELETE", "/api/v1/agents/"+url.PathEscape(name), nil, nil); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Agent %s deregistered\n", name)
+		return nil
+
+	case "refresh":
+		name, err := oneArg("agents refresh <name>", args[1:])
+		if err != nil {
+			return err
+		}
+		if err := c.api.do("POST", "/api/v1/agents/"+url.PathEscape(name)+"/refresh", nil, nil); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Refresh requested, agent %s pushes after its next heartbeat\n", name)
+		return nil
+
+	case "maintenance":
+		flags := flag.NewFlagSet("agents maintenance", flag.ContinueOnError)
+		duration := flags.Duration("duration", 0, "how long the maintenance lasts, e.g. 2h")
+		reason := flags.String("reason", "", "why the agent is in maintenance")
+		end := flags.Bool("end", false, "end maintenance now")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		name, err := oneArg("agents maintenance -duration d [-reason r] <name>", flags.Args())
+		if err != nil {
+			return err
+		}
+		if *duration <= 0 && !*end {
+			return fmt.Errorf("agents maintenance: -duration or -end is required")
+		}
+
+		path := "/api/v1/agents/" + url.PathEscape(name) + "/maintenance"
+		var agent server.ServerState
+		if *end {
+			err = c.api.do("DELETE", path, nil, &agent)
+		} else {
+			err = c.api.do("PUT", path, server.MaintenanceRequest{Duration: duration.String(), Reason: *reason}, &agent)
+		}
+		if err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(agent)
+		}
+		if agent.MaintenanceUntil == nil {
+			fmt.Fprintf(c.out, "Agent %s is out of maintenance\n", name)
+		} else {
+			fmt.Fprintf(c.out, "Agent %s in maintenance until %s\n", name, agent.MaintenanceUntil.Local().Format(time.RFC3339))
+		}
+		return nil
+
+	case "uptime":
+		flags := flag.NewFlagSet("agents uptime", flag.ContinueOnError)
+		window := flags.String("window", "30d", "how far back to look, e.g. 30d or 12h")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		query := "?window=" + url.QueryEscape(*window)
+
+		if flags.NArg() == 0 {
+			var fleet server.FleetUptime
+			if err := c.api.get("/api/v1/uptime"+query, &fleet); err != nil {
+				return err
+			}
+			if c.json {
+				return c.printJSON(fleet)
+			}
+			fmt.Fprintf(c.out, "Fleet availability %.3f%%, %d incidents, MTTR %s\n\n",
+				fleet.AvailabilityPercent, fleet.Incidents, seconds(fleet.MTTRSeconds))
+			w := c.table("AGENT", "AVAILABILITY", "DOWNTIME", "INCIDENTS", "MTTR")
+			for _, agent := range fleet.Agents {
+				fmt.Fprintf(w, "%s\t%.3f%%\t%s\t%d\t%s\n", agent.AgentName, agent.AvailabilityPercent,
+					seconds(agent.DowntimeSeconds), agent.Incidents, seconds(agent.MTTRSeconds))
+			}
+			return w.Flush()
+		}
+
+		name, err := oneArg("agents uptime [-window 30d] [name]", flags.Args())
+		if err != nil {
+			return err
+		}
+		var uptime server.Uptime
+		if err := c.api.get("/api/v1/agents/"+url.PathEscape(name)+"/uptime"+query, &uptime); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(uptime)
+		}
+		fmt.Fprintf(c.out, "Agent %s availability %.3f%%, down %s, MTTR %s\n\n",
+			name, uptime.AvailabilityPercent, seconds(uptime.DowntimeSeconds), seconds(uptime.MTTRSeconds))
+		w := c.table("OFFLINE SINCE", "BACK AT", "DURATION")
+		for _, incident := range uptime.Incidents {
+			back, end := "-", uptime.To
+			if incident.End != nil {
+				back, end = incident.End.Local().Format(time.RFC3339), *incident.End
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", incident.Start.Local().Format(time.RFC3339), back, end.Sub(incident.Start).Round(time.Second))
+		}
+		return w.Flush()
+
+	case "diff":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: saviourctl agents diff <a> <b>")
+		}
+		var diff server.AgentDiff
+		query := url.Values{"a": {args[1]}, "b": {args[2]}}
+		if err := c.api.get("/api/v1/agents/diff?"+query.Encode(), &diff); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(diff)
+		}
+
+		w := c.table("", diff.A, diff.B)
+		for _, f := range diff.System {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", f.Field, orDash(f.A), orDash(f.B))
+		}
+		for _, m := range diff.Metrics {
+			fmt.Fprintf(w, "%s\t%.1f\t%.1f (%+.1f)\n", m.Metric, m.A, m.B, m.Delta)
+		}
+		for _, name := range diff.OnlyA {
+			fmt.Fprintf(w, "container %s\tpresent\t-\n", name)
+		}
+		for _, name := range diff.OnlyB {
+			fmt.Fprintf(w, "container %s\t-\tpresent\n", name)
+		}
+		for _, container := range diff.Containers {
+			for _, f := range container.Fields {
+				fmt.Fprintf(w, "container %s %s\t%s\t%s\n", container.Container, f.Field, orDash(f.A), orDash(f.B))
+			}
+		}
+		return w.Flush()
+
+	case "trends":
+		name, err := oneArg("agents trends <name>", args[1:])
+		if err != nil {
+			return err
+		}
+		var trends server.Trends
+		if err := c.api.get("/api/v1/agents/"+url.PathEscape(name)+"/trends", &trends); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(trends)
+		}
+		w := c.table("WINDOW", "METRIC", "CURRENT", "PER DAY", fmt.Sprintf("IN %dD", trends.HorizonDays), "FULL IN")
+		for _, window := range trends.Windows {
+			if window.Disk == nil {
+				fmt.Fprintf(w, "%s\t-\tnot enough history (%d hours)\t\t\t\n", window.Window, window.Hours)
+				continue
+			}
+			for _, metric := range []struct {
+				name  string
+				trend *server.Trend
+			}{{"disk", window.Disk}, {"memory", window.Memory}, {"containers", window.Containers}} {
+				full := "-"
+				if days := metric.trend.DaysUntilFull; days != nil {
+					full = fmt.Sprintf("%.0fd", *days)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%.1f\t%+.2f\t%.1f\t%s\n", window.Window, metric.name,
+					metric.trend.Current, metric.trend.GrowthPerDay, metric.trend.Projected, full)
+			}
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown agents command %q", args[0])
+	}
+}
+
+// printAgent prints an agent's details and containers
+func (c *cli) printAgent(agent *server.ServerState) error {
+	fmt.Fprintf(c.out, "Name:      %s\n", agent.AgentName)
+	fmt.Fprintf(c.out, "Status:    %s", agent.Status)
+	if agent.StatusReason != "" {
+		fmt.Fprintf(c.out, " (%s)", agent.StatusReason)
+	}
+	fmt.Fprintln(c.out)
+	fmt.Fprintf(c.out, "Health:    %d/100\n", agent.HealthScore)
+	if agent.InMaintenance(time.Now()) {
+		fmt.Fprintf(c.out, "Maintenance: until %s", agent.MaintenanceUntil.Local().Format(time.RFC3339))
+		if agent.MaintenanceReason != "" {
+			fmt.Fprintf(c.out, " (%s)", agent.MaintenanceReason)
+		}
+		fmt.Fprintln(c.out)
+	}
+	if agent.AgentVersion != "" {
+		fmt.Fprintf(c.out, "Version:   %s\n", agent.AgentVersion)
+	}
+	fmt.Fprintf(c.out, "Last seen: %s (%s)\n", agent.LastSeen.Local().Format(time.RFC3339), ago(agent.LastSeen))
+	if math.Abs(agent.ClockSkewSeconds) >= 1 {
+		fmt.Fprintf(c.out, "Clock:     %+.1fs from server\n", agent.ClockSkewSeconds)
+	}
+	if agent.Cloud != nil {
+		fmt.Fprintf(c.out, "Instance:  %s %s (%s, %s)\n", agent.Cloud.Provider, agent.Cloud.InstanceID, agent.Cloud.InstanceType, agent.Cloud.Zone)
+	}
+	fmt.Fprintf(c.out, "CPU:       %.1f%%\n", agent.SystemMetrics.CPU.UsagePercent)
+	fmt.Fprintf(c.out, "Memory:    %.1f%%\n", agent.SystemMetrics.Memory.UsedPercent)
+	for _, disk := range agent.SystemMetrics.Disk {
+		fmt.Fprintf(c.out, "Disk:      %s %.1f%%\n", disk.MountPoint, disk.UsedPercent)
+	}
+
+	if len(agent.Containers) > 0 {
+		fmt.Fprintln(c.out)
+		w := c.table("CONTAINER", "IMAGE", "STATE", "HEALTH", "CPU", "MEMORY", "RESTARTS")
+		for _, container := range agent.Containers {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.1f%%\t%.1f%%\t%d\n",
+				container.Name, container.Image, container.State, container.Health,
+				container.CPUPercent, container.MemoryPercent, container.RestartCount)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(agent.ActiveAlerts) > 0 {
+		fmt.Fprintln(c.out)
+		return c.printAlerts(agent.ActiveAlerts)
+	}
+	return nil
+}
+
+func (c *cli) alerts(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: saviourctl alerts list|ack|resolve|assign|raise|noisy")
+	}
+
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("alerts list", flag.ContinueOnError)
+		status := flags.String("status", "active", "active, acknowledged, resolved or all")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		var alerts []server.Alert
+		if err := c.api.get("/api/v1/alerts?status="+url.QueryEscape(*status), &alerts); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(alerts)
+		}
+		return c.printAlerts(alerts)
+
+	case "ack", "resolve":
+		id, err := oneArg("alerts "+args[0]+" <id>", args[1:])
+		if err != nil {
+			return err
+		}
+
+		var alert server.Alert
+		if err := c.api.do("POST", "/api/v1/alerts/"+url.PathEscape(id)+"/"+args[0], nil, &alert); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(alert)
+		}
+		fmt.Fprintf(c.out, "Alert %s is now %s\n", alert.ID, alert.Status)
+		return nil
+
+	case "assign":
+		flags := flag.NewFlagSet("alerts assign", flag.ContinueOnError)
+		unassign := flags.Bool("clear", false, "remove the assignee")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		rest := flags.Args()
+		if (*unassign && len(rest) != 1) || (!*unassign && len(rest) != 2) {
+			return fmt.Errorf("usage: saviourctl alerts assign <id> <who> | -clear <id>")
+		}
+		var assignee string
+		if !*unassign {
+			assignee = rest[1]
+		}
+
+		var alert server.Alert
+		if err := c.api.do("PUT", "/api/v1/alerts/"+url.PathEscape(rest[0])+"/assign", server.AlertAssignment{Assignee: assignee}, &alert); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(alert)
+		}
+		if alert.Assignee == "" {
+			fmt.Fprintf(c.out, "Alert %s is unassigned\n", alert.ID)
+		} else {
+			fmt.Fprintf(c.out, "Alert %s is assigned to %s\n", alert.ID, alert.Assignee)
+		}
+		return nil
+
+	case "raise":
+		flags := flag.NewFlagSet("alerts raise", flag.ContinueOnError)
+		agent := flags.String("agent", "", "the host the alert is about")
+		alertType := flags.String("type", "", "alert type, e.g. backup_failed (default: external)")
+		severity := flags.String("severity", "", "critical, warning or info (default: warning)")
+		source := flags.String("source", "", "what is raising the alert (default: external)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *agent == "" || flags.NArg() == 0 {
+			return fmt.Errorf("usage: saviourctl alerts raise -agent name [-type t] [-severity s] [-source src] <message>")
+		}
+
+		request := server.ExternalAlertRequest{
+			AgentName: *agent,
+			AlertType: *alertType,
+			Severity:  *severity,
+			Source:    *source,
+			Message:   strings.Join(flags.Args(), " "),
+		}
+
+		var alert server.Alert
+		if err := c.api.do("POST", "/api/v1/alerts/external", request, &alert); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(alert)
+		}
+		if alert.ID == "" {
+			fmt.Fprintln(c.out, "Alert already raised")
+		} else {
+			fmt.Fprintf(c.out, "Alert %s raised\n", alert.ID)
+		}
+		return nil
+
+	case "noisy":
+		flags := flag.NewFlagSet("alerts noisy", flag.ContinueOnError)
+		window := flags.String("window", "7d", "how far back to look, e.g. 7d or 12h")
+		top := flags.Int("top", 10, "how many fingerprints and agents to show")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		var noisy server.NoisyAlerts
+		query := fmt.Sprintf("?window=%s&top=%d", url.QueryEscape(*window), *top)
+		if err := c.api.get("/api/v1/alerts/noisy"+query, &noisy); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(noisy)
+		}
+		fmt.Fprintf(c.out, "%d alerts fired since %s\n\n", noisy.Firings, noisy.From.Local().Format(time.RFC3339))
+		w := c.table("FIRINGS", "TYPE", "AGENT", "SUBJECT", "LAST")
+		for _, f := range noisy.Fingerprints {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", f.Firings, f.AlertType, f.AgentName, orDash(f.Subject), ago(f.LastTriggered))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out)
+		w = c.table("FIRINGS", "AGENT", "FINGERPRINTS")
+		for _, agent := range noisy.Agents {
+			fmt.Fprintf(w, "%d\t%s\t%d\n", agent.Firings, agent.AgentName, agent.Fingerprints)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown alerts command %q", args[0])
+	}
+}
+
+func (c *cli) containers(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: saviourctl containers list [-image i] [-state s] [-health h] [-project p] [-label k[=v]]... [-agent pattern]")
+	}
+
+	var labels stringList
+	flags := flag.NewFlagSet("containers list", flag.ContinueOnError)
+	image := flags.String("image", "", "image name or glob, e.g. redis or nginx:1.19*")
+	state := flags.String("state", "", "container state, e.g. running or exited")
+	health := flags.String("health", "", "health check status, e.g. unhealthy")
+	project := flags.String("project", "", "compose project")
+	agent := flags.String("agent", "", "agent name glob")
+	flags.Var(&labels, "label", "container label, key or key=value (repeatable)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	for name, value := range map[string]string{"image": *image, "state": *state, "health": *health, "project": *project, "agent": *agent} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	for _, label := range labels {
+		query.Add("label", label)
+	}
+	path := "/api/v1/containers"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var containers []server.FleetContainer
+	if err := c.api.get(path, &containers); err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(containers)
+	}
+
+	w := c.table("AGENT", "NAME", "IMAGE", "STATE", "HEALTH", "CPU", "MEMORY", "RESTARTS")
+	for _, container := range containers {
+		agentName := container.AgentName
+		if container.AgentStatus != "online" && container.AgentStatus != "degraded" {
+			agentName += " (" + container.AgentStatus + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%.1f%%\t%.1f%%\t%d\n", agentName, container.Name, container.Image,
+			container.State, orDash(container.Health), container.CPUPercent, container.MemoryPercent, container.RestartCount)
+	}
+	return w.Flush()
+}
+
+func (c *cli) images(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: saviourctl images list [-image i] [-agent pattern]")
+	}
+
+	flags := flag.NewFlagSet("images list", flag.ContinueOnError)
+	image := flags.String("image", "", "image name or glob, e.g. nginx or nginx:1.19*")
+	agent := flags.String("agent", "", "agent name glob")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *image != "" {
+		query.Set("image", *image)
+	}
+	if *agent != "" {
+		query.Set("agent", *agent)
+	}
+	path := "/api/v1/images"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var images []server.ImageInventory
+	if err := c.api.get(path, &images); err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(images)
+	}
+
+	w := c.table("IMAGE", "IMAGE IDS", "CONTAINERS", "RUNNING", "AGENTS")
+	for _, inventory := range images {
+		for _, version := range inventory.Versions {
+			name := version.Image
+			if version.UpdateAvailable {
+				name += " (update available)"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", name, len(version.ImageIDs), version.Containers,
+				version.Running, strings.Join(version.Agents, ","))
+		}
+	}
+	return w.Flush()
+}
+
+func (c *cli) alerting(args []string) error {
+	if len(args) == 0 || (args[0] != "get" && args[0] != "set") || (args[0] == "set" && len(args) < 2) {
+		return fmt.Errorf("usage: saviourctl alerting get | alerting set <setting>=<value>...")
+	}
+
+	var settings server.AlertingSettings
+	if args[0] == "get" {
+		if err := c.api.get("/api/v1/admin/alerting", &settings); err != nil {
+			return err
+		}
+	} else {
+		// Only the given settings change; values are sent as JSON numbers or
+		// booleans where they parse as one, strings (durations) otherwise
+		changes := make(map[string]interface{})
+		for _, arg := range args[1:] {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid setting %q, want <setting>=<value>", arg)
+			}
+			if number, err := strconv.ParseFloat(value, 64); err == nil {
+				changes[key] = number
+			} else if boolean, err := strconv.ParseBool(value); err == nil {
+				changes[key] = boolean
+			} else {
+				changes[key] = value
+			}
+		}
+		if err := c.api.do("PUT", "/api/v1/admin/alerting", changes, &settings); err != nil {
+			return err
+		}
+	}
+	if c.json {
+		return c.printJSON(settings)
+	}
+
+	// One row per setting, alphabetically
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	w := c.table("SETTING", "VALUE")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\n", key, strings.Trim(string(fields[key]), `"`))
+	}
+	return w.Flush()
+}
+
+func (c *cli) metrics(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: saviourctl metrics list [-name n] [-agent pattern] [-service pattern] [-label k[=v]]...")
+	}
+
+	flags := flag.NewFlagSet("metrics list", flag.ContinueOnError)
+	name := flags.String("name", "", "metric name")
+	agent := flags.String("agent", "", "agent name glob")
+	service := flags.String("service", "", "service name glob")
+	var labels stringList
+	flags.Var(&labels, "label", "metric label k or k=v (repeatable)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	for key, value := range map[string]string{"name": *name, "agent": *agent, "service": *service} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	for _, label := range labels {
+		query.Add("label", label)
+	}
+	path := "/api/v1/metrics/custom"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var metrics []server.CustomMetric
+	if err := c.api.get(path, &metrics); err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(metrics)
+	}
+
+	w := c.table("OWNER", "METRIC", "TYPE", "VALUE", "LABELS", "UPDATED")
+	for _, m := range metrics {
+		labels := make([]string, 0, len(m.Labels))
+		for k, v := range m.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%g\t%s\t%s\n", m.Owner(), m.Name, m.Type, m.Value,
+			orDash(strings.Join(labels, ",")), ago(m.UpdatedAt))
+	}
+	return w.Flush()
+}
+
+// printAlerts prints alerts as a table, showing the first line of each message
+func (c *cli) printAlerts(alerts []server.Alert) error {
+	w := c.table("ID", "SEVERITY", "TYPE", "AGENT", "STATUS", "ASSIGNEE", "TRIGGERED", "MESSAGE")
+	for _, alert := range alerts {
+		summary, _, _ := strings.Cut(alert.Message, "\n")
+		assignee := alert.Assignee
+		if assignee == "" {
+			assignee = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			alert.ID, alert.Severity, alert.AlertType, alert.AgentName, alert.Status, assignee, ago(alert.TriggeredAt), summary)
+	}
+	return w.Flush()
+}
+
+func (c *cli) silences(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: saviourctl silences list|add|delete")
+	}
+
+	switch args[0] {
+	case "list":
+		var silences []server.Silence
+		if err := c.api.get("/api/v1/silences", &silences); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(silences)
+		}
+
+		w := c.table("ID", "AGENT", "TYPE", "ENDS", "CREATED BY", "COMMENT")
+		for _, silence := range silences {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				silence.ID, orAny(silence.AgentName), orAny(silence.AlertType),
+				silence.EndsAt.Local().Format(time.RFC3339), silence.CreatedBy, silence.Comment)
+		}
+		return w.Flush()
+
+	case "add":
+		flags := flag.NewFlagSet("silences add", flag.ContinueOnError)
+		agent := flags.String("agent", "", "agent name or glob pattern (default: all agents)")
+		alertType := flags.String("type", "", "alert type, e.g. agent_offline (default: all types)")
+		duration := flags.Duration("duration", 0, "how long to silence for, e.g. 2h")
+		comment := flags.String("comment", "", "why the alerts are silenced")
+		createdBy := flags.String("created-by", envOr("USER", ""), "who created the silence")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *duration <= 0 {
+			return fmt.Errorf("silences add: -duration is required")
+		}
+
+		request := server.Silence{
+			AgentName: *agent,
+			AlertType: *alertType,
+			Comment:   *comment,
+			CreatedBy: *createdBy,
+			EndsAt:    time.Now().Add(*duration),
+		}
+
+		var created server.Silence
+		if err := c.api.do("POST", "/api/v1/silences", request, &created); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(created)
+		}
+		fmt.Fprintf(c.out, "Silence %s created until %s\n", created.ID, created.EndsAt.Local().Format(time.RFC3339))
+		return nil
+
+	case "delete":
+		id, err := oneArg("silences delete <id>", args[1:])
+		if err != nil {
+			return err
+		}
+		if err := c.api.do("DELETE", "/api/v1/silences/"+url.PathEscape(id), nil, nil); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Silence %s removed\n", id)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown silences command %q", args[0])
+	}
+}
+
+// keys manages API keys. Keys live in the server config, so this only
+// generates them; add the printed entry under auth.api_keys and restart.
+func (c *cli) keys(args []string) error {
+	if len(args) == 0 || args[0] != "generate" {
+		return fmt.Errorf("usage: saviourctl keys generate -name n [-scopes s1,s2]")
+	}
+
+	flags := flag.NewFlagSet("keys generate", flag.ContinueOnError)
+	name := flags.String("name", "", "key name, shown in server logs")
+	scopes := flags.String("scopes", "metrics:write,heartbeat:write", "comma-separated scopes (alerts:write for operators)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("keys generate: -name is required")
+	}
+
+	secret, err := setup.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+
+	key := api.APIKey{
+		Key:    secret,
+		Name:   *name,
+		Scopes: strings.Split(*scopes, ","),
+	}
+	if c.json {
+		return c.printJSON(key)
+	}
+
+	quoted := make([]string, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		quoted[i] = fmt.Sprintf("%q", strings.TrimSpace(scope))
+	}
+
+	fmt.Fprintln(c.out, "# Add under auth.api_keys in the server config:")
+	fmt.Fprintf(c.out, "- key: %q\n", key.Key)
+	fmt.Fprintf(c.out, "  name: %q\n", key.Name)
+	fmt.Fprintf(c.out, "  scopes: [%s]\n", strings.Join(quoted, ", "))
+	return nil
+}
+
+// table starts a tab-aligned table with the given header
+func (c *cli) table(columns ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+	return w
+}
+
+func (c *cli) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(c.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// oneArg returns the single positional argument of a command
+func oneArg(usage string, args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("usage: saviourctl %s", usage)
+	}
+	return args[0], nil
+}
+
+// ago formats how long ago t was, e.g. "42s ago"
+func ago(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+// instance describes the cloud instance an agent runs on
+func instance(cloud *server.CloudMetadata) string {
+	if cloud == nil {
+		return "-"
+	}
+	return cloud.Provider + ":" + cloud.InstanceID
+}
+
+// seconds formats a number of seconds as a duration
+func seconds(s float64) string {
+	return (time.Duration(s) * time.Second).Round(time.Second).String()
+}
+
+func orAny(s string) string {
+	if s == "" {
+		return "*"
+	}
+	return s
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,106 @@
+// Command saviourctl manages a Saviour server from the command line:
+// listing agents and alerts, acknowledging and resolving alerts, managing
+// silences, generating API keys and testing alert rules.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/anurag/saviour/internal/version"
+)
+
+const usage = `Usage: saviourctl [flags] <command> [args]
+
+Commands:
+  health                          Show server health
+  version                         Show client and server versions
+  agents list [-tag k[=v]]...     List agents
+  agents get <name>               Show one agent
+  agents delete <name>            Deregister an agent
+  agents refresh <name>           Ask an agent to collect and push right away
+  agents maintenance -duration d [-reason r] <name>
+                                  Skip all alert checks for an agent (-end to stop)
+  agents uptime [-window 30d] [name]
+                                  Availability of the fleet or one agent
+  agents trends <name>            Disk, memory and container growth over 7 and 30 days
+  agents diff <a> <b>             Compare system info, metrics and containers of two agents
+  alerts list [-status s]         List alerts (active, acknowledged, resolved, all)
+  alerts ack <id>                 Acknowledge an alert
+  alerts resolve <id>             Resolve an alert
+  alerts assign <id> <who>        Assign an alert to someone (-clear to unassign)
+  alerts raise -agent a [-type t] [-severity s] [-source src] <message>
+                                  Raise an alert from a script
+  alerts noisy [-window 7d] [-top 10]
+                                  Alerts and agents that fire most often
+  alerting get                    Show the alert engine's thresholds and timeouts
+  alerting set <setting>=<value>...
+                                  Change them at runtime, e.g. system_cpu_threshold=90
+  containers list [-image i] [-state s] [-health h] [-project p] [-label k[=v]] [-agent a]
+                                  Containers across all agents
+  images list [-image i] [-agent a]
+                                  Images in use and the agents running them
+  metrics list [-name n] [-agent a] [-service s] [-label k[=v]]
+                                  Custom metrics pushed by applications
+  silences list                   List active silences
+  silences add -duration d [-agent pattern] [-type alert_type] [-comment c]
+                                  Mute notifications for matching alerts
+  silences delete <id>            Remove a silence
+  keys generate -name n -scopes s Generate an API key for the server config
+  rules test [-config server.yaml] <fixture.json>...
+                                  Check which alert rules fire for saved custom metrics
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("saviourctl", flag.ExitOnError)
+	serverURL := flags.String("server", envOr("SAVIOUR_SERVER", "http://localhost:8080"), "server URL (env SAVIOUR_SERVER)")
+	apiKey := flags.String("api-key", os.Getenv("SAVIOUR_API_KEY"), "API key for write operations (env SAVIOUR_API_KEY)")
+	output := flags.String("o", "table", "output format: table or json")
+	showVersion := flags.Bool("version", false, "print version and exit")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	if *showVersion {
+		fmt.Println(version.String("saviourctl"))
+		return
+	}
+
+	if *output != "table" && *output != "json" {
+		fatalf("invalid output format %q (want table or json)", *output)
+	}
+
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cli := &cli{
+		api:  newAPIClient(*serverURL, *apiKey),
+		json: *output == "json",
+		out:  os.Stdout,
+	}
+
+	if err := cli.run(args); err != nil {
+		fatalf("%v", err)
+	}
+}
+
+// envOr returns the environment variable key, or fallback if it is unset
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "saviourctl: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anurag/saviour/internal/alerting"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// ruleFixture is a snapshot of custom metrics to test rules against, such as
+// the output of saviourctl -o json metrics list. A fixture file holds either
+// the bare list of metrics or this object.
+type ruleFixture struct {
+	Metrics []server.CustomMetric `json:"metrics"`
+
+	// The alerts that must fire, and no others; nil to only report them
+	Expect *[]expectedAlert `json:"expect"`
+}
+
+// expectedAlert is an alert a fixture expects a rule to raise
+type expectedAlert struct {
+	Rule  string `json:"rule"`
+	Owner string `json:"owner,omitempty"` // Agent or service; empty for any
+}
+
+// ruleTestResult is an alert that fires for a fixture, or was expected to
+type ruleTestResult struct {
+	Fixture  string   `json:"fixture"`
+	Rule     string   `json:"rule"`
+	Owner    string   `json:"owner,omitempty"`
+	Series   string   `json:"series,omitempty"`
+	Value    *float64 `json:"value,omitempty"`
+	Severity string   `json:"severity,omitempty"`
+	DryRun   bool     `json:"dry_run,omitempty"`
+	Result   string   `json:"result"` // fires, expected, unexpected or missing
+}
+
+func (c *cli) rules(args []string) error {
+	if len(args) == 0 || args[0] != "test" {
+		return fmt.Errorf("usage: saviourctl rules test [-config server.yaml] <fixture.json>...")
+	}
+
+	flags := flag.NewFlagSet("rules test", flag.ContinueOnError)
+	configPath := flags.String("config", "server.yaml", "server config with the rules under alerting.rules")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: saviourctl rules test [-config server.yaml] <fixture.json>...")
+	}
+
+	cfg, err := server.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if err := server.ValidateAlertRules(cfg.Alerting.Rules); err != nil {
+		return err
+	}
+	rules := make([]alerting.Rule, len(cfg.Alerting.Rules))
+	for i, rule := range cfg.Alerting.Rules {
+		rules[i] = rule.AlertingRule()
+	}
+
+	results := []ruleTestResult{}
+	failed := 0
+	for _, path := range flags.Args() {
+		fixture, err := loadRuleFixture(path)
+		if err != nil {
+			return err
+		}
+		fixtureResults := testRules(filepath.Base(path), rules, fixture)
+		for _, result := range fixtureResults {
+			if result.Result == "unexpected" || result.Result == "missing" {
+				failed++
+				break
+			}
+		}
+		results = append(results, fixtureResults...)
+	}
+
+	if c.json {
+		if err := c.printJSON(results); err != nil {
+			return err
+		}
+	} else if len(results) > 0 {
+		w := c.table("FIXTURE", "RULE", "OWNER", "SERIES", "VALUE", "SEVERITY", "RESULT")
+		for _, r := range results {
+			rule, value := r.Rule, "-"
+			if r.DryRun {
+				rule += " (dry run)"
+			}
+			if r.Value != nil {
+				value = fmt.Sprintf("%g", *r.Value)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Fixture, rule, orAny(r.Owner), orDash(r.Series), value, orDash(r.Severity), r.Result)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d fixtures failed", failed, flags.NArg())
+	}
+	if !c.json {
+		fmt.Fprintf(c.out, "✓ %d rules tested against %d fixtures\n", len(rules), flags.NArg())
+	}
+	return nil
+}
+
+// loadRuleFixture reads a fixture file
+func loadRuleFixture(path string) (*ruleFixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture ruleFixture
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &fixture.Metrics)
+	} else {
+		err = json.Unmarshal(data, &fixture)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid fixture: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// testRules evaluates rules against a fixture. Without expectations every
+// alert that fires is reported; with them, each is expected or unexpected,
+// and expectations nothing matched are missing.
+func testRules(name string, rules []alerting.Rule, fixture *ruleFixture) []ruleTestResult {
+	metrics := make([]alerting.CustomMetric, len(fixture.Metrics))
+	for i := range fixture.Metrics {
+		metrics[i] = fixture.Metrics[i].AlertingMetric()
+	}
+
+	var expect []expectedAlert
+	if fixture.Expect != nil {
+		expect = *fixture.Expect
+	}
+	matched := make([]bool, len(expect))
+
+	var results []ruleTestResult
+	for _, firing := range alerting.EvaluateRules(rules, metrics) {
+		value := firing.Metric.Value
+		result := ruleTestResult{
+			Fixture:  name,
+			Rule:     firing.Rule.Name,
+			Owner:    firing.Metric.Owner(),
+			Series:   firing.Metric.Series(),
+			Value:    &value,
+			Severity: firing.Rule.Severity,
+			DryRun:   firing.Rule.DryRun,
+			Result:   "fires",
+		}
+		if fixture.Expect != nil {
+			result.Result = "unexpected"
+			for i, e := range expect {
+				if e.Rule == result.Rule && (e.Owner == "" || e.Owner == result.Owner) {
+					matched[i] = true
+					result.Result = "expected"
+				}
+			}
+		}
+		results = append(results, result)
+	}
+
+	for i, e := range expect {
+		if !matched[i] {
+			results = append(results, ruleTestResult{Fixture: name, Rule: e.Rule, Owner: e.Owner, Result: "missing"})
+		}
+	}
+	return results
+}
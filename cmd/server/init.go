@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/anurag/saviour/internal/setup"
+)
+
+// runInit implements "saviour-server init": write a starter server.yaml
+// with freshly generated API keys, asking for each value on a terminal
+// unless -y is given
+func runInit(args []string) error {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	output := flags.String("output", "server.yaml", "file to write")
+	port := flags.Int("port", 8080, "HTTP port")
+	webhook := flags.String("google-chat-webhook", "", "Google Chat webhook URL (empty = console notifications)")
+	dashboardURL := flags.String("dashboard-url", "", "dashboard URL linked from alerts")
+	origins := flags.String("allowed-origins", "", "comma-separated CORS origins (empty = dev mode)")
+	yes := flags.Bool("y", false, "don't prompt; use flag values and defaults")
+	force := flags.Bool("force", false, "overwrite an existing file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	p := setup.NewPrompter(os.Stdin, os.Stdout, !*yes && setup.IsTerminal(os.Stdin))
+
+	opts := setup.ServerOptions{}
+	var err error
+	if opts.Port, err = strconv.Atoi(p.String("Port", strconv.Itoa(*port))); err != nil {
+		return fmt.Errorf("invalid port: %w", err)
+	}
+	opts.WebhookURL = p.String("Google Chat webhook URL (empty for console notifications)", *webhook)
+	opts.DashboardURL = p.String("Dashboard URL", *dashboardURL)
+	for _, origin := range strings.Split(p.String("Allowed CORS origins, comma-separated (empty for dev mode)", *origins), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			opts.AllowedOrigins = append(opts.AllowedOrigins, origin)
+		}
+	}
+
+	for _, key := range []*string{&opts.AgentKey, &opts.DashboardKey, &opts.OperatorKey} {
+		if *key, err = setup.GenerateAPIKey(); err != nil {
+			return err
+		}
+	}
+
+	data, err := setup.ServerConfig(opts)
+	if err != nil {
+		return err
+	}
+	if err := setup.WriteConfig(*output, data, *force); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Wrote %s with new agent, dashboard and operator API keys\n", *output)
+	fmt.Printf("\nSet up agents with:\n  saviour-agent init -server-url http://<this-host>:%d -api-key %s\n", opts.Port, opts.AgentKey)
+	fmt.Printf("\nStart the server with: saviour-server -config %s\n", *output)
+	return nil
+}
+
+// serverService describes the server's systemd unit
+func serverService() *setup.Service {
+	return setup.NewService("saviour-server", "Saviour Monitoring Server")
+}
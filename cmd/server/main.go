@@ -1,50 +1,131 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"regexp"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/anurag/saviour/internal/alerting"
 	"github.com/anurag/saviour/internal/api"
+	"github.com/anurag/saviour/internal/deadman"
+	"github.com/anurag/saviour/internal/events"
+	"github.com/anurag/saviour/internal/export"
+	"github.com/anurag/saviour/internal/federation"
+	"github.com/anurag/saviour/internal/ha"
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/report"
+	"github.com/anurag/saviour/internal/scrape"
 	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/internal/setup"
+	"github.com/anurag/saviour/internal/shared"
+	"github.com/anurag/saviour/internal/synthetic"
+	"github.com/anurag/saviour/internal/tracing"
+	"github.com/anurag/saviour/internal/version"
+	"github.com/anurag/saviour/web"
 )
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "init":
+			if err := runInit(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		case "install", "uninstall":
+			if err := setup.RunServiceCommand(serverService(), os.Args[1], os.Args[2:], "/etc/saviour/server.yaml"); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	// Parse command-line flags
 	configPath := flag.String("config", "server.yaml", "Path to server configuration file")
+	showVersion := flag.Bool("version", false, "Print version and exit")
+	checkOnly := flag.Bool("check-config", false, "Validate the config, print it with defaults applied and exit (0 = valid, 1 = invalid)")
 	flag.Parse()
 
+	if *showVersion {
+		fmt.Println(version.String("saviour-server"))
+		return
+	}
+	if *checkOnly {
+		os.Exit(checkConfig(*configPath))
+	}
+
 	// Load configuration
-	log.Printf("Loading configuration from %s", *configPath)
+	slog.Info("Loading configuration", "path", *configPath)
 	cfg, err := server.LoadConfig(*configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("Failed to load config", err)
 	}
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
-		log.Fatalf("Invalid configuration: %v", err)
+		fatal("Invalid configuration", err)
+	}
+
+	// Set up logging; packages tag their records with their component
+	if err := logging.Setup(os.Stdout, cfg.Logging); err != nil {
+		fatal("Invalid configuration", err)
 	}
+	logger := logging.Component("server")
 
-	log.Printf("Starting Saviour Server on %s", cfg.Address())
+	logger.Info("Starting Saviour Server", "version", version.Version, "addr", cfg.Address())
 
 	// Initialize state store
 	state := server.NewStateStore()
 
+	// Time zone notifications show times in, which a channel can override
+	location, err := alerting.LoadTimezone(cfg.Alerting.Timezone)
+	if err != nil {
+		fatal("Invalid alerting timezone", err)
+	}
+
 	// Initialize notifier
 	var notifier alerting.Notifier
+	channel := "console"
 	if cfg.GoogleChat.Enabled {
-		log.Printf("Google Chat notifications enabled")
-		notifier = alerting.NewGoogleChatNotifier(cfg.GoogleChat.WebhookURL, cfg.GoogleChat.DashboardURL)
+		logger.Info("Google Chat notifications enabled")
+		googleChat := alerting.NewGoogleChatNotifier(cfg.GoogleChat.WebhookURL, cfg.GoogleChat.DashboardURL)
+		googleChat.SetLocation(location)
+		if cfg.GoogleChat.Timezone != "" {
+			chatLocation, err := alerting.LoadTimezone(cfg.GoogleChat.Timezone)
+			if err != nil {
+				fatal("Invalid google_chat timezone", err)
+			}
+			googleChat.SetLocation(chatLocation)
+		}
+		notifier = googleChat
+		channel = "google_chat"
 	} else {
-		log.Printf("Using console notifier (Google Chat disabled)")
+		logger.Info("Using console notifier, Google Chat disabled")
 		notifier = alerting.NewConsoleNotifier()
 	}
 
+	// Track whether alerts get through, and verify the channel periodically
+	channels := alerting.NewChannelMonitor(cfg.Alerting.ChannelCheckInterval, cfg.Alerting.ChannelAlertAfter)
+	alertNotifier := channels.Watch(channel, notifier)
+	if webhook := cfg.Webhook; webhook.Enabled {
+		logger.Info("Webhook notifications enabled", "format", webhook.Format)
+		webhookNotifier := alerting.NewWebhookNotifier(webhook.URL, webhook.Format, webhook.Headers, webhook.Timeout)
+		webhookNotifier.SetExternalURL(webhook.ExternalURL)
+		alertNotifier = alerting.Notifiers{alertNotifier, channels.Watch("webhook", webhookNotifier)}
+	}
+
 	// Create adapter for alerting
 	stateAdapter := server.NewAlertingAdapter(state)
 
@@ -58,16 +139,212 @@ func main() {
 		SystemCPUThreshold:    cfg.Alerting.SystemCPUThreshold,
 		SystemMemoryThreshold: cfg.Alerting.SystemMemoryThreshold,
 		SystemDiskThreshold:   cfg.Alerting.SystemDiskThreshold,
+
+		DockerGoroutineThreshold:  cfg.Alerting.DockerGoroutineThreshold,
+		ImageUpdateDigestInterval: cfg.Alerting.ImageUpdateDigestInterval,
+		ContainerOOMKillThreshold: cfg.Alerting.ContainerOOMKillThreshold,
+		AgentRetention:            max(cfg.Alerting.AgentRetention, 0),
+		ClockSkewThreshold:        max(cfg.Alerting.ClockSkewThreshold, 0),
+
+		ContainerCPUThreshold:    cfg.Alerting.ContainerCPUThreshold,
+		ContainerMemoryThreshold: cfg.Alerting.ContainerMemoryThreshold,
+
+		Annotations: cfg.Alerting.Annotations,
+
+		Location: location,
+	}
+	for _, o := range cfg.Alerting.ContainerThresholdOverrides {
+		alertConfig.ContainerThresholdOverrides = append(alertConfig.ContainerThresholdOverrides, alerting.ContainerThresholdOverride{
+			Name:            o.Name,
+			CPUThreshold:    o.CPUThreshold,
+			MemoryThreshold: o.MemoryThreshold,
+		})
+	}
+
+	for _, r := range cfg.Alerting.Rules {
+		alertConfig.Rules = append(alertConfig.Rules, r.AlertingRule())
+	}
+
+	// Settings changed at runtime override the config file
+	if saved, err := server.LoadAlertingSettings(cfg.Alerting.SettingsFile); err != nil {
+		fatal("Failed to load alerting settings", err)
+	} else if saved != nil {
+		if err := saved.Apply(alertConfig); err != nil {
+			fatal("Invalid alerting settings", err, "path", cfg.Alerting.SettingsFile)
+		}
+		logger.Info("Using alerting settings changed at runtime", "path", cfg.Alerting.SettingsFile)
 	}
 
 	// Initialize alert engine
-	alertEngine := alerting.NewEngine(stateAdapter, alertConfig, notifier)
+	alertEngine := alerting.NewEngine(stateAdapter, alertConfig, alertNotifier)
+
+	// Redundant servers elect a leader, which alone checks alerts and
+	// notifies; all of them keep accepting pushes
+	var elector *ha.Elector
+	isLeader := func() bool { return true }
+	if h := cfg.HA; h.Enabled {
+		elector = ha.New(h.NodeID, h.Peers, h.Token, h.LeaseDuration)
+		isLeader = elector.IsLeader
+		alertEngine.SetLeadership(isLeader)
+	}
+
+	// Servers sharing Redis deduplicate, silence and track acks together
+	var sharedState *shared.Store
+	if r := cfg.HA.Redis; r.Enabled {
+		sharedState, err = shared.New(r.URL, r.KeyPrefix, r.Timeout)
+		if err != nil {
+			fatal("Invalid configuration", err)
+		}
+		alertEngine.SetDedupStore(sharedState)
+		state.SetSharedState(sharedState)
+	}
 
 	// Start alert engine in background
 	go alertEngine.Start()
 
+	// Start exporters, stopped on shutdown
+	exportCtx, stopExporters := context.WithCancel(context.Background())
+	electionDone := make(chan struct{})
+	if elector != nil {
+		go func() {
+			defer close(electionDone)
+			elector.Run(exportCtx)
+		}()
+	} else {
+		close(electionDone)
+	}
+	if sharedState != nil {
+		go sharedState.Run(exportCtx, state, cfg.HA.Redis.SyncInterval)
+	}
+	if t := cfg.Tracing; t.Enabled {
+		tracer := tracing.New(t.ServiceName, t.Endpoint, t.Headers, t.SampleRatio)
+		tracing.SetDefault(tracer)
+		go tracer.Run(exportCtx, 5*time.Second)
+	}
+	if cw := cfg.Exporters.CloudWatch; cw.Enabled {
+		exporter := export.NewCloudWatchExporter(cw.Region, cw.Namespace, cw.Endpoint)
+		go export.Run(exportCtx, state, exporter, cw.Interval)
+	}
+	if dd := cfg.Exporters.Datadog; dd.Enabled {
+		exporter := export.NewDatadogExporter(dd.APIKey, dd.Site, dd.Tags)
+		go export.Run(exportCtx, state, exporter, dd.Interval)
+	}
+	if graphite := cfg.Exporters.Graphite; graphite.Enabled {
+		exporter := export.NewGraphiteExporter(graphite.Host, graphite.Port, graphite.Prefix)
+		go export.Run(exportCtx, state, exporter, graphite.Interval)
+	}
+	if kafka := cfg.Events.Kafka; kafka.Enabled {
+		go events.Run(exportCtx, state, events.NewKafkaPublisher(kafka.Brokers, kafka.Topic))
+	}
+	if nats := cfg.Events.NATS; nats.Enabled {
+		publisher, err := events.NewNATSPublisher(nats.URL, nats.Subject)
+		if err != nil {
+			fatal("Invalid configuration", err)
+		}
+		go events.Run(exportCtx, state, publisher)
+	}
+	if mqtt := cfg.Events.MQTT; mqtt.Enabled {
+		publisher, err := events.NewMQTTPublisher(mqtt.URL, mqtt.ClientID, mqtt.AlertTopic, mqtt.StatusTopic, mqtt.QoS)
+		if err != nil {
+			fatal("Invalid configuration", err)
+		}
+		go events.Run(exportCtx, state, publisher)
+	}
+
 	// Initialize API handler
+	state.SetMetricsTimeout(max(cfg.Server.MetricsTimeout, 0))
+	state.SetShutdownGracePeriod(cfg.Server.ShutdownGracePeriod)
+	state.SetHeartbeatTimeoutOverrides(cfg.Alerting.HeartbeatTimeoutOverrides)
 	handler := api.NewHandler(state)
+	var history *server.History
+	if cfg.History.Retention > 0 {
+		history = server.NewHistory(cfg.History.Retention, max(cfg.History.RollupRetention, 0))
+		state.SetHistory(history)
+		handler.SetHistory(history)
+	}
+	if reports := cfg.Reports; reports.Enabled {
+		at, _ := time.Parse("15:04", reports.At)
+		weekday, _ := server.ParseWeekday(reports.Weekday)
+		schedule := report.Schedule{
+			Weekly:  reports.Schedule == "weekly",
+			Weekday: weekday,
+			Hour:    at.Hour(),
+			Minute:  at.Minute(),
+		}
+		// Both notifiers can post reports
+		sender := leaderSender{Sender: notifier.(report.Sender), isLeader: isLeader}
+		go report.Run(exportCtx, state, history, sender, schedule, reports.Top)
+	}
+	handler.OnMetricsPush(alertEngine.Trigger)
+	if cfg.Alerting.Enabled {
+		handler.SetExternalAlerts(alertEngine.RaiseExternal)
+		handler.SetAlertingSettings(alertEngine, cfg.Alerting.SettingsFile)
+		go channels.Run(exportCtx, leaderOnly(alertEngine.RaiseExternal, isLeader))
+	} else {
+		go channels.Run(exportCtx, nil)
+	}
+	handler.SetChannelStatus(channels.Status)
+	if elector != nil {
+		handler.SetHAStatus(elector.Status)
+	}
+	if checks := cfg.Synthetic.Checks; len(checks) > 0 {
+		var raise func(alerting.ExternalAlert) *alerting.Alert
+		if cfg.Alerting.Enabled {
+			raise = leaderOnly(alertEngine.RaiseExternal, isLeader)
+		}
+		go synthetic.Run(exportCtx, state, syntheticChecks(checks), raise)
+	}
+	if configured := cfg.Pings.Checks; len(configured) > 0 {
+		checks := pingChecks(configured)
+		if err := deadman.IssueTokens(cfg.Pings.TokensFile, checks); err != nil {
+			fatal("Failed to issue ping tokens", err, "path", cfg.Pings.TokensFile)
+		}
+		pings := deadman.NewMonitor(checks, state)
+		handler.SetPings(pings)
+		var raise func(alerting.ExternalAlert) *alerting.Alert
+		if cfg.Alerting.Enabled {
+			raise = leaderOnly(alertEngine.RaiseExternal, isLeader)
+		}
+		go pings.Run(exportCtx, raise)
+	}
+	if influx := cfg.Exporters.InfluxDB; influx.Enabled {
+		writer := export.NewInfluxWriter(influx.URL, influx.Org, influx.Bucket, influx.Token, state)
+		handler.OnMetricsPush(writer.Enqueue)
+		go writer.Run(exportCtx)
+	}
+	if f := cfg.Federation; f.Enabled {
+		forwarder := federation.New(federation.Upstream{
+			URL:         f.URL,
+			APIKey:      f.APIKey,
+			Agents:      f.Agents,
+			AgentPrefix: f.AgentPrefix,
+			Interval:    f.Interval,
+			Timeout:     f.Timeout,
+		}, state)
+		handler.OnMetricsPush(forwarder.EnqueueMetrics)
+		handler.OnHeartbeat(forwarder.EnqueueHeartbeat)
+		go forwarder.Run(exportCtx)
+	}
+	handler.SetLimits(api.PayloadLimits{
+		MaxRequestSize: cfg.Server.MaxRequestSize,
+		MaxContainers:  cfg.Server.MaxContainersPerPush,
+		MaxLabels:      cfg.Server.MaxLabelsPerContainer,
+	})
+
+	// Agents in pull mode are scraped, their metrics stored like pushes
+	for _, t := range cfg.Scrape.Targets {
+		scraper, err := scrape.New(scrape.Target{
+			URL:      t.URL,
+			Token:    t.Token,
+			Interval: t.Interval,
+			Timeout:  t.Timeout,
+			CAFile:   t.CAFile,
+		}, state, handler.IngestMetrics)
+		if err != nil {
+			fatal("Invalid scrape target", err, "url", t.URL)
+		}
+		go scraper.Run(exportCtx)
+	}
 
 	// Convert API keys
 	apiKeys := make([]api.APIKey, len(cfg.Auth.APIKeys))
@@ -81,13 +358,39 @@ func main() {
 
 	// Set up authentication
 	authConfig := api.NewAuthConfig(apiKeys)
+	streamSecret := []byte(cfg.Auth.StreamTokens.Secret)
+	if len(streamSecret) == 0 {
+		streamSecret = make([]byte, 32)
+		if _, err := rand.Read(streamSecret); err != nil {
+			fatal("Failed to generate stream token secret", err)
+		}
+	}
+	streamTokens := authConfig.NewStreamTokens(streamSecret, cfg.Auth.StreamTokens.TTL)
 
 	// Set up HTTP routes
 	mux := http.NewServeMux()
 
-	// Metrics endpoints (require metrics:write scope)
+	// Metrics endpoints (require metrics:write scope). Pushes are shed with
+	// 429 when too many are in flight; heartbeats are cheap and stay
+	// unlimited so busy periods don't mark agents offline.
 	metricsAuth := authConfig.AuthMiddleware([]string{"metrics:write"})
-	mux.Handle("/api/v1/metrics/push", metricsAuth(http.HandlerFunc(handler.HandleMetricsPush)))
+	admission := api.AdmissionMiddleware(cfg.Server.MaxInflightPushes, 5*time.Second)
+	mux.Handle("/api/v1/metrics/push", admission(metricsAuth(http.HandlerFunc(handler.HandleMetricsPush))))
+	mux.Handle("/api/v1/metrics/bulk", admission(metricsAuth(http.HandlerFunc(handler.HandleMetricsBulk))))
+	mux.Handle("/api/v1/prom/write", admission(metricsAuth(http.HandlerFunc(handler.HandlePromWrite))))
+
+	// Application metrics (require metrics:custom scope, so applications
+	// can't push agent metrics); listing them needs no auth, like the
+	// dashboard API
+	customAuth := authConfig.AuthMiddleware([]string{"metrics:custom"})
+	pushCustom := admission(customAuth(http.HandlerFunc(handler.HandleCustomMetrics)))
+	mux.HandleFunc("/api/v1/metrics/custom", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			handler.HandleCustomMetrics(w, r)
+			return
+		}
+		pushCustom.ServeHTTP(w, r)
+	})
 
 	// Heartbeat endpoint (require heartbeat:write scope)
 	heartbeatAuth := authConfig.AuthMiddleware([]string{"heartbeat:write"})
@@ -95,53 +398,139 @@ func main() {
 
 	// Health endpoint (no auth required)
 	mux.HandleFunc("/api/v1/health", handler.HandleHealth)
+	mux.HandleFunc("/api/v1/version", handler.HandleVersion)
+
+	// Alert and silence management (require alerts:write scope)
+	alertsAuth := authConfig.AuthMiddleware([]string{"alerts:write"})
 
 	// Dashboard API endpoints (no auth required for now - can add read scope later)
 	mux.HandleFunc("/api/v1/agents", handler.HandleGetAgents)
-	mux.HandleFunc("/api/v1/agents/", handler.HandleGetAgent)
+	// Deregistration also ends offline alerting for the agent, so it takes
+	// the admin scope; maintenance mutes alerts, so it takes the alert
+	// management scope
+	deleteAgent := authConfig.AuthMiddleware(api.DeleteAgentScopes)(http.HandlerFunc(handler.HandleDeleteAgent))
+	maintenance := alertsAuth(http.HandlerFunc(handler.HandleAgentMaintenance))
+	refresh := authConfig.AuthMiddleware(api.RefreshAgentScopes)(http.HandlerFunc(handler.HandleAgentRefresh))
+	mux.HandleFunc("/api/v1/agents/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/maintenance") {
+			maintenance.ServeHTTP(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/uptime") {
+			handler.HandleAgentUptime(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/trends") {
+			handler.HandleAgentTrends(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/refresh") {
+			refresh.ServeHTTP(w, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			deleteAgent.ServeHTTP(w, r)
+			return
+		}
+		handler.HandleGetAgent(w, r)
+	})
+	mux.HandleFunc("/api/v1/agents/diff", handler.HandleAgentDiff)
+	mux.HandleFunc("/api/v1/uptime", handler.HandleFleetUptime)
+	mux.HandleFunc("/api/v1/containers", handler.HandleGetContainers)
+	mux.HandleFunc("/api/v1/images", handler.HandleGetImages)
+	mux.HandleFunc("/api/v1/percentiles", handler.HandlePercentiles)
+	mux.HandleFunc("/api/v1/heatmap", handler.HandleHeatmap)
 	mux.HandleFunc("/api/v1/alerts", handler.HandleGetAlerts)
-	mux.HandleFunc("/api/v1/events", handler.HandleEventsSSE)
-
-	// Serve static files from web/dist (if exists)
-	fileServer := http.FileServer(http.Dir("./web/dist"))
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// API routes are already handled above
-		if r.URL.Path == "/" || r.URL.Path == "/index.html" {
-			// Serve index.html for root and index
-			http.ServeFile(w, r, "./web/dist/index.html")
+	mux.HandleFunc("/api/v1/alerts/noisy", handler.HandleNoisyAlerts)
+	mux.HandleFunc("/api/v1/alerts/dry-run", handler.HandleDryRunAlerts)
+	mux.Handle("/api/v1/alerts/", alertsAuth(http.HandlerFunc(handler.HandleAlertAction)))
+
+	// Alerts from other systems (require alerts:create scope, so scripts can
+	// raise alerts without managing them)
+	externalAuth := authConfig.AuthMiddleware([]string{"alerts:create"})
+	mux.Handle("/api/v1/alerts/external", externalAuth(http.HandlerFunc(handler.HandleExternalAlert)))
+	mux.Handle("/api/v1/integrations/alertmanager", externalAuth(http.HandlerFunc(handler.HandleAlertmanagerWebhook)))
+
+	// Dead man's switch pings (the token in the URL authenticates them, so
+	// cron jobs need no API key); listing them reveals the tokens, so it
+	// needs an alerts:create key
+	mux.HandleFunc("/api/v1/ping/", handler.HandlePing)
+	mux.Handle("/api/v1/pings", externalAuth(http.HandlerFunc(handler.HandleGetPings)))
+	silences := alertsAuth(http.HandlerFunc(handler.HandleSilences))
+	mux.HandleFunc("/api/v1/silences", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			handler.HandleSilences(w, r)
 			return
 		}
-
-		// Check if requesting an API endpoint
-		if len(r.URL.Path) >= 4 && r.URL.Path[:4] == "/api" {
-			// API route not found
-			http.NotFound(w, r)
+		silences.ServeHTTP(w, r)
+	})
+	mux.Handle("/api/v1/silences/", alertsAuth(http.HandlerFunc(handler.HandleDeleteSilence)))
+
+	// Live tuning of the alert engine (require admin:write scope to change)
+	adminAuth := authConfig.AuthMiddleware([]string{"admin:write"})
+	alertingSettings := adminAuth(http.HandlerFunc(handler.HandleAlertingSettings))
+	mux.HandleFunc("/api/v1/admin/alerting", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			handler.HandleAlertingSettings(w, r)
 			return
 		}
-
-		// Try serving static file, fallback to index.html for SPA routing
-		fileServer.ServeHTTP(w, r)
+		alertingSettings.ServeHTTP(w, r)
 	})
 
+	// Streams take a stream token in the query string, since browsers can't
+	// set headers on EventSource; any API key can mint one
+	streamAuth := streamTokens.Middleware(cfg.Auth.StreamTokens.Required)
+	mux.Handle("/api/v1/events", streamAuth(http.HandlerFunc(handler.HandleEventsSSE)))
+	mux.Handle("/api/v1/auth/stream-token", authConfig.AuthMiddleware(nil)(http.HandlerFunc(streamTokens.HandleMint)))
+
+	// Grafana JSON datasource over the metrics and alert history (no auth
+	// required, like the dashboard API)
+	mux.HandleFunc("/api/v1/grafana", handler.HandleGrafana)
+	mux.HandleFunc("/api/v1/grafana/", handler.HandleGrafana)
+
+	// Fleet metrics for Prometheus to scrape (no auth required, like the
+	// dashboard API)
+	mux.Handle("/metrics/fleet", export.FleetMetricsHandler(state))
+
+	// Leader election between redundant servers (authenticated with the
+	// shared ha.token rather than an API key)
+	if elector != nil {
+		mux.Handle("/api/v1/ha/lease", elector)
+	}
+
+	// Serve the dashboard embedded at build time, or from disk in development
+	dashboard := web.Dist()
+	if cfg.Server.WebDir != "" {
+		logger.Info("Serving dashboard from disk", "path", cfg.Server.WebDir)
+		dashboard = os.DirFS(cfg.Server.WebDir)
+	}
+	mux.Handle("/", api.DashboardHandler(dashboard))
+
 	// Apply middleware
 	var finalHandler http.Handler = mux
 
 	// Apply CORS middleware if enabled
 	if cfg.CORS.Enabled {
 		corsConfig := &api.CORSConfig{
-			AllowedOrigins: cfg.CORS.AllowedOrigins,
-			DevMode:        cfg.CORS.DevMode,
+			AllowedOrigins:   cfg.CORS.AllowedOrigins,
+			DevMode:          cfg.CORS.DevMode,
+			AllowedMethods:   cfg.CORS.AllowedMethods,
+			AllowedHeaders:   cfg.CORS.AllowedHeaders,
+			ExposedHeaders:   cfg.CORS.ExposedHeaders,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           cfg.CORS.MaxAge,
 		}
 		finalHandler = api.CORSMiddleware(corsConfig)(finalHandler)
 		if cfg.CORS.DevMode {
-			log.Println("CORS enabled in development mode (allowing all origins)")
+			logger.Info("CORS enabled in development mode, allowing all origins")
 		} else {
-			log.Printf("CORS enabled with allowed origins: %v", cfg.CORS.AllowedOrigins)
+			logger.Info("CORS enabled", "allowed_origins", cfg.CORS.AllowedOrigins)
 		}
 	}
 
-	// Apply logging middleware
+	// Apply logging and tracing middleware
 	finalHandler = api.LoggingMiddleware(finalHandler)
+	finalHandler = api.TracingMiddleware(finalHandler)
 
 	// Start HTTP server
 	httpServer := &http.Server{
@@ -155,26 +544,159 @@ func main() {
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 
-		log.Println("Shutting down server...")
+		logger.Info("Shutting down server")
+		stopExporters()
+		<-electionDone // Hand leadership over before going away
 		if err := httpServer.Close(); err != nil {
-			log.Printf("Error closing server: %v", err)
+			logger.Error("Error closing server", logging.Err(err))
 		}
 	}()
 
 	// Start server
-	log.Printf("Server listening on %s", cfg.Address())
-	log.Printf("Endpoints:")
-	log.Printf("  POST /api/v1/metrics/push  - Receive metrics from agents")
-	log.Printf("  POST /api/v1/heartbeat     - Receive heartbeat from agents")
-	log.Printf("  GET  /api/v1/health        - Health check")
-	log.Printf("  GET  /api/v1/agents        - List all agents")
-	log.Printf("  GET  /api/v1/agents/:name  - Get specific agent")
-	log.Printf("  GET  /api/v1/alerts        - List all alerts")
-	log.Printf("  GET  /api/v1/events        - Server-Sent Events stream")
+	logger.Info("Server listening", "addr", cfg.Address())
+	for _, e := range endpoints {
+		logger.Debug("Endpoint", "method", e.method, "path", e.path, "description", e.description)
+	}
 
 	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Server failed: %v", err)
+		fatal("Server failed", err)
+	}
+
+	logger.Info("Server stopped")
+}
+
+// syntheticChecks converts the configured synthetic checks, which
+// LoadConfig has validated
+func syntheticChecks(configured []server.SyntheticCheck) []synthetic.Check {
+	checks := make([]synthetic.Check, 0, len(configured))
+	for _, c := range configured {
+		check := synthetic.Check{
+			Name:       c.Name,
+			Type:       c.Type,
+			Target:     c.Target,
+			Interval:   c.Interval,
+			Timeout:    c.Timeout,
+			Severity:   c.Severity,
+			MaxLatency: c.MaxLatency,
+			Status:     c.Status,
+		}
+		if c.BodyMatch != "" {
+			check.BodyMatch = regexp.MustCompile(c.BodyMatch)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkConfig loads and validates the config at path and prints the
+// effective configuration, for CI and config management pre-checks. It
+// returns the exit code: 0 if the config is valid, 1 if not.
+func checkConfig(path string) int {
+	cfg, err := server.LoadConfig(path)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err == nil {
+		err = setup.PrintConfig(os.Stdout, cfg)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: invalid configuration: %v\n", path, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%s: configuration OK\n", path)
+	return 0
+}
+
+// pingChecks converts the configured ping checks, which LoadConfig has
+// validated
+func pingChecks(configured []server.PingCheck) []deadman.Check {
+	checks := make([]deadman.Check, 0, len(configured))
+	for _, c := range configured {
+		checks = append(checks, deadman.Check{
+			Name:     c.Name,
+			Token:    c.Token,
+			Period:   c.Period,
+			Grace:    c.Grace,
+			Host:     c.Host,
+			Severity: c.Severity,
+		})
+	}
+	return checks
+}
+
+// leaderOnly wraps raise to drop alerts while another server leads, since
+// the leader raises them too
+func leaderOnly(raise func(alerting.ExternalAlert) *alerting.Alert, isLeader func() bool) func(alerting.ExternalAlert) *alerting.Alert {
+	return func(alert alerting.ExternalAlert) *alerting.Alert {
+		if !isLeader() {
+			return nil
+		}
+		return raise(alert)
+	}
+}
+
+// leaderSender sends reports only while this server leads
+type leaderSender struct {
+	report.Sender
+	isLeader func() bool
+}
+
+// SendReport sends the report if this server leads
+func (s leaderSender) SendReport(title, text string) error {
+	if !s.isLeader() {
+		return nil
 	}
+	return s.Sender.SendReport(title, text)
+}
+
+// fatal logs an error, with any attributes, and exits
+func fatal(msg string, err error, args ...interface{}) {
+	slog.Error(msg, append(args, logging.Err(err))...)
+	os.Exit(1)
+}
 
-	log.Println("Server stopped")
+// endpoints are logged at startup, at debug level
+var endpoints = []struct {
+	method, path, description string
+}{
+	{"POST", "/api/v1/metrics/push", "Receive metrics from agents"},
+	{"POST", "/api/v1/metrics/bulk", "Receive newline-delimited metrics pushes"},
+	{"POST", "/api/v1/prom/write", "Prometheus remote_write receiver"},
+	{"POST", "/api/v1/metrics/custom", "Receive application gauges and counters"},
+	{"GET", "/api/v1/metrics/custom", "List application metrics (?name=&agent=&service=)"},
+	{"POST", "/api/v1/heartbeat", "Receive heartbeat from agents"},
+	{"GET", "/api/v1/health", "Health check, with notification channel status"},
+	{"GET", "/api/v1/version", "Server build information"},
+	{"GET", "/api/v1/agents", "List all agents"},
+	{"GET", "/api/v1/agents/:name", "Get specific agent"},
+	{"DELETE", "/api/v1/agents/:name", "Deregister an agent"},
+	{"GET", "/api/v1/agents/:name/uptime", "Availability of an agent (?window=30d)"},
+	{"GET", "/api/v1/agents/:name/trends", "Disk, memory and container growth with projections"},
+	{"POST", "/api/v1/agents/:name/refresh", "Ask an agent to collect and push right away"},
+	{"GET", "/api/v1/agents/diff?a=:name&b=:name", "Compare two agents"},
+	{"GET", "/api/v1/uptime", "Availability of the fleet (?window=30d)"},
+	{"GET", "/api/v1/containers", "Containers across all agents (?image=redis&state=running&...)"},
+	{"GET", "/api/v1/images", "Images in use, by tag, with the agents running them (?image=nginx)"},
+	{"GET", "/api/v1/percentiles", "p50/p95/p99 CPU and memory per agent (?window=24h&agent=web-*)"},
+	{"GET", "/api/v1/heatmap", "Distribution of a metric over time (?metric=cpu_percent&window=24h)"},
+	{"GET", "/api/v1/alerts", "List all alerts"},
+	{"GET", "/api/v1/alerts/noisy", "Most frequently firing alerts and agents (?window=7d&top=10)"},
+	{"GET", "/api/v1/alerts/dry-run", "Alerts dry-run rules would have raised (?rule=name)"},
+	{"POST", "/api/v1/alerts/:id/ack", "Acknowledge an alert"},
+	{"POST", "/api/v1/alerts/:id/resolve", "Resolve an alert"},
+	{"PUT", "/api/v1/alerts/:id/assign", "Assign an alert to someone"},
+	{"POST", "/api/v1/alerts/external", "Raise an alert from another system"},
+	{"POST", "/api/v1/integrations/alertmanager", "Receive alerts from Prometheus Alertmanager's webhook"},
+	{"GET", "/api/v1/pings", "Ping checks with their ping URLs and state"},
+	{"GET", "/api/v1/ping/:token", "Ping a check from a cron job or script"},
+	{"GET", "/api/v1/silences", "List active silences"},
+	{"POST", "/api/v1/silences", "Create a silence"},
+	{"DELETE", "/api/v1/silences/:id", "Remove a silence"},
+	{"GET", "/api/v1/admin/alerting", "Alert thresholds, deduplication and heartbeat timeout"},
+	{"PUT", "/api/v1/admin/alerting", "Change them at runtime (saved across restarts)"},
+	{"GET", "/api/v1/events", "Server-Sent Events stream (?token=stream token)"},
+	{"POST", "/api/v1/auth/stream-token", "Mint a short-lived token for /api/v1/events"},
+	{"POST", "/api/v1/ha/lease", "Leader election between redundant servers"},
+	{"POST", "/api/v1/grafana/query", "Grafana JSON datasource (also /search, /annotations)"},
+	{"GET", "/metrics/fleet", "Fleet metrics for Prometheus"},
 }
@@ -4,14 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-	"path/filepath"
-	"strings"
+	"log/slog"
+	"net"
+	"sync"
 	"time"
 
 	"github.com/anurag/saviour/internal/collector"
 	"github.com/anurag/saviour/internal/config"
 	"github.com/anurag/saviour/internal/docker"
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/version"
 	"github.com/anurag/saviour/pkg/metrics"
 )
 
@@ -20,17 +22,29 @@ type Agent struct {
 	config          *config.Config
 	systemCollector *collector.SystemCollector
 	dockerCollector *collector.DockerCollector
+	ecsCollector    *collector.ECSCollector // Used instead of Docker inside ECS tasks without a socket
 	sender          *Sender
-	logger          *log.Logger
+	pull            *pullServer // nil unless the server scrapes the agent
+	metadata        *Sender     // Cloud metadata for scrapes: the sender, or one that never sends
+	logger          *slog.Logger
 	lastMetrics     *metrics.SystemMetrics // Store last collected metrics for push
+
+	labelWarnings       map[string]bool                      // Containers already warned about bad threshold labels
+	remediator          *Remediator                          // nil unless remediation is enabled
+	pendingRemediations map[string]metrics.RemediationAction // Actions not yet pushed, by container ID
+	watchdog            *ProcessWatchdog                     // nil unless processes are configured
+
+	terminationReason string // Set once the instance is scheduled for termination
+	rebalanceNotified bool
 }
 
 // New creates a new agent instance
-func New(cfg *config.Config, logger *log.Logger) (*Agent, error) {
+func New(cfg *config.Config, logger *slog.Logger) (*Agent, error) {
 	agent := &Agent{
 		config:          cfg,
 		systemCollector: collector.NewSystemCollector(cfg.Agent.Name, cfg.Metrics.DiskMounts),
 		logger:          logger,
+		labelWarnings:   make(map[string]bool),
 	}
 
 	// Initialize Docker collector if enabled
@@ -40,35 +54,105 @@ func New(cfg *config.Config, logger *log.Logger) (*Agent, error) {
 			Labels:     cfg.Metrics.Docker.Filters.Labels,
 			Names:      cfg.Metrics.Docker.Filters.Names,
 			Images:     cfg.Metrics.Docker.Filters.Images,
+
+			ExcludeLabels: cfg.Metrics.Docker.Filters.ExcludeLabels,
+			ExcludeNames:  cfg.Metrics.Docker.Filters.ExcludeNames,
+			ExcludeImages: cfg.Metrics.Docker.Filters.ExcludeImages,
 		}
 
 		dockerCollector, err := collector.NewDockerCollector(
 			cfg.Metrics.Docker.Socket,
 			filterConfig,
-			logger,
+			logger.With("component", "collector"),
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to initialize Docker collector: %w", err)
+			// Fargate tasks have no Docker socket, but the task metadata
+			// endpoint reports the same container state and stats
+			uri := docker.ECSMetadataURI()
+			if uri == "" {
+				return nil, fmt.Errorf("failed to initialize Docker collector: %w", err)
+			}
+			agent.ecsCollector = collector.NewECSCollector(uri)
+			logger.Info("ECS task monitoring enabled, Docker socket unavailable", "metadata_uri", uri)
+		} else {
+			agent.initDocker(dockerCollector)
 		}
-		agent.dockerCollector = dockerCollector
-		logger.Println("✓ Docker monitoring enabled")
+	}
+
+	if len(cfg.Metrics.Processes) > 0 {
+		agent.watchdog = NewProcessWatchdog(cfg.Metrics.Processes, logger.With("component", "watchdog"))
+		logger.Info("Process watchdog enabled", "processes", len(cfg.Metrics.Processes))
 	}
 
 	// Initialize sender if server URL is configured
 	if cfg.Agent.ServerURL != "" {
 		agent.sender = NewSender(cfg.Agent.ServerURL, cfg.Agent.APIKey)
-		logger.Printf("✓ Server push enabled: %s", cfg.Agent.ServerURL)
-	} else {
-		logger.Println("⚠️  No server URL configured - metrics will only be logged locally")
+		agent.sender.ConfigureTransport(cfg.Agent.Transport)
+		agent.sender.SetPushTimeout(cfg.Agent.PushTimeout)
+		agent.sender.SetMaxRetryAfter(cfg.Agent.PushInterval)
+		agent.sender.SetHeartbeatInterval(cfg.Agent.HeartbeatInterval)
+		agent.sender.SetPayloadEncoding(cfg.Agent.PayloadEncoding)
+		if cfg.Agent.IMDSEndpoint != "" {
+			agent.sender.SetIMDSEndpoint(cfg.Agent.IMDSEndpoint)
+		}
+		if cfg.Agent.DeltaPush.Enabled {
+			agent.sender.EnableDeltaPush(cfg.Agent.DeltaPush.FullInterval)
+		}
+		logger.Info("Server push enabled", "server_url", cfg.Agent.ServerURL)
+		agent.metadata = agent.sender
+	} else if !cfg.Agent.Pull.Enabled {
+		logger.Warn("No server URL configured, metrics will only be logged locally")
+	}
+
+	if cfg.Agent.Pull.Enabled {
+		agent.pull = newPullServer(cfg.Agent.Pull.Token, logger.With("component", "pull"))
+		if agent.metadata == nil {
+			agent.metadata = NewSender("", "")
+			if cfg.Agent.IMDSEndpoint != "" {
+				agent.metadata.SetIMDSEndpoint(cfg.Agent.IMDSEndpoint)
+			}
+		}
+	}
+
+	// Cloud metadata is attached to pushes and scrapes once detected,
+	// without delaying startup off-cloud
+	if agent.metadata != nil && cfg.Agent.CloudProvider != "none" {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			agent.metadata.DetectCloudMetadata(ctx, cfg.Agent.CloudProvider)
+		}()
 	}
 
 	return agent, nil
 }
 
+// initDocker applies the Docker-specific options to a connected collector
+func (a *Agent) initDocker(dockerCollector *collector.DockerCollector) {
+	cfg := a.config
+
+	dockerCollector.SetCollectionLimits(cfg.Metrics.Docker.CollectWorkers, cfg.Metrics.Docker.CollectTimeout)
+	if cfg.Metrics.Docker.StreamStats {
+		dockerCollector.EnableStatsStreaming()
+	}
+	a.dockerCollector = dockerCollector
+	a.logger.Info("Docker monitoring enabled")
+
+	if cfg.Metrics.Docker.Remediation.Enabled {
+		a.remediator = NewRemediator(dockerCollector, cfg.Metrics.Docker.Remediation.Policies, a.logger.With("component", "remediation"))
+		a.pendingRemediations = make(map[string]metrics.RemediationAction)
+		a.logger.Info("Container remediation enabled", "policies", len(cfg.Metrics.Docker.Remediation.Policies))
+	}
+
+	if cfg.Metrics.Docker.ImageUpdateCheck.Enabled {
+		dockerCollector.EnableImageUpdateCheck(cfg.Metrics.Docker.ImageUpdateCheck.Interval)
+		a.logger.Info("Image update check enabled", "interval", cfg.Metrics.Docker.ImageUpdateCheck.Interval)
+	}
+}
+
 // Run starts the agent's main loop
 func (a *Agent) Run(ctx context.Context) error {
-	a.logger.Printf("Agent '%s' starting...", a.config.Agent.Name)
-	a.logger.Printf("Collection interval: %v", a.config.Agent.CollectInterval)
+	a.logger.Info("Agent starting", "collect_interval", a.config.Agent.CollectInterval)
 
 	// Collection ticker
 	collectTicker := time.NewTicker(a.config.Agent.CollectInterval)
@@ -79,7 +163,7 @@ func (a *Agent) Run(ctx context.Context) error {
 	if a.sender != nil {
 		pushTicker = time.NewTicker(a.config.Agent.PushInterval)
 		defer pushTicker.Stop()
-		a.logger.Printf("Push interval: %v", a.config.Agent.PushInterval)
+		a.logger.Info("Pushing metrics", "push_interval", a.config.Agent.PushInterval)
 	}
 
 	// Heartbeat ticker (if server configured)
@@ -87,24 +171,49 @@ func (a *Agent) Run(ctx context.Context) error {
 	if a.sender != nil {
 		heartbeatTicker = time.NewTicker(a.config.Agent.HeartbeatInterval)
 		defer heartbeatTicker.Stop()
-		a.logger.Printf("Heartbeat interval: %v", a.config.Agent.HeartbeatInterval)
+		a.logger.Info("Sending heartbeats", "heartbeat_interval", a.config.Agent.HeartbeatInterval)
+	}
+
+	// Metadata refresh and termination notice tickers (if server configured)
+	var metadataTicker, terminationTicker *time.Ticker
+	if a.sender != nil {
+		metadataTicker = time.NewTicker(a.config.Agent.MetadataRefreshInterval)
+		defer metadataTicker.Stop()
+		terminationTicker = time.NewTicker(terminationCheckInterval)
+		defer terminationTicker.Stop()
+	}
+
+	// Serve scrapes (if pull mode is enabled)
+	if a.pull != nil {
+		listener, err := net.Listen("tcp", a.config.Agent.Pull.Listen)
+		if err != nil {
+			return fmt.Errorf("failed to listen for scrapes: %w", err)
+		}
+		go a.pull.serve(ctx, listener, a.config.Agent.Pull)
 	}
 
 	// Collect immediately on start
 	if err := a.collectAndProcess(); err != nil {
-		a.logger.Printf("Error during initial collection: %v", err)
+		a.logger.Error("Initial collection failed", logging.Err(err))
 	}
 
 	// Main loop
 	for {
 		select {
 		case <-ctx.Done():
-			a.logger.Println("Agent shutting down...")
+			a.logger.Info("Agent shutting down")
+			if a.sender != nil {
+				if a.config.Agent.DeregisterOnShutdown {
+					a.deregister()
+				} else {
+					a.notifyStopping()
+				}
+			}
 			return ctx.Err()
 
 		case <-collectTicker.C:
 			if err := a.collectAndProcess(); err != nil {
-				a.logger.Printf("Error collecting metrics: %v", err)
+				a.logger.Error("Collection failed", logging.Err(err))
 			}
 
 		case <-func() <-chan time.Time {
@@ -115,9 +224,10 @@ func (a *Agent) Run(ctx context.Context) error {
 		}():
 			if a.lastMetrics != nil {
 				if err := a.pushMetrics(ctx); err != nil {
-					a.logger.Printf("Error pushing metrics: %v", err)
+					a.logger.Error("Metrics push failed", logging.Err(err))
 				} else {
-					a.logger.Println("✓ Metrics pushed to server")
+					a.logger.Debug("Metrics pushed to server")
+					a.refreshIfRequested(ctx)
 				}
 			}
 
@@ -128,10 +238,29 @@ func (a *Agent) Run(ctx context.Context) error {
 			return make(chan time.Time) // Never fires
 		}():
 			if err := a.sendHeartbeat(ctx); err != nil {
-				a.logger.Printf("Error sending heartbeat: %v", err)
+				a.logger.Error("Heartbeat failed", logging.Err(err))
 			} else {
-				a.logger.Println("♥ Heartbeat sent")
+				a.logger.Debug("Heartbeat sent")
+				a.refreshIfRequested(ctx)
 			}
+
+		case <-func() <-chan time.Time {
+			if metadataTicker != nil {
+				return metadataTicker.C
+			}
+			return make(chan time.Time) // Never fires
+		}():
+			if err := a.sender.RefreshCloudMetadata(ctx); err != nil {
+				a.logger.Error("Cloud metadata refresh failed", logging.Err(err))
+			}
+
+		case <-func() <-chan time.Time {
+			if terminationTicker != nil {
+				return terminationTicker.C
+			}
+			return make(chan time.Time) // Never fires
+		}():
+			a.checkTermination(ctx)
 		}
 	}
 }
@@ -141,7 +270,31 @@ func (a *Agent) pushMetrics(ctx context.Context) error {
 	if a.sender == nil {
 		return nil
 	}
-	return a.sender.PushMetrics(ctx, a.lastMetrics)
+	if err := a.sender.PushMetrics(ctx, a.lastMetrics); err != nil {
+		return err
+	}
+
+	// Remediations are attached to every snapshot until the server has them
+	if len(a.pendingRemediations) > 0 {
+		a.pendingRemediations = make(map[string]metrics.RemediationAction)
+	}
+	return nil
+}
+
+// refreshIfRequested collects and pushes right away if the server asked,
+// e.g. because someone clicked refresh on the dashboard
+func (a *Agent) refreshIfRequested(ctx context.Context) {
+	if !a.sender.TakeRefreshRequest() {
+		return
+	}
+	a.logger.Debug("Refresh requested by the server")
+	if err := a.collectAndProcess(); err != nil {
+		a.logger.Error("Collection failed", logging.Err(err))
+		return
+	}
+	if err := a.pushMetrics(ctx); err != nil {
+		a.logger.Error("Metrics push failed", logging.Err(err))
+	}
 }
 
 // sendHeartbeat sends a heartbeat to the server
@@ -149,58 +302,107 @@ func (a *Agent) sendHeartbeat(ctx context.Context) error {
 	if a.sender == nil {
 		return nil
 	}
+	if a.terminationReason != "" {
+		return a.sender.SendStatus(ctx, a.config.Agent.Name, "terminating", a.terminationReason)
+	}
 	return a.sender.SendHeartbeat(ctx, a.config.Agent.Name)
 }
 
 func (a *Agent) collectAndProcess() error {
 	ctx := context.Background()
 	
-	// Collect system metrics
-	m, err := a.systemCollector.Collect()
-	if err != nil {
-		return fmt.Errorf("collection failed: %w", err)
+	// Collect system, container and Docker daemon metrics concurrently, so
+	// collection takes as long as the slowest rather than all of them
+	var (
+		wg            sync.WaitGroup
+		m             *metrics.SystemMetrics
+		containers    []docker.ContainerInfo
+		containersErr error
+		daemon        *metrics.DockerMetrics
+		daemonErr     error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m = a.systemCollector.Collect()
+	}()
+	// Containers come from Docker, or from the task metadata endpoint
+	// inside ECS tasks
+	collectContainers := a.dockerCollector != nil || a.ecsCollector != nil
+	if collectContainers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			containers, containersErr = a.collectContainers(ctx)
+		}()
 	}
-
-	// Collect Docker container metrics if enabled
 	if a.dockerCollector != nil {
-		containers, err := a.dockerCollector.Collect(ctx)
-		if err != nil {
-			a.logger.Printf("Warning: Docker collection failed: %v", err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			daemon, daemonErr = a.dockerCollector.CollectDaemon(ctx)
+		}()
+	}
+	wg.Wait()
+
+	// Sources that failed are listed in CollectorErrors
+	for _, collectorErr := range m.CollectorErrors {
+		a.logger.Warn("System collection failed", "error", collectorErr)
+	}
+	if collectContainers {
+		if containersErr != nil {
+			a.logger.Warn("Container collection failed", logging.Err(containersErr))
+			m.CollectorErrors = append(m.CollectorErrors, "containers: "+containersErr.Error())
 		} else {
-			// Convert docker.ContainerInfo to metrics.ContainerMetrics
-			m.Containers = make([]metrics.ContainerMetrics, len(containers))
-			for i, c := range containers {
-				m.Containers[i] = metrics.ContainerMetrics{
-					ID:              c.ID,
-					Name:            c.Name,
-					Image:           c.Image,
-					ImageID:         c.ImageID,
-					Labels:          c.Labels,
-					State:           c.State,
-					Status:          c.Status,
-					Health:          c.Health,
-					ExitCode:        c.ExitCode,
-					OOMKilled:       c.OOMKilled,
-					RestartCount:    c.RestartCount,
-					Created:         c.Created,
-					StartedAt:       c.StartedAt,
-					FinishedAt:      c.FinishedAt,
-					CPUPercent:      c.CPUPercent,
-					MemoryUsage:     c.MemoryUsage,
-					MemoryLimit:     c.MemoryLimit,
-					MemoryPercent:   c.MemoryPercent,
-					NetworkRxBytes:  c.NetworkRxBytes,
-					NetworkTxBytes:  c.NetworkTxBytes,
-					BlockReadBytes:  c.BlockReadBytes,
-					BlockWriteBytes: c.BlockWriteBytes,
-					PIDs:            c.PIDs,
-				}
+			m.Containers = a.convertContainers(containers)
+		}
+	}
+
+	// Remediations are attached to every snapshot until the server has them
+	if a.pull != nil && a.pull.servedLatest() && len(a.pendingRemediations) > 0 {
+		a.pendingRemediations = make(map[string]metrics.RemediationAction)
+	}
+
+	// Restart stopped containers covered by a remediation policy. Skipped
+	// when collection failed, which would look like every container was
+	// removed and reset their restart attempts.
+	if a.remediator != nil && containersErr == nil {
+		for id, action := range a.remediator.Evaluate(ctx, m.Containers) {
+			a.pendingRemediations[id] = action
+		}
+		for i := range m.Containers {
+			if action, exists := a.pendingRemediations[m.Containers[i].ID]; exists {
+				m.Containers[i].Remediation = &action
 			}
 		}
 	}
 
-	// Store metrics for push
+	// Look for watched processes, restarting dead ones
+	if a.watchdog != nil {
+		processes, err := a.watchdog.Check(ctx)
+		if err != nil {
+			a.logger.Warn("Process collection failed", logging.Err(err))
+			m.CollectorErrors = append(m.CollectorErrors, "processes: "+err.Error())
+		} else {
+			m.Processes = processes
+		}
+	}
+
+	// Docker daemon metrics, collected above
+	if a.dockerCollector != nil {
+		if daemonErr != nil {
+			a.logger.Warn("Docker daemon info failed", logging.Err(daemonErr))
+			daemon = &metrics.DockerMetrics{Error: daemonErr.Error()}
+			m.CollectorErrors = append(m.CollectorErrors, "docker: "+daemonErr.Error())
+		}
+		m.Docker = daemon
+	}
+
+	// Store metrics for push, and for scrapes
 	a.lastMetrics = m
+	if a.pull != nil {
+		a.pull.publish(&PullResponse{MetricsPayload: a.metadata.payload(m), AgentVersion: version.Version})
+	}
 
 	// Process and log metrics
 	if err := a.processMetrics(m); err != nil {
@@ -210,6 +412,63 @@ func (a *Agent) collectAndProcess() error {
 	return nil
 }
 
+// collectContainers reads container info from whichever collector is active
+func (a *Agent) collectContainers(ctx context.Context) ([]docker.ContainerInfo, error) {
+	if a.dockerCollector != nil {
+		return a.dockerCollector.Collect(ctx)
+	}
+	return a.ecsCollector.Collect(ctx)
+}
+
+// convertContainers converts docker.ContainerInfo to metrics.ContainerMetrics,
+// applying threshold labels
+func (a *Agent) convertContainers(containers []docker.ContainerInfo) []metrics.ContainerMetrics {
+	result := make([]metrics.ContainerMetrics, len(containers))
+	for i, c := range containers {
+		result[i] = metrics.ContainerMetrics{
+			ID:                  c.ID,
+			Name:                c.Name,
+			Image:               c.Image,
+			ImageID:             c.ImageID,
+			Labels:              c.Labels,
+			State:               c.State,
+			Status:              c.Status,
+			Health:              c.Health,
+			HealthCheckExitCode: c.HealthCheckExitCode,
+			HealthCheckOutput:   c.HealthCheckOutput,
+			UpdateAvailable:     c.UpdateAvailable,
+			ExitCode:            c.ExitCode,
+			OOMKilled:           c.OOMKilled,
+			StateError:          c.StateError,
+			OOMKillsLastHour:    c.OOMKillsLastHour,
+			RestartCount:        c.RestartCount,
+			Created:             c.Created,
+			StartedAt:           c.StartedAt,
+			FinishedAt:          c.FinishedAt,
+			CPUPercent:          c.CPUPercent,
+			MemoryUsage:         c.MemoryUsage,
+			MemoryLimit:         c.MemoryLimit,
+			MemoryPercent:       c.MemoryPercent,
+			NetworkRxBytes:      c.NetworkRxBytes,
+			NetworkTxBytes:      c.NetworkTxBytes,
+			BlockReadBytes:      c.BlockReadBytes,
+			BlockWriteBytes:     c.BlockWriteBytes,
+			PIDs:                c.PIDs,
+		}
+
+		thresholds, errs := thresholdsFromLabels(c.Labels)
+		result[i].Thresholds = thresholds
+		if len(errs) > 0 && !a.labelWarnings[c.ID] {
+			a.labelWarnings[c.ID] = true
+			for _, err := range errs {
+				a.logger.Warn("Invalid threshold label", "container", c.Name, logging.Err(err))
+			}
+		}
+	}
+
+	return result
+}
+
 func (a *Agent) processMetrics(m *metrics.SystemMetrics) error {
 	// Check alert thresholds
 	a.checkAlerts(m)
@@ -224,24 +483,24 @@ func (a *Agent) processMetrics(m *metrics.SystemMetrics) error {
 func (a *Agent) checkAlerts(m *metrics.SystemMetrics) {
 	// System alerts
 	if m.CPU.UsagePercent > a.config.Alerts.CPUThreshold {
-		a.logger.Printf("⚠️  ALERT: CPU usage (%.2f%%) exceeds threshold (%.2f%%)",
-			m.CPU.UsagePercent, a.config.Alerts.CPUThreshold)
+		a.logger.Warn("CPU usage exceeds threshold",
+			"cpu_percent", m.CPU.UsagePercent, "threshold", a.config.Alerts.CPUThreshold)
 	}
 
 	if m.Memory.UsedPercent > a.config.Alerts.MemoryThreshold {
-		a.logger.Printf("⚠️  ALERT: Memory usage (%.2f%%) exceeds threshold (%.2f%%)",
-			m.Memory.UsedPercent, a.config.Alerts.MemoryThreshold)
+		a.logger.Warn("Memory usage exceeds threshold",
+			"memory_percent", m.Memory.UsedPercent, "threshold", a.config.Alerts.MemoryThreshold)
 	}
 
 	for _, disk := range m.Disk {
 		if disk.UsedPercent > a.config.Alerts.DiskThreshold {
-			a.logger.Printf("⚠️  ALERT: Disk usage on %s (%.2f%%) exceeds threshold (%.2f%%)",
-				disk.MountPoint, disk.UsedPercent, a.config.Alerts.DiskThreshold)
+			a.logger.Warn("Disk usage exceeds threshold",
+				"mount_point", disk.MountPoint, "disk_percent", disk.UsedPercent, "threshold", a.config.Alerts.DiskThreshold)
 		}
 	}
 
 	// Container alerts
-	if a.dockerCollector != nil {
+	if a.dockerCollector != nil || a.ecsCollector != nil {
 		a.checkContainerAlerts(m.Containers)
 	}
 }
@@ -257,18 +516,7 @@ func (a *Agent) checkContainerAlerts(containers []metrics.ContainerMetrics) {
 
 		for _, override := range a.config.Metrics.Docker.Alerts.Overrides {
 			// Support glob-style pattern matching (e.g., "api-*", "worker-*")
-			matched := false
-			if strings.Contains(override.Name, "*") || strings.Contains(override.Name, "?") {
-				// Use glob pattern matching
-				if match, err := filepath.Match(override.Name, container.Name); err == nil && match {
-					matched = true
-				}
-			} else {
-				// Exact match
-				matched = (container.Name == override.Name)
-			}
-
-			if matched {
+			if matchContainerName(override.Name, container.Name) {
 				if override.CPUThreshold > 0 {
 					cpuThreshold = override.CPUThreshold
 				}
@@ -282,161 +530,98 @@ func (a *Agent) checkContainerAlerts(containers []metrics.ContainerMetrics) {
 			}
 		}
 
+		// Thresholds from container labels take precedence over config
+		if t := container.Thresholds; t != nil {
+			if t.CPUThreshold > 0 {
+				cpuThreshold = t.CPUThreshold
+			}
+			if t.MemoryThreshold > 0 {
+				memThreshold = t.MemoryThreshold
+			}
+			if t.RestartThreshold > 0 {
+				restartThreshold = t.RestartThreshold
+			}
+		}
+
+		logger := a.logger.With("container", container.Name)
+
 		// Container state alerts
 		if container.State == "exited" {
-			a.logger.Printf("💀 ALERT: Container '%s' stopped (exit code: %d)",
-				container.Name, container.ExitCode)
+			logger.Warn("Container stopped", "exit_code", container.ExitCode)
 		}
 
 		if container.Health == "unhealthy" {
-			a.logger.Printf("🏥 ALERT: Container '%s' is unhealthy",
-				container.Name)
+			logger.Warn("Container is unhealthy")
 		}
 
 		if container.OOMKilled {
-			a.logger.Printf("💥 ALERT: Container '%s' was OOM killed",
-				container.Name)
+			logger.Warn("Container was OOM killed")
 		}
 
 		// Resource alerts (only for running containers)
 		if container.State == "running" {
 			if container.CPUPercent > cpuThreshold {
-				a.logger.Printf("⚠️  ALERT: Container '%s' CPU (%.2f%%) exceeds threshold (%.2f%%)",
-					container.Name, container.CPUPercent, cpuThreshold)
+				logger.Warn("Container CPU exceeds threshold", "cpu_percent", container.CPUPercent, "threshold", cpuThreshold)
 			}
 
 			if container.MemoryPercent > memThreshold {
-				a.logger.Printf("⚠️  ALERT: Container '%s' memory (%.2f%%) exceeds threshold (%.2f%%)",
-					container.Name, container.MemoryPercent, memThreshold)
+				logger.Warn("Container memory exceeds threshold", "memory_percent", container.MemoryPercent, "threshold", memThreshold)
 			}
 		}
 
 		// Restart count alert
 		if container.RestartCount > restartThreshold {
-			a.logger.Printf("🔄 ALERT: Container '%s' restart count (%d) exceeds threshold (%d)",
-				container.Name, container.RestartCount, restartThreshold)
+			logger.Warn("Container restart count exceeds threshold", "restart_count", container.RestartCount, "threshold", restartThreshold)
 		}
 	}
 }
 
+// logMetrics logs a summary of each collection at debug level, with the full
+// snapshot as JSON
 func (a *Agent) logMetrics(m *metrics.SystemMetrics) {
-	a.logger.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	a.logger.Printf("📊 Metrics collected at %s", m.Timestamp.Format(time.RFC3339))
-	a.logger.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-
-	// System Info
-	a.logger.Printf("🖥️  System: %s (%s %s)", m.SystemInfo.Hostname, m.SystemInfo.OS, m.SystemInfo.Platform)
-	a.logger.Printf("   Uptime: %s", formatDuration(time.Duration(m.SystemInfo.Uptime)*time.Second))
-
-	// CPU
-	a.logger.Printf("💻 CPU Usage: %.2f%%", m.CPU.UsagePercent)
-	a.logger.Printf("   Load Avg: %.2f (1m) | %.2f (5m) | %.2f (15m)",
-		m.CPU.LoadAvg1, m.CPU.LoadAvg5, m.CPU.LoadAvg15)
-
-	// Memory
-	a.logger.Printf("🧠 Memory: %.2f%% used (%s / %s)",
-		m.Memory.UsedPercent,
-		formatBytes(m.Memory.Used),
-		formatBytes(m.Memory.Total))
-	if m.Memory.SwapTotal > 0 {
-		a.logger.Printf("   Swap: %.2f%% used (%s / %s)",
-			m.Memory.SwapPercent,
-			formatBytes(m.Memory.SwapUsed),
-			formatBytes(m.Memory.SwapTotal))
-	}
-
-	// Disk
-	a.logger.Println("💾 Disk Usage:")
-	for _, disk := range m.Disk {
-		a.logger.Printf("   %s: %.2f%% used (%s / %s)",
-			disk.MountPoint,
-			disk.UsedPercent,
-			formatBytes(disk.Used),
-			formatBytes(disk.Total))
-	}
-
-	// Network
-	a.logger.Printf("🌐 Network: ↑ %s sent | ↓ %s received",
-		formatBytes(m.Network.BytesSent),
-		formatBytes(m.Network.BytesRecv))
-
-	// Docker containers
-	if len(m.Containers) > 0 {
-		a.logger.Printf("🐳 Containers: %d monitored", len(m.Containers))
-		for _, container := range m.Containers {
-			statusIcon := getContainerStatusIcon(container.State, container.Health)
-			if container.State == "running" {
-				a.logger.Printf("   %s %s: CPU %.1f%% | Mem %s (%.1f%%) | Restarts: %d",
-					statusIcon,
-					container.Name,
-					container.CPUPercent,
-					formatBytes(container.MemoryUsage),
-					container.MemoryPercent,
-					container.RestartCount)
-			} else {
-				a.logger.Printf("   %s %s: %s (exit code: %d)",
-					statusIcon,
-					container.Name,
-					container.State,
-					container.ExitCode)
-			}
-		}
+	if !a.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
 	}
 
-	// Output JSON for debugging
-	if a.config.Agent.Name != "" {
-		jsonData, _ := json.MarshalIndent(m, "", "  ")
-		a.logger.Printf("\n📄 JSON Output:\n%s\n", string(jsonData))
+	a.logger.Debug("Metrics collected",
+		"hostname", m.SystemInfo.Hostname,
+		"uptime", time.Duration(m.SystemInfo.Uptime)*time.Second,
+		"cpu_percent", m.CPU.UsagePercent,
+		"load_avg_1", m.CPU.LoadAvg1,
+		"memory_percent", m.Memory.UsedPercent,
+		"swap_percent", m.Memory.SwapPercent,
+		"network_bytes_sent", m.Network.BytesSent,
+		"network_bytes_recv", m.Network.BytesRecv,
+		"network_sent_bytes_per_sec", m.Network.BytesSentPerSec,
+		"network_recv_bytes_per_sec", m.Network.BytesRecvPerSec,
+		"containers", len(m.Containers))
+	for _, disk := range m.Disk {
+		a.logger.Debug("Disk usage", "mount_point", disk.MountPoint, "disk_percent", disk.UsedPercent, "used_bytes", disk.Used, "total_bytes", disk.Total)
 	}
-}
-
-func getContainerStatusIcon(state, health string) string {
-	if state == "running" {
-		if health == "healthy" {
-			return "🟢"
-		} else if health == "unhealthy" {
-			return "🔴"
-		} else if health == "starting" {
-			return "🟡"
+	if m.Docker != nil {
+		if m.Docker.Error != "" {
+			a.logger.Debug("Docker daemon unreachable", "error", m.Docker.Error)
+		} else {
+			a.logger.Debug("Docker daemon",
+				"version", m.Docker.Version,
+				"storage_driver", m.Docker.StorageDriver,
+				"containers", m.Docker.Containers,
+				"containers_running", m.Docker.ContainersRunning,
+				"images", m.Docker.Images,
+				"goroutines", m.Docker.Goroutines)
 		}
-		return "🟢" // No health check defined
-	} else if state == "exited" {
-		return "⚫"
-	} else if state == "restarting" {
-		return "🔄"
-	} else if state == "paused" {
-		return "⏸️"
-	}
-	return "⚪"
-}
-
-// Helper functions
-
-func formatBytes(bytes uint64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
 	}
-	div, exp := uint64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+	for _, container := range m.Containers {
+		a.logger.Debug("Container",
+			"container", container.Name,
+			"state", container.State,
+			"health", container.Health,
+			"cpu_percent", container.CPUPercent,
+			"memory_percent", container.MemoryPercent,
+			"restart_count", container.RestartCount,
+			"exit_code", container.ExitCode)
 	}
-	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
 
-func formatDuration(d time.Duration) string {
-	days := d / (24 * time.Hour)
-	d -= days * 24 * time.Hour
-	hours := d / time.Hour
-	d -= hours * time.Hour
-	minutes := d / time.Minute
-
-	if days > 0 {
-		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
-	}
-	if hours > 0 {
-		return fmt.Sprintf("%dh %dm", hours, minutes)
-	}
-	return fmt.Sprintf("%dm", minutes)
+	jsonData, _ := json.Marshal(m)
+	a.logger.Debug("Metrics snapshot", "metrics", json.RawMessage(jsonData))
 }
@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+)
+
+const (
+	// Azure Instance Metadata Service (IMDS) endpoint
+	azureIMDSURL        = "http://169.254.169.254/metadata/instance"
+	azureIMDSAPIVersion = "2021-02-01"
+
+	// Timeout for Azure IMDS requests
+	azureIMDSTimeout = 2 * time.Second
+)
+
+// azureInstance is the subset of the IMDS instance document we use
+type azureInstance struct {
+	Compute struct {
+		VMID     string `json:"vmId"`
+		VMSize   string `json:"vmSize"`
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+		TagsList []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"tagsList"`
+	} `json:"compute"`
+}
+
+// AzureMetadataClient fetches Azure VM metadata
+type AzureMetadataClient struct {
+	client  *http.Client
+	baseURL string
+}
+
+// NewAzureMetadataClient creates a new Azure metadata client
+func NewAzureMetadataClient() *AzureMetadataClient {
+	return &AzureMetadataClient{
+		client: &http.Client{
+			Timeout: azureIMDSTimeout,
+		},
+		baseURL: azureIMDSURL,
+	}
+}
+
+// GetAzureMetadata fetches Azure VM metadata from IMDS
+func (c *AzureMetadataClient) GetAzureMetadata(ctx context.Context) (*server.CloudMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"?api-version="+azureIMDSAPIVersion, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch instance metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata request failed with status: %d", resp.StatusCode)
+	}
+
+	var instance azureInstance
+	if err := json.NewDecoder(resp.Body).Decode(&instance); err != nil {
+		return nil, fmt.Errorf("failed to parse instance metadata: %w", err)
+	}
+
+	if instance.Compute.VMID == "" {
+		return nil, fmt.Errorf("instance metadata has no VM ID")
+	}
+
+	metadata := &server.CloudMetadata{
+		Provider:     "azure",
+		InstanceID:   instance.Compute.VMID,
+		InstanceType: instance.Compute.VMSize,
+		Region:       instance.Compute.Location,
+		Zone:         instance.Compute.Zone,
+	}
+
+	if len(instance.Compute.TagsList) > 0 {
+		metadata.Tags = make(map[string]string, len(instance.Compute.TagsList))
+		for _, tag := range instance.Compute.TagsList {
+			metadata.Tags[tag.Name] = tag.Value
+		}
+	}
+
+	return metadata, nil
+}
+
+// IsRunningOnAzure checks if the agent is running on an Azure VM
+func IsRunningOnAzure(ctx context.Context) bool {
+	client := &http.Client{
+		Timeout: 1 * time.Second,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", azureIMDSURL+"?api-version="+azureIMDSAPIVersion, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
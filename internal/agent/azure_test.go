@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetAzureMetadata_Success(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" {
+			t.Errorf("Expected Metadata header, got '%s'", r.Header.Get("Metadata"))
+		}
+		if r.URL.Query().Get("api-version") != azureIMDSAPIVersion {
+			t.Errorf("Expected api-version %s, got '%s'", azureIMDSAPIVersion, r.URL.Query().Get("api-version"))
+		}
+
+		_, _ = w.Write([]byte(`{
+			"compute": {
+				"vmId": "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+				"vmSize": "Standard_D2s_v3",
+				"location": "westeurope",
+				"zone": "1",
+				"tagsList": [
+					{"name": "env", "value": "production"},
+					{"name": "team", "value": "platform"}
+				]
+			}
+		}`))
+	}))
+	defer testServer.Close()
+
+	client := NewAzureMetadataClient()
+	client.baseURL = testServer.URL
+
+	metadata, err := client.GetAzureMetadata(context.Background())
+	if err != nil {
+		t.Fatalf("GetAzureMetadata failed: %v", err)
+	}
+
+	if metadata.Provider != "azure" {
+		t.Errorf("Expected provider 'azure', got '%s'", metadata.Provider)
+	}
+	if metadata.InstanceID != "02aab8a4-74ef-476e-8182-f6d2ba4166a6" {
+		t.Errorf("Expected VM ID, got '%s'", metadata.InstanceID)
+	}
+	if metadata.InstanceType != "Standard_D2s_v3" {
+		t.Errorf("Expected size 'Standard_D2s_v3', got '%s'", metadata.InstanceType)
+	}
+	if metadata.Region != "westeurope" || metadata.Zone != "1" {
+		t.Errorf("Expected westeurope zone 1, got %s zone %s", metadata.Region, metadata.Zone)
+	}
+	if len(metadata.Tags) != 2 || metadata.Tags["env"] != "production" {
+		t.Errorf("Expected 2 tags including env=production, got %v", metadata.Tags)
+	}
+}
+
+func TestGetAzureMetadata_Failure(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer testServer.Close()
+
+	client := NewAzureMetadataClient()
+	client.baseURL = testServer.URL
+
+	if _, err := client.GetAzureMetadata(context.Background()); err == nil {
+		t.Error("Expected error for failed metadata request")
+	}
+}
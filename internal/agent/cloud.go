@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/anurag/saviour/internal/docker"
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// CloudMetadataProvider fetches instance metadata from one cloud's metadata
+// service and maps it to the provider-agnostic form stored by the server
+type CloudMetadataProvider interface {
+	// Name is the provider identifier used in config and payloads
+	Name() string
+	// Detect reports whether the agent runs on this provider
+	Detect(ctx context.Context) bool
+	// Metadata fetches the current instance metadata
+	Metadata(ctx context.Context) (*server.CloudMetadata, error)
+}
+
+// awsProvider reads EC2 instance metadata via IMDSv2
+type awsProvider struct {
+	client *EC2MetadataClient
+}
+
+func (p *awsProvider) Name() string                    { return "aws" }
+func (p *awsProvider) Detect(ctx context.Context) bool { return IsRunningOnEC2(ctx, p.client.endpoint) }
+
+func (p *awsProvider) Metadata(ctx context.Context) (*server.CloudMetadata, error) {
+	metadata, err := p.client.GetEC2Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.CloudMetadata(), nil
+}
+
+// gcpProvider reads GCE instance metadata
+type gcpProvider struct {
+	client *GCPMetadataClient
+}
+
+func (p *gcpProvider) Name() string                    { return "gcp" }
+func (p *gcpProvider) Detect(ctx context.Context) bool { return IsRunningOnGCP(ctx) }
+
+func (p *gcpProvider) Metadata(ctx context.Context) (*server.CloudMetadata, error) {
+	return p.client.GetGCPMetadata(ctx)
+}
+
+// azureProvider reads Azure VM metadata from IMDS
+type azureProvider struct {
+	client *AzureMetadataClient
+}
+
+func (p *azureProvider) Name() string                    { return "azure" }
+func (p *azureProvider) Detect(ctx context.Context) bool { return IsRunningOnAzure(ctx) }
+
+func (p *azureProvider) Metadata(ctx context.Context) (*server.CloudMetadata, error) {
+	return p.client.GetAzureMetadata(ctx)
+}
+
+// ecsProvider reads ECS task metadata. Fargate tasks have no IMDS access,
+// so the task stands in for the instance.
+type ecsProvider struct {
+	metadataURI string
+}
+
+func (p *ecsProvider) Name() string                { return "ecs" }
+func (p *ecsProvider) Detect(context.Context) bool { return p.metadataURI != "" }
+
+func (p *ecsProvider) Metadata(ctx context.Context) (*server.CloudMetadata, error) {
+	if p.metadataURI == "" {
+		return nil, fmt.Errorf("%s is not set", docker.ECSMetadataEnv)
+	}
+
+	task, err := docker.NewECSClient(p.metadataURI).GetTask(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &server.CloudMetadata{
+		Provider:     "ecs",
+		InstanceID:   task.TaskARN,
+		InstanceType: task.LaunchType,
+		Zone:         task.AvailabilityZone,
+		Tags: map[string]string{
+			"cluster":  task.Cluster,
+			"family":   task.Family,
+			"revision": task.Revision,
+		},
+	}
+
+	// Task ARNs look like arn:aws:ecs:<region>:<account>:task/<cluster>/<id>
+	if parts := strings.Split(task.TaskARN, ":"); len(parts) > 3 {
+		metadata.Region = parts[3]
+	}
+
+	return metadata, nil
+}
+
+// noneProvider is used when cloud metadata is disabled or nothing was detected
+type noneProvider struct{}
+
+func (noneProvider) Name() string                                            { return "none" }
+func (noneProvider) Detect(context.Context) bool                             { return true }
+func (noneProvider) Metadata(context.Context) (*server.CloudMetadata, error) { return nil, nil }
+
+// cloudProviders returns the known providers in auto-detection order
+func (s *Sender) cloudProviders() []CloudMetadataProvider {
+	return []CloudMetadataProvider{
+		&ecsProvider{metadataURI: docker.ECSMetadataURI()},
+		&awsProvider{client: s.ec2Client},
+		&gcpProvider{client: NewGCPMetadataClient()},
+		&azureProvider{client: NewAzureMetadataClient()},
+	}
+}
+
+// selectCloudProvider returns the provider named in config, or for "auto"
+// the first one whose metadata service answers
+func (s *Sender) selectCloudProvider(ctx context.Context, name string) CloudMetadataProvider {
+	if name == "none" {
+		return noneProvider{}
+	}
+
+	for _, provider := range s.cloudProviders() {
+		if name == provider.Name() || (name == "auto" && provider.Detect(ctx)) {
+			return provider
+		}
+	}
+	return noneProvider{}
+}
+
+// DetectCloudMetadata selects the cloud provider ("auto", "ecs", "aws",
+// "gcp", "azure" or "none") and records its metadata, so it is included in later
+// pushes. This is best effort and may take a few seconds off-cloud, so
+// callers run it in the background.
+func (s *Sender) DetectCloudMetadata(ctx context.Context, providerName string) {
+	provider := s.selectCloudProvider(ctx, providerName)
+
+	s.metadataMu.Lock()
+	s.provider = provider
+	s.metadataMu.Unlock()
+
+	if _, none := provider.(noneProvider); none {
+		return
+	}
+
+	metadata, err := provider.Metadata(ctx)
+	if err != nil {
+		logging.Component("cloud").Warn("Failed to fetch cloud metadata", "provider", provider.Name(), logging.Err(err))
+		return
+	}
+	s.setCloudMetadata(metadata)
+	logging.Component("cloud").Info("Running on cloud instance", "provider", provider.Name(), "instance_id", metadata.InstanceID, "instance_type", metadata.InstanceType)
+}
+
+// RefreshCloudMetadata re-fetches metadata from the selected provider so tag
+// changes and instance resizes reach the server on the next push. Does
+// nothing when no provider was detected.
+func (s *Sender) RefreshCloudMetadata(ctx context.Context) error {
+	s.metadataMu.RLock()
+	provider := s.provider
+	current := s.cloudMetadata
+	s.metadataMu.RUnlock()
+
+	if provider == nil || current == nil {
+		return nil
+	}
+
+	metadata, err := provider.Metadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to refresh %s metadata: %w", provider.Name(), err)
+	}
+
+	if !reflect.DeepEqual(current, metadata) {
+		logging.Component("cloud").Info("Cloud metadata changed", "instance_id", metadata.InstanceID, "instance_type", metadata.InstanceType)
+	}
+	s.setCloudMetadata(metadata)
+
+	return nil
+}
+
+// setCloudMetadata stores metadata for pushes. EC2 metadata is also kept in
+// its legacy form for servers that predate cloud_metadata.
+func (s *Sender) setCloudMetadata(metadata *server.CloudMetadata) {
+	s.metadataMu.Lock()
+	s.cloudMetadata = metadata
+	s.ec2Metadata = metadata.EC2Metadata()
+	s.metadataMu.Unlock()
+}
@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anurag/saviour/internal/server"
+)
+
+// mockCloudProvider is a CloudMetadataProvider returning canned metadata
+type mockCloudProvider struct {
+	name     string
+	metadata *server.CloudMetadata
+	err      error
+}
+
+func (m *mockCloudProvider) Name() string                { return m.name }
+func (m *mockCloudProvider) Detect(context.Context) bool { return true }
+func (m *mockCloudProvider) Metadata(context.Context) (*server.CloudMetadata, error) {
+	return m.metadata, m.err
+}
+
+func TestSelectCloudProvider_Explicit(t *testing.T) {
+	sender := NewSender("", "")
+	ctx := context.Background()
+
+	// Explicit providers are used without probing the metadata services
+	for _, name := range []string{"ecs", "aws", "gcp", "azure", "none"} {
+		provider := sender.selectCloudProvider(ctx, name)
+		if provider.Name() != name {
+			t.Errorf("Expected provider '%s', got '%s'", name, provider.Name())
+		}
+	}
+}
+
+func TestRefreshCloudMetadata(t *testing.T) {
+	sender := NewSender("", "")
+	provider := &mockCloudProvider{
+		name: "aws",
+		metadata: &server.CloudMetadata{
+			Provider:     "aws",
+			InstanceID:   "i-1234567890abcdef0",
+			InstanceType: "t3.large",
+			Lifecycle:    "spot",
+		},
+	}
+	sender.provider = provider
+	sender.setCloudMetadata(&server.CloudMetadata{Provider: "aws", InstanceID: "i-1234567890abcdef0", InstanceType: "t3.medium"})
+
+	if err := sender.RefreshCloudMetadata(context.Background()); err != nil {
+		t.Fatalf("RefreshCloudMetadata failed: %v", err)
+	}
+
+	if sender.cloudMetadata.InstanceType != "t3.large" {
+		t.Errorf("Expected refreshed instance type 't3.large', got '%s'", sender.cloudMetadata.InstanceType)
+	}
+	if sender.ec2Metadata == nil || sender.ec2Metadata.InstanceType != "t3.large" {
+		t.Error("Expected legacy EC2 metadata to be refreshed too")
+	}
+	if !sender.OnEC2() || !sender.IsSpotInstance() {
+		t.Error("Expected refreshed metadata to report a spot EC2 instance")
+	}
+
+	// A failed refresh keeps the last known metadata
+	provider.err = errors.New("imds unavailable")
+	if err := sender.RefreshCloudMetadata(context.Background()); err == nil {
+		t.Error("Expected error from failed refresh")
+	}
+	if sender.cloudMetadata.InstanceType != "t3.large" {
+		t.Errorf("Expected previous metadata to be kept, got '%s'", sender.cloudMetadata.InstanceType)
+	}
+}
+
+func TestRefreshCloudMetadata_NoProvider(t *testing.T) {
+	sender := NewSender("", "")
+
+	if err := sender.RefreshCloudMetadata(context.Background()); err != nil {
+		t.Errorf("Expected no error without a provider, got %v", err)
+	}
+	if sender.cloudMetadata != nil {
+		t.Error("Expected cloud metadata to remain nil")
+	}
+}
+
+func TestCloudMetadata_EC2RoundTrip(t *testing.T) {
+	gcp := &server.CloudMetadata{Provider: "gcp", InstanceID: "123"}
+	if gcp.EC2Metadata() != nil {
+		t.Error("Expected no EC2 metadata for a GCP instance")
+	}
+
+	ec2 := &server.EC2Metadata{
+		InstanceID:        "i-1234567890abcdef0",
+		AvailabilityZone:  "us-west-2a",
+		InstanceLifecycle: "spot",
+	}
+	roundTrip := ec2.CloudMetadata().EC2Metadata()
+	if roundTrip.AvailabilityZone != "us-west-2a" || roundTrip.InstanceLifecycle != "spot" {
+		t.Errorf("Expected EC2 metadata to survive a round trip, got %+v", roundTrip)
+	}
+}
+
+func TestECSProvider_Metadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/task" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{
+			"Cluster": "arn:aws:ecs:eu-west-1:111122223333:cluster/prod",
+			"TaskARN": "arn:aws:ecs:eu-west-1:111122223333:task/prod/0123456789abcdef",
+			"Family": "api",
+			"Revision": "7",
+			"LaunchType": "FARGATE",
+			"AvailabilityZone": "eu-west-1b"
+		}`))
+	}))
+	defer server.Close()
+
+	provider := &ecsProvider{metadataURI: server.URL}
+	if !provider.Detect(context.Background()) {
+		t.Error("Expected ECS to be detected when the metadata URI is set")
+	}
+
+	metadata, err := provider.Metadata(context.Background())
+	if err != nil {
+		t.Fatalf("Metadata failed: %v", err)
+	}
+
+	if metadata.Provider != "ecs" {
+		t.Errorf("Expected provider 'ecs', got '%s'", metadata.Provider)
+	}
+	if metadata.InstanceID != "arn:aws:ecs:eu-west-1:111122223333:task/prod/0123456789abcdef" {
+		t.Errorf("Expected task ARN as instance ID, got '%s'", metadata.InstanceID)
+	}
+	if metadata.InstanceType != "FARGATE" {
+		t.Errorf("Expected instance type 'FARGATE', got '%s'", metadata.InstanceType)
+	}
+	if metadata.Region != "eu-west-1" {
+		t.Errorf("Expected region 'eu-west-1', got '%s'", metadata.Region)
+	}
+	if metadata.Zone != "eu-west-1b" {
+		t.Errorf("Expected zone 'eu-west-1b', got '%s'", metadata.Zone)
+	}
+	if metadata.Tags["family"] != "api" || metadata.Tags["revision"] != "7" {
+		t.Errorf("Expected family and revision tags, got %v", metadata.Tags)
+	}
+	if metadata.EC2Metadata() != nil {
+		t.Error("Expected no legacy EC2 metadata for an ECS task")
+	}
+
+	if (&ecsProvider{}).Detect(context.Background()) {
+		t.Error("Expected ECS not to be detected without a metadata URI")
+	}
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+// Resource usage is compared in buckets of this many percent, so idle
+// containers jittering by a fraction of a percent don't count as changed
+const deltaBucketPercent = 5
+
+// containerFingerprint is what has to differ for a container to be resent
+// in a delta push. Counters such as network bytes are left out; they catch
+// up at the next full push.
+type containerFingerprint struct {
+	Image               string
+	State               string
+	Health              string
+	ExitCode            int
+	OOMKilled           bool
+	RestartCount        int
+	StateError          string
+	OOMKillsLastHour    int
+	HealthCheckExitCode int
+	UpdateAvailable     bool
+	CPUBucket           int
+	MemoryBucket        int
+	Remediation         time.Time
+	Thresholds          metrics.ContainerThresholds
+}
+
+func fingerprint(c metrics.ContainerMetrics) containerFingerprint {
+	f := containerFingerprint{
+		Image:               c.Image,
+		State:               c.State,
+		Health:              c.Health,
+		ExitCode:            c.ExitCode,
+		OOMKilled:           c.OOMKilled,
+		RestartCount:        c.RestartCount,
+		StateError:          c.StateError,
+		OOMKillsLastHour:    c.OOMKillsLastHour,
+		HealthCheckExitCode: c.HealthCheckExitCode,
+		UpdateAvailable:     c.UpdateAvailable,
+		CPUBucket:           int(c.CPUPercent / deltaBucketPercent),
+		MemoryBucket:        int(c.MemoryPercent / deltaBucketPercent),
+	}
+	if c.Remediation != nil {
+		f.Remediation = c.Remediation.Timestamp
+	}
+	if c.Thresholds != nil {
+		f.Thresholds = *c.Thresholds
+	}
+	return f
+}
+
+// deltaTracker remembers what the server was last sent, so pushes between
+// periodic full ones only carry containers that changed
+type deltaTracker struct {
+	fullInterval time.Duration
+
+	sequence uint64 // Of the last push the server accepted, 0 before the first
+	lastFull time.Time
+	sent     map[string]containerFingerprint // Containers as of that push
+}
+
+func newDeltaTracker(fullInterval time.Duration) *deltaTracker {
+	return &deltaTracker{fullInterval: fullInterval}
+}
+
+// reset forces the next push to be a full one
+func (d *deltaTracker) reset() {
+	d.sent = nil
+}
+
+// prepare numbers the payload and, unless a full push is due, cuts its
+// containers down to those that changed. Call the returned function once
+// the server accepts the push.
+func (d *deltaTracker) prepare(payload *MetricsPayload, now time.Time) func() {
+	containers := payload.SystemMetrics.Containers
+	current := make(map[string]containerFingerprint, len(containers))
+	for _, c := range containers {
+		current[c.ID] = fingerprint(c)
+	}
+
+	sequence := d.sequence + 1
+	payload.Sequence = sequence
+
+	full := d.sent == nil || now.Sub(d.lastFull) >= d.fullInterval
+	if !full {
+		changed := make([]metrics.ContainerMetrics, 0)
+		for _, c := range containers {
+			if previous, ok := d.sent[c.ID]; !ok || previous != current[c.ID] {
+				changed = append(changed, c)
+			}
+		}
+		var removed []string
+		for id := range d.sent {
+			if _, ok := current[id]; !ok {
+				removed = append(removed, id)
+			}
+		}
+
+		// Don't touch the caller's metrics, they are pushed again on failure
+		m := *payload.SystemMetrics
+		m.Containers = changed
+		payload.SystemMetrics = &m
+		payload.Delta = true
+		payload.BaseSequence = d.sequence
+		payload.RemovedContainers = removed
+	}
+
+	return func() {
+		d.sequence = sequence
+		d.sent = current
+		if full {
+			d.lastFull = now
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func deltaPayload(containers ...metrics.ContainerMetrics) *MetricsPayload {
+	return &MetricsPayload{
+		AgentName:     "test-agent",
+		SystemMetrics: &metrics.SystemMetrics{AgentName: "test-agent", Containers: containers},
+	}
+}
+
+func containerIDs(containers []metrics.ContainerMetrics) []string {
+	ids := make([]string, len(containers))
+	for i, c := range containers {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func TestDeltaTracker(t *testing.T) {
+	d := newDeltaTracker(10 * time.Minute)
+	now := time.Now()
+
+	web := metrics.ContainerMetrics{ID: "web", State: "running", CPUPercent: 10.2}
+	db := metrics.ContainerMetrics{ID: "db", State: "running", MemoryPercent: 40}
+	cache := metrics.ContainerMetrics{ID: "cache", State: "running"}
+
+	// First push is always full
+	payload := deltaPayload(web, db, cache)
+	d.prepare(payload, now)()
+	if payload.Delta || payload.Sequence != 1 || len(payload.SystemMetrics.Containers) != 3 {
+		t.Fatalf("Expected full push 1 with 3 containers, got delta=%v seq=%d containers=%d",
+			payload.Delta, payload.Sequence, len(payload.SystemMetrics.Containers))
+	}
+
+	// Jitter within a bucket is not a change, a state change is
+	web.CPUPercent = 11.9
+	db.State = "exited"
+	source := deltaPayload(web, db)
+	payload = deltaPayload(web, db)
+	d.prepare(payload, now.Add(time.Minute))()
+
+	if !payload.Delta || payload.BaseSequence != 1 || payload.Sequence != 2 {
+		t.Fatalf("Expected delta 2 based on 1, got delta=%v base=%d seq=%d", payload.Delta, payload.BaseSequence, payload.Sequence)
+	}
+	if ids := containerIDs(payload.SystemMetrics.Containers); len(ids) != 1 || ids[0] != "db" {
+		t.Errorf("Expected only db to be sent, got %v", ids)
+	}
+	if len(payload.RemovedContainers) != 1 || payload.RemovedContainers[0] != "cache" {
+		t.Errorf("Expected cache reported removed, got %v", payload.RemovedContainers)
+	}
+	if len(source.SystemMetrics.Containers) != 2 {
+		t.Error("Expected the caller's metrics to be left intact")
+	}
+
+	// A push the server never accepted doesn't move the base
+	web.CPUPercent = 50
+	payload = deltaPayload(web, db)
+	d.prepare(payload, now.Add(2*time.Minute))
+	payload = deltaPayload(web, db)
+	d.prepare(payload, now.Add(3*time.Minute))
+	if payload.BaseSequence != 2 || payload.Sequence != 3 {
+		t.Errorf("Expected delta 3 based on 2 after an unaccepted push, got base=%d seq=%d", payload.BaseSequence, payload.Sequence)
+	}
+
+	// Full push once the interval has passed
+	payload = deltaPayload(web, db)
+	d.prepare(payload, now.Add(11*time.Minute))
+	if payload.Delta || len(payload.SystemMetrics.Containers) != 2 {
+		t.Errorf("Expected a full push after full_interval, got delta=%v", payload.Delta)
+	}
+
+	// And after a reset
+	d.reset()
+	payload = deltaPayload(web, db)
+	d.prepare(payload, now.Add(4*time.Minute))
+	if payload.Delta {
+		t.Error("Expected a full push after reset")
+	}
+}
@@ -0,0 +1,272 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/internal/docker"
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// Check outcomes reported by Diagnose
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+	CheckSkip = "skip"
+)
+
+// Clock skew against the server beyond which timestamps become misleading
+const maxClockSkew = 30 * time.Second
+
+// CheckResult is the outcome of one doctor check
+type CheckResult struct {
+	Name   string
+	Status string // CheckOK, CheckWarn, CheckFail or CheckSkip
+	Detail string
+	Fix    string // What to do about a warning or failure
+}
+
+// Diagnose checks everything the agent depends on at runtime: Docker
+// socket access, instance metadata, server connectivity and auth, disk
+// mounts and the clock. Checks never change server state.
+func Diagnose(ctx context.Context, cfg *config.Config) []CheckResult {
+	var results []CheckResult
+	results = append(results, checkDocker(ctx, cfg))
+	results = append(results, checkIMDS(ctx, cfg))
+	results = append(results, checkServer(ctx, cfg)...)
+	results = append(results, checkDiskMounts(cfg)...)
+	return results
+}
+
+// checkDocker verifies the Docker socket exists, is accessible and answers
+func checkDocker(ctx context.Context, cfg *config.Config) CheckResult {
+	result := CheckResult{Name: "Docker socket"}
+
+	if !cfg.Metrics.Docker.Enabled {
+		result.Status, result.Detail = CheckSkip, "docker monitoring disabled"
+		return result
+	}
+
+	socket := cfg.Metrics.Docker.Socket
+	if _, err := os.Stat(socket); err != nil {
+		result.Status = CheckFail
+		if errors.Is(err, fs.ErrNotExist) {
+			result.Detail = fmt.Sprintf("%s does not exist", socket)
+			result.Fix = "Start Docker, or set metrics.docker.socket to the daemon's socket (in a container, bind-mount it with -v /var/run/docker.sock:/var/run/docker.sock:ro)"
+		} else {
+			result.Detail = err.Error()
+		}
+		return result
+	}
+
+	client, err := docker.NewClient(socket, docker.FilterConfig{})
+	if err != nil {
+		result.Status, result.Detail = CheckFail, err.Error()
+		return result
+	}
+	defer client.Close()
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx); err != nil {
+		result.Status, result.Detail = CheckFail, err.Error()
+		if errors.Is(err, fs.ErrPermission) || strings.Contains(err.Error(), "permission denied") {
+			result.Fix = "Add the agent's user to the docker group (sudo usermod -aG docker <user>) or run the agent as root"
+		} else {
+			result.Fix = "Check that the Docker daemon is running (systemctl status docker)"
+		}
+		return result
+	}
+
+	result.Status, result.Detail = CheckOK, fmt.Sprintf("daemon reachable at %s", socket)
+	return result
+}
+
+// checkIMDS verifies EC2 instance metadata is reachable and issues IMDSv2
+// tokens, when the configured cloud provider may be AWS
+func checkIMDS(ctx context.Context, cfg *config.Config) CheckResult {
+	result := CheckResult{Name: "EC2 instance metadata"}
+
+	provider := cfg.Agent.CloudProvider
+	if provider != "auto" && provider != "aws" {
+		result.Status, result.Detail = CheckSkip, fmt.Sprintf("cloud_provider is %s", provider)
+		return result
+	}
+
+	endpoint := IMDSEndpoint(cfg.Agent.IMDSEndpoint)
+	if !IsRunningOnEC2(ctx, endpoint) {
+		result.Detail = fmt.Sprintf("%s not reachable", endpoint)
+		if provider == "aws" {
+			result.Status = CheckFail
+			result.Fix = "Check that IMDS is enabled on the instance and not blocked by a firewall or proxy"
+		} else {
+			result.Status = CheckWarn
+			result.Fix = "Fine if this host is not on EC2; otherwise check that IMDS is enabled on the instance"
+		}
+		return result
+	}
+
+	// IMDS answers GETs from containers even when the token PUT can't make
+	// the extra network hop, so fetch a token explicitly
+	if _, err := NewEC2MetadataClient(endpoint).getToken(ctx); err != nil {
+		result.Status = CheckFail
+		result.Detail = fmt.Sprintf("reachable but IMDSv2 token request failed: %v", err)
+		result.Fix = "In a container, raise the hop limit: aws ec2 modify-instance-metadata-options --instance-id <id> --http-put-response-hop-limit 2"
+		return result
+	}
+
+	result.Status, result.Detail = CheckOK, fmt.Sprintf("IMDSv2 available at %s", endpoint)
+	return result
+}
+
+// checkServer verifies the server is reachable, accepts the API key and
+// agrees on the time
+func checkServer(ctx context.Context, cfg *config.Config) []CheckResult {
+	connectivity := CheckResult{Name: "Server connectivity"}
+	auth := CheckResult{Name: "API key"}
+	clock := CheckResult{Name: "Clock"}
+
+	if cfg.Agent.ServerURL == "" {
+		connectivity.Status, connectivity.Detail = CheckFail, "agent.server_url is not set"
+		connectivity.Fix = "Set agent.server_url to the Saviour server, e.g. https://saviour.company.com"
+		auth.Status, clock.Status = CheckSkip, CheckSkip
+		return []CheckResult{connectivity, auth, clock}
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	serverURL := strings.TrimSuffix(cfg.Agent.ServerURL, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serverURL+"/api/v1/health", nil)
+	if err != nil {
+		connectivity.Status, connectivity.Detail = CheckFail, err.Error()
+		auth.Status, clock.Status = CheckSkip, CheckSkip
+		return []CheckResult{connectivity, auth, clock}
+	}
+	sent := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		connectivity.Status, connectivity.Detail = CheckFail, err.Error()
+		connectivity.Fix = "Check the server is running and that DNS, firewalls and proxies allow this host to reach it"
+		auth.Status, clock.Status = CheckSkip, CheckSkip
+		return []CheckResult{connectivity, auth, clock}
+	}
+	resp.Body.Close()
+	rtt := time.Since(sent)
+
+	if resp.StatusCode != http.StatusOK {
+		connectivity.Status = CheckFail
+		connectivity.Detail = fmt.Sprintf("health check returned HTTP %d", resp.StatusCode)
+		connectivity.Fix = "Check that server_url points at the Saviour server and not another service or proxy page"
+	} else {
+		connectivity.Status = CheckOK
+		connectivity.Detail = fmt.Sprintf("%s healthy (%v round trip)", serverURL, rtt.Round(time.Millisecond))
+	}
+
+	// The Date header has second precision, so only flag skew well above that
+	clock = checkClock(resp.Header.Get("Date"), sent.Add(rtt/2))
+	auth = checkAPIKey(ctx, client, serverURL, cfg.Agent.APIKey)
+
+	return []CheckResult{connectivity, auth, clock}
+}
+
+// checkAPIKey sends a heartbeat without an agent name: the auth middleware
+// runs first, so 401/403 means a bad key while the handler's 400 means the
+// key was accepted, and nothing is recorded either way
+func checkAPIKey(ctx context.Context, client *http.Client, serverURL, apiKey string) CheckResult {
+	result := CheckResult{Name: "API key"}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, serverURL+"/api/v1/heartbeat", bytes.NewReader([]byte("{}")))
+	if err != nil {
+		result.Status, result.Detail = CheckFail, err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	req.Header.Set("User-Agent", "saviour-agent/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Status, result.Detail = CheckFail, err.Error()
+		return result
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusBadRequest, http.StatusOK:
+		result.Status, result.Detail = CheckOK, "accepted by the server"
+	case http.StatusUnauthorized:
+		result.Status = CheckFail
+		if apiKey == "" {
+			result.Detail = "agent.api_key is not set"
+		} else {
+			result.Detail = "rejected by the server"
+		}
+		result.Fix = "Set agent.api_key to a key listed under auth.api_keys in the server config"
+	case http.StatusForbidden:
+		result.Status, result.Detail = CheckFail, "key lacks the heartbeat:write scope"
+		result.Fix = `Give the key scopes ["metrics:write", "heartbeat:write"] in the server config`
+	default:
+		result.Status, result.Detail = CheckWarn, fmt.Sprintf("unexpected HTTP %d from heartbeat endpoint", resp.StatusCode)
+	}
+	return result
+}
+
+// checkClock compares the server's Date header with the local time
+func checkClock(dateHeader string, local time.Time) CheckResult {
+	result := CheckResult{Name: "Clock"}
+
+	serverTime, err := http.ParseTime(dateHeader)
+	if err != nil {
+		result.Status, result.Detail = CheckSkip, "server sent no Date header"
+		return result
+	}
+
+	skew := local.Sub(serverTime).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxClockSkew {
+		result.Status = CheckWarn
+		result.Detail = fmt.Sprintf("local clock differs from the server by %v", skew)
+		result.Fix = "Enable time synchronisation on this host (timedatectl set-ntp true) or on the server"
+		return result
+	}
+
+	result.Status, result.Detail = CheckOK, fmt.Sprintf("within %v of the server", maxClockSkew)
+	return result
+}
+
+// checkDiskMounts verifies each configured mount can be read. The collector
+// silently skips unreadable mounts, so a typo here means missing data.
+func checkDiskMounts(cfg *config.Config) []CheckResult {
+	if len(cfg.Metrics.DiskMounts) == 0 {
+		return []CheckResult{{Name: "Disk mounts", Status: CheckSkip, Detail: "all mounts monitored"}}
+	}
+
+	var results []CheckResult
+	for _, mount := range cfg.Metrics.DiskMounts {
+		result := CheckResult{Name: "Disk mount " + mount}
+
+		usage, err := disk.Usage(mount)
+		if err != nil {
+			result.Status, result.Detail = CheckFail, err.Error()
+			result.Fix = "Fix the path in metrics.disk_mounts; in a container, bind-mount the host path read-only"
+		} else {
+			result.Status = CheckOK
+			result.Detail = fmt.Sprintf("%s, %.1f%% used", usage.Fstype, usage.UsedPercent)
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,213 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/config"
+)
+
+func doctorConfig(serverURL, apiKey string) *config.Config {
+	cfg := &config.Config{}
+	cfg.Agent.ServerURL = serverURL
+	cfg.Agent.APIKey = apiKey
+	cfg.Agent.CloudProvider = "none"
+	return cfg
+}
+
+// fakeSaviourServer mimics the health endpoint and heartbeat auth
+func fakeSaviourServer(t *testing.T, clockOffset time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(clockOffset).UTC().Format(http.TimeFormat))
+
+		switch r.URL.Path {
+		case "/api/v1/health":
+			w.WriteHeader(http.StatusOK)
+		case "/api/v1/heartbeat":
+			switch r.Header.Get("Authorization") {
+			case "Bearer good-key":
+				http.Error(w, "agent_name is required", http.StatusBadRequest)
+			case "Bearer read-only-key":
+				http.Error(w, "Forbidden: Insufficient permissions", http.StatusForbidden)
+			default:
+				http.Error(w, "Unauthorized: Invalid API key", http.StatusUnauthorized)
+			}
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}))
+}
+
+func findCheck(t *testing.T, results []CheckResult, name string) CheckResult {
+	t.Helper()
+	for _, r := range results {
+		if r.Name == name {
+			return r
+		}
+	}
+	t.Fatalf("No %q check in results", name)
+	return CheckResult{}
+}
+
+func TestCheckServer(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		skew       time.Duration
+		wantAuth   string
+		wantClock  string
+		wantDetail string
+	}{
+		{"valid key", "good-key", 0, CheckOK, CheckOK, "accepted"},
+		{"invalid key", "bad-key", 0, CheckFail, CheckOK, "rejected"},
+		{"missing key", "", 0, CheckFail, CheckOK, "not set"},
+		{"missing scope", "read-only-key", 0, CheckFail, CheckOK, "heartbeat:write"},
+		{"clock skew", "good-key", 5 * time.Minute, CheckOK, CheckWarn, "accepted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := fakeSaviourServer(t, tt.skew)
+			defer srv.Close()
+
+			results := checkServer(context.Background(), doctorConfig(srv.URL+"/", tt.apiKey))
+
+			if got := findCheck(t, results, "Server connectivity"); got.Status != CheckOK {
+				t.Errorf("Expected connectivity ok, got %s: %s", got.Status, got.Detail)
+			}
+			auth := findCheck(t, results, "API key")
+			if auth.Status != tt.wantAuth {
+				t.Errorf("Expected auth %s, got %s: %s", tt.wantAuth, auth.Status, auth.Detail)
+			}
+			if !strings.Contains(auth.Detail, tt.wantDetail) {
+				t.Errorf("Expected auth detail to mention %q, got %q", tt.wantDetail, auth.Detail)
+			}
+			if got := findCheck(t, results, "Clock"); got.Status != tt.wantClock {
+				t.Errorf("Expected clock %s, got %s: %s", tt.wantClock, got.Status, got.Detail)
+			}
+		})
+	}
+}
+
+func TestCheckServer_Unreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	results := checkServer(context.Background(), doctorConfig(url, "good-key"))
+
+	connectivity := findCheck(t, results, "Server connectivity")
+	if connectivity.Status != CheckFail || connectivity.Fix == "" {
+		t.Errorf("Expected failure with a fix, got %+v", connectivity)
+	}
+	if got := findCheck(t, results, "API key"); got.Status != CheckSkip {
+		t.Errorf("Expected auth check skipped, got %s", got.Status)
+	}
+}
+
+func TestCheckServer_NoURL(t *testing.T) {
+	results := checkServer(context.Background(), doctorConfig("", "good-key"))
+
+	if got := findCheck(t, results, "Server connectivity"); got.Status != CheckFail {
+		t.Errorf("Expected failure without server_url, got %s", got.Status)
+	}
+}
+
+func TestCheckIMDS(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    string
+		tokenStatus int
+		want        string
+	}{
+		{"token issued", "aws", http.StatusOK, CheckOK},
+		{"hop limit", "auto", http.StatusForbidden, CheckFail},
+		{"other cloud", "gcp", http.StatusOK, CheckSkip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPut {
+					w.WriteHeader(tt.tokenStatus)
+					w.Write([]byte("token"))
+					return
+				}
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			defer srv.Close()
+
+			cfg := doctorConfig("", "")
+			cfg.Agent.CloudProvider = tt.provider
+			cfg.Agent.IMDSEndpoint = srv.URL
+
+			if got := checkIMDS(context.Background(), cfg); got.Status != tt.want {
+				t.Errorf("Expected %s, got %s: %s", tt.want, got.Status, got.Detail)
+			}
+		})
+	}
+}
+
+func TestCheckIMDS_Unreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	for provider, want := range map[string]string{"auto": CheckWarn, "aws": CheckFail} {
+		cfg := doctorConfig("", "")
+		cfg.Agent.CloudProvider = provider
+		cfg.Agent.IMDSEndpoint = url
+
+		if got := checkIMDS(context.Background(), cfg); got.Status != want {
+			t.Errorf("%s: expected %s, got %s", provider, want, got.Status)
+		}
+	}
+}
+
+func TestCheckDocker(t *testing.T) {
+	cfg := doctorConfig("", "")
+	if got := checkDocker(context.Background(), cfg); got.Status != CheckSkip {
+		t.Errorf("Expected skip when docker disabled, got %s", got.Status)
+	}
+
+	cfg.Metrics.Docker.Enabled = true
+	cfg.Metrics.Docker.Socket = "/nonexistent/docker.sock"
+	got := checkDocker(context.Background(), cfg)
+	if got.Status != CheckFail || !strings.Contains(got.Detail, "does not exist") {
+		t.Errorf("Expected missing socket failure, got %s: %s", got.Status, got.Detail)
+	}
+}
+
+func TestCheckDiskMounts(t *testing.T) {
+	cfg := doctorConfig("", "")
+	cfg.Metrics.DiskMounts = []string{t.TempDir(), "/nonexistent/mount"}
+
+	results := checkDiskMounts(cfg)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].Status != CheckOK {
+		t.Errorf("Expected readable mount ok, got %s: %s", results[0].Status, results[0].Detail)
+	}
+	if results[1].Status != CheckFail {
+		t.Errorf("Expected missing mount to fail, got %s", results[1].Status)
+	}
+}
+
+func TestCheckClock(t *testing.T) {
+	now := time.Now()
+
+	if got := checkClock(now.UTC().Format(http.TimeFormat), now); got.Status != CheckOK {
+		t.Errorf("Expected ok, got %s", got.Status)
+	}
+	if got := checkClock(now.Add(-2*time.Minute).UTC().Format(http.TimeFormat), now); got.Status != CheckWarn {
+		t.Errorf("Expected warning for a fast local clock, got %s", got.Status)
+	}
+	if got := checkClock("", now); got.Status != CheckSkip {
+		t.Errorf("Expected skip without Date header, got %s", got.Status)
+	}
+}
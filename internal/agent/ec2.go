@@ -2,53 +2,95 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/anurag/saviour/internal/server"
 )
 
 const (
-	// EC2 Instance Metadata Service (IMDS) v2 endpoints
-	imdsTokenURL     = "http://169.254.169.254/latest/api/token"
-	imdsBaseURL      = "http://169.254.169.254/latest/meta-data"
-	imdsInstanceID   = imdsBaseURL + "/instance-id"
-	imdsInstanceType = imdsBaseURL + "/instance-type"
-	imdsRegion       = imdsBaseURL + "/placement/region"
-	imdsAZ           = imdsBaseURL + "/placement/availability-zone"
-	imdsTags         = imdsBaseURL + "/tags/instance"
+	// Default EC2 Instance Metadata Service (IMDS) endpoint
+	defaultIMDSEndpoint = "http://169.254.169.254"
+	// IMDSEndpointEnv overrides the IMDS endpoint, as in the AWS SDKs
+	IMDSEndpointEnv = "AWS_EC2_METADATA_SERVICE_ENDPOINT"
+
+	// IMDSv2 paths, relative to the endpoint
+	imdsTokenPath    = "/latest/api/token"
+	imdsMetadataPath = "/latest/meta-data"
+	imdsInstanceID   = imdsMetadataPath + "/instance-id"
+	imdsInstanceType = imdsMetadataPath + "/instance-type"
+	imdsRegion       = imdsMetadataPath + "/placement/region"
+	imdsAZ           = imdsMetadataPath + "/placement/availability-zone"
+	imdsTags         = imdsMetadataPath + "/tags/instance"
+	imdsLifecycle    = imdsMetadataPath + "/instance-life-cycle"
+	imdsSpotAction   = imdsMetadataPath + "/spot/instance-action"
+	imdsRebalance    = imdsMetadataPath + "/events/recommendations/rebalance"
+	imdsASGLifecycle = imdsMetadataPath + "/autoscaling/target-lifecycle-state"
 
 	// Timeout for IMDS requests
 	imdsTimeout = 2 * time.Second
 	// Token TTL (6 hours max)
-	imdsTokenTTL = "21600"
+	imdsTokenTTL      = "21600"
+	imdsTokenLifetime = 6 * time.Hour
+	// Renew the token this long before it expires
+	imdsTokenRenewBefore = 10 * time.Minute
 )
 
+// errMetadataNotFound is returned when an IMDS path does not exist, which for
+// event endpoints means there is no pending event
+var errMetadataNotFound = errors.New("metadata not found")
+
+// SpotInstanceAction is a pending spot interruption
+type SpotInstanceAction struct {
+	Action string    `json:"action"` // terminate, stop, hibernate
+	Time   time.Time `json:"time"`
+}
+
 // EC2MetadataClient fetches EC2 instance metadata
 type EC2MetadataClient struct {
-	client *http.Client
-	token  string
+	client      *http.Client
+	endpoint    string
+	token       string
+	tokenExpiry time.Time
 }
 
-// NewEC2MetadataClient creates a new EC2 metadata client
-func NewEC2MetadataClient() *EC2MetadataClient {
+// NewEC2MetadataClient creates a new EC2 metadata client. An empty endpoint
+// uses $AWS_EC2_METADATA_SERVICE_ENDPOINT or the link-local default.
+func NewEC2MetadataClient(endpoint string) *EC2MetadataClient {
 	return &EC2MetadataClient{
 		client: &http.Client{
 			Timeout: imdsTimeout,
 		},
+		endpoint: IMDSEndpoint(endpoint),
+	}
+}
+
+// IMDSEndpoint resolves the IMDS base URL: the configured value if set,
+// then the environment, then the link-local default
+func IMDSEndpoint(configured string) string {
+	endpoint := configured
+	if endpoint == "" {
+		endpoint = os.Getenv(IMDSEndpointEnv)
 	}
+	if endpoint == "" {
+		endpoint = defaultIMDSEndpoint
+	}
+	return strings.TrimSuffix(endpoint, "/")
 }
 
 // GetEC2Metadata fetches EC2 instance metadata using IMDSv2
 func (c *EC2MetadataClient) GetEC2Metadata(ctx context.Context) (*server.EC2Metadata, error) {
-	// Get IMDSv2 token
-	token, err := c.getToken(ctx)
-	if err != nil {
+	// Get or renew IMDSv2 token
+	if err := c.ensureToken(ctx); err != nil {
 		return nil, fmt.Errorf("failed to get IMDS token: %w", err)
 	}
-	c.token = token
 
 	metadata := &server.EC2Metadata{}
 
@@ -74,6 +116,11 @@ func (c *EC2MetadataClient) GetEC2Metadata(ctx context.Context) (*server.EC2Meta
 		metadata.AvailabilityZone = az
 	}
 
+	// Fetch lifecycle, "spot" or "on-demand" (optional)
+	if lifecycle, err := c.fetchMetadata(ctx, imdsLifecycle); err == nil {
+		metadata.InstanceLifecycle = lifecycle
+	}
+
 	// Fetch tags (optional)
 	if tags, err := c.fetchTags(ctx); err == nil {
 		metadata.Tags = tags
@@ -82,9 +129,30 @@ func (c *EC2MetadataClient) GetEC2Metadata(ctx context.Context) (*server.EC2Meta
 	return metadata, nil
 }
 
+// ensureToken fetches a new IMDSv2 token if there is none or the current
+// one is about to expire
+func (c *EC2MetadataClient) ensureToken(ctx context.Context) error {
+	if c.tokenValid() {
+		return nil
+	}
+
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return err
+	}
+	c.token = token
+	c.tokenExpiry = time.Now().Add(imdsTokenLifetime)
+	return nil
+}
+
+// tokenValid reports whether the current token can still be used
+func (c *EC2MetadataClient) tokenValid() bool {
+	return c.token != "" && time.Until(c.tokenExpiry) > imdsTokenRenewBefore
+}
+
 // getToken fetches an IMDSv2 session token
 func (c *EC2MetadataClient) getToken(ctx context.Context) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, "PUT", imdsTokenURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.endpoint+imdsTokenPath, nil)
 	if err != nil {
 		return "", err
 	}
@@ -108,9 +176,9 @@ func (c *EC2MetadataClient) getToken(ctx context.Context) (string, error) {
 	return string(token), nil
 }
 
-// fetchMetadata fetches a single metadata value
-func (c *EC2MetadataClient) fetchMetadata(ctx context.Context, url string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// fetchMetadata fetches a single metadata value from a path below the endpoint
+func (c *EC2MetadataClient) fetchMetadata(ctx context.Context, path string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.endpoint+path, nil)
 	if err != nil {
 		return "", err
 	}
@@ -122,6 +190,9 @@ func (c *EC2MetadataClient) fetchMetadata(ctx context.Context, url string) (stri
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return "", errMetadataNotFound
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("metadata request failed with status: %d", resp.StatusCode)
 	}
@@ -134,7 +205,59 @@ func (c *EC2MetadataClient) fetchMetadata(ctx context.Context, url string) (stri
 	return string(data), nil
 }
 
-// fetchTags fetches instance tags
+// GetSpotInstanceAction returns the pending spot interruption, or nil if
+// there is none
+func (c *EC2MetadataClient) GetSpotInstanceAction(ctx context.Context) (*SpotInstanceAction, error) {
+	if err := c.ensureToken(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get IMDS token: %w", err)
+	}
+
+	data, err := c.fetchMetadata(ctx, imdsSpotAction)
+	if errors.Is(err, errMetadataNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var action SpotInstanceAction
+	if err := json.Unmarshal([]byte(data), &action); err != nil {
+		return nil, fmt.Errorf("failed to parse spot instance action: %w", err)
+	}
+	return &action, nil
+}
+
+// HasRebalanceRecommendation reports whether EC2 has signalled an elevated
+// risk of spot interruption
+func (c *EC2MetadataClient) HasRebalanceRecommendation(ctx context.Context) (bool, error) {
+	if err := c.ensureToken(ctx); err != nil {
+		return false, fmt.Errorf("failed to get IMDS token: %w", err)
+	}
+
+	_, err := c.fetchMetadata(ctx, imdsRebalance)
+	if errors.Is(err, errMetadataNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetTargetLifecycleState returns the auto scaling lifecycle state the
+// instance is transitioning to ("InService", "Terminated", ...), or an empty
+// string when the instance is not in an auto scaling group
+func (c *EC2MetadataClient) GetTargetLifecycleState(ctx context.Context) (string, error) {
+	if err := c.ensureToken(ctx); err != nil {
+		return "", fmt.Errorf("failed to get IMDS token: %w", err)
+	}
+
+	state, err := c.fetchMetadata(ctx, imdsASGLifecycle)
+	if errors.Is(err, errMetadataNotFound) {
+		return "", nil
+	}
+	return strings.TrimSpace(state), err
+}
+
+// fetchTags fetches instance tags. Tags are only available in IMDS when
+// "Allow tags in instance metadata" is enabled on the instance.
 func (c *EC2MetadataClient) fetchTags(ctx context.Context) (map[string]string, error) {
 	// First, get the list of tag keys
 	tagKeys, err := c.fetchMetadata(ctx, imdsTags)
@@ -146,22 +269,32 @@ func (c *EC2MetadataClient) fetchTags(ctx context.Context) (map[string]string, e
 		return nil, nil
 	}
 
-	// Parse tag keys (newline separated)
+	// Tag keys are newline separated, each value is a separate request
 	tags := make(map[string]string)
-	// In a real implementation, you'd split by newlines and fetch each tag
-	// For simplicity, we'll return an empty map
-	// This would require additional IMDS calls to fetch each tag value
+	for _, key := range strings.Split(tagKeys, "\n") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		value, err := c.fetchMetadata(ctx, imdsTags+"/"+url.PathEscape(key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch tag %q: %w", key, err)
+		}
+		tags[key] = value
+	}
 
 	return tags, nil
 }
 
-// IsRunningOnEC2 checks if the agent is running on an EC2 instance
-func IsRunningOnEC2(ctx context.Context) bool {
+// IsRunningOnEC2 checks if the agent is running on an EC2 instance by
+// probing IMDS at endpoint (resolved as in NewEC2MetadataClient)
+func IsRunningOnEC2(ctx context.Context, endpoint string) bool {
 	client := &http.Client{
 		Timeout: 1 * time.Second,
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", imdsBaseURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", IMDSEndpoint(endpoint)+imdsMetadataPath, nil)
 	if err != nil {
 		return false
 	}
@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+)
+
+const (
+	// GCE metadata server
+	gcpMetadataURL = "http://metadata.google.internal/computeMetadata/v1"
+
+	// Timeout for GCP metadata requests
+	gcpMetadataTimeout = 2 * time.Second
+)
+
+// gcpSkipAttributes are instance attributes that hold scripts or keys
+// rather than descriptive metadata
+var gcpSkipAttributes = map[string]bool{
+	"ssh-keys":              true,
+	"startup-script":        true,
+	"shutdown-script":       true,
+	"user-data":             true,
+	"kube-env":              true,
+	"configure-sh":          true,
+	"google-logging-enable": true,
+}
+
+// GCPMetadataClient fetches GCE instance metadata
+type GCPMetadataClient struct {
+	client  *http.Client
+	baseURL string
+}
+
+// NewGCPMetadataClient creates a new GCP metadata client
+func NewGCPMetadataClient() *GCPMetadataClient {
+	return &GCPMetadataClient{
+		client: &http.Client{
+			Timeout: gcpMetadataTimeout,
+		},
+		baseURL: gcpMetadataURL,
+	}
+}
+
+// GetGCPMetadata fetches GCE instance metadata. The metadata server does
+// not expose instance labels, so custom metadata attributes are reported
+// as tags instead.
+func (c *GCPMetadataClient) GetGCPMetadata(ctx context.Context) (*server.CloudMetadata, error) {
+	metadata := &server.CloudMetadata{Provider: "gcp"}
+
+	// Fetch instance ID
+	instanceID, err := c.fetchMetadata(ctx, "/instance/id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch instance ID: %w", err)
+	}
+	metadata.InstanceID = instanceID
+
+	// Machine type is returned as projects/<num>/machineTypes/<type> (optional)
+	if machineType, err := c.fetchMetadata(ctx, "/instance/machine-type"); err == nil {
+		metadata.InstanceType = lastPathSegment(machineType)
+	}
+
+	// Zone is returned as projects/<num>/zones/<zone> (optional)
+	if zone, err := c.fetchMetadata(ctx, "/instance/zone"); err == nil {
+		metadata.Zone = lastPathSegment(zone)
+		if i := strings.LastIndex(metadata.Zone, "-"); i > 0 {
+			metadata.Region = metadata.Zone[:i]
+		}
+	}
+
+	// Fetch attributes (optional)
+	if attributes, err := c.fetchAttributes(ctx); err == nil {
+		metadata.Tags = attributes
+	}
+
+	return metadata, nil
+}
+
+// fetchMetadata fetches a single metadata value
+func (c *GCPMetadataClient) fetchMetadata(ctx context.Context, path string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata request failed with status: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// fetchAttributes fetches custom instance metadata attributes
+func (c *GCPMetadataClient) fetchAttributes(ctx context.Context) (map[string]string, error) {
+	data, err := c.fetchMetadata(ctx, "/instance/attributes/?recursive=true")
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes map[string]string
+	if err := json.Unmarshal([]byte(data), &attributes); err != nil {
+		return nil, fmt.Errorf("failed to parse attributes: %w", err)
+	}
+
+	for key := range attributes {
+		if gcpSkipAttributes[key] {
+			delete(attributes, key)
+		}
+	}
+
+	if len(attributes) == 0 {
+		return nil, nil
+	}
+	return attributes, nil
+}
+
+// lastPathSegment returns the part of s after the final slash
+func lastPathSegment(s string) string {
+	return s[strings.LastIndex(s, "/")+1:]
+}
+
+// IsRunningOnGCP checks if the agent is running on a GCE instance
+func IsRunningOnGCP(ctx context.Context) bool {
+	client := &http.Client{
+		Timeout: 1 * time.Second,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", gcpMetadataURL+"/", nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.Header.Get("Metadata-Flavor") == "Google"
+}
@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newGCPTestServer(t *testing.T) *httptest.Server {
+	responses := map[string]string{
+		"/instance/id":           "1234567890123456789",
+		"/instance/machine-type": "projects/123456/machineTypes/e2-standard-4",
+		"/instance/zone":         "projects/123456/zones/us-central1-a",
+		"/instance/attributes/":  `{"env":"production","team":"platform","ssh-keys":"user:ssh-rsa AAAA"}`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			t.Errorf("Expected Metadata-Flavor header, got '%s'", r.Header.Get("Metadata-Flavor"))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Metadata-Flavor", "Google")
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func TestGetGCPMetadata_Success(t *testing.T) {
+	testServer := newGCPTestServer(t)
+	defer testServer.Close()
+
+	client := NewGCPMetadataClient()
+	client.baseURL = testServer.URL
+
+	metadata, err := client.GetGCPMetadata(context.Background())
+	if err != nil {
+		t.Fatalf("GetGCPMetadata failed: %v", err)
+	}
+
+	if metadata.Provider != "gcp" {
+		t.Errorf("Expected provider 'gcp', got '%s'", metadata.Provider)
+	}
+	if metadata.InstanceID != "1234567890123456789" {
+		t.Errorf("Expected instance ID '1234567890123456789', got '%s'", metadata.InstanceID)
+	}
+	if metadata.InstanceType != "e2-standard-4" {
+		t.Errorf("Expected machine type 'e2-standard-4', got '%s'", metadata.InstanceType)
+	}
+	if metadata.Zone != "us-central1-a" {
+		t.Errorf("Expected zone 'us-central1-a', got '%s'", metadata.Zone)
+	}
+	if metadata.Region != "us-central1" {
+		t.Errorf("Expected region 'us-central1', got '%s'", metadata.Region)
+	}
+	if metadata.Tags["env"] != "production" || metadata.Tags["team"] != "platform" {
+		t.Errorf("Expected env and team attributes, got %v", metadata.Tags)
+	}
+	if _, exists := metadata.Tags["ssh-keys"]; exists {
+		t.Error("Expected ssh-keys attribute to be skipped")
+	}
+}
+
+func TestGetGCPMetadata_InstanceIDFailure(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer testServer.Close()
+
+	client := NewGCPMetadataClient()
+	client.baseURL = testServer.URL
+
+	if _, err := client.GetGCPMetadata(context.Background()); err == nil {
+		t.Error("Expected error when instance ID is unavailable")
+	}
+}
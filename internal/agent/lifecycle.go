@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+)
+
+// terminationCheckInterval matches AWS's recommendation for polling
+// interruption notices
+const terminationCheckInterval = 5 * time.Second
+
+// checkTermination polls IMDS for spot interruption notices and auto scaling
+// scale-in. When the instance is scheduled for termination, the server is
+// told straight away so it expects the agent to disappear.
+func (a *Agent) checkTermination(ctx context.Context) {
+	if a.terminationReason != "" || !a.sender.OnEC2() {
+		return
+	}
+
+	state, err := a.sender.ec2Client.GetTargetLifecycleState(ctx)
+	if err != nil {
+		a.logger.Warn("Auto scaling lifecycle check failed", logging.Err(err))
+	} else if state == "Terminated" {
+		a.markTerminating(ctx, "auto scaling scale-in")
+		return
+	}
+
+	if a.sender.IsSpotInstance() {
+		a.checkSpotInterruption(ctx)
+	}
+}
+
+// checkSpotInterruption polls IMDS for spot interruption notices
+func (a *Agent) checkSpotInterruption(ctx context.Context) {
+	if !a.rebalanceNotified {
+		if recommended, err := a.sender.ec2Client.HasRebalanceRecommendation(ctx); err == nil && recommended {
+			a.rebalanceNotified = true
+			a.logger.Warn("EC2 rebalance recommendation received, spot interruption risk is elevated")
+		}
+	}
+
+	action, err := a.sender.ec2Client.GetSpotInstanceAction(ctx)
+	if err != nil {
+		a.logger.Warn("Spot interruption check failed", logging.Err(err))
+		return
+	}
+	if action == nil {
+		return
+	}
+
+	a.markTerminating(ctx, fmt.Sprintf("spot instance %s at %s", action.Action, action.Time.UTC().Format(time.RFC3339)))
+}
+
+// markTerminating flags the agent as terminating and tells the server now
+// rather than at the next heartbeat
+func (a *Agent) markTerminating(ctx context.Context, reason string) {
+	a.terminationReason = reason
+	a.logger.Warn("Instance is terminating", "reason", reason)
+
+	if err := a.sendHeartbeat(ctx); err != nil {
+		a.logger.Error("Termination notice failed", logging.Err(err))
+	}
+}
+
+// notifyStopping sends a final heartbeat on a clean shutdown, so the
+// server knows the agent stopped on purpose and holds off the offline
+// alert. An instance already reported as terminating is left that way.
+func (a *Agent) notifyStopping() {
+	if a.terminationReason != "" {
+		return
+	}
+
+	reason := "shutdown"
+	if a.config.Agent.DecommissionOnShutdown {
+		reason = "decommission"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := a.sender.SendStatus(ctx, a.config.Agent.Name, "stopping", reason); err != nil {
+		a.logger.Error("Shutdown notice failed", logging.Err(err))
+		return
+	}
+	a.logger.Info("Told server the agent is stopping", "reason", reason)
+}
+
+// deregister removes the agent from the server on a clean shutdown so it
+// is not reported offline
+func (a *Agent) deregister() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := a.sender.Deregister(ctx, a.config.Agent.Name); err != nil {
+		// Still hold off the offline alert, e.g. when the key lacks admin:write
+		a.logger.Error("Deregistering from server failed", logging.Err(err))
+		a.notifyStopping()
+		return
+	}
+	a.logger.Info("Deregistered from server")
+}
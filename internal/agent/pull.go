@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/internal/logging"
+)
+
+// PullResponse is what the agent answers a scrape of GET /api/v1/metrics
+// with: the payload it would otherwise push, plus what heartbeats carry
+type PullResponse struct {
+	MetricsPayload
+	AgentVersion string `json:"agent_version,omitempty"`
+}
+
+// pullServer serves the agent's latest metrics to a server that scrapes
+// them, see config.PullConfig
+type pullServer struct {
+	logger *slog.Logger
+	token  string
+	latest atomic.Pointer[PullResponse] // Last published snapshot
+	served atomic.Pointer[PullResponse] // Last snapshot a scrape received
+}
+
+// newPullServer creates a pull server accepting scrapes with token
+func newPullServer(token string, logger *slog.Logger) *pullServer {
+	return &pullServer{logger: logger, token: token}
+}
+
+// publish makes a snapshot the one scrapes receive
+func (p *pullServer) publish(resp *PullResponse) {
+	p.latest.Store(resp)
+}
+
+// servedLatest reports whether a scrape has received the latest snapshot,
+// so what it carried has reached the server
+func (p *pullServer) servedLatest() bool {
+	latest := p.latest.Load()
+	return latest != nil && p.served.Load() == latest
+}
+
+// ServeHTTP answers scrapes of GET /api/v1/metrics
+func (p *pullServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/v1/metrics" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(p.token)) != 1 {
+		p.logger.Warn("Rejected scrape", "remote_addr", r.RemoteAddr)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	snapshot := p.latest.Load()
+	if snapshot == nil {
+		http.Error(w, "No metrics collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		p.logger.Error("Error encoding scrape response", logging.Err(err))
+		return
+	}
+	p.served.Store(snapshot)
+	p.logger.Debug("Served scrape", "remote_addr", r.RemoteAddr)
+}
+
+// serve answers scrapes on listener until ctx is done
+func (p *pullServer) serve(ctx context.Context, listener net.Listener, cfg config.PullConfig) {
+	srv := &http.Server{
+		Handler:           p,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	p.logger.Info("Serving metrics for scrapes", "addr", listener.Addr().String(), "tls", cfg.TLSCertFile != "")
+	var err error
+	if cfg.TLSCertFile != "" {
+		err = srv.ServeTLS(listener, cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		err = srv.Serve(listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		p.logger.Error("Pull server failed", logging.Err(err))
+	}
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+const testPullToken = "0123456789abcdef"
+
+func scrapeRequest(token string) *http.Request {
+	req := httptest.NewRequest("GET", "/api/v1/metrics", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestPullServer_RequiresToken(t *testing.T) {
+	p := newPullServer(testPullToken, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	p.publish(&PullResponse{MetricsPayload: MetricsPayload{AgentName: "web-1"}})
+
+	for _, token := range []string{"", "wrong-token-0000"} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, scrapeRequest(token))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for token %q, got %d", token, rec.Code)
+		}
+	}
+	if p.servedLatest() {
+		t.Error("Expected rejected scrapes not to count as served")
+	}
+}
+
+func TestPullServer_ServesLatestSnapshot(t *testing.T) {
+	p := newPullServer(testPullToken, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, scrapeRequest(testPullToken))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before the first collection, got %d", rec.Code)
+	}
+
+	collected := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	p.publish(&PullResponse{
+		MetricsPayload: MetricsPayload{AgentName: "web-1", Timestamp: collected, SystemMetrics: &metrics.SystemMetrics{AgentName: "web-1", Timestamp: collected}},
+		AgentVersion:   "v1.2.0",
+	})
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, scrapeRequest(testPullToken))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp PullResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.AgentName != "web-1" || resp.AgentVersion != "v1.2.0" || !resp.SystemMetrics.Timestamp.Equal(collected) {
+		t.Errorf("Unexpected snapshot: %+v", resp)
+	}
+	if !p.servedLatest() {
+		t.Error("Expected the latest snapshot to count as served")
+	}
+
+	// A newer snapshot hasn't reached the server yet
+	p.publish(&PullResponse{MetricsPayload: MetricsPayload{AgentName: "web-1"}})
+	if p.servedLatest() {
+		t.Error("Expected a new snapshot not to count as served")
+	}
+}
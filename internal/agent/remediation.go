@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+// ContainerRestarter starts stopped containers
+type ContainerRestarter interface {
+	RestartContainer(ctx context.Context, containerID string) error
+}
+
+// remediationHistory tracks restart attempts for a single container
+type remediationHistory struct {
+	attempts    int
+	lastAttempt time.Time
+	gaveUp      bool
+}
+
+// Remediator restarts containers that exit unexpectedly, as allowed by the
+// configured policies. It is only created when remediation is enabled.
+type Remediator struct {
+	restarter ContainerRestarter
+	policies  []config.RemediationPolicy
+	logger    *slog.Logger
+	history   map[string]*remediationHistory // key: container ID
+	now       func() time.Time
+}
+
+// NewRemediator creates a new remediator
+func NewRemediator(restarter ContainerRestarter, policies []config.RemediationPolicy, logger *slog.Logger) *Remediator {
+	return &Remediator{
+		restarter: restarter,
+		policies:  policies,
+		logger:    logger,
+		history:   make(map[string]*remediationHistory),
+		now:       time.Now,
+	}
+}
+
+// Evaluate restarts stopped containers covered by a policy and returns the
+// actions taken, keyed by container ID
+func (r *Remediator) Evaluate(ctx context.Context, containers []metrics.ContainerMetrics) map[string]metrics.RemediationAction {
+	actions := make(map[string]metrics.RemediationAction)
+	now := r.now()
+
+	// Forget containers that were removed, so history doesn't pile up on
+	// hosts that churn through containers
+	present := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		present[container.ID] = true
+	}
+	for id := range r.history {
+		if !present[id] {
+			delete(r.history, id)
+		}
+	}
+
+	for _, container := range containers {
+		policy := r.policyFor(container.Name)
+		if policy == nil {
+			continue
+		}
+
+		history, tracked := r.history[container.ID]
+
+		if container.State == "running" {
+			// Running for a full cooloff means the last restart stuck
+			if tracked && now.Sub(history.lastAttempt) > policy.Cooloff {
+				delete(r.history, container.ID)
+			}
+			continue
+		}
+
+		if container.State != "exited" && container.State != "dead" {
+			continue
+		}
+
+		if !container.OOMKilled && containsInt(policy.IgnoreExitCodes, container.ExitCode) {
+			continue
+		}
+
+		if !tracked {
+			history = &remediationHistory{}
+			r.history[container.ID] = history
+		}
+
+		if history.attempts >= policy.MaxAttempts {
+			if !history.gaveUp {
+				history.gaveUp = true
+				r.logger.Warn("Giving up restarting container",
+					"container", container.Name, "attempts", history.attempts)
+			}
+			continue
+		}
+
+		if history.attempts > 0 && now.Sub(history.lastAttempt) < policy.Cooloff {
+			continue
+		}
+
+		history.attempts++
+		history.lastAttempt = now
+
+		action := metrics.RemediationAction{
+			Action:      "restart",
+			Attempt:     history.attempts,
+			MaxAttempts: policy.MaxAttempts,
+			ExitCode:    container.ExitCode,
+			Timestamp:   now,
+		}
+
+		if err := r.restarter.RestartContainer(ctx, container.ID); err != nil {
+			action.Error = err.Error()
+			r.logger.Error("Container restart failed",
+				"container", container.Name, "attempt", history.attempts, "max_attempts", policy.MaxAttempts, logging.Err(err))
+		} else {
+			action.Success = true
+			r.logger.Info("Restarted container",
+				"container", container.Name, "exit_code", container.ExitCode, "attempt", history.attempts, "max_attempts", policy.MaxAttempts)
+		}
+
+		actions[container.ID] = action
+	}
+
+	return actions
+}
+
+// policyFor returns the last policy matching the container name, if any
+func (r *Remediator) policyFor(name string) *config.RemediationPolicy {
+	var matched *config.RemediationPolicy
+	for i := range r.policies {
+		if matchContainerName(r.policies[i].Name, name) {
+			matched = &r.policies[i]
+		}
+	}
+	return matched
+}
+
+// matchContainerName matches a container name against an exact name or glob pattern
+func matchContainerName(pattern, name string) bool {
+	if strings.Contains(pattern, "*") || strings.Contains(pattern, "?") {
+		match, err := filepath.Match(pattern, name)
+		return err == nil && match
+	}
+	return pattern == name
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
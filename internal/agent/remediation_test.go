@@ -0,0 +1,191 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+// mockRestarter records restart calls
+type mockRestarter struct {
+	restarted []string
+	err       error
+}
+
+func (m *mockRestarter) RestartContainer(ctx context.Context, containerID string) error {
+	m.restarted = append(m.restarted, containerID)
+	return m.err
+}
+
+func newTestRemediator(restarter ContainerRestarter, now *time.Time) *Remediator {
+	policies := []config.RemediationPolicy{
+		{Name: "api-*", MaxAttempts: 2, Cooloff: time.Minute, IgnoreExitCodes: []int{0}},
+	}
+	r := NewRemediator(restarter, policies, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r.now = func() time.Time { return *now }
+	return r
+}
+
+func TestRemediator_RestartsMatchingContainer(t *testing.T) {
+	restarter := &mockRestarter{}
+	now := time.Now()
+	r := newTestRemediator(restarter, &now)
+
+	containers := []metrics.ContainerMetrics{
+		{ID: "c1", Name: "api-v1", State: "exited", ExitCode: 1},
+		{ID: "c2", Name: "postgres", State: "exited", ExitCode: 1},
+		{ID: "c3", Name: "api-v2", State: "exited", ExitCode: 0},
+		{ID: "c4", Name: "api-v3", State: "running"},
+	}
+
+	actions := r.Evaluate(context.Background(), containers)
+
+	if len(restarter.restarted) != 1 || restarter.restarted[0] != "c1" {
+		t.Fatalf("Expected only c1 to be restarted, got %v", restarter.restarted)
+	}
+
+	action, exists := actions["c1"]
+	if !exists {
+		t.Fatal("Expected remediation action for c1")
+	}
+	if action.Action != "restart" || !action.Success || action.Attempt != 1 || action.MaxAttempts != 2 {
+		t.Errorf("Unexpected action: %+v", action)
+	}
+	if action.ExitCode != 1 {
+		t.Errorf("Expected exit code 1, got %d", action.ExitCode)
+	}
+}
+
+func TestRemediator_OOMKilledIgnoresExitCodeFilter(t *testing.T) {
+	restarter := &mockRestarter{}
+	now := time.Now()
+	r := newTestRemediator(restarter, &now)
+
+	r.Evaluate(context.Background(), []metrics.ContainerMetrics{
+		{ID: "c1", Name: "api-v1", State: "exited", ExitCode: 0, OOMKilled: true},
+	})
+
+	if len(restarter.restarted) != 1 {
+		t.Errorf("Expected OOM killed container to be restarted, got %v", restarter.restarted)
+	}
+}
+
+func TestRemediator_CooloffAndMaxAttempts(t *testing.T) {
+	restarter := &mockRestarter{}
+	now := time.Now()
+	r := newTestRemediator(restarter, &now)
+
+	stopped := []metrics.ContainerMetrics{{ID: "c1", Name: "api-v1", State: "exited", ExitCode: 137}}
+
+	r.Evaluate(context.Background(), stopped)
+
+	// Within cooloff: no new attempt
+	now = now.Add(30 * time.Second)
+	if actions := r.Evaluate(context.Background(), stopped); len(actions) != 0 {
+		t.Errorf("Expected no action during cooloff, got %d", len(actions))
+	}
+
+	// After cooloff: second attempt
+	now = now.Add(time.Minute)
+	actions := r.Evaluate(context.Background(), stopped)
+	if actions["c1"].Attempt != 2 {
+		t.Errorf("Expected attempt 2, got %d", actions["c1"].Attempt)
+	}
+
+	// Max attempts reached: give up
+	now = now.Add(2 * time.Minute)
+	if actions := r.Evaluate(context.Background(), stopped); len(actions) != 0 {
+		t.Errorf("Expected no action after max attempts, got %d", len(actions))
+	}
+
+	if len(restarter.restarted) != 2 {
+		t.Errorf("Expected 2 restarts, got %d", len(restarter.restarted))
+	}
+}
+
+func TestRemediator_ResetsAfterStableRun(t *testing.T) {
+	restarter := &mockRestarter{}
+	now := time.Now()
+	r := newTestRemediator(restarter, &now)
+
+	stopped := []metrics.ContainerMetrics{{ID: "c1", Name: "api-v1", State: "exited", ExitCode: 1}}
+	running := []metrics.ContainerMetrics{{ID: "c1", Name: "api-v1", State: "running"}}
+
+	r.Evaluate(context.Background(), stopped)
+
+	// Running longer than cooloff clears the attempt counter
+	now = now.Add(2 * time.Minute)
+	r.Evaluate(context.Background(), running)
+
+	actions := r.Evaluate(context.Background(), stopped)
+	if actions["c1"].Attempt != 1 {
+		t.Errorf("Expected attempt counter to reset, got attempt %d", actions["c1"].Attempt)
+	}
+}
+
+func TestRemediator_ForgetsRemovedContainers(t *testing.T) {
+	restarter := &mockRestarter{}
+	now := time.Now()
+	r := newTestRemediator(restarter, &now)
+
+	r.Evaluate(context.Background(), []metrics.ContainerMetrics{
+		{ID: "c1", Name: "api-v1", State: "exited", ExitCode: 1},
+		{ID: "c2", Name: "api-v2", State: "exited", ExitCode: 1},
+	})
+	if len(r.history) != 2 {
+		t.Fatalf("Expected 2 containers tracked, got %d", len(r.history))
+	}
+
+	// c1 was removed, e.g. a finished CI job
+	r.Evaluate(context.Background(), []metrics.ContainerMetrics{
+		{ID: "c2", Name: "api-v2", State: "exited", ExitCode: 1},
+	})
+	if _, tracked := r.history["c1"]; tracked || len(r.history) != 1 {
+		t.Errorf("Expected only c2 still tracked, got %d containers", len(r.history))
+	}
+}
+
+func TestRemediator_RestartFailure(t *testing.T) {
+	restarter := &mockRestarter{err: errors.New("no such container")}
+	now := time.Now()
+	r := newTestRemediator(restarter, &now)
+
+	actions := r.Evaluate(context.Background(), []metrics.ContainerMetrics{
+		{ID: "c1", Name: "api-v1", State: "dead", ExitCode: 1},
+	})
+
+	action := actions["c1"]
+	if action.Success {
+		t.Error("Expected failed remediation")
+	}
+	if action.Error != "no such container" {
+		t.Errorf("Expected error to be recorded, got '%s'", action.Error)
+	}
+}
+
+func TestMatchContainerName(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"redis", "redis", true},
+		{"redis", "redis-2", false},
+		{"api-*", "api-gateway", true},
+		{"*-service", "user-service", true},
+		{"worker-?", "worker-1", true},
+		{"worker-?", "worker-10", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchContainerName(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchContainerName(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -5,13 +5,20 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/internal/msgpack"
 	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/internal/version"
 	"github.com/anurag/saviour/pkg/metrics"
 )
 
@@ -23,7 +30,21 @@ type Sender struct {
 	maxRetries   int
 	retryBackoff time.Duration
 	ec2Client    *EC2MetadataClient
-	ec2Metadata  *server.EC2Metadata
+
+	maxRetryAfter time.Duration // Longest Retry-After honoured, see SetMaxRetryAfter
+
+	metadataMu    sync.RWMutex
+	provider      CloudMetadataProvider // Set once detection has run
+	ec2Metadata   *server.EC2Metadata   // Legacy form, only on EC2
+	cloudMetadata *server.CloudMetadata
+
+	deltaMu sync.Mutex
+	delta   *deltaTracker // nil unless delta pushes are enabled
+
+	heartbeatInterval time.Duration // Reported so the server can size its offline timeout
+	useMsgpack        bool          // Encode payloads as MessagePack instead of JSON
+
+	refresh atomic.Bool // The server asked for a push right away, see TakeRefreshRequest
 }
 
 // NewSender creates a new metrics sender
@@ -32,24 +53,13 @@ func NewSender(serverURL, apiKey string) *Sender {
 		serverURL: serverURL,
 		apiKey:    apiKey,
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: newTransport(defaultTransportConfig),
 		},
-		maxRetries:   3,
-		retryBackoff: 2 * time.Second,
-		ec2Client:    NewEC2MetadataClient(),
-	}
-
-	// Try to fetch EC2 metadata on initialization (best effort)
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	if IsRunningOnEC2(ctx) {
-		if metadata, err := sender.ec2Client.GetEC2Metadata(ctx); err == nil {
-			sender.ec2Metadata = metadata
-			log.Printf("Running on EC2 instance: %s (%s)", metadata.InstanceID, metadata.InstanceType)
-		} else {
-			log.Printf("Failed to fetch EC2 metadata: %v", err)
-		}
+		maxRetries:    3,
+		retryBackoff:  2 * time.Second,
+		ec2Client:     NewEC2MetadataClient(""),
+		maxRetryAfter: 30 * time.Second,
 	}
 
 	return sender
@@ -60,14 +70,27 @@ type MetricsPayload struct {
 	AgentName     string                 `json:"agent_name"`
 	Timestamp     time.Time              `json:"timestamp"`
 	EC2Metadata   *server.EC2Metadata    `json:"ec2_metadata,omitempty"`
+	CloudMetadata *server.CloudMetadata  `json:"cloud_metadata,omitempty"`
 	SystemMetrics *metrics.SystemMetrics `json:"system_metrics"`
+
+	// Delta push fields, see server.MetricsPushPayload
+	Sequence          uint64   `json:"sequence,omitempty"`
+	Delta             bool     `json:"delta,omitempty"`
+	BaseSequence      uint64   `json:"base_sequence,omitempty"`
+	RemovedContainers []string `json:"removed_containers,omitempty"`
 }
 
 // HeartbeatPayload represents a lightweight heartbeat
 type HeartbeatPayload struct {
 	AgentName string    `json:"agent_name"`
 	Timestamp time.Time `json:"timestamp"`
-	Status    string    `json:"status"` // "online"
+	Status    string    `json:"status"`           // "online", "terminating" or "stopping"
+	Reason    string    `json:"reason,omitempty"` // Why the agent is terminating or stopping
+
+	AgentVersion string `json:"agent_version,omitempty"`
+
+	// How often this agent heartbeats, in seconds
+	HeartbeatIntervalSeconds int `json:"heartbeat_interval_seconds,omitempty"`
 }
 
 // PushMetrics sends metrics to the central server
@@ -77,19 +100,124 @@ func (s *Sender) PushMetrics(ctx context.Context, m *metrics.SystemMetrics) erro
 		return nil
 	}
 
-	payload := MetricsPayload{
+	payload := s.payload(m)
+	endpoint := s.serverURL + "/api/v1/metrics/push"
+	if s.delta == nil {
+		return s.sendWithRetry(ctx, endpoint, payload)
+	}
+
+	s.deltaMu.Lock()
+	defer s.deltaMu.Unlock()
+
+	full := payload
+	commit := s.delta.prepare(&payload, time.Now())
+	err := s.sendWithRetry(ctx, endpoint, payload)
+
+	var httpErr *HTTPError
+	if payload.Delta && errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusConflict {
+		// The server doesn't have the push the delta builds on, e.g. it restarted
+		s.delta.reset()
+		payload = full
+		commit = s.delta.prepare(&payload, time.Now())
+		err = s.sendWithRetry(ctx, endpoint, payload)
+	}
+	if err != nil {
+		return err
+	}
+
+	commit()
+	return nil
+}
+
+// payload wraps metrics with the cloud metadata detected so far
+func (s *Sender) payload(m *metrics.SystemMetrics) MetricsPayload {
+	s.metadataMu.RLock()
+	defer s.metadataMu.RUnlock()
+
+	return MetricsPayload{
 		AgentName:     m.AgentName,
 		Timestamp:     m.Timestamp,
 		EC2Metadata:   s.ec2Metadata, // May be nil if not on EC2
+		CloudMetadata: s.cloudMetadata,
 		SystemMetrics: m,
 	}
+}
 
-	endpoint := s.serverURL + "/api/v1/metrics/push"
-	return s.sendWithRetry(ctx, endpoint, payload)
+// ConfigureTransport replaces the connection settings used to reach the
+// server. Must be called before the first request.
+func (s *Sender) ConfigureTransport(cfg config.TransportConfig) {
+	s.client.Transport = newTransport(cfg)
+}
+
+// SetMaxRetryAfter caps how long a Retry-After from the server can hold up
+// a retry. Retries run on the agent's only loop, so waiting longer than a
+// push interval would stall collection and heartbeats.
+func (s *Sender) SetMaxRetryAfter(limit time.Duration) {
+	if limit > 0 {
+		s.maxRetryAfter = limit
+	}
+}
+
+// SetPushTimeout bounds each attempt of a request to the server, so a hung
+// attempt leaves time for the retries after it
+func (s *Sender) SetPushTimeout(timeout time.Duration) {
+	s.client.Timeout = timeout
+}
+
+// EnableDeltaPush makes pushes between full ones every fullInterval carry
+// only the containers that changed
+func (s *Sender) EnableDeltaPush(fullInterval time.Duration) {
+	s.deltaMu.Lock()
+	defer s.deltaMu.Unlock()
+	s.delta = newDeltaTracker(fullInterval)
+}
+
+// SetHeartbeatInterval sets the interval reported in heartbeats. The server
+// waits a few intervals before marking the agent offline, so agents that
+// heartbeat rarely aren't reported offline between heartbeats.
+func (s *Sender) SetHeartbeatInterval(interval time.Duration) {
+	s.heartbeatInterval = interval
+}
+
+// SetPayloadEncoding sets how payloads are encoded: json (the default) or
+// msgpack
+func (s *Sender) SetPayloadEncoding(encoding string) {
+	s.useMsgpack = encoding == "msgpack"
+}
+
+// TakeRefreshRequest reports whether the server asked, in a response since
+// the last call, for the agent to collect and push right away
+func (s *Sender) TakeRefreshRequest() bool {
+	return s.refresh.Swap(false)
+}
+
+// SetIMDSEndpoint points EC2 metadata requests at a non-default IMDS
+// endpoint, such as a proxy. Must be called before cloud detection.
+func (s *Sender) SetIMDSEndpoint(endpoint string) {
+	s.ec2Client = NewEC2MetadataClient(endpoint)
+}
+
+// OnEC2 reports whether EC2 metadata was detected
+func (s *Sender) OnEC2() bool {
+	s.metadataMu.RLock()
+	defer s.metadataMu.RUnlock()
+	return s.ec2Metadata != nil
+}
+
+// IsSpotInstance reports whether the agent runs on an EC2 spot instance
+func (s *Sender) IsSpotInstance() bool {
+	s.metadataMu.RLock()
+	defer s.metadataMu.RUnlock()
+	return s.cloudMetadata != nil && s.cloudMetadata.Lifecycle == "spot"
 }
 
 // SendHeartbeat sends a lightweight heartbeat signal
 func (s *Sender) SendHeartbeat(ctx context.Context, agentName string) error {
+	return s.SendStatus(ctx, agentName, "online", "")
+}
+
+// SendStatus sends a heartbeat carrying an explicit agent status
+func (s *Sender) SendStatus(ctx context.Context, agentName, status, reason string) error {
 	if s.serverURL == "" {
 		return nil
 	}
@@ -97,11 +225,62 @@ func (s *Sender) SendHeartbeat(ctx context.Context, agentName string) error {
 	payload := HeartbeatPayload{
 		AgentName: agentName,
 		Timestamp: time.Now(),
-		Status:    "online",
+		Status:    status,
+		Reason:    reason,
+
+		AgentVersion: version.Version,
+
+		HeartbeatIntervalSeconds: int((s.heartbeatInterval + time.Second - 1) / time.Second),
 	}
 
 	endpoint := s.serverURL + "/api/v1/heartbeat"
-	return s.sendWithRetry(ctx, endpoint, payload)
+	return s.sendWithRetry(ctx, endpoint, &payload)
+}
+
+// stampedPayload is a payload stamped with its send time, which the server
+// measures clock skew from, rather than its collection time
+type stampedPayload interface {
+	stamp(now time.Time)
+}
+
+// stamp sets the send time, again on each attempt so time spent on
+// retries doesn't look like clock skew
+func (p *HeartbeatPayload) stamp(now time.Time) {
+	p.Timestamp = now
+}
+
+// Deregister removes the agent from the server, used when the agent is
+// shut down on purpose
+func (s *Sender) Deregister(ctx context.Context, agentName string) error {
+	if s.serverURL == "" {
+		return nil
+	}
+
+	endpoint := s.serverURL + "/api/v1/agents/" + url.PathEscape(agentName)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+	req.Header.Set("User-Agent", "saviour-agent/1.0")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Message:    string(bodyBytes),
+	}
 }
 
 // sendWithRetry sends a request with exponential backoff retry
@@ -112,6 +291,13 @@ func (s *Sender) sendWithRetry(ctx context.Context, endpoint string, payload int
 		if attempt > 0 {
 			// Wait before retry
 			backoff := s.retryBackoff * time.Duration(1<<uint(attempt-1)) // Exponential backoff
+
+			// A rate-limited server says how long to back off for, within reason
+			var httpErr *HTTPError
+			if errors.As(lastErr, &httpErr) {
+				backoff = max(backoff, min(httpErr.RetryAfter, s.maxRetryAfter))
+			}
+
 			select {
 			case <-time.After(backoff):
 			case <-ctx.Done():
@@ -119,6 +305,9 @@ func (s *Sender) sendWithRetry(ctx context.Context, endpoint string, payload int
 			}
 		}
 
+		if stamped, ok := payload.(stampedPayload); ok {
+			stamped.stamp(time.Now())
+		}
 		err := s.send(ctx, endpoint, payload)
 		if err == nil {
 			return nil // Success
@@ -135,10 +324,21 @@ func (s *Sender) sendWithRetry(ctx context.Context, endpoint string, payload int
 	return fmt.Errorf("failed after %d retries: %w", s.maxRetries, lastErr)
 }
 
+// gzipWriters reuses compressors across pushes; each allocates several
+// hundred KB of state, which adds up at short push intervals
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
 // send performs the actual HTTP POST
 func (s *Sender) send(ctx context.Context, endpoint string, payload interface{}) error {
-	// Marshal payload to JSON
-	jsonData, err := json.Marshal(payload)
+	// Marshal payload
+	contentType := "application/json"
+	marshal := json.Marshal
+	if s.useMsgpack {
+		contentType, marshal = msgpack.ContentType, msgpack.Marshal
+	}
+	data, err := marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
@@ -146,19 +346,24 @@ func (s *Sender) send(ctx context.Context, endpoint string, payload interface{})
 	// Compress payload if large (> 1KB)
 	var body io.Reader
 	var contentEncoding string
-	if len(jsonData) > 1024 {
-		var buf bytes.Buffer
-		gzipWriter := gzip.NewWriter(&buf)
-		if _, err := gzipWriter.Write(jsonData); err != nil {
-			return fmt.Errorf("failed to compress payload: %w", err)
+	if len(data) > 1024 {
+		// Metrics typically compress well below a quarter of their size.
+		// The buffer isn't pooled: the transport may still read it after Do.
+		buf := bytes.NewBuffer(make([]byte, 0, len(data)/4))
+		gzipWriter := gzipWriters.Get().(*gzip.Writer)
+		gzipWriter.Reset(buf)
+		_, err := gzipWriter.Write(data)
+		if err == nil {
+			err = gzipWriter.Close()
 		}
-		if err := gzipWriter.Close(); err != nil {
-			return fmt.Errorf("failed to close gzip writer: %w", err)
+		gzipWriters.Put(gzipWriter)
+		if err != nil {
+			return fmt.Errorf("failed to compress payload: %w", err)
 		}
-		body = &buf
+		body = buf
 		contentEncoding = "gzip"
 	} else {
-		body = bytes.NewReader(jsonData)
+		body = bytes.NewReader(data)
 	}
 
 	// Create request
@@ -168,7 +373,7 @@ func (s *Sender) send(ctx context.Context, endpoint string, payload interface{})
 	}
 
 	// Set headers
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	if contentEncoding != "" {
 		req.Header.Set("Content-Encoding", contentEncoding)
 	}
@@ -186,27 +391,57 @@ func (s *Sender) send(ctx context.Context, endpoint string, payload interface{})
 
 	// Check response status
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// The server may ask for a refresh. Older servers don't, and a body
+		// that isn't a response isn't an error.
+		var reply struct {
+			Refresh bool `json:"refresh"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&reply)
+		if reply.Refresh {
+			s.refresh.Store(true)
+		}
+		// Drain the body so the connection goes back to the pool
+		io.Copy(io.Discard, resp.Body)
 		return nil // Success
 	}
 
 	// Read error response
 	bodyBytes, _ := io.ReadAll(resp.Body)
-	return &HTTPError{
+	httpErr := &HTTPError{
 		StatusCode: resp.StatusCode,
 		Message:    string(bodyBytes),
 	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		httpErr.RetryAfter, _ = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return httpErr
 }
 
 // HTTPError represents an HTTP error response
 type HTTPError struct {
 	StatusCode int
 	Message    string
+	RetryAfter time.Duration // Retry-After sent with a 429, zero if absent
 }
 
 func (e *HTTPError) Error() string {
 	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
 }
 
+// parseRetryAfter reads a Retry-After header, either seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
 // isRetryable determines if an error should trigger a retry
 func isRetryable(err error) bool {
 	if httpErr, ok := err.(*HTTPError); ok {
@@ -5,13 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/internal/msgpack"
 	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/internal/version"
 	"github.com/anurag/saviour/pkg/metrics"
 )
 
@@ -169,6 +174,7 @@ func TestSendHeartbeat_Success(t *testing.T) {
 	defer server.Close()
 
 	sender := NewSender(server.URL, "test-api-key")
+	sender.SetHeartbeatInterval(1500 * time.Millisecond)
 	ctx := context.Background()
 
 	err := sender.SendHeartbeat(ctx, "test-agent")
@@ -187,6 +193,96 @@ func TestSendHeartbeat_Success(t *testing.T) {
 	if capturedPayload.Status != "online" {
 		t.Errorf("Expected status 'online', got '%s'", capturedPayload.Status)
 	}
+
+	if capturedPayload.AgentVersion != version.Version {
+		t.Errorf("Expected agent version '%s', got '%s'", version.Version, capturedPayload.AgentVersion)
+	}
+
+	if capturedPayload.HeartbeatIntervalSeconds != 2 {
+		t.Errorf("Expected heartbeat interval 2s (rounded up), got %d", capturedPayload.HeartbeatIntervalSeconds)
+	}
+}
+
+func TestSendHeartbeat_RefreshRequested(t *testing.T) {
+	refresh := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "refresh": refresh})
+	}))
+	defer ts.Close()
+
+	sender := NewSender(ts.URL, "test-api-key")
+	if err := sender.SendHeartbeat(context.Background(), "test-agent"); err != nil {
+		t.Fatalf("SendHeartbeat failed: %v", err)
+	}
+	if !sender.TakeRefreshRequest() {
+		t.Error("Expected the refresh request recorded")
+	}
+	if sender.TakeRefreshRequest() {
+		t.Error("Expected the refresh request taken only once")
+	}
+
+	refresh = false
+	sender.SendHeartbeat(context.Background(), "test-agent")
+	if sender.TakeRefreshRequest() {
+		t.Error("Expected no refresh request")
+	}
+}
+
+func TestSendHeartbeat_StampedPerAttempt(t *testing.T) {
+	var stamps []time.Time
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload HeartbeatPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		stamps = append(stamps, payload.Timestamp)
+		if len(stamps) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	sender := NewSender(ts.URL, "test-api-key")
+	sender.retryBackoff = 50 * time.Millisecond
+	if err := sender.SendHeartbeat(context.Background(), "test-agent"); err != nil {
+		t.Fatalf("SendHeartbeat failed: %v", err)
+	}
+	if len(stamps) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(stamps))
+	}
+	// The retry isn't stamped with the first attempt's time, which the
+	// server would take for clock skew
+	if gap := stamps[1].Sub(stamps[0]); gap < 50*time.Millisecond {
+		t.Errorf("Expected the retry stamped after the backoff, got %v later", gap)
+	}
+}
+
+func TestDeregister(t *testing.T) {
+	var method, path, auth string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.EscapedPath()
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := NewSender(server.URL, "test-api-key")
+
+	if err := sender.Deregister(context.Background(), "web 1"); err != nil {
+		t.Fatalf("Deregister failed: %v", err)
+	}
+
+	if method != http.MethodDelete {
+		t.Errorf("Expected DELETE request, got %s", method)
+	}
+	if path != "/api/v1/agents/web%201" {
+		t.Errorf("Expected escaped agent path, got %s", path)
+	}
+	if auth != "Bearer test-api-key" {
+		t.Errorf("Expected bearer token, got '%s'", auth)
+	}
 }
 
 func TestSendHeartbeat_NoServerURL(t *testing.T) {
@@ -251,6 +347,290 @@ func TestSend_GzipCompression(t *testing.T) {
 	}
 }
 
+func TestSend_GzipWriterReuse(t *testing.T) {
+	var received []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("Failed to create gzip reader: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer reader.Close()
+
+		var payload MetricsPayload
+		if err := json.NewDecoder(reader).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode gzipped payload: %v", err)
+		}
+		received = append(received, payload.AgentName)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := NewSender(server.URL, "test-api-key")
+
+	// Pooled writers must not leak state from one payload into the next
+	names := []string{"agent-a", "agent-b", "agent-c"}
+	for _, name := range names {
+		m := &metrics.SystemMetrics{
+			AgentName: name,
+			Timestamp: time.Now(),
+			Containers: []metrics.ContainerMetrics{
+				{ID: strings.Repeat(name, 200)},
+			},
+		}
+		if err := sender.PushMetrics(context.Background(), m); err != nil {
+			t.Fatalf("PushMetrics failed: %v", err)
+		}
+	}
+
+	if strings.Join(received, ",") != strings.Join(names, ",") {
+		t.Errorf("Expected payloads for %v, got %v", names, received)
+	}
+}
+
+func TestPushMetrics_Msgpack(t *testing.T) {
+	var received server.MetricsPushPayload
+	var contentType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			body, _ = gzip.NewReader(r.Body)
+		}
+		if err := msgpack.NewDecoder(body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode MessagePack payload: %v", err)
+		}
+	}))
+	defer ts.Close()
+
+	sender := NewSender(ts.URL, "test-api-key")
+	sender.SetPayloadEncoding("msgpack")
+	m := &metrics.SystemMetrics{
+		AgentName:  "test-agent",
+		Timestamp:  time.Now(),
+		Containers: make([]metrics.ContainerMetrics, 20),
+	}
+	for i := range m.Containers {
+		m.Containers[i] = metrics.ContainerMetrics{ID: strings.Repeat("x", 64), Name: "api"}
+	}
+	if err := sender.PushMetrics(context.Background(), m); err != nil {
+		t.Fatalf("PushMetrics failed: %v", err)
+	}
+
+	if contentType != msgpack.ContentType {
+		t.Errorf("Expected Content-Type %s, got %s", msgpack.ContentType, contentType)
+	}
+	if received.AgentName != "test-agent" || len(received.SystemMetrics.Containers) != 20 {
+		t.Errorf("Expected the push from test-agent with 20 containers, got %s with %d", received.AgentName, len(received.SystemMetrics.Containers))
+	}
+}
+
+func TestPushMetrics_DeltaFallsBackToFull(t *testing.T) {
+	var received []MetricsPayload
+	knowsBase := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Fatalf("Failed to create gzip reader: %v", err)
+			}
+			body = reader
+		}
+
+		var payload MetricsPayload
+		if err := json.NewDecoder(body).Decode(&payload); err != nil {
+			t.Fatalf("Failed to decode payload: %v", err)
+		}
+		received = append(received, payload)
+
+		if payload.Delta && !knowsBase {
+			http.Error(w, "Full metrics push required", http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := NewSender(server.URL, "test-api-key")
+	sender.EnableDeltaPush(time.Hour)
+
+	m := &metrics.SystemMetrics{
+		AgentName:  "test-agent",
+		Containers: []metrics.ContainerMetrics{{ID: "web", State: "running"}, {ID: "db", State: "running"}},
+	}
+	push := func() {
+		t.Helper()
+		if err := sender.PushMetrics(context.Background(), m); err != nil {
+			t.Fatalf("PushMetrics failed: %v", err)
+		}
+	}
+
+	push()
+	push()
+	if len(received) != 2 || received[0].Delta || !received[1].Delta {
+		t.Fatalf("Expected a full push then a delta, got %d pushes", len(received))
+	}
+	if len(received[1].SystemMetrics.Containers) != 0 {
+		t.Errorf("Expected an unchanged delta to carry no containers, got %d", len(received[1].SystemMetrics.Containers))
+	}
+
+	// The server restarts and loses the base
+	knowsBase = false
+	received = nil
+	push()
+	if len(received) != 2 || !received[0].Delta || received[1].Delta {
+		t.Fatalf("Expected a rejected delta followed by a full push, got %d pushes", len(received))
+	}
+	if len(received[1].SystemMetrics.Containers) != 2 {
+		t.Errorf("Expected the full push to carry all containers, got %d", len(received[1].SystemMetrics.Containers))
+	}
+}
+
+func TestSender_ReusesConnections(t *testing.T) {
+	var mu sync.Mutex
+	newConns := 0
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A response body the sender has to drain for the connection to be reused
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			newConns++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	sender := NewSender(server.URL, "test-api-key")
+	sender.ConfigureTransport(config.TransportConfig{
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     time.Minute,
+		TLSHandshakeTimeout: time.Second,
+		KeepAlive:           time.Second,
+	})
+
+	ctx := context.Background()
+	m := &metrics.SystemMetrics{AgentName: "test-agent", Timestamp: time.Now()}
+	for i := 0; i < 5; i++ {
+		if err := sender.PushMetrics(ctx, m); err != nil {
+			t.Fatalf("PushMetrics failed: %v", err)
+		}
+		if err := sender.SendHeartbeat(ctx, "test-agent"); err != nil {
+			t.Fatalf("SendHeartbeat failed: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if newConns != 1 {
+		t.Errorf("Expected pushes and heartbeats to share 1 connection, got %d", newConns)
+	}
+}
+
+func TestSender_PushTimeoutPerAttempt(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		hang := attempts == 1
+		mu.Unlock()
+		if hang {
+			io.Copy(io.Discard, r.Body) // So the server notices the client giving up
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := NewSender(server.URL, "test-api-key")
+	sender.SetPushTimeout(100 * time.Millisecond)
+	sender.retryBackoff = 10 * time.Millisecond // Speed up test
+
+	start := time.Now()
+	if err := sender.SendHeartbeat(context.Background(), "test-agent"); err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the hung attempt to time out, took %v", elapsed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestOrderAddrs(t *testing.T) {
+	v4 := net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	v6 := net.IPAddr{IP: net.ParseIP("2001:db8::1")}
+	addrs := []net.IPAddr{v6, v4}
+
+	tests := []struct {
+		family   string
+		expected []net.IPAddr
+	}{
+		{"auto", []net.IPAddr{v6, v4}},
+		{"prefer_ipv4", []net.IPAddr{v4, v6}},
+		{"prefer_ipv6", []net.IPAddr{v6, v4}},
+		{"ipv4", []net.IPAddr{v4}},
+		{"ipv6", []net.IPAddr{v6}},
+	}
+	for _, tt := range tests {
+		got := orderAddrs(addrs, tt.family)
+		if len(got) != len(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.family, tt.expected, got)
+			continue
+		}
+		for i := range got {
+			if !got[i].IP.Equal(tt.expected[i].IP) {
+				t.Errorf("%s: expected %v, got %v", tt.family, tt.expected, got)
+				break
+			}
+		}
+	}
+}
+
+func TestServerDialer_ResolvesName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// httptest listens on 127.0.0.1, which only the IPv4 address reaches
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	sender := NewSender("http://localhost:"+port, "test-api-key")
+	sender.ConfigureTransport(config.TransportConfig{
+		MaxIdleConnsPerHost: 2,
+		DialTimeout:         time.Second,
+		DNSTimeout:          time.Second,
+		IPFamily:            "ipv4",
+	})
+
+	if err := sender.SendHeartbeat(context.Background(), "test-agent"); err != nil {
+		t.Errorf("Expected to reach localhost over IPv4, got %v", err)
+	}
+}
+
+func TestServerDialer_RejectsLiteralOfOtherFamily(t *testing.T) {
+	d := &serverDialer{resolver: net.DefaultResolver, family: "ipv6"}
+	if _, err := d.DialContext(context.Background(), "tcp", "127.0.0.1:9"); err == nil || !strings.Contains(err.Error(), "not ipv6") {
+		t.Errorf("Expected an IPv4 address rejected with ip_family ipv6, got %v", err)
+	}
+}
+
 func TestSend_SmallPayloadNoCompression(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		contentEncoding := r.Header.Get("Content-Encoding")
@@ -401,6 +781,96 @@ func TestSendWithRetry_RateLimitRetry(t *testing.T) {
 	}
 }
 
+func TestSendWithRetry_RateLimitRetryAfter(t *testing.T) {
+	var attemptTimes []time.Time
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attemptTimes = append(attemptTimes, time.Now())
+		if len(attemptTimes) < 2 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	sender := NewSender(server.URL, "test-api-key")
+	sender.retryBackoff = 10 * time.Millisecond
+	ctx := context.Background()
+
+	payload := map[string]string{"test": "data"}
+	if err := sender.sendWithRetry(ctx, server.URL, payload); err != nil {
+		t.Fatalf("Expected success after rate limit retry, got error: %v", err)
+	}
+
+	if len(attemptTimes) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(attemptTimes))
+	}
+
+	// The server's Retry-After outweighs the much shorter backoff
+	if delay := attemptTimes[1].Sub(attemptTimes[0]); delay < time.Second {
+		t.Errorf("Expected retry to wait at least the Retry-After of 1s, waited %v", delay)
+	}
+}
+
+func TestSendWithRetry_RetryAfterCapped(t *testing.T) {
+	attempts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 2 {
+			w.Header().Set("Retry-After", "86400")
+			w.WriteHeader(http.StatusTooManyRequests)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	sender := NewSender(server.URL, "test-api-key")
+	sender.retryBackoff = 10 * time.Millisecond
+	sender.SetMaxRetryAfter(50 * time.Millisecond)
+
+	// A day-long Retry-After must not stall the agent's loop
+	start := time.Now()
+	payload := map[string]string{"test": "data"}
+	if err := sender.sendWithRetry(context.Background(), server.URL, payload); err != nil {
+		t.Fatalf("Expected success after rate limit retry, got error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Retry-After to be capped at 50ms, waited %v", elapsed)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"7", 7 * time.Second, true},
+		{"-1", 0, false},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q): expected %v/%v, got %v/%v", tt.value, tt.expected, tt.ok, got, ok)
+		}
+	}
+}
+
 func TestSendWithRetry_ContextCancellation(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
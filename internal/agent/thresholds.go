@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+// Container labels that let app teams set their own alert thresholds, e.g.
+//
+//	labels:
+//	  saviour.cpu_threshold: "95"
+//	  saviour.memory_threshold: "80"
+//	  saviour.restart_threshold: "10"
+const (
+	LabelCPUThreshold     = "saviour.cpu_threshold"
+	LabelMemoryThreshold  = "saviour.memory_threshold"
+	LabelRestartThreshold = "saviour.restart_threshold"
+)
+
+// thresholdsFromLabels parses threshold labels. It returns nil when no
+// threshold labels are set; invalid values are reported and skipped.
+func thresholdsFromLabels(labels map[string]string) (*metrics.ContainerThresholds, []error) {
+	var thresholds metrics.ContainerThresholds
+	var errs []error
+	found := false
+
+	if v, exists := labels[LabelCPUThreshold]; exists {
+		if pct, err := parsePercentLabel(LabelCPUThreshold, v); err != nil {
+			errs = append(errs, err)
+		} else {
+			thresholds.CPUThreshold = pct
+			found = true
+		}
+	}
+
+	if v, exists := labels[LabelMemoryThreshold]; exists {
+		if pct, err := parsePercentLabel(LabelMemoryThreshold, v); err != nil {
+			errs = append(errs, err)
+		} else {
+			thresholds.MemoryThreshold = pct
+			found = true
+		}
+	}
+
+	if v, exists := labels[LabelRestartThreshold]; exists {
+		count, err := strconv.Atoi(v)
+		if err != nil || count <= 0 {
+			errs = append(errs, fmt.Errorf("invalid %s label %q: must be a positive integer", LabelRestartThreshold, v))
+		} else {
+			thresholds.RestartThreshold = count
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, errs
+	}
+	return &thresholds, errs
+}
+
+// parsePercentLabel parses a percentage label value in (0, 100]
+func parsePercentLabel(label, value string) (float64, error) {
+	pct, err := strconv.ParseFloat(value, 64)
+	if err != nil || pct <= 0 || pct > 100 {
+		return 0, fmt.Errorf("invalid %s label %q: must be between 0 and 100", label, value)
+	}
+	return pct, nil
+}
@@ -0,0 +1,54 @@
+package agent
+
+import "testing"
+
+func TestThresholdsFromLabels(t *testing.T) {
+	thresholds, errs := thresholdsFromLabels(map[string]string{
+		"com.docker.compose.service": "api",
+		LabelCPUThreshold:            "95",
+		LabelMemoryThreshold:         "80.5",
+		LabelRestartThreshold:        "10",
+	})
+
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if thresholds == nil {
+		t.Fatal("Expected thresholds, got nil")
+	}
+	if thresholds.CPUThreshold != 95 {
+		t.Errorf("CPUThreshold = %v, want 95", thresholds.CPUThreshold)
+	}
+	if thresholds.MemoryThreshold != 80.5 {
+		t.Errorf("MemoryThreshold = %v, want 80.5", thresholds.MemoryThreshold)
+	}
+	if thresholds.RestartThreshold != 10 {
+		t.Errorf("RestartThreshold = %v, want 10", thresholds.RestartThreshold)
+	}
+}
+
+func TestThresholdsFromLabels_NoLabels(t *testing.T) {
+	thresholds, errs := thresholdsFromLabels(map[string]string{"env": "prod"})
+
+	if thresholds != nil {
+		t.Errorf("Expected nil thresholds, got %+v", thresholds)
+	}
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+}
+
+func TestThresholdsFromLabels_InvalidValues(t *testing.T) {
+	thresholds, errs := thresholdsFromLabels(map[string]string{
+		LabelCPUThreshold:     "high",
+		LabelMemoryThreshold:  "150",
+		LabelRestartThreshold: "-1",
+	})
+
+	if thresholds != nil {
+		t.Errorf("Expected nil thresholds for invalid labels, got %+v", thresholds)
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %d: %v", len(errs), errs)
+	}
+}
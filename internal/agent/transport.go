@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/anurag/saviour/internal/config"
+)
+
+// defaultTransportConfig matches the config defaults, for senders created
+// without a config
+var defaultTransportConfig = config.TransportConfig{
+	MaxIdleConnsPerHost: 2,
+	IdleConnTimeout:     90 * time.Second,
+	TLSHandshakeTimeout: 10 * time.Second,
+	KeepAlive:           30 * time.Second,
+	DialTimeout:         5 * time.Second,
+	DNSTimeout:          5 * time.Second,
+	IPFamily:            "auto",
+}
+
+// newTransport builds the sender's own transport, so its idle connections
+// aren't shared with (or evicted by) other clients in the process
+func newTransport(cfg config.TransportConfig) *http.Transport {
+	dialer := &serverDialer{
+		dialer: net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: cfg.KeepAlive,
+		},
+		resolver:   net.DefaultResolver,
+		dnsTimeout: cfg.DNSTimeout,
+		family:     cfg.IPFamily,
+	}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConnsPerHost,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// serverDialer dials the server. With ip_family auto it is a plain
+// net.Dialer, which races IPv4 and IPv6 (Happy Eyeballs). Otherwise it
+// resolves the server's name itself, within dnsTimeout, and dials the
+// addresses of the chosen family first.
+type serverDialer struct {
+	dialer     net.Dialer // Timeout applies to each address
+	resolver   *net.Resolver
+	dnsTimeout time.Duration
+	family     string // See config.TransportConfig.IPFamily
+}
+
+// DialContext resolves addr and dials its addresses in the preferred order
+// until one connects
+func (d *serverDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.family == "" || d.family == "auto" {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if len(orderAddrs([]net.IPAddr{{IP: ip}}, d.family)) == 0 {
+			return nil, &net.AddrError{Err: "address is not " + d.family, Addr: host}
+		}
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+
+	lookupCtx := ctx
+	if d.dnsTimeout > 0 {
+		var cancel context.CancelFunc
+		lookupCtx, cancel = context.WithTimeout(ctx, d.dnsTimeout)
+		defer cancel()
+	}
+	addrs, err := d.resolver.LookupIPAddr(lookupCtx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs = orderAddrs(addrs, d.family)
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no " + d.family + " address", Name: host, IsNotFound: true}
+	}
+
+	var firstErr error
+	for _, ip := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// orderAddrs filters and orders resolved addresses for family: "ipv4" or
+// "ipv6" keep only that family, "prefer_ipv4" or "prefer_ipv6" try it
+// first, and anything else keeps the resolver's order
+func orderAddrs(addrs []net.IPAddr, family string) []net.IPAddr {
+	var v4, v6 []net.IPAddr
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			v4 = append(v4, addr)
+		} else {
+			v6 = append(v6, addr)
+		}
+	}
+
+	switch family {
+	case "ipv4":
+		return v4
+	case "ipv6":
+		return v6
+	case "prefer_ipv4":
+		return append(v4, v6...)
+	case "prefer_ipv6":
+		return append(v6, v4...)
+	default:
+		return addrs
+	}
+}
@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+// restartTimeout bounds systemctl restart
+const restartTimeout = 30 * time.Second
+
+// ProcessRestarter starts a process that has died
+type ProcessRestarter interface {
+	RestartProcess(ctx context.Context, process config.ProcessConfig) error
+}
+
+// systemRestarter restarts a process's systemd unit, or runs its restart
+// command
+type systemRestarter struct{}
+
+func (systemRestarter) RestartProcess(ctx context.Context, process config.ProcessConfig) error {
+	if process.SystemdUnit != "" {
+		ctx, cancel := context.WithTimeout(ctx, restartTimeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, "systemctl", "restart", process.SystemdUnit).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("systemctl restart %s: %w: %s", process.SystemdUnit, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	cmd := exec.Command(process.RestartCommand[0], process.RestartCommand[1:]...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap it once it exits, e.g. after daemonizing
+	go cmd.Wait()
+	return nil
+}
+
+// processHistory tracks restart attempts for a single process
+type processHistory struct {
+	remediationHistory
+	last *metrics.RemediationAction
+}
+
+// ProcessWatchdog looks for the configured processes each collection and
+// restarts those with restart_on_failure that it finds dead, a limited number
+// of times
+type ProcessWatchdog struct {
+	processes []config.ProcessConfig
+	restarter ProcessRestarter
+	procRoot  string
+	logger    *slog.Logger
+	history   map[string]*processHistory // key: process name
+	down      map[string]bool            // Processes already logged as not running
+	now       func() time.Time
+}
+
+// NewProcessWatchdog creates a watchdog for the configured processes
+func NewProcessWatchdog(processes []config.ProcessConfig, logger *slog.Logger) *ProcessWatchdog {
+	return &ProcessWatchdog{
+		processes: processes,
+		restarter: systemRestarter{},
+		procRoot:  "/proc",
+		logger:    logger,
+		history:   make(map[string]*processHistory),
+		down:      make(map[string]bool),
+		now:       time.Now,
+	}
+}
+
+// Check returns the state of each watched process, restarting dead ones as
+// their configuration allows
+func (w *ProcessWatchdog) Check(ctx context.Context) ([]metrics.WatchedProcess, error) {
+	counts, err := countProcesses(w.procRoot)
+	if err != nil {
+		return nil, err
+	}
+	now := w.now()
+
+	result := make([]metrics.WatchedProcess, 0, len(w.processes))
+	for _, process := range w.processes {
+		state := metrics.WatchedProcess{Name: process.Name, Count: counts[process.Name]}
+		state.Running = state.Count > 0
+		history, tracked := w.history[process.Name]
+
+		if state.Running {
+			delete(w.down, process.Name)
+			// Running for a full cooloff means the last restart stuck
+			if tracked && now.Sub(history.lastAttempt) > process.Cooloff {
+				delete(w.history, process.Name)
+				tracked = false
+			}
+		} else {
+			if !w.down[process.Name] {
+				w.down[process.Name] = true
+				w.logger.Warn("Watched process is not running", "process", process.Name)
+			}
+			if process.RestartOnFailure {
+				if !tracked {
+					history = &processHistory{}
+					w.history[process.Name] = history
+					tracked = true
+				}
+				w.restart(ctx, process, history, now)
+			}
+		}
+
+		if tracked {
+			state.Remediation = history.last
+		}
+		result = append(result, state)
+	}
+	return result, nil
+}
+
+// restart restarts a dead process unless it is cooling off from the last
+// attempt or out of attempts
+func (w *ProcessWatchdog) restart(ctx context.Context, process config.ProcessConfig, history *processHistory, now time.Time) {
+	if history.attempts >= process.MaxAttempts {
+		if !history.gaveUp {
+			history.gaveUp = true
+			w.logger.Warn("Giving up restarting process", "process", process.Name, "attempts", history.attempts)
+		}
+		return
+	}
+	if history.attempts > 0 && now.Sub(history.lastAttempt) < process.Cooloff {
+		return
+	}
+
+	history.attempts++
+	history.lastAttempt = now
+	action := &metrics.RemediationAction{
+		Action:      "restart",
+		Attempt:     history.attempts,
+		MaxAttempts: process.MaxAttempts,
+		Timestamp:   now,
+	}
+	if err := w.restarter.RestartProcess(ctx, process); err != nil {
+		action.Error = err.Error()
+		w.logger.Error("Process restart failed",
+			"process", process.Name, "attempt", history.attempts, "max_attempts", process.MaxAttempts, logging.Err(err))
+	} else {
+		action.Success = true
+		w.logger.Info("Restarted process",
+			"process", process.Name, "attempt", history.attempts, "max_attempts", process.MaxAttempts)
+	}
+	history.last = action
+}
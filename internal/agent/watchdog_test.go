@@ -0,0 +1,135 @@
+//go:build !windows
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+// mockProcessRestarter records restart calls
+type mockProcessRestarter struct {
+	restarted []string
+	err       error
+}
+
+func (m *mockProcessRestarter) RestartProcess(ctx context.Context, process config.ProcessConfig) error {
+	m.restarted = append(m.restarted, process.Name)
+	return m.err
+}
+
+// writeProc fakes a /proc entry
+func writeProc(t *testing.T, root string, pid int, stat, cmdline string) {
+	t.Helper()
+	dir := filepath.Join(root, strconv.Itoa(pid))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644)
+	os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0o644)
+}
+
+func newTestWatchdog(t *testing.T, restarter ProcessRestarter, now *time.Time) (*ProcessWatchdog, string) {
+	processes := []config.ProcessConfig{
+		{Name: "nginx", RestartOnFailure: true, SystemdUnit: "nginx.service", MaxAttempts: 2, Cooloff: time.Minute},
+		{Name: "cron"},
+	}
+	w := NewProcessWatchdog(processes, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	w.restarter = restarter
+	w.procRoot = t.TempDir()
+	w.now = func() time.Time { return *now }
+	return w, w.procRoot
+}
+
+func TestCountProcesses(t *testing.T) {
+	root := t.TempDir()
+	writeProc(t, root, 1, "1 (systemd) S 0 1", "/sbin/init\x00splash\x00")
+	writeProc(t, root, 20, "20 (nginx) S 1 20", "nginx: master process\x00")
+	writeProc(t, root, 21, "21 (nginx) S 20 20", "nginx: worker process\x00")
+	writeProc(t, root, 30, "30 (my (odd) name) R 1 30", "")
+	writeProc(t, root, 40, "40 (payments-servi) S 1 40", "/opt/bin/payments-service\x00--port\x00")
+	writeProc(t, root, 50, "50 (cron) Z 1 50", "")
+	os.MkdirAll(filepath.Join(root, "self"), 0o755)
+
+	counts, err := countProcesses(root)
+	if err != nil {
+		t.Fatalf("countProcesses failed: %v", err)
+	}
+	want := map[string]int{"systemd": 1, "init": 1, "nginx": 2, "my (odd) name": 1, "payments-service": 1}
+	for name, n := range want {
+		if counts[name] != n {
+			t.Errorf("Expected %d %s, got %d", n, name, counts[name])
+		}
+	}
+	if counts["cron"] != 0 {
+		t.Error("Expected zombies not counted")
+	}
+}
+
+func TestProcessWatchdog_Restarts(t *testing.T) {
+	restarter := &mockProcessRestarter{}
+	now := time.Now()
+	w, root := newTestWatchdog(t, restarter, &now)
+
+	processes, err := w.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(restarter.restarted) != 1 || restarter.restarted[0] != "nginx" {
+		t.Fatalf("Expected only nginx restarted, got %v", restarter.restarted)
+	}
+	if processes[0].Running || processes[0].Remediation == nil || !processes[0].Remediation.Success || processes[0].Remediation.Attempt != 1 {
+		t.Errorf("Expected the restart reported for nginx, got %+v", processes[0])
+	}
+	if processes[1].Running || processes[1].Remediation != nil {
+		t.Errorf("Expected cron down without a restart, got %+v", processes[1])
+	}
+
+	// Still down within the cooloff: no new attempt
+	now = now.Add(30 * time.Second)
+	w.Check(context.Background())
+	if len(restarter.restarted) != 1 {
+		t.Errorf("Expected no restart within the cooloff, got %d", len(restarter.restarted))
+	}
+
+	// Up again: the restart is still reported until it has stuck
+	writeProc(t, root, 20, "20 (nginx) S 1 20", "nginx\x00")
+	now = now.Add(20 * time.Second)
+	processes, _ = w.Check(context.Background())
+	if !processes[0].Running || processes[0].Count != 1 || processes[0].Remediation == nil {
+		t.Errorf("Expected nginx running with its restart reported, got %+v", processes[0])
+	}
+	now = now.Add(time.Minute)
+	processes, _ = w.Check(context.Background())
+	if processes[0].Remediation != nil {
+		t.Errorf("Expected the restart cleared once it stuck, got %+v", processes[0].Remediation)
+	}
+}
+
+func TestProcessWatchdog_GivesUp(t *testing.T) {
+	restarter := &mockProcessRestarter{err: errors.New("unit not found")}
+	now := time.Now()
+	w, _ := newTestWatchdog(t, restarter, &now)
+
+	var processes []metrics.WatchedProcess
+	for i := 0; i < 4; i++ {
+		processes, _ = w.Check(context.Background())
+		now = now.Add(2 * time.Minute)
+	}
+	if len(restarter.restarted) != 2 {
+		t.Errorf("Expected 2 attempts, got %d", len(restarter.restarted))
+	}
+	if action := processes[0].Remediation; action == nil || action.Success || action.Error != "unit not found" || action.Attempt != 2 {
+		t.Errorf("Expected the last failed attempt reported, got %+v", action)
+	}
+}
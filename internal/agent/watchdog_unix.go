@@ -0,0 +1,52 @@
+//go:build !windows
+
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// countProcesses counts live processes by name under procRoot. A process
+// counts under its command name and the base name of its first argument, as
+// the kernel truncates command names to 15 characters.
+func countProcesses(procRoot string) (map[string]int, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		// Processes can exit while being read; skip them
+		stat, err := os.ReadFile(filepath.Join(procRoot, entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// pid (comm) state ...; comm may itself contain parentheses
+		start, end := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+		if start < 0 || end < start || end+2 >= len(stat) {
+			continue
+		}
+		if state := stat[end+2]; state == 'Z' || state == 'X' {
+			continue
+		}
+
+		comm := string(stat[start+1 : end])
+		counts[comm]++
+		if cmdline, err := os.ReadFile(filepath.Join(procRoot, entry.Name(), "cmdline")); err == nil {
+			argv0, _, _ := strings.Cut(string(cmdline), "\x00")
+			if name := filepath.Base(argv0); argv0 != "" && name != comm {
+				counts[name]++
+			}
+		}
+	}
+	return counts, nil
+}
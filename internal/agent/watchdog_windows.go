@@ -0,0 +1,37 @@
+//go:build windows
+
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// countProcesses counts running processes by executable name, from a
+// snapshot of the process list; procRoot is unused. A process counts with and
+// without its .exe suffix, so "nginx" and "nginx.exe" both match.
+func countProcesses(string) (map[string]int, error) {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	defer windows.CloseHandle(snapshot)
+
+	counts := make(map[string]int)
+	entry := windows.ProcessEntry32{Size: uint32(unsafe.Sizeof(windows.ProcessEntry32{}))}
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		exe := windows.UTF16ToString(entry.ExeFile[:])
+		counts[exe]++
+		if len(exe) > 4 && strings.EqualFold(exe[len(exe)-4:], ".exe") {
+			counts[exe[:len(exe)-4]]++
+		}
+	}
+	if !errors.Is(err, windows.ERROR_NO_MORE_FILES) {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	return counts, nil
+}
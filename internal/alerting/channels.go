@@ -0,0 +1,178 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+)
+
+// ChannelChecker is a notifier that can verify its channel works, such as a
+// webhook being reachable and accepting its token, without notifying anyone
+type ChannelChecker interface {
+	CheckChannel() error
+}
+
+// ChannelStatus is the health of a notification channel, from periodic
+// checks and from the alerts sent through it
+type ChannelStatus struct {
+	Channel             string
+	Healthy             bool
+	LastCheck           time.Time // Zero until checked or used
+	LastSuccess         time.Time
+	LastError           string
+	FailingSince        time.Time // Zero while healthy
+	ConsecutiveFailures int
+}
+
+// ChannelMonitor tracks the health of notification channels, so a broken
+// webhook shows up on the health endpoint and as an alert rather than as a
+// missed page
+type ChannelMonitor struct {
+	logger     *slog.Logger
+	interval   time.Duration // Between checks
+	alertAfter time.Duration // How long a channel fails before it alerts
+
+	mu       sync.Mutex
+	channels []*watchedChannel
+}
+
+// watchedChannel is a notifier and its health
+type watchedChannel struct {
+	notifier Notifier
+	status   ChannelStatus
+	alerted  bool // Already alerted on the current failure
+}
+
+// NewChannelMonitor creates a monitor checking channels every interval and
+// alerting on those failing for alertAfter
+func NewChannelMonitor(interval, alertAfter time.Duration) *ChannelMonitor {
+	return &ChannelMonitor{logger: logging.Component("alerting"), interval: interval, alertAfter: alertAfter}
+}
+
+// Watch starts tracking a notifier under a channel name. Alerts must be sent
+// through the returned notifier for their outcome to count.
+func (m *ChannelMonitor) Watch(name string, notifier Notifier) Notifier {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	channel := &watchedChannel{notifier: notifier, status: ChannelStatus{Channel: name, Healthy: true}}
+	m.channels = append(m.channels, channel)
+	return &monitoredNotifier{monitor: m, channel: channel}
+}
+
+// monitoredNotifier records the outcome of each alert sent to a channel
+type monitoredNotifier struct {
+	monitor *ChannelMonitor
+	channel *watchedChannel
+}
+
+// SendAlert sends the alert and records whether the channel took it
+func (n *monitoredNotifier) SendAlert(alert *Alert) error {
+	err := n.channel.notifier.SendAlert(alert)
+	n.monitor.record(n.channel, err, time.Now())
+	return err
+}
+
+// Status returns the health of every watched channel
+func (m *ChannelMonitor) Status() []ChannelStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]ChannelStatus, len(m.channels))
+	for i, channel := range m.channels {
+		statuses[i] = channel.status
+	}
+	return statuses
+}
+
+// Run checks the channels every interval until ctx is done, raising an
+// alert through raise for each that keeps failing (nil to only track them)
+func (m *ChannelMonitor) Run(ctx context.Context, raise func(ExternalAlert) *Alert) {
+	if m.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	m.check(raise, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check(raise, time.Now())
+		}
+	}
+}
+
+// check verifies every channel that supports it and alerts on those that
+// have been failing for alertAfter
+func (m *ChannelMonitor) check(raise func(ExternalAlert) *Alert, now time.Time) {
+	m.mu.Lock()
+	channels := append([]*watchedChannel(nil), m.channels...)
+	m.mu.Unlock()
+
+	for _, channel := range channels {
+		if checker, ok := channel.notifier.(ChannelChecker); ok {
+			m.record(channel, checker.CheckChannel(), now)
+		}
+
+		m.mu.Lock()
+		status := channel.status
+		due := !status.Healthy && !channel.alerted && now.Sub(status.FailingSince) >= m.alertAfter
+		if due {
+			channel.alerted = true
+		}
+		m.mu.Unlock()
+
+		if due && raise != nil {
+			raise(ExternalAlert{
+				Source:    "saviour-server",
+				AgentName: "saviour-server",
+				AlertType: "notification_channel_failing",
+				Severity:  "critical",
+				Message:   fmt.Sprintf("Notification channel %s failing for %s: %s", status.Channel, HumanizeDuration(now.Sub(status.FailingSince)), status.LastError),
+				Details: map[string]interface{}{
+					"channel":              status.Channel,
+					"failing_since":        status.FailingSince,
+					"consecutive_failures": status.ConsecutiveFailures,
+					"error":                status.LastError,
+				},
+			})
+		}
+	}
+}
+
+// record updates a channel's health with the outcome of a check or send
+func (m *ChannelMonitor) record(channel *watchedChannel, err error, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := &channel.status
+	status.LastCheck = at
+	if err == nil {
+		if !status.Healthy {
+			m.logger.Info("Notification channel recovered", "channel", status.Channel, "failures", status.ConsecutiveFailures)
+		}
+		status.Healthy = true
+		status.LastSuccess = at
+		status.LastError = ""
+		status.FailingSince = time.Time{}
+		status.ConsecutiveFailures = 0
+		channel.alerted = false
+		return
+	}
+
+	if status.Healthy {
+		m.logger.Warn("Notification channel failing", "channel", status.Channel, logging.Err(err))
+		status.FailingSince = at
+	}
+	status.Healthy = false
+	status.LastError = err.Error()
+	status.ConsecutiveFailures++
+}
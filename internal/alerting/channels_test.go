@@ -0,0 +1,112 @@
+package alerting
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// checkedNotifier is a notifier whose channel check fails while err is set
+type checkedNotifier struct {
+	MockNotifier
+	err error
+}
+
+func (n *checkedNotifier) CheckChannel() error {
+	return n.err
+}
+
+func TestChannelMonitor_RecordsSends(t *testing.T) {
+	monitor := NewChannelMonitor(time.Minute, 15*time.Minute)
+	mock := NewMockNotifier()
+	notifier := monitor.Watch("console", mock)
+
+	mock.shouldFail = true
+	if err := notifier.SendAlert(&Alert{ID: "a1"}); err == nil {
+		t.Fatal("Expected the send error to be returned")
+	}
+	notifier.SendAlert(&Alert{ID: "a2"})
+
+	status := monitor.Status()
+	if len(status) != 1 {
+		t.Fatalf("Expected 1 channel, got %d", len(status))
+	}
+	if status[0].Channel != "console" || status[0].Healthy || status[0].ConsecutiveFailures != 2 || status[0].LastError == "" {
+		t.Errorf("Expected console failing twice, got %+v", status[0])
+	}
+	if status[0].FailingSince.IsZero() {
+		t.Error("Expected failing since to be set")
+	}
+
+	mock.shouldFail = false
+	if err := notifier.SendAlert(&Alert{ID: "a3"}); err != nil {
+		t.Fatalf("Expected send to succeed, got %v", err)
+	}
+	status = monitor.Status()
+	if !status[0].Healthy || status[0].ConsecutiveFailures != 0 || !status[0].FailingSince.IsZero() || status[0].LastSuccess.IsZero() {
+		t.Errorf("Expected console recovered, got %+v", status[0])
+	}
+	if len(mock.sentAlerts) != 1 {
+		t.Errorf("Expected 1 alert delivered, got %d", len(mock.sentAlerts))
+	}
+}
+
+func TestChannelMonitor_AlertsOncePerFailure(t *testing.T) {
+	monitor := NewChannelMonitor(time.Minute, 10*time.Minute)
+	notifier := &checkedNotifier{err: errors.New("webhook returned status 404")}
+	monitor.Watch("google_chat", notifier)
+
+	var raised []ExternalAlert
+	raise := func(alert ExternalAlert) *Alert {
+		raised = append(raised, alert)
+		return nil
+	}
+
+	start := time.Now()
+	monitor.check(raise, start)
+	monitor.check(raise, start.Add(5*time.Minute))
+	if len(raised) != 0 {
+		t.Fatalf("Expected no alert before alert_after, got %d", len(raised))
+	}
+
+	monitor.check(raise, start.Add(10*time.Minute))
+	monitor.check(raise, start.Add(15*time.Minute))
+	if len(raised) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(raised))
+	}
+	if raised[0].AlertType != "notification_channel_failing" || raised[0].Severity != "critical" || raised[0].Details["channel"] != "google_chat" {
+		t.Errorf("Unexpected alert: %+v", raised[0])
+	}
+	if failures := monitor.Status()[0].ConsecutiveFailures; failures != 4 {
+		t.Errorf("Expected 4 consecutive failures, got %d", failures)
+	}
+
+	// Recovering rearms the alert for the next failure
+	notifier.err = nil
+	monitor.check(raise, start.Add(20*time.Minute))
+	notifier.err = errors.New("webhook unreachable")
+	monitor.check(raise, start.Add(25*time.Minute))
+	monitor.check(raise, start.Add(35*time.Minute))
+	if len(raised) != 2 {
+		t.Errorf("Expected a second alert after recovery, got %d alerts", len(raised))
+	}
+}
+
+func TestChannelMonitor_RunDisabled(t *testing.T) {
+	monitor := NewChannelMonitor(0, time.Minute)
+	monitor.Watch("google_chat", &checkedNotifier{err: errors.New("down")})
+
+	done := make(chan struct{})
+	go func() {
+		monitor.Run(t.Context(), nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Run to return when checks are disabled")
+	}
+	if !monitor.Status()[0].Healthy {
+		t.Error("Expected channel to stay unchecked")
+	}
+}
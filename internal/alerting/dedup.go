@@ -0,0 +1,76 @@
+package alerting
+
+import (
+	"container/list"
+	"time"
+)
+
+// maxDedupEntries caps the deduplication cache. Keys include container IDs,
+// which churn fast on CI-like hosts, so without a cap the cache grows
+// without bound between cleanups.
+const maxDedupEntries = 10000
+
+// dedupCache remembers when each alert key was last sent, holding at most
+// capacity keys and evicting the one sent longest ago. It is not safe for
+// concurrent use; the engine guards it with its mutex.
+type dedupCache struct {
+	capacity int
+	order    *list.List               // Front was sent most recently
+	entries  map[string]*list.Element // Values are *dedupEntry
+}
+
+type dedupEntry struct {
+	key  string
+	sent time.Time
+}
+
+func newDedupCache(capacity int) *dedupCache {
+	return &dedupCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// get returns when key was last sent
+func (c *dedupCache) get(key string) (time.Time, bool) {
+	elem, exists := c.entries[key]
+	if !exists {
+		return time.Time{}, false
+	}
+	return elem.Value.(*dedupEntry).sent, true
+}
+
+// set records that key was sent, evicting the oldest key if full
+func (c *dedupCache) set(key string, sent time.Time) {
+	if elem, exists := c.entries[key]; exists {
+		elem.Value.(*dedupEntry).sent = sent
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&dedupEntry{key: key, sent: sent})
+	if c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+// removeOlderThan drops keys last sent before cutoff
+func (c *dedupCache) removeOlderThan(cutoff time.Time) {
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*dedupEntry).sent.Before(cutoff) {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+func (c *dedupCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*dedupEntry).key)
+}
+
+func (c *dedupCache) len() int {
+	return c.order.Len()
+}
@@ -0,0 +1,69 @@
+package alerting
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDedupCache_EvictsOldestSent(t *testing.T) {
+	cache := newDedupCache(3)
+	now := time.Now()
+
+	cache.set("a", now)
+	cache.set("b", now)
+	cache.set("c", now)
+
+	// Re-sending a makes b the oldest
+	cache.set("a", now.Add(time.Second))
+	cache.set("d", now.Add(2*time.Second))
+
+	if cache.len() != 3 {
+		t.Fatalf("Expected 3 entries, got %d", cache.len())
+	}
+	if _, exists := cache.get("b"); exists {
+		t.Error("Expected b to be evicted as the oldest sent")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, exists := cache.get(key); !exists {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+	if sent, _ := cache.get("a"); !sent.Equal(now.Add(time.Second)) {
+		t.Errorf("Expected a's send time to be updated, got %v", sent)
+	}
+}
+
+func TestDedupCache_Bounded(t *testing.T) {
+	cache := newDedupCache(100)
+
+	// Simulate container churn: every key is new
+	for i := 0; i < 10000; i++ {
+		cache.set(fmt.Sprintf("container_stopped:agent:%d", i), time.Now())
+	}
+
+	if cache.len() != 100 {
+		t.Errorf("Expected cache capped at 100 entries, got %d", cache.len())
+	}
+	if len(cache.entries) != cache.len() {
+		t.Errorf("Expected index and order to agree, got %d and %d", len(cache.entries), cache.len())
+	}
+}
+
+func TestDedupCache_RemoveOlderThan(t *testing.T) {
+	cache := newDedupCache(10)
+	now := time.Now()
+
+	cache.set("old", now.Add(-time.Hour))
+	cache.set("new", now)
+	cache.set("older", now.Add(-2*time.Hour))
+
+	cache.removeOlderThan(now.Add(-time.Minute))
+
+	if cache.len() != 1 {
+		t.Fatalf("Expected 1 entry, got %d", cache.len())
+	}
+	if _, exists := cache.get("new"); !exists {
+		t.Error("Expected new entry to be kept")
+	}
+}
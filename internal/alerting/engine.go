@@ -1,19 +1,30 @@
 package alerting
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/tracing"
 	"github.com/google/uuid"
 )
 
 // StateStore interface for accessing server state
 type StateStore interface {
 	GetAllAgents() []*ServerState
+	GetAgent(agentName string) (*ServerState, bool)
 	CheckOfflineAgents(timeout time.Duration) []*ServerState
+	ExpireAgents(retention time.Duration) []*ServerState
 	AddAlert(alert *Alert)
+	IsSilenced(agentName, alertType string) bool
+	AlertOwner(agentName, alertType string) string
+	CustomMetrics() []CustomMetric
 }
 
 // ServerState represents an agent's state (simplified interface)
@@ -21,6 +32,8 @@ type ServerState struct {
 	AgentName     string
 	Status        string
 	LastSeen      time.Time
+	ClockSkew     time.Duration // Agent clock minus server clock
+	InMaintenance bool          // No checks run while set
 	SystemMetrics SystemMetrics
 	Containers    []ContainerState
 	ActiveAlerts  []Alert
@@ -31,6 +44,7 @@ type SystemMetrics struct {
 	CPU    CPUMetrics
 	Memory MemoryMetrics
 	Disk   []DiskMetrics
+	Docker *DockerMetrics // nil when the agent doesn't monitor Docker
 }
 
 // CPUMetrics holds CPU metrics
@@ -49,16 +63,74 @@ type DiskMetrics struct {
 	UsedPercent float64
 }
 
+// DockerMetrics holds Docker daemon metrics
+type DockerMetrics struct {
+	Goroutines          int
+	DataRoot            string
+	DataRootUsedPercent float64
+	Error               string
+}
+
 // ContainerState holds container state
 type ContainerState struct {
-	ID             string
-	Name           string
-	State          string
-	PreviousState  string
-	Health         string
-	CPUPercent     float64
-	MemoryPercent  float64
-	RestartCount   int
+	ID                  string
+	Name                string
+	Image               string
+	State               string
+	PreviousState       string
+	Health              string
+	HealthCheckExitCode int
+	HealthCheckOutput   string
+	CPUPercent          float64
+	MemoryPercent       float64
+	RestartCount        int
+	UpdateAvailable     bool
+	Remediation         *Remediation // Last remediation performed by the agent
+
+	// How the container last exited
+	ExitCode   int
+	OOMKilled  bool
+	FinishedAt time.Time
+	StateError string
+
+	OOMKillsLastHour int
+
+	// Per-container thresholds from labels (0 = use the engine default)
+	CPUThreshold    float64
+	MemoryThreshold float64
+}
+
+// Remediation holds an automatic action the agent took on a container
+type Remediation struct {
+	Action      string
+	Attempt     int
+	MaxAttempts int
+	Success     bool
+	Error       string
+	Timestamp   time.Time
+}
+
+// Details returns the remediation in alert details format
+func (r *Remediation) Details() map[string]interface{} {
+	details := map[string]interface{}{
+		"action":       r.Action,
+		"attempt":      r.Attempt,
+		"max_attempts": r.MaxAttempts,
+		"success":      r.Success,
+		"timestamp":    r.Timestamp,
+	}
+	if r.Error != "" {
+		details["error"] = r.Error
+	}
+	return details
+}
+
+// Summary returns a short human-readable description of the remediation
+func (r *Remediation) Summary() string {
+	if r.Success {
+		return fmt.Sprintf("%s succeeded (attempt %d/%d)", r.Action, r.Attempt, r.MaxAttempts)
+	}
+	return fmt.Sprintf("%s failed (attempt %d/%d): %s", r.Action, r.Attempt, r.MaxAttempts, r.Error)
 }
 
 // Alert represents an alert
@@ -73,8 +145,16 @@ type Alert struct {
 	ResolvedAt  *time.Time
 	Status      string
 	NotifiedAt  *time.Time
+
+	// Annotations such as runbook_url, team and priority, from the alert's
+	// rule or configured for its type
+	Annotations map[string]string
 }
 
+// RunbookURLAnnotation is the annotation notifications link to as the
+// alert's runbook
+const RunbookURLAnnotation = "runbook_url"
+
 // Config holds alerting configuration
 type Config struct {
 	Enabled               bool
@@ -85,80 +165,286 @@ type Config struct {
 	SystemCPUThreshold    float64
 	SystemMemoryThreshold float64
 	SystemDiskThreshold   float64
+
+	// DockerGoroutineThreshold alerts when dockerd's goroutine count exceeds
+	// it, which usually indicates a leak or hung daemon (0 disables it)
+	DockerGoroutineThreshold int
+
+	// ImageUpdateDigestInterval controls how often the "image updates
+	// available" digest is sent (0 disables it)
+	ImageUpdateDigestInterval time.Duration
+
+	// ContainerOOMKillThreshold alerts when a container is OOM-killed more
+	// than this many times in an hour (0 disables it)
+	ContainerOOMKillThreshold int
+
+	// AgentRetention removes agents that have been offline this long, so
+	// decommissioned hosts don't linger in the store (0 keeps them forever)
+	AgentRetention time.Duration
+
+	// ClockSkewThreshold alerts when an agent's clock is off from the
+	// server's by more than this (0 disables it)
+	ClockSkewThreshold time.Duration
+
+	// Container CPU and memory percentages to alert above (0 = 90 and 95).
+	// Overrides whose name pattern matches a container replace them, the
+	// last match winning; thresholds from the container's labels beat both.
+	ContainerCPUThreshold       float64
+	ContainerMemoryThreshold    float64
+	ContainerThresholdOverrides []ContainerThresholdOverride
+
+	// Rules over the custom metrics applications push, checked every
+	// CheckInterval
+	Rules []Rule
+
+	// Annotations added to alerts by type, e.g. a runbook for system_cpu_high.
+	// A rule's own annotations take precedence.
+	Annotations map[string]map[string]string
+
+	// Location is the time zone times in alert messages are shown in (nil
+	// = UTC)
+	Location *time.Location
 }
 
+// ContainerThresholdOverride sets the thresholds of matching containers
+type ContainerThresholdOverride struct {
+	Name            string  // Container name or glob pattern
+	CPUThreshold    float64 // 0 keeps the default
+	MemoryThreshold float64 // 0 keeps the default
+}
+
+// Container thresholds used when the config leaves them unset
+const (
+	defaultContainerCPUThreshold    = 90.0
+	defaultContainerMemoryThreshold = 95.0
+)
+
 // Notifier interface for sending notifications
 type Notifier interface {
 	SendAlert(alert *Alert) error
 }
 
+// DedupStore remembers when alerts were last sent for several servers, so
+// one taking over from another doesn't send them again
+type DedupStore interface {
+	LastSent(alertKey string) (time.Time, bool, error)
+	MarkSent(alertKey string, at time.Time, ttl time.Duration) error
+}
+
 // Engine handles alert detection and management
 type Engine struct {
+	logger       *slog.Logger
 	state        StateStore
-	config       *Config
+	config       atomic.Pointer[Config] // See Reconfigure
+	configMu     sync.Mutex             // Serializes Reconfigure
 	notifier     Notifier
 	mu           sync.RWMutex
-	recentAlerts map[string]time.Time // For deduplication: alertKey -> lastSent
+	recentAlerts *dedupCache // For deduplication: alertKey -> lastSent
+
+	lastImageDigest time.Time // When the image update digest was last sent
+
+	// Agents with new metrics waiting to be evaluated, see Trigger
+	pendingMu sync.Mutex
+	pending   map[string]struct{}
+	triggered chan struct{}
+
+	isLeader func() bool // Nil when the server runs alone, see SetLeadership
+	shared   DedupStore  // Nil unless servers share deduplication, see SetDedupStore
+
+	// Alerts dry-run rules would have raised, oldest first
+	dryRunMu sync.Mutex
+	dryRuns  []Alert
 }
 
+// triggerDebounce is how long the engine gathers metrics pushes before
+// evaluating them, so a burst of pushes costs one pass per agent
+const triggerDebounce = 500 * time.Millisecond
+
 // NewEngine creates a new alert detection engine
 func NewEngine(state StateStore, config *Config, notifier Notifier) *Engine {
-	return &Engine{
+	e := &Engine{
+		logger:       logging.Component("alerting"),
 		state:        state,
-		config:       config,
 		notifier:     notifier,
-		recentAlerts: make(map[string]time.Time),
+		recentAlerts: newDedupCache(maxDedupEntries),
+		// First digest goes out one full interval after startup
+		lastImageDigest: time.Now(),
+		pending:         make(map[string]struct{}),
+		triggered:       make(chan struct{}, 1),
+	}
+	e.config.Store(config)
+	return e
+}
+
+// cfg returns the current configuration, which must not be modified
+func (e *Engine) cfg() *Config {
+	return e.config.Load()
+}
+
+// Config returns a copy of the engine's current configuration
+func (e *Engine) Config() Config {
+	return *e.cfg()
+}
+
+// Reconfigure changes the configuration of a running engine, such as its
+// thresholds during an incident. update is given a copy of the current
+// configuration to change; checks already underway finish with the old one.
+// The check interval only takes effect on the next Start.
+func (e *Engine) Reconfigure(update func(*Config)) {
+	e.configMu.Lock()
+	defer e.configMu.Unlock()
+
+	config := *e.cfg()
+	update(&config)
+	e.config.Store(&config)
+}
+
+// Trigger schedules an agent's metrics for evaluation, typically right after
+// it pushes them. Evaluations are debounced; Trigger never blocks.
+func (e *Engine) Trigger(agentName string) {
+	if !e.cfg().Enabled {
+		return
 	}
+
+	e.pendingMu.Lock()
+	e.pending[agentName] = struct{}{}
+	e.pendingMu.Unlock()
+
+	select {
+	case e.triggered <- struct{}{}:
+	default:
+		// An evaluation is already scheduled and will pick this agent up
+	}
+}
+
+// SetLeadership makes the engine check alerts only while isLeader reports
+// true, so of several redundant servers only the elected one notifies.
+// Call it before Start.
+func (e *Engine) SetLeadership(isLeader func() bool) {
+	e.isLeader = isLeader
+}
+
+// SetDedupStore makes the engine deduplicate alerts with other servers
+// through store, falling back to its own memory while the store fails.
+// Call it before Start.
+func (e *Engine) SetDedupStore(store DedupStore) {
+	e.shared = store
+}
+
+// leading reports whether this server is the one to check alerts
+func (e *Engine) leading() bool {
+	return e.isLeader == nil || e.isLeader()
 }
 
 // Start begins the alert detection loop
 func (e *Engine) Start() {
-	if !e.config.Enabled {
-		log.Println("Alert engine disabled")
+	if !e.cfg().Enabled {
+		e.logger.Info("Alert engine disabled")
 		return
 	}
 
 	// Validate check interval to prevent panic in time.NewTicker
-	checkInterval := e.config.CheckInterval
+	checkInterval := e.cfg().CheckInterval
 	if checkInterval <= 0 {
-		log.Printf("Warning: Invalid check interval (%v), using default 30s", checkInterval)
+		e.logger.Warn("Invalid check interval, using default 30s", "check_interval", checkInterval)
 		checkInterval = 30 * time.Second
-		e.config.CheckInterval = checkInterval
+		e.Reconfigure(func(c *Config) { c.CheckInterval = checkInterval })
 	}
 
-	log.Printf("Starting alert engine (check interval: %v)", checkInterval)
+	e.logger.Info("Starting alert engine", "check_interval", checkInterval)
 
 	ticker := time.NewTicker(checkInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		e.checkAlerts()
+	// Metrics are evaluated as they arrive, see Trigger; the ticker catches
+	// what arrives by absence: offline agents
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ticker.C:
+			_, span := tracing.Start(context.Background(), "alerting.check", tracing.KindInternal)
+			e.checkAlerts()
+			span.End()
+		case <-e.triggered:
+			if debounce == nil {
+				debounce = time.After(triggerDebounce)
+			}
+		case <-debounce:
+			debounce = nil
+			_, span := tracing.Start(context.Background(), "alerting.evaluate", tracing.KindInternal)
+			span.SetAttr("agents", e.evaluatePending())
+			span.End()
+		}
 	}
 }
 
-// checkAlerts performs all alert checks
+// checkAlerts performs the periodic checks, unless another server leads
 func (e *Engine) checkAlerts() {
+	if !e.leading() {
+		return
+	}
+
 	// Check for offline agents
 	e.checkOfflineAgents()
 
-	// Check system and container metrics for all agents
+	// Forget agents that have been gone for longer than the retention
+	e.expireAgents()
+
 	agents := e.state.GetAllAgents()
-	for _, agent := range agents {
-		if agent.Status == "online" {
-			e.checkSystemAlerts(agent)
-			e.checkContainerAlerts(agent)
-		}
-	}
+
+	// Clock skew is measured on heartbeats, not metrics pushes
+	e.checkClockSkew(agents)
+
+	// Periodic digest of containers running outdated images
+	e.checkImageUpdateDigest(agents)
+
+	// Custom metrics are pushed by applications, not agents
+	e.checkRules()
 
 	// Cleanup old deduplication entries
 	e.cleanupDeduplication()
 }
 
+// evaluatePending checks the metrics of agents passed to Trigger since the
+// last evaluation, returning how many it checked. A follower drops them.
+func (e *Engine) evaluatePending() int {
+	e.pendingMu.Lock()
+	pending := e.pending
+	e.pending = make(map[string]struct{})
+	e.pendingMu.Unlock()
+
+	if !e.leading() {
+		return 0
+	}
+
+	for agentName := range pending {
+		e.evaluateAgent(agentName)
+	}
+	return len(pending)
+}
+
+// evaluateAgent checks an agent's system and container metrics
+func (e *Engine) evaluateAgent(agentName string) {
+	agent, exists := e.state.GetAgent(agentName)
+	// Degraded agents still push metrics, so still alert on them
+	if !exists || agent.InMaintenance || (agent.Status != "online" && agent.Status != "degraded") {
+		return
+	}
+
+	e.checkSystemAlerts(agent)
+	e.checkDockerAlerts(agent)
+	e.checkContainerAlerts(agent)
+}
+
 // checkOfflineAgents checks for agents that haven't sent heartbeat
 func (e *Engine) checkOfflineAgents() {
-	offline := e.state.CheckOfflineAgents(e.config.HeartbeatTimeout)
+	offline := e.state.CheckOfflineAgents(e.cfg().HeartbeatTimeout)
 
 	for _, agent := range offline {
+		if agent.InMaintenance {
+			e.logger.Info("Agent went offline during maintenance, not alerting", "agent", agent.AgentName)
+			continue
+		}
 		alertKey := fmt.Sprintf("agent_offline:%s", agent.AgentName)
 		if e.shouldSendAlert(alertKey) {
 			alert := &Alert{
@@ -166,7 +452,7 @@ func (e *Engine) checkOfflineAgents() {
 				AgentName:   agent.AgentName,
 				AlertType:   "agent_offline",
 				Severity:    "critical",
-				Message:     fmt.Sprintf("🔴 Agent Offline\nAgent: %s\nLast Seen: %s", agent.AgentName, agent.LastSeen.Format(time.RFC3339)),
+				Message:     fmt.Sprintf("🔴 Agent Offline\nAgent: %s\nLast Seen: %s (%s)", agent.AgentName, formatTime(agent.LastSeen, e.cfg().Location), Ago(agent.LastSeen, time.Now())),
 				Details: map[string]interface{}{
 					"agent_name": agent.AgentName,
 					"last_seen":  agent.LastSeen,
@@ -174,15 +460,76 @@ func (e *Engine) checkOfflineAgents() {
 				TriggeredAt: time.Now(),
 				Status:      "active",
 			}
+			e.sendAlert(alert, alertKey)
+		}
+	}
+}
+
+// expireAgents removes long-offline agents and records an info-level alert
+// for each, already resolved since there is nothing left to act on
+func (e *Engine) expireAgents() {
+	if e.cfg().AgentRetention <= 0 {
+		return
+	}
+
+	for _, agent := range e.state.ExpireAgents(e.cfg().AgentRetention) {
+		now := time.Now()
+		alert := &Alert{
+			ID:        uuid.New().String(),
+			AgentName: agent.AgentName,
+			AlertType: "agent_expired",
+			Severity:  "info",
+			Message:   fmt.Sprintf("🗑️ Agent Removed\nAgent: %s\nLast Seen: %s (%s)", agent.AgentName, formatTime(agent.LastSeen, e.cfg().Location), Ago(agent.LastSeen, now)),
+			Details: map[string]interface{}{
+				"agent_name": agent.AgentName,
+				"last_seen":  agent.LastSeen,
+				"retention":  e.cfg().AgentRetention.String(),
+			},
+			TriggeredAt: now,
+			ResolvedAt:  &now,
+			Status:      "resolved",
+		}
+		e.logger.Info("Removing agent", "agent", agent.AgentName, "offline_since", agent.LastSeen)
+		e.sendAlert(alert, fmt.Sprintf("agent_expired:%s", agent.AgentName))
+	}
+}
+
+// checkClockSkew warns about agents whose clocks drifted from the server's,
+// which shifts their metric timestamps
+func (e *Engine) checkClockSkew(agents []*ServerState) {
+	if e.cfg().ClockSkewThreshold <= 0 {
+		return
+	}
+
+	for _, agent := range agents {
+		if agent.InMaintenance || (agent.Status != "online" && agent.Status != "degraded") {
+			continue
+		}
+		skew := agent.ClockSkew
+		if skew.Abs() <= e.cfg().ClockSkewThreshold {
+			continue
+		}
 
-			e.state.AddAlert(alert)
-			if err := e.notifier.SendAlert(alert); err != nil {
-				log.Printf("Failed to send alert: %v", err)
-			} else {
-				now := time.Now()
-				alert.NotifiedAt = &now
-				e.markAlertSent(alertKey)
+		alertKey := fmt.Sprintf("clock_skew:%s", agent.AgentName)
+		if e.shouldSendAlert(alertKey) {
+			direction := "ahead of"
+			if skew < 0 {
+				direction = "behind"
+			}
+			alert := &Alert{
+				ID:        uuid.New().String(),
+				AgentName: agent.AgentName,
+				AlertType: "agent_clock_skew",
+				Severity:  "warning",
+				Message:   fmt.Sprintf("🕐 Clock Skew\nAgent: %s\nAgent clock is %s %s the server", agent.AgentName, skew.Abs().Round(time.Second), direction),
+				Details: map[string]interface{}{
+					"agent_name":         agent.AgentName,
+					"clock_skew_seconds": skew.Seconds(),
+				},
+				TriggeredAt: time.Now(),
+				Status:      "active",
 			}
+			e.sendAlert(alert, alertKey)
 		}
 	}
 }
@@ -190,7 +537,7 @@ func (e *Engine) checkOfflineAgents() {
 // checkSystemAlerts checks system-level thresholds
 func (e *Engine) checkSystemAlerts(agent *ServerState) {
 	// CPU alert
-	if e.config.SystemCPUThreshold > 0 && agent.SystemMetrics.CPU.UsagePercent > e.config.SystemCPUThreshold {
+	if e.cfg().SystemCPUThreshold > 0 && agent.SystemMetrics.CPU.UsagePercent > e.cfg().SystemCPUThreshold {
 		alertKey := fmt.Sprintf("system_cpu:%s", agent.AgentName)
 		if e.shouldSendAlert(alertKey) {
 			alert := &Alert{
@@ -211,7 +558,7 @@ func (e *Engine) checkSystemAlerts(agent *ServerState) {
 	}
 
 	// Memory alert
-	if e.config.SystemMemoryThreshold > 0 && agent.SystemMetrics.Memory.UsedPercent > e.config.SystemMemoryThreshold {
+	if e.cfg().SystemMemoryThreshold > 0 && agent.SystemMetrics.Memory.UsedPercent > e.cfg().SystemMemoryThreshold {
 		alertKey := fmt.Sprintf("system_memory:%s", agent.AgentName)
 		if e.shouldSendAlert(alertKey) {
 			alert := &Alert{
@@ -233,7 +580,7 @@ func (e *Engine) checkSystemAlerts(agent *ServerState) {
 
 	// Disk alert
 	for _, disk := range agent.SystemMetrics.Disk {
-		if e.config.SystemDiskThreshold > 0 && disk.UsedPercent > e.config.SystemDiskThreshold {
+		if e.cfg().SystemDiskThreshold > 0 && disk.UsedPercent > e.cfg().SystemDiskThreshold {
 			alertKey := fmt.Sprintf("system_disk:%s:%s", agent.AgentName, disk.MountPoint)
 			if e.shouldSendAlert(alertKey) {
 				alert := &Alert{
@@ -256,6 +603,111 @@ func (e *Engine) checkSystemAlerts(agent *ServerState) {
 	}
 }
 
+// checkDockerAlerts checks the health of the Docker daemon itself
+func (e *Engine) checkDockerAlerts(agent *ServerState) {
+	docker := agent.SystemMetrics.Docker
+	if docker == nil {
+		return
+	}
+
+	// Daemon unreachable
+	if docker.Error != "" {
+		alertKey := fmt.Sprintf("docker_daemon_unreachable:%s", agent.AgentName)
+		if e.shouldSendAlert(alertKey) {
+			alert := &Alert{
+				ID:        uuid.New().String(),
+				AgentName: agent.AgentName,
+				AlertType: "docker_daemon_unreachable",
+				Severity:  "critical",
+				Message:   fmt.Sprintf("🐳 Docker Daemon Unreachable\nAgent: %s\nError: %s", agent.AgentName, docker.Error),
+				Details: map[string]interface{}{
+					"agent_name": agent.AgentName,
+					"error":      docker.Error,
+				},
+				TriggeredAt: time.Now(),
+				Status:      "active",
+			}
+			e.sendAlert(alert, alertKey)
+		}
+		return
+	}
+
+	// Goroutine leak
+	if e.cfg().DockerGoroutineThreshold > 0 && docker.Goroutines > e.cfg().DockerGoroutineThreshold {
+		alertKey := fmt.Sprintf("docker_goroutines:%s", agent.AgentName)
+		if e.shouldSendAlert(alertKey) {
+			alert := &Alert{
+				ID:        uuid.New().String(),
+				AgentName: agent.AgentName,
+				AlertType: "docker_goroutines_high",
+				Severity:  "warning",
+				Message:   fmt.Sprintf("⚠️ Docker Daemon Goroutines High\nAgent: %s\nGoroutines: %d", agent.AgentName, docker.Goroutines),
+				Details: map[string]interface{}{
+					"agent_name": agent.AgentName,
+					"goroutines": docker.Goroutines,
+				},
+				TriggeredAt: time.Now(),
+				Status:      "active",
+			}
+			e.sendAlert(alert, alertKey)
+		}
+	}
+
+	// Data root disk usage
+	if e.cfg().SystemDiskThreshold > 0 && docker.DataRootUsedPercent > e.cfg().SystemDiskThreshold {
+		alertKey := fmt.Sprintf("docker_data_root:%s", agent.AgentName)
+		if e.shouldSendAlert(alertKey) {
+			alert := &Alert{
+				ID:        uuid.New().String(),
+				AgentName: agent.AgentName,
+				AlertType: "docker_data_root_high",
+				Severity:  "critical",
+				Message:   fmt.Sprintf("🚨 Docker Data Root Usage High\nAgent: %s\nPath: %s\nUsage: %.1f%%", agent.AgentName, docker.DataRoot, docker.DataRootUsedPercent),
+				Details: map[string]interface{}{
+					"agent_name":   agent.AgentName,
+					"data_root":    docker.DataRoot,
+					"disk_percent": docker.DataRootUsedPercent,
+				},
+				TriggeredAt: time.Now(),
+				Status:      "active",
+			}
+			e.sendAlert(alert, alertKey)
+		}
+	}
+}
+
+// containerThresholds returns the CPU and memory percentages a container
+// alerts above
+func (e *Engine) containerThresholds(container ContainerState) (cpu, memory float64) {
+	cpu, memory = e.cfg().ContainerCPUThreshold, e.cfg().ContainerMemoryThreshold
+	if cpu <= 0 {
+		cpu = defaultContainerCPUThreshold
+	}
+	if memory <= 0 {
+		memory = defaultContainerMemoryThreshold
+	}
+
+	for _, o := range e.cfg().ContainerThresholdOverrides {
+		if matched, _ := filepath.Match(o.Name, container.Name); !matched {
+			continue
+		}
+		if o.CPUThreshold > 0 {
+			cpu = o.CPUThreshold
+		}
+		if o.MemoryThreshold > 0 {
+			memory = o.MemoryThreshold
+		}
+	}
+
+	if container.CPUThreshold > 0 {
+		cpu = container.CPUThreshold
+	}
+	if container.MemoryThreshold > 0 {
+		memory = container.MemoryThreshold
+	}
+	return cpu, memory
+}
+
 // checkContainerAlerts checks container-specific alerts
 func (e *Engine) checkContainerAlerts(agent *ServerState) {
 	for _, container := range agent.Containers {
@@ -268,13 +720,50 @@ func (e *Engine) checkContainerAlerts(agent *ServerState) {
 					AgentName: agent.AgentName,
 					AlertType: "container_stopped",
 					Severity:  "critical",
-					Message:   fmt.Sprintf("💀 Container Stopped\nAgent: %s\nContainer: %s\nState: %s", agent.AgentName, container.Name, container.State),
+					Message:   fmt.Sprintf("💀 Container Stopped\nAgent: %s\nContainer: %s\nState: %s", agent.AgentName, container.Name, exitReason(container, e.cfg().Location)),
 					Details: map[string]interface{}{
 						"agent_name":     agent.AgentName,
 						"container_id":   container.ID,
 						"container_name": container.Name,
 						"state":          container.State,
 						"previous_state": container.PreviousState,
+						"exit_code":      container.ExitCode,
+						"oom_killed":     container.OOMKilled,
+					},
+					TriggeredAt: time.Now(),
+					Status:      "active",
+				}
+				if !container.FinishedAt.IsZero() {
+					alert.Details["finished_at"] = container.FinishedAt
+				}
+				if container.StateError != "" {
+					alert.Details["error"] = container.StateError
+				}
+				if container.Remediation != nil {
+					alert.Message += fmt.Sprintf("\nRemediation: %s", container.Remediation.Summary())
+					alert.Details["remediation"] = container.Remediation.Details()
+				}
+				e.sendAlert(alert, alertKey)
+			}
+		}
+
+		// Repeated OOM kills, often hidden by a restart policy
+		if e.cfg().ContainerOOMKillThreshold > 0 && container.OOMKillsLastHour > e.cfg().ContainerOOMKillThreshold {
+			alertKey := fmt.Sprintf("container_oom_kills:%s:%s", agent.AgentName, container.ID)
+			if e.shouldSendAlert(alertKey) {
+				alert := &Alert{
+					ID:        uuid.New().String(),
+					AgentName: agent.AgentName,
+					AlertType: "container_oom_kills",
+					Severity:  "critical",
+					Message:   fmt.Sprintf("💥 Container Repeatedly OOM-Killed\nAgent: %s\nContainer: %s\nOOM Kills (last hour): %d\nRestarts: %d", agent.AgentName, container.Name, container.OOMKillsLastHour, container.RestartCount),
+					Details: map[string]interface{}{
+						"agent_name":          agent.AgentName,
+						"container_id":        container.ID,
+						"container_name":      container.Name,
+						"oom_kills_last_hour": container.OOMKillsLastHour,
+						"threshold":           e.cfg().ContainerOOMKillThreshold,
+						"restart_count":       container.RestartCount,
 					},
 					TriggeredAt: time.Now(),
 					Status:      "active",
@@ -287,17 +776,24 @@ func (e *Engine) checkContainerAlerts(agent *ServerState) {
 		if container.Health == "unhealthy" {
 			alertKey := fmt.Sprintf("container_unhealthy:%s:%s", agent.AgentName, container.ID)
 			if e.shouldSendAlert(alertKey) {
+				message := fmt.Sprintf("🏥 Container Unhealthy\nAgent: %s\nContainer: %s", agent.AgentName, container.Name)
+				if container.HealthCheckOutput != "" {
+					message += fmt.Sprintf("\nHealth Check (exit %d): %s", container.HealthCheckExitCode, container.HealthCheckOutput)
+				}
+
 				alert := &Alert{
 					ID:        uuid.New().String(),
 					AgentName: agent.AgentName,
 					AlertType: "container_unhealthy",
 					Severity:  "warning",
-					Message:   fmt.Sprintf("🏥 Container Unhealthy\nAgent: %s\nContainer: %s", agent.AgentName, container.Name),
+					Message:   message,
 					Details: map[string]interface{}{
-						"agent_name":     agent.AgentName,
-						"container_id":   container.ID,
-						"container_name": container.Name,
-						"health":         container.Health,
+						"agent_name":             agent.AgentName,
+						"container_id":           container.ID,
+						"container_name":         container.Name,
+						"health":                 container.Health,
+						"health_check_exit_code": container.HealthCheckExitCode,
+						"health_check_output":    container.HealthCheckOutput,
 					},
 					TriggeredAt: time.Now(),
 					Status:      "active",
@@ -307,7 +803,8 @@ func (e *Engine) checkContainerAlerts(agent *ServerState) {
 		}
 
 		// Container high CPU
-		if container.CPUPercent > 90.0 {
+		cpuThreshold, memoryThreshold := e.containerThresholds(container)
+		if container.CPUPercent > cpuThreshold {
 			alertKey := fmt.Sprintf("container_cpu:%s:%s", agent.AgentName, container.ID)
 			if e.shouldSendAlert(alertKey) {
 				alert := &Alert{
@@ -321,6 +818,7 @@ func (e *Engine) checkContainerAlerts(agent *ServerState) {
 						"container_id":   container.ID,
 						"container_name": container.Name,
 						"cpu_percent":    container.CPUPercent,
+						"threshold":      cpuThreshold,
 					},
 					TriggeredAt: time.Now(),
 					Status:      "active",
@@ -330,7 +828,7 @@ func (e *Engine) checkContainerAlerts(agent *ServerState) {
 		}
 
 		// Container high memory
-		if container.MemoryPercent > 95.0 {
+		if container.MemoryPercent > memoryThreshold {
 			alertKey := fmt.Sprintf("container_memory:%s:%s", agent.AgentName, container.ID)
 			if e.shouldSendAlert(alertKey) {
 				alert := &Alert{
@@ -344,6 +842,7 @@ func (e *Engine) checkContainerAlerts(agent *ServerState) {
 						"container_id":   container.ID,
 						"container_name": container.Name,
 						"memory_percent": container.MemoryPercent,
+						"threshold":      memoryThreshold,
 					},
 					TriggeredAt: time.Now(),
 					Status:      "active",
@@ -354,41 +853,144 @@ func (e *Engine) checkContainerAlerts(agent *ServerState) {
 	}
 }
 
+// checkImageUpdateDigest sends one info-level alert per agent listing the
+// containers whose registry image has changed since they were pulled. It's
+// a digest rather than an incident, so it's recorded already resolved.
+func (e *Engine) checkImageUpdateDigest(agents []*ServerState) {
+	if e.cfg().ImageUpdateDigestInterval <= 0 || time.Since(e.lastImageDigest) < e.cfg().ImageUpdateDigestInterval {
+		return
+	}
+	e.lastImageDigest = time.Now()
+
+	for _, agent := range agents {
+		if agent.InMaintenance {
+			continue
+		}
+		var lines []string
+		var names []string
+		for _, container := range agent.Containers {
+			if container.UpdateAvailable {
+				lines = append(lines, fmt.Sprintf("• %s (%s)", container.Name, container.Image))
+				names = append(names, container.Name)
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+
+		alertKey := fmt.Sprintf("image_update_available:%s", agent.AgentName)
+		now := time.Now()
+		alert := &Alert{
+			ID:        uuid.New().String(),
+			AgentName: agent.AgentName,
+			AlertType: "image_update_available",
+			Severity:  "info",
+			Message:   fmt.Sprintf("📦 Image Updates Available\nAgent: %s\n%s", agent.AgentName, strings.Join(lines, "\n")),
+			Details: map[string]interface{}{
+				"agent_name": agent.AgentName,
+				"containers": names,
+			},
+			TriggeredAt: now,
+			ResolvedAt:  &now,
+			Status:      "resolved",
+		}
+		e.sendAlert(alert, alertKey)
+	}
+}
+
 // shouldSendAlert checks if alert should be sent based on deduplication
 func (e *Engine) shouldSendAlert(alertKey string) bool {
-	if !e.config.DeduplicationEnabled {
+	if !e.cfg().DeduplicationEnabled {
 		return true
 	}
 
 	e.mu.RLock()
-	lastSent, exists := e.recentAlerts[alertKey]
+	lastSent, exists := e.recentAlerts.get(alertKey)
 	e.mu.RUnlock()
 
+	if e.shared != nil {
+		if sharedLastSent, sharedExists, err := e.shared.LastSent(alertKey); err != nil {
+			e.logger.Debug("Shared deduplication unavailable, using local", "alert_key", alertKey, logging.Err(err))
+		} else {
+			lastSent, exists = sharedLastSent, sharedExists
+		}
+	}
+
 	if !exists {
 		return true
 	}
 
-	return time.Since(lastSent) > e.config.DeduplicationWindow
+	return time.Since(lastSent) > e.cfg().DeduplicationWindow
 }
 
 // markAlertSent marks an alert as sent for deduplication
 func (e *Engine) markAlertSent(alertKey string) {
+	now := time.Now()
 	e.mu.Lock()
-	defer e.mu.Unlock()
-	e.recentAlerts[alertKey] = time.Now()
+	e.recentAlerts.set(alertKey, now)
+	e.mu.Unlock()
+
+	if e.shared != nil && e.cfg().DeduplicationEnabled {
+		if err := e.shared.MarkSent(alertKey, now, e.cfg().DeduplicationWindow*2); err != nil {
+			e.logger.Debug("Failed to share deduplication", "alert_key", alertKey, logging.Err(err))
+		}
+	}
 }
 
-// sendAlert sends an alert and updates state
-func (e *Engine) sendAlert(alert *Alert, alertKey string) {
+// sendAlert sends an alert and updates state. Silenced alerts are recorded
+// but not notified. A condition that fires again while someone owns the
+// earlier alert is left to them rather than raised again. Returns whether
+// the alert was recorded.
+func (e *Engine) sendAlert(alert *Alert, alertKey string) bool {
+	e.annotate(alert)
+	if alert.Status == "active" {
+		if owner := e.state.AlertOwner(alert.AgentName, alert.AlertType); owner != "" {
+			e.markAlertSent(alertKey)
+			e.logger.Info("Alert already owned", "owner", owner, "alert_type", alert.AlertType, "agent", alert.AgentName)
+			return false
+		}
+	}
 	e.state.AddAlert(alert)
-	if err := e.notifier.SendAlert(alert); err != nil {
-		log.Printf("Failed to send alert: %v", err)
+	if e.state.IsSilenced(alert.AgentName, alert.AlertType) {
+		// Still deduplicated, so a silenced condition isn't re-recorded every check
+		e.markAlertSent(alertKey)
+		e.logger.Info("Alert silenced", "alert_id", alert.ID, "alert_type", alert.AlertType, "agent", alert.AgentName)
+		return true
+	}
+	_, span := tracing.Start(context.Background(), "alerting.notify", tracing.KindClient)
+	span.SetAttr("alert.id", alert.ID)
+	span.SetAttr("alert.type", alert.AlertType)
+	span.SetAttr("alert.severity", alert.Severity)
+	span.SetAttr("agent.name", alert.AgentName)
+	err := e.notifier.SendAlert(alert)
+	span.SetError(err)
+	span.End()
+	if err != nil {
+		e.logger.Error("Failed to send alert", "alert_id", alert.ID, "alert_type", alert.AlertType, "agent", alert.AgentName, logging.Err(err))
 	} else {
 		now := time.Now()
 		alert.NotifiedAt = &now
 		e.markAlertSent(alertKey)
-		log.Printf("Alert sent: %s - %s", alert.AlertType, alert.AgentName)
+		e.logger.Info("Alert sent", "alert_id", alert.ID, "alert_type", alert.AlertType, "agent", alert.AgentName)
+	}
+	return true
+}
+
+// annotate adds the annotations configured for the alert's type, keeping
+// those the alert already carries
+func (e *Engine) annotate(alert *Alert) {
+	configured := e.cfg().Annotations[alert.AlertType]
+	if len(configured) == 0 {
+		return
 	}
+	annotations := make(map[string]string, len(configured)+len(alert.Annotations))
+	for k, v := range configured {
+		annotations[k] = v
+	}
+	for k, v := range alert.Annotations {
+		annotations[k] = v
+	}
+	alert.Annotations = annotations
 }
 
 // cleanupDeduplication removes old deduplication entries
@@ -396,10 +998,24 @@ func (e *Engine) cleanupDeduplication() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	now := time.Now()
-	for key, lastSent := range e.recentAlerts {
-		if now.Sub(lastSent) > e.config.DeduplicationWindow*2 {
-			delete(e.recentAlerts, key)
+	e.recentAlerts.removeOlderThan(time.Now().Add(-e.cfg().DeduplicationWindow * 2))
+}
+
+// exitReason describes how a container stopped, e.g. "exited 137 (OOMKilled) at 12:03:04 UTC",
+// with the time shown in loc
+func exitReason(container ContainerState, loc *time.Location) string {
+	reason := fmt.Sprintf("%s %d", container.State, container.ExitCode)
+	if container.OOMKilled {
+		reason += " (OOMKilled)"
+	}
+	if !container.FinishedAt.IsZero() {
+		if loc == nil {
+			loc = time.UTC
 		}
+		reason += " at " + container.FinishedAt.In(loc).Format("15:04:05 MST")
+	}
+	if container.StateError != "" {
+		reason += ": " + container.StateError
 	}
+	return reason
 }
@@ -2,6 +2,8 @@ package alerting
 
 import (
 	"errors"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -10,7 +12,11 @@ import (
 type MockStateStore struct {
 	agents        []*ServerState
 	offlineAgents []*ServerState
+	expiredAgents []*ServerState
 	alerts        []*Alert
+	silenced      map[string]bool   // key: agent_name:alert_type
+	owners        map[string]string // key: agent_name:alert_type
+	customMetrics []CustomMetric
 }
 
 func NewMockStateStore() *MockStateStore {
@@ -18,6 +24,8 @@ func NewMockStateStore() *MockStateStore {
 		agents:        make([]*ServerState, 0),
 		offlineAgents: make([]*ServerState, 0),
 		alerts:        make([]*Alert, 0),
+		silenced:      make(map[string]bool),
+		owners:        make(map[string]string),
 	}
 }
 
@@ -25,14 +33,41 @@ func (m *MockStateStore) GetAllAgents() []*ServerState {
 	return m.agents
 }
 
+func (m *MockStateStore) GetAgent(agentName string) (*ServerState, bool) {
+	for _, agent := range m.agents {
+		if agent.AgentName == agentName {
+			return agent, true
+		}
+	}
+	return nil, false
+}
+
 func (m *MockStateStore) CheckOfflineAgents(timeout time.Duration) []*ServerState {
 	return m.offlineAgents
 }
 
+func (m *MockStateStore) ExpireAgents(retention time.Duration) []*ServerState {
+	expired := m.expiredAgents
+	m.expiredAgents = nil
+	return expired
+}
+
 func (m *MockStateStore) AddAlert(alert *Alert) {
 	m.alerts = append(m.alerts, alert)
 }
 
+func (m *MockStateStore) IsSilenced(agentName, alertType string) bool {
+	return m.silenced[agentName+":"+alertType]
+}
+
+func (m *MockStateStore) AlertOwner(agentName, alertType string) string {
+	return m.owners[agentName+":"+alertType]
+}
+
+func (m *MockStateStore) CustomMetrics() []CustomMetric {
+	return m.customMetrics
+}
+
 // MockNotifier implements Notifier interface for testing
 type MockNotifier struct {
 	sentAlerts []*Alert
@@ -71,7 +106,7 @@ func TestNewEngine(t *testing.T) {
 		t.Error("Engine state not set correctly")
 	}
 
-	if engine.config != config {
+	if engine.cfg() != config {
 		t.Error("Engine config not set correctly")
 	}
 
@@ -80,7 +115,152 @@ func TestNewEngine(t *testing.T) {
 	}
 
 	if engine.recentAlerts == nil {
-		t.Error("recentAlerts map not initialized")
+		t.Error("recentAlerts cache not initialized")
+	}
+}
+
+func TestExpireAgents(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:        true,
+		AgentRetention: 24 * time.Hour,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	state.expiredAgents = []*ServerState{
+		{AgentName: "old-agent", Status: "offline", LastSeen: time.Now().Add(-48 * time.Hour)},
+	}
+
+	engine.checkAlerts()
+
+	if len(state.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(state.alerts))
+	}
+
+	alert := state.alerts[0]
+	if alert.AlertType != "agent_expired" {
+		t.Errorf("Expected alert type 'agent_expired', got '%s'", alert.AlertType)
+	}
+	if alert.Severity != "info" {
+		t.Errorf("Expected severity 'info', got '%s'", alert.Severity)
+	}
+	if alert.Status != "resolved" || alert.ResolvedAt == nil {
+		t.Errorf("Expected the alert to be recorded as resolved, got status '%s'", alert.Status)
+	}
+	if len(notifier.sentAlerts) != 1 {
+		t.Errorf("Expected 1 notification, got %d", len(notifier.sentAlerts))
+	}
+}
+
+func TestExpireAgents_Disabled(t *testing.T) {
+	state := NewMockStateStore()
+	engine := NewEngine(state, &Config{Enabled: true}, NewMockNotifier())
+
+	state.expiredAgents = []*ServerState{{AgentName: "old-agent"}}
+
+	engine.expireAgents()
+
+	if len(state.expiredAgents) != 1 {
+		t.Error("Expected the store not to be asked to expire agents")
+	}
+	if len(state.alerts) != 0 {
+		t.Errorf("Expected no alerts, got %d", len(state.alerts))
+	}
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:            true,
+		ClockSkewThreshold: 30 * time.Second,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	agents := []*ServerState{
+		{AgentName: "in-sync", Status: "online", ClockSkew: 2 * time.Second},
+		{AgentName: "behind", Status: "online", ClockSkew: -2 * time.Minute},
+		{AgentName: "gone", Status: "offline", ClockSkew: time.Hour},
+	}
+
+	engine.checkClockSkew(agents)
+
+	if len(state.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(state.alerts))
+	}
+	alert := state.alerts[0]
+	if alert.AgentName != "behind" || alert.AlertType != "agent_clock_skew" {
+		t.Errorf("Expected agent_clock_skew for 'behind', got %s for '%s'", alert.AlertType, alert.AgentName)
+	}
+	if !strings.Contains(alert.Message, "2m0s behind") {
+		t.Errorf("Expected message to say how far behind, got %q", alert.Message)
+	}
+}
+
+func TestMaintenance_SkipsChecks(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:            true,
+		HeartbeatTimeout:   time.Minute,
+		SystemCPUThreshold: 80,
+		ClockSkewThreshold: 30 * time.Second,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	agent := &ServerState{
+		AgentName:     "db-1",
+		Status:        "online",
+		InMaintenance: true,
+		ClockSkew:     time.Hour,
+		SystemMetrics: SystemMetrics{CPU: CPUMetrics{UsagePercent: 99}},
+	}
+	state.agents = append(state.agents, agent)
+	state.offlineAgents = append(state.offlineAgents, &ServerState{AgentName: "db-2", Status: "offline", InMaintenance: true})
+
+	engine.evaluateAgent("db-1")
+	engine.checkAlerts()
+
+	if len(state.alerts) != 0 {
+		t.Errorf("Expected no alerts during maintenance, got %d (first: %s)", len(state.alerts), state.alerts[0].AlertType)
+	}
+}
+
+func TestLeadership_FollowerSkipsChecks(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	engine := NewEngine(state, &Config{Enabled: true, HeartbeatTimeout: time.Minute, SystemCPUThreshold: 80}, notifier)
+	leader := false
+	engine.SetLeadership(func() bool { return leader })
+
+	state.agents = append(state.agents, &ServerState{
+		AgentName:     "db-1",
+		Status:        "online",
+		SystemMetrics: SystemMetrics{CPU: CPUMetrics{UsagePercent: 99}},
+	})
+	state.offlineAgents = append(state.offlineAgents, &ServerState{AgentName: "db-2", Status: "offline"})
+
+	engine.Trigger("db-1")
+	if evaluated := engine.evaluatePending(); evaluated != 0 {
+		t.Errorf("Expected a follower to evaluate no agents, got %d", evaluated)
+	}
+	engine.checkAlerts()
+	if len(notifier.sentAlerts) != 0 {
+		t.Fatalf("Expected no notifications from a follower, got %d", len(notifier.sentAlerts))
+	}
+
+	// Metrics pushed while following are not evaluated on taking over
+	leader = true
+	if evaluated := engine.evaluatePending(); evaluated != 0 {
+		t.Errorf("Expected pending agents to be dropped, got %d evaluated", evaluated)
+	}
+	engine.checkAlerts()
+	if len(notifier.sentAlerts) != 1 || notifier.sentAlerts[0].AlertType != "agent_offline" {
+		t.Errorf("Expected the leader to alert on the offline agent, got %d notifications", len(notifier.sentAlerts))
 	}
 }
 
@@ -129,6 +309,27 @@ func TestCheckOfflineAgents(t *testing.T) {
 	}
 }
 
+func TestCheckOfflineAgents_Timezone(t *testing.T) {
+	state := NewMockStateStore()
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("Failed to load time zone: %v", err)
+	}
+	engine := NewEngine(state, &Config{Enabled: true, HeartbeatTimeout: time.Minute, Location: tokyo}, NewMockNotifier())
+
+	lastSeen := time.Now().Add(-5 * time.Minute)
+	state.offlineAgents = append(state.offlineAgents, &ServerState{AgentName: "offline-agent", Status: "offline", LastSeen: lastSeen})
+	engine.checkOfflineAgents()
+
+	if len(state.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(state.alerts))
+	}
+	want := "Last Seen: " + lastSeen.In(tokyo).Format(TimeLayout) + " (5m ago)"
+	if !strings.Contains(state.alerts[0].Message, want) {
+		t.Errorf("Expected %q in message, got %q", want, state.alerts[0].Message)
+	}
+}
+
 func TestCheckOfflineAgents_NotificationFailure(t *testing.T) {
 	state := NewMockStateStore()
 	notifier := NewMockNotifier()
@@ -161,13 +362,73 @@ func TestCheckOfflineAgents_NotificationFailure(t *testing.T) {
 	}
 }
 
-func TestCheckSystemAlerts_CPU(t *testing.T) {
+func TestCheckOfflineAgents_Silenced(t *testing.T) {
 	state := NewMockStateStore()
+	state.silenced["offline-agent:agent_offline"] = true
 	notifier := NewMockNotifier()
 	config := &Config{
 		Enabled:              true,
-		SystemCPUThreshold:   80.0,
-		DeduplicationEnabled: false,
+		HeartbeatTimeout:     1 * time.Minute,
+		DeduplicationEnabled: true,
+		DeduplicationWindow:  5 * time.Minute,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	state.offlineAgents = append(state.offlineAgents, &ServerState{
+		AgentName: "offline-agent",
+		Status:    "offline",
+		LastSeen:  time.Now().Add(-2 * time.Minute),
+	})
+
+	engine.checkOfflineAgents()
+	engine.checkOfflineAgents()
+
+	// Recorded once, then deduplicated like a sent alert
+	if len(state.alerts) != 1 {
+		t.Fatalf("Expected 1 alert in state, got %d", len(state.alerts))
+	}
+	if len(notifier.sentAlerts) != 0 {
+		t.Errorf("Expected no notifications for a silenced alert, got %d", len(notifier.sentAlerts))
+	}
+}
+
+func TestCheckOfflineAgents_Owned(t *testing.T) {
+	state := NewMockStateStore()
+	state.owners["offline-agent:agent_offline"] = "alice"
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:              true,
+		HeartbeatTimeout:     1 * time.Minute,
+		DeduplicationEnabled: true,
+		DeduplicationWindow:  5 * time.Minute,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	state.offlineAgents = append(state.offlineAgents, &ServerState{
+		AgentName: "offline-agent",
+		Status:    "offline",
+		LastSeen:  time.Now().Add(-2 * time.Minute),
+	})
+
+	engine.checkOfflineAgents()
+
+	if len(state.alerts) != 0 {
+		t.Errorf("Expected no new alert while the earlier one is owned, got %d", len(state.alerts))
+	}
+	if len(notifier.sentAlerts) != 0 {
+		t.Errorf("Expected no notifications while the earlier alert is owned, got %d", len(notifier.sentAlerts))
+	}
+}
+
+func TestCheckSystemAlerts_CPU(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:                 true,
+		SystemCPUThreshold:      80.0,
+		DeduplicationEnabled:    false,
 	}
 
 	engine := NewEngine(state, config, notifier)
@@ -342,22 +603,282 @@ func TestCheckSystemAlerts_BelowThreshold(t *testing.T) {
 		},
 	}
 
-	engine.checkSystemAlerts(agent)
+	engine.checkSystemAlerts(agent)
+
+	if len(state.alerts) != 0 {
+		t.Errorf("Expected 0 alerts when below threshold, got %d", len(state.alerts))
+	}
+}
+
+func TestCheckSystemAlerts_ThresholdsDisabled(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:                 true,
+		SystemCPUThreshold:      0, // Disabled
+		SystemMemoryThreshold:   0, // Disabled
+		SystemDiskThreshold:     0, // Disabled
+		DeduplicationEnabled:    false,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	agent := &ServerState{
+		AgentName: "test-agent",
+		Status:    "online",
+		SystemMetrics: SystemMetrics{
+			CPU: CPUMetrics{
+				UsagePercent: 95.0,
+			},
+			Memory: MemoryMetrics{
+				UsedPercent: 95.0,
+			},
+			Disk: []DiskMetrics{
+				{
+					MountPoint:  "/",
+					UsedPercent: 95.0,
+				},
+			},
+		},
+	}
+
+	engine.checkSystemAlerts(agent)
+
+	if len(state.alerts) != 0 {
+		t.Errorf("Expected 0 alerts when thresholds are disabled, got %d", len(state.alerts))
+	}
+}
+
+func TestCheckDockerAlerts_DaemonUnreachable(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:                  true,
+		SystemDiskThreshold:      90.0,
+		DockerGoroutineThreshold: 5000,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	agent := &ServerState{
+		AgentName: "test-agent",
+		Status:    "online",
+		SystemMetrics: SystemMetrics{
+			Docker: &DockerMetrics{Error: "Cannot connect to the Docker daemon"},
+		},
+	}
+
+	engine.checkDockerAlerts(agent)
+
+	if len(state.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(state.alerts))
+	}
+
+	if state.alerts[0].AlertType != "docker_daemon_unreachable" {
+		t.Errorf("Expected alert type 'docker_daemon_unreachable', got '%s'", state.alerts[0].AlertType)
+	}
+
+	if state.alerts[0].Severity != "critical" {
+		t.Errorf("Expected severity 'critical', got '%s'", state.alerts[0].Severity)
+	}
+}
+
+func TestCheckDockerAlerts_GoroutinesAndDataRoot(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:                  true,
+		SystemDiskThreshold:      90.0,
+		DockerGoroutineThreshold: 5000,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	agent := &ServerState{
+		AgentName: "test-agent",
+		Status:    "online",
+		SystemMetrics: SystemMetrics{
+			Docker: &DockerMetrics{
+				Goroutines:          12000,
+				DataRoot:            "/var/lib/docker",
+				DataRootUsedPercent: 96.5,
+			},
+		},
+	}
+
+	engine.checkDockerAlerts(agent)
+
+	if len(state.alerts) != 2 {
+		t.Fatalf("Expected 2 alerts, got %d", len(state.alerts))
+	}
+
+	alertTypes := make(map[string]bool)
+	for _, alert := range state.alerts {
+		alertTypes[alert.AlertType] = true
+	}
+
+	if !alertTypes["docker_goroutines_high"] {
+		t.Error("Expected docker_goroutines_high alert")
+	}
+	if !alertTypes["docker_data_root_high"] {
+		t.Error("Expected docker_data_root_high alert")
+	}
+}
+
+func TestCheckDockerAlerts_NoDockerMetrics(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:                  true,
+		SystemDiskThreshold:      90.0,
+		DockerGoroutineThreshold: 5000,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	engine.checkDockerAlerts(&ServerState{AgentName: "test-agent", Status: "online"})
+
+	if len(state.alerts) != 0 {
+		t.Errorf("Expected no alerts without Docker metrics, got %d", len(state.alerts))
+	}
+}
+
+func TestCheckContainerAlerts_Stopped(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:              true,
+		DeduplicationEnabled: false,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	agent := &ServerState{
+		AgentName: "test-agent",
+		Status:    "online",
+		Containers: []ContainerState{
+			{
+				ID:            "container-123",
+				Name:          "nginx",
+				State:         "exited",
+				PreviousState: "running",
+			},
+		},
+	}
+
+	engine.checkContainerAlerts(agent)
+
+	if len(state.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(state.alerts))
+	}
+
+	alert := state.alerts[0]
+	if alert.AlertType != "container_stopped" {
+		t.Errorf("Expected alert type 'container_stopped', got '%s'", alert.AlertType)
+	}
+
+	if alert.Severity != "critical" {
+		t.Errorf("Expected severity 'critical', got '%s'", alert.Severity)
+	}
+}
+
+func TestCheckContainerAlerts_StoppedWithRemediation(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:              true,
+		DeduplicationEnabled: false,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	agent := &ServerState{
+		AgentName: "test-agent",
+		Status:    "online",
+		Containers: []ContainerState{
+			{
+				ID:            "container-123",
+				Name:          "api",
+				State:         "exited",
+				PreviousState: "running",
+				Remediation: &Remediation{
+					Action:      "restart",
+					Attempt:     1,
+					MaxAttempts: 3,
+					Success:     true,
+					Timestamp:   time.Now(),
+				},
+			},
+		},
+	}
+
+	engine.checkContainerAlerts(agent)
+
+	if len(state.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(state.alerts))
+	}
+
+	alert := state.alerts[0]
+	if !strings.Contains(alert.Message, "restart succeeded (attempt 1/3)") {
+		t.Errorf("Expected remediation summary in message, got '%s'", alert.Message)
+	}
+
+	if _, ok := alert.Details["remediation"]; !ok {
+		t.Error("Expected remediation in alert details")
+	}
+}
+
+func TestCheckContainerAlerts_StoppedIncludesExitReason(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:              true,
+		DeduplicationEnabled: false,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	agent := &ServerState{
+		AgentName: "test-agent",
+		Status:    "online",
+		Containers: []ContainerState{
+			{
+				ID:            "container-123",
+				Name:          "api",
+				State:         "exited",
+				PreviousState: "running",
+				ExitCode:      137,
+				OOMKilled:     true,
+				FinishedAt:    time.Date(2024, 1, 1, 12, 3, 4, 0, time.UTC),
+			},
+		},
+	}
+
+	engine.checkContainerAlerts(agent)
 
-	if len(state.alerts) != 0 {
-		t.Errorf("Expected 0 alerts when below threshold, got %d", len(state.alerts))
+	if len(state.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(state.alerts))
+	}
+
+	alert := state.alerts[0]
+	if !strings.Contains(alert.Message, "State: exited 137 (OOMKilled) at 12:03:04 UTC") {
+		t.Errorf("Expected exit reason in message, got '%s'", alert.Message)
+	}
+	if alert.Details["exit_code"] != 137 {
+		t.Errorf("Expected exit_code 137 in details, got %v", alert.Details["exit_code"])
+	}
+	if alert.Details["oom_killed"] != true {
+		t.Errorf("Expected oom_killed in details, got %v", alert.Details["oom_killed"])
 	}
 }
 
-func TestCheckSystemAlerts_ThresholdsDisabled(t *testing.T) {
+func TestCheckContainerAlerts_OOMKills(t *testing.T) {
 	state := NewMockStateStore()
 	notifier := NewMockNotifier()
 	config := &Config{
-		Enabled:                 true,
-		SystemCPUThreshold:      0, // Disabled
-		SystemMemoryThreshold:   0, // Disabled
-		SystemDiskThreshold:     0, // Disabled
-		DeduplicationEnabled:    false,
+		Enabled:                   true,
+		DeduplicationEnabled:      false,
+		ContainerOOMKillThreshold: 3,
 	}
 
 	engine := NewEngine(state, config, notifier)
@@ -365,30 +886,28 @@ func TestCheckSystemAlerts_ThresholdsDisabled(t *testing.T) {
 	agent := &ServerState{
 		AgentName: "test-agent",
 		Status:    "online",
-		SystemMetrics: SystemMetrics{
-			CPU: CPUMetrics{
-				UsagePercent: 95.0,
-			},
-			Memory: MemoryMetrics{
-				UsedPercent: 95.0,
-			},
-			Disk: []DiskMetrics{
-				{
-					MountPoint:  "/",
-					UsedPercent: 95.0,
-				},
-			},
+		Containers: []ContainerState{
+			{ID: "c1", Name: "api", State: "running", OOMKillsLastHour: 4, RestartCount: 4},
+			{ID: "c2", Name: "web", State: "running", OOMKillsLastHour: 3},
 		},
 	}
 
-	engine.checkSystemAlerts(agent)
+	engine.checkContainerAlerts(agent)
 
-	if len(state.alerts) != 0 {
-		t.Errorf("Expected 0 alerts when thresholds are disabled, got %d", len(state.alerts))
+	if len(state.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(state.alerts))
+	}
+
+	alert := state.alerts[0]
+	if alert.AlertType != "container_oom_kills" {
+		t.Errorf("Expected alert type 'container_oom_kills', got '%s'", alert.AlertType)
+	}
+	if alert.Details["container_name"] != "api" {
+		t.Errorf("Expected alert for container 'api', got %v", alert.Details["container_name"])
 	}
 }
 
-func TestCheckContainerAlerts_Stopped(t *testing.T) {
+func TestCheckContainerAlerts_Unhealthy(t *testing.T) {
 	state := NewMockStateStore()
 	notifier := NewMockNotifier()
 	config := &Config{
@@ -403,10 +922,10 @@ func TestCheckContainerAlerts_Stopped(t *testing.T) {
 		Status:    "online",
 		Containers: []ContainerState{
 			{
-				ID:            "container-123",
-				Name:          "nginx",
-				State:         "exited",
-				PreviousState: "running",
+				ID:     "container-123",
+				Name:   "nginx",
+				State:  "running",
+				Health: "unhealthy",
 			},
 		},
 	}
@@ -418,16 +937,16 @@ func TestCheckContainerAlerts_Stopped(t *testing.T) {
 	}
 
 	alert := state.alerts[0]
-	if alert.AlertType != "container_stopped" {
-		t.Errorf("Expected alert type 'container_stopped', got '%s'", alert.AlertType)
+	if alert.AlertType != "container_unhealthy" {
+		t.Errorf("Expected alert type 'container_unhealthy', got '%s'", alert.AlertType)
 	}
 
-	if alert.Severity != "critical" {
-		t.Errorf("Expected severity 'critical', got '%s'", alert.Severity)
+	if alert.Severity != "warning" {
+		t.Errorf("Expected severity 'warning', got '%s'", alert.Severity)
 	}
 }
 
-func TestCheckContainerAlerts_Unhealthy(t *testing.T) {
+func TestCheckContainerAlerts_UnhealthyIncludesHealthCheckOutput(t *testing.T) {
 	state := NewMockStateStore()
 	notifier := NewMockNotifier()
 	config := &Config{
@@ -442,10 +961,12 @@ func TestCheckContainerAlerts_Unhealthy(t *testing.T) {
 		Status:    "online",
 		Containers: []ContainerState{
 			{
-				ID:     "container-123",
-				Name:   "nginx",
-				State:  "running",
-				Health: "unhealthy",
+				ID:                  "container-123",
+				Name:                "nginx",
+				State:               "running",
+				Health:              "unhealthy",
+				HealthCheckExitCode: 7,
+				HealthCheckOutput:   "curl: (7) Failed to connect to localhost port 80",
 			},
 		},
 	}
@@ -457,12 +978,16 @@ func TestCheckContainerAlerts_Unhealthy(t *testing.T) {
 	}
 
 	alert := state.alerts[0]
-	if alert.AlertType != "container_unhealthy" {
-		t.Errorf("Expected alert type 'container_unhealthy', got '%s'", alert.AlertType)
+	if alert.Details["health_check_exit_code"] != 7 {
+		t.Errorf("Expected health_check_exit_code 7, got %v", alert.Details["health_check_exit_code"])
 	}
 
-	if alert.Severity != "warning" {
-		t.Errorf("Expected severity 'warning', got '%s'", alert.Severity)
+	if alert.Details["health_check_output"] != "curl: (7) Failed to connect to localhost port 80" {
+		t.Errorf("Unexpected health_check_output: %v", alert.Details["health_check_output"])
+	}
+
+	if !strings.Contains(alert.Message, "Failed to connect") {
+		t.Errorf("Expected message to include health check output, got '%s'", alert.Message)
 	}
 }
 
@@ -505,6 +1030,86 @@ func TestCheckContainerAlerts_HighCPU(t *testing.T) {
 	}
 }
 
+func TestCheckContainerAlerts_LabelThresholds(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:              true,
+		DeduplicationEnabled: false,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	agent := &ServerState{
+		AgentName: "test-agent",
+		Status:    "online",
+		Containers: []ContainerState{
+			// Above the default but below its own label threshold
+			{ID: "c1", Name: "batch", State: "running", CPUPercent: 93.0, CPUThreshold: 99.0},
+			// Below the default but above its own label threshold
+			{ID: "c2", Name: "api", State: "running", MemoryPercent: 85.0, MemoryThreshold: 80.0},
+		},
+	}
+
+	engine.checkContainerAlerts(agent)
+
+	if len(state.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(state.alerts))
+	}
+
+	alert := state.alerts[0]
+	if alert.AlertType != "container_memory_high" {
+		t.Errorf("Expected alert type 'container_memory_high', got '%s'", alert.AlertType)
+	}
+	if alert.Details["threshold"] != 80.0 {
+		t.Errorf("Expected threshold 80 in details, got %v", alert.Details["threshold"])
+	}
+}
+
+func TestCheckContainerAlerts_ConfigThresholds(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:                  true,
+		DeduplicationEnabled:     false,
+		ContainerCPUThreshold:    70.0,
+		ContainerMemoryThreshold: 80.0,
+		ContainerThresholdOverrides: []ContainerThresholdOverride{
+			{Name: "db-*", MemoryThreshold: 90.0},
+			{Name: "db-replica", MemoryThreshold: 98.0},
+		},
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	agent := &ServerState{
+		AgentName: "test-agent",
+		Status:    "online",
+		Containers: []ContainerState{
+			// Above the configured CPU threshold
+			{ID: "c1", Name: "api", State: "running", CPUPercent: 75.0},
+			// Above the default but within its override
+			{ID: "c2", Name: "db-primary", State: "running", MemoryPercent: 85.0},
+			// The later, more specific override wins
+			{ID: "c3", Name: "db-replica", State: "running", MemoryPercent: 95.0},
+			// Labels beat the override
+			{ID: "c4", Name: "db-cache", State: "running", MemoryPercent: 85.0, MemoryThreshold: 82.0},
+		},
+	}
+
+	engine.checkContainerAlerts(agent)
+
+	if len(state.alerts) != 2 {
+		t.Fatalf("Expected 2 alerts, got %d", len(state.alerts))
+	}
+	if alert := state.alerts[0]; alert.AlertType != "container_cpu_high" || alert.Details["threshold"] != 70.0 {
+		t.Errorf("Expected a CPU alert at threshold 70, got %s at %v", alert.AlertType, alert.Details["threshold"])
+	}
+	if alert := state.alerts[1]; alert.Details["container_name"] != "db-cache" || alert.Details["threshold"] != 82.0 {
+		t.Errorf("Expected a db-cache memory alert at threshold 82, got %v at %v", alert.Details["container_name"], alert.Details["threshold"])
+	}
+}
+
 func TestCheckContainerAlerts_HighMemory(t *testing.T) {
 	state := NewMockStateStore()
 	notifier := NewMockNotifier()
@@ -660,7 +1265,7 @@ func TestShouldSendAlert_DeduplicationEnabled(t *testing.T) {
 
 	// Manually set the time to past the deduplication window
 	engine.mu.Lock()
-	engine.recentAlerts[alertKey] = time.Now().Add(-6 * time.Minute)
+	engine.recentAlerts.set(alertKey, time.Now().Add(-6*time.Minute))
 	engine.mu.Unlock()
 
 	// After window should send again
@@ -669,6 +1274,49 @@ func TestShouldSendAlert_DeduplicationEnabled(t *testing.T) {
 	}
 }
 
+// mockDedupStore is deduplication shared with another server
+type mockDedupStore struct {
+	sent map[string]time.Time
+	err  error
+}
+
+func (m *mockDedupStore) LastSent(alertKey string) (time.Time, bool, error) {
+	sent, exists := m.sent[alertKey]
+	return sent, exists, m.err
+}
+
+func (m *mockDedupStore) MarkSent(alertKey string, at time.Time, ttl time.Duration) error {
+	if m.err == nil {
+		m.sent[alertKey] = at
+	}
+	return m.err
+}
+
+func TestShouldSendAlert_SharedDedup(t *testing.T) {
+	config := &Config{Enabled: true, DeduplicationEnabled: true, DeduplicationWindow: 5 * time.Minute}
+	shared := &mockDedupStore{sent: map[string]time.Time{"sent-elsewhere": time.Now()}}
+	engine := NewEngine(NewMockStateStore(), config, NewMockNotifier())
+	engine.SetDedupStore(shared)
+
+	if engine.shouldSendAlert("sent-elsewhere") {
+		t.Error("Expected an alert another server sent to be deduplicated")
+	}
+
+	engine.markAlertSent("sent-here")
+	if _, exists := shared.sent["sent-here"]; !exists {
+		t.Error("Expected the sent alert to be shared")
+	}
+
+	// While the store fails, the engine's own memory is used
+	shared.err = errors.New("connection refused")
+	if engine.shouldSendAlert("sent-here") {
+		t.Error("Expected local deduplication while the store fails")
+	}
+	if !engine.shouldSendAlert("sent-elsewhere") {
+		t.Error("Expected an alert unknown locally to be sent while the store fails")
+	}
+}
+
 func TestMarkAlertSent(t *testing.T) {
 	state := NewMockStateStore()
 	notifier := NewMockNotifier()
@@ -684,7 +1332,7 @@ func TestMarkAlertSent(t *testing.T) {
 
 	// Initially should not exist
 	engine.mu.RLock()
-	_, exists := engine.recentAlerts[alertKey]
+	_, exists := engine.recentAlerts.get(alertKey)
 	engine.mu.RUnlock()
 
 	if exists {
@@ -696,7 +1344,7 @@ func TestMarkAlertSent(t *testing.T) {
 
 	// Should now exist
 	engine.mu.RLock()
-	timestamp, exists := engine.recentAlerts[alertKey]
+	timestamp, exists := engine.recentAlerts.get(alertKey)
 	engine.mu.RUnlock()
 
 	if !exists {
@@ -720,9 +1368,9 @@ func TestCleanupDeduplication(t *testing.T) {
 	engine := NewEngine(state, config, notifier)
 
 	// Add some old and recent alerts
-	engine.recentAlerts["old-alert"] = time.Now().Add(-15 * time.Minute)
-	engine.recentAlerts["recent-alert"] = time.Now().Add(-2 * time.Minute)
-	engine.recentAlerts["very-old-alert"] = time.Now().Add(-1 * time.Hour)
+	engine.recentAlerts.set("old-alert", time.Now().Add(-15*time.Minute))
+	engine.recentAlerts.set("recent-alert", time.Now().Add(-2*time.Minute))
+	engine.recentAlerts.set("very-old-alert", time.Now().Add(-1*time.Hour))
 
 	engine.cleanupDeduplication()
 
@@ -730,22 +1378,143 @@ func TestCleanupDeduplication(t *testing.T) {
 	defer engine.mu.RUnlock()
 
 	// Recent alert should remain (within 2x deduplication window)
-	if _, exists := engine.recentAlerts["recent-alert"]; !exists {
+	if _, exists := engine.recentAlerts.get("recent-alert"); !exists {
 		t.Error("Recent alert should not be cleaned up")
 	}
 
 	// Old alerts should be removed (beyond 2x deduplication window)
-	if _, exists := engine.recentAlerts["old-alert"]; exists {
+	if _, exists := engine.recentAlerts.get("old-alert"); exists {
 		t.Error("Old alert should be cleaned up")
 	}
 
-	if _, exists := engine.recentAlerts["very-old-alert"]; exists {
+	if _, exists := engine.recentAlerts.get("very-old-alert"); exists {
 		t.Error("Very old alert should be cleaned up")
 	}
 
 	// Should have exactly 1 entry remaining
-	if len(engine.recentAlerts) != 1 {
-		t.Errorf("Expected 1 recent alert, got %d", len(engine.recentAlerts))
+	if engine.recentAlerts.len() != 1 {
+		t.Errorf("Expected 1 recent alert, got %d", engine.recentAlerts.len())
+	}
+}
+
+func TestCheckImageUpdateDigest(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:                   true,
+		ImageUpdateDigestInterval: 7 * 24 * time.Hour,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	agents := []*ServerState{
+		{
+			AgentName: "test-agent",
+			Status:    "online",
+			Containers: []ContainerState{
+				{ID: "c1", Name: "nginx", Image: "nginx:latest", UpdateAvailable: true},
+				{ID: "c2", Name: "redis", Image: "redis:7", UpdateAvailable: false},
+			},
+		},
+		{
+			AgentName: "up-to-date-agent",
+			Status:    "online",
+			Containers: []ContainerState{
+				{ID: "c3", Name: "postgres", Image: "postgres:16"},
+			},
+		},
+	}
+
+	// Interval has not elapsed since startup
+	engine.checkImageUpdateDigest(agents)
+	if len(state.alerts) != 0 {
+		t.Fatalf("Expected no digest before interval elapsed, got %d alerts", len(state.alerts))
+	}
+
+	engine.lastImageDigest = time.Now().Add(-8 * 24 * time.Hour)
+	engine.checkImageUpdateDigest(agents)
+
+	if len(state.alerts) != 1 {
+		t.Fatalf("Expected 1 digest alert, got %d", len(state.alerts))
+	}
+
+	alert := state.alerts[0]
+	if alert.AlertType != "image_update_available" {
+		t.Errorf("Expected alert type 'image_update_available', got '%s'", alert.AlertType)
+	}
+	if alert.Severity != "info" {
+		t.Errorf("Expected severity 'info', got '%s'", alert.Severity)
+	}
+	if !strings.Contains(alert.Message, "nginx (nginx:latest)") || strings.Contains(alert.Message, "redis") {
+		t.Errorf("Unexpected digest message: %s", alert.Message)
+	}
+	if alert.Status != "resolved" || alert.ResolvedAt == nil {
+		t.Errorf("Expected digest to be recorded resolved, got status '%s'", alert.Status)
+	}
+
+	// Digest is not repeated until the next interval
+	engine.checkImageUpdateDigest(agents)
+	if len(state.alerts) != 1 {
+		t.Errorf("Expected digest to be sent once per interval, got %d alerts", len(state.alerts))
+	}
+}
+
+func TestCheckImageUpdateDigest_NoActiveAlertsPileUp(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:                   true,
+		ImageUpdateDigestInterval: 7 * 24 * time.Hour,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	agents := []*ServerState{
+		{
+			AgentName:  "test-agent",
+			Status:     "online",
+			Containers: []ContainerState{{ID: "c1", Name: "nginx", Image: "nginx:latest", UpdateAvailable: true}},
+		},
+	}
+
+	for cycle := 0; cycle < 2; cycle++ {
+		engine.lastImageDigest = time.Now().Add(-8 * 24 * time.Hour)
+		engine.checkImageUpdateDigest(agents)
+	}
+
+	if len(state.alerts) != 2 {
+		t.Fatalf("Expected a digest per cycle, got %d alerts", len(state.alerts))
+	}
+	if len(notifier.sentAlerts) != 2 {
+		t.Errorf("Expected each digest to be notified, got %d", len(notifier.sentAlerts))
+	}
+
+	active := map[string]int{}
+	for _, alert := range state.alerts {
+		if alert.Status == "active" {
+			active[alert.AgentName]++
+		}
+	}
+	if active["test-agent"] > 1 {
+		t.Errorf("Expected at most 1 active digest alert per agent, got %d", active["test-agent"])
+	}
+}
+
+func TestCheckImageUpdateDigest_Disabled(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	engine := NewEngine(state, &Config{Enabled: true}, notifier)
+	engine.lastImageDigest = time.Time{}
+
+	engine.checkImageUpdateDigest([]*ServerState{
+		{
+			AgentName:  "test-agent",
+			Containers: []ContainerState{{ID: "c1", Name: "nginx", UpdateAvailable: true}},
+		},
+	})
+
+	if len(state.alerts) != 0 {
+		t.Errorf("Expected no digest when interval is 0, got %d alerts", len(state.alerts))
 	}
 }
 
@@ -798,7 +1567,10 @@ func TestCheckAlerts_Integration(t *testing.T) {
 		},
 	})
 
+	// The ticker finds the offline agent, the push evaluates the online one
 	engine.checkAlerts()
+	engine.Trigger("online-agent")
+	engine.evaluatePending()
 
 	// Should have alerts for:
 	// 1. Offline agent
@@ -849,7 +1621,9 @@ func TestCheckAlerts_OnlyOnlineAgents(t *testing.T) {
 		},
 	})
 
-	engine.checkAlerts()
+	engine.Trigger("offline-agent")
+	engine.Trigger("online-agent")
+	engine.evaluatePending()
 
 	// Should have no alerts (offline agent ignored, online agent below threshold)
 	if len(state.alerts) != 0 {
@@ -857,6 +1631,86 @@ func TestCheckAlerts_OnlyOnlineAgents(t *testing.T) {
 	}
 }
 
+func TestCheckAlerts_LeavesMetricsToTrigger(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:            true,
+		SystemCPUThreshold: 80.0,
+	}
+
+	engine := NewEngine(state, config, notifier)
+
+	state.agents = append(state.agents, &ServerState{
+		AgentName:     "busy-agent",
+		Status:        "online",
+		SystemMetrics: SystemMetrics{CPU: CPUMetrics{UsagePercent: 95.0}},
+	})
+
+	engine.checkAlerts()
+	if len(state.alerts) != 0 {
+		t.Fatalf("Expected the periodic check to skip metrics, got %d alerts", len(state.alerts))
+	}
+
+	engine.Trigger("busy-agent")
+	engine.Trigger("busy-agent")
+	engine.Trigger("unknown-agent")
+	engine.evaluatePending()
+
+	if len(state.alerts) != 1 {
+		t.Errorf("Expected 1 alert from the triggered evaluation, got %d", len(state.alerts))
+	}
+
+	// Pending agents are evaluated once
+	engine.evaluatePending()
+	if len(state.alerts) != 1 {
+		t.Errorf("Expected no further alerts without a new trigger, got %d", len(state.alerts))
+	}
+}
+
+func TestTrigger_EvaluatesPromptly(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	config := &Config{
+		Enabled:            true,
+		CheckInterval:      time.Hour,
+		SystemCPUThreshold: 80.0,
+	}
+
+	engine := NewEngine(state, config, notifier)
+	state.agents = append(state.agents, &ServerState{
+		AgentName:     "busy-agent",
+		Status:        "online",
+		SystemMetrics: SystemMetrics{CPU: CPUMetrics{UsagePercent: 95.0}},
+	})
+
+	go engine.Start()
+	engine.Trigger("busy-agent")
+
+	// Long before the hourly check, only the trigger can have done this
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		engine.mu.RLock()
+		_, sent := engine.recentAlerts.get("system_cpu:busy-agent")
+		engine.mu.RUnlock()
+		if sent {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected a triggered evaluation well before the check interval")
+}
+
+func TestTrigger_DisabledEngine(t *testing.T) {
+	engine := NewEngine(NewMockStateStore(), &Config{Enabled: false}, NewMockNotifier())
+
+	engine.Trigger("agent")
+
+	if len(engine.pending) != 0 {
+		t.Error("Expected a disabled engine to ignore triggers")
+	}
+}
+
 func TestSendAlert(t *testing.T) {
 	state := NewMockStateStore()
 	notifier := NewMockNotifier()
@@ -898,7 +1752,7 @@ func TestSendAlert(t *testing.T) {
 
 	// Verify alert was marked as sent for deduplication
 	engine.mu.RLock()
-	_, exists := engine.recentAlerts[alertKey]
+	_, exists := engine.recentAlerts.get(alertKey)
 	engine.mu.RUnlock()
 
 	if !exists {
@@ -906,6 +1760,29 @@ func TestSendAlert(t *testing.T) {
 	}
 }
 
+func TestSendAlert_Annotations(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	engine := NewEngine(state, &Config{
+		Enabled: true,
+		Annotations: map[string]map[string]string{
+			"queue_backlog": {"runbook_url": "https://wiki.example.com/queues", "team": "platform"},
+		},
+	}, notifier)
+
+	// The rule's own annotations win over those of its type
+	engine.sendAlert(&Alert{AlertType: "queue_backlog", Status: "active", Annotations: map[string]string{"team": "billing"}}, "a")
+	engine.sendAlert(&Alert{AlertType: "system_cpu", Status: "active"}, "b")
+
+	want := map[string]string{"runbook_url": "https://wiki.example.com/queues", "team": "billing"}
+	if got := notifier.sentAlerts[0].Annotations; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected annotations %v, got %v", want, got)
+	}
+	if got := notifier.sentAlerts[1].Annotations; got != nil {
+		t.Errorf("Expected no annotations, got %v", got)
+	}
+}
+
 func TestSendAlert_NotificationFails(t *testing.T) {
 	state := NewMockStateStore()
 	notifier := NewMockNotifier()
@@ -938,3 +1815,17 @@ func TestSendAlert_NotificationFails(t *testing.T) {
 		t.Error("NotifiedAt should not be set when notification fails")
 	}
 }
+
+func TestReconfigure(t *testing.T) {
+	config := &Config{Enabled: true, SystemCPUThreshold: 80}
+	engine := NewEngine(NewMockStateStore(), config, NewMockNotifier())
+
+	engine.Reconfigure(func(c *Config) { c.SystemCPUThreshold = 95 })
+
+	if got := engine.Config().SystemCPUThreshold; got != 95 {
+		t.Errorf("Expected threshold 95, got %v", got)
+	}
+	if config.SystemCPUThreshold != 80 {
+		t.Error("Expected the original configuration to be left unchanged")
+	}
+}
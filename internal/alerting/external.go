@@ -0,0 +1,76 @@
+package alerting
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExternalAlert is an alert reported by another system, such as a backup
+// script or cron job, through the API
+type ExternalAlert struct {
+	Source      string // What reported it, e.g. "nightly-backup"
+	AgentName   string // The host it concerns, which needn't run an agent
+	AlertType   string
+	Key         string // Tells apart alerts of the same type and host, e.g. a check name (optional)
+	Severity    string // critical, warning or info
+	Message     string
+	Details     map[string]interface{}
+	Annotations map[string]string // Such as runbook_url, next to the configured ones
+}
+
+// RaiseExternal passes an externally reported alert through the same
+// deduplication, ownership, silencing and notification as the engine's own.
+// It returns the recorded alert, or nil if it was a duplicate or is
+// already owned.
+func (e *Engine) RaiseExternal(ext ExternalAlert) *Alert {
+	alertKey := fmt.Sprintf("external:%s:%s:%s", ext.Source, ext.AgentName, ext.AlertType)
+	if ext.Key != "" {
+		alertKey += ":" + ext.Key
+	}
+	if !e.shouldSendAlert(alertKey) {
+		e.logger.Debug("Duplicate external alert", "source", ext.Source, "alert_type", ext.AlertType, "agent", ext.AgentName)
+		return nil
+	}
+
+	details := map[string]interface{}{}
+	for k, v := range ext.Details {
+		details[k] = v
+	}
+	details["agent_name"] = ext.AgentName
+	details["source"] = ext.Source
+
+	alert := &Alert{
+		ID:          uuid.New().String(),
+		AgentName:   ext.AgentName,
+		AlertType:   ext.AlertType,
+		Severity:    ext.Severity,
+		Message:     fmt.Sprintf("%s %s\nAgent: %s\nSource: %s", severityIcon(ext.Severity), ext.Message, ext.AgentName, ext.Source),
+		Details:     details,
+		TriggeredAt: time.Now(),
+		Status:      "active",
+	}
+	if len(ext.Annotations) > 0 {
+		alert.Annotations = make(map[string]string, len(ext.Annotations))
+		for k, v := range ext.Annotations {
+			alert.Annotations[k] = v
+		}
+	}
+	if !e.sendAlert(alert, alertKey) {
+		return nil
+	}
+	return alert
+}
+
+// severityIcon is the icon alert messages start with
+func severityIcon(severity string) string {
+	switch severity {
+	case "critical":
+		return "🚨"
+	case "info":
+		return "ℹ️"
+	default:
+		return "⚠️"
+	}
+}
@@ -0,0 +1,94 @@
+package alerting
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newExternalTestEngine(state *MockStateStore, notifier *MockNotifier) *Engine {
+	return NewEngine(state, &Config{
+		Enabled:              true,
+		DeduplicationEnabled: true,
+		DeduplicationWindow:  5 * time.Minute,
+	}, notifier)
+}
+
+func TestRaiseExternal(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	engine := newExternalTestEngine(state, notifier)
+
+	alert := engine.RaiseExternal(ExternalAlert{
+		Source:      "nightly-backup",
+		AgentName:   "db-1",
+		AlertType:   "backup_failed",
+		Severity:    "critical",
+		Message:     "pg_dump exited 1",
+		Details:     map[string]interface{}{"exit_code": 1},
+		Annotations: map[string]string{RunbookURLAnnotation: "https://wiki.example.com/backups"},
+	})
+	if alert == nil {
+		t.Fatal("Expected an alert, got nil")
+	}
+
+	if len(state.alerts) != 1 || len(notifier.sentAlerts) != 1 {
+		t.Fatalf("Expected 1 stored and 1 sent alert, got %d and %d", len(state.alerts), len(notifier.sentAlerts))
+	}
+	if alert.AlertType != "backup_failed" || alert.Severity != "critical" || alert.Status != "active" {
+		t.Errorf("Unexpected alert: %+v", alert)
+	}
+	if !strings.Contains(alert.Message, "pg_dump exited 1") || !strings.Contains(alert.Message, "Source: nightly-backup") {
+		t.Errorf("Expected message to include the text and source, got %q", alert.Message)
+	}
+	if alert.Details["source"] != "nightly-backup" || alert.Details["agent_name"] != "db-1" || alert.Details["exit_code"] != 1 {
+		t.Errorf("Unexpected details: %v", alert.Details)
+	}
+	if alert.Annotations[RunbookURLAnnotation] != "https://wiki.example.com/backups" {
+		t.Errorf("Expected the runbook annotation, got %v", alert.Annotations)
+	}
+}
+
+func TestRaiseExternal_Deduplicated(t *testing.T) {
+	state := NewMockStateStore()
+	notifier := NewMockNotifier()
+	engine := newExternalTestEngine(state, notifier)
+
+	ext := ExternalAlert{Source: "cron", AgentName: "web-1", AlertType: "job_failed", Severity: "warning", Message: "failed"}
+	if engine.RaiseExternal(ext) == nil {
+		t.Fatal("Expected the first alert to be raised")
+	}
+	if alert := engine.RaiseExternal(ext); alert != nil {
+		t.Errorf("Expected a repeat to be deduplicated, got %+v", alert)
+	}
+
+	// Another source raising the same type is a different alert
+	ext.Source = "other-cron"
+	if engine.RaiseExternal(ext) == nil {
+		t.Error("Expected an alert from another source to be raised")
+	}
+
+	// So is one with another key
+	ext.Key = "nightly"
+	if engine.RaiseExternal(ext) == nil {
+		t.Error("Expected an alert with another key to be raised")
+	}
+	if len(notifier.sentAlerts) != 3 {
+		t.Errorf("Expected 3 sent alerts, got %d", len(notifier.sentAlerts))
+	}
+}
+
+func TestRaiseExternal_Owned(t *testing.T) {
+	state := NewMockStateStore()
+	state.owners["db-1:backup_failed"] = "alice"
+	notifier := NewMockNotifier()
+	engine := newExternalTestEngine(state, notifier)
+
+	alert := engine.RaiseExternal(ExternalAlert{Source: "nightly-backup", AgentName: "db-1", AlertType: "backup_failed", Severity: "critical", Message: "failed"})
+	if alert != nil {
+		t.Errorf("Expected nil while the earlier alert is owned, got %+v", alert)
+	}
+	if len(state.alerts) != 0 || len(notifier.sentAlerts) != 0 {
+		t.Errorf("Expected nothing stored or sent, got %d and %d", len(state.alerts), len(notifier.sentAlerts))
+	}
+}
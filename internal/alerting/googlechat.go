@@ -4,126 +4,266 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"html"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/anurag/saviour/internal/logging"
 )
 
 // GoogleChatNotifier sends alerts to Google Chat via webhook
 type GoogleChatNotifier struct {
+	logger       *slog.Logger
 	webhookURL   string
 	dashboardURL string
 	httpClient   *http.Client
+	location     *time.Location // Time zone of card times, see SetLocation
+
+	// How long to wait before retrying a rate limited post, see post
+	sleep func(time.Duration)
 }
 
+// Retrying posts that Google Chat rate limits with 429, as it does during
+// alert storms. Retry-After is honoured up to maxRetryWait; without it the
+// wait doubles from initialRetryWait.
+const (
+	maxPostAttempts  = 4
+	initialRetryWait = time.Second
+	maxRetryWait     = 30 * time.Second
+)
+
 // NewGoogleChatNotifier creates a new Google Chat notifier
 func NewGoogleChatNotifier(webhookURL, dashboardURL string) *GoogleChatNotifier {
 	return &GoogleChatNotifier{
+		logger:       logging.Component("google_chat"),
 		webhookURL:   webhookURL,
 		dashboardURL: dashboardURL,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		sleep: time.Sleep,
 	}
 }
 
+// SetLocation sets the time zone the cards show times in (default: UTC)
+func (g *GoogleChatNotifier) SetLocation(loc *time.Location) {
+	g.location = loc
+}
+
 // SendAlert sends an alert to Google Chat
 func (g *GoogleChatNotifier) SendAlert(alert *Alert) error {
-	message := g.buildMessage(alert)
+	return g.post(g.buildMessage(alert))
+}
+
+// SendReport posts a summary report as a plain text message, threaded
+// apart from alerts
+func (g *GoogleChatNotifier) SendReport(title, text string) error {
+	return g.post(map[string]interface{}{
+		"text":   fmt.Sprintf("*%s*\n%s", title, text),
+		"thread": map[string]interface{}{"threadKey": "saviour-report"},
+	})
+}
+
+// CheckChannel verifies the webhook without posting to the space: an empty
+// message is rejected as invalid (400) only once the space, key and token
+// have been accepted, while a broken webhook answers 401, 403 or 404
+func (g *GoogleChatNotifier) CheckChannel() error {
+	resp, err := g.httpClient.Post(g.webhookURL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		return fmt.Errorf("Google Chat webhook unreachable: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusBadRequest, http.StatusTooManyRequests:
+		return nil
+	default:
+		return fmt.Errorf("Google Chat webhook returned status %d", resp.StatusCode)
+	}
+}
 
+// post sends a message to the webhook, retrying while it is rate limited
+func (g *GoogleChatNotifier) post(message map[string]interface{}) error {
 	payload, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal Google Chat message: %w", err)
 	}
 
-	resp, err := g.httpClient.Post(g.webhookURL, "application/json", bytes.NewBuffer(payload))
-	if err != nil {
-		return fmt.Errorf("failed to send Google Chat webhook: %w", err)
+	// Reply in the message's thread, starting it if it doesn't exist yet
+	webhookURL := g.webhookURL
+	if _, threaded := message["thread"]; threaded {
+		webhookURL = withQuery(webhookURL, "messageReplyOption", "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Google Chat webhook returned status %d", resp.StatusCode)
+	wait := initialRetryWait
+	for attempt := 1; ; attempt++ {
+		resp, err := g.httpClient.Post(webhookURL, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("failed to send Google Chat webhook: %w", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+		if resp.StatusCode != http.StatusTooManyRequests || attempt == maxPostAttempts {
+			return fmt.Errorf("Google Chat webhook returned status %d", resp.StatusCode)
+		}
+
+		delay := wait
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			delay = retryAfter
+		}
+		delay = min(delay, maxRetryWait)
+		g.logger.Warn("Google Chat rate limited the webhook, retrying", "delay", delay, "attempt", attempt, "max_attempts", maxPostAttempts)
+		g.sleep(delay)
+		wait *= 2
 	}
+}
 
-	return nil
+// parseRetryAfter reads a Retry-After header, either seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
 }
 
-// buildMessage creates a Google Chat card message
+// withQuery adds a query parameter to a URL that may already have some
+func withQuery(rawURL, key, value string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	query.Set(key, value)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// buildMessage creates a Google Chat message with a cardsV2 card
 func (g *GoogleChatNotifier) buildMessage(alert *Alert) map[string]interface{} {
 	// Determine icon based on severity
 	icon := g.getSeverityIcon(alert.Severity)
 
-	// Build sections
-	sections := []map[string]interface{}{
+	// Card text is HTML; keep the message's line breaks
+	text := strings.ReplaceAll(html.EscapeString(alert.Message), "\n", "<br>")
+
+	widgets := []map[string]interface{}{
 		{
-			"widgets": []map[string]interface{}{
-				{
-					"textParagraph": map[string]interface{}{
-						"text": fmt.Sprintf("<b>%s</b>", alert.Message),
-					},
-				},
-				{
-					"keyValue": map[string]interface{}{
-						"topLabel": "Alert Type",
-						"content":  alert.AlertType,
-					},
-				},
-				{
-					"keyValue": map[string]interface{}{
-						"topLabel": "Severity",
-						"content":  alert.Severity,
-					},
-				},
-				{
-					"keyValue": map[string]interface{}{
-						"topLabel": "Triggered At",
-						"content":  alert.TriggeredAt.Format("2006-01-02 15:04:05 MST"),
-					},
-				},
+			"textParagraph": map[string]interface{}{
+				"text": fmt.Sprintf("<b>%s</b>", text),
 			},
 		},
+		decoratedText("Alert Type", alert.AlertType),
+		decoratedText("Severity", alert.Severity),
+		decoratedText("Triggered At", formatTime(alert.TriggeredAt, g.location)),
 	}
 
-	// Add dashboard link if available
+	// Annotations other than the runbook, which gets a button, e.g. team
+	keys := make([]string, 0, len(alert.Annotations))
+	for key := range alert.Annotations {
+		if key != RunbookURLAnnotation {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		widgets = append(widgets, decoratedText(annotationLabel(key), alert.Annotations[key]))
+	}
+
+	// Build sections
+	sections := []map[string]interface{}{
+		{"widgets": widgets},
+	}
+
+	// Link to the runbook and dashboard if available
+	var buttons []map[string]interface{}
+	if runbook := alert.Annotations[RunbookURLAnnotation]; runbook != "" {
+		buttons = append(buttons, linkButton("Open Runbook", runbook))
+	}
 	if g.dashboardURL != "" {
-		buttons := map[string]interface{}{
-			"buttons": []map[string]interface{}{
+		buttons = append(buttons, linkButton("View Dashboard", g.dashboardURL))
+	}
+	if len(buttons) > 0 {
+		sections = append(sections, map[string]interface{}{
+			"widgets": []map[string]interface{}{
 				{
-					"textButton": map[string]interface{}{
-						"text": "View Dashboard",
-						"onClick": map[string]interface{}{
-							"openLink": map[string]interface{}{
-								"url": g.dashboardURL,
-							},
-						},
+					"buttonList": map[string]interface{}{
+						"buttons": buttons,
 					},
 				},
 			},
-		}
-		sections = append(sections, buttons)
+		})
 	}
 
 	// Build card
-	card := map[string]interface{}{
-		"cards": []map[string]interface{}{
+	message := map[string]interface{}{
+		"cardsV2": []map[string]interface{}{
 			{
-				"header": map[string]interface{}{
-					"title":    fmt.Sprintf("%s %s Alert", icon, alert.Severity),
-					"subtitle": alert.AgentName,
+				"cardId": "alert-" + alert.ID,
+				"card": map[string]interface{}{
+					"header": map[string]interface{}{
+						"title":    fmt.Sprintf("%s %s Alert", icon, alert.Severity),
+						"subtitle": alert.AgentName,
+					},
+					"sections": sections,
 				},
-				"sections": sections,
 			},
 		},
 	}
 
 	// Add thread key for grouping related alerts
 	if g.supportsThreading() {
-		card["thread"] = map[string]interface{}{
+		message["thread"] = map[string]interface{}{
 			"threadKey": fmt.Sprintf("alert-%s-%s", alert.AgentName, alert.AlertType),
 		}
 	}
 
-	return card
+	return message
+}
+
+// decoratedText is a card widget showing a labelled value
+func decoratedText(label, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"decoratedText": map[string]interface{}{
+			"topLabel": label,
+			"text":     html.EscapeString(text),
+		},
+	}
+}
+
+// linkButton is a card button opening a URL
+func linkButton(text, url string) map[string]interface{} {
+	return map[string]interface{}{
+		"text": text,
+		"onClick": map[string]interface{}{
+			"openLink": map[string]interface{}{
+				"url": url,
+			},
+		},
+	}
+}
+
+// annotationLabel turns an annotation key into a card label, e.g. "on_call"
+// into "On call"
+func annotationLabel(key string) string {
+	label := strings.ReplaceAll(key, "_", " ")
+	if label == "" {
+		return label
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
 }
 
 // getSeverityIcon returns emoji icon based on severity
@@ -162,6 +302,20 @@ func (c *ConsoleNotifier) SendAlert(alert *Alert) error {
 	fmt.Printf("Agent: %s\n", alert.AgentName)
 	fmt.Printf("Message: %s\n", alert.Message)
 	fmt.Printf("Triggered: %s\n", alert.TriggeredAt.Format(time.RFC3339))
+	keys := make([]string, 0, len(alert.Annotations))
+	for key := range alert.Annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("%s: %s\n", annotationLabel(key), alert.Annotations[key])
+	}
 	fmt.Printf("=============\n\n")
 	return nil
 }
+
+// SendReport prints a summary report to console
+func (c *ConsoleNotifier) SendReport(title, text string) error {
+	fmt.Printf("\n=== %s ===\n%s=============\n\n", title, text)
+	return nil
+}
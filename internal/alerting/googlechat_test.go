@@ -0,0 +1,233 @@
+package alerting
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGoogleChatNotifier_BuildMessage(t *testing.T) {
+	notifier := NewGoogleChatNotifier("https://chat.example.com/webhook", "https://saviour.example.com")
+	message := notifier.buildMessage(&Alert{
+		ID:          "a1",
+		AgentName:   "web-1",
+		AlertType:   "system_cpu_high",
+		Severity:    "critical",
+		Message:     "🔥 High CPU\nAgent: web-1\nCPU: 97% > 80%",
+		TriggeredAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	})
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatalf("Failed to marshal message: %v", err)
+	}
+	var decoded struct {
+		CardsV2 []struct {
+			CardID string `json:"cardId"`
+			Card   struct {
+				Header struct {
+					Title    string `json:"title"`
+					Subtitle string `json:"subtitle"`
+				} `json:"header"`
+				Sections []struct {
+					Widgets []map[string]json.RawMessage `json:"widgets"`
+				} `json:"sections"`
+			} `json:"card"`
+		} `json:"cardsV2"`
+		Thread struct {
+			ThreadKey string `json:"threadKey"`
+		} `json:"thread"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode message: %v", err)
+	}
+
+	if len(decoded.CardsV2) != 1 {
+		t.Fatalf("Expected 1 cardsV2 card, got %d", len(decoded.CardsV2))
+	}
+	card := decoded.CardsV2[0]
+	if card.CardID != "alert-a1" || card.Card.Header.Subtitle != "web-1" || !strings.Contains(card.Card.Header.Title, "critical") {
+		t.Errorf("Unexpected card: %+v", card)
+	}
+	if len(card.Card.Sections) != 2 {
+		t.Fatalf("Expected details and button sections, got %d", len(card.Card.Sections))
+	}
+	details := card.Card.Sections[0].Widgets
+	var paragraph struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(details[0]["textParagraph"], &paragraph); err != nil {
+		t.Fatalf("Failed to decode text paragraph: %v", err)
+	}
+	if !strings.Contains(paragraph.Text, "Agent: web-1<br>CPU: 97% &gt; 80%") {
+		t.Errorf("Expected escaped message with line breaks, got %q", paragraph.Text)
+	}
+	if _, ok := details[1]["decoratedText"]; !ok {
+		t.Errorf("Expected decoratedText widgets, got %v", details[1])
+	}
+	if !strings.Contains(string(card.Card.Sections[1].Widgets[0]["buttonList"]), "https://saviour.example.com") {
+		t.Errorf("Expected dashboard button, got %v", card.Card.Sections[1].Widgets[0])
+	}
+	if decoded.Thread.ThreadKey != "alert-web-1-system_cpu_high" {
+		t.Errorf("Unexpected thread key %q", decoded.Thread.ThreadKey)
+	}
+}
+
+func TestGoogleChatNotifier_Annotations(t *testing.T) {
+	notifier := NewGoogleChatNotifier("https://chat.example.com/webhook", "https://saviour.example.com")
+	message := notifier.buildMessage(&Alert{
+		ID:        "a1",
+		AgentName: "billing",
+		AlertType: "queue_backlog",
+		Severity:  "warning",
+		Annotations: map[string]string{
+			"runbook_url": "https://wiki.example.com/queues",
+			"team":        "billing",
+			"priority":    "P2",
+		},
+	})
+
+	data, _ := json.Marshal(message)
+	card := string(data)
+	for _, want := range []string{`"text":"P2","topLabel":"Priority"`, `"text":"billing","topLabel":"Team"`} {
+		if !strings.Contains(card, want) {
+			t.Errorf("Expected card to contain %s, got %s", want, card)
+		}
+	}
+	runbook := strings.Index(card, `"text":"Open Runbook"`)
+	dashboard := strings.Index(card, `"text":"View Dashboard"`)
+	if runbook < 0 || dashboard < runbook {
+		t.Errorf("Expected runbook button before dashboard button, got %s", card)
+	}
+	if strings.Contains(card, `"topLabel":"Runbook url"`) {
+		t.Error("Expected the runbook only as a button")
+	}
+}
+
+func TestGoogleChatNotifier_Location(t *testing.T) {
+	notifier := NewGoogleChatNotifier("https://chat.example.com/webhook", "")
+	notifier.SetLocation(time.FixedZone("CEST", 2*60*60))
+	message := notifier.buildMessage(&Alert{ID: "a1", TriggeredAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)})
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatalf("Failed to marshal message: %v", err)
+	}
+	if !strings.Contains(string(data), "2024-05-01 14:00:00 CEST") {
+		t.Errorf("Expected trigger time in the notifier's time zone, got %s", data)
+	}
+}
+
+func TestGoogleChatNotifier_RetriesRateLimited(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("messageReplyOption") != "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD" {
+			t.Errorf("Expected threaded reply option, got query %q", r.URL.RawQuery)
+		}
+		if requests.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	notifier := NewGoogleChatNotifier(server.URL+"?key=k", "")
+	var waits []time.Duration
+	notifier.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	if err := notifier.SendAlert(&Alert{ID: "a1", AgentName: "web-1", AlertType: "agent_offline", Severity: "critical"}); err != nil {
+		t.Fatalf("SendAlert() error = %v", err)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests.Load())
+	}
+	if len(waits) != 2 || waits[0] != 7*time.Second {
+		t.Errorf("Expected two waits of Retry-After, got %v", waits)
+	}
+}
+
+func TestGoogleChatNotifier_GivesUp(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	notifier := NewGoogleChatNotifier(server.URL, "")
+	var waits []time.Duration
+	notifier.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	if err := notifier.SendReport("Daily report", "all good"); err == nil {
+		t.Fatal("Expected an error once the retries are used up")
+	}
+	if requests.Load() != maxPostAttempts {
+		t.Errorf("Expected %d requests, got %d", maxPostAttempts, requests.Load())
+	}
+	// Without Retry-After the wait doubles
+	if len(waits) != 3 || waits[0] != initialRetryWait || waits[2] != 4*initialRetryWait {
+		t.Errorf("Unexpected waits %v", waits)
+	}
+
+	// Other errors aren't retried
+	requests.Store(0)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	notifier.webhookURL = failing.URL
+	if err := notifier.SendReport("Daily report", "all good"); err == nil || requests.Load() != 1 {
+		t.Errorf("Expected one failed request, got %d and %v", requests.Load(), err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"Wed, 01 May 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Wed, 01 May 2024 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGoogleChatNotifier_CheckChannel(t *testing.T) {
+	tests := []struct {
+		status  int
+		healthy bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusForbidden, false},
+		{http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+		err := NewGoogleChatNotifier(server.URL, "").CheckChannel()
+		server.Close()
+
+		if (err == nil) != tt.healthy {
+			t.Errorf("Status %d: expected healthy=%v, got error %v", tt.status, tt.healthy, err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package alerting
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Rule raises an alert for every custom metric series that matches it and
+// crosses its threshold
+type Rule struct {
+	Name      string            // Also the type of the alerts it raises
+	Metric    string            // Custom metric name
+	Agent     string            // Agent name or glob pattern, empty for any
+	Service   string            // Service name or glob pattern, empty for any
+	Labels    map[string]string // Labels the series must carry
+	Operator  string            // One of RuleOperators
+	Threshold float64
+	Severity  string // critical, warning or info
+	Message   string // Empty to use the rule name
+
+	// Annotations attached to the alerts the rule raises, e.g. runbook_url
+	Annotations map[string]string
+
+	// DryRun records the alerts the rule would raise, see DryRunAlerts,
+	// without raising or notifying them, to tune a new rule against real
+	// traffic
+	DryRun bool
+}
+
+// maxDryRunAlerts is how many would-have-fired alerts the engine keeps
+const maxDryRunAlerts = 1000
+
+// RuleOperators are the comparisons a rule can make against its threshold
+var RuleOperators = []string{">", ">=", "<", "<=", "==", "!="}
+
+// CustomMetric is an application metric pushed to the server, which rules
+// are evaluated against
+type CustomMetric struct {
+	Name      string
+	Type      string // gauge or counter
+	Value     float64
+	Labels    map[string]string
+	AgentName string // Set for metrics of an agent
+	Service   string // Set for metrics of a logical service
+	UpdatedAt time.Time
+}
+
+// Owner is the agent or service the metric belongs to
+func (m CustomMetric) Owner() string {
+	if m.Service != "" {
+		return m.Service
+	}
+	return m.AgentName
+}
+
+// Series describes the metric by name and labels, e.g. queue_depth{queue="jobs"}
+func (m CustomMetric) Series() string {
+	if len(m.Labels) == 0 {
+		return m.Name
+	}
+	pairs := make([]string, 0, len(m.Labels))
+	for k, v := range m.Labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(pairs)
+	return m.Name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Matches reports whether the rule applies to a metric series
+func (r *Rule) Matches(m CustomMetric) bool {
+	if m.Name != r.Metric {
+		return false
+	}
+	if r.Agent != "" {
+		if matched, _ := filepath.Match(r.Agent, m.AgentName); !matched || m.AgentName == "" {
+			return false
+		}
+	}
+	if r.Service != "" {
+		if matched, _ := filepath.Match(r.Service, m.Service); !matched || m.Service == "" {
+			return false
+		}
+	}
+	for k, v := range r.Labels {
+		if m.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Fires reports whether a value crosses the rule's threshold
+func (r *Rule) Fires(value float64) bool {
+	switch r.Operator {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	case "==":
+		return value == r.Threshold
+	case "!=":
+		return value != r.Threshold
+	}
+	return false
+}
+
+// RuleFiring is a rule crossing its threshold for one metric series
+type RuleFiring struct {
+	Rule   *Rule
+	Metric CustomMetric
+}
+
+// Alert builds the alert the firing raises
+func (f RuleFiring) Alert() *Alert {
+	return ruleAlert(f.Rule, f.Metric)
+}
+
+// EvaluateRules returns every metric series crossing the threshold of a rule,
+// by series and then in rule order. Dry-run rules are included.
+func EvaluateRules(rules []Rule, metrics []CustomMetric) []RuleFiring {
+	var firings []RuleFiring
+	for _, metric := range metrics {
+		for i := range rules {
+			rule := &rules[i]
+			if rule.Matches(metric) && rule.Fires(metric.Value) {
+				firings = append(firings, RuleFiring{Rule: rule, Metric: metric})
+			}
+		}
+	}
+	return firings
+}
+
+// checkRules raises an alert for every custom metric series crossing the
+// threshold of a rule. Series of agents in maintenance are skipped.
+func (e *Engine) checkRules() {
+	rules := e.cfg().Rules
+	if len(rules) == 0 {
+		return
+	}
+
+	var metrics []CustomMetric
+	for _, metric := range e.state.CustomMetrics() {
+		if metric.AgentName != "" {
+			if agent, exists := e.state.GetAgent(metric.AgentName); exists && agent.InMaintenance {
+				continue
+			}
+		}
+		metrics = append(metrics, metric)
+	}
+
+	for _, firing := range EvaluateRules(rules, metrics) {
+		rule, metric := firing.Rule, firing.Metric
+		alertKey := fmt.Sprintf("rule:%s:%s:%s", rule.Name, metric.Owner(), metric.Series())
+		if rule.DryRun {
+			// Deduplicated like the real alert would be, under a key of its
+			// own so the first real alert isn't held back once the rule goes
+			// live
+			alertKey = "dry_run:" + alertKey
+			if e.shouldSendAlert(alertKey) {
+				e.recordDryRun(firing.Alert())
+				e.markAlertSent(alertKey)
+			}
+			continue
+		}
+		if e.shouldSendAlert(alertKey) {
+			e.sendAlert(firing.Alert(), alertKey)
+		}
+	}
+}
+
+// recordDryRun keeps an alert a dry-run rule would have raised, dropping the
+// oldest beyond maxDryRunAlerts
+func (e *Engine) recordDryRun(alert *Alert) {
+	e.annotate(alert)
+	alert.Status = "dry_run"
+	e.logger.Info("Dry-run rule would have fired", "rule", alert.AlertType, "agent", alert.AgentName)
+
+	e.dryRunMu.Lock()
+	defer e.dryRunMu.Unlock()
+	if len(e.dryRuns) == maxDryRunAlerts {
+		copy(e.dryRuns, e.dryRuns[1:])
+		e.dryRuns = e.dryRuns[:len(e.dryRuns)-1]
+	}
+	e.dryRuns = append(e.dryRuns, *alert)
+}
+
+// DryRunAlerts returns the alerts dry-run rules would have raised, newest
+// first. They are kept in memory, so only since the server started.
+func (e *Engine) DryRunAlerts() []Alert {
+	e.dryRunMu.Lock()
+	defer e.dryRunMu.Unlock()
+
+	alerts := make([]Alert, len(e.dryRuns))
+	for i, alert := range e.dryRuns {
+		alerts[len(alerts)-1-i] = alert
+	}
+	return alerts
+}
+
+// ruleAlert builds the alert a rule raises for a metric series
+func ruleAlert(rule *Rule, metric CustomMetric) *Alert {
+	message := rule.Message
+	if message == "" {
+		message = rule.Name
+	}
+	owner := "Agent: " + metric.AgentName
+	details := map[string]interface{}{
+		"rule":      rule.Name,
+		"metric":    metric.Name,
+		"value":     metric.Value,
+		"operator":  rule.Operator,
+		"threshold": rule.Threshold,
+	}
+	if metric.Service != "" {
+		owner = "Service: " + metric.Service
+		details["service"] = metric.Service
+	} else {
+		details["agent_name"] = metric.AgentName
+	}
+	if len(metric.Labels) > 0 {
+		details["labels"] = metric.Labels
+	}
+
+	return &Alert{
+		ID:          uuid.New().String(),
+		AgentName:   metric.Owner(),
+		AlertType:   rule.Name,
+		Severity:    rule.Severity,
+		Message:     fmt.Sprintf("%s %s\n%s\nMetric: %s = %g (%s %g)", severityIcon(rule.Severity), message, owner, metric.Series(), metric.Value, rule.Operator, rule.Threshold),
+		Details:     details,
+		TriggeredAt: time.Now(),
+		Status:      "active",
+		Annotations: rule.Annotations,
+	}
+}
@@ -0,0 +1,193 @@
+package alerting
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRule_Matches(t *testing.T) {
+	rule := &Rule{Name: "backlog", Metric: "queue_depth", Service: "billing*", Labels: map[string]string{"queue": "invoices"}}
+
+	tests := []struct {
+		name   string
+		metric CustomMetric
+		want   bool
+	}{
+		{"matching", CustomMetric{Name: "queue_depth", Service: "billing-eu", Labels: map[string]string{"queue": "invoices", "region": "eu"}}, true},
+		{"other metric", CustomMetric{Name: "queue_age", Service: "billing", Labels: map[string]string{"queue": "invoices"}}, false},
+		{"other service", CustomMetric{Name: "queue_depth", Service: "search", Labels: map[string]string{"queue": "invoices"}}, false},
+		{"agent metric", CustomMetric{Name: "queue_depth", AgentName: "billing-1", Labels: map[string]string{"queue": "invoices"}}, false},
+		{"other label", CustomMetric{Name: "queue_depth", Service: "billing", Labels: map[string]string{"queue": "refunds"}}, false},
+		{"no labels", CustomMetric{Name: "queue_depth", Service: "billing"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := rule.Matches(tt.metric); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Without agent, service or labels every series of the metric matches
+	any := &Rule{Metric: "queue_depth"}
+	if !any.Matches(CustomMetric{Name: "queue_depth", AgentName: "web-1"}) {
+		t.Error("Expected rule without matchers to match every series of the metric")
+	}
+}
+
+func TestRule_Fires(t *testing.T) {
+	tests := []struct {
+		operator string
+		value    float64
+		want     bool
+	}{
+		{">", 11, true},
+		{">", 10, false},
+		{">=", 10, true},
+		{"<", 9, true},
+		{"<", 10, false},
+		{"<=", 10, true},
+		{"==", 10, true},
+		{"!=", 10, false},
+		{"~", 10, false},
+	}
+
+	for _, tt := range tests {
+		rule := &Rule{Operator: tt.operator, Threshold: 10}
+		if got := rule.Fires(tt.value); got != tt.want {
+			t.Errorf("%g %s 10 = %v, want %v", tt.value, tt.operator, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	state := NewMockStateStore()
+	state.agents = append(state.agents, &ServerState{AgentName: "web-1", Status: "online"})
+	state.agents = append(state.agents, &ServerState{AgentName: "web-2", Status: "online", InMaintenance: true})
+	state.customMetrics = []CustomMetric{
+		{Name: "queue_depth", Service: "billing", Value: 1500, Labels: map[string]string{"queue": "invoices"}},
+		{Name: "queue_depth", Service: "billing", Value: 20, Labels: map[string]string{"queue": "refunds"}},
+		{Name: "active_sessions", AgentName: "web-1", Value: 0},
+		{Name: "active_sessions", AgentName: "web-2", Value: 0},
+	}
+	notifier := NewMockNotifier()
+	engine := NewEngine(state, &Config{
+		Enabled:              true,
+		DeduplicationEnabled: true,
+		DeduplicationWindow:  5 * time.Minute,
+		Rules: []Rule{
+			{Name: "queue_backlog", Metric: "queue_depth", Operator: ">", Threshold: 1000, Severity: "critical", Message: "Queue is backing up"},
+			{Name: "no_sessions", Metric: "active_sessions", Agent: "web-*", Operator: "==", Threshold: 0, Severity: "warning"},
+		},
+	}, notifier)
+
+	engine.checkRules()
+
+	if len(notifier.sentAlerts) != 2 {
+		t.Fatalf("Expected 2 alerts, got %d", len(notifier.sentAlerts))
+	}
+	backlog := notifier.sentAlerts[0]
+	if backlog.AlertType != "queue_backlog" || backlog.AgentName != "billing" || backlog.Severity != "critical" {
+		t.Errorf("Unexpected alert: %+v", backlog)
+	}
+	if !strings.Contains(backlog.Message, "Queue is backing up") || !strings.Contains(backlog.Message, `queue_depth{queue="invoices"} = 1500 (> 1000)`) {
+		t.Errorf("Expected message to include the summary and series, got %q", backlog.Message)
+	}
+	if backlog.Details["service"] != "billing" || backlog.Details["value"] != 1500.0 {
+		t.Errorf("Unexpected details: %v", backlog.Details)
+	}
+
+	// The agent in maintenance is skipped
+	sessions := notifier.sentAlerts[1]
+	if sessions.AlertType != "no_sessions" || sessions.AgentName != "web-1" || !strings.Contains(sessions.Message, "Agent: web-1") {
+		t.Errorf("Unexpected alert: %+v", sessions)
+	}
+
+	// Firing again within the deduplication window doesn't repeat them
+	engine.checkRules()
+	if len(notifier.sentAlerts) != 2 {
+		t.Errorf("Expected repeats to be deduplicated, got %d alerts", len(notifier.sentAlerts))
+	}
+}
+
+func TestCheckRules_DryRun(t *testing.T) {
+	state := NewMockStateStore()
+	state.customMetrics = []CustomMetric{
+		{Name: "queue_depth", Service: "billing", Value: 1500},
+		{Name: "queue_depth", Service: "search", Value: 50},
+	}
+	notifier := NewMockNotifier()
+	engine := NewEngine(state, &Config{
+		Enabled:              true,
+		DeduplicationEnabled: true,
+		DeduplicationWindow:  5 * time.Minute,
+		Rules: []Rule{
+			{Name: "queue_backlog", Metric: "queue_depth", Operator: ">", Threshold: 100, Severity: "warning", DryRun: true},
+		},
+	}, notifier)
+
+	engine.checkRules()
+	engine.checkRules()
+
+	if len(notifier.sentAlerts) != 0 || len(state.alerts) != 0 {
+		t.Errorf("Expected no alerts raised by a dry-run rule, got %d sent and %d recorded", len(notifier.sentAlerts), len(state.alerts))
+	}
+	dryRuns := engine.DryRunAlerts()
+	if len(dryRuns) != 1 {
+		t.Fatalf("Expected 1 deduplicated dry-run alert, got %d", len(dryRuns))
+	}
+	if dryRuns[0].AgentName != "billing" || dryRuns[0].AlertType != "queue_backlog" || dryRuns[0].Status != "dry_run" {
+		t.Errorf("Unexpected dry-run alert: %+v", dryRuns[0])
+	}
+
+	// Going live notifies right away rather than waiting out the window
+	engine.Reconfigure(func(c *Config) {
+		c.Rules = []Rule{{Name: "queue_backlog", Metric: "queue_depth", Operator: ">", Threshold: 100, Severity: "warning"}}
+	})
+	engine.checkRules()
+	if len(notifier.sentAlerts) != 1 {
+		t.Errorf("Expected the live rule to notify, got %d alerts", len(notifier.sentAlerts))
+	}
+}
+
+func TestDryRunAlerts_Limit(t *testing.T) {
+	engine := NewEngine(NewMockStateStore(), &Config{}, NewMockNotifier())
+	for i := 0; i < maxDryRunAlerts+5; i++ {
+		engine.recordDryRun(&Alert{ID: fmt.Sprint(i)})
+	}
+
+	alerts := engine.DryRunAlerts()
+	if len(alerts) != maxDryRunAlerts {
+		t.Fatalf("Expected %d alerts, got %d", maxDryRunAlerts, len(alerts))
+	}
+	if alerts[0].ID != fmt.Sprint(maxDryRunAlerts+4) || alerts[len(alerts)-1].ID != "5" {
+		t.Errorf("Expected newest first and the oldest dropped, got %s..%s", alerts[0].ID, alerts[len(alerts)-1].ID)
+	}
+}
+
+func TestEvaluateRules(t *testing.T) {
+	rules := []Rule{
+		{Name: "backlog", Metric: "queue_depth", Operator: ">", Threshold: 1000},
+		{Name: "empty", Metric: "queue_depth", Operator: "==", Threshold: 0, DryRun: true},
+	}
+	metrics := []CustomMetric{
+		{Name: "queue_depth", Service: "billing", Value: 1500},
+		{Name: "queue_depth", Service: "search", Value: 0},
+		{Name: "queue_depth", Service: "mail", Value: 10},
+	}
+
+	firings := EvaluateRules(rules, metrics)
+	if len(firings) != 2 {
+		t.Fatalf("Expected 2 firings, got %d", len(firings))
+	}
+	if firings[0].Rule.Name != "backlog" || firings[0].Metric.Service != "billing" {
+		t.Errorf("Expected backlog for billing first, got %s for %s", firings[0].Rule.Name, firings[0].Metric.Owner())
+	}
+	if firings[1].Rule.Name != "empty" || firings[1].Metric.Service != "search" {
+		t.Errorf("Expected the dry-run rule for search second, got %s for %s", firings[1].Rule.Name, firings[1].Metric.Owner())
+	}
+	if alert := firings[0].Alert(); alert.AlertType != "backlog" || alert.AgentName != "billing" {
+		t.Errorf("Unexpected alert: %+v", alert)
+	}
+}
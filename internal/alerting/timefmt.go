@@ -0,0 +1,66 @@
+package alerting
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeLayout is how notifications show a point in time
+const TimeLayout = "2006-01-02 15:04:05 MST"
+
+// LoadTimezone returns the IANA time zone to show notification times in,
+// such as "Europe/Berlin". Empty or "Local" is the server's time zone.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// formatTime shows t in loc, or in UTC if loc is nil
+func formatTime(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(TimeLayout)
+}
+
+// Ago describes how long before now t was, e.g. "4m ago"
+func Ago(t, now time.Time) string {
+	d := now.Sub(t)
+	if d < time.Minute {
+		return "just now"
+	}
+	return HumanizeDuration(d) + " ago"
+}
+
+// HumanizeDuration describes d in its two largest units, e.g. "45s", "4m",
+// "1h 5m" or "3d 2h"
+func HumanizeDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	const day = 24 * time.Hour
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < day:
+		return joinUnits(int(d/time.Hour), "h", int(d%time.Hour/time.Minute), "m")
+	default:
+		return joinUnits(int(d/day), "d", int(d%day/time.Hour), "h")
+	}
+}
+
+// joinUnits writes a major and minor unit, leaving out a zero minor one
+func joinUnits(major int, majorUnit string, minor int, minorUnit string) string {
+	if minor == 0 {
+		return fmt.Sprintf("%d%s", major, majorUnit)
+	}
+	return fmt.Sprintf("%d%s %d%s", major, majorUnit, minor, minorUnit)
+}
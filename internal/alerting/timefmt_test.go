@@ -0,0 +1,55 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHumanizeDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{45 * time.Second, "45s"},
+		{4*time.Minute + 30*time.Second, "4m"},
+		{time.Hour, "1h"},
+		{time.Hour + 5*time.Minute, "1h 5m"},
+		{3*24*time.Hour + 2*time.Hour + 10*time.Minute, "3d 2h"},
+		{-2 * time.Minute, "2m"},
+	}
+
+	for _, tt := range tests {
+		if got := HumanizeDuration(tt.d); got != tt.want {
+			t.Errorf("HumanizeDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestAgo(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if got := Ago(now.Add(-4*time.Minute), now); got != "4m ago" {
+		t.Errorf("Expected '4m ago', got %q", got)
+	}
+	if got := Ago(now.Add(-10*time.Second), now); got != "just now" {
+		t.Errorf("Expected 'just now', got %q", got)
+	}
+}
+
+func TestLoadTimezone(t *testing.T) {
+	loc, err := LoadTimezone("")
+	if err != nil || loc != time.Local {
+		t.Errorf("Expected server local time for an empty name, got %v (%v)", loc, err)
+	}
+
+	loc, err = LoadTimezone("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("LoadTimezone failed: %v", err)
+	}
+	if got := formatTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), loc); got != "2024-05-01 21:00:00 JST" {
+		t.Errorf("Expected Tokyo time, got %q", got)
+	}
+
+	if _, err := LoadTimezone("Mars/Olympus_Mons"); err == nil {
+		t.Error("Expected error for unknown timezone")
+	}
+}
@@ -0,0 +1,224 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Payload formats of the webhook notifier
+const (
+	// WebhookFormatSaviour posts the alert as the API returns it
+	WebhookFormatSaviour = "saviour"
+
+	// WebhookFormatAlertmanager posts Prometheus Alertmanager's webhook
+	// payload, for receivers built for Alertmanager
+	WebhookFormatAlertmanager = "alertmanager"
+)
+
+// WebhookFormats are the payload formats the webhook notifier can post
+var WebhookFormats = []string{WebhookFormatSaviour, WebhookFormatAlertmanager}
+
+// WebhookNotifier posts alerts as JSON to an HTTP endpoint
+type WebhookNotifier struct {
+	url         string
+	format      string
+	headers     map[string]string
+	externalURL string // Link back to Saviour, see SetExternalURL
+	httpClient  *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting alerts to url in one of
+// WebhookFormats, with headers such as Authorization added to each request
+func NewWebhookNotifier(url, format string, headers map[string]string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:        url,
+		format:     format,
+		headers:    headers,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// SetExternalURL sets the link back to Saviour, e.g. the dashboard, sent as
+// externalURL and generatorURL in the Alertmanager format
+func (w *WebhookNotifier) SetExternalURL(url string) {
+	w.externalURL = url
+}
+
+// SendAlert posts the alert, failing unless the endpoint answers 2xx
+func (w *WebhookNotifier) SendAlert(alert *Alert) error {
+	var message interface{} = webhookAlertOf(alert)
+	if w.format == WebhookFormatAlertmanager {
+		message = w.alertmanagerMessage(alert)
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookAlert is an alert in the saviour format, as the API returns it
+type webhookAlert struct {
+	ID          string                 `json:"id"`
+	AgentName   string                 `json:"agent_name"`
+	AlertType   string                 `json:"alert_type"`
+	Severity    string                 `json:"severity"`
+	Message     string                 `json:"message"`
+	Details     map[string]interface{} `json:"details"`
+	TriggeredAt time.Time              `json:"triggered_at"`
+	ResolvedAt  *time.Time             `json:"resolved_at,omitempty"`
+	Status      string                 `json:"status"`
+	Annotations map[string]string      `json:"annotations,omitempty"`
+}
+
+func webhookAlertOf(alert *Alert) webhookAlert {
+	return webhookAlert{
+		ID:          alert.ID,
+		AgentName:   alert.AgentName,
+		AlertType:   alert.AlertType,
+		Severity:    alert.Severity,
+		Message:     alert.Message,
+		Details:     alert.Details,
+		TriggeredAt: alert.TriggeredAt,
+		ResolvedAt:  alert.ResolvedAt,
+		Status:      alert.Status,
+		Annotations: alert.Annotations,
+	}
+}
+
+// alertmanagerMessage is Alertmanager's webhook payload, version 4
+type alertmanagerMessage struct {
+	Version           string              `json:"version"`
+	GroupKey          string              `json:"groupKey"`
+	TruncatedAlerts   int                 `json:"truncatedAlerts"`
+	Status            string              `json:"status"` // firing or resolved
+	Receiver          string              `json:"receiver"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	Alerts            []alertmanagerAlert `json:"alerts"`
+}
+
+type alertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"` // Zero while firing
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// alertmanagerMessage builds the Alertmanager payload for an alert, as a
+// group of one grouped by alertname. The alert type, agent and severity
+// become labels; the first line of the message is the summary annotation
+// and the whole message the description, next to the alert's annotations.
+func (w *WebhookNotifier) alertmanagerMessage(alert *Alert) alertmanagerMessage {
+	labels := map[string]string{
+		"alertname": alert.AlertType,
+		"agent":     alert.AgentName,
+		"severity":  alert.Severity,
+	}
+
+	summary, _, _ := strings.Cut(alert.Message, "\n")
+	annotations := map[string]string{
+		"summary":     summary,
+		"description": alert.Message,
+	}
+	for k, v := range alert.Annotations {
+		annotations[k] = v
+	}
+
+	status := "firing"
+	var endsAt time.Time
+	if alert.Status == "resolved" {
+		status = "resolved"
+		if alert.ResolvedAt != nil {
+			endsAt = *alert.ResolvedAt
+		}
+	}
+
+	return alertmanagerMessage{
+		Version:           "4",
+		GroupKey:          fmt.Sprintf("{}:{alertname=%q}", alert.AlertType),
+		Status:            status,
+		Receiver:          "saviour",
+		GroupLabels:       map[string]string{"alertname": alert.AlertType},
+		CommonLabels:      labels,
+		CommonAnnotations: annotations,
+		ExternalURL:       w.externalURL,
+		Alerts: []alertmanagerAlert{{
+			Status:       status,
+			Labels:       labels,
+			Annotations:  annotations,
+			StartsAt:     alert.TriggeredAt,
+			EndsAt:       endsAt,
+			GeneratorURL: w.externalURL,
+			Fingerprint:  fingerprint(labels),
+		}},
+	}
+}
+
+// fingerprint identifies an alert by its labels, like Alertmanager's
+func fingerprint(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0xff})
+		h.Write([]byte(labels[k]))
+		h.Write([]byte{0xff})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// Notifiers sends each alert to several notifiers, such as Google Chat and
+// a webhook. Sending fails only if every notifier fails, so one broken
+// channel doesn't make the engine resend the alert through the others; watch
+// each with a ChannelMonitor to notice it.
+type Notifiers []Notifier
+
+// SendAlert sends the alert to every notifier
+func (n Notifiers) SendAlert(alert *Alert) error {
+	var errs []error
+	for _, notifier := range n {
+		if err := notifier.SendAlert(alert); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == len(n) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
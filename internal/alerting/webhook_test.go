@@ -0,0 +1,136 @@
+package alerting
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the requests a webhook notifier makes
+func webhookReceiver(t *testing.T, status int) (*httptest.Server, *[]*http.Request, *[][]byte) {
+	t.Helper()
+	var requests []*http.Request
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests, &bodies
+}
+
+func TestWebhookNotifier_Saviour(t *testing.T) {
+	srv, requests, bodies := webhookReceiver(t, http.StatusNoContent)
+	notifier := NewWebhookNotifier(srv.URL, WebhookFormatSaviour, map[string]string{"Authorization": "Bearer secret"}, time.Second)
+
+	err := notifier.SendAlert(&Alert{
+		ID:          "a1",
+		AgentName:   "web-1",
+		AlertType:   "system_cpu_high",
+		Severity:    "critical",
+		Message:     "High CPU",
+		TriggeredAt: time.Now(),
+		Status:      "active",
+		Annotations: map[string]string{"team": "platform"},
+	})
+	if err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(*requests))
+	}
+	req := (*requests)[0]
+	if req.Header.Get("Authorization") != "Bearer secret" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected headers: %v", req.Header)
+	}
+	var got webhookAlert
+	if err := json.Unmarshal((*bodies)[0], &got); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if got.ID != "a1" || got.AlertType != "system_cpu_high" || got.Annotations["team"] != "platform" {
+		t.Errorf("Unexpected payload: %+v", got)
+	}
+}
+
+func TestWebhookNotifier_Alertmanager(t *testing.T) {
+	srv, _, bodies := webhookReceiver(t, http.StatusOK)
+	notifier := NewWebhookNotifier(srv.URL, WebhookFormatAlertmanager, nil, time.Second)
+	notifier.SetExternalURL("https://saviour.example.com")
+
+	triggered := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	resolved := triggered.Add(time.Hour)
+	alerts := []*Alert{
+		{AgentName: "db-1", AlertType: "system_disk_high", Severity: "warning", Message: "⚠️ Disk filling up\nAgent: db-1",
+			TriggeredAt: triggered, Status: "active", Annotations: map[string]string{"runbook_url": "https://wiki.example.com/disk"}},
+		{AgentName: "db-1", AlertType: "system_disk_high", Severity: "warning", Message: "Disk OK",
+			TriggeredAt: triggered, ResolvedAt: &resolved, Status: "resolved"},
+	}
+	for _, alert := range alerts {
+		if err := notifier.SendAlert(alert); err != nil {
+			t.Fatalf("SendAlert failed: %v", err)
+		}
+	}
+
+	var firing, cleared alertmanagerMessage
+	json.Unmarshal((*bodies)[0], &firing)
+	json.Unmarshal((*bodies)[1], &cleared)
+
+	if firing.Version != "4" || firing.Status != "firing" || firing.GroupKey != `{}:{alertname="system_disk_high"}` {
+		t.Errorf("Unexpected message: %+v", firing)
+	}
+	if firing.ExternalURL != "https://saviour.example.com" || firing.GroupLabels["alertname"] != "system_disk_high" {
+		t.Errorf("Unexpected message: %+v", firing)
+	}
+	if len(firing.Alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(firing.Alerts))
+	}
+	alert := firing.Alerts[0]
+	if alert.Labels["alertname"] != "system_disk_high" || alert.Labels["agent"] != "db-1" || alert.Labels["severity"] != "warning" {
+		t.Errorf("Unexpected labels: %v", alert.Labels)
+	}
+	if alert.Annotations["summary"] != "⚠️ Disk filling up" || alert.Annotations["runbook_url"] != "https://wiki.example.com/disk" {
+		t.Errorf("Unexpected annotations: %v", alert.Annotations)
+	}
+	if !alert.StartsAt.Equal(triggered) || !alert.EndsAt.IsZero() || len(alert.Fingerprint) != 16 {
+		t.Errorf("Unexpected alert: %+v", alert)
+	}
+
+	if cleared.Status != "resolved" || cleared.Alerts[0].Status != "resolved" || !cleared.Alerts[0].EndsAt.Equal(resolved) {
+		t.Errorf("Expected a resolved alert ending at %v, got %+v", resolved, cleared)
+	}
+	// Same labels, same alert
+	if cleared.Alerts[0].Fingerprint != alert.Fingerprint {
+		t.Errorf("Expected fingerprint %s, got %s", alert.Fingerprint, cleared.Alerts[0].Fingerprint)
+	}
+}
+
+func TestWebhookNotifier_Fails(t *testing.T) {
+	srv, _, _ := webhookReceiver(t, http.StatusBadGateway)
+	notifier := NewWebhookNotifier(srv.URL, WebhookFormatSaviour, nil, time.Second)
+
+	if err := notifier.SendAlert(&Alert{AlertType: "test"}); err == nil {
+		t.Error("Expected an error for a 502 response")
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	working, broken := NewMockNotifier(), &MockNotifier{shouldFail: true}
+
+	// One channel taking the alert is enough
+	if err := (Notifiers{broken, working}).SendAlert(&Alert{AlertType: "test"}); err != nil {
+		t.Errorf("Expected no error while one notifier works, got %v", err)
+	}
+	if len(working.sentAlerts) != 1 {
+		t.Errorf("Expected the working notifier to get the alert, got %d", len(working.sentAlerts))
+	}
+
+	if err := (Notifiers{broken, broken}).SendAlert(&Alert{AlertType: "test"}); err == nil {
+		t.Error("Expected an error when every notifier fails")
+	}
+}
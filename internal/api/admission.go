@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AdmissionMiddleware caps the number of requests handled at once. Beyond
+// the limit it answers 429 with Retry-After straight away, so a server that
+// falls behind sheds load instead of queueing requests until memory runs
+// out. Agents already retry 429s with backoff. A limit of 0 or less
+// disables the check.
+func AdmissionMiddleware(limit int, retryAfter time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+
+		inFlight := make(chan struct{}, limit)
+		retrySeconds := strconv.Itoa(int((retryAfter + time.Second - 1) / time.Second))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case inFlight <- struct{}{}:
+				defer func() { <-inFlight }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", retrySeconds)
+				http.Error(w, "Server busy, retry later", http.StatusTooManyRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdmissionMiddleware(t *testing.T) {
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(2)
+
+	handler := AdmissionMiddleware(2, 1500*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Done()
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Fill both slots
+	var done sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		done.Add(1)
+		go func(i int) {
+			defer done.Done()
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/metrics/push", nil))
+			codes[i] = rec.Code
+		}(i)
+	}
+	started.Wait()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/metrics/push", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 beyond the limit, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After 2 (rounded up), got %q", got)
+	}
+
+	close(release)
+	done.Wait()
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("Request %d: expected status 200, got %d", i, code)
+		}
+	}
+
+	// Slots are released once requests finish
+	started.Add(1)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/metrics/push", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 after requests finished, got %d", rec.Code)
+	}
+}
+
+func TestAdmissionMiddleware_Disabled(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	handler := AdmissionMiddleware(0, time.Second)(next)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with no limit, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/alerting"
+	"github.com/anurag/saviour/internal/logging"
+)
+
+// alertmanagerSource is the source of alerts received from Alertmanager
+const alertmanagerSource = "alertmanager"
+
+// alertmanagerWebhook is the payload Alertmanager's webhook_config posts
+type alertmanagerWebhook struct {
+	Version     string                    `json:"version"`
+	Status      string                    `json:"status"`
+	Receiver    string                    `json:"receiver"`
+	ExternalURL string                    `json:"externalURL"`
+	Alerts      []alertmanagerWebhookItem `json:"alerts"`
+}
+
+type alertmanagerWebhookItem struct {
+	Status       string            `json:"status"` // firing or resolved
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// alertmanagerResult counts what became of the alerts of a webhook
+type alertmanagerResult struct {
+	Raised    int `json:"raised"`
+	Duplicate int `json:"duplicate"`
+	Resolved  int `json:"resolved"`
+	Ignored   int `json:"ignored"` // Resolutions of alerts Saviour doesn't have open
+}
+
+// HandleAlertmanagerWebhook handles POST /api/v1/integrations/alertmanager,
+// which receives Alertmanager's webhook so Prometheus alerts show up and are
+// routed like the server's own. Firing alerts are raised as external alerts
+// and resolved ones resolve the alert they raised, matched by fingerprint.
+func (h *Handler) HandleAlertmanagerWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.raiseExternal == nil {
+		http.Error(w, "Alerting is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxRequestSize)
+	var payload alertmanagerWebhook
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	for _, item := range payload.Alerts {
+		if item.Labels["alertname"] == "" {
+			http.Error(w, "Every alert needs an alertname label", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var result alertmanagerResult
+	for _, item := range payload.Alerts {
+		key := item.Fingerprint
+		if key == "" {
+			key = labelsKey(item.Labels)
+		}
+		open := h.openAlertmanagerAlert(key)
+
+		if item.Status == "resolved" {
+			if open == "" {
+				result.Ignored++
+				continue
+			}
+			h.state.ResolveAlert(open)
+			result.Resolved++
+			continue
+		}
+
+		// Alertmanager repeats firing alerts until they resolve
+		if open != "" {
+			result.Duplicate++
+			continue
+		}
+		if h.raiseExternal(alertmanagerAlertOf(item, key)) == nil {
+			result.Duplicate++
+			continue
+		}
+		result.Raised++
+	}
+
+	h.logger.Info("Alertmanager webhook", "receiver", payload.Receiver, "status", payload.Status,
+		"raised", result.Raised, "resolved", result.Resolved, "duplicate", result.Duplicate)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("Error encoding response", logging.Err(err))
+	}
+}
+
+// openAlertmanagerAlert returns the ID of the unresolved alert raised for
+// an Alertmanager alert, or "" if there is none
+func (h *Handler) openAlertmanagerAlert(key string) string {
+	for _, alert := range h.state.GetAlertsByStatus("all") {
+		if alert.Status != "resolved" && alert.Details["source"] == alertmanagerSource && alert.Details["fingerprint"] == key {
+			return alert.ID
+		}
+	}
+	return ""
+}
+
+// alertmanagerAlertOf converts a firing Alertmanager alert. The alertname
+// becomes the alert type; the agent, host or instance label the host it
+// concerns; the summary or description the message. The remaining
+// annotations, such as runbook_url, are kept as annotations and the labels
+// as details.
+func alertmanagerAlertOf(item alertmanagerWebhookItem, key string) alerting.ExternalAlert {
+	alertName := item.Labels["alertname"]
+
+	agentName := alertmanagerSource
+	for _, label := range []string{"agent", "host", "instance"} {
+		if value := item.Labels[label]; value != "" {
+			agentName = value
+			break
+		}
+	}
+	if host, _, err := net.SplitHostPort(agentName); err == nil {
+		agentName = host // instance is usually host:port
+	}
+
+	message := item.Annotations["summary"]
+	if message == "" {
+		message = item.Annotations["description"]
+	}
+	if message == "" {
+		message = alertName
+	}
+
+	annotations := make(map[string]string, len(item.Annotations))
+	for k, v := range item.Annotations {
+		if k != "summary" && k != "description" {
+			annotations[k] = v
+		}
+	}
+
+	details := map[string]interface{}{
+		"fingerprint": key,
+		"starts_at":   item.StartsAt,
+	}
+	if item.GeneratorURL != "" {
+		details["generator_url"] = item.GeneratorURL
+	}
+	if description := item.Annotations["description"]; description != "" && description != message {
+		details["description"] = description
+	}
+	for k, v := range item.Labels {
+		if _, taken := details[k]; !taken {
+			details[k] = v
+		}
+	}
+
+	return alerting.ExternalAlert{
+		Source:      alertmanagerSource,
+		AgentName:   agentName,
+		AlertType:   alertName,
+		Key:         key,
+		Severity:    alertmanagerSeverity(item.Labels["severity"]),
+		Message:     message,
+		Details:     details,
+		Annotations: annotations,
+	}
+}
+
+// alertmanagerSeverity maps a Prometheus severity label onto Saviour's
+func alertmanagerSeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical", "page", "error":
+		return "critical"
+	case "info", "none":
+		return "info"
+	default:
+		return "warning"
+	}
+}
+
+// labelsKey identifies an alert without a fingerprint by its labels
+func labelsKey(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anurag/saviour/internal/alerting"
+	"github.com/anurag/saviour/internal/server"
+)
+
+const alertmanagerFiring = `{
+	"version": "4",
+	"status": "firing",
+	"receiver": "saviour",
+	"alerts": [{
+		"status": "firing",
+		"labels": {"alertname": "HighLatency", "instance": "api-1:9100", "severity": "page", "job": "api"},
+		"annotations": {"summary": "p99 latency above 2s", "runbook_url": "https://wiki.example.com/latency"},
+		"startsAt": "2024-05-01T12:00:00Z",
+		"generatorURL": "http://prometheus:9090/graph",
+		"fingerprint": "a1b2c3"
+	}]
+}`
+
+func postAlertmanager(handler *Handler, body string) (*httptest.ResponseRecorder, alertmanagerResult) {
+	req := httptest.NewRequest("POST", "/api/v1/integrations/alertmanager", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.HandleAlertmanagerWebhook(rec, req)
+	var result alertmanagerResult
+	json.NewDecoder(rec.Body).Decode(&result)
+	return rec, result
+}
+
+func TestHandleAlertmanagerWebhook(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	var raised []alerting.ExternalAlert
+	handler.SetExternalAlerts(func(ext alerting.ExternalAlert) *alerting.Alert {
+		raised = append(raised, ext)
+		ext.Details["source"] = ext.Source // As RaiseExternal records it
+		state.AddAlert(&server.Alert{ID: "am1", AgentName: ext.AgentName, AlertType: ext.AlertType, Details: ext.Details, Status: "active"})
+		return &alerting.Alert{ID: "am1"}
+	})
+
+	rec, result := postAlertmanager(handler, alertmanagerFiring)
+	if rec.Code != http.StatusOK || result.Raised != 1 {
+		t.Fatalf("Expected 1 raised alert with status 200, got %d: %+v", rec.Code, result)
+	}
+	ext := raised[0]
+	if ext.Source != "alertmanager" || ext.AgentName != "api-1" || ext.AlertType != "HighLatency" || ext.Severity != "critical" {
+		t.Errorf("Unexpected external alert: %+v", ext)
+	}
+	if ext.Message != "p99 latency above 2s" || ext.Key != "a1b2c3" {
+		t.Errorf("Unexpected message or key: %q, %q", ext.Message, ext.Key)
+	}
+	if ext.Annotations["runbook_url"] != "https://wiki.example.com/latency" || ext.Annotations["summary"] != "" {
+		t.Errorf("Unexpected annotations: %v", ext.Annotations)
+	}
+	if ext.Details["job"] != "api" || ext.Details["generator_url"] != "http://prometheus:9090/graph" {
+		t.Errorf("Unexpected details: %v", ext.Details)
+	}
+
+	// Alertmanager repeats the alert while it fires
+	_, result = postAlertmanager(handler, alertmanagerFiring)
+	if result.Duplicate != 1 || len(raised) != 1 {
+		t.Errorf("Expected the repeat to be a duplicate, got %+v", result)
+	}
+
+	resolved := strings.ReplaceAll(alertmanagerFiring, `"firing"`, `"resolved"`)
+	_, result = postAlertmanager(handler, resolved)
+	if result.Resolved != 1 {
+		t.Errorf("Expected 1 resolved alert, got %+v", result)
+	}
+	if alert, _ := state.GetAlert("am1"); alert.Status != "resolved" {
+		t.Errorf("Expected the alert to be resolved, got %s", alert.Status)
+	}
+
+	_, result = postAlertmanager(handler, resolved)
+	if result.Ignored != 1 {
+		t.Errorf("Expected a resolution without an open alert to be ignored, got %+v", result)
+	}
+}
+
+func TestHandleAlertmanagerWebhook_Invalid(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	if rec, _ := postAlertmanager(handler, alertmanagerFiring); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 with alerting disabled, got %d", rec.Code)
+	}
+
+	handler.SetExternalAlerts(func(alerting.ExternalAlert) *alerting.Alert {
+		t.Error("Expected invalid payloads not to raise alerts")
+		return nil
+	})
+	tests := map[string]string{
+		"invalid JSON":      `{`,
+		"missing alertname": `{"alerts": [{"status": "firing", "labels": {"instance": "api-1"}}]}`,
+	}
+	for name, body := range tests {
+		if rec, _ := postAlertmanager(handler, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestAlertmanagerAlertOf_Defaults(t *testing.T) {
+	ext := alertmanagerAlertOf(alertmanagerWebhookItem{
+		Labels:      map[string]string{"alertname": "Watchdog"},
+		Annotations: map[string]string{"description": "Always firing"},
+	}, labelsKey(map[string]string{"alertname": "Watchdog"}))
+
+	if ext.AgentName != "alertmanager" || ext.Severity != "warning" || ext.Message != "Always firing" {
+		t.Errorf("Unexpected external alert: %+v", ext)
+	}
+	if ext.Key != `{alertname="Watchdog"}` {
+		t.Errorf("Expected key from labels, got %s", ext.Key)
+	}
+}
@@ -1,9 +1,13 @@
 package api
 
 import (
-	"log"
+	"context"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
 )
 
 // AuthConfig holds authentication configuration
@@ -18,6 +22,17 @@ type APIKey struct {
 	Scopes []string `json:"scopes"`
 }
 
+// keyNameContextKey is the request context key of the authenticated API
+// key's name
+type keyNameContextKey struct{}
+
+// KeyName returns the name of the API key that authenticated a request,
+// or "" if none did
+func KeyName(ctx context.Context) string {
+	name, _ := ctx.Value(keyNameContextKey{}).(string)
+	return name
+}
+
 // NewAuthConfig creates a new auth configuration
 func NewAuthConfig(keys []APIKey) *AuthConfig {
 	keyMap := make(map[string]APIKey)
@@ -32,11 +47,12 @@ func NewAuthConfig(keys []APIKey) *AuthConfig {
 // AuthMiddleware validates API key from Authorization header
 func (ac *AuthConfig) AuthMiddleware(requiredScopes []string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
+		logger := logging.Component("auth")
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract Authorization header
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				log.Printf("Missing Authorization header from %s", r.RemoteAddr)
+				logger.Warn("Missing Authorization header", "remote_addr", r.RemoteAddr)
 				http.Error(w, "Unauthorized: Missing Authorization header", http.StatusUnauthorized)
 				return
 			}
@@ -44,7 +60,7 @@ func (ac *AuthConfig) AuthMiddleware(requiredScopes []string) func(http.Handler)
 			// Parse Bearer token
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				log.Printf("Invalid Authorization header format from %s", r.RemoteAddr)
+				logger.Warn("Invalid Authorization header format", "remote_addr", r.RemoteAddr)
 				http.Error(w, "Unauthorized: Invalid Authorization header format", http.StatusUnauthorized)
 				return
 			}
@@ -54,23 +70,23 @@ func (ac *AuthConfig) AuthMiddleware(requiredScopes []string) func(http.Handler)
 			// Validate API key
 			key, valid := ac.APIKeys[apiKey]
 			if !valid {
-				log.Printf("Invalid API key from %s", r.RemoteAddr)
+				logger.Warn("Invalid API key", "remote_addr", r.RemoteAddr)
 				http.Error(w, "Unauthorized: Invalid API key", http.StatusUnauthorized)
 				return
 			}
 
 			// Check scopes if required
 			if len(requiredScopes) > 0 && !ac.hasScopes(key.Scopes, requiredScopes) {
-				log.Printf("Insufficient permissions for %s (key: %s)", r.RemoteAddr, key.Name)
+				logger.Warn("Insufficient permissions", "remote_addr", r.RemoteAddr, "key", key.Name)
 				http.Error(w, "Forbidden: Insufficient permissions", http.StatusForbidden)
 				return
 			}
 
 			// Add API key name to request context for logging
-			log.Printf("Authenticated request from %s (key: %s)", r.RemoteAddr, key.Name)
+			logger.Debug("Authenticated request", "remote_addr", r.RemoteAddr, "key", key.Name)
 
 			// Call next handler
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), keyNameContextKey{}, key.Name)))
 		})
 	}
 }
@@ -90,36 +106,72 @@ func (ac *AuthConfig) hasScopes(keyScopes, requiredScopes []string) bool {
 	return true
 }
 
+// Defaults of CORSConfig
+var (
+	DefaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	DefaultCORSHeaders = []string{"Content-Type", "Authorization"}
+)
+
 // CORSConfig holds CORS configuration
 type CORSConfig struct {
+	// Exact origins, or a wildcard subdomain like https://*.corp.example.com
 	AllowedOrigins []string
-	DevMode        bool
+	DevMode        bool // Allow all origins, unless credentials are allowed
+
+	AllowedMethods   []string      // Default: DefaultCORSMethods
+	AllowedHeaders   []string      // Default: DefaultCORSHeaders
+	ExposedHeaders   []string      // Response headers scripts may read
+	AllowCredentials bool          // Let browsers send cookies and auth headers
+	MaxAge           time.Duration // How long browsers may cache a preflight (0 = their default)
 }
 
 // CORSMiddleware handles CORS with configurable origins
 func CORSMiddleware(config *CORSConfig) func(http.Handler) http.Handler {
+	methods, headers := config.AllowedMethods, config.AllowedHeaders
+	if len(methods) == 0 {
+		methods = DefaultCORSMethods
+	}
+	if len(headers) == 0 {
+		headers = DefaultCORSHeaders
+	}
+	allowMethods, allowHeaders := strings.Join(methods, ", "), strings.Join(headers, ", ")
+	exposeHeaders := strings.Join(config.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			origin := r.Header.Get("Origin")
 
-			// In dev mode, allow all origins
-			if config.DevMode {
+			allowed := false
+			if config.DevMode && !config.AllowCredentials {
+				// In dev mode, allow all origins
 				w.Header().Set("Access-Control-Allow-Origin", "*")
-			} else if origin != "" && isAllowedOrigin(origin, config.AllowedOrigins) {
-				// In production, only allow whitelisted origins
-				w.Header().Set("Access-Control-Allow-Origin", origin)
-				w.Header().Set("Vary", "Origin")
+				allowed = true
+			} else {
+				// Only allow whitelisted origins, which is never any origin
+				// when credentials are allowed: that would let every site
+				// read responses as the signed-in user. The response depends
+				// on the origin either way, so caches must key on it.
+				w.Header().Add("Vary", "Origin")
+				if origin != "" && isAllowedOrigin(origin, config.AllowedOrigins) {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+					allowed = true
+				}
 			}
 
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			
-			// For SSE requests, ensure proper CORS headers
-			if r.URL.Path == "/api/v1/events" {
-				w.Header().Set("Access-Control-Expose-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+			if allowed && config.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			if exposeHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", exposeHeaders)
 			}
 
 			if r.Method == "OPTIONS" {
+				if config.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", maxAge)
+				}
 				w.WriteHeader(http.StatusOK)
 				return
 			}
@@ -129,20 +181,30 @@ func CORSMiddleware(config *CORSConfig) func(http.Handler) http.Handler {
 	}
 }
 
-// isAllowedOrigin checks if an origin is in the allowed list
+// isAllowedOrigin checks if an origin is in the allowed list, where
+// scheme://*.domain allows any subdomain of domain with that scheme
 func isAllowedOrigin(origin string, allowedOrigins []string) bool {
 	for _, allowed := range allowedOrigins {
 		if origin == allowed {
 			return true
 		}
+		scheme, domain, ok := strings.Cut(allowed, "://*.")
+		if !ok {
+			continue
+		}
+		host, found := strings.CutPrefix(origin, scheme+"://")
+		if found && strings.HasSuffix(host, "."+domain) && len(host) > len(domain)+1 && !strings.ContainsAny(host, "/?#@") {
+			return true
+		}
 	}
 	return false
 }
 
 // LoggingMiddleware logs all requests
 func LoggingMiddleware(next http.Handler) http.Handler {
+	logger := logging.Component("http")
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s", r.Method, r.URL.Path, r.RemoteAddr)
+		logger.Info("Request", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
 		next.ServeHTTP(w, r)
 	})
 }
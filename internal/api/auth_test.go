@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestNewAuthConfig(t *testing.T) {
@@ -433,6 +434,7 @@ func TestIsAllowedOrigin(t *testing.T) {
 		"https://example.com",
 		"https://app.example.com",
 		"http://localhost:3000",
+		"https://*.corp.example.com",
 	}
 
 	tests := []struct {
@@ -446,6 +448,11 @@ func TestIsAllowedOrigin(t *testing.T) {
 		{"https://example.com.evil.com", false},
 		{"http://example.com", false}, // Different protocol
 		{"", false},
+		{"https://grafana.corp.example.com", true},
+		{"https://a.b.corp.example.com", true},
+		{"https://corp.example.com", false}, // The wildcard needs a subdomain
+		{"http://grafana.corp.example.com", false},
+		{"https://evilcorp.example.com", false},
 	}
 
 	for _, tt := range tests {
@@ -458,6 +465,84 @@ func TestIsAllowedOrigin(t *testing.T) {
 	}
 }
 
+func TestCORSMiddleware_Configured(t *testing.T) {
+	config := &CORSConfig{
+		AllowedOrigins:   []string{"https://*.corp.example.com"},
+		AllowedMethods:   []string{"GET", "OPTIONS"},
+		AllowedHeaders:   []string{"Authorization", "X-Request-ID"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	handler := CORSMiddleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("OPTIONS", "/api/v1/agents", nil)
+	req.Header.Set("Origin", "https://grafana.corp.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://grafana.corp.example.com",
+		"Access-Control-Allow-Methods":     "GET, OPTIONS",
+		"Access-Control-Allow-Headers":     "Authorization, X-Request-ID",
+		"Access-Control-Expose-Headers":    "X-Request-ID",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "600",
+	}
+	for header, value := range want {
+		if got := rec.Header().Get(header); got != value {
+			t.Errorf("Expected %s %q, got %q", header, value, got)
+		}
+	}
+
+	// Credentials are only granted to allowed origins
+	req = httptest.NewRequest("GET", "/api/v1/agents", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("Expected no CORS grant for a disallowed origin, got %v", rec.Header())
+	}
+}
+
+func TestCORSMiddleware_DevModeWithCredentials(t *testing.T) {
+	config := &CORSConfig{DevMode: true, AllowCredentials: true, AllowedOrigins: []string{"http://localhost:3000"}}
+
+	handler := CORSMiddleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"http://localhost:3000", true},
+		{"https://evil.example.com", false}, // Would read responses with the user's credentials
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/v1/health", nil)
+		req.Header.Set("Origin", tt.origin)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		// Browsers refuse credentials with a wildcard origin, so listed
+		// origins are echoed
+		want := ""
+		if tt.allowed {
+			want = tt.origin
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Errorf("%s: expected Access-Control-Allow-Origin %q, got %q", tt.origin, want, got)
+		}
+		if got := rec.Header().Get("Vary"); got != "Origin" {
+			t.Errorf("%s: expected Vary: Origin, got %q", tt.origin, got)
+		}
+	}
+}
+
 func TestLoggingMiddleware(t *testing.T) {
 	handler := LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
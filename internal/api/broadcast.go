@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// sseInterval is how often dashboards receive a state update
+const sseInterval = 2 * time.Second
+
+// broadcaster serializes the dashboard state once per tick and fans the
+// bytes out to every connected SSE client, instead of each client copying
+// and marshaling the whole store. It only runs while clients are connected.
+type broadcaster struct {
+	state    *server.StateStore
+	interval time.Duration
+
+	mu          sync.Mutex
+	subscribers map[chan []byte]struct{}
+	stop        chan struct{} // nil while no clients are connected
+
+	// Agent and alert copies at the last store revision seen
+	revision uint64
+	agents   []*server.ServerState
+	alerts   []*server.Alert
+}
+
+func newBroadcaster(state *server.StateStore, interval time.Duration) *broadcaster {
+	return &broadcaster{
+		state:       state,
+		interval:    interval,
+		subscribers: make(map[chan []byte]struct{}),
+	}
+}
+
+// subscribe registers a client. Its channel receives complete SSE frames,
+// the first one straight away; a client that falls behind only gets the
+// newest frame. Call the returned function when the client goes away.
+func (b *broadcaster) subscribe() (<-chan []byte, func()) {
+	ch := make(chan []byte, 1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if frame := b.frame(); frame != nil {
+		ch <- frame
+	}
+	b.subscribers[ch] = struct{}{}
+	if b.stop == nil {
+		b.stop = make(chan struct{})
+		go b.run(b.stop)
+	}
+
+	return ch, func() { b.unsubscribe(ch) }
+}
+
+func (b *broadcaster) unsubscribe(ch chan []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers, ch)
+	if len(b.subscribers) == 0 && b.stop != nil {
+		close(b.stop)
+		b.stop = nil
+	}
+}
+
+// run sends a frame to all subscribers every interval until stopped
+func (b *broadcaster) run(stop chan struct{}) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			b.broadcast()
+		}
+	}
+}
+
+func (b *broadcaster) broadcast() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	frame := b.frame()
+	if frame == nil {
+		return
+	}
+	for ch := range b.subscribers {
+		// Replace an unread frame rather than block on a slow client; only
+		// the broadcaster sends, so the buffer has room after draining it
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- frame
+	}
+}
+
+// frame marshals the current state as an SSE message, copying the store
+// only when it changed since the last frame. Caller must hold mu.
+func (b *broadcaster) frame() []byte {
+	revision := b.state.Revision()
+	if b.agents == nil || revision != b.revision {
+		b.revision = revision
+		b.agents = b.state.GetAllAgents()
+		b.alerts = b.state.GetActiveAlerts()
+	}
+	// Scores change with time alone, so they're fresh on every frame
+	now := time.Now()
+	for _, agent := range b.agents {
+		agent.HealthScore = agent.HealthAt(now)
+	}
+
+	data := map[string]interface{}{
+		"agents":    b.agents,
+		"alerts":    b.alerts,
+		"timestamp": time.Now().Unix(),
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		logging.Component("api").Error("Error marshaling SSE data", logging.Err(err))
+		return nil
+	}
+
+	frame := make([]byte, 0, len(jsonData)+8)
+	frame = append(frame, "data: "...)
+	frame = append(frame, jsonData...)
+	return append(frame, "\n\n"...)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+)
+
+func receiveFrame(t *testing.T, frames <-chan []byte) []byte {
+	t.Helper()
+	select {
+	case frame := <-frames:
+		return frame
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for an SSE frame")
+		return nil
+	}
+}
+
+func TestBroadcaster_SharesFrames(t *testing.T) {
+	state := server.NewStateStore()
+	state.UpdateAgent(&server.ServerState{AgentName: "agent1"})
+	b := newBroadcaster(state, time.Hour)
+
+	first, unsubscribeFirst := b.subscribe()
+	defer unsubscribeFirst()
+	second, unsubscribeSecond := b.subscribe()
+	defer unsubscribeSecond()
+
+	// Initial frames are built per subscriber, broadcasts are shared
+	receiveFrame(t, first)
+	receiveFrame(t, second)
+
+	b.broadcast()
+	a := receiveFrame(t, first)
+	c := receiveFrame(t, second)
+	if &a[0] != &c[0] {
+		t.Error("Expected subscribers to share one serialized frame")
+	}
+
+	if !strings.HasPrefix(string(a), "data: ") || !strings.HasSuffix(string(a), "\n\n") {
+		t.Errorf("Expected an SSE data frame, got %q", a)
+	}
+	if !strings.Contains(string(a), `"agent_name":"agent1"`) {
+		t.Errorf("Expected frame to contain agent1, got %s", a)
+	}
+}
+
+func TestBroadcaster_CopiesOnlyOnChange(t *testing.T) {
+	state := server.NewStateStore()
+	state.UpdateAgent(&server.ServerState{AgentName: "agent1"})
+	b := newBroadcaster(state, time.Hour)
+
+	b.mu.Lock()
+	b.frame()
+	copied := b.agents[0]
+	b.frame()
+	if b.agents[0] != copied {
+		t.Error("Expected unchanged state to reuse the copied agents")
+	}
+
+	state.UpdateHeartbeat("agent1")
+	b.frame()
+	if b.agents[0] == copied {
+		t.Error("Expected agents to be copied again after the state changed")
+	}
+	b.mu.Unlock()
+}
+
+func TestBroadcaster_StopsWithoutSubscribers(t *testing.T) {
+	b := newBroadcaster(server.NewStateStore(), time.Hour)
+
+	_, unsubscribe := b.subscribe()
+	if b.stop == nil {
+		t.Fatal("Expected broadcaster to start with a subscriber")
+	}
+	unsubscribe()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stop != nil || len(b.subscribers) != 0 {
+		t.Error("Expected broadcaster to stop after the last subscriber left")
+	}
+}
+
+func TestBroadcaster_SlowSubscriberGetsLatest(t *testing.T) {
+	state := server.NewStateStore()
+	b := newBroadcaster(state, time.Hour)
+
+	frames, unsubscribe := b.subscribe()
+	defer unsubscribe()
+
+	// Never read the initial frame, then broadcast a newer state
+	state.UpdateAgent(&server.ServerState{AgentName: "late-agent"})
+	b.broadcast()
+
+	frame := receiveFrame(t, frames)
+	if !strings.Contains(string(frame), "late-agent") {
+		t.Errorf("Expected the newest frame, got %s", frame)
+	}
+}
+
+func TestHandleEventsSSE(t *testing.T) {
+	state := server.NewStateStore()
+	state.UpdateAgent(&server.ServerState{AgentName: "agent1"})
+	handler := NewHandler(state)
+
+	srv := httptest.NewServer(http.HandlerFunc(handler.HandleEventsSSE))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got %s", ct)
+	}
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read event: %v", err)
+	}
+
+	var data struct {
+		Agents []server.ServerState `json:"agents"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data); err != nil {
+		t.Fatalf("Failed to decode event %q: %v", line, err)
+	}
+	if len(data.Agents) != 1 || data.Agents[0].AgentName != "agent1" {
+		t.Errorf("Expected agent1 in the first event, got %+v", data.Agents)
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/msgpack"
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/internal/tracing"
+)
+
+// maxBulkErrors caps the per-line errors a bulk response lists
+const maxBulkErrors = 100
+
+// BulkLineError is why one line of a bulk push was rejected
+type BulkLineError struct {
+	Line      int    `json:"line"` // From 1; for MessagePack, the value's position
+	AgentName string `json:"agent_name,omitempty"`
+	Error     string `json:"error"`
+}
+
+// BulkResponse is the outcome of a bulk push
+type BulkResponse struct {
+	Status   string          `json:"status"` // success, or partial if any line was rejected
+	Accepted int             `json:"accepted"`
+	Rejected int             `json:"rejected"`
+	Errors   []BulkLineError `json:"errors,omitempty"` // The first maxBulkErrors
+}
+
+// HandleMetricsBulk handles POST /api/v1/metrics/bulk: newline-delimited
+// metrics pushes, such as several agents' from a proxy or one agent's
+// buffered samples, stored in order as if pushed one by one. Rejected lines
+// don't stop the rest and are listed in the response. A MessagePack body is
+// a sequence of pushes instead of lines; one that can't be decoded ends it,
+// as there is no newline to resume from.
+func (h *Handler) HandleMetricsBulk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	receivedAt := time.Now()
+	if r.ContentLength > h.limits.MaxRequestSize {
+		h.logger.Warn("Request too large", "bytes", r.ContentLength, "max_bytes", h.limits.MaxRequestSize)
+		http.Error(w, "Request entity too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxRequestSize)
+
+	body, err := h.readBody(r)
+	if err != nil {
+		h.logger.Error("Error reading request body", logging.Err(err))
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	defer body.Close()
+
+	resp := BulkResponse{Status: "success"}
+	reject := func(line int, agentName string, err error) {
+		resp.Rejected++
+		if len(resp.Errors) < maxBulkErrors {
+			resp.Errors = append(resp.Errors, BulkLineError{Line: line, AgentName: agentName, Error: err.Error()})
+		}
+	}
+
+	ingest := func(line int, payload *server.MetricsPushPayload) {
+		if payload.AgentName == "" {
+			reject(line, "", errors.New("agent_name is required"))
+		} else if err := h.IngestMetrics(r.Context(), payload, receivedAt); err != nil {
+			reject(line, payload.AgentName, err)
+		} else {
+			resp.Accepted++
+		}
+	}
+	failRead := func(err error) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.logger.Warn("Request too large", "max_bytes", tooLarge.Limit)
+			http.Error(w, fmt.Sprintf("Request entity too large, the limit is %d bytes (server.max_request_size)", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		h.logger.Error("Error reading request body", logging.Err(err))
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+	}
+
+	if payloadFormat(r) == "MessagePack" {
+		decoder := msgpack.NewDecoder(body)
+		for line := 1; ; line++ {
+			var payload server.MetricsPushPayload
+			err := decoder.Decode(&payload)
+			if err == io.EOF {
+				break
+			}
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				failRead(err)
+				return
+			}
+			if err != nil {
+				reject(line, "", errors.New("invalid MessagePack payload"))
+				break
+			}
+			ingest(line, &payload)
+		}
+	} else {
+		reader := bufio.NewReader(body)
+		for line := 1; ; line++ {
+			data, err := reader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				failRead(err)
+				return
+			}
+			if data = bytes.TrimSpace(data); len(data) > 0 {
+				var payload server.MetricsPushPayload
+				if err := json.Unmarshal(data, &payload); err != nil {
+					reject(line, "", errors.New("invalid JSON payload"))
+				} else {
+					ingest(line, &payload)
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+		}
+	}
+
+	if resp.Rejected > 0 {
+		resp.Status = "partial"
+		h.logger.Warn("Rejected lines of bulk metrics push", "accepted", resp.Accepted, "rejected", resp.Rejected)
+	}
+	if span := tracing.FromContext(r.Context()); span != nil {
+		span.SetAttr("bulk.accepted", resp.Accepted)
+		span.SetAttr("bulk.rejected", resp.Rejected)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.logger.Error("Error encoding response", logging.Err(err))
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/msgpack"
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+// ndjson encodes payloads one per line
+func ndjson(payloads ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, p := range payloads {
+		json.NewEncoder(&buf).Encode(p)
+	}
+	return buf.Bytes()
+}
+
+func TestHandleMetricsBulk(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+	var pushed []string
+	handler.OnMetricsPush(func(agentName string) { pushed = append(pushed, agentName) })
+
+	now := time.Now()
+	body := ndjson(
+		server.MetricsPushPayload{AgentName: "web-1", SystemMetrics: metrics.SystemMetrics{Timestamp: now.Add(-time.Minute), CPU: metrics.CPUMetrics{UsagePercent: 10}}},
+		server.MetricsPushPayload{AgentName: "web-1", SystemMetrics: metrics.SystemMetrics{Timestamp: now, CPU: metrics.CPUMetrics{UsagePercent: 20}}},
+		server.MetricsPushPayload{AgentName: "web-2", SystemMetrics: metrics.SystemMetrics{Timestamp: now}},
+	)
+	rec := httptest.NewRecorder()
+	handler.HandleMetricsBulk(rec, httptest.NewRequest("POST", "/api/v1/metrics/bulk", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp BulkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Status != "success" || resp.Accepted != 3 || resp.Rejected != 0 {
+		t.Errorf("Expected 3 lines accepted, got %+v", resp)
+	}
+	if agent, _ := state.GetAgent("web-1"); agent == nil || agent.SystemMetrics.CPU.UsagePercent != 20 {
+		t.Errorf("Expected web-1 to hold its latest sample, got %+v", agent)
+	}
+	if len(pushed) != 3 {
+		t.Errorf("Expected the push hook called per line, got %v", pushed)
+	}
+}
+
+func TestHandleMetricsBulk_PartialAndGzip(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	gz.Write(ndjson(server.MetricsPushPayload{AgentName: "web-1", Timestamp: time.Now()}))
+	gz.Write([]byte("\n{not json\n"))
+	gz.Write(ndjson(
+		server.MetricsPushPayload{Timestamp: time.Now()},
+		server.MetricsPushPayload{AgentName: "web-2", Sequence: 5, Delta: true, BaseSequence: 4},
+	))
+	gz.Close()
+
+	req := httptest.NewRequest("POST", "/api/v1/metrics/bulk", &body)
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.HandleMetricsBulk(rec, req)
+
+	var resp BulkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Status != "partial" || resp.Accepted != 1 || resp.Rejected != 3 {
+		t.Fatalf("Expected 1 accepted and 3 rejected, got %+v", resp)
+	}
+	// The blank line still counts
+	want := []BulkLineError{
+		{Line: 3, Error: "invalid JSON payload"},
+		{Line: 4, Error: "agent_name is required"},
+		{Line: 5, AgentName: "web-2", Error: ErrFullPushRequired.Error()},
+	}
+	for i, e := range want {
+		if resp.Errors[i] != e {
+			t.Errorf("Expected error %+v, got %+v", e, resp.Errors[i])
+		}
+	}
+	if _, exists := state.GetAgent("web-1"); !exists {
+		t.Error("Expected the valid line stored")
+	}
+}
+
+func TestHandleMetricsBulk_Msgpack(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	var body bytes.Buffer
+	for _, payload := range []server.MetricsPushPayload{
+		{AgentName: "web-1", Timestamp: time.Now()},
+		{Timestamp: time.Now()},
+		{AgentName: "web-2", Timestamp: time.Now()},
+	} {
+		data, _ := msgpack.Marshal(payload)
+		body.Write(data)
+	}
+	body.Write([]byte{0xc1}) // Not MessagePack; ends the body
+
+	req := httptest.NewRequest("POST", "/api/v1/metrics/bulk", &body)
+	req.Header.Set("Content-Type", msgpack.ContentType)
+	rec := httptest.NewRecorder()
+	handler.HandleMetricsBulk(rec, req)
+
+	var resp BulkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Accepted != 2 || resp.Rejected != 2 {
+		t.Fatalf("Expected 2 accepted and 2 rejected, got %+v", resp)
+	}
+	want := []BulkLineError{
+		{Line: 2, Error: "agent_name is required"},
+		{Line: 4, Error: "invalid MessagePack payload"},
+	}
+	for i, e := range want {
+		if resp.Errors[i] != e {
+			t.Errorf("Expected error %+v, got %+v", e, resp.Errors[i])
+		}
+	}
+	if _, exists := state.GetAgent("web-2"); !exists {
+		t.Error("Expected web-2 stored")
+	}
+}
+
+func TestHandleMetricsBulk_TooLarge(t *testing.T) {
+	handler := NewHandler(server.NewStateStore())
+	handler.SetLimits(PayloadLimits{MaxRequestSize: 64})
+
+	body := strings.Repeat(`{"agent_name":"web-1"}`+"\n", 10)
+	req := httptest.NewRequest("POST", "/api/v1/metrics/bulk", strings.NewReader(body))
+	req.ContentLength = -1 // Streamed, so the limit applies while reading
+	rec := httptest.NewRecorder()
+	handler.HandleMetricsBulk(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// HandleGetContainers handles GET /api/v1/containers: containers across all
+// agents, sorted by agent and name. Optional filters, which must all match:
+//
+//	?image=redis      image name, with or without registry and tag, or a glob
+//	                  such as "nginx:1.19*" for specific tags
+//	?state=running    container state
+//	?health=unhealthy health check status
+//	?project=shop     compose project
+//	?label=k[=v]      container label (repeatable)
+//	?agent=web-*      agent name glob
+func (h *Handler) HandleGetContainers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	image, agentPattern := query.Get("image"), query.Get("agent")
+	for _, pattern := range []string{image, agentPattern} {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid pattern %q", pattern), http.StatusBadRequest)
+			return
+		}
+	}
+	state, health, project := query.Get("state"), query.Get("health"), query.Get("project")
+	labels := query["label"]
+
+	containers := make([]server.FleetContainer, 0)
+	err := h.state.ForEachAgent(func(agent *server.ServerState) error {
+		if matched, _ := filepath.Match(agentPattern, agent.AgentName); agentPattern != "" && !matched {
+			return nil
+		}
+		for _, c := range agent.Containers {
+			switch {
+			case image != "" && !imageMatches(image, c.Image):
+			case state != "" && c.State != state:
+			case health != "" && c.Health != health:
+			case project != "" && !strings.HasPrefix(c.ComposeService, project+"/"):
+			case !matchesLabels(c.Labels, labels):
+			default:
+				containers = append(containers, server.FleetContainer{AgentName: agent.AgentName, AgentStatus: agent.Status, ContainerState: c})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		h.logger.Error("Error listing containers", logging.Err(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	sort.Slice(containers, func(i, j int) bool {
+		if containers[i].AgentName != containers[j].AgentName {
+			return containers[i].AgentName < containers[j].AgentName
+		}
+		return containers[i].Name < containers[j].Name
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(containers); err != nil {
+		h.logger.Error("Error encoding containers response", logging.Err(err))
+	}
+}
+
+// imageMatches reports whether an image reference matches pattern, with or
+// without its registry and path. A pattern without a tag or digest matches
+// any tag, so "redis" matches "docker.io/library/redis:7" and "nginx:1.19*"
+// matches "nginx:1.19.3".
+func imageMatches(pattern, image string) bool {
+	if !strings.ContainsAny(pattern, ":@") {
+		image = imageRepository(image)
+	}
+	for {
+		if matched, _ := filepath.Match(pattern, image); matched {
+			return true
+		}
+		_, rest, found := strings.Cut(image, "/")
+		if !found {
+			return false
+		}
+		image = rest
+	}
+}
+
+// imageRepository strips the tag and digest from an image reference
+func imageRepository(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	// A colon after the last slash starts the tag; before it, a registry port
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// matchesLabels reports whether labels has every "key" or "key=value" filter
+func matchesLabels(labels map[string]string, filters []string) bool {
+	for _, filter := range filters {
+		key, value, hasValue := strings.Cut(filter, "=")
+		actual, exists := labels[key]
+		if !exists || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
+}
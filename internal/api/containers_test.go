@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func TestHandleGetContainers(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	state.UpdateAgent(&server.ServerState{
+		AgentName: "web-1",
+		Status:    "online",
+		Containers: handler.convertContainers([]metrics.ContainerMetrics{
+			{ID: "a1", Name: "redis", Image: "docker.io/library/redis:7", State: "running", Health: "healthy"},
+			{ID: "a2", Name: "shop-api-1", Image: "registry.local:5000/shop/api:1.2", State: "exited",
+				Labels: map[string]string{"com.docker.compose.project": "shop", "com.docker.compose.service": "api", "team": "payments"}},
+		}),
+	})
+	state.UpdateAgent(&server.ServerState{
+		AgentName: "db-1",
+		Status:    "online",
+		Containers: handler.convertContainers([]metrics.ContainerMetrics{
+			{ID: "b1", Name: "cache", Image: "redis:6-alpine", State: "running", Health: "unhealthy"},
+			{ID: "b2", Name: "proxy", Image: "nginx:1.19.3", State: "running"},
+		}),
+	})
+
+	tests := []struct {
+		query string
+		want  []string // agent/container
+	}{
+		{"", []string{"db-1/cache", "db-1/proxy", "web-1/redis", "web-1/shop-api-1"}},
+		{"?image=redis", []string{"db-1/cache", "web-1/redis"}},
+		{"?image=redis&health=unhealthy", []string{"db-1/cache"}},
+		{"?image=nginx:1.19*", []string{"db-1/proxy"}},
+		{"?image=shop/api", []string{"web-1/shop-api-1"}},
+		{"?state=exited", []string{"web-1/shop-api-1"}},
+		{"?project=shop", []string{"web-1/shop-api-1"}},
+		{"?label=team=payments", []string{"web-1/shop-api-1"}},
+		{"?label=team=search", nil},
+		{"?agent=web-*&state=running", []string{"web-1/redis"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.HandleGetContainers(rec, httptest.NewRequest("GET", "/api/v1/containers"+tt.query, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			var containers []server.FleetContainer
+			if err := json.NewDecoder(rec.Body).Decode(&containers); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			var got []string
+			for _, c := range containers {
+				got = append(got, c.AgentName+"/"+c.Name)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Expected %v, got %v", tt.want, got)
+					break
+				}
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handler.HandleGetContainers(rec, httptest.NewRequest("GET", "/api/v1/containers?image=[", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid pattern, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// HandleCustomMetrics handles /api/v1/metrics/custom. POST stores the gauges
+// and counters an application pushes for an agent or a logical service; GET
+// lists the stored metrics, optionally filtered:
+//
+//	?name=queue_depth metric name
+//	?agent=web-*      agent name glob
+//	?service=billing  service name glob
+//	?label=k[=v]      metric label (repeatable)
+func (h *Handler) HandleCustomMetrics(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listCustomMetrics(w, r)
+	case http.MethodPost:
+		h.pushCustomMetrics(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// pushCustomMetrics validates and stores a push of custom metrics
+func (h *Handler) pushCustomMetrics(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxRequestSize)
+	var req server.CustomMetricsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	req.AgentName = strings.TrimSpace(req.AgentName)
+	req.Service = strings.TrimSpace(req.Service)
+	if (req.AgentName == "") == (req.Service == "") {
+		http.Error(w, "One of agent_name and service is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Metrics) == 0 {
+		http.Error(w, "metrics are required", http.StatusBadRequest)
+		return
+	}
+	for i := range req.Metrics {
+		m := &req.Metrics[i]
+		m.Name = strings.TrimSpace(m.Name)
+		if m.Name == "" {
+			http.Error(w, fmt.Sprintf("metric %d: name is required", i), http.StatusBadRequest)
+			return
+		}
+		switch m.Type {
+		case "":
+			m.Type = server.CustomGauge
+		case server.CustomGauge, server.CustomCounter:
+		default:
+			http.Error(w, fmt.Sprintf("metric %q: type must be gauge or counter", m.Name), http.StatusBadRequest)
+			return
+		}
+		if math.IsNaN(m.Value) || math.IsInf(m.Value, 0) || (m.Type == server.CustomCounter && m.Value < 0) {
+			http.Error(w, fmt.Sprintf("metric %q: invalid value %g", m.Name, m.Value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.state.PushCustomMetrics(req, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"metrics": len(req.Metrics),
+	}); err != nil {
+		h.logger.Error("Error encoding response", logging.Err(err))
+	}
+}
+
+// listCustomMetrics serves the stored custom metrics matching the query
+func (h *Handler) listCustomMetrics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name, agentPattern, servicePattern := query.Get("name"), query.Get("agent"), query.Get("service")
+	for _, pattern := range []string{agentPattern, servicePattern} {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid pattern %q", pattern), http.StatusBadRequest)
+			return
+		}
+	}
+	labels := query["label"]
+
+	metrics := make([]server.CustomMetric, 0)
+	for _, m := range h.state.CustomMetrics() {
+		agentMatched, _ := filepath.Match(agentPattern, m.AgentName)
+		serviceMatched, _ := filepath.Match(servicePattern, m.Service)
+		switch {
+		case name != "" && m.Name != name:
+		case agentPattern != "" && (m.AgentName == "" || !agentMatched):
+		case servicePattern != "" && (m.Service == "" || !serviceMatched):
+		case !matchesLabels(m.Labels, labels):
+		default:
+			metrics = append(metrics, m)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(metrics); err != nil {
+		h.logger.Error("Error encoding custom metrics response", logging.Err(err))
+	}
+}
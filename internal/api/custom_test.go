@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+)
+
+func TestHandleCustomMetrics_Push(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"gauge for a service", `{"service": "billing", "metrics": [{"name": "queue_depth", "value": 12, "labels": {"queue": "invoices"}}]}`, http.StatusOK},
+		{"counter for an agent", `{"agent_name": "web-1", "metrics": [{"name": "requests", "type": "counter", "value": 3}]}`, http.StatusOK},
+		{"no owner", `{"metrics": [{"name": "queue_depth", "value": 1}]}`, http.StatusBadRequest},
+		{"agent and service", `{"agent_name": "web-1", "service": "billing", "metrics": [{"name": "queue_depth", "value": 1}]}`, http.StatusBadRequest},
+		{"no metrics", `{"service": "billing"}`, http.StatusBadRequest},
+		{"no name", `{"service": "billing", "metrics": [{"value": 1}]}`, http.StatusBadRequest},
+		{"unknown type", `{"service": "billing", "metrics": [{"name": "latency", "type": "histogram", "value": 1}]}`, http.StatusBadRequest},
+		{"negative counter", `{"agent_name": "web-1", "metrics": [{"name": "requests", "type": "counter", "value": -1}]}`, http.StatusBadRequest},
+		{"type change", `{"service": "billing", "metrics": [{"name": "queue_depth", "type": "counter", "value": 1, "labels": {"queue": "invoices"}}]}`, http.StatusBadRequest},
+		{"invalid JSON", `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.HandleCustomMetrics(rec, httptest.NewRequest("POST", "/api/v1/metrics/custom", strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	metrics := state.CustomMetrics()
+	if len(metrics) != 2 {
+		t.Fatalf("Expected 2 series stored, got %d", len(metrics))
+	}
+	if metrics[0].Type != server.CustomGauge {
+		t.Errorf("Expected type to default to gauge, got %q", metrics[0].Type)
+	}
+}
+
+func TestHandleCustomMetrics_List(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	for _, req := range []server.CustomMetricsRequest{
+		{Service: "billing", Metrics: []server.CustomMetricSample{
+			{Name: "queue_depth", Type: server.CustomGauge, Value: 12, Labels: map[string]string{"queue": "invoices"}},
+			{Name: "queue_depth", Type: server.CustomGauge, Value: 3, Labels: map[string]string{"queue": "refunds"}},
+		}},
+		{AgentName: "web-1", Metrics: []server.CustomMetricSample{{Name: "requests", Type: server.CustomCounter, Value: 3}}},
+	} {
+		if err := state.PushCustomMetrics(req, time.Now()); err != nil {
+			t.Fatalf("PushCustomMetrics() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 3},
+		{"?name=queue_depth", 2},
+		{"?service=bill*", 2},
+		{"?agent=web-*", 1},
+		{"?label=queue=refunds", 1},
+		{"?service=search", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.HandleCustomMetrics(rec, httptest.NewRequest("GET", "/api/v1/metrics/custom"+tt.query, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			var metrics []server.CustomMetric
+			if err := json.NewDecoder(rec.Body).Decode(&metrics); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(metrics) != tt.want {
+				t.Errorf("Expected %d metrics, got %d", tt.want, len(metrics))
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handler.HandleCustomMetrics(rec, httptest.NewRequest("GET", "/api/v1/metrics/custom?agent=web-[", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid pattern, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// DashboardHandler serves the single-page dashboard from fsys. Paths that
+// aren't files get index.html so client-side routes survive a reload, while
+// unknown /api paths stay 404s.
+func DashboardHandler(fsys fs.FS) http.Handler {
+	fileServer := http.FileServer(http.FS(fsys))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		if _, err := fs.Stat(fsys, "index.html"); err != nil {
+			http.Error(w, "Dashboard not built: run \"make build-web\" and rebuild the server, or set server.web_dir", http.StatusNotFound)
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		if info, err := fs.Stat(fsys, name); name == "" || err != nil || info.IsDir() {
+			http.ServeFileFS(w, r, fsys, "index.html")
+			return
+		}
+
+		// Vite fingerprints everything under assets/, so it never changes
+		if strings.HasPrefix(name, "assets/") {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		fileServer.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	handler := DashboardHandler(fstest.MapFS{
+		"index.html":       {Data: []byte("<html>dashboard</html>")},
+		"assets/app-1a.js": {Data: []byte("console.log(1)")},
+		"favicon.svg":      {Data: []byte("<svg/>")},
+	})
+
+	tests := []struct {
+		path      string
+		wantCode  int
+		wantBody  string
+		immutable bool
+	}{
+		{"/", http.StatusOK, "dashboard", false},
+		{"/alerts", http.StatusOK, "dashboard", false},        // Client-side route
+		{"/agents/web-1", http.StatusOK, "dashboard", false},  // Nested client-side route
+		{"/assets/app-1a.js", http.StatusOK, "console", true}, // Fingerprinted asset
+		{"/favicon.svg", http.StatusOK, "<svg/>", false},
+		{"/api/v1/unknown", http.StatusNotFound, "not found", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("Expected body to contain %q, got %q", tt.wantBody, rec.Body.String())
+			}
+			if immutable := strings.Contains(rec.Header().Get("Cache-Control"), "immutable"); immutable != tt.immutable {
+				t.Errorf("Expected immutable caching %v, got %q", tt.immutable, rec.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestDashboardHandler_NotBuilt(t *testing.T) {
+	handler := DashboardHandler(fstest.MapFS{".gitkeep": {}})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "make build-web") {
+		t.Errorf("Expected build hint, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// Defaults and limits of the percentile and heatmap endpoints
+const (
+	defaultDistributionWindow = 24 * time.Hour
+	defaultHeatmapBuckets     = 10
+	defaultHeatmapColumns     = 48
+	maxHeatmapBuckets         = 100
+	maxHeatmapColumns         = 1000
+)
+
+// HandlePercentiles handles GET /api/v1/percentiles?window=24h&agent=web-*:
+// p50, p95 and p99 CPU and memory of each agent with history in the window
+func (h *Handler) HandlePercentiles(w http.ResponseWriter, r *http.Request) {
+	agents, from, to, ok := h.distributionQuery(w, r)
+	if !ok {
+		return
+	}
+
+	percentiles := make([]server.AgentPercentiles, 0, len(agents))
+	for _, agent := range agents {
+		if p, ok := h.history.Percentiles(agent, from, to); ok {
+			percentiles = append(percentiles, p)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(percentiles); err != nil {
+		h.logger.Error("Error encoding percentiles response", logging.Err(err))
+	}
+}
+
+// HandleHeatmap handles GET /api/v1/heatmap?metric=cpu_percent&window=24h:
+// how the agents' samples of a metric are distributed over value buckets in
+// each time step, for heatmaps. Optional are agent (a glob), buckets
+// (default 10) and step (default a 48th of the window).
+func (h *Handler) HandleHeatmap(w http.ResponseWriter, r *http.Request) {
+	agents, from, to, ok := h.distributionQuery(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+
+	metric := query.Get("metric")
+	if !slices.Contains(server.HistoryMetrics, metric) {
+		http.Error(w, fmt.Sprintf("metric must be one of %v", server.HistoryMetrics), http.StatusBadRequest)
+		return
+	}
+
+	buckets := defaultHeatmapBuckets
+	if value := query.Get("buckets"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxHeatmapBuckets {
+			http.Error(w, fmt.Sprintf("buckets must be between 1 and %d", maxHeatmapBuckets), http.StatusBadRequest)
+			return
+		}
+		buckets = n
+	}
+
+	step := max(to.Sub(from)/defaultHeatmapColumns, server.HistoryResolution).Round(server.HistoryResolution)
+	if value := query.Get("step"); value != "" {
+		var err error
+		if step, err = time.ParseDuration(value); err != nil || step < server.HistoryResolution {
+			http.Error(w, fmt.Sprintf("step must be a duration of at least %s", server.HistoryResolution), http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Sub(from)/step > maxHeatmapColumns {
+		http.Error(w, fmt.Sprintf("step is too small for the window, at most %d columns", maxHeatmapColumns), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.history.Heatmap(metric, agents, from, to, step, buckets)); err != nil {
+		h.logger.Error("Error encoding heatmap response", logging.Err(err))
+	}
+}
+
+// distributionQuery reads ?window and ?agent, returning the agents with
+// history that match. It writes an error if they are invalid or the server
+// keeps no history.
+func (h *Handler) distributionQuery(w http.ResponseWriter, r *http.Request) (agents []string, from, to time.Time, ok bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, from, to, false
+	}
+	if h.history == nil {
+		http.Error(w, "History is disabled (history.retention)", http.StatusServiceUnavailable)
+		return nil, from, to, false
+	}
+	query := r.URL.Query()
+
+	window := defaultDistributionWindow
+	if value := query.Get("window"); value != "" {
+		var err error
+		if window, err = parseWindow(value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, from, to, false
+		}
+	}
+
+	pattern := query.Get("agent")
+	if pattern == "" {
+		pattern = "*"
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid agent pattern %q", pattern), http.StatusBadRequest)
+		return nil, from, to, false
+	}
+	for _, agent := range h.history.Agents() {
+		if matched, _ := filepath.Match(pattern, agent); matched {
+			agents = append(agents, agent)
+		}
+	}
+
+	to = time.Now()
+	return agents, to.Add(-window), to, true
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+)
+
+func TestHandlePercentiles(t *testing.T) {
+	handler := NewHandler(server.NewStateStore())
+
+	rec := httptest.NewRecorder()
+	handler.HandlePercentiles(rec, httptest.NewRequest("GET", "/api/v1/percentiles", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without history, got %d", rec.Code)
+	}
+
+	history := server.NewHistory(24*time.Hour, 0)
+	handler.SetHistory(history)
+	now := time.Now()
+	history.Record("web-1", server.HistorySample{Time: now.Add(-time.Minute), CPUPercent: 30})
+	history.Record("db-1", server.HistorySample{Time: now.Add(-time.Minute), CPUPercent: 60})
+
+	rec = httptest.NewRecorder()
+	handler.HandlePercentiles(rec, httptest.NewRequest("GET", "/api/v1/percentiles?agent=web-*", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var percentiles []server.AgentPercentiles
+	if err := json.NewDecoder(rec.Body).Decode(&percentiles); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(percentiles) != 1 || percentiles[0].AgentName != "web-1" || percentiles[0].CPU.P99 != 30 {
+		t.Errorf("Expected only web-1 with a p99 of 30, got %+v", percentiles)
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/percentiles?window=2h":    http.StatusOK,
+		"/api/v1/percentiles?window=never": http.StatusBadRequest,
+		"/api/v1/percentiles?agent=[":      http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		handler.HandlePercentiles(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestHandleHeatmap(t *testing.T) {
+	history := server.NewHistory(24*time.Hour, 0)
+	handler := NewHandler(server.NewStateStore())
+	handler.SetHistory(history)
+	history.Record("web-1", server.HistorySample{Time: time.Now().Add(-time.Minute), CPUPercent: 95})
+
+	rec := httptest.NewRecorder()
+	handler.HandleHeatmap(rec, httptest.NewRequest("GET", "/api/v1/heatmap?metric=cpu_percent&window=1h&buckets=4", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var heatmap server.Heatmap
+	if err := json.NewDecoder(rec.Body).Decode(&heatmap); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if heatmap.StepSeconds != 60 || len(heatmap.Buckets) != 4 {
+		t.Errorf("Expected minute columns of 4 buckets, got step %vs and %d buckets", heatmap.StepSeconds, len(heatmap.Buckets))
+	}
+	total := 0
+	for _, column := range heatmap.Columns {
+		total += column.Counts[3]
+	}
+	if total != 1 {
+		t.Errorf("Expected the sample in the top bucket, got %d", total)
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/heatmap":                                     http.StatusBadRequest,
+		"/api/v1/heatmap?metric=bogus":                        http.StatusBadRequest,
+		"/api/v1/heatmap?metric=load1&buckets=0":              http.StatusBadRequest,
+		"/api/v1/heatmap?metric=load1&step=1s":                http.StatusBadRequest,
+		"/api/v1/heatmap?metric=load1&window=30d&step=1m":     http.StatusBadRequest,
+		"/api/v1/heatmap?metric=containers&window=2h&step=5m": http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		handler.HandleHeatmap(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// grafanaAlertsTarget is the target that returns alert history as a table
+const grafanaAlertsTarget = "alerts"
+
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type grafanaQueryRequest struct {
+	Range         grafanaRange `json:"range"`
+	MaxDataPoints int          `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+	} `json:"targets"`
+}
+
+type grafanaAnnotationRequest struct {
+	Range      grafanaRange    `json:"range"`
+	Annotation json.RawMessage `json:"annotation"`
+}
+
+// grafanaSeries is a time series; datapoints are [value, unix ms] pairs
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+type grafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type grafanaTable struct {
+	Type    string          `json:"type"`
+	Columns []grafanaColumn `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+type grafanaAnnotation struct {
+	Annotation json.RawMessage `json:"annotation,omitempty"`
+	Time       int64           `json:"time"`
+	TimeEnd    int64           `json:"timeEnd,omitempty"`
+	IsRegion   bool            `json:"isRegion"`
+	Title      string          `json:"title"`
+	Text       string          `json:"text"`
+	Tags       []string        `json:"tags"`
+}
+
+// HandleGrafana handles the Grafana JSON datasource API under
+// /api/v1/grafana: GET / to test the datasource, POST /search for the
+// targets, POST /query for data and POST /annotations for alerts.
+//
+// Targets are "<agent>:<metric>" series from the metrics history, where the
+// agent may be a glob pattern such as "web-*" for a series per agent, and
+// "alerts" for the alert history as a table.
+func (h *Handler) HandleGrafana(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/grafana"), "/")
+	if endpoint == "" {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxRequestSize)
+
+	var response interface{}
+	switch endpoint {
+	case "/search":
+		var req struct {
+			Target string `json:"target"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		response = h.grafanaTargets(req.Target)
+
+	case "/query":
+		var req grafanaQueryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		results, err := h.grafanaQuery(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response = results
+
+	case "/annotations":
+		var req grafanaAnnotationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		var annotation struct {
+			Query string `json:"query"`
+		}
+		json.Unmarshal(req.Annotation, &annotation)
+		pattern := strings.TrimSpace(annotation.Query)
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			http.Error(w, fmt.Sprintf("invalid agent pattern %q", pattern), http.StatusBadRequest)
+			return
+		}
+
+		annotations := make([]grafanaAnnotation, 0)
+		for _, alert := range h.grafanaAlerts(req.Range, pattern) {
+			a := grafanaAnnotation{
+				Annotation: req.Annotation,
+				Time:       alert.TriggeredAt.UnixMilli(),
+				Title:      fmt.Sprintf("%s on %s", alert.AlertType, alert.AgentName),
+				Text:       alert.Message,
+				Tags:       []string{alert.AgentName, alert.AlertType, alert.Severity},
+			}
+			if alert.ResolvedAt != nil {
+				a.TimeEnd = alert.ResolvedAt.UnixMilli()
+				a.IsRegion = true
+			}
+			annotations = append(annotations, a)
+		}
+		response = annotations
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Error encoding Grafana response", logging.Err(err))
+	}
+}
+
+// grafanaTargets lists the targets containing filter: the alerts table,
+// each metric across all agents, then each metric of each agent
+func (h *Handler) grafanaTargets(filter string) []string {
+	candidates := []string{grafanaAlertsTarget}
+	if h.history != nil {
+		for _, metric := range server.HistoryMetrics {
+			candidates = append(candidates, "*:"+metric)
+		}
+		for _, agent := range h.history.Agents() {
+			for _, metric := range server.HistoryMetrics {
+				candidates = append(candidates, agent+":"+metric)
+			}
+		}
+	}
+
+	targets := make([]string, 0, len(candidates))
+	for _, target := range candidates {
+		if strings.Contains(target, filter) {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// grafanaQuery answers a query with a series per matching agent for each
+// metric target and a table for the alerts target
+func (h *Handler) grafanaQuery(req grafanaQueryRequest) ([]interface{}, error) {
+	if req.Range.To.IsZero() {
+		req.Range.To = time.Now()
+	}
+
+	results := make([]interface{}, 0, len(req.Targets))
+	for _, t := range req.Targets {
+		if t.Target == grafanaAlertsTarget {
+			results = append(results, h.grafanaAlertTable(req.Range))
+			continue
+		}
+
+		pattern, metric, ok := strings.Cut(t.Target, ":")
+		if _, known := (server.HistorySample{}).Value(metric); !ok || !known {
+			return nil, fmt.Errorf("unknown target %q, expected <agent>:<metric> or %s", t.Target, grafanaAlertsTarget)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid agent pattern %q", pattern)
+		}
+		if h.history == nil {
+			continue
+		}
+
+		for _, agent := range h.history.Agents() {
+			if matched, _ := filepath.Match(pattern, agent); !matched {
+				continue
+			}
+			samples := h.history.Samples(agent, req.Range.From, req.Range.To)
+			points := make([][2]float64, 0, len(samples))
+			for _, s := range samples {
+				value, _ := s.Value(metric)
+				points = append(points, [2]float64{value, float64(s.Time.UnixMilli())})
+			}
+			results = append(results, grafanaSeries{
+				Target:     agent + ":" + metric,
+				Datapoints: downsample(points, req.MaxDataPoints),
+			})
+		}
+	}
+	return results, nil
+}
+
+// grafanaAlertTable returns the alerts open during a range as a table
+func (h *Handler) grafanaAlertTable(r grafanaRange) grafanaTable {
+	table := grafanaTable{
+		Type: "table",
+		Columns: []grafanaColumn{
+			{Text: "Time", Type: "time"},
+			{Text: "Agent", Type: "string"},
+			{Text: "Type", Type: "string"},
+			{Text: "Severity", Type: "string"},
+			{Text: "Status", Type: "string"},
+			{Text: "Message", Type: "string"},
+			{Text: "Resolved", Type: "time"},
+		},
+		Rows: make([][]interface{}, 0),
+	}
+	for _, alert := range h.grafanaAlerts(r, "") {
+		var resolved interface{}
+		if alert.ResolvedAt != nil {
+			resolved = alert.ResolvedAt.UnixMilli()
+		}
+		summary, _, _ := strings.Cut(alert.Message, "\n")
+		table.Rows = append(table.Rows, []interface{}{
+			alert.TriggeredAt.UnixMilli(), alert.AgentName, alert.AlertType,
+			alert.Severity, alert.Status, summary, resolved,
+		})
+	}
+	return table
+}
+
+// grafanaAlerts returns the alerts open at some point during a range, of
+// agents matching pattern (empty for all), oldest first
+func (h *Handler) grafanaAlerts(r grafanaRange, pattern string) []*server.Alert {
+	if r.To.IsZero() {
+		r.To = time.Now()
+	}
+
+	var alerts []*server.Alert
+	for _, alert := range h.state.GetAlertsByStatus("all") {
+		if alert.TriggeredAt.After(r.To) || (alert.ResolvedAt != nil && alert.ResolvedAt.Before(r.From)) {
+			continue
+		}
+		if matched, _ := filepath.Match(pattern, alert.AgentName); pattern != "" && !matched {
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].TriggeredAt.Before(alerts[j].TriggeredAt) })
+	return alerts
+}
+
+// downsample averages consecutive points down to at most maxPoints
+// (0 = no limit)
+func downsample(points [][2]float64, maxPoints int) [][2]float64 {
+	if maxPoints <= 0 || len(points) <= maxPoints {
+		return points
+	}
+	size := (len(points) + maxPoints - 1) / maxPoints
+	out := make([][2]float64, 0, maxPoints)
+	for i := 0; i < len(points); i += size {
+		end := min(i+size, len(points))
+		var sum float64
+		for _, p := range points[i:end] {
+			sum += p[0]
+		}
+		out = append(out, [2]float64{sum / float64(end-i), points[i][1]})
+	}
+	return out
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+)
+
+// grafanaTestHandler returns a handler with ten minutes of CPU history for
+// web-1 and web-2, and an alert for web-1
+func grafanaTestHandler(start time.Time) *Handler {
+	state := server.NewStateStore()
+	history := server.NewHistory(time.Hour, 0)
+	handler := NewHandler(state)
+	handler.SetHistory(history)
+
+	for i := 0; i < 10; i++ {
+		at := start.Add(time.Duration(i) * time.Minute)
+		history.Record("web-1", server.HistorySample{Time: at, CPUPercent: float64(i)})
+		history.Record("web-2", server.HistorySample{Time: at, CPUPercent: 50})
+	}
+	resolved := start.Add(5 * time.Minute)
+	state.AddAlert(&server.Alert{
+		ID: "a1", AgentName: "web-1", AlertType: "high_cpu", Severity: "warning",
+		Message: "High CPU\nAgent: web-1", Status: "resolved",
+		TriggeredAt: start.Add(2 * time.Minute), ResolvedAt: &resolved,
+	})
+	return handler
+}
+
+func grafanaRequest(t *testing.T, handler *Handler, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/v1/grafana"+path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.HandleGrafana(rec, req)
+	return rec
+}
+
+func TestHandleGrafana_TestConnection(t *testing.T) {
+	handler := NewHandler(server.NewStateStore())
+
+	for _, path := range []string{"/api/v1/grafana", "/api/v1/grafana/"} {
+		rec := httptest.NewRecorder()
+		handler.HandleGrafana(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", path, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.HandleGrafana(rec, httptest.NewRequest("GET", "/api/v1/grafana/query", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET /query, got %d", rec.Code)
+	}
+
+	rec = grafanaRequest(t, handler, "/unknown", "{}")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown endpoint, got %d", rec.Code)
+	}
+}
+
+func TestHandleGrafana_Search(t *testing.T) {
+	handler := grafanaTestHandler(time.Now().Add(-time.Hour))
+
+	rec := grafanaRequest(t, handler, "/search", `{"target": "web-2:cpu"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var targets []string
+	if err := json.NewDecoder(rec.Body).Decode(&targets); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(targets) != 1 || targets[0] != "web-2:cpu_percent" {
+		t.Errorf("Expected [web-2:cpu_percent], got %v", targets)
+	}
+
+	rec = grafanaRequest(t, handler, "/search", `{"target": ""}`)
+	targets = nil
+	json.NewDecoder(rec.Body).Decode(&targets)
+	want := 1 + 3*len(server.HistoryMetrics) // alerts, *, web-1 and web-2
+	if len(targets) != want || targets[0] != "alerts" {
+		t.Errorf("Expected %d targets starting with alerts, got %v", want, targets)
+	}
+}
+
+func TestHandleGrafana_Query(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	handler := grafanaTestHandler(start)
+
+	body := `{
+		"range": {"from": "2024-01-01T10:00:00Z", "to": "2024-01-01T10:09:00Z"},
+		"maxDataPoints": 5,
+		"targets": [{"target": "web-*:cpu_percent", "refId": "A"}, {"target": "alerts", "refId": "B", "type": "table"}]
+	}`
+	rec := grafanaRequest(t, handler, "/query", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var results []json.RawMessage
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 2 series and a table, got %d results", len(results))
+	}
+
+	var series grafanaSeries
+	json.Unmarshal(results[0], &series)
+	if series.Target != "web-1:cpu_percent" {
+		t.Errorf("Expected web-1:cpu_percent first, got %s", series.Target)
+	}
+	// Ten samples averaged in pairs down to five points
+	if len(series.Datapoints) != 5 {
+		t.Fatalf("Expected 5 datapoints, got %d", len(series.Datapoints))
+	}
+	if series.Datapoints[0][0] != 0.5 || series.Datapoints[0][1] != float64(start.UnixMilli()) {
+		t.Errorf("Expected [0.5, %d], got %v", start.UnixMilli(), series.Datapoints[0])
+	}
+
+	var table grafanaTable
+	json.Unmarshal(results[2], &table)
+	if table.Type != "table" || len(table.Rows) != 1 {
+		t.Fatalf("Expected a table with 1 alert, got %+v", table)
+	}
+	if table.Rows[0][1] != "web-1" || table.Rows[0][5] != "High CPU" {
+		t.Errorf("Unexpected alert row: %v", table.Rows[0])
+	}
+
+	for _, target := range []string{"web-1", "web-1:unknown", "[:cpu_percent"} {
+		rec := grafanaRequest(t, handler, "/query", `{"targets": [{"target": "`+target+`"}]}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestHandleGrafana_Annotations(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	handler := grafanaTestHandler(start)
+
+	body := `{
+		"range": {"from": "2024-01-01T10:00:00Z", "to": "2024-01-01T11:00:00Z"},
+		"annotation": {"name": "Alerts", "enable": true, "query": "web-1"}
+	}`
+	rec := grafanaRequest(t, handler, "/annotations", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var annotations []grafanaAnnotation
+	if err := json.NewDecoder(rec.Body).Decode(&annotations); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(annotations) != 1 {
+		t.Fatalf("Expected 1 annotation, got %d", len(annotations))
+	}
+	a := annotations[0]
+	if a.Time != start.Add(2*time.Minute).UnixMilli() || a.TimeEnd != start.Add(5*time.Minute).UnixMilli() || !a.IsRegion {
+		t.Errorf("Expected a region from the alert firing to its resolution, got %+v", a)
+	}
+	if !strings.Contains(string(a.Annotation), `"Alerts"`) {
+		t.Errorf("Expected the annotation query to be echoed, got %s", a.Annotation)
+	}
+
+	// Other agents and earlier ranges have none
+	for _, body := range []string{
+		`{"range": {"from": "2024-01-01T10:00:00Z", "to": "2024-01-01T11:00:00Z"}, "annotation": {"query": "db-*"}}`,
+		`{"range": {"from": "2024-01-01T09:00:00Z", "to": "2024-01-01T10:00:00Z"}, "annotation": {}}`,
+	} {
+		rec := grafanaRequest(t, handler, "/annotations", body)
+		annotations = nil
+		json.NewDecoder(rec.Body).Decode(&annotations)
+		if len(annotations) != 0 {
+			t.Errorf("Expected no annotations for %s, got %d", body, len(annotations))
+		}
+	}
+}
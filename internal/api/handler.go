@@ -2,34 +2,145 @@ package api
 
 import (
 	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/anurag/saviour/internal/alerting"
+	"github.com/anurag/saviour/internal/deadman"
+	"github.com/anurag/saviour/internal/ha"
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/msgpack"
+	"github.com/anurag/saviour/internal/promwrite"
 	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/internal/tracing"
+	"github.com/anurag/saviour/internal/version"
 	"github.com/anurag/saviour/pkg/metrics"
 )
 
 const (
-	// MaxRequestSize is the maximum allowed request body size (10MB)
+	// MaxRequestSize is the default maximum request body size (10MB)
 	MaxRequestSize = 10 * 1024 * 1024 // 10MB
 )
 
+// PayloadLimits bounds what a single metrics push may contain, so one
+// pathological agent can't exhaust server memory
+type PayloadLimits struct {
+	MaxRequestSize int64 // Bytes on the wire, before decompression
+	MaxContainers  int   // Containers per push (0 or less = unlimited)
+	MaxLabels      int   // Labels per container (0 or less = unlimited)
+}
+
+// DefaultPayloadLimits are used until SetLimits is called
+var DefaultPayloadLimits = PayloadLimits{
+	MaxRequestSize: MaxRequestSize,
+	MaxContainers:  1000,
+	MaxLabels:      256,
+}
+
 // Handler manages HTTP endpoints for the server
 type Handler struct {
-	state *server.StateStore
+	logger *slog.Logger
+	state  *server.StateStore
+	events *broadcaster
+	onPush []func(agentName string)        // See OnMetricsPush
+	onBeat []func(server.HeartbeatPayload) // See OnHeartbeat
+	limits PayloadLimits
+	prom   *promwrite.Receiver
+
+	// See SetExternalAlerts; nil while alerting is disabled
+	raiseExternal func(alerting.ExternalAlert) *alerting.Alert
+
+	// See SetHistory; nil while history is disabled
+	history *server.History
+
+	// See SetChannelStatus; nil without a channel monitor
+	channelStatus func() []alerting.ChannelStatus
+
+	// See SetHAStatus; nil unless running redundant servers
+	haStatus func() ha.Status
+
+	// See SetPings; nil without ping checks
+	pings *deadman.Monitor
+
+	// See SetAlertingSettings; nil while alerting is disabled
+	alerting     *alerting.Engine
+	settingsFile string
+	settingsMu   sync.Mutex // serializes settings changes, see HandleAlertingSettings
 }
 
 // NewHandler creates a new API handler
 func NewHandler(state *server.StateStore) *Handler {
 	return &Handler{
-		state: state,
+		logger: logging.Component("api"),
+		state:  state,
+		events: newBroadcaster(state, sseInterval),
+		limits: DefaultPayloadLimits,
+		prom:   promwrite.NewReceiver(),
 	}
 }
 
+// SetLimits replaces the per-push payload limits
+func (h *Handler) SetLimits(limits PayloadLimits) {
+	h.limits = limits
+}
+
+// SetExternalAlerts sets what POST /api/v1/alerts/external raises alerts
+// with, normally the alert engine's RaiseExternal. Call it before serving
+// requests.
+func (h *Handler) SetExternalAlerts(raise func(alerting.ExternalAlert) *alerting.Alert) {
+	h.raiseExternal = raise
+}
+
+// SetChannelStatus sets where GET /api/v1/health reads the health of the
+// notification channels from. Call it before serving requests.
+func (h *Handler) SetChannelStatus(status func() []alerting.ChannelStatus) {
+	h.channelStatus = status
+}
+
+// SetHAStatus sets where GET /api/v1/health reads the leader election from.
+// Call it before serving requests.
+func (h *Handler) SetHAStatus(status func() ha.Status) {
+	h.haStatus = status
+}
+
+// SetAlertingSettings lets /api/v1/admin/alerting tune the engine at
+// runtime, saving changes to settingsFile, and /api/v1/alerts/dry-run list
+// what its dry-run rules would have raised. Call it before serving requests.
+func (h *Handler) SetAlertingSettings(engine *alerting.Engine, settingsFile string) {
+	h.alerting = engine
+	h.settingsFile = settingsFile
+}
+
+// SetHistory sets the metrics history the Grafana endpoints read, the one
+// the state store records into. Call it before serving requests.
+func (h *Handler) SetHistory(history *server.History) {
+	h.history = history
+}
+
+// OnMetricsPush registers a function called after each accepted metrics
+// push, e.g. to evaluate alerts for the agent straight away. It must not
+// block. Call it before serving requests.
+func (h *Handler) OnMetricsPush(fn func(agentName string)) {
+	h.onPush = append(h.onPush, fn)
+}
+
+// OnHeartbeat registers a function called after each accepted heartbeat.
+// It must not block. Call it before serving requests.
+func (h *Handler) OnHeartbeat(fn func(heartbeat server.HeartbeatPayload)) {
+	h.onBeat = append(h.onBeat, fn)
+}
+
 // HandleMetricsPush handles POST /api/v1/metrics/push
 func (h *Handler) HandleMetricsPush(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -37,20 +148,25 @@ func (h *Handler) HandleMetricsPush(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	receivedAt := time.Now()
+
 	// Enforce maximum request size
-	if r.ContentLength > MaxRequestSize {
-		log.Printf("Request too large: %d bytes (max: %d)", r.ContentLength, MaxRequestSize)
+	if r.ContentLength > h.limits.MaxRequestSize {
+		h.logger.Warn("Request too large", "bytes", r.ContentLength, "max_bytes", h.limits.MaxRequestSize)
 		http.Error(w, "Request entity too large", http.StatusRequestEntityTooLarge)
 		return
 	}
 
 	// Limit request body size to prevent DoS/gzip bombs
-	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestSize)
+	r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxRequestSize)
 
 	// Read and potentially decompress body
+	_, decodeSpan := tracing.Start(r.Context(), "metrics.decode", tracing.KindInternal)
 	body, err := h.readBody(r)
 	if err != nil {
-		log.Printf("Error reading request body: %v", err)
+		decodeSpan.SetError(err)
+		decodeSpan.End()
+		h.logger.Error("Error reading request body", logging.Err(err))
 		http.Error(w, "Failed to read request body", http.StatusBadRequest)
 		return
 	}
@@ -58,9 +174,18 @@ func (h *Handler) HandleMetricsPush(w http.ResponseWriter, r *http.Request) {
 
 	// Parse metrics payload
 	var payload server.MetricsPushPayload
-	if err := json.NewDecoder(body).Decode(&payload); err != nil {
-		log.Printf("Error decoding metrics payload: %v", err)
-		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+	err = decodePayload(r, body, &payload)
+	decodeSpan.SetError(err)
+	decodeSpan.End()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.logger.Warn("Request too large", "max_bytes", tooLarge.Limit)
+			http.Error(w, fmt.Sprintf("Request entity too large, the limit is %d bytes (server.max_request_size)", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		h.logger.Warn("Invalid metrics payload", logging.Err(err))
+		http.Error(w, "Invalid "+payloadFormat(r)+" payload", http.StatusBadRequest)
 		return
 	}
 
@@ -69,29 +194,87 @@ func (h *Handler) HandleMetricsPush(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "agent_name is required", http.StatusBadRequest)
 		return
 	}
+	if span := tracing.FromContext(r.Context()); span != nil {
+		span.SetAttr("agent.name", payload.AgentName)
+		span.SetAttr("agent.containers", len(payload.SystemMetrics.Containers))
+	}
+
+	// Reject rather than store pushes beyond the limits; agents don't retry 4xx
+	if err := h.IngestMetrics(r.Context(), &payload, receivedAt); errors.Is(err, ErrFullPushRequired) {
+		http.Error(w, "Full metrics push required", http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Return success
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(IngestResponse{
+		Status:  "success",
+		Message: "Metrics received",
+		Refresh: h.state.TakeRefresh(payload.AgentName),
+	}); err != nil {
+		h.logger.Error("Error encoding response", logging.Err(err))
+	}
+}
+
+// IngestResponse is the body of a successful push or heartbeat
+type IngestResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+
+	// Set when the agent should collect and push right away, see
+	// server.StateStore.RequestRefresh
+	Refresh bool `json:"refresh,omitempty"`
+}
+
+// ErrFullPushRequired is returned by IngestMetrics for a delta push whose
+// base push the server doesn't have
+var ErrFullPushRequired = errors.New("full metrics push required")
+
+// IngestMetrics stores the metrics of an agent received at receivedAt,
+// pushed or scraped, and runs the OnMetricsPush functions. Besides
+// ErrFullPushRequired it fails for payloads beyond the limits.
+func (h *Handler) IngestMetrics(ctx context.Context, payload *server.MetricsPushPayload, receivedAt time.Time) error {
+	// Delta pushes only carry changed containers; without the push they are
+	// based on, ask the agent for a full one
+	if !h.state.ExpandDelta(payload) {
+		return ErrFullPushRequired
+	}
+
+	if err := h.checkLimits(payload); err != nil {
+		h.logger.Warn("Rejected metrics", "agent", payload.AgentName, logging.Err(err))
+		return err
+	}
 
 	// Create/update server state
+	cloud := h.getCloudMetadata(payload)
 	state := &server.ServerState{
 		AgentName:     payload.AgentName,
-		EC2InstanceID: h.getEC2InstanceID(payload.EC2Metadata),
+		EC2InstanceID: h.getEC2InstanceID(cloud.EC2Metadata()),
+		Cloud:         cloud,
 		SystemMetrics: payload.SystemMetrics,
 		Containers:    h.convertContainers(payload.SystemMetrics.Containers),
 		ActiveAlerts:  []server.Alert{}, // Will be populated by alert engine
+		PushSequence:  payload.Sequence,
+		LastSeen:      receivedAt, // Server clock; the payload's is kept as reported
 	}
 
-	h.state.UpdateAgent(state)
-
-	log.Printf("Received metrics from agent: %s", payload.AgentName)
-
-	// Return success
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]string{
-		"status":  "success",
-		"message": "Metrics received",
-	}); err != nil {
-		log.Printf("Error encoding response: %v", err)
+	// Still a success for late retries, the agent has nothing to resend
+	_, storeSpan := tracing.Start(ctx, "state.update", tracing.KindInternal)
+	defer storeSpan.End()
+	if !h.state.UpdateAgent(state) {
+		storeSpan.SetAttr("out_of_order", true)
+		h.logger.Info("Ignored out-of-order metrics", "agent", payload.AgentName, "collected_at", payload.SystemMetrics.Timestamp)
+		return nil
+	}
+	for _, fn := range h.onPush {
+		fn(state.AgentName)
 	}
+	h.logger.Debug("Received metrics", "agent", payload.AgentName)
+	return nil
 }
 
 // HandleHeartbeat handles POST /api/v1/heartbeat
@@ -101,11 +284,13 @@ func (h *Handler) HandleHeartbeat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	receivedAt := time.Now()
+
 	// Parse heartbeat payload
 	var payload server.HeartbeatPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		log.Printf("Error decoding heartbeat payload: %v", err)
-		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+	if err := decodePayload(r, r.Body, &payload); err != nil {
+		h.logger.Warn("Invalid heartbeat payload", logging.Err(err))
+		http.Error(w, "Invalid "+payloadFormat(r)+" payload", http.StatusBadRequest)
 		return
 	}
 
@@ -116,17 +301,41 @@ func (h *Handler) HandleHeartbeat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update heartbeat
-	h.state.UpdateHeartbeat(payload.AgentName)
-
-	log.Printf("Heartbeat received from agent: %s", payload.AgentName)
+	switch payload.Status {
+	case "terminating":
+		h.state.MarkTerminating(payload.AgentName, payload.Reason)
+		h.logger.Info("Agent is terminating", "agent", payload.AgentName, "reason", payload.Reason)
+	case "stopping":
+		decommission := payload.Reason == "decommission"
+		h.state.MarkStopped(payload.AgentName, decommission)
+		h.logger.Info("Agent is stopping", "agent", payload.AgentName, "reason", payload.Reason)
+	default:
+		h.state.UpdateHeartbeat(payload.AgentName)
+		h.logger.Debug("Heartbeat received", "agent", payload.AgentName)
+	}
+	if payload.AgentVersion != "" {
+		h.state.SetAgentVersion(payload.AgentName, payload.AgentVersion)
+	}
+	// Heartbeats are stamped as they are sent, unlike metrics which carry
+	// their collection time, so they show the agent's clock skew
+	if !payload.Timestamp.IsZero() {
+		h.state.SetClockSkew(payload.AgentName, payload.Timestamp.Sub(receivedAt))
+	}
+	if payload.HeartbeatIntervalSeconds > 0 {
+		h.state.SetHeartbeatInterval(payload.AgentName, time.Duration(payload.HeartbeatIntervalSeconds)*time.Second)
+	}
+	for _, fn := range h.onBeat {
+		fn(payload)
+	}
 
 	// Return success
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]string{
-		"status": "success",
+	if err := json.NewEncoder(w).Encode(IngestResponse{
+		Status:  "success",
+		Refresh: h.state.TakeRefresh(payload.AgentName),
 	}); err != nil {
-		log.Printf("Error encoding response: %v", err)
+		h.logger.Error("Error encoding response", logging.Err(err))
 	}
 }
 
@@ -141,32 +350,133 @@ func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
 	activeAlerts := h.state.GetActiveAlerts()
 
 	health := map[string]interface{}{
-		"status":         "ok",
-		"agents_online":  countOnlineAgents(agents),
-		"agents_offline": countOfflineAgents(agents),
-		"active_alerts":  len(activeAlerts),
+		"status":          "ok",
+		"agents_online":   countOnlineAgents(agents),
+		"agents_degraded": countDegradedAgents(agents),
+		"agents_offline":  countOfflineAgents(agents),
+		"active_alerts":   len(activeAlerts),
+	}
+	if score, ok := fleetHealthScore(agents, time.Now()); ok {
+		health["health_score"] = score
+	}
+	if h.channelStatus != nil {
+		channels := []server.NotificationChannelStatus{}
+		for _, status := range h.channelStatus() {
+			channels = append(channels, server.NotificationChannelStatus{
+				Channel:             status.Channel,
+				Healthy:             status.Healthy,
+				LastCheck:           timeOrNil(status.LastCheck),
+				LastSuccess:         timeOrNil(status.LastSuccess),
+				LastError:           status.LastError,
+				FailingSince:        timeOrNil(status.FailingSince),
+				ConsecutiveFailures: status.ConsecutiveFailures,
+			})
+		}
+		health["notification_channels"] = channels
+	}
+	if h.haStatus != nil {
+		health["ha"] = h.haStatus()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(health); err != nil {
-		log.Printf("Error encoding health response: %v", err)
+		h.logger.Error("Error encoding health response", logging.Err(err))
+	}
+}
+
+// timeOrNil returns nil for the zero time, so it is left out of responses
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// HandleVersion handles GET /api/v1/version
+func (h *Handler) HandleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(version.Get()); err != nil {
+		h.logger.Error("Error encoding version response", logging.Err(err))
+	}
+}
+
+// checkLimits reports the first payload limit a push exceeds
+func (h *Handler) checkLimits(payload *server.MetricsPushPayload) error {
+	containers := payload.SystemMetrics.Containers
+	if h.limits.MaxContainers > 0 && len(containers) > h.limits.MaxContainers {
+		return fmt.Errorf("payload has %d containers, the limit is %d (server.max_containers_per_push)", len(containers), h.limits.MaxContainers)
+	}
+	if h.limits.MaxLabels > 0 {
+		for _, c := range containers {
+			if len(c.Labels) > h.limits.MaxLabels {
+				return fmt.Errorf("container %s has %d labels, the limit is %d (server.max_labels_per_container)", c.Name, len(c.Labels), h.limits.MaxLabels)
+			}
+		}
+	}
+	return nil
+}
+
+// payloadFormat is how an ingest request body is encoded: MessagePack if
+// its Content-Type says so, and otherwise JSON
+func payloadFormat(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if msgpack.IsContentType(mediaType) {
+		return "MessagePack"
+	}
+	return "JSON"
+}
+
+// decodePayload decodes a push or heartbeat in its payloadFormat
+func decodePayload(r *http.Request, body io.Reader, v interface{}) error {
+	if payloadFormat(r) == "MessagePack" {
+		return msgpack.NewDecoder(body).Decode(v)
 	}
+	return json.NewDecoder(body).Decode(v)
 }
 
 // readBody handles reading and decompressing request body
 func (h *Handler) readBody(r *http.Request) (io.ReadCloser, error) {
 	// Check if body is gzip compressed
 	if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
-		reader, err := gzip.NewReader(r.Body)
-		if err != nil {
+		reader, _ := gzipReaders.Get().(*gzip.Reader)
+		if reader == nil {
+			reader = new(gzip.Reader)
+		}
+		if err := reader.Reset(r.Body); err != nil {
+			gzipReaders.Put(reader)
 			return nil, err
 		}
-		return reader, nil
+		return &pooledGzipReader{reader}, nil
 	}
 	return r.Body, nil
 }
 
-// getEC2InstanceID extracts EC2 instance ID from metadata
+// gzipReaders reuses decompressors across pushes; each holds sizeable
+// buffers and a busy server decompresses every push
+var gzipReaders sync.Pool
+
+// pooledGzipReader returns its reader to the pool when closed
+type pooledGzipReader struct {
+	*gzip.Reader
+}
+
+func (r *pooledGzipReader) Close() error {
+	if r.Reader == nil {
+		return nil
+	}
+	err := r.Reader.Close()
+	gzipReaders.Put(r.Reader)
+	r.Reader = nil
+	return err
+}
+
+// getEC2InstanceID extracts EC2 instance ID from metadata. Newer agents may
+// only send cloud metadata, see getCloudMetadata.
 func (h *Handler) getEC2InstanceID(metadata *server.EC2Metadata) string {
 	if metadata != nil {
 		return metadata.InstanceID
@@ -174,21 +484,46 @@ func (h *Handler) getEC2InstanceID(metadata *server.EC2Metadata) string {
 	return ""
 }
 
+// getCloudMetadata returns the payload's cloud metadata, falling back to
+// EC2 metadata from agents that predate the generic form
+func (h *Handler) getCloudMetadata(payload *server.MetricsPushPayload) *server.CloudMetadata {
+	if payload.CloudMetadata != nil {
+		return payload.CloudMetadata
+	}
+	return payload.EC2Metadata.CloudMetadata()
+}
+
 // convertContainers converts metrics containers to server container states
 func (h *Handler) convertContainers(containers []metrics.ContainerMetrics) []server.ContainerState {
 	result := make([]server.ContainerState, len(containers))
 	for i, c := range containers {
 		result[i] = server.ContainerState{
-			ID:            c.ID,
-			Name:          c.Name,
-			Image:         c.Image,
-			State:         c.State,
-			Health:        c.Health,
-			CPUPercent:    c.CPUPercent,
-			MemoryPercent: calculateMemoryPercent(c.MemoryUsage, c.MemoryLimit),
-			MemoryUsage:   c.MemoryUsage,
-			MemoryLimit:   c.MemoryLimit,
-			RestartCount:  c.RestartCount,
+			ID:                  c.ID,
+			Name:                c.Name,
+			Image:               c.Image,
+			ImageID:             c.ImageID,
+			State:               c.State,
+			Health:              c.Health,
+			HealthCheckExitCode: c.HealthCheckExitCode,
+			HealthCheckOutput:   c.HealthCheckOutput,
+			UpdateAvailable:     c.UpdateAvailable,
+			Remediation:         c.Remediation,
+			Thresholds:          c.Thresholds,
+			CPUPercent:          c.CPUPercent,
+			MemoryPercent:       calculateMemoryPercent(c.MemoryUsage, c.MemoryLimit),
+			MemoryUsage:         c.MemoryUsage,
+			MemoryLimit:         c.MemoryLimit,
+			RestartCount:        c.RestartCount,
+			ExitCode:            c.ExitCode,
+			OOMKilled:           c.OOMKilled,
+			FinishedAt:          c.FinishedAt,
+			StateError:          c.StateError,
+			OOMKillsLastHour:    c.OOMKillsLastHour,
+			Labels:              c.Labels,
+		}
+		if project, service := c.Labels["com.docker.compose.project"], c.Labels["com.docker.compose.service"]; project != "" && service != "" {
+			result[i].ComposeService = project + "/" + service
+			result[i].ComposeNumber = c.Labels["com.docker.compose.container-number"]
 		}
 	}
 	return result
@@ -209,13 +544,180 @@ func (h *Handler) HandleGetAgents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	agents := h.state.GetAllAgents()
+	// Optional cloud tag filters: ?tag=env=production&tag=team (all must match)
+	tagFilters := r.URL.Query()["tag"]
 
+	// Stream agent by agent so a large fleet is never copied in full
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(agents); err != nil {
-		log.Printf("Error encoding agents response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	written := 0
+	now := time.Now()
+	err := h.state.ForEachAgent(func(agent *server.ServerState) error {
+		if len(tagFilters) > 0 && !matchesTags(agent, tagFilters) {
+			return nil
+		}
+		agent.HealthScore = agent.HealthAt(now)
+		data, err := json.Marshal(agent)
+		if err != nil {
+			return err
+		}
+
+		separator := ","
+		if written == 0 {
+			separator = "["
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		written++
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		// Part of the body may already be sent, so the client sees it truncated
+		h.logger.Error("Error encoding agents response", logging.Err(err))
+		return
 	}
+
+	if written == 0 {
+		io.WriteString(w, "[")
+	}
+	io.WriteString(w, "]\n")
+}
+
+// DeleteAgentScopes are the scopes DELETE /api/v1/agents/{name} requires.
+// Removing an agent also stops its offline alerts, so agent keys, which
+// aren't tied to one agent, can't do it.
+var DeleteAgentScopes = []string{"admin:write"}
+
+// HandleDeleteAgent handles DELETE /api/v1/agents/{name}, used by scale-in
+// lifecycle hooks and by agents shutting down cleanly with an admin key
+func (h *Handler) HandleDeleteAgent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	agentName := strings.TrimPrefix(r.URL.Path, "/api/v1/agents/")
+	if agentName == "" {
+		http.Error(w, "Agent name required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.state.RemoveAgent(agentName) {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+
+	h.logger.Info("Agent deregistered", "agent", agentName)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+	}); err != nil {
+		h.logger.Error("Error encoding response", logging.Err(err))
+	}
+}
+
+// RefreshAgentScopes are the scopes POST /api/v1/agents/{name}/refresh
+// requires. A refresh costs the agent a full collection and push, so it
+// takes a scope of its own, which the dashboard's key can hold.
+var RefreshAgentScopes = []string{"agents:refresh"}
+
+// HandleAgentRefresh handles POST /api/v1/agents/{name}/refresh, asking the
+// agent to collect and push right away instead of at its next push. The
+// agent hears of it with its next heartbeat or push.
+func (h *Handler) HandleAgentRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	agentName, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/agents/"), "/refresh")
+	if !ok || agentName == "" {
+		http.Error(w, "Agent name required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.state.RequestRefresh(agentName) {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	h.logger.Debug("Refresh requested", "agent", agentName)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"status": "requested",
+	}); err != nil {
+		h.logger.Error("Error encoding response", logging.Err(err))
+	}
+}
+
+// HandleAgentMaintenance handles PUT and DELETE
+// /api/v1/agents/{name}/maintenance, starting and ending maintenance
+func (h *Handler) HandleAgentMaintenance(w http.ResponseWriter, r *http.Request) {
+	agentName, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/agents/"), "/maintenance")
+	if !ok || agentName == "" {
+		http.Error(w, "Agent name required", http.StatusBadRequest)
+		return
+	}
+
+	var until time.Time
+	var reason string
+	switch r.Method {
+	case http.MethodPut:
+		r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxRequestSize)
+
+		var request server.MaintenanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		duration, err := time.ParseDuration(request.Duration)
+		if err != nil || duration <= 0 {
+			http.Error(w, "duration must be a positive duration, e.g. \"2h\"", http.StatusBadRequest)
+			return
+		}
+		until, reason = time.Now().Add(duration), request.Reason
+
+	case http.MethodDelete:
+		// Zero until ends maintenance
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.state.SetMaintenance(agentName, until, reason) {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+
+	if until.IsZero() {
+		h.logger.Info("Agent out of maintenance", "agent", agentName)
+	} else {
+		h.logger.Info("Agent in maintenance", "agent", agentName, "until", until, "reason", reason)
+	}
+
+	agent, _ := h.state.GetAgent(agentName)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(agent); err != nil {
+		h.logger.Error("Error encoding agent response", logging.Err(err))
+	}
+}
+
+// matchesTags checks an agent's cloud tags against "key" or "key=value" filters
+func matchesTags(agent *server.ServerState, filters []string) bool {
+	if agent.Cloud == nil {
+		return false
+	}
+	for _, filter := range filters {
+		key, value, hasValue := strings.Cut(filter, "=")
+		actual, exists := agent.Cloud.Tags[key]
+		if !exists || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
 }
 
 // HandleGetAgent handles GET /api/v1/agents/{name}
@@ -237,14 +739,45 @@ func (h *Handler) HandleGetAgent(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Agent not found", http.StatusNotFound)
 		return
 	}
+	agent.HealthScore = agent.HealthAt(time.Now())
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(agent); err != nil {
-		log.Printf("Error encoding agent response: %v", err)
+		h.logger.Error("Error encoding agent response", logging.Err(err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
 
+// HandleAgentDiff handles GET /api/v1/agents/diff?a=web-1&b=web-2: how two
+// agents differ in system info, key metrics and containers
+func (h *Handler) HandleAgentDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	nameA, nameB := r.URL.Query().Get("a"), r.URL.Query().Get("b")
+	if nameA == "" || nameB == "" {
+		http.Error(w, "Agents a and b required", http.StatusBadRequest)
+		return
+	}
+
+	a, exists := h.state.GetAgent(nameA)
+	if !exists {
+		http.Error(w, fmt.Sprintf("Agent %q not found", nameA), http.StatusNotFound)
+		return
+	}
+	b, exists := h.state.GetAgent(nameB)
+	if !exists {
+		http.Error(w, fmt.Sprintf("Agent %q not found", nameB), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(server.DiffAgents(a, b)); err != nil {
+		h.logger.Error("Error encoding agent diff response", logging.Err(err))
+	}
+}
+
 // HandleGetAlerts handles GET /api/v1/alerts
 func (h *Handler) HandleGetAlerts(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -252,15 +785,293 @@ func (h *Handler) HandleGetAlerts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	alerts := h.state.GetActiveAlerts()
+	// Optional ?status=active|acknowledged|resolved|all (default: active)
+	var alerts []*server.Alert
+	switch status := r.URL.Query().Get("status"); status {
+	case "", "active":
+		alerts = h.state.GetActiveAlerts()
+	case "acknowledged", "resolved", "all":
+		alerts = h.state.GetAlertsByStatus(status)
+	default:
+		http.Error(w, "Invalid status filter", http.StatusBadRequest)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(alerts); err != nil {
-		log.Printf("Error encoding alerts response: %v", err)
+		h.logger.Error("Error encoding alerts response", logging.Err(err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
 
+// defaultNoisyWindow and defaultNoisyTop are what GET /api/v1/alerts/noisy
+// covers without ?window and ?top
+const (
+	defaultNoisyWindow = 7 * 24 * time.Hour
+	defaultNoisyTop    = 10
+)
+
+// HandleNoisyAlerts handles GET /api/v1/alerts/noisy?window=7d&top=10: the
+// alert fingerprints and agents that fired most often, for alert hygiene
+// reviews. Alerts are kept in memory, so the window reaches back at most
+// to when the server started.
+func (h *Handler) HandleNoisyAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	window := defaultNoisyWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		var err error
+		if window, err = parseWindow(value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	top := defaultNoisyTop
+	if value := r.URL.Query().Get("top"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "top must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+
+	to := time.Now()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.state.NoisyAlerts(to.Add(-window), to, top)); err != nil {
+		h.logger.Error("Error encoding noisy alerts response", logging.Err(err))
+	}
+}
+
+// HandleDryRunAlerts handles GET /api/v1/alerts/dry-run?rule=name: the alerts
+// rules with dry_run set would have raised, newest first, to tune them
+// before they notify anyone
+func (h *Handler) HandleDryRunAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.alerting == nil {
+		http.Error(w, "Alerting is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	rule := r.URL.Query().Get("rule")
+	alerts := []server.Alert{}
+	for _, alert := range h.alerting.DryRunAlerts() {
+		if rule != "" && alert.AlertType != rule {
+			continue
+		}
+		alerts = append(alerts, server.Alert{
+			ID:          alert.ID,
+			AgentName:   alert.AgentName,
+			AlertType:   alert.AlertType,
+			Severity:    alert.Severity,
+			Message:     alert.Message,
+			Details:     alert.Details,
+			TriggeredAt: alert.TriggeredAt,
+			Status:      alert.Status,
+			Annotations: alert.Annotations,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(alerts); err != nil {
+		h.logger.Error("Error encoding dry-run alerts response", logging.Err(err))
+	}
+}
+
+// HandleAlertAction handles POST /api/v1/alerts/{id}/ack,
+// POST /api/v1/alerts/{id}/resolve and PUT /api/v1/alerts/{id}/assign
+func (h *Handler) HandleAlertAction(w http.ResponseWriter, r *http.Request) {
+	alertID, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v1/alerts/"), "/")
+	if !ok || alertID == "" {
+		http.Error(w, "Alert ID and action required", http.StatusBadRequest)
+		return
+	}
+
+	method := http.MethodPost
+	if action == "assign" {
+		method = http.MethodPut
+	}
+	if r.Method != method {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var found bool
+	switch action {
+	case "ack":
+		found = h.state.AcknowledgeAlert(alertID)
+	case "resolve":
+		found = h.state.ResolveAlert(alertID)
+	case "assign":
+		var req server.AlertAssignment
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		found = h.state.AssignAlert(alertID, strings.TrimSpace(req.Assignee))
+	default:
+		http.Error(w, "Unknown alert action", http.StatusNotFound)
+		return
+	}
+
+	if !found {
+		http.Error(w, "Alert not found", http.StatusNotFound)
+		return
+	}
+
+	h.logger.Info("Alert updated", "alert_id", alertID, "action", action)
+
+	alert, _ := h.state.GetAlert(alertID)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(alert); err != nil {
+		h.logger.Error("Error encoding alert response", logging.Err(err))
+	}
+}
+
+// HandleExternalAlert handles POST /api/v1/alerts/external, which lets
+// other systems raise alerts that go through deduplication, silences and
+// notification like the server's own
+func (h *Handler) HandleExternalAlert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.raiseExternal == nil {
+		http.Error(w, "Alerting is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxRequestSize)
+	var req server.ExternalAlertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	req.AgentName = strings.TrimSpace(req.AgentName)
+	req.Message = strings.TrimSpace(req.Message)
+	if req.AgentName == "" || req.Message == "" {
+		http.Error(w, "agent_name and message are required", http.StatusBadRequest)
+		return
+	}
+	if req.AlertType == "" {
+		req.AlertType = "external"
+	}
+	if req.Source == "" {
+		req.Source = "external"
+	}
+	switch req.Severity {
+	case "":
+		req.Severity = "warning"
+	case "critical", "warning", "info":
+	default:
+		http.Error(w, "severity must be critical, warning or info", http.StatusBadRequest)
+		return
+	}
+
+	alert := h.raiseExternal(alerting.ExternalAlert{
+		Source:    req.Source,
+		AgentName: req.AgentName,
+		AlertType: req.AlertType,
+		Severity:  req.Severity,
+		Message:   req.Message,
+		Details:   req.Details,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if alert == nil {
+		// Already raised within the deduplication window, or someone owns it
+		if err := json.NewEncoder(w).Encode(map[string]string{"status": "duplicate"}); err != nil {
+			h.logger.Error("Error encoding response", logging.Err(err))
+		}
+		return
+	}
+
+	h.logger.Info("External alert", "source", req.Source, "alert_type", req.AlertType, "agent", req.AgentName)
+	stored, _ := h.state.GetAlert(alert.ID)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(stored); err != nil {
+		h.logger.Error("Error encoding alert response", logging.Err(err))
+	}
+}
+
+// HandleSilences handles GET and POST /api/v1/silences
+func (h *Handler) HandleSilences(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(h.state.GetSilences()); err != nil {
+			h.logger.Error("Error encoding silences response", logging.Err(err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxRequestSize)
+
+		var silence server.Silence
+		if err := json.NewDecoder(r.Body).Decode(&silence); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if silence.EndsAt.IsZero() || !silence.EndsAt.After(time.Now()) {
+			http.Error(w, "ends_at must be in the future", http.StatusBadRequest)
+			return
+		}
+		if !silence.StartsAt.IsZero() && !silence.EndsAt.After(silence.StartsAt) {
+			http.Error(w, "ends_at must be after starts_at", http.StatusBadRequest)
+			return
+		}
+
+		created := h.state.AddSilence(silence)
+		h.logger.Info("Silence created",
+			"silence_id", created.ID, "agent", created.AgentName, "alert_type", created.AlertType, "until", created.EndsAt)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(created); err != nil {
+			h.logger.Error("Error encoding silence response", logging.Err(err))
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleDeleteSilence handles DELETE /api/v1/silences/{id}
+func (h *Handler) HandleDeleteSilence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	silenceID := strings.TrimPrefix(r.URL.Path, "/api/v1/silences/")
+	if silenceID == "" {
+		http.Error(w, "Silence ID required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.state.DeleteSilence(silenceID) {
+		http.Error(w, "Silence not found", http.StatusNotFound)
+		return
+	}
+
+	h.logger.Info("Silence removed", "silence_id", silenceID)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+	}); err != nil {
+		h.logger.Error("Error encoding response", logging.Err(err))
+	}
+}
+
 // HandleEventsSSE handles GET /api/v1/events (Server-Sent Events)
 func (h *Handler) HandleEventsSSE(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -281,12 +1092,9 @@ func (h *Handler) HandleEventsSSE(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Send initial data
-	h.sendSSEUpdate(w, flusher)
-
-	// Create a ticker to send updates every 2 seconds
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
+	// Frames are built once per tick by the broadcaster and shared by all clients
+	frames, unsubscribe := h.events.subscribe()
+	defer unsubscribe()
 
 	// Listen for client disconnect
 	ctx := r.Context()
@@ -294,46 +1102,32 @@ func (h *Handler) HandleEventsSSE(w http.ResponseWriter, r *http.Request) {
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("SSE client disconnected")
+			h.logger.Debug("SSE client disconnected")
 			return
-		case <-ticker.C:
-			h.sendSSEUpdate(w, flusher)
+		case frame := <-frames:
+			if _, err := w.Write(frame); err != nil {
+				h.logger.Error("Error writing SSE data", logging.Err(err))
+				return
+			}
+			flusher.Flush()
 		}
 	}
 }
 
-// sendSSEUpdate sends a single SSE update with current state
-func (h *Handler) sendSSEUpdate(w http.ResponseWriter, flusher http.Flusher) {
-	agents := h.state.GetAllAgents()
-	alerts := h.state.GetActiveAlerts()
-
-	data := map[string]interface{}{
-		"agents": agents,
-		"alerts": alerts,
-		"timestamp": time.Now().Unix(),
-	}
-
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		log.Printf("Error marshaling SSE data: %v", err)
-		return
-	}
-
-	// Send SSE message
-	if _, err := w.Write([]byte("data: ")); err != nil {
-		log.Printf("Error writing SSE prefix: %v", err)
-		return
-	}
-	if _, err := w.Write(jsonData); err != nil {
-		log.Printf("Error writing SSE data: %v", err)
-		return
+// fleetHealthScore is the mean health score of the agents that are
+// reporting, as the dashboard shows it; false if none are
+func fleetHealthScore(agents []*server.ServerState, now time.Time) (int, bool) {
+	total, reporting := 0, 0
+	for _, agent := range agents {
+		if agent.Status == "online" || agent.Status == "degraded" {
+			total += agent.HealthAt(now)
+			reporting++
+		}
 	}
-	if _, err := w.Write([]byte("\n\n")); err != nil {
-		log.Printf("Error writing SSE suffix: %v", err)
-		return
+	if reporting == 0 {
+		return 0, false
 	}
-
-	flusher.Flush()
+	return int(math.Round(float64(total) / float64(reporting))), true
 }
 
 // Helper functions
@@ -347,6 +1141,16 @@ func countOnlineAgents(agents []*server.ServerState) int {
 	return count
 }
 
+func countDegradedAgents(agents []*server.ServerState) int {
+	count := 0
+	for _, agent := range agents {
+		if agent.Status == "degraded" {
+			count++
+		}
+	}
+	return count
+}
+
 func countOfflineAgents(agents []*server.ServerState) int {
 	count := 0
 	for _, agent := range agents {
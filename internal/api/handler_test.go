@@ -6,10 +6,15 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/anurag/saviour/internal/alerting"
+	"github.com/anurag/saviour/internal/ha"
+	"github.com/anurag/saviour/internal/msgpack"
 	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/internal/version"
 	"github.com/anurag/saviour/pkg/metrics"
 )
 
@@ -72,6 +77,97 @@ func TestHandleMetricsPush_ValidRequest(t *testing.T) {
 	}
 }
 
+func TestHandleMetricsPush_CallsPushHook(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	var pushed []string
+	handler.OnMetricsPush(func(agentName string) {
+		// The hook runs after the state is updated
+		if _, exists := state.GetAgent(agentName); !exists {
+			t.Errorf("Expected %s in state when the hook runs", agentName)
+		}
+		pushed = append(pushed, agentName)
+	})
+
+	body, _ := json.Marshal(server.MetricsPushPayload{AgentName: "test-agent", Timestamp: time.Now()})
+	req := httptest.NewRequest("POST", "/api/v1/metrics/push", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.HandleMetricsPush(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if len(pushed) != 1 || pushed[0] != "test-agent" {
+		t.Errorf("Expected hook called once for test-agent, got %v", pushed)
+	}
+
+	// Every registered hook runs
+	handler.OnMetricsPush(func(agentName string) {
+		pushed = append(pushed, "second:"+agentName)
+	})
+	req = httptest.NewRequest("POST", "/api/v1/metrics/push", bytes.NewReader(body))
+	handler.HandleMetricsPush(httptest.NewRecorder(), req)
+	if len(pushed) != 3 || pushed[2] != "second:test-agent" {
+		t.Errorf("Expected both hooks called, got %v", pushed)
+	}
+}
+
+func TestHandleMetricsPush_Delta(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	push := func(payload server.MetricsPushPayload) int {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", "/api/v1/metrics/push", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.HandleMetricsPush(rec, req)
+		return rec.Code
+	}
+
+	delta := server.MetricsPushPayload{
+		AgentName:    "test-agent",
+		Sequence:     2,
+		Delta:        true,
+		BaseSequence: 1,
+		SystemMetrics: metrics.SystemMetrics{
+			Containers: []metrics.ContainerMetrics{{ID: "db", Name: "db", State: "exited"}},
+		},
+	}
+
+	// Without the base, the agent is asked for a full push
+	if code := push(delta); code != http.StatusConflict {
+		t.Fatalf("Expected status 409 for a delta without a base, got %d", code)
+	}
+
+	full := server.MetricsPushPayload{
+		AgentName: "test-agent",
+		Sequence:  1,
+		SystemMetrics: metrics.SystemMetrics{
+			Containers: []metrics.ContainerMetrics{
+				{ID: "web", Name: "web", State: "running"},
+				{ID: "db", Name: "db", State: "running"},
+			},
+		},
+	}
+	if code := push(full); code != http.StatusOK {
+		t.Fatalf("Expected status 200 for a full push, got %d", code)
+	}
+	if code := push(delta); code != http.StatusOK {
+		t.Fatalf("Expected status 200 for a delta on the stored push, got %d", code)
+	}
+
+	agent, _ := state.GetAgent("test-agent")
+	if len(agent.Containers) != 2 {
+		t.Fatalf("Expected both containers after the delta, got %d", len(agent.Containers))
+	}
+	for _, c := range agent.Containers {
+		if c.ID == "db" && c.State != "exited" {
+			t.Errorf("Expected db to be exited, got %s", c.State)
+		}
+	}
+}
+
 func TestHandleMetricsPush_InvalidMethod(t *testing.T) {
 	state := server.NewStateStore()
 	handler := NewHandler(state)
@@ -103,6 +199,93 @@ func TestHandleMetricsPush_RequestTooLarge(t *testing.T) {
 	}
 }
 
+func TestHandleMetricsPush_ConfiguredRequestSize(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+	handler.SetLimits(PayloadLimits{MaxRequestSize: 1024})
+
+	payload := server.MetricsPushPayload{
+		AgentName: "test-agent",
+		Timestamp: time.Now(),
+		SystemMetrics: metrics.SystemMetrics{
+			Containers: []metrics.ContainerMetrics{{ID: "c1", Labels: map[string]string{"padding": strings.Repeat("x", 2048)}}},
+		},
+	}
+	body, _ := json.Marshal(payload)
+
+	// Same check without a Content-Length, as with chunked uploads
+	req := httptest.NewRequest("POST", "/api/v1/metrics/push", bytes.NewReader(body))
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+
+	handler.HandleMetricsPush(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, exists := state.GetAgent("test-agent"); exists {
+		t.Error("Expected no agent to be stored")
+	}
+}
+
+func TestHandleMetricsPush_PayloadLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers []metrics.ContainerMetrics
+		wantCode   int
+		wantError  string
+	}{
+		{
+			name:       "within limits",
+			containers: []metrics.ContainerMetrics{{ID: "c1", Name: "web", Labels: map[string]string{"a": "1", "b": "2"}}},
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "too many containers",
+			containers: []metrics.ContainerMetrics{{ID: "c1"}, {ID: "c2"}, {ID: "c3"}},
+			wantCode:   http.StatusRequestEntityTooLarge,
+			wantError:  "3 containers, the limit is 2",
+		},
+		{
+			name:       "too many labels",
+			containers: []metrics.ContainerMetrics{{ID: "c1", Name: "web", Labels: map[string]string{"a": "1", "b": "2", "c": "3"}}},
+			wantCode:   http.StatusRequestEntityTooLarge,
+			wantError:  "container web has 3 labels, the limit is 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := server.NewStateStore()
+			handler := NewHandler(state)
+			handler.SetLimits(PayloadLimits{MaxRequestSize: MaxRequestSize, MaxContainers: 2, MaxLabels: 2})
+
+			payload := server.MetricsPushPayload{
+				AgentName:     "test-agent",
+				Timestamp:     time.Now(),
+				SystemMetrics: metrics.SystemMetrics{Timestamp: time.Now(), Containers: tt.containers},
+			}
+			body, _ := json.Marshal(payload)
+			req := httptest.NewRequest("POST", "/api/v1/metrics/push", bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+
+			handler.HandleMetricsPush(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantError != "" {
+				if !strings.Contains(rec.Body.String(), tt.wantError) {
+					t.Errorf("Expected error containing %q, got %q", tt.wantError, rec.Body.String())
+				}
+				if _, exists := state.GetAgent("test-agent"); exists {
+					t.Error("Expected rejected push not to be stored")
+				}
+			}
+		})
+	}
+}
+
 func TestHandleMetricsPush_InvalidJSON(t *testing.T) {
 	state := server.NewStateStore()
 	handler := NewHandler(state)
@@ -176,6 +359,57 @@ func TestHandleMetricsPush_WithEC2Metadata(t *testing.T) {
 	if agent.EC2InstanceID != "i-1234567890abcdef0" {
 		t.Errorf("Expected EC2 instance ID 'i-1234567890abcdef0', got '%s'", agent.EC2InstanceID)
 	}
+
+	// Older agents only send EC2 metadata, which is mapped to the generic form
+	if agent.Cloud == nil {
+		t.Fatal("Expected cloud metadata derived from EC2 metadata")
+	}
+	if agent.Cloud.Provider != "aws" || agent.Cloud.Zone != "us-west-2a" {
+		t.Errorf("Expected aws provider in us-west-2a, got %s in %s", agent.Cloud.Provider, agent.Cloud.Zone)
+	}
+}
+
+func TestHandleMetricsPush_WithCloudMetadata(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	payload := server.MetricsPushPayload{
+		AgentName: "test-agent",
+		Timestamp: time.Now(),
+		CloudMetadata: &server.CloudMetadata{
+			Provider:     "gcp",
+			InstanceID:   "1234567890123456789",
+			InstanceType: "e2-standard-4",
+			Region:       "us-central1",
+			Zone:         "us-central1-a",
+		},
+		SystemMetrics: metrics.SystemMetrics{
+			Timestamp: time.Now(),
+			AgentName: "test-agent",
+		},
+	}
+
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest("POST", "/api/v1/metrics/push", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.HandleMetricsPush(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+
+	agent, exists := state.GetAgent("test-agent")
+	if !exists {
+		t.Fatal("Agent not found in state")
+	}
+
+	if agent.Cloud == nil || agent.Cloud.Provider != "gcp" {
+		t.Fatalf("Expected gcp cloud metadata, got %+v", agent.Cloud)
+	}
+	if agent.EC2InstanceID != "" {
+		t.Errorf("Expected no EC2 instance ID, got '%s'", agent.EC2InstanceID)
+	}
 }
 
 func TestHandleMetricsPush_WithContainers(t *testing.T) {
@@ -278,6 +512,75 @@ func TestHandleMetricsPush_GzipCompressed(t *testing.T) {
 	}
 }
 
+func TestHandleMetricsPush_Msgpack(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	data, _ := msgpack.Marshal(server.MetricsPushPayload{
+		AgentName: "test-agent",
+		Timestamp: time.Now(),
+		SystemMetrics: metrics.SystemMetrics{
+			Timestamp:  time.Now(),
+			CPU:        metrics.CPUMetrics{UsagePercent: 37},
+			Containers: []metrics.ContainerMetrics{{ID: "c1", Name: "api"}},
+		},
+	})
+	req := httptest.NewRequest("POST", "/api/v1/metrics/push", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/x-msgpack")
+	rec := httptest.NewRecorder()
+	handler.HandleMetricsPush(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	agent, exists := state.GetAgent("test-agent")
+	if !exists || agent.SystemMetrics.CPU.UsagePercent != 37 || len(agent.SystemMetrics.Containers) != 1 {
+		t.Errorf("Expected the decoded push stored, got %+v", agent)
+	}
+
+	// JSON sent as MessagePack is rejected as MessagePack
+	req = httptest.NewRequest("POST", "/api/v1/metrics/push", strings.NewReader(`{"agent_name":"test-agent"}`))
+	req.Header.Set("Content-Type", msgpack.ContentType)
+	rec = httptest.NewRecorder()
+	handler.HandleMetricsPush(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Invalid MessagePack payload") {
+		t.Errorf("Expected 400 Invalid MessagePack payload, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleMetricsPush_GzipReaderReuse(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	push := func(body []byte) int {
+		req := httptest.NewRequest("POST", "/api/v1/metrics/push", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.HandleMetricsPush(rec, req)
+		return rec.Code
+	}
+
+	// A corrupt body in between must not break the pooled readers
+	if code := push([]byte("not gzip")); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a corrupt body, got %d", code)
+	}
+
+	for _, name := range []string{"agent-a", "agent-b", "agent-c"} {
+		jsonData, _ := json.Marshal(server.MetricsPushPayload{AgentName: name, Timestamp: time.Now()})
+		var buf bytes.Buffer
+		gzWriter := gzip.NewWriter(&buf)
+		_, _ = gzWriter.Write(jsonData)
+		gzWriter.Close()
+
+		if code := push(buf.Bytes()); code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", name, code)
+		}
+		if _, exists := state.GetAgent(name); !exists {
+			t.Errorf("Expected %s in state", name)
+		}
+	}
+}
+
 func TestHandleHeartbeat_ValidRequest(t *testing.T) {
 	state := server.NewStateStore()
 	handler := NewHandler(state)
@@ -312,111 +615,437 @@ func TestHandleHeartbeat_ValidRequest(t *testing.T) {
 	}
 }
 
-func TestHandleHeartbeat_InvalidMethod(t *testing.T) {
+func TestHandleHeartbeat_AgentVersion(t *testing.T) {
 	state := server.NewStateStore()
 	handler := NewHandler(state)
 
-	req := httptest.NewRequest("GET", "/api/v1/heartbeat", nil)
+	body, _ := json.Marshal(server.HeartbeatPayload{
+		AgentName:    "test-agent",
+		Timestamp:    time.Now(),
+		AgentVersion: "v1.4.0",
+	})
+	req := httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader(body))
 	rec := httptest.NewRecorder()
 
 	handler.HandleHeartbeat(rec, req)
 
-	if rec.Code != http.StatusMethodNotAllowed {
-		t.Errorf("Expected status 405, got %d", rec.Code)
+	agent, exists := state.GetAgent("test-agent")
+	if !exists {
+		t.Fatal("Agent not found in state")
+	}
+	if agent.AgentVersion != "v1.4.0" {
+		t.Errorf("Expected agent version v1.4.0, got '%s'", agent.AgentVersion)
 	}
 }
 
-func TestHandleHeartbeat_InvalidJSON(t *testing.T) {
+func TestHandleAgentRefresh(t *testing.T) {
 	state := server.NewStateStore()
 	handler := NewHandler(state)
+	state.UpdateAgent(&server.ServerState{AgentName: "web-1"})
 
-	req := httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader([]byte("invalid json")))
 	rec := httptest.NewRecorder()
+	handler.HandleAgentRefresh(rec, httptest.NewRequest("POST", "/api/v1/agents/web-1/refresh", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", rec.Code)
+	}
 
-	handler.HandleHeartbeat(rec, req)
+	// The next heartbeat is told to push, the one after isn't
+	for i, want := range []bool{true, false} {
+		body, _ := json.Marshal(server.HeartbeatPayload{AgentName: "web-1", Timestamp: time.Now()})
+		rec = httptest.NewRecorder()
+		handler.HandleHeartbeat(rec, httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader(body)))
+		var resp IngestResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		if resp.Status != "success" || resp.Refresh != want {
+			t.Errorf("Heartbeat %d: expected refresh %v, got %+v", i+1, want, resp)
+		}
+	}
 
-	if rec.Code != http.StatusBadRequest {
-		t.Errorf("Expected status 400, got %d", rec.Code)
+	rec = httptest.NewRecorder()
+	handler.HandleAgentRefresh(rec, httptest.NewRequest("POST", "/api/v1/agents/unknown/refresh", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown agent, got %d", rec.Code)
 	}
 }
 
-func TestHandleHeartbeat_MissingAgentName(t *testing.T) {
+func TestHandleAgentRefresh_RequiresScope(t *testing.T) {
 	state := server.NewStateStore()
 	handler := NewHandler(state)
+	state.UpdateAgent(&server.ServerState{AgentName: "web-1"})
+	auth := NewAuthConfig([]APIKey{
+		{Key: "agent-key", Name: "agents", Scopes: []string{"metrics:write", "heartbeat:write"}},
+		{Key: "dashboard-key", Name: "dashboard", Scopes: []string{"agents:refresh"}},
+	})
+	refresh := auth.AuthMiddleware(RefreshAgentScopes)(http.HandlerFunc(handler.HandleAgentRefresh))
 
-	payload := server.HeartbeatPayload{
-		Timestamp: time.Now(),
+	tests := []struct {
+		key      string
+		expected int
+	}{
+		{"", http.StatusUnauthorized},
+		{"agent-key", http.StatusForbidden},
+		{"dashboard-key", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/v1/agents/web-1/refresh", nil)
+		if tt.key != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+		}
+		rec := httptest.NewRecorder()
+		refresh.ServeHTTP(rec, req)
+		if rec.Code != tt.expected {
+			t.Errorf("Key %q: expected status %d, got %d", tt.key, tt.expected, rec.Code)
+		}
 	}
+}
 
-	body, _ := json.Marshal(payload)
-	req := httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader(body))
+func TestHandleAgentMaintenance(t *testing.T) {
+	state := server.NewStateStore()
+	state.UpdateHeartbeat("db-1")
+	handler := NewHandler(state)
+
+	body := strings.NewReader(`{"duration": "2h", "reason": "kernel patching"}`)
+	req := httptest.NewRequest("PUT", "/api/v1/agents/db-1/maintenance", body)
 	rec := httptest.NewRecorder()
 
-	handler.HandleHeartbeat(rec, req)
+	handler.HandleAgentMaintenance(rec, req)
 
-	if rec.Code != http.StatusBadRequest {
-		t.Errorf("Expected status 400, got %d", rec.Code)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	agent, _ := state.GetAgent("db-1")
+	if !agent.InMaintenance(time.Now().Add(119 * time.Minute)) {
+		t.Errorf("Expected maintenance for 2h, got until %v", agent.MaintenanceUntil)
+	}
+	if agent.MaintenanceReason != "kernel patching" {
+		t.Errorf("Expected reason to be stored, got %q", agent.MaintenanceReason)
+	}
+
+	// Survives metrics pushes
+	state.UpdateAgent(&server.ServerState{AgentName: "db-1"})
+	agent, _ = state.GetAgent("db-1")
+	if !agent.InMaintenance(time.Now()) {
+		t.Error("Expected maintenance to be kept across pushes")
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/v1/agents/db-1/maintenance", nil)
+	rec = httptest.NewRecorder()
+	handler.HandleAgentMaintenance(rec, req)
+
+	agent, _ = state.GetAgent("db-1")
+	if rec.Code != http.StatusOK || agent.MaintenanceUntil != nil {
+		t.Errorf("Expected maintenance to end, got status %d, until %v", rec.Code, agent.MaintenanceUntil)
 	}
 }
 
-func TestHandleHeartbeat_UpdatesExistingAgent(t *testing.T) {
+func TestHandleAgentMaintenance_Invalid(t *testing.T) {
 	state := server.NewStateStore()
+	state.UpdateHeartbeat("db-1")
 	handler := NewHandler(state)
 
-	// First, create an agent with metrics
-	metricsPayload := server.MetricsPushPayload{
-		AgentName: "test-agent",
-		Timestamp: time.Now(),
-		SystemMetrics: metrics.SystemMetrics{
-			Timestamp: time.Now(),
-			AgentName: "test-agent",
-		},
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{"bad duration", "PUT", "/api/v1/agents/db-1/maintenance", `{"duration": "soon"}`, http.StatusBadRequest},
+		{"negative duration", "PUT", "/api/v1/agents/db-1/maintenance", `{"duration": "-1h"}`, http.StatusBadRequest},
+		{"unknown agent", "PUT", "/api/v1/agents/db-2/maintenance", `{"duration": "1h"}`, http.StatusNotFound},
+		{"wrong method", "POST", "/api/v1/agents/db-1/maintenance", `{"duration": "1h"}`, http.StatusMethodNotAllowed},
 	}
 
-	body, _ := json.Marshal(metricsPayload)
-	req := httptest.NewRequest("POST", "/api/v1/metrics/push", bytes.NewReader(body))
-	rec := httptest.NewRecorder()
-	handler.HandleMetricsPush(rec, req)
-
-	// Get the original LastSeen
-	agent1, _ := state.GetAgent("test-agent")
-	originalLastSeen := agent1.LastSeen
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
 
-	// Wait a bit
-	time.Sleep(10 * time.Millisecond)
+			handler.HandleAgentMaintenance(rec, req)
 
-	// Send a heartbeat
-	heartbeatPayload := server.HeartbeatPayload{
-		AgentName: "test-agent",
-		Timestamp: time.Now(),
+			if rec.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+		})
 	}
+}
+
+func TestHandleHeartbeat_Interval(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	body, _ := json.Marshal(server.HeartbeatPayload{
+		AgentName:                "batch-host",
+		Timestamp:                time.Now(),
+		HeartbeatIntervalSeconds: 600,
+	})
+	req := httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
 
-	body, _ = json.Marshal(heartbeatPayload)
-	req = httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader(body))
-	rec = httptest.NewRecorder()
 	handler.HandleHeartbeat(rec, req)
 
-	if rec.Code != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", rec.Code)
+	agent, exists := state.GetAgent("batch-host")
+	if !exists {
+		t.Fatal("Agent not found in state")
+	}
+	if agent.HeartbeatInterval != 10*time.Minute {
+		t.Errorf("Expected heartbeat interval 10m, got %v", agent.HeartbeatInterval)
 	}
+}
 
-	// Verify LastSeen was updated
-	agent2, _ := state.GetAgent("test-agent")
-	if !agent2.LastSeen.After(originalLastSeen) {
-		t.Error("LastSeen was not updated by heartbeat")
+func TestHandleHeartbeat_OnHeartbeat(t *testing.T) {
+	handler := NewHandler(server.NewStateStore())
+	var received []server.HeartbeatPayload
+	handler.OnHeartbeat(func(heartbeat server.HeartbeatPayload) { received = append(received, heartbeat) })
+
+	body, _ := json.Marshal(server.HeartbeatPayload{AgentName: "web-1", Timestamp: time.Now(), AgentVersion: "v1.2.0"})
+	rec := httptest.NewRecorder()
+	handler.HandleHeartbeat(rec, httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader(body)))
+
+	if len(received) != 1 || received[0].AgentName != "web-1" || received[0].AgentVersion != "v1.2.0" {
+		t.Errorf("Expected the heartbeat passed on, got %+v", received)
 	}
 }
 
-func TestHandleHealth_ValidRequest(t *testing.T) {
+func TestHandleHeartbeat_ClockSkew(t *testing.T) {
 	state := server.NewStateStore()
 	handler := NewHandler(state)
 
-	// Add some agents (UpdateAgent automatically sets status to "online")
-	state.UpdateAgent(&server.ServerState{
-		AgentName: "agent1",
-	})
-	state.UpdateAgent(&server.ServerState{
-		AgentName: "agent2",
+	body, _ := json.Marshal(server.HeartbeatPayload{
+		AgentName: "test-agent",
+		Timestamp: time.Now().Add(-90 * time.Second), // Agent clock runs behind
+	})
+	req := httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.HandleHeartbeat(rec, req)
+
+	agent, _ := state.GetAgent("test-agent")
+	if agent.ClockSkewSeconds > -89 || agent.ClockSkewSeconds < -91 {
+		t.Errorf("Expected clock skew of about -90s, got %.3fs", agent.ClockSkewSeconds)
+	}
+}
+
+func TestHandleVersion(t *testing.T) {
+	handler := NewHandler(server.NewStateStore())
+
+	req := httptest.NewRequest("GET", "/api/v1/version", nil)
+	rec := httptest.NewRecorder()
+
+	handler.HandleVersion(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var info version.Info
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if info.Version != version.Version {
+		t.Errorf("Expected version %s, got %s", version.Version, info.Version)
+	}
+	if info.GoVersion == "" {
+		t.Error("Expected go_version to be set")
+	}
+}
+
+func TestHandleHeartbeat_Terminating(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	payload := server.HeartbeatPayload{
+		AgentName: "spot-agent",
+		Timestamp: time.Now(),
+		Status:    "terminating",
+		Reason:    "spot instance terminate at 2024-01-01T12:00:00Z",
+	}
+
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.HandleHeartbeat(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+
+	agent, exists := state.GetAgent("spot-agent")
+	if !exists {
+		t.Fatal("Agent not found in state")
+	}
+	if agent.Status != "terminating" {
+		t.Errorf("Expected status 'terminating', got '%s'", agent.Status)
+	}
+	if agent.StatusReason != payload.Reason {
+		t.Errorf("Expected reason '%s', got '%s'", payload.Reason, agent.StatusReason)
+	}
+}
+
+func TestHandleHeartbeat_Stopping(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   string
+	}{
+		{"shutdown", "stopped"},
+		{"decommission", "decommissioned"},
+	}
+
+	for _, tt := range tests {
+		state := server.NewStateStore()
+		handler := NewHandler(state)
+
+		payload := server.HeartbeatPayload{
+			AgentName: "web-agent",
+			Timestamp: time.Now(),
+			Status:    "stopping",
+			Reason:    tt.reason,
+		}
+
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+
+		handler.HandleHeartbeat(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", tt.reason, rec.Code)
+		}
+
+		agent, exists := state.GetAgent("web-agent")
+		if !exists {
+			t.Fatalf("%s: agent not found in state", tt.reason)
+		}
+		if agent.Status != tt.want {
+			t.Errorf("%s: expected status '%s', got '%s'", tt.reason, tt.want, agent.Status)
+		}
+	}
+}
+
+func TestHandleHeartbeat_InvalidMethod(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	req := httptest.NewRequest("GET", "/api/v1/heartbeat", nil)
+	rec := httptest.NewRecorder()
+
+	handler.HandleHeartbeat(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
+
+func TestHandleHeartbeat_Msgpack(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	data, _ := msgpack.Marshal(server.HeartbeatPayload{AgentName: "test-agent", Timestamp: time.Now(), AgentVersion: "1.2.3"})
+	req := httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader(data))
+	req.Header.Set("Content-Type", msgpack.ContentType)
+	rec := httptest.NewRecorder()
+	handler.HandleHeartbeat(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if agent, exists := state.GetAgent("test-agent"); !exists || agent.AgentVersion != "1.2.3" {
+		t.Errorf("Expected the heartbeat recorded, got %+v", agent)
+	}
+}
+
+func TestHandleHeartbeat_InvalidJSON(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	req := httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader([]byte("invalid json")))
+	rec := httptest.NewRecorder()
+
+	handler.HandleHeartbeat(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestHandleHeartbeat_MissingAgentName(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	payload := server.HeartbeatPayload{
+		Timestamp: time.Now(),
+	}
+
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.HandleHeartbeat(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestHandleHeartbeat_UpdatesExistingAgent(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	// First, create an agent with metrics
+	metricsPayload := server.MetricsPushPayload{
+		AgentName: "test-agent",
+		Timestamp: time.Now(),
+		SystemMetrics: metrics.SystemMetrics{
+			Timestamp: time.Now(),
+			AgentName: "test-agent",
+		},
+	}
+
+	body, _ := json.Marshal(metricsPayload)
+	req := httptest.NewRequest("POST", "/api/v1/metrics/push", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.HandleMetricsPush(rec, req)
+
+	// Get the original LastSeen
+	agent1, _ := state.GetAgent("test-agent")
+	originalLastSeen := agent1.LastSeen
+
+	// Wait a bit
+	time.Sleep(10 * time.Millisecond)
+
+	// Send a heartbeat
+	heartbeatPayload := server.HeartbeatPayload{
+		AgentName: "test-agent",
+		Timestamp: time.Now(),
+	}
+
+	body, _ = json.Marshal(heartbeatPayload)
+	req = httptest.NewRequest("POST", "/api/v1/heartbeat", bytes.NewReader(body))
+	rec = httptest.NewRecorder()
+	handler.HandleHeartbeat(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+
+	// Verify LastSeen was updated
+	agent2, _ := state.GetAgent("test-agent")
+	if !agent2.LastSeen.After(originalLastSeen) {
+		t.Error("LastSeen was not updated by heartbeat")
+	}
+}
+
+func TestHandleHealth_ValidRequest(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	// Add some agents (UpdateAgent automatically sets status to "online")
+	state.UpdateAgent(&server.ServerState{
+		AgentName: "agent1",
+	})
+	state.UpdateAgent(&server.ServerState{
+		AgentName: "agent2",
 	})
 	state.UpdateAgent(&server.ServerState{
 		AgentName: "agent3",
@@ -472,6 +1101,61 @@ func TestHandleHealth_ValidRequest(t *testing.T) {
 	}
 }
 
+func TestHandleHealth_NotificationChannels(t *testing.T) {
+	handler := NewHandler(server.NewStateStore())
+	failingSince := time.Now().Add(-20 * time.Minute)
+	handler.SetChannelStatus(func() []alerting.ChannelStatus {
+		return []alerting.ChannelStatus{{
+			Channel:             "google_chat",
+			LastCheck:           time.Now(),
+			LastError:           "Google Chat webhook returned status 404",
+			FailingSince:        failingSince,
+			ConsecutiveFailures: 4,
+		}}
+	})
+
+	rec := httptest.NewRecorder()
+	handler.HandleHealth(rec, httptest.NewRequest("GET", "/api/v1/health", nil))
+
+	var health struct {
+		Status   string                             `json:"status"`
+		Channels []server.NotificationChannelStatus `json:"notification_channels"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if len(health.Channels) != 1 {
+		t.Fatalf("Expected 1 channel, got %d", len(health.Channels))
+	}
+	channel := health.Channels[0]
+	if channel.Channel != "google_chat" || channel.Healthy || channel.ConsecutiveFailures != 4 {
+		t.Errorf("Unexpected channel status: %+v", channel)
+	}
+	if channel.FailingSince == nil || !channel.FailingSince.Equal(failingSince) || channel.LastSuccess != nil {
+		t.Errorf("Expected failing since %v and no success, got %v and %v", failingSince, channel.FailingSince, channel.LastSuccess)
+	}
+}
+
+func TestHandleHealth_HA(t *testing.T) {
+	handler := NewHandler(server.NewStateStore())
+	handler.SetHAStatus(func() ha.Status {
+		return ha.Status{NodeID: "server-b", LeaderID: "server-a", Peers: 1}
+	})
+
+	rec := httptest.NewRecorder()
+	handler.HandleHealth(rec, httptest.NewRequest("GET", "/api/v1/health", nil))
+
+	var health struct {
+		HA ha.Status `json:"ha"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if health.HA.NodeID != "server-b" || health.HA.Leader || health.HA.LeaderID != "server-a" {
+		t.Errorf("Unexpected HA status: %+v", health.HA)
+	}
+}
+
 func TestHandleHealth_InvalidMethod(t *testing.T) {
 	state := server.NewStateStore()
 	handler := NewHandler(state)
@@ -511,6 +1195,520 @@ func TestHandleHealth_EmptyState(t *testing.T) {
 	if health["active_alerts"] != float64(0) {
 		t.Errorf("Expected 0 active alerts, got %v", health["active_alerts"])
 	}
+
+	if _, exists := health["health_score"]; exists {
+		t.Errorf("Expected no fleet health score without agents, got %v", health["health_score"])
+	}
+}
+
+func TestHandleHealth_FleetHealthScore(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	state.UpdateAgent(&server.ServerState{
+		AgentName:     "web-1",
+		SystemMetrics: metrics.SystemMetrics{Memory: metrics.MemoryMetrics{UsedPercent: 90}},
+	})
+	state.UpdateAgent(&server.ServerState{AgentName: "web-2"})
+
+	rec := httptest.NewRecorder()
+	handler.HandleHealth(rec, httptest.NewRequest("GET", "/api/v1/health", nil))
+
+	var health map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	// 70 at 90% memory and 100 for the idle agent
+	if health["health_score"] != float64(85) {
+		t.Errorf("Expected fleet health score 85, got %v", health["health_score"])
+	}
+
+	rec = httptest.NewRecorder()
+	handler.HandleGetAgents(rec, httptest.NewRequest("GET", "/api/v1/agents", nil))
+	var agents []server.ServerState
+	if err := json.NewDecoder(rec.Body).Decode(&agents); err != nil {
+		t.Fatalf("Failed to decode agents response: %v", err)
+	}
+	for _, agent := range agents {
+		if agent.AgentName == "web-1" && agent.HealthScore != 70 {
+			t.Errorf("Expected health score 70 for web-1, got %d", agent.HealthScore)
+		}
+	}
+}
+
+func TestHandleGetAgents_TagFilter(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	state.UpdateAgent(&server.ServerState{
+		AgentName: "web-1",
+		Cloud:     &server.CloudMetadata{Provider: "aws", Tags: map[string]string{"env": "production", "team": "web"}},
+	})
+	state.UpdateAgent(&server.ServerState{
+		AgentName: "web-2",
+		Cloud:     &server.CloudMetadata{Provider: "aws", Tags: map[string]string{"env": "staging", "team": "web"}},
+	})
+	state.UpdateAgent(&server.ServerState{AgentName: "on-prem"})
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{"", 3},
+		{"?tag=team", 2},
+		{"?tag=env=production", 1},
+		{"?tag=env=production&tag=team=web", 1},
+		{"?tag=env=production&tag=team=data", 0},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/v1/agents"+tt.query, nil)
+		rec := httptest.NewRecorder()
+
+		handler.HandleGetAgents(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", tt.query, rec.Code)
+		}
+
+		var agents []server.ServerState
+		if err := json.NewDecoder(rec.Body).Decode(&agents); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tt.query, err)
+		}
+		if len(agents) != tt.expected {
+			t.Errorf("%s: expected %d agents, got %d", tt.query, tt.expected, len(agents))
+		}
+	}
+}
+
+func TestHandleGetAgents_Empty(t *testing.T) {
+	handler := NewHandler(server.NewStateStore())
+
+	req := httptest.NewRequest("GET", "/api/v1/agents", nil)
+	rec := httptest.NewRecorder()
+	handler.HandleGetAgents(rec, req)
+
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Errorf("Expected an empty JSON array, got %q", body)
+	}
+}
+
+func TestHandleDeleteAgent(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	state.UpdateAgent(&server.ServerState{AgentName: "test-agent"})
+
+	req := httptest.NewRequest("DELETE", "/api/v1/agents/test-agent", nil)
+	rec := httptest.NewRecorder()
+
+	handler.HandleDeleteAgent(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if _, exists := state.GetAgent("test-agent"); exists {
+		t.Error("Expected agent to be removed")
+	}
+
+	// Deleting again is a 404
+	rec = httptest.NewRecorder()
+	handler.HandleDeleteAgent(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestHandleDeleteAgent_RequiresAdminScope(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+	auth := NewAuthConfig([]APIKey{
+		{Key: "agent-key", Name: "agents", Scopes: []string{"metrics:write", "heartbeat:write"}},
+		{Key: "admin-key", Name: "admin", Scopes: []string{"admin:write"}},
+	})
+	deleteAgent := auth.AuthMiddleware(DeleteAgentScopes)(http.HandlerFunc(handler.HandleDeleteAgent))
+
+	state.UpdateAgent(&server.ServerState{AgentName: "other-agent"})
+
+	// A plain agent key can't remove hosts and silence their offline alerts
+	req := httptest.NewRequest("DELETE", "/api/v1/agents/other-agent", nil)
+	req.Header.Set("Authorization", "Bearer agent-key")
+	rec := httptest.NewRecorder()
+	deleteAgent.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for an agent key, got %d", rec.Code)
+	}
+	if _, exists := state.GetAgent("other-agent"); !exists {
+		t.Error("Expected agent to be kept")
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/v1/agents/other-agent", nil)
+	req.Header.Set("Authorization", "Bearer admin-key")
+	rec = httptest.NewRecorder()
+	deleteAgent.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for an admin key, got %d", rec.Code)
+	}
+	if _, exists := state.GetAgent("other-agent"); exists {
+		t.Error("Expected agent to be removed")
+	}
+}
+
+func TestHandleAlertAction(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	state.UpdateAgent(&server.ServerState{AgentName: "test-agent"})
+	state.AddAlert(&server.Alert{ID: "alert1", AgentName: "test-agent", Status: "active"})
+
+	req := httptest.NewRequest("POST", "/api/v1/alerts/alert1/ack", nil)
+	rec := httptest.NewRecorder()
+	handler.HandleAlertAction(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var alert server.Alert
+	if err := json.NewDecoder(rec.Body).Decode(&alert); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if alert.Status != "acknowledged" {
+		t.Errorf("Expected status 'acknowledged', got '%s'", alert.Status)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/alerts/alert1/resolve", nil)
+	rec = httptest.NewRecorder()
+	handler.HandleAlertAction(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if got, _ := state.GetAlert("alert1"); got.Status != "resolved" {
+		t.Errorf("Expected status 'resolved', got '%s'", got.Status)
+	}
+
+	// Resolved alerts show up with ?status=resolved only
+	req = httptest.NewRequest("GET", "/api/v1/alerts?status=resolved", nil)
+	rec = httptest.NewRecorder()
+	handler.HandleGetAlerts(rec, req)
+
+	var alerts []server.Alert
+	if err := json.NewDecoder(rec.Body).Decode(&alerts); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Errorf("Expected 1 resolved alert, got %d", len(alerts))
+	}
+
+	for _, path := range []string{"/api/v1/alerts/missing/ack", "/api/v1/alerts/alert1/snooze"} {
+		req = httptest.NewRequest("POST", path, nil)
+		rec = httptest.NewRecorder()
+		handler.HandleAlertAction(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestHandleAlertAction_Assign(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	state.AddAlert(&server.Alert{ID: "alert1", AgentName: "test-agent", AlertType: "agent_offline", Status: "active"})
+
+	body, _ := json.Marshal(server.AlertAssignment{Assignee: "alice"})
+	req := httptest.NewRequest("PUT", "/api/v1/alerts/alert1/assign", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.HandleAlertAction(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var alert server.Alert
+	if err := json.NewDecoder(rec.Body).Decode(&alert); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if alert.Assignee != "alice" {
+		t.Errorf("Expected assignee 'alice', got '%s'", alert.Assignee)
+	}
+
+	// Assigning takes PUT, the other actions POST
+	req = httptest.NewRequest("POST", "/api/v1/alerts/alert1/assign", bytes.NewReader(body))
+	rec = httptest.NewRecorder()
+	handler.HandleAlertAction(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for POST, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/v1/alerts/alert1/assign", bytes.NewReader([]byte("{")))
+	rec = httptest.NewRecorder()
+	handler.HandleAlertAction(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid JSON, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/v1/alerts/missing/assign", bytes.NewReader(body))
+	rec = httptest.NewRecorder()
+	handler.HandleAlertAction(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown alert, got %d", rec.Code)
+	}
+}
+
+func TestHandleExternalAlert(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	var raised []alerting.ExternalAlert
+	handler.SetExternalAlerts(func(ext alerting.ExternalAlert) *alerting.Alert {
+		raised = append(raised, ext)
+		if len(raised) > 1 {
+			return nil // Deduplicated
+		}
+		state.AddAlert(&server.Alert{ID: "ext1", AgentName: ext.AgentName, AlertType: ext.AlertType, Severity: ext.Severity, Status: "active"})
+		return &alerting.Alert{ID: "ext1"}
+	})
+
+	body := `{"agent_name": " db-1 ", "alert_type": "backup_failed", "source": "nightly-backup", "message": "pg_dump exited 1"}`
+	req := httptest.NewRequest("POST", "/api/v1/alerts/external", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.HandleExternalAlert(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var alert server.Alert
+	if err := json.NewDecoder(rec.Body).Decode(&alert); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if alert.ID != "ext1" {
+		t.Errorf("Expected alert 'ext1', got '%s'", alert.ID)
+	}
+	if len(raised) != 1 || raised[0].AgentName != "db-1" || raised[0].Severity != "warning" || raised[0].Source != "nightly-backup" {
+		t.Errorf("Unexpected external alert: %+v", raised)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/alerts/external", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	handler.HandleExternalAlert(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "duplicate") {
+		t.Errorf("Expected a duplicate with status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleExternalAlert_Invalid(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	// Without alerting there is nothing to raise alerts with
+	req := httptest.NewRequest("POST", "/api/v1/alerts/external", strings.NewReader(`{"agent_name": "db-1", "message": "x"}`))
+	rec := httptest.NewRecorder()
+	handler.HandleExternalAlert(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 with alerting disabled, got %d", rec.Code)
+	}
+
+	handler.SetExternalAlerts(func(alerting.ExternalAlert) *alerting.Alert {
+		t.Error("Expected invalid requests not to raise alerts")
+		return nil
+	})
+
+	tests := map[string]string{
+		"invalid JSON":     `{`,
+		"missing agent":    `{"message": "x"}`,
+		"missing message":  `{"agent_name": "db-1", "message": "  "}`,
+		"unknown severity": `{"agent_name": "db-1", "message": "x", "severity": "fatal"}`,
+	}
+	for name, body := range tests {
+		req := httptest.NewRequest("POST", "/api/v1/alerts/external", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.HandleExternalAlert(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", name, rec.Code)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/alerts/external", nil)
+	rec = httptest.NewRecorder()
+	handler.HandleExternalAlert(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
+
+func TestHandleAgentDiff(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+	state.UpdateAgent(&server.ServerState{AgentName: "web-1", AgentVersion: "1.0.0"})
+	state.UpdateAgent(&server.ServerState{AgentName: "web-2", AgentVersion: "1.1.0"})
+
+	req := httptest.NewRequest("GET", "/api/v1/agents/diff?a=web-1&b=web-2", nil)
+	rec := httptest.NewRecorder()
+	handler.HandleAgentDiff(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var diff server.AgentDiff
+	if err := json.NewDecoder(rec.Body).Decode(&diff); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if diff.A != "web-1" || len(diff.System) != 1 || diff.System[0].Field != "agent_version" {
+		t.Errorf("Expected only the agent version to differ, got %+v", diff)
+	}
+
+	tests := map[string]int{
+		"/api/v1/agents/diff?a=web-1":           http.StatusBadRequest,
+		"/api/v1/agents/diff?a=web-1&b=missing": http.StatusNotFound,
+	}
+	for path, want := range tests {
+		rec := httptest.NewRecorder()
+		handler.HandleAgentDiff(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestHandleNoisyAlerts(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	now := time.Now()
+	state.AddAlert(&server.Alert{ID: "1", AgentName: "web-1", AlertType: "system_cpu", TriggeredAt: now.Add(-time.Hour)})
+	state.AddAlert(&server.Alert{ID: "2", AgentName: "web-1", AlertType: "system_cpu", TriggeredAt: now.Add(-2 * time.Hour)})
+	state.AddAlert(&server.Alert{ID: "3", AgentName: "db-1", AlertType: "system_disk", TriggeredAt: now.Add(-time.Hour)})
+	// Outside the default week
+	state.AddAlert(&server.Alert{ID: "4", AgentName: "db-1", AlertType: "system_disk", TriggeredAt: now.Add(-8 * 24 * time.Hour)})
+
+	req := httptest.NewRequest("GET", "/api/v1/alerts/noisy?top=1", nil)
+	rec := httptest.NewRecorder()
+	handler.HandleNoisyAlerts(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var noisy server.NoisyAlerts
+	if err := json.NewDecoder(rec.Body).Decode(&noisy); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if noisy.Firings != 3 {
+		t.Errorf("Expected 3 firings in the last week, got %d", noisy.Firings)
+	}
+	if len(noisy.Fingerprints) != 1 || noisy.Fingerprints[0].Fingerprint != "system_cpu:web-1" || noisy.Fingerprints[0].Firings != 2 {
+		t.Errorf("Expected system_cpu:web-1 with 2 firings, got %+v", noisy.Fingerprints)
+	}
+	if len(noisy.Agents) != 1 || noisy.Agents[0].AgentName != "web-1" {
+		t.Errorf("Expected web-1 as the noisiest agent, got %+v", noisy.Agents)
+	}
+
+	tests := map[string]int{
+		"/api/v1/alerts/noisy?window=30d": http.StatusOK,
+		"/api/v1/alerts/noisy?window=0s":  http.StatusBadRequest,
+		"/api/v1/alerts/noisy?top=0":      http.StatusBadRequest,
+		"/api/v1/alerts/noisy?top=many":   http.StatusBadRequest,
+	}
+	for path, want := range tests {
+		rec := httptest.NewRecorder()
+		handler.HandleNoisyAlerts(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestHandleDryRunAlerts(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	rec := httptest.NewRecorder()
+	handler.HandleDryRunAlerts(rec, httptest.NewRequest("GET", "/api/v1/alerts/dry-run", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without alerting, got %d", rec.Code)
+	}
+
+	engine := alerting.NewEngine(server.NewAlertingAdapter(state), &alerting.Config{Enabled: true}, alerting.NewConsoleNotifier())
+	handler.SetAlertingSettings(engine, "")
+
+	rec = httptest.NewRecorder()
+	handler.HandleDryRunAlerts(rec, httptest.NewRequest("GET", "/api/v1/alerts/dry-run?rule=queue_backlog", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Errorf("Expected an empty list, got %s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.HandleDryRunAlerts(rec, httptest.NewRequest("POST", "/api/v1/alerts/dry-run", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
+
+func TestHandleSilences(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	body, _ := json.Marshal(server.Silence{
+		AgentName: "web-*",
+		Comment:   "deploy",
+		EndsAt:    time.Now().Add(time.Hour),
+	})
+	req := httptest.NewRequest("POST", "/api/v1/silences", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.HandleSilences(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", rec.Code)
+	}
+	var created server.Silence
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !state.IsSilenced("web-1", "agent_offline") {
+		t.Error("Expected web-1 to be silenced")
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/silences", nil)
+	rec = httptest.NewRecorder()
+	handler.HandleSilences(rec, req)
+
+	var silences []server.Silence
+	if err := json.NewDecoder(rec.Body).Decode(&silences); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(silences) != 1 || silences[0].ID != created.ID {
+		t.Errorf("Expected the created silence to be listed, got %+v", silences)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/v1/silences/"+created.ID, nil)
+	rec = httptest.NewRecorder()
+	handler.HandleDeleteSilence(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if state.IsSilenced("web-1", "agent_offline") {
+		t.Error("Expected silence to be removed")
+	}
+}
+
+func TestHandleSilences_InvalidWindow(t *testing.T) {
+	handler := NewHandler(server.NewStateStore())
+
+	body, _ := json.Marshal(server.Silence{EndsAt: time.Now().Add(-time.Minute)})
+	req := httptest.NewRequest("POST", "/api/v1/silences", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.HandleSilences(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
 }
 
 func TestGetEC2InstanceID(t *testing.T) {
@@ -545,6 +1743,26 @@ func TestGetEC2InstanceID(t *testing.T) {
 	}
 }
 
+func TestConvertContainers_ComposeService(t *testing.T) {
+	handler := NewHandler(nil)
+
+	result := handler.convertContainers([]metrics.ContainerMetrics{
+		{ID: "c1", Name: "shop-web-1", Labels: map[string]string{
+			"com.docker.compose.project":          "shop",
+			"com.docker.compose.service":          "web",
+			"com.docker.compose.container-number": "1",
+		}},
+		{ID: "c2", Name: "standalone"},
+	})
+
+	if result[0].ComposeService != "shop/web" || result[0].ComposeNumber != "1" {
+		t.Errorf("Expected compose service shop/web #1, got %q #%q", result[0].ComposeService, result[0].ComposeNumber)
+	}
+	if result[1].ComposeService != "" {
+		t.Errorf("Expected no compose service, got %q", result[1].ComposeService)
+	}
+}
+
 func TestConvertContainers(t *testing.T) {
 	handler := NewHandler(nil)
 
@@ -599,6 +1817,40 @@ func TestConvertContainers(t *testing.T) {
 	}
 }
 
+func TestConvertContainers_UnhealthyWithHealthCheckOutput(t *testing.T) {
+	handler := NewHandler(nil)
+
+	containers := []metrics.ContainerMetrics{
+		{
+			ID:                  "container3",
+			Name:                "redis",
+			Image:               "redis:alpine",
+			State:               "running",
+			Health:              "unhealthy",
+			HealthCheckExitCode: 1,
+			HealthCheckOutput:   "PONG expected",
+		},
+	}
+
+	result := handler.convertContainers(containers)
+
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 container, got %d", len(result))
+	}
+
+	if result[0].Health != "unhealthy" {
+		t.Errorf("Expected health 'unhealthy', got '%s'", result[0].Health)
+	}
+
+	if result[0].HealthCheckExitCode != 1 {
+		t.Errorf("Expected health check exit code 1, got %d", result[0].HealthCheckExitCode)
+	}
+
+	if result[0].HealthCheckOutput != "PONG expected" {
+		t.Errorf("Expected health check output 'PONG expected', got '%s'", result[0].HealthCheckOutput)
+	}
+}
+
 func TestCalculateMemoryPercent(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -678,6 +1930,20 @@ func TestCountOfflineAgents(t *testing.T) {
 	}
 }
 
+func TestCountDegradedAgents(t *testing.T) {
+	agents := []*server.ServerState{
+		{AgentName: "agent1", Status: "online"},
+		{AgentName: "agent2", Status: "degraded"},
+		{AgentName: "agent3", Status: "offline"},
+		{AgentName: "agent4", Status: "degraded"},
+	}
+
+	count := countDegradedAgents(agents)
+	if count != 2 {
+		t.Errorf("Expected 2 degraded agents, got %d", count)
+	}
+}
+
 func TestCountAgents_EmptyList(t *testing.T) {
 	var agents []*server.ServerState
 
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// HandleGetImages handles GET /api/v1/images: the images containers run
+// across the fleet, by repository and then tag or digest, with the agents
+// running each. Optional are ?image=nginx:1.19* (see imageMatches) and
+// ?agent=web-* filters.
+func (h *Handler) HandleGetImages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	image, agentPattern := r.URL.Query().Get("image"), r.URL.Query().Get("agent")
+	for _, pattern := range []string{image, agentPattern} {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid pattern %q", pattern), http.StatusBadRequest)
+			return
+		}
+	}
+
+	versions := make(map[string]*server.ImageVersion)
+	err := h.state.ForEachAgent(func(agent *server.ServerState) error {
+		if matched, _ := filepath.Match(agentPattern, agent.AgentName); agentPattern != "" && !matched {
+			return nil
+		}
+		for _, c := range agent.Containers {
+			if image != "" && !imageMatches(image, c.Image) {
+				continue
+			}
+			version, exists := versions[c.Image]
+			if !exists {
+				version = &server.ImageVersion{Image: c.Image, ImageIDs: []string{}, Agents: []string{}}
+				version.Tag, version.Digest = imageTag(c.Image)
+				versions[c.Image] = version
+			}
+			version.Containers++
+			if c.State == "running" {
+				version.Running++
+			}
+			if c.ImageID != "" && !slices.Contains(version.ImageIDs, c.ImageID) {
+				version.ImageIDs = append(version.ImageIDs, c.ImageID)
+			}
+			if !slices.Contains(version.Agents, agent.AgentName) {
+				version.Agents = append(version.Agents, agent.AgentName)
+			}
+			version.UpdateAvailable = version.UpdateAvailable || c.UpdateAvailable
+		}
+		return nil
+	})
+	if err != nil {
+		h.logger.Error("Error listing images", logging.Err(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(imageInventory(versions)); err != nil {
+		h.logger.Error("Error encoding images response", logging.Err(err))
+	}
+}
+
+// imageInventory groups image versions by repository, sorted by name
+func imageInventory(versions map[string]*server.ImageVersion) []server.ImageInventory {
+	byRepository := make(map[string]*server.ImageInventory)
+	agents := make(map[string]map[string]bool)
+	for _, version := range versions {
+		repository := imageRepository(version.Image)
+		inventory, exists := byRepository[repository]
+		if !exists {
+			inventory = &server.ImageInventory{Repository: repository}
+			byRepository[repository] = inventory
+			agents[repository] = make(map[string]bool)
+		}
+		sort.Strings(version.ImageIDs)
+		sort.Strings(version.Agents)
+		inventory.Versions = append(inventory.Versions, *version)
+		inventory.Containers += version.Containers
+		for _, agent := range version.Agents {
+			agents[repository][agent] = true
+		}
+	}
+
+	inventories := make([]server.ImageInventory, 0, len(byRepository))
+	for repository, inventory := range byRepository {
+		inventory.Agents = len(agents[repository])
+		sort.Slice(inventory.Versions, func(i, j int) bool {
+			return inventory.Versions[i].Image < inventory.Versions[j].Image
+		})
+		inventories = append(inventories, *inventory)
+	}
+	sort.Slice(inventories, func(i, j int) bool {
+		return inventories[i].Repository < inventories[j].Repository
+	})
+	return inventories
+}
+
+// imageTag returns the tag and digest of an image reference, if it has them
+func imageTag(image string) (tag, digest string) {
+	image, digest, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		tag = image[i+1:]
+	}
+	return tag, digest
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func TestHandleGetImages(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	state.UpdateAgent(&server.ServerState{
+		AgentName: "web-1",
+		Containers: handler.convertContainers([]metrics.ContainerMetrics{
+			{ID: "a1", Name: "proxy", Image: "nginx:1.19", ImageID: "sha256:old", State: "running"},
+			{ID: "a2", Name: "cache", Image: "redis:7", ImageID: "sha256:r7", State: "exited"},
+		}),
+	})
+	state.UpdateAgent(&server.ServerState{
+		AgentName: "web-2",
+		Containers: handler.convertContainers([]metrics.ContainerMetrics{
+			{ID: "b1", Name: "proxy", Image: "nginx:1.19", ImageID: "sha256:new", State: "running", UpdateAvailable: true},
+			{ID: "b2", Name: "proxy-next", Image: "nginx:1.25", ImageID: "sha256:n25", State: "running"},
+		}),
+	})
+
+	rec := httptest.NewRecorder()
+	handler.HandleGetImages(rec, httptest.NewRequest("GET", "/api/v1/images", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var images []server.ImageInventory
+	if err := json.NewDecoder(rec.Body).Decode(&images); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(images) != 2 || images[0].Repository != "nginx" || images[1].Repository != "redis" {
+		t.Fatalf("Expected nginx and redis, got %+v", images)
+	}
+
+	nginx := images[0]
+	if nginx.Containers != 3 || nginx.Agents != 2 || len(nginx.Versions) != 2 {
+		t.Errorf("Expected 3 nginx containers on 2 agents in 2 versions, got %+v", nginx)
+	}
+	old := nginx.Versions[0]
+	if old.Tag != "1.19" || len(old.ImageIDs) != 2 || len(old.Agents) != 2 || old.Running != 2 || !old.UpdateAvailable {
+		t.Errorf("Expected nginx:1.19 on both agents with 2 image IDs and an update, got %+v", old)
+	}
+
+	// Who still runs nginx:1.19?
+	rec = httptest.NewRecorder()
+	handler.HandleGetImages(rec, httptest.NewRequest("GET", "/api/v1/images?image=nginx:1.19*", nil))
+	images = nil
+	if err := json.NewDecoder(rec.Body).Decode(&images); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(images) != 1 || len(images[0].Versions) != 1 || images[0].Versions[0].Image != "nginx:1.19" {
+		t.Errorf("Expected only nginx:1.19, got %+v", images)
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	tests := []struct {
+		image, tag, digest string
+	}{
+		{"nginx", "", ""},
+		{"nginx:1.19", "1.19", ""},
+		{"registry.local:5000/shop/api", "", ""},
+		{"registry.local:5000/shop/api:2", "2", ""},
+		{"nginx@sha256:abc", "", "sha256:abc"},
+		{"nginx:1.19@sha256:abc", "1.19", "sha256:abc"},
+	}
+
+	for _, tt := range tests {
+		tag, digest := imageTag(tt.image)
+		if tag != tt.tag || digest != tt.digest {
+			t.Errorf("imageTag(%q) = %q, %q, want %q, %q", tt.image, tag, digest, tt.tag, tt.digest)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/anurag/saviour/internal/deadman"
+	"github.com/anurag/saviour/internal/logging"
+)
+
+// pingPath is where ping URLs live, followed by the check's token
+const pingPath = "/api/v1/ping/"
+
+// pingCheckResponse is a ping check as GET /api/v1/pings lists it
+type pingCheckResponse struct {
+	deadman.Status
+	PingURL string `json:"ping_url"`
+}
+
+// SetPings sets the dead man's switch checks /api/v1/ping/<token> records
+// pings for. Call it before serving requests.
+func (h *Handler) SetPings(monitor *deadman.Monitor) {
+	h.pings = monitor
+}
+
+// HandlePing handles GET, POST and HEAD /api/v1/ping/<token>, which a cron
+// job or script requests when it succeeds
+func (h *Handler) HandlePing(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodPost, http.MethodHead:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, pingPath), "/")
+	if h.pings == nil || token == "" {
+		http.Error(w, "Ping check not found", http.StatusNotFound)
+		return
+	}
+	name, ok := h.pings.Ping(token)
+	if !ok {
+		h.logger.Warn("Ping with unknown token", "remote_addr", r.RemoteAddr)
+		http.Error(w, "Ping check not found", http.StatusNotFound)
+		return
+	}
+
+	h.logger.Debug("Ping received", "check", name, "remote_addr", r.RemoteAddr)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("OK\n"))
+}
+
+// HandleGetPings handles GET /api/v1/pings, the ping checks with their ping
+// URLs and whether each pinged in time
+func (h *Handler) HandleGetPings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	checks := []pingCheckResponse{}
+	if h.pings != nil {
+		for _, status := range h.pings.Status() {
+			checks = append(checks, pingCheckResponse{
+				Status:  status,
+				PingURL: scheme + "://" + r.Host + pingPath + status.Token,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"checks": checks}); err != nil {
+		h.logger.Error("Error encoding pings response", logging.Err(err))
+	}
+}
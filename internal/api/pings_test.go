@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/deadman"
+	"github.com/anurag/saviour/internal/server"
+)
+
+func TestHandlePing(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	// Without ping checks every token is unknown
+	req := httptest.NewRequest("GET", "/api/v1/ping/backup-token", nil)
+	rec := httptest.NewRecorder()
+	handler.HandlePing(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 without ping checks, got %d", rec.Code)
+	}
+
+	handler.SetPings(deadman.NewMonitor([]deadman.Check{{Name: "backup", Token: "backup-token", Period: time.Hour}}, state))
+	for _, method := range []string{"GET", "POST", "HEAD"} {
+		req := httptest.NewRequest(method, "/api/v1/ping/backup-token", nil)
+		rec := httptest.NewRecorder()
+		handler.HandlePing(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", method, rec.Code)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/ping/wrong-token", nil)
+	rec = httptest.NewRecorder()
+	handler.HandlePing(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown token, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/pings", nil)
+	req.Host = "saviour.example.com"
+	rec = httptest.NewRecorder()
+	handler.HandleGetPings(rec, req)
+	var response struct {
+		Checks []pingCheckResponse `json:"checks"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Checks) != 1 {
+		t.Fatalf("Expected 1 check, got %d", len(response.Checks))
+	}
+	check := response.Checks[0]
+	if check.PingURL != "http://saviour.example.com/api/v1/ping/backup-token" || check.Pings != 3 || check.Status.Status != deadman.StatusUp {
+		t.Errorf("Unexpected check: %+v", check)
+	}
+	if !strings.Contains(rec.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("Expected JSON, got %s", rec.Header().Get("Content-Type"))
+	}
+}
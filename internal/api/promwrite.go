@@ -0,0 +1,54 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/promwrite"
+)
+
+// HandlePromWrite handles POST /api/v1/prom/write, a Prometheus
+// remote_write receiver. node_exporter and cAdvisor series are mapped into
+// agent state, one agent per saviour_agent label or instance host.
+func (h *Handler) HandlePromWrite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	receivedAt := time.Now()
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxRequestSize)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Request entity too large, the limit is %d bytes (server.max_request_size)", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	series, err := promwrite.Decode(body)
+	if err != nil {
+		h.logger.Error("Error decoding remote_write request", logging.Err(err))
+		http.Error(w, "Invalid remote_write request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, state := range h.prom.Apply(series, receivedAt) {
+		if !h.state.UpdateAgent(state) {
+			continue
+		}
+		for _, fn := range h.onPush {
+			fn(state.AgentName)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+)
+
+// remoteWriteBody encodes a WriteRequest with one node_load1 sample of db-1
+// as a snappy block of a single literal
+func remoteWriteBody(value float64, at time.Time) []byte {
+	field := func(b []byte, number int, value []byte) []byte {
+		b = binary.AppendUvarint(b, uint64(number)<<3|2)
+		b = binary.AppendUvarint(b, uint64(len(value)))
+		return append(b, value...)
+	}
+	label := func(name, value string) []byte {
+		return field(field(nil, 1, []byte(name)), 2, []byte(value))
+	}
+
+	sample := binary.AppendUvarint(nil, 1<<3|1)
+	sample = binary.LittleEndian.AppendUint64(sample, math.Float64bits(value))
+	sample = binary.AppendUvarint(sample, 2<<3)
+	sample = binary.AppendUvarint(sample, uint64(at.UnixMilli()))
+
+	series := field(nil, 1, label("__name__", "node_load1"))
+	series = field(series, 1, label("instance", "db-1:9100"))
+	series = field(series, 2, sample)
+	req := field(nil, 1, series)
+
+	block := binary.AppendUvarint(nil, uint64(len(req)))
+	block = append(block, 60<<2, byte(len(req)-1))
+	return append(block, req...)
+}
+
+func TestHandlePromWrite(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+	var pushed []string
+	handler.OnMetricsPush(func(agentName string) { pushed = append(pushed, agentName) })
+
+	req := httptest.NewRequest("POST", "/api/v1/prom/write", bytes.NewReader(remoteWriteBody(2.5, time.Now())))
+	rec := httptest.NewRecorder()
+	handler.HandlePromWrite(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	agent, exists := state.GetAgent("db-1")
+	if !exists {
+		t.Fatal("Expected agent db-1 to be created")
+	}
+	if agent.SystemMetrics.CPU.LoadAvg1 != 2.5 {
+		t.Errorf("Expected load 2.5, got %f", agent.SystemMetrics.CPU.LoadAvg1)
+	}
+	if agent.Status != "online" {
+		t.Errorf("Expected status online, got %s", agent.Status)
+	}
+	if len(pushed) != 1 || pushed[0] != "db-1" {
+		t.Errorf("Expected the push hook to be called for db-1, got %v", pushed)
+	}
+}
+
+func TestHandlePromWrite_Invalid(t *testing.T) {
+	handler := NewHandler(server.NewStateStore())
+
+	req := httptest.NewRequest("GET", "/api/v1/prom/write", nil)
+	rec := httptest.NewRecorder()
+	handler.HandlePromWrite(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/prom/write", bytes.NewReader([]byte("not snappy")))
+	rec = httptest.NewRecorder()
+	handler.HandlePromWrite(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+
+	handler.SetLimits(PayloadLimits{MaxRequestSize: 10})
+	req = httptest.NewRequest("POST", "/api/v1/prom/write", bytes.NewReader(remoteWriteBody(1, time.Now())))
+	rec = httptest.NewRecorder()
+	handler.HandlePromWrite(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/anurag/saviour/internal/alerting"
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// HandleAlertingSettings handles GET and PUT /api/v1/admin/alerting, the
+// thresholds, deduplication and heartbeat timeout of the running alert
+// engine. PUT takes any subset of the settings; the rest keep their value.
+// Changes apply from the next check and are saved so they survive restarts.
+// Changes are made one at a time, so concurrent PUTs of different settings
+// don't undo each other.
+func (h *Handler) HandleAlertingSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.alerting == nil {
+		http.Error(w, "Alerting is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		h.settingsMu.Lock()
+		defer h.settingsMu.Unlock()
+	}
+
+	settings := server.AlertingSettingsOf(h.alerting.Config())
+	if r.Method == http.MethodGet {
+		saved, err := server.LoadAlertingSettings(h.settingsFile)
+		if err != nil {
+			h.logger.Error("Error reading alerting settings", logging.Err(err))
+		} else if saved != nil {
+			settings.UpdatedAt = saved.UpdatedAt
+		}
+		h.writeAlertingSettings(w, settings)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxRequestSize)
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	config := h.alerting.Config()
+	if err := settings.Apply(&config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	settings.UpdatedAt = &now
+	if err := server.SaveAlertingSettings(h.settingsFile, settings); err != nil {
+		h.logger.Error("Error saving alerting settings", logging.Err(err))
+		http.Error(w, "Failed to save alerting settings", http.StatusInternalServerError)
+		return
+	}
+	h.alerting.Reconfigure(func(c *alerting.Config) {
+		// Validated above
+		settings.Apply(c)
+	})
+	h.logger.Info("Alerting settings changed at runtime", "path", h.settingsFile)
+	h.writeAlertingSettings(w, settings)
+}
+
+// writeAlertingSettings writes settings as the JSON response
+func (h *Handler) writeAlertingSettings(w http.ResponseWriter, settings server.AlertingSettings) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(settings); err != nil {
+		h.logger.Error("Error encoding alerting settings response", logging.Err(err))
+	}
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/alerting"
+	"github.com/anurag/saviour/internal/server"
+)
+
+func TestHandleAlertingSettings(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	// Disabled alerting has nothing to tune
+	rec := httptest.NewRecorder()
+	handler.HandleAlertingSettings(rec, httptest.NewRequest("GET", "/api/v1/admin/alerting", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without alerting, got %d", rec.Code)
+	}
+
+	engine := alerting.NewEngine(server.NewAlertingAdapter(state), &alerting.Config{
+		Enabled:              true,
+		HeartbeatTimeout:     2 * time.Minute,
+		DeduplicationEnabled: true,
+		DeduplicationWindow:  5 * time.Minute,
+		SystemCPUThreshold:   80,
+	}, alerting.NewConsoleNotifier())
+	settingsFile := filepath.Join(t.TempDir(), "alerting-settings.json")
+	handler.SetAlertingSettings(engine, settingsFile)
+
+	rec = httptest.NewRecorder()
+	handler.HandleAlertingSettings(rec, httptest.NewRequest("GET", "/api/v1/admin/alerting", nil))
+	var settings server.AlertingSettings
+	if err := json.NewDecoder(rec.Body).Decode(&settings); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if settings.SystemCPUThreshold != 80 || settings.HeartbeatTimeout != "2m0s" || settings.UpdatedAt != nil {
+		t.Errorf("Unexpected settings: %+v", settings)
+	}
+
+	// Settings left out keep their value
+	rec = httptest.NewRecorder()
+	body := `{"system_cpu_threshold": 95, "heartbeat_timeout": "5m"}`
+	handler.HandleAlertingSettings(rec, httptest.NewRequest("PUT", "/api/v1/admin/alerting", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	config := engine.Config()
+	if config.SystemCPUThreshold != 95 || config.HeartbeatTimeout != 5*time.Minute || config.DeduplicationWindow != 5*time.Minute {
+		t.Errorf("Expected the engine to be reconfigured, got %+v", config)
+	}
+
+	saved, err := server.LoadAlertingSettings(settingsFile)
+	if err != nil || saved == nil {
+		t.Fatalf("Expected the settings to be saved, got %v", err)
+	}
+	if saved.SystemCPUThreshold != 95 || saved.UpdatedAt == nil {
+		t.Errorf("Unexpected saved settings: %+v", saved)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.HandleAlertingSettings(rec, httptest.NewRequest("GET", "/api/v1/admin/alerting", nil))
+	settings = server.AlertingSettings{}
+	if err := json.NewDecoder(rec.Body).Decode(&settings); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if settings.UpdatedAt == nil {
+		t.Error("Expected the time of the last change")
+	}
+
+	tests := []string{
+		`{"system_disk_threshold": 150}`,
+		`{"deduplication_window": "later"}`,
+		`{"heartbeat_timeout": 300}`,
+		`{`,
+	}
+	for _, body := range tests {
+		rec := httptest.NewRecorder()
+		handler.HandleAlertingSettings(rec, httptest.NewRequest("PUT", "/api/v1/admin/alerting", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", body, rec.Code)
+		}
+	}
+	if engine.Config().SystemCPUThreshold != 95 {
+		t.Error("Expected rejected changes to leave the engine alone")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.HandleAlertingSettings(rec, httptest.NewRequest("POST", "/api/v1/admin/alerting", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
+
+// gatedReader holds a request body back until release is closed
+type gatedReader struct {
+	release <-chan struct{}
+	r       io.Reader
+}
+
+func (g *gatedReader) Read(p []byte) (int, error) {
+	<-g.release
+	return g.r.Read(p)
+}
+
+func TestHandleAlertingSettings_ConcurrentUpdates(t *testing.T) {
+	state := server.NewStateStore()
+	handler := NewHandler(state)
+
+	engine := alerting.NewEngine(server.NewAlertingAdapter(state), &alerting.Config{
+		Enabled:          true,
+		HeartbeatTimeout: 2 * time.Minute,
+	}, alerting.NewConsoleNotifier())
+	settingsFile := filepath.Join(t.TempDir(), "alerting-settings.json")
+	handler.SetAlertingSettings(engine, settingsFile)
+
+	// Each request changes a different setting, and its body arrives only
+	// once all of them are in flight; none of the changes may be lost
+	bodies := []string{
+		`{"system_cpu_threshold": 91}`,
+		`{"system_memory_threshold": 92}`,
+		`{"system_disk_threshold": 93}`,
+	}
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for _, body := range bodies {
+		wg.Add(1)
+		go func(body string) {
+			defer wg.Done()
+			req := httptest.NewRequest("PUT", "/api/v1/admin/alerting", &gatedReader{release: release, r: strings.NewReader(body)})
+			rec := httptest.NewRecorder()
+			handler.HandleAlertingSettings(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+		}(body)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	config := engine.Config()
+	if config.SystemCPUThreshold != 91 || config.SystemMemoryThreshold != 92 || config.SystemDiskThreshold != 93 {
+		t.Errorf("Expected every change to reach the engine, got cpu %v memory %v disk %v",
+			config.SystemCPUThreshold, config.SystemMemoryThreshold, config.SystemDiskThreshold)
+	}
+
+	saved, err := server.LoadAlertingSettings(settingsFile)
+	if err != nil || saved == nil {
+		t.Fatalf("Expected the settings to be saved, got %v", err)
+	}
+	if saved.SystemCPUThreshold != 91 || saved.SystemMemoryThreshold != 92 || saved.SystemDiskThreshold != 93 {
+		t.Errorf("Expected every change to be saved, got %+v", saved)
+	}
+}
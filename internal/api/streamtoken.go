@@ -0,0 +1,119 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+)
+
+// streamTokenClaims is the signed part of a stream token
+type streamTokenClaims struct {
+	Subject string `json:"sub"` // Name of the API key it was minted with
+	Expires int64  `json:"exp"` // Unix seconds
+}
+
+// StreamTokens mints and checks short-lived signed tokens that authenticate
+// streaming endpoints, such as /api/v1/events, in the query string, since
+// browsers can't set an Authorization header on EventSource or WebSocket
+// requests
+type StreamTokens struct {
+	auth   *AuthConfig
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewStreamTokens creates stream tokens signed with secret and valid for
+// ttl, minted with the API keys of ac. Redundant servers must share the
+// secret to accept each other's tokens.
+func (ac *AuthConfig) NewStreamTokens(secret []byte, ttl time.Duration) *StreamTokens {
+	return &StreamTokens{auth: ac, secret: secret, ttl: ttl, now: time.Now}
+}
+
+// Mint returns a token for the API key named subject, and when it expires
+func (t *StreamTokens) Mint(subject string) (string, time.Time) {
+	expires := t.now().Add(t.ttl).Truncate(time.Second)
+	claims, _ := json.Marshal(streamTokenClaims{Subject: subject, Expires: expires.Unix()})
+	payload := base64.RawURLEncoding.EncodeToString(claims)
+	return payload + "." + t.sign(payload), expires
+}
+
+// Verify checks a token's signature and expiry, returning its subject
+func (t *StreamTokens) Verify(token string) (string, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(t.sign(payload))) {
+		return "", errors.New("invalid token")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", errors.New("invalid token")
+	}
+	var claims streamTokenClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return "", errors.New("invalid token")
+	}
+	if !t.now().Before(time.Unix(claims.Expires, 0)) {
+		return "", errors.New("token expired")
+	}
+	return claims.Subject, nil
+}
+
+func (t *StreamTokens) sign(payload string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Middleware authenticates a streaming endpoint with a token in the
+// ?token= query parameter, or an API key in the Authorization header for
+// clients that can send one. Unless required, requests with neither are let
+// through.
+func (t *StreamTokens) Middleware(required bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		logger := logging.Component("auth")
+		withKey := t.auth.AuthMiddleware(nil)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.URL.Query().Get("token")
+			switch {
+			case token != "":
+				if _, err := t.Verify(token); err != nil {
+					logger.Warn("Rejected stream token", "remote_addr", r.RemoteAddr, logging.Err(err))
+					http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r)
+			case r.Header.Get("Authorization") != "":
+				withKey.ServeHTTP(w, r)
+			case required:
+				logger.Warn("Missing stream token", "remote_addr", r.RemoteAddr)
+				http.Error(w, "Unauthorized: Missing token", http.StatusUnauthorized)
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// HandleMint handles POST /api/v1/auth/stream-token, which trades an API
+// key for a stream token. Wrap it in AuthMiddleware.
+func (t *StreamTokens) HandleMint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, expires := t.Mint(KeyName(r.Context()))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	response := map[string]interface{}{"token": token, "expires_at": expires}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.Component("auth").Error("Error encoding stream token response", logging.Err(err))
+	}
+}
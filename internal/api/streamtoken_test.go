@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStreamTokens() *StreamTokens {
+	auth := NewAuthConfig([]APIKey{{Key: "dashboard-key", Name: "dashboard"}})
+	return auth.NewStreamTokens([]byte("0123456789abcdef0123456789abcdef"), 5*time.Minute)
+}
+
+func TestStreamTokens_MintAndVerify(t *testing.T) {
+	tokens := newTestStreamTokens()
+	now := time.Now()
+	tokens.now = func() time.Time { return now }
+
+	token, expires := tokens.Mint("dashboard")
+	if expires.Sub(now) > 5*time.Minute {
+		t.Errorf("Expected expiry within 5m, got %v", expires.Sub(now))
+	}
+	subject, err := tokens.Verify(token)
+	if err != nil || subject != "dashboard" {
+		t.Errorf("Expected subject dashboard, got %q, %v", subject, err)
+	}
+
+	// Tampered with, or signed with another secret
+	payload, signature, _ := strings.Cut(token, ".")
+	if _, err := tokens.Verify(payload + "x." + signature); err == nil {
+		t.Error("Expected a tampered token to be rejected")
+	}
+	other := NewAuthConfig(nil).NewStreamTokens([]byte("another secret, another server.."), 5*time.Minute)
+	if _, err := other.Verify(token); err == nil {
+		t.Error("Expected a token signed with another secret to be rejected")
+	}
+
+	now = now.Add(6 * time.Minute)
+	if _, err := tokens.Verify(token); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Expected an expired token, got %v", err)
+	}
+}
+
+func TestStreamTokens_Middleware(t *testing.T) {
+	tokens := newTestStreamTokens()
+	token, _ := tokens.Mint("dashboard")
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		required bool
+		query    string
+		header   string
+		want     int
+	}{
+		{"token", true, "?token=" + token, "", http.StatusOK},
+		{"API key", true, "", "Bearer dashboard-key", http.StatusOK},
+		{"missing", true, "", "", http.StatusUnauthorized},
+		{"missing, optional", false, "", "", http.StatusOK},
+		{"invalid, optional", false, "?token=garbage", "", http.StatusUnauthorized},
+		{"wrong API key", true, "", "Bearer wrong", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/v1/events"+tt.query, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		tokens.Middleware(tt.required)(ok).ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, rec.Code)
+		}
+	}
+}
+
+func TestStreamTokens_HandleMint(t *testing.T) {
+	tokens := newTestStreamTokens()
+	handler := tokens.auth.AuthMiddleware(nil)(http.HandlerFunc(tokens.HandleMint))
+
+	req := httptest.NewRequest("POST", "/api/v1/auth/stream-token", nil)
+	req.Header.Set("Authorization", "Bearer dashboard-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var response struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if subject, err := tokens.Verify(response.Token); err != nil || subject != "dashboard" {
+		t.Errorf("Expected a token for dashboard, got %q, %v", subject, err)
+	}
+}
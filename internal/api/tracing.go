@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/anurag/saviour/internal/tracing"
+)
+
+// TracingMiddleware records a server span for every request, named by the
+// route that served it and continuing the caller's trace if it sent a W3C
+// traceparent header. It does nothing while tracing is disabled.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.StartRemote(r.Context(), r.Method, r.Header.Get("traceparent"))
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(rec, r)
+
+		// The mux records the pattern it matched on the request
+		route := r.Pattern
+		if route == "" {
+			route = r.URL.Path
+		}
+		span.SetName(r.Method + " " + route)
+		span.SetAttr("http.request.method", r.Method)
+		span.SetAttr("http.route", route)
+		span.SetAttr("url.path", r.URL.Path)
+		span.SetAttr("http.response.status_code", rec.status)
+		if rec.status >= 500 {
+			span.SetError(fmt.Errorf("status %d", rec.status))
+		}
+	})
+}
+
+// statusRecorder remembers the status code a handler answered with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Flush lets the events stream through the recorder
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anurag/saviour/internal/tracing"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	var exported struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					Name       string `json:"name"`
+					Attributes []struct {
+						Key   string                 `json:"key"`
+						Value map[string]interface{} `json:"value"`
+					} `json:"attributes"`
+					Status struct {
+						Code int `json:"code"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&exported)
+	}))
+	defer collector.Close()
+
+	tracer := tracing.New("saviour-test", collector.URL, nil, 1)
+	tracing.SetDefault(tracer)
+	defer tracing.SetDefault(nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/agents/", func(w http.ResponseWriter, r *http.Request) {
+		if tracing.FromContext(r.Context()) == nil {
+			t.Error("Expected the request span in the handler's context")
+		}
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+
+	rec := httptest.NewRecorder()
+	TracingMiddleware(mux).ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/agents/web-1", nil))
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	spans := exported.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name != "GET /api/v1/agents/" || spans[0].Status.Code != 2 {
+		t.Errorf("Expected failed span named by route, got %+v", spans[0])
+	}
+	var status interface{}
+	for _, attr := range spans[0].Attributes {
+		if attr.Key == "http.response.status_code" {
+			status = attr.Value["intValue"]
+		}
+	}
+	if status != "500" {
+		t.Errorf("Expected status code attribute 500, got %v", status)
+	}
+}
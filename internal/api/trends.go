@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+)
+
+// HandleAgentTrends handles GET /api/v1/agents/{name}/trends: disk, memory
+// and container count growth over 7 and 30 days, projected ahead, for
+// capacity planning
+func (h *Handler) HandleAgentTrends(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	agentName, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/agents/"), "/trends")
+	if !ok || agentName == "" {
+		http.Error(w, "Agent name required", http.StatusBadRequest)
+		return
+	}
+	if h.history == nil {
+		http.Error(w, "History is disabled (history.retention)", http.StatusServiceUnavailable)
+		return
+	}
+
+	trends, exists := h.history.Trends(agentName, time.Now())
+	if !exists {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(trends); err != nil {
+		h.logger.Error("Error encoding trends response", logging.Err(err))
+	}
+}
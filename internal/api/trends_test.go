@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+)
+
+func TestHandleAgentTrends(t *testing.T) {
+	handler := NewHandler(server.NewStateStore())
+
+	// Without history there is nothing to fit
+	rec := httptest.NewRecorder()
+	handler.HandleAgentTrends(rec, httptest.NewRequest("GET", "/api/v1/agents/web-1/trends", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without history, got %d", rec.Code)
+	}
+
+	history := server.NewHistory(time.Hour, 30*24*time.Hour)
+	handler.SetHistory(history)
+	now := time.Now()
+	for i := 24; i >= 0; i-- {
+		history.Record("web-1", server.HistorySample{Time: now.Add(-time.Duration(i) * time.Hour), DiskPercent: 80 - float64(i)})
+	}
+
+	rec = httptest.NewRecorder()
+	handler.HandleAgentTrends(rec, httptest.NewRequest("GET", "/api/v1/agents/web-1/trends", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var trends server.Trends
+	if err := json.NewDecoder(rec.Body).Decode(&trends); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	// A point an hour is 24 a day, full within a day
+	disk := trends.Windows[0].Disk
+	if disk == nil || disk.DaysUntilFull == nil || *disk.DaysUntilFull > 1 {
+		t.Errorf("Expected the disk to fill within a day, got %+v", disk)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.HandleAgentTrends(rec, httptest.NewRequest("GET", "/api/v1/agents/missing/trends", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown agent, got %d", rec.Code)
+	}
+}
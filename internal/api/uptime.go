@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+)
+
+// defaultUptimeWindow is the window uptime reports cover without ?window
+const defaultUptimeWindow = 30 * 24 * time.Hour
+
+// HandleAgentUptime handles GET /api/v1/agents/{name}/uptime?window=30d:
+// the agent's availability, offline incidents and mean time to recovery
+func (h *Handler) HandleAgentUptime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	agentName, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/agents/"), "/uptime")
+	if !ok || agentName == "" {
+		http.Error(w, "Agent name required", http.StatusBadRequest)
+		return
+	}
+	from, to, ok := h.uptimeWindow(w, r)
+	if !ok {
+		return
+	}
+
+	uptime, exists := h.history.Uptime(agentName, from, to)
+	if !exists {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(uptime); err != nil {
+		h.logger.Error("Error encoding uptime response", logging.Err(err))
+	}
+}
+
+// HandleFleetUptime handles GET /api/v1/uptime?window=30d: availability
+// across all agents, with a line per agent
+func (h *Handler) HandleFleetUptime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	from, to, ok := h.uptimeWindow(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.history.FleetUptime(from, to)); err != nil {
+		h.logger.Error("Error encoding uptime response", logging.Err(err))
+	}
+}
+
+// uptimeWindow reads ?window, writing an error if it is invalid or the
+// server keeps no history
+func (h *Handler) uptimeWindow(w http.ResponseWriter, r *http.Request) (from, to time.Time, ok bool) {
+	if h.history == nil {
+		http.Error(w, "History is disabled (history.retention)", http.StatusServiceUnavailable)
+		return from, to, false
+	}
+
+	window := defaultUptimeWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		var err error
+		if window, err = parseWindow(value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return from, to, false
+		}
+	}
+	to = time.Now()
+	return to.Add(-window), to, true
+}
+
+// parseWindow parses a duration such as "12h", also accepting whole days
+// such as "30d"
+func parseWindow(value string) (time.Duration, error) {
+	var window time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		window, err = time.ParseDuration(value)
+	}
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("window must be a positive duration, e.g. \"30d\" or \"12h\", got %q", value)
+	}
+	return window, nil
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+)
+
+func TestHandleAgentUptime(t *testing.T) {
+	history := server.NewHistory(time.Hour, 0)
+	handler := NewHandler(server.NewStateStore())
+	handler.SetHistory(history)
+
+	now := time.Now()
+	history.StatusChanged("web-1", "", "online", now.Add(-4*time.Hour), now.Add(-4*time.Hour))
+	history.StatusChanged("web-1", "online", "offline", now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	history.StatusChanged("web-1", "offline", "online", now.Add(-time.Hour), now.Add(-time.Hour))
+
+	req := httptest.NewRequest("GET", "/api/v1/agents/web-1/uptime?window=3h", nil)
+	rec := httptest.NewRecorder()
+	handler.HandleAgentUptime(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var uptime server.Uptime
+	if err := json.NewDecoder(rec.Body).Decode(&uptime); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(uptime.Incidents) != 1 || uptime.MTTRSeconds < 3599 || uptime.MTTRSeconds > 3601 {
+		t.Errorf("Expected 1 incident with an MTTR of 1h, got %+v", uptime)
+	}
+	if uptime.AvailabilityPercent < 66 || uptime.AvailabilityPercent > 67 {
+		t.Errorf("Expected about 66.7%% availability over 3h, got %v", uptime.AvailabilityPercent)
+	}
+
+	tests := map[string]int{
+		"/api/v1/agents/web-1/uptime?window=7d":   http.StatusOK,
+		"/api/v1/agents/web-1/uptime?window=-1h":  http.StatusBadRequest,
+		"/api/v1/agents/web-1/uptime?window=week": http.StatusBadRequest,
+		"/api/v1/agents/missing/uptime":           http.StatusNotFound,
+	}
+	for path, want := range tests {
+		rec := httptest.NewRecorder()
+		handler.HandleAgentUptime(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestHandleFleetUptime(t *testing.T) {
+	handler := NewHandler(server.NewStateStore())
+
+	// Without history there is nothing to report from
+	rec := httptest.NewRecorder()
+	handler.HandleFleetUptime(rec, httptest.NewRequest("GET", "/api/v1/uptime", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without history, got %d", rec.Code)
+	}
+
+	history := server.NewHistory(time.Hour, 0)
+	handler.SetHistory(history)
+	history.StatusChanged("web-1", "", "online", time.Now(), time.Now())
+
+	rec = httptest.NewRecorder()
+	handler.HandleFleetUptime(rec, httptest.NewRequest("GET", "/api/v1/uptime", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var fleet server.FleetUptime
+	if err := json.NewDecoder(rec.Body).Decode(&fleet); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(fleet.Agents) != 1 || fleet.AvailabilityPercent != 100 {
+		t.Errorf("Expected 1 agent at 100%%, got %+v", fleet)
+	}
+	if window := fleet.To.Sub(fleet.From); window != 30*24*time.Hour {
+		t.Errorf("Expected a 30 day default window, got %v", window)
+	}
+}
@@ -3,20 +3,22 @@ package collector
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/anurag/saviour/internal/docker"
+	"github.com/anurag/saviour/pkg/metrics"
+	"github.com/shirou/gopsutil/v3/disk"
 )
 
 // DockerCollector collects Docker container metrics
 type DockerCollector struct {
 	client *docker.Client
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 // NewDockerCollector creates a new Docker collector
-func NewDockerCollector(socketPath string, filterConfig docker.FilterConfig, logger *log.Logger) (*DockerCollector, error) {
+func NewDockerCollector(socketPath string, filterConfig docker.FilterConfig, logger *slog.Logger) (*DockerCollector, error) {
 	client, err := docker.NewClient(socketPath, filterConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %w", err)
@@ -32,12 +34,29 @@ func NewDockerCollector(socketPath string, filterConfig docker.FilterConfig, log
 		return nil, fmt.Errorf("failed to connect to Docker daemon: %w", err)
 	}
 
+	client.TrackOOMEvents()
+
 	return &DockerCollector{
 		client: client,
 		logger: logger,
 	}, nil
 }
 
+// SetCollectionLimits configures collection concurrency and per-container timeout
+func (c *DockerCollector) SetCollectionLimits(workers int, containerTimeout time.Duration) {
+	c.client.SetCollectionLimits(workers, containerTimeout)
+}
+
+// EnableImageUpdateCheck turns on periodic registry checks for newer images
+func (c *DockerCollector) EnableImageUpdateCheck(interval time.Duration) {
+	c.client.EnableImageUpdateCheck(interval)
+}
+
+// EnableStatsStreaming reads container stats from long-lived streams
+func (c *DockerCollector) EnableStatsStreaming() {
+	c.client.EnableStatsStreaming()
+}
+
 // Collect gathers all container metrics
 func (c *DockerCollector) Collect(ctx context.Context) ([]docker.ContainerInfo, error) {
 	containers, err := c.client.GetAllContainerInfo(ctx)
@@ -48,6 +67,45 @@ func (c *DockerCollector) Collect(ctx context.Context) ([]docker.ContainerInfo,
 	return containers, nil
 }
 
+// CollectDaemon gathers Docker daemon metrics, including disk usage of the
+// data root when it is visible to the agent
+func (c *DockerCollector) CollectDaemon(ctx context.Context) (*metrics.DockerMetrics, error) {
+	info, err := c.client.GetDaemonInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &metrics.DockerMetrics{
+		Version:           info.Version,
+		APIVersion:        info.APIVersion,
+		StorageDriver:     info.StorageDriver,
+		Containers:        info.Containers,
+		ContainersRunning: info.ContainersRunning,
+		ContainersPaused:  info.ContainersPaused,
+		ContainersStopped: info.ContainersStopped,
+		Images:            info.Images,
+		Goroutines:        info.Goroutines,
+		DataRoot:          info.DataRoot,
+	}
+
+	// Data root may not be mounted into the agent's filesystem (e.g. when
+	// running in a container), so usage is best effort
+	if info.DataRoot != "" {
+		if usage, err := disk.Usage(info.DataRoot); err == nil {
+			m.DataRootTotal = usage.Total
+			m.DataRootUsed = usage.Used
+			m.DataRootUsedPercent = usage.UsedPercent
+		}
+	}
+
+	return m, nil
+}
+
+// RestartContainer starts a stopped container again
+func (c *DockerCollector) RestartContainer(ctx context.Context, containerID string) error {
+	return c.client.StartContainer(ctx, containerID)
+}
+
 // Close closes the Docker client connection
 func (c *DockerCollector) Close() error {
 	if c.client != nil {
@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anurag/saviour/internal/docker"
+)
+
+// ECSCollector collects container metrics from the ECS task metadata
+// endpoint when the Docker socket is unavailable (e.g. on Fargate)
+type ECSCollector struct {
+	client *docker.ECSClient
+}
+
+// NewECSCollector creates a collector for the given task metadata endpoint
+func NewECSCollector(metadataURI string) *ECSCollector {
+	return &ECSCollector{
+		client: docker.NewECSClient(metadataURI),
+	}
+}
+
+// Collect gathers metrics for all containers in the task
+func (c *ECSCollector) Collect(ctx context.Context) ([]docker.ContainerInfo, error) {
+	containers, err := c.client.GetAllContainerInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect task container info: %w", err)
+	}
+
+	return containers, nil
+}
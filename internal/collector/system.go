@@ -1,14 +1,16 @@
 package collector
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"sync"
 	"time"
 
 	"github.com/anurag/saviour/pkg/metrics"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/host"
-	"github.com/shirou/gopsutil/v3/load"
 	"github.com/shirou/gopsutil/v3/mem"
 	"github.com/shirou/gopsutil/v3/net"
 )
@@ -17,6 +19,17 @@ import (
 type SystemCollector struct {
 	agentName  string
 	diskMounts []string
+
+	// CPU times at the previous collection, which usage is measured since
+	cpuMu       sync.Mutex
+	lastCPU     cpu.TimesStat
+	lastPerCore []cpu.TimesStat
+
+	// Network counters at the previous collection, which rates are
+	// measured since
+	netMu     sync.Mutex
+	lastNet   metrics.NetworkMetrics
+	lastNetAt time.Time
 }
 
 // NewSystemCollector creates a new system metrics collector
@@ -27,105 +40,139 @@ func NewSystemCollector(agentName string, diskMounts []string) *SystemCollector
 	}
 }
 
-// Collect gathers all system metrics
-func (c *SystemCollector) Collect() (*metrics.SystemMetrics, error) {
+// Collect gathers all system metrics. It is best-effort: a source that
+// fails leaves its metrics zero and is listed in CollectorErrors, so one
+// broken source doesn't hide the rest. Sources are read concurrently, so a
+// slow one (e.g. a hung network mount) doesn't hold up the others.
+func (c *SystemCollector) Collect() *metrics.SystemMetrics {
 	m := &metrics.SystemMetrics{
 		Timestamp: time.Now(),
 		AgentName: c.agentName,
 	}
 
-	// Collect CPU metrics
-	cpuMetrics, err := c.collectCPU()
-	if err != nil {
-		return nil, fmt.Errorf("failed to collect CPU metrics: %w", err)
-	}
-	m.CPU = cpuMetrics
-
-	// Collect memory metrics
-	memMetrics, err := c.collectMemory()
-	if err != nil {
-		return nil, fmt.Errorf("failed to collect memory metrics: %w", err)
+	// Each source sets its own part of m
+	sources := []struct {
+		name    string
+		collect func() error
+	}{
+		{"cpu", func() (err error) { m.CPU, err = c.collectCPU(); return }},
+		{"memory", func() (err error) { m.Memory, err = c.collectMemory(); return }},
+		{"disk", func() (err error) { m.Disk, err = c.collectDisk(); return }},
+		{"network", func() (err error) { m.Network, err = c.collectNetwork(); return }},
+		{"system info", func() (err error) { m.SystemInfo, err = c.collectSystemInfo(); return }},
 	}
-	m.Memory = memMetrics
-
-	// Collect disk metrics
-	diskMetrics, err := c.collectDisk()
-	if err != nil {
-		return nil, fmt.Errorf("failed to collect disk metrics: %w", err)
-	}
-	m.Disk = diskMetrics
-
-	// Collect network metrics
-	netMetrics, err := c.collectNetwork()
-	if err != nil {
-		return nil, fmt.Errorf("failed to collect network metrics: %w", err)
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = source.collect()
+		}()
 	}
-	m.Network = netMetrics
+	wg.Wait()
 
-	// Collect system info
-	sysInfo, err := c.collectSystemInfo()
-	if err != nil {
-		return nil, fmt.Errorf("failed to collect system info: %w", err)
+	for i, err := range errs {
+		if err != nil {
+			m.CollectorErrors = append(m.CollectorErrors, sources[i].name+": "+err.Error())
+		}
 	}
-	m.SystemInfo = sysInfo
-
-	return m, nil
+	return m
 }
 
+// collectCPU gathers usage and load averages, keeping whichever succeed.
+// Usage is measured from the CPU times since the previous collection rather
+// than by sampling over a second, so it doesn't block; the first collection
+// reports the average since boot.
 func (c *SystemCollector) collectCPU() (metrics.CPUMetrics, error) {
 	var m metrics.CPUMetrics
+	var errs []error
 
+	c.cpuMu.Lock()
 	// Overall CPU usage
-	percentages, err := cpu.Percent(time.Second, false)
+	total, err := cpu.Times(false)
 	if err != nil {
-		return m, err
-	}
-	if len(percentages) > 0 {
-		m.UsagePercent = percentages[0]
+		errs = append(errs, fmt.Errorf("usage: %w", err))
+	} else if len(total) > 0 {
+		m.UsagePercent = cpuPercent(c.lastCPU, total[0])
+		c.lastCPU = total[0]
 	}
 
 	// Per-core usage
-	perCore, err := cpu.Percent(time.Second, true)
+	perCore, err := cpu.Times(true)
 	if err != nil {
-		return m, err
+		errs = append(errs, fmt.Errorf("per-core usage: %w", err))
+	} else {
+		m.PerCorePercent = make([]float64, len(perCore))
+		for i, times := range perCore {
+			var last cpu.TimesStat // Cores that came online since start from boot
+			if i < len(c.lastPerCore) && c.lastPerCore[i].CPU == times.CPU {
+				last = c.lastPerCore[i]
+			}
+			m.PerCorePercent[i] = cpuPercent(last, times)
+		}
+		c.lastPerCore = perCore
 	}
-	m.PerCorePercent = perCore
+	c.cpuMu.Unlock()
 
-	// Load average
-	loadAvg, err := load.Avg()
+	// Load average, which fails in some containers
+	loadAvg, err := loadAverage()
 	if err != nil {
-		return m, err
+		errs = append(errs, fmt.Errorf("load average: %w", err))
+	} else {
+		m.LoadAvg1 = loadAvg.Load1
+		m.LoadAvg5 = loadAvg.Load5
+		m.LoadAvg15 = loadAvg.Load15
 	}
-	m.LoadAvg1 = loadAvg.Load1
-	m.LoadAvg5 = loadAvg.Load5
-	m.LoadAvg15 = loadAvg.Load15
 
-	return m, nil
+	return m, errors.Join(errs...)
+}
+
+// cpuPercent is the share of time the CPU was busy between two readings of
+// its times
+func cpuPercent(last, current cpu.TimesStat) float64 {
+	lastBusy, lastTotal := cpuBusy(last)
+	busy, total := cpuBusy(current)
+	if total <= lastTotal || busy <= lastBusy {
+		return 0
+	}
+	return math.Min(100, (busy-lastBusy)/(total-lastTotal)*100)
 }
 
+// cpuBusy splits CPU times into busy and total time. Guest time is already
+// counted in user time, so it is left out.
+func cpuBusy(t cpu.TimesStat) (busy, total float64) {
+	total = t.User + t.System + t.Idle + t.Nice + t.Iowait + t.Irq + t.Softirq + t.Steal
+	return total - t.Idle - t.Iowait, total
+}
+
+// collectMemory gathers memory and swap usage, keeping whichever succeed
 func (c *SystemCollector) collectMemory() (metrics.MemoryMetrics, error) {
 	var m metrics.MemoryMetrics
+	var errs []error
 
 	// Virtual memory
 	vmem, err := mem.VirtualMemory()
 	if err != nil {
-		return m, err
+		errs = append(errs, err)
+	} else {
+		m.Total = vmem.Total
+		m.Available = vmem.Available
+		m.Used = vmem.Used
+		m.UsedPercent = vmem.UsedPercent
 	}
-	m.Total = vmem.Total
-	m.Available = vmem.Available
-	m.Used = vmem.Used
-	m.UsedPercent = vmem.UsedPercent
 
 	// Swap memory
 	swap, err := mem.SwapMemory()
 	if err != nil {
-		return m, err
+		errs = append(errs, fmt.Errorf("swap: %w", err))
+	} else {
+		m.SwapTotal = swap.Total
+		m.SwapUsed = swap.Used
+		m.SwapPercent = swap.UsedPercent
 	}
-	m.SwapTotal = swap.Total
-	m.SwapUsed = swap.Used
-	m.SwapPercent = swap.UsedPercent
 
-	return m, nil
+	return m, errors.Join(errs...)
 }
 
 func (c *SystemCollector) collectDisk() ([]metrics.DiskMetrics, error) {
@@ -134,13 +181,10 @@ func (c *SystemCollector) collectDisk() ([]metrics.DiskMetrics, error) {
 	// If no specific mounts configured, get all partitions
 	mounts := c.diskMounts
 	if len(mounts) == 0 {
-		partitions, err := disk.Partitions(false)
-		if err != nil {
+		var err error
+		if mounts, err = defaultMounts(); err != nil {
 			return nil, err
 		}
-		for _, p := range partitions {
-			mounts = append(mounts, p.Mountpoint)
-		}
 	}
 
 	// Collect metrics for each mount point
@@ -169,6 +213,7 @@ func (c *SystemCollector) collectDisk() ([]metrics.DiskMetrics, error) {
 	return diskMetrics, nil
 }
 
+// collectNetwork gathers the traffic of all interfaces, with its rates
 func (c *SystemCollector) collectNetwork() (metrics.NetworkMetrics, error) {
 	var m metrics.NetworkMetrics
 
@@ -190,9 +235,35 @@ func (c *SystemCollector) collectNetwork() (metrics.NetworkMetrics, error) {
 		m.DropsOut += counter.Dropout
 	}
 
+	c.netMu.Lock()
+	defer c.netMu.Unlock()
+	now := time.Now()
+	if !c.lastNetAt.IsZero() {
+		networkRates(&m, c.lastNet, now.Sub(c.lastNetAt))
+	}
+	c.lastNet, c.lastNetAt = m, now
+
 	return m, nil
 }
 
+// networkRates sets the send and receive rates of m from the counters
+// elapsed earlier
+func networkRates(m *metrics.NetworkMetrics, last metrics.NetworkMetrics, elapsed time.Duration) {
+	m.BytesSentPerSec = counterRate(last.BytesSent, m.BytesSent, elapsed)
+	m.BytesRecvPerSec = counterRate(last.BytesRecv, m.BytesRecv, elapsed)
+	m.PacketsSentPerSec = counterRate(last.PacketsSent, m.PacketsSent, elapsed)
+	m.PacketsRecvPerSec = counterRate(last.PacketsRecv, m.PacketsRecv, elapsed)
+}
+
+// counterRate is how fast a counter grew per second. A counter that went
+// down, e.g. because an interface was removed, has no rate.
+func counterRate(last, current uint64, elapsed time.Duration) float64 {
+	if current < last || elapsed <= 0 {
+		return 0
+	}
+	return float64(current-last) / elapsed.Seconds()
+}
+
 func (c *SystemCollector) collectSystemInfo() (metrics.SystemInfo, error) {
 	var m metrics.SystemInfo
 
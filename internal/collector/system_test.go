@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/pkg/metrics"
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+func TestCPUPercent(t *testing.T) {
+	last := cpu.TimesStat{User: 100, System: 50, Idle: 800, Iowait: 50}
+
+	tests := []struct {
+		name     string
+		current  cpu.TimesStat
+		expected float64
+	}{
+		{"busy quarter", cpu.TimesStat{User: 120, System: 55, Idle: 870, Iowait: 55}, 25},
+		{"idle", cpu.TimesStat{User: 100, System: 50, Idle: 900, Iowait: 50}, 0},
+		{"no time passed", last, 0},
+		{"guest time not counted twice", cpu.TimesStat{User: 150, System: 50, Idle: 850, Iowait: 50, Guest: 50}, 50},
+	}
+	for _, tt := range tests {
+		if got := cpuPercent(last, tt.current); got != tt.expected {
+			t.Errorf("%s: expected %v%%, got %v%%", tt.name, tt.expected, got)
+		}
+	}
+
+	// The first collection averages since boot
+	if got := cpuPercent(cpu.TimesStat{}, last); got != 15 {
+		t.Errorf("Expected 15%% since boot, got %v%%", got)
+	}
+}
+
+func TestNetworkRates(t *testing.T) {
+	last := metrics.NetworkMetrics{BytesSent: 1000, BytesRecv: 5000, PacketsSent: 10, PacketsRecv: 40}
+	m := metrics.NetworkMetrics{BytesSent: 31000, BytesRecv: 4000, PacketsSent: 40, PacketsRecv: 100}
+
+	networkRates(&m, last, 15*time.Second)
+	if m.BytesSentPerSec != 2000 || m.PacketsSentPerSec != 2 || m.PacketsRecvPerSec != 4 {
+		t.Errorf("Expected 2000 B/s and 2 and 4 packets/s, got %+v", m)
+	}
+	// Received bytes went down, e.g. an interface was removed
+	if m.BytesRecvPerSec != 0 {
+		t.Errorf("Expected no receive rate for a counter that went down, got %v", m.BytesRecvPerSec)
+	}
+	if m.BytesSent != 31000 {
+		t.Errorf("Expected totals kept, got %d", m.BytesSent)
+	}
+}
+
+func TestCollect_DoesNotBlockOnCPU(t *testing.T) {
+	c := NewSystemCollector("test-agent", []string{"/"})
+	first := c.Collect()
+	second := c.Collect()
+
+	if second.AgentName != "test-agent" {
+		t.Errorf("Expected agent name test-agent, got %s", second.AgentName)
+	}
+	if elapsed := second.Timestamp.Sub(first.Timestamp); elapsed >= 900*time.Millisecond {
+		t.Errorf("Expected collection well under a second, took %v", elapsed)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package collector
+
+import (
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/load"
+)
+
+// loadAverage reads the kernel's load averages
+func loadAverage() (*load.AvgStat, error) {
+	return load.Avg()
+}
+
+// defaultMounts lists every mounted filesystem
+func defaultMounts() ([]string, error) {
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return nil, err
+	}
+	mounts := make([]string, 0, len(partitions))
+	for _, p := range partitions {
+		mounts = append(mounts, p.Mountpoint)
+	}
+	return mounts, nil
+}
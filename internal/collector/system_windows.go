@@ -0,0 +1,43 @@
+//go:build windows
+
+package collector
+
+import (
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/load"
+	"golang.org/x/sys/windows"
+)
+
+// loadAverage approximates load averages from the processor queue length
+// counter, which gopsutil samples every 5 seconds in the background, so they
+// read zero for the first collections. Windows has no real load average, so a
+// failing counter leaves them at zero rather than failing collection.
+func loadAverage() (*load.AvgStat, error) {
+	avg, err := load.Avg()
+	if err != nil {
+		return &load.AvgStat{}, nil
+	}
+	return avg, nil
+}
+
+// defaultMounts lists the local fixed drives, e.g. C:. Removable, optical and
+// network drives are left out as they may be empty or slow to query.
+// Partitions reports drives it couldn't read as warnings alongside the rest,
+// so an error only counts when nothing was found.
+func defaultMounts() ([]string, error) {
+	partitions, err := disk.Partitions(false)
+	if len(partitions) == 0 && err != nil {
+		return nil, err
+	}
+	mounts := make([]string, 0, len(partitions))
+	for _, p := range partitions {
+		root, err := windows.UTF16PtrFromString(p.Mountpoint + `\`)
+		if err != nil {
+			continue
+		}
+		if windows.GetDriveType(root) == windows.DRIVE_FIXED {
+			mounts = append(mounts, p.Mountpoint)
+		}
+	}
+	return mounts, nil
+}
@@ -2,9 +2,13 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"runtime"
 	"time"
 
+	"github.com/anurag/saviour/internal/logging"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,7 +17,8 @@ type Config struct {
 	Agent        AgentConfig        `yaml:"agent"`
 	Metrics      MetricsConfig      `yaml:"metrics"`
 	HealthChecks []HealthCheckConfig `yaml:"health_checks"`
-	Alerts       AlertsConfig       `yaml:"alerts"`
+	Alerts       AlertsConfig        `yaml:"alerts"`
+	Logging      logging.Config      `yaml:"logging"`
 }
 
 // AgentConfig contains agent-specific settings
@@ -24,9 +29,80 @@ type AgentConfig struct {
 	CollectInterval   time.Duration `yaml:"collect_interval"`
 	PushInterval      time.Duration `yaml:"push_interval"`
 	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
-	PushTimeout       time.Duration `yaml:"push_timeout"`
+	PushTimeout       time.Duration `yaml:"push_timeout"` // Per attempt; retries get their own
 	RetryAttempts     int           `yaml:"retry_attempts"`
 	RetryBackoff      time.Duration `yaml:"retry_backoff"`
+
+	// Cloud metadata provider: auto (default), ecs, aws, gcp, azure or none
+	CloudProvider string `yaml:"cloud_provider"`
+
+	// EC2 metadata endpoint, e.g. an IMDS proxy (default: $AWS_EC2_METADATA_SERVICE_ENDPOINT
+	// or http://169.254.169.254)
+	IMDSEndpoint string `yaml:"imds_endpoint"`
+
+	// How often cloud instance metadata is re-fetched
+	MetadataRefreshInterval time.Duration `yaml:"metadata_refresh_interval"`
+
+	// Remove the agent from the server on shutdown instead of letting it go
+	// offline. Needs a key with the admin:write scope.
+	DeregisterOnShutdown bool `yaml:"deregister_on_shutdown"`
+
+	// Tell the server on shutdown that this host is being retired for good,
+	// so it never raises an offline alert for it
+	DecommissionOnShutdown bool `yaml:"decommission_on_shutdown"`
+
+	// Send only changed containers between periodic full pushes
+	DeltaPush DeltaPushConfig `yaml:"delta_push"`
+
+	// How pushes and heartbeats are encoded: json (default) or msgpack,
+	// which is smaller and cheaper on hosts with many containers. The
+	// server must be new enough to accept MessagePack.
+	PayloadEncoding string `yaml:"payload_encoding"`
+
+	// Connection settings for talking to the server
+	Transport TransportConfig `yaml:"transport"`
+
+	// Serve metrics for the server to scrape, instead of or as well as
+	// pushing them
+	Pull PullConfig `yaml:"pull"`
+}
+
+// PullConfig lets the server scrape the agent's latest metrics from
+// GET /api/v1/metrics, for hosts that can't connect out to the server.
+// Scrapes must present the token as a bearer token.
+type PullConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Listen      string `yaml:"listen"` // Default: :9150
+	Token       string `yaml:"token"`
+	TLSCertFile string `yaml:"tls_cert_file"` // Serve HTTPS with this certificate and key
+	TLSKeyFile  string `yaml:"tls_key_file"`
+}
+
+// TransportConfig tunes the HTTP connections to the server. Connections are
+// kept open between pushes and heartbeats; keep idle_conn_timeout above the
+// push and heartbeat intervals so they aren't re-established each time.
+type TransportConfig struct {
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout"`
+	TLSHandshakeTimeout time.Duration `yaml:"tls_handshake_timeout"`
+	KeepAlive           time.Duration `yaml:"keep_alive"` // TCP keep-alive probe interval
+
+	// A push gets a fresh connection within dial_timeout. With ip_family
+	// auto that includes resolving the server's name, and IPv4 and IPv6 are
+	// tried in parallel; otherwise the name is resolved within dns_timeout
+	// and dial_timeout applies to each address.
+	DialTimeout time.Duration `yaml:"dial_timeout"`
+	DNSTimeout  time.Duration `yaml:"dns_timeout"`
+	// Which of the server's addresses to dial: "auto", "prefer_ipv4",
+	// "prefer_ipv6", or only "ipv4" or "ipv6"
+	IPFamily string `yaml:"ip_family"`
+}
+
+// DeltaPushConfig controls delta metrics pushes, which cut bandwidth on
+// hosts with many mostly idle containers
+type DeltaPushConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	FullInterval time.Duration `yaml:"full_interval"` // How often the full state is sent anyway
 }
 
 // MetricsConfig defines what metrics to collect
@@ -39,11 +115,39 @@ type MetricsConfig struct {
 
 // DockerConfig defines Docker monitoring settings
 type DockerConfig struct {
-	Enabled    bool                       `yaml:"enabled"`
-	Socket     string                     `yaml:"socket"`
-	MonitorAll bool                       `yaml:"monitor_all"`
-	Filters    DockerFilterConfig         `yaml:"filters"`
-	Alerts     DockerAlertsConfig         `yaml:"alerts"`
+	Enabled    bool               `yaml:"enabled"`
+	Socket     string             `yaml:"socket"`
+	MonitorAll bool               `yaml:"monitor_all"`
+	Filters    DockerFilterConfig `yaml:"filters"`
+	Alerts     DockerAlertsConfig `yaml:"alerts"`
+
+	ImageUpdateCheck ImageUpdateCheckConfig `yaml:"image_update_check"`
+	Remediation      RemediationConfig      `yaml:"remediation"`
+
+	CollectWorkers int           `yaml:"collect_workers"` // Containers inspected in parallel
+	CollectTimeout time.Duration `yaml:"collect_timeout"` // Per-container inspect + stats timeout
+	StreamStats    bool          `yaml:"stream_stats"`    // Keep a stats stream open per running container
+}
+
+// RemediationConfig defines opt-in automatic restarts of stopped containers.
+// Only containers matching a policy are ever touched.
+type RemediationConfig struct {
+	Enabled  bool                `yaml:"enabled"`
+	Policies []RemediationPolicy `yaml:"policies"`
+}
+
+// RemediationPolicy defines how a set of containers is remediated
+type RemediationPolicy struct {
+	Name            string        `yaml:"name"`              // Container name or glob pattern
+	MaxAttempts     int           `yaml:"max_attempts"`      // Give up after this many restarts
+	Cooloff         time.Duration `yaml:"cooloff"`           // Minimum time between restarts
+	IgnoreExitCodes []int         `yaml:"ignore_exit_codes"` // Exit codes treated as intentional stops
+}
+
+// ImageUpdateCheckConfig controls comparing running images against the registry
+type ImageUpdateCheckConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"` // How often each image is re-checked
 }
 
 // DockerFilterConfig defines container filtering options
@@ -51,6 +155,11 @@ type DockerFilterConfig struct {
 	Labels []string `yaml:"labels"`
 	Names  []string `yaml:"names"`
 	Images []string `yaml:"images"`
+
+	// Exclusions are applied after the include filters (and with monitor_all)
+	ExcludeLabels []string `yaml:"exclude_labels"`
+	ExcludeNames  []string `yaml:"exclude_names"`
+	ExcludeImages []string `yaml:"exclude_images"`
 }
 
 // DockerAlertsConfig defines container alert thresholds
@@ -77,8 +186,16 @@ type ContainerAlertOverride struct {
 
 // ProcessConfig defines a process to monitor
 type ProcessConfig struct {
-	Name             string `yaml:"name"`
+	Name             string `yaml:"name"` // Executable name, as ps shows it
 	RestartOnFailure bool   `yaml:"restart_on_failure"`
+
+	// How the process is restarted: with systemctl restart, or by running
+	// a command. One is required with restart_on_failure.
+	SystemdUnit    string   `yaml:"systemd_unit"`
+	RestartCommand []string `yaml:"restart_command"`
+
+	MaxAttempts int           `yaml:"max_attempts"` // Give up after this many restarts (default: 3)
+	Cooloff     time.Duration `yaml:"cooloff"`      // Minimum time between restarts (default: 1m)
 }
 
 // HealthCheckConfig defines a health check
@@ -130,15 +247,59 @@ func Load(path string) (*Config, error) {
 	if cfg.Agent.RetryBackoff == 0 {
 		cfg.Agent.RetryBackoff = 2 * time.Second
 	}
+	if cfg.Agent.CloudProvider == "" {
+		cfg.Agent.CloudProvider = "auto"
+	}
+	if cfg.Agent.MetadataRefreshInterval == 0 {
+		cfg.Agent.MetadataRefreshInterval = time.Hour
+	}
+	if cfg.Agent.PayloadEncoding == "" {
+		cfg.Agent.PayloadEncoding = "json"
+	}
+	if cfg.Agent.DeltaPush.FullInterval == 0 {
+		cfg.Agent.DeltaPush.FullInterval = 10 * time.Minute
+	}
+	if cfg.Agent.Transport.MaxIdleConnsPerHost == 0 {
+		cfg.Agent.Transport.MaxIdleConnsPerHost = 2
+	}
+	if cfg.Agent.Transport.IdleConnTimeout == 0 {
+		cfg.Agent.Transport.IdleConnTimeout = 90 * time.Second
+	}
+	if cfg.Agent.Transport.TLSHandshakeTimeout == 0 {
+		cfg.Agent.Transport.TLSHandshakeTimeout = 10 * time.Second
+	}
+	if cfg.Agent.Transport.KeepAlive == 0 {
+		cfg.Agent.Transport.KeepAlive = 30 * time.Second
+	}
+	if cfg.Agent.Transport.DialTimeout == 0 {
+		cfg.Agent.Transport.DialTimeout = 5 * time.Second
+	}
+	if cfg.Agent.Transport.DNSTimeout == 0 {
+		cfg.Agent.Transport.DNSTimeout = 5 * time.Second
+	}
+	if cfg.Agent.Transport.IPFamily == "" {
+		cfg.Agent.Transport.IPFamily = "auto"
+	}
+	if cfg.Agent.Pull.Listen == "" {
+		cfg.Agent.Pull.Listen = ":9150"
+	}
 	if cfg.Agent.Name == "" {
 		hostname, _ := os.Hostname()
 		cfg.Agent.Name = hostname
 	}
+	for i := range cfg.Metrics.Processes {
+		if cfg.Metrics.Processes[i].MaxAttempts == 0 {
+			cfg.Metrics.Processes[i].MaxAttempts = 3
+		}
+		if cfg.Metrics.Processes[i].Cooloff == 0 {
+			cfg.Metrics.Processes[i].Cooloff = time.Minute
+		}
+	}
 
 	// Docker defaults
 	if cfg.Metrics.Docker.Enabled {
 		if cfg.Metrics.Docker.Socket == "" {
-			cfg.Metrics.Docker.Socket = "/var/run/docker.sock"
+			cfg.Metrics.Docker.Socket = DefaultDockerSocket
 		}
 
 		// Default to monitoring all containers if no filters are specified
@@ -161,6 +322,32 @@ func Load(path string) (*Config, error) {
 		if cfg.Metrics.Docker.Alerts.Default.RestartWindow == "" {
 			cfg.Metrics.Docker.Alerts.Default.RestartWindow = "300s"
 		}
+
+		if cfg.Metrics.Docker.CollectWorkers == 0 {
+			cfg.Metrics.Docker.CollectWorkers = 10
+		}
+		if cfg.Metrics.Docker.CollectTimeout == 0 {
+			cfg.Metrics.Docker.CollectTimeout = 10 * time.Second
+		}
+
+		if cfg.Metrics.Docker.ImageUpdateCheck.Interval == 0 {
+			cfg.Metrics.Docker.ImageUpdateCheck.Interval = 6 * time.Hour
+		}
+
+		for i := range cfg.Metrics.Docker.Remediation.Policies {
+			policy := &cfg.Metrics.Docker.Remediation.Policies[i]
+			if policy.MaxAttempts == 0 {
+				policy.MaxAttempts = 3
+			}
+			if policy.Cooloff == 0 {
+				policy.Cooloff = 5 * time.Minute
+			}
+			if policy.IgnoreExitCodes == nil {
+				// Clean exits, and docker stop: SIGTERM (143), then SIGKILL
+				// (137) after the stop timeout. OOM kills are restarted anyway.
+				policy.IgnoreExitCodes = []int{0, 137, 143}
+			}
+		}
 	}
 
 	return &cfg, nil
@@ -174,5 +361,85 @@ func (c *Config) Validate() error {
 	if c.Agent.CollectInterval < time.Second {
 		return fmt.Errorf("collect_interval must be at least 1 second")
 	}
+	switch c.Agent.CloudProvider {
+	case "auto", "ecs", "aws", "gcp", "azure", "none":
+	default:
+		return fmt.Errorf("cloud_provider must be one of auto, ecs, aws, gcp, azure, none; got %q", c.Agent.CloudProvider)
+	}
+	if c.Agent.IMDSEndpoint != "" {
+		if u, err := url.Parse(c.Agent.IMDSEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("imds_endpoint must be an http(s) URL; got %q", c.Agent.IMDSEndpoint)
+		}
+	}
+	if c.Agent.MetadataRefreshInterval < time.Minute {
+		return fmt.Errorf("metadata_refresh_interval must be at least 1 minute")
+	}
+	if c.Agent.DeltaPush.Enabled && c.Agent.DeltaPush.FullInterval < c.Agent.PushInterval {
+		return fmt.Errorf("delta_push.full_interval must be at least push_interval")
+	}
+	if c.Agent.PayloadEncoding != "json" && c.Agent.PayloadEncoding != "msgpack" {
+		return fmt.Errorf("payload_encoding must be json or msgpack; got %q", c.Agent.PayloadEncoding)
+	}
+	if t := c.Agent.Transport; t.MaxIdleConnsPerHost < 0 || t.IdleConnTimeout < 0 || t.TLSHandshakeTimeout < 0 || t.KeepAlive < 0 || t.DialTimeout < 0 || t.DNSTimeout < 0 {
+		return fmt.Errorf("transport settings must not be negative")
+	}
+	switch c.Agent.Transport.IPFamily {
+	case "auto", "ipv4", "ipv6", "prefer_ipv4", "prefer_ipv6":
+	default:
+		return fmt.Errorf("transport.ip_family must be auto, ipv4, ipv6, prefer_ipv4 or prefer_ipv6; got %q", c.Agent.Transport.IPFamily)
+	}
+	if c.Agent.PushTimeout < 0 {
+		return fmt.Errorf("push_timeout must not be negative")
+	}
+	if pull := c.Agent.Pull; pull.Enabled {
+		if _, _, err := net.SplitHostPort(pull.Listen); err != nil {
+			return fmt.Errorf("pull.listen must be [host]:port; got %q", pull.Listen)
+		}
+		if len(pull.Token) < 16 {
+			return fmt.Errorf("pull.token must be at least 16 characters")
+		}
+		if (pull.TLSCertFile == "") != (pull.TLSKeyFile == "") {
+			return fmt.Errorf("pull.tls_cert_file and pull.tls_key_file must be set together")
+		}
+	}
+
+	remediation := c.Metrics.Docker.Remediation
+	if remediation.Enabled {
+		if !c.Metrics.Docker.Enabled {
+			return fmt.Errorf("docker remediation requires docker monitoring to be enabled")
+		}
+		if len(remediation.Policies) == 0 {
+			return fmt.Errorf("docker remediation enabled but no policies configured")
+		}
+		for i, policy := range remediation.Policies {
+			if policy.Name == "" {
+				return fmt.Errorf("remediation policy %d: name is required", i)
+			}
+			if policy.MaxAttempts < 0 {
+				return fmt.Errorf("remediation policy %d: max_attempts must be >= 0", i)
+			}
+		}
+	}
+
+	for i, process := range c.Metrics.Processes {
+		if process.Name == "" {
+			return fmt.Errorf("process %d: name is required", i)
+		}
+		if !process.RestartOnFailure {
+			continue
+		}
+		if (process.SystemdUnit == "") == (len(process.RestartCommand) == 0) {
+			return fmt.Errorf("process %s: restart_on_failure needs one of systemd_unit or restart_command", process.Name)
+		}
+		if process.SystemdUnit != "" && runtime.GOOS == "windows" {
+			return fmt.Errorf("process %s: systemd_unit is not supported on Windows; use restart_command", process.Name)
+		}
+		if process.MaxAttempts < 1 {
+			return fmt.Errorf("process %s: max_attempts must be at least 1", process.Name)
+		}
+	}
+	if err := c.Logging.Validate(); err != nil {
+		return fmt.Errorf("logging: %w", err)
+	}
 	return nil
 }
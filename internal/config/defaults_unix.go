@@ -0,0 +1,11 @@
+//go:build !windows
+
+package config
+
+const (
+	// DefaultConfigPath is where the installed agent reads its config
+	DefaultConfigPath = "/etc/saviour/agent.yaml"
+
+	// DefaultDockerSocket is where the Docker daemon listens
+	DefaultDockerSocket = "/var/run/docker.sock"
+)
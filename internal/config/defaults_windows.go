@@ -0,0 +1,11 @@
+//go:build windows
+
+package config
+
+const (
+	// DefaultConfigPath is where the installed agent reads its config
+	DefaultConfigPath = `C:\ProgramData\Saviour\agent.yaml`
+
+	// DefaultDockerSocket is Docker Desktop's named pipe
+	DefaultDockerSocket = "npipe:////./pipe/docker_engine"
+)
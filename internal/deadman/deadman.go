@@ -0,0 +1,289 @@
+// Package deadman is a dead man's switch for cron jobs and scripts. Each
+// check has a ping URL the job requests when it succeeds; a check whose
+// ping is overdue raises an alert, which resolves with the next ping.
+package deadman
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/anurag/saviour/internal/alerting"
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// AgentName is the host alerts concern unless a check names its own
+const AgentName = "cron"
+
+// maxCheckInterval caps how often overdue pings are looked for
+const maxCheckInterval = 30 * time.Second
+
+// Check states
+const (
+	StatusNew  = "new"  // No ping since the server started, not yet overdue
+	StatusUp   = "up"   // Pinged within the period
+	StatusLate = "late" // Past the period, within the grace time
+	StatusDown = "down" // Past the grace time, alerted
+)
+
+// Check expects a ping every period
+type Check struct {
+	Name     string
+	Token    string        // Secret part of the ping URL
+	Period   time.Duration // How often the job runs
+	Grace    time.Duration // How late a ping may be before alerting
+	Host     string        // The host the job runs on (default: AgentName)
+	Severity string        // Of the alert raised when the ping is overdue
+}
+
+// Status is the state of a check
+type Status struct {
+	Name     string     `json:"name"`
+	Token    string     `json:"token"`
+	Period   string     `json:"period"`
+	Grace    string     `json:"grace"`
+	Status   string     `json:"status"` // StatusNew, StatusUp, StatusLate or StatusDown
+	LastPing *time.Time `json:"last_ping,omitempty"`
+	Pings    int        `json:"pings"` // Since the server started
+	Due      time.Time  `json:"due"`   // When the check goes down without a ping
+}
+
+// watchedCheck is a check and its pings
+type watchedCheck struct {
+	Check
+	since    time.Time // Last ping, or when watching started
+	lastPing time.Time
+	pings    int
+	alertID  string // Active alert of the overdue check, if any
+}
+
+// due is when the check goes down without another ping
+func (c *watchedCheck) due() time.Time {
+	return c.since.Add(c.Period + c.Grace)
+}
+
+// Monitor watches checks for overdue pings
+type Monitor struct {
+	logger  *slog.Logger
+	store   *server.StateStore
+	now     func() time.Time
+	mu      sync.Mutex
+	checks  []*watchedCheck
+	byToken map[string]*watchedCheck
+}
+
+// NewMonitor creates a monitor for checks. Every check starts as if pinged
+// now, so jobs get a full period to report in after the server starts.
+func NewMonitor(checks []Check, store *server.StateStore) *Monitor {
+	m := &Monitor{
+		logger:  logging.Component("deadman"),
+		store:   store,
+		now:     time.Now,
+		byToken: make(map[string]*watchedCheck, len(checks)),
+	}
+	now := m.now()
+	for _, check := range checks {
+		if check.Host == "" {
+			check.Host = AgentName
+		}
+		watched := &watchedCheck{Check: check, since: now}
+		m.checks = append(m.checks, watched)
+		m.byToken[check.Token] = watched
+	}
+	return m
+}
+
+// Ping records a ping for the check with token, resolving its alert if it
+// was down. It returns the check's name, or false for an unknown token.
+func (m *Monitor) Ping(token string) (string, bool) {
+	m.mu.Lock()
+	check, ok := m.byToken[token]
+	if !ok {
+		m.mu.Unlock()
+		return "", false
+	}
+	now := m.now()
+	check.since, check.lastPing = now, now
+	check.pings++
+	alertID := check.alertID
+	check.alertID = ""
+	m.mu.Unlock()
+
+	m.logger.Debug("Ping", "check", check.Name)
+	if alertID != "" {
+		m.logger.Info("Ping check recovered", "check", check.Name)
+		m.store.ResolveAlert(alertID)
+	}
+	return check.Name, true
+}
+
+// Status returns the state of every check, in configuration order
+func (m *Monitor) Status() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	statuses := make([]Status, 0, len(m.checks))
+	for _, check := range m.checks {
+		status := Status{
+			Name:   check.Name,
+			Token:  check.Token,
+			Period: check.Period.String(),
+			Grace:  check.Grace.String(),
+			Pings:  check.pings,
+			Due:    check.due(),
+		}
+		switch {
+		case !now.Before(check.due()):
+			status.Status = StatusDown
+		case now.Sub(check.since) > check.Period:
+			status.Status = StatusLate
+		case check.pings == 0:
+			status.Status = StatusNew
+		default:
+			status.Status = StatusUp
+		}
+		if check.pings > 0 {
+			lastPing := check.lastPing
+			status.LastPing = &lastPing
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Run looks for overdue pings until ctx is done, alerting through raise
+// (nil to only report them in Status)
+func (m *Monitor) Run(ctx context.Context, raise func(alerting.ExternalAlert) *alerting.Alert) {
+	if len(m.checks) == 0 {
+		return
+	}
+
+	interval := maxCheckInterval
+	for _, check := range m.checks {
+		if check.Grace > 0 {
+			interval = min(interval, check.Grace)
+		}
+	}
+	m.logger.Info("Watching ping checks", "checks", len(m.checks))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check(raise)
+		}
+	}
+}
+
+// check raises an alert for each check whose ping is overdue
+func (m *Monitor) check(raise func(alerting.ExternalAlert) *alerting.Alert) {
+	now := m.now()
+	m.mu.Lock()
+	overdue := make(map[*watchedCheck]alerting.ExternalAlert)
+	for _, check := range m.checks {
+		if now.Before(check.due()) {
+			continue
+		}
+		if alert, ok := m.store.GetAlert(check.alertID); ok && alert.Status != "resolved" {
+			continue // Still open from earlier
+		}
+		m.logger.Warn("Ping overdue", "check", check.Name, "due", check.due())
+
+		lastPing := "never since the server started"
+		if check.pings > 0 {
+			lastPing = alerting.Ago(check.lastPing, now)
+		}
+		overdue[check] = alerting.ExternalAlert{
+			Source:    "deadman",
+			AgentName: check.Host,
+			AlertType: "ping_missed",
+			Key:       check.Name,
+			Severity:  check.Severity,
+			Message:   fmt.Sprintf("No ping from %s for %s (expected every %s)\nLast ping: %s", check.Name, alerting.HumanizeDuration(now.Sub(check.since)), alerting.HumanizeDuration(check.Period), lastPing),
+			Details: map[string]interface{}{
+				"check":  check.Name,
+				"period": check.Period.String(),
+				"grace":  check.Grace.String(),
+				"due":    check.due(),
+			},
+		}
+	}
+	m.mu.Unlock()
+	if raise == nil {
+		return
+	}
+
+	for check, ext := range overdue {
+		alert := raise(ext)
+		if alert == nil {
+			continue
+		}
+		m.mu.Lock()
+		pinged := m.now().Before(check.due())
+		if !pinged {
+			check.alertID = alert.ID
+		}
+		m.mu.Unlock()
+		if pinged {
+			m.store.ResolveAlert(alert.ID) // The ping came in meanwhile
+		}
+	}
+}
+
+// IssueTokens fills in the token of each check that doesn't configure one,
+// from path, a JSON object of check name to token. Checks new to the file
+// are issued a random token, which is saved to it so ping URLs survive
+// restarts.
+func IssueTokens(path string, checks []Check) error {
+	tokens := map[string]string{}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &tokens); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	issued := false
+	for i := range checks {
+		check := &checks[i]
+		if check.Token != "" {
+			continue
+		}
+		if token, ok := tokens[check.Name]; ok {
+			check.Token = token
+			continue
+		}
+		token := make([]byte, 16)
+		if _, err := rand.Read(token); err != nil {
+			return err
+		}
+		check.Token = hex.EncodeToString(token)
+		tokens[check.Name] = check.Token
+		issued = true
+	}
+	if !issued {
+		return nil
+	}
+
+	data, err = json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
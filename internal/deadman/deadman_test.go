@@ -0,0 +1,100 @@
+package deadman
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/alerting"
+	"github.com/anurag/saviour/internal/server"
+)
+
+func TestMonitor_AlertsOverduePings(t *testing.T) {
+	store := server.NewStateStore()
+	var raised []alerting.ExternalAlert
+	raise := func(ext alerting.ExternalAlert) *alerting.Alert {
+		raised = append(raised, ext)
+		store.AddAlert(&server.Alert{ID: "alert-" + ext.Key, AgentName: ext.AgentName, AlertType: ext.AlertType, Status: "active", TriggeredAt: time.Now()})
+		return &alerting.Alert{ID: "alert-" + ext.Key}
+	}
+
+	start := time.Now()
+	now := start
+	m := NewMonitor([]Check{
+		{Name: "backup", Token: "backup-token", Period: time.Hour, Grace: 10 * time.Minute, Severity: "critical"},
+		{Name: "cleanup", Token: "cleanup-token", Period: 24 * time.Hour, Host: "web-1", Severity: "warning"},
+	}, store)
+	m.now = func() time.Time { return now }
+
+	if status := m.Status(); status[0].Status != StatusNew || status[0].LastPing != nil {
+		t.Errorf("Expected a new check before the first ping, got %+v", status[0])
+	}
+	if name, ok := m.Ping("backup-token"); !ok || name != "backup" {
+		t.Fatalf("Expected a ping for backup, got %q, %v", name, ok)
+	}
+	if _, ok := m.Ping("unknown"); ok {
+		t.Error("Expected an unknown token to be rejected")
+	}
+
+	// Late, but within the grace time
+	now = start.Add(65 * time.Minute)
+	m.check(raise)
+	if len(raised) != 0 || m.Status()[0].Status != StatusLate {
+		t.Errorf("Expected a late check and no alert, got %+v and %d alerts", m.Status()[0], len(raised))
+	}
+
+	// Overdue; the alert stays open until the next ping
+	now = start.Add(75 * time.Minute)
+	m.check(raise)
+	m.check(raise)
+	if len(raised) != 1 {
+		t.Fatalf("Expected 1 raised alert, got %d", len(raised))
+	}
+	if raised[0].AlertType != "ping_missed" || raised[0].AgentName != AgentName || raised[0].Key != "backup" || !strings.Contains(raised[0].Message, "backup") {
+		t.Errorf("Unexpected alert: %+v", raised[0])
+	}
+	if status := m.Status()[0]; status.Status != StatusDown || status.Pings != 1 {
+		t.Errorf("Expected a down check with 1 ping, got %+v", status)
+	}
+
+	m.Ping("backup-token")
+	if alert, _ := store.GetAlert("alert-backup"); alert.Status != "resolved" {
+		t.Errorf("Expected the alert resolved by the ping, got %s", alert.Status)
+	}
+	if status := m.Status()[0]; status.Status != StatusUp || !status.LastPing.Equal(now) {
+		t.Errorf("Expected an up check pinged now, got %+v", status)
+	}
+}
+
+func TestIssueTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ping-tokens.json")
+	checks := []Check{{Name: "backup"}, {Name: "cleanup", Token: "configured-token-1"}}
+
+	if err := IssueTokens(path, checks); err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+	if len(checks[0].Token) != 32 || checks[1].Token != "configured-token-1" {
+		t.Errorf("Expected an issued and a configured token, got %q and %q", checks[0].Token, checks[1].Token)
+	}
+
+	var saved map[string]string
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("Failed to read tokens file: %v", err)
+	}
+	if len(saved) != 1 || saved["backup"] != checks[0].Token {
+		t.Errorf("Expected only the issued token saved, got %v", saved)
+	}
+
+	// The same token after a restart
+	again := []Check{{Name: "backup"}}
+	if err := IssueTokens(path, again); err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+	if again[0].Token != checks[0].Token {
+		t.Errorf("Expected token %s again, got %s", checks[0].Token, again[0].Token)
+	}
+}
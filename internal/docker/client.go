@@ -7,6 +7,7 @@ import (
 	"io"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -17,8 +18,43 @@ import (
 
 // Client wraps the Docker client with our custom methods
 type Client struct {
-	cli    *client.Client
-	filter FilterConfig
+	cli     *client.Client
+	filter  FilterConfig
+	updates *ImageUpdateChecker // nil unless image update checks are enabled
+	streams *StatsStreamer      // nil unless streaming stats are enabled
+
+	oom     *OOMTracker // nil until TrackOOMEvents is called
+	stopOOM context.CancelFunc
+
+	workers          int           // Max containers inspected concurrently
+	containerTimeout time.Duration // Max time spent inspecting one container
+}
+
+const (
+	// DefaultCollectWorkers is the default number of concurrent container inspections
+	DefaultCollectWorkers = 10
+	// DefaultContainerTimeout bounds inspect + stats for a single container
+	DefaultContainerTimeout = 10 * time.Second
+)
+
+// HostURL turns the configured socket into a Docker host URL. A bare path is
+// a unix socket; URLs such as npipe:////./pipe/docker_engine, Docker
+// Desktop's named pipe on Windows, are used as given.
+func HostURL(socket string) string {
+	if strings.Contains(socket, "://") {
+		return socket
+	}
+	return "unix://" + socket
+}
+
+// SocketPath returns the file the configured socket lives at, to check that
+// it exists. Named pipes are returned in Windows form, e.g.
+// \\.\pipe\docker_engine.
+func SocketPath(socket string) string {
+	if pipe, ok := strings.CutPrefix(socket, "npipe://"); ok {
+		return strings.ReplaceAll(pipe, "/", `\`)
+	}
+	return strings.TrimPrefix(socket, "unix://")
 }
 
 // NewClient creates a new Docker client
@@ -30,7 +66,7 @@ func NewClient(socketPath string, filterConfig FilterConfig) (*Client, error) {
 
 	// Override socket path if provided
 	if socketPath != "" {
-		opts = append(opts, client.WithHost("unix://"+socketPath))
+		opts = append(opts, client.WithHost(HostURL(socketPath)))
 	}
 
 	cli, err := client.NewClientWithOpts(opts...)
@@ -39,13 +75,54 @@ func NewClient(socketPath string, filterConfig FilterConfig) (*Client, error) {
 	}
 
 	return &Client{
-		cli:    cli,
-		filter: filterConfig,
+		cli:              cli,
+		filter:           filterConfig,
+		workers:          DefaultCollectWorkers,
+		containerTimeout: DefaultContainerTimeout,
 	}, nil
 }
 
+// SetCollectionLimits configures how many containers are inspected in
+// parallel and how long a single container may take. Zero values keep the
+// defaults.
+func (c *Client) SetCollectionLimits(workers int, containerTimeout time.Duration) {
+	if workers > 0 {
+		c.workers = workers
+	}
+	if containerTimeout > 0 {
+		c.containerTimeout = containerTimeout
+	}
+}
+
+// EnableImageUpdateCheck turns on registry digest comparison for running
+// containers, re-checking each image at most once per interval
+func (c *Client) EnableImageUpdateCheck(interval time.Duration) {
+	c.updates = NewImageUpdateChecker(c.cli, interval)
+}
+
+// EnableStatsStreaming keeps a stats stream open per running container so
+// collection reads the latest sample instead of blocking on a one-shot request
+func (c *Client) EnableStatsStreaming() {
+	c.streams = NewStatsStreamer(c.cli)
+}
+
+// TrackOOMEvents subscribes to the daemon's OOM events so container info
+// reports how many times each container was OOM-killed in the last hour
+func (c *Client) TrackOOMEvents() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.oom = NewOOMTracker(c.cli)
+	c.stopOOM = cancel
+	go c.oom.Run(ctx)
+}
+
 // Close closes the Docker client connection
 func (c *Client) Close() error {
+	if c.streams != nil {
+		c.streams.Close()
+	}
+	if c.stopOOM != nil {
+		c.stopOOM()
+	}
 	return c.cli.Close()
 }
 
@@ -55,6 +132,27 @@ func (c *Client) Ping(ctx context.Context) error {
 	return err
 }
 
+// GetDaemonInfo retrieves version, storage and object counts from the daemon
+func (c *Client) GetDaemonInfo(ctx context.Context) (*DaemonInfo, error) {
+	info, err := c.cli.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daemon info: %w", err)
+	}
+
+	return &DaemonInfo{
+		Version:           info.ServerVersion,
+		APIVersion:        c.cli.ClientVersion(),
+		StorageDriver:     info.Driver,
+		Containers:        info.Containers,
+		ContainersRunning: info.ContainersRunning,
+		ContainersPaused:  info.ContainersPaused,
+		ContainersStopped: info.ContainersStopped,
+		Images:            info.Images,
+		Goroutines:        info.NGoroutines,
+		DataRoot:          info.DockerRootDir,
+	}, nil
+}
+
 // ListContainers returns all containers matching the filter criteria
 func (c *Client) ListContainers(ctx context.Context) ([]types.Container, error) {
 	opts := container.ListOptions{
@@ -83,9 +181,55 @@ func (c *Client) ListContainers(ctx context.Context) ([]types.Container, error)
 		containers = c.filterByPatterns(containers)
 	}
 
+	// Exclusions always apply, even when monitoring all containers
+	containers = c.filterExclusions(containers)
+
 	return containers, nil
 }
 
+// filterExclusions drops containers matching any exclude label, name or image
+func (c *Client) filterExclusions(containers []types.Container) []types.Container {
+	if len(c.filter.ExcludeLabels) == 0 && len(c.filter.ExcludeNames) == 0 && len(c.filter.ExcludeImages) == 0 {
+		return containers
+	}
+
+	filtered := []types.Container{}
+	for _, container := range containers {
+		if !c.isExcluded(container) {
+			filtered = append(filtered, container)
+		}
+	}
+
+	return filtered
+}
+
+// isExcluded checks a container against the exclude filters
+func (c *Client) isExcluded(container types.Container) bool {
+	for _, label := range c.filter.ExcludeLabels {
+		key, value, hasValue := strings.Cut(label, "=")
+		if actual, exists := container.Labels[key]; exists && (!hasValue || actual == value) {
+			return true
+		}
+	}
+
+	for _, name := range container.Names {
+		name = strings.TrimPrefix(name, "/")
+		for _, pattern := range c.filter.ExcludeNames {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+
+	for _, pattern := range c.filter.ExcludeImages {
+		if matched, _ := filepath.Match(pattern, container.Image); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
 // filterByPatterns applies name and image pattern matching
 func (c *Client) filterByPatterns(containers []types.Container) []types.Container {
 	if len(c.filter.Names) == 0 && len(c.filter.Images) == 0 {
@@ -131,6 +275,14 @@ func (c *Client) filterByPatterns(containers []types.Container) []types.Containe
 	return filtered
 }
 
+// StartContainer starts a stopped container
+func (c *Client) StartContainer(ctx context.Context, containerID string) error {
+	if err := c.cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start container %s: %w", containerID, err)
+	}
+	return nil
+}
+
 // InspectContainer gets detailed information about a container
 func (c *Client) InspectContainer(ctx context.Context, containerID string) (types.ContainerJSON, error) {
 	inspect, err := c.cli.ContainerInspect(ctx, containerID)
@@ -159,6 +311,17 @@ func (c *Client) GetContainerStats(ctx context.Context, containerID string) (*co
 	return &v, nil
 }
 
+// containerStats returns the latest streamed sample when streaming is
+// enabled, falling back to a one-shot request until the stream has data
+func (c *Client) containerStats(ctx context.Context, containerID string) (*container.StatsResponse, error) {
+	if c.streams != nil {
+		if stats, ok := c.streams.Latest(containerID); ok {
+			return stats, nil
+		}
+	}
+	return c.GetContainerStats(ctx, containerID)
+}
+
 // GetContainerInfo gets comprehensive information about a container
 func (c *Client) GetContainerInfo(ctx context.Context, containerID string) (*ContainerInfo, error) {
 	// Inspect container for details
@@ -178,6 +341,7 @@ func (c *Client) GetContainerInfo(ctx context.Context, containerID string) (*Con
 		Status:       inspect.State.Status,
 		ExitCode:     inspect.State.ExitCode,
 		OOMKilled:    inspect.State.OOMKilled,
+		StateError:   inspect.State.Error,
 		RestartCount: inspect.RestartCount,
 	}
 
@@ -194,45 +358,65 @@ func (c *Client) GetContainerInfo(ctx context.Context, containerID string) (*Con
 		info.FinishedAt = finishedAt
 	}
 
+	if c.oom != nil {
+		info.OOMKillsLastHour = c.oom.Count(inspect.ID)
+	}
+
 	// Health status
 	if inspect.State.Health != nil {
 		info.Health = inspect.State.Health.Status
+
+		// Capture the last probe result so alerts can say why it failed
+		if info.Health == "unhealthy" && len(inspect.State.Health.Log) > 0 {
+			last := inspect.State.Health.Log[len(inspect.State.Health.Log)-1]
+			info.HealthCheckExitCode = last.ExitCode
+			info.HealthCheckOutput = strings.TrimSpace(last.Output)
+		}
 	} else {
 		info.Health = "none"
 	}
 
 	// Get stats only if container is running
 	if inspect.State.Running {
-		stats, err := c.GetContainerStats(ctx, containerID)
+		stats, err := c.containerStats(ctx, containerID)
 		if err == nil {
-			info.CPUPercent = calculateCPUPercent(stats)
-			info.MemoryUsage = stats.MemoryStats.Usage
-			info.MemoryLimit = stats.MemoryStats.Limit
-			if stats.MemoryStats.Limit > 0 {
-				info.MemoryPercent = float64(stats.MemoryStats.Usage) / float64(stats.MemoryStats.Limit) * 100.0
-			}
+			applyStats(info, stats)
+		}
 
-			// Network I/O
-			for _, network := range stats.Networks {
-				info.NetworkRxBytes += network.RxBytes
-				info.NetworkTxBytes += network.TxBytes
-			}
+		if c.updates != nil {
+			info.UpdateAvailable = c.updates.UpdateAvailable(ctx, inspect.Config.Image, inspect.Image)
+		}
+	}
 
-			// Block I/O
-			for _, blkio := range stats.BlkioStats.IoServiceBytesRecursive {
-				if blkio.Op == "read" || blkio.Op == "Read" {
-					info.BlockReadBytes += blkio.Value
-				} else if blkio.Op == "write" || blkio.Op == "Write" {
-					info.BlockWriteBytes += blkio.Value
-				}
-			}
+	return info, nil
+}
+
+// applyStats fills in the resource usage fields of info from a stats sample
+func applyStats(info *ContainerInfo, stats *container.StatsResponse) {
+	info.CPUPercent = calculateCPUPercent(stats)
+	info.MemoryUsage = stats.MemoryStats.Usage
+	info.MemoryLimit = stats.MemoryStats.Limit
+	if stats.MemoryStats.Limit > 0 {
+		info.MemoryPercent = float64(stats.MemoryStats.Usage) / float64(stats.MemoryStats.Limit) * 100.0
+	}
 
-			// PIDs
-			info.PIDs = stats.PidsStats.Current
+	// Network I/O
+	for _, network := range stats.Networks {
+		info.NetworkRxBytes += network.RxBytes
+		info.NetworkTxBytes += network.TxBytes
+	}
+
+	// Block I/O
+	for _, blkio := range stats.BlkioStats.IoServiceBytesRecursive {
+		if blkio.Op == "read" || blkio.Op == "Read" {
+			info.BlockReadBytes += blkio.Value
+		} else if blkio.Op == "write" || blkio.Op == "Write" {
+			info.BlockWriteBytes += blkio.Value
 		}
 	}
 
-	return info, nil
+	// PIDs
+	info.PIDs = stats.PidsStats.Current
 }
 
 // GetAllContainerInfo retrieves info for all monitored containers
@@ -242,15 +426,54 @@ func (c *Client) GetAllContainerInfo(ctx context.Context) ([]ContainerInfo, erro
 		return nil, err
 	}
 
+	// Close streams for containers that stopped or were removed
+	if c.streams != nil {
+		running := make(map[string]bool)
+		for _, container := range containers {
+			if container.State == "running" {
+				running[container.ID] = true
+			}
+		}
+		c.streams.Prune(running)
+	}
+
+	// Inspect containers concurrently with a bounded worker pool; results
+	// are written by index so the output order matches the list order
+	results := make([]*ContainerInfo, len(containers))
+	errs := make([]error, len(containers))
+	collectedAt := time.Now()
+
+	sem := make(chan struct{}, c.workers)
+	var wg sync.WaitGroup
+
+	for i, container := range containers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, containerID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			containerCtx, cancel := context.WithTimeout(ctx, c.containerTimeout)
+			defer cancel()
+
+			results[i], errs[i] = c.GetContainerInfo(containerCtx, containerID)
+		}(i, container.ID)
+	}
+	wg.Wait()
+
+	// Forget update checks for images no running container uses anymore
+	if c.updates != nil {
+		c.updates.Prune(collectedAt)
+	}
+
 	infos := make([]ContainerInfo, 0, len(containers))
 	var firstErr error
 
-	for _, container := range containers {
-		info, err := c.GetContainerInfo(ctx, container.ID)
-		if err != nil {
-			// Capture first error but continue with other containers
+	for i, info := range results {
+		if errs[i] != nil {
+			// Capture first error but keep the other containers
 			if firstErr == nil {
-				firstErr = err
+				firstErr = errs[i]
 			}
 			continue
 		}
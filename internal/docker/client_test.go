@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestFilterExclusions(t *testing.T) {
+	c := &Client{
+		filter: FilterConfig{
+			MonitorAll:    true,
+			ExcludeLabels: []string{"ci", "saviour.ignore=true"},
+			ExcludeNames:  []string{"buildkit*", "tmp-*"},
+			ExcludeImages: []string{"moby/buildkit:*"},
+		},
+	}
+
+	containers := []types.Container{
+		{ID: "1", Names: []string{"/api"}, Image: "mycompany/api:1.0"},
+		{ID: "2", Names: []string{"/buildkit_worker"}, Image: "alpine"},
+		{ID: "3", Names: []string{"/tmp-123"}, Image: "alpine"},
+		{ID: "4", Names: []string{"/builder"}, Image: "moby/buildkit:v0.12"},
+		{ID: "5", Names: []string{"/runner"}, Image: "alpine", Labels: map[string]string{"ci": "github"}},
+		{ID: "6", Names: []string{"/web"}, Image: "nginx", Labels: map[string]string{"saviour.ignore": "true"}},
+		{ID: "7", Names: []string{"/worker"}, Image: "nginx", Labels: map[string]string{"saviour.ignore": "false"}},
+	}
+
+	filtered := c.filterExclusions(containers)
+
+	if len(filtered) != 2 {
+		t.Fatalf("Expected 2 containers after exclusions, got %d", len(filtered))
+	}
+	if filtered[0].ID != "1" || filtered[1].ID != "7" {
+		t.Errorf("Expected containers 1 and 7 to remain, got %s and %s", filtered[0].ID, filtered[1].ID)
+	}
+}
+
+func TestFilterExclusions_NoExclusions(t *testing.T) {
+	c := &Client{filter: FilterConfig{MonitorAll: true}}
+
+	containers := []types.Container{
+		{ID: "1", Names: []string{"/api"}},
+		{ID: "2", Names: []string{"/buildkit"}},
+	}
+
+	if filtered := c.filterExclusions(containers); len(filtered) != 2 {
+		t.Errorf("Expected all containers without exclusions, got %d", len(filtered))
+	}
+}
+
+func TestHostURL(t *testing.T) {
+	tests := []struct {
+		socket string
+		url    string
+		path   string
+	}{
+		{"/var/run/docker.sock", "unix:///var/run/docker.sock", "/var/run/docker.sock"},
+		{"unix:///run/user/1000/docker.sock", "unix:///run/user/1000/docker.sock", "/run/user/1000/docker.sock"},
+		{"npipe:////./pipe/docker_engine", "npipe:////./pipe/docker_engine", `\\.\pipe\docker_engine`},
+	}
+
+	for _, tt := range tests {
+		if got := HostURL(tt.socket); got != tt.url {
+			t.Errorf("%s: expected URL %s, got %s", tt.socket, tt.url, got)
+		}
+		if got := SocketPath(tt.socket); got != tt.path {
+			t.Errorf("%s: expected path %s, got %s", tt.socket, tt.path, got)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+const (
+	// ECSMetadataEnv is set by the ECS agent (and Fargate) to the task
+	// metadata endpoint v4 of the current container
+	ECSMetadataEnv = "ECS_CONTAINER_METADATA_URI_V4"
+
+	// Timeout for task metadata requests
+	ecsMetadataTimeout = 5 * time.Second
+)
+
+// ECSTask is the subset of the task metadata v4 document we use
+type ECSTask struct {
+	Cluster          string         `json:"Cluster"`
+	TaskARN          string         `json:"TaskARN"`
+	Family           string         `json:"Family"`
+	Revision         string         `json:"Revision"`
+	KnownStatus      string         `json:"KnownStatus"`
+	LaunchType       string         `json:"LaunchType"`
+	AvailabilityZone string         `json:"AvailabilityZone"`
+	Containers       []ECSContainer `json:"Containers"`
+}
+
+// ECSContainer describes one container of an ECS task
+type ECSContainer struct {
+	DockerID    string            `json:"DockerId"`
+	Name        string            `json:"Name"`
+	Image       string            `json:"Image"`
+	ImageID     string            `json:"ImageID"`
+	Labels      map[string]string `json:"Labels"`
+	KnownStatus string            `json:"KnownStatus"` // PENDING, RUNNING, STOPPED
+	ExitCode    *int              `json:"ExitCode"`
+	CreatedAt   time.Time         `json:"CreatedAt"`
+	StartedAt   time.Time         `json:"StartedAt"`
+	FinishedAt  time.Time         `json:"FinishedAt"`
+	Health      *struct {
+		Status string `json:"status"` // HEALTHY, UNHEALTHY, UNKNOWN
+	} `json:"Health"`
+}
+
+// ECSClient reads container state and stats from the ECS task metadata
+// endpoint, for tasks where the Docker socket is not available (Fargate)
+type ECSClient struct {
+	client      *http.Client
+	metadataURI string
+}
+
+// NewECSClient creates a client for the given task metadata endpoint
+func NewECSClient(metadataURI string) *ECSClient {
+	return &ECSClient{
+		client: &http.Client{
+			Timeout: ecsMetadataTimeout,
+		},
+		metadataURI: strings.TrimSuffix(metadataURI, "/"),
+	}
+}
+
+// ECSMetadataURI returns the task metadata endpoint from the environment,
+// or "" when not running in an ECS task
+func ECSMetadataURI() string {
+	return os.Getenv(ECSMetadataEnv)
+}
+
+// GetTask fetches the task metadata document
+func (c *ECSClient) GetTask(ctx context.Context) (*ECSTask, error) {
+	var task ECSTask
+	if err := c.get(ctx, "/task", &task); err != nil {
+		return nil, fmt.Errorf("failed to fetch task metadata: %w", err)
+	}
+	return &task, nil
+}
+
+// GetTaskStats fetches stats for every container in the task, keyed by
+// Docker ID. Containers that are not running have no entry.
+func (c *ECSClient) GetTaskStats(ctx context.Context) (map[string]*container.StatsResponse, error) {
+	var stats map[string]*container.StatsResponse
+	if err := c.get(ctx, "/task/stats", &stats); err != nil {
+		return nil, fmt.Errorf("failed to fetch task stats: %w", err)
+	}
+	return stats, nil
+}
+
+// GetAllContainerInfo retrieves info for all containers in the task
+func (c *ECSClient) GetAllContainerInfo(ctx context.Context) ([]ContainerInfo, error) {
+	task, err := c.GetTask(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stats are best effort; state is still worth reporting without them
+	stats, _ := c.GetTaskStats(ctx)
+
+	infos := make([]ContainerInfo, 0, len(task.Containers))
+	for _, ecsContainer := range task.Containers {
+		info := ecsContainerInfo(ecsContainer)
+		if s := stats[ecsContainer.DockerID]; s != nil && info.State == "running" {
+			applyStats(&info, s)
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// ecsContainerInfo maps task metadata to the same form the Docker client
+// reports, so alerting treats both alike
+func ecsContainerInfo(c ECSContainer) ContainerInfo {
+	info := ContainerInfo{
+		ID:         shortID(c.DockerID),
+		Name:       c.Name,
+		Image:      c.Image,
+		ImageID:    shortID(strings.TrimPrefix(c.ImageID, "sha256:")),
+		Labels:     c.Labels,
+		Created:    c.CreatedAt,
+		StartedAt:  c.StartedAt,
+		FinishedAt: c.FinishedAt,
+		Health:     "none",
+	}
+
+	switch c.KnownStatus {
+	case "RUNNING":
+		info.State = "running"
+	case "STOPPED":
+		info.State = "exited"
+	default:
+		info.State = "created"
+	}
+	info.Status = info.State
+
+	if c.ExitCode != nil {
+		info.ExitCode = *c.ExitCode
+	}
+
+	if c.Health != nil {
+		switch c.Health.Status {
+		case "HEALTHY":
+			info.Health = "healthy"
+		case "UNHEALTHY":
+			info.Health = "unhealthy"
+		}
+	}
+
+	return info
+}
+
+// shortID truncates a container or image ID to the 12 characters Docker displays
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// get fetches a path below the metadata endpoint and decodes it into v
+func (c *ECSClient) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.metadataURI+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("metadata request failed with status: %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
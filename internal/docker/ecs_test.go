@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestECSClient_GetAllContainerInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/task":
+			w.Write([]byte(`{
+				"Cluster": "prod",
+				"TaskARN": "arn:aws:ecs:us-east-1:111122223333:task/prod/abc",
+				"Containers": [
+					{
+						"DockerId": "0123456789abcdef0123",
+						"Name": "api",
+						"Image": "example/api:1.2",
+						"ImageID": "sha256:fedcba9876543210fedc",
+						"KnownStatus": "RUNNING",
+						"Health": {"status": "UNHEALTHY"}
+					},
+					{
+						"DockerId": "aaaaaaaaaaaaaaaaaaaa",
+						"Name": "migrate",
+						"Image": "example/migrate:1.2",
+						"KnownStatus": "STOPPED",
+						"ExitCode": 1
+					}
+				]
+			}`))
+		case "/task/stats":
+			w.Write([]byte(`{
+				"0123456789abcdef0123": {
+					"memory_stats": {"usage": 256, "limit": 1024},
+					"networks": {"eth1": {"rx_bytes": 10, "tx_bytes": 20}},
+					"pids_stats": {"current": 4}
+				},
+				"aaaaaaaaaaaaaaaaaaaa": null
+			}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	containers, err := NewECSClient(server.URL + "/").GetAllContainerInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllContainerInfo failed: %v", err)
+	}
+	if len(containers) != 2 {
+		t.Fatalf("Expected 2 containers, got %d", len(containers))
+	}
+
+	api := containers[0]
+	if api.ID != "0123456789ab" || api.ImageID != "fedcba987654" {
+		t.Errorf("Expected short IDs, got '%s' and '%s'", api.ID, api.ImageID)
+	}
+	if api.State != "running" || api.Health != "unhealthy" {
+		t.Errorf("Expected running and unhealthy, got '%s' and '%s'", api.State, api.Health)
+	}
+	if api.MemoryPercent != 25 {
+		t.Errorf("Expected memory percent 25, got %.2f", api.MemoryPercent)
+	}
+	if api.NetworkRxBytes != 10 || api.NetworkTxBytes != 20 || api.PIDs != 4 {
+		t.Errorf("Expected network and PID stats to be applied, got rx=%d tx=%d pids=%d",
+			api.NetworkRxBytes, api.NetworkTxBytes, api.PIDs)
+	}
+
+	migrate := containers[1]
+	if migrate.State != "exited" || migrate.ExitCode != 1 {
+		t.Errorf("Expected exited with code 1, got '%s' with %d", migrate.State, migrate.ExitCode)
+	}
+	if migrate.Health != "none" {
+		t.Errorf("Expected health 'none', got '%s'", migrate.Health)
+	}
+}
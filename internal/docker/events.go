@@ -0,0 +1,92 @@
+package docker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// oomWindow is how long OOM events are remembered
+const oomWindow = time.Hour
+
+// OOMTracker counts OOM kill events per container from the Docker events
+// stream. Unlike the OOMKilled flag on inspect, this catches kills that a
+// restart policy has already recovered from.
+type OOMTracker struct {
+	cli *client.Client
+
+	mu     sync.Mutex
+	events map[string][]time.Time // key: full container ID
+}
+
+// NewOOMTracker creates a new OOM tracker
+func NewOOMTracker(cli *client.Client) *OOMTracker {
+	return &OOMTracker{
+		cli:    cli,
+		events: make(map[string][]time.Time),
+	}
+}
+
+// Run subscribes to OOM events until ctx is cancelled, resubscribing with
+// a short delay if the daemon connection drops
+func (t *OOMTracker) Run(ctx context.Context) {
+	args := filters.NewArgs(
+		filters.Arg("type", string(events.ContainerEventType)),
+		filters.Arg("event", string(events.ActionOOM)),
+	)
+
+	for {
+		msgs, errs := t.cli.Events(ctx, events.ListOptions{Filters: args})
+
+	receive:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-msgs:
+				t.Record(msg.Actor.ID, time.Unix(0, msg.TimeNano))
+			case <-errs:
+				break receive
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// Record registers an OOM kill for a container
+func (t *OOMTracker) Record(containerID string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events[containerID] = append(t.events[containerID], at)
+}
+
+// Count returns the number of OOM kills for a container in the last hour,
+// dropping older events
+func (t *OOMTracker) Count(containerID string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := time.Now().Add(-oomWindow)
+	recent := t.events[containerID][:0]
+	for _, at := range t.events[containerID] {
+		if at.After(cutoff) {
+			recent = append(recent, at)
+		}
+	}
+
+	if len(recent) == 0 {
+		delete(t.events, containerID)
+		return 0
+	}
+	t.events[containerID] = recent
+	return len(recent)
+}
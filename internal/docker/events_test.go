@@ -0,0 +1,29 @@
+package docker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOOMTracker_Count(t *testing.T) {
+	tracker := NewOOMTracker(nil)
+
+	now := time.Now()
+	tracker.Record("abc", now.Add(-2*time.Hour)) // Outside the window
+	tracker.Record("abc", now.Add(-30*time.Minute))
+	tracker.Record("abc", now.Add(-time.Minute))
+	tracker.Record("def", now.Add(-90*time.Minute))
+
+	if count := tracker.Count("abc"); count != 2 {
+		t.Errorf("Expected 2 OOM kills for abc, got %d", count)
+	}
+	if count := tracker.Count("def"); count != 0 {
+		t.Errorf("Expected 0 OOM kills for def, got %d", count)
+	}
+	if _, exists := tracker.events["def"]; exists {
+		t.Error("Expected expired events for def to be dropped")
+	}
+	if count := tracker.Count("unknown"); count != 0 {
+		t.Errorf("Expected 0 OOM kills for unknown container, got %d", count)
+	}
+}
@@ -0,0 +1,125 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// statsStream holds the most recent sample from a container's stats stream
+type statsStream struct {
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	latest *container.StatsResponse
+	done   bool
+}
+
+// StatsStreamer keeps a long-lived stats stream open per running container.
+// Docker pushes a sample roughly every second with PreCPUStats already
+// filled in, so collection can read the last value instead of waiting for
+// a one-shot request to take two samples itself.
+type StatsStreamer struct {
+	cli    *client.Client
+	maxAge time.Duration // Samples older than this are not served
+
+	mu      sync.Mutex
+	streams map[string]*statsStream // key: container ID
+}
+
+// NewStatsStreamer creates a new stats streamer
+func NewStatsStreamer(cli *client.Client) *StatsStreamer {
+	return &StatsStreamer{
+		cli:     cli,
+		maxAge:  5 * time.Second,
+		streams: make(map[string]*statsStream),
+	}
+}
+
+// Latest returns the last streamed sample for a container. The first call
+// for a container opens its stream and returns false; callers should fall
+// back to a one-shot request until a sample is available.
+func (s *StatsStreamer) Latest(containerID string) (*container.StatsResponse, bool) {
+	s.mu.Lock()
+	stream, exists := s.streams[containerID]
+	if !exists || stream.isDone() {
+		stream = s.open(containerID)
+		s.streams[containerID] = stream
+	}
+	s.mu.Unlock()
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if stream.latest == nil || time.Since(stream.latest.Read) > s.maxAge {
+		return nil, false
+	}
+	return stream.latest, true
+}
+
+// Prune closes streams for containers that are no longer running
+func (s *StatsStreamer) Prune(running map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, stream := range s.streams {
+		if !running[id] {
+			stream.cancel()
+			delete(s.streams, id)
+		}
+	}
+}
+
+// Close stops all open streams
+func (s *StatsStreamer) Close() {
+	s.Prune(nil)
+}
+
+// open starts reading a container's stats stream in the background.
+// Must be called with s.mu held.
+func (s *StatsStreamer) open(containerID string) *statsStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &statsStream{cancel: cancel}
+
+	go func() {
+		defer stream.finish()
+
+		resp, err := s.cli.ContainerStats(ctx, containerID, true)
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var v container.StatsResponse
+			if err := decoder.Decode(&v); err != nil {
+				// Stream ends when the container stops or we cancel it
+				return
+			}
+
+			stream.mu.Lock()
+			stream.latest = &v
+			stream.mu.Unlock()
+		}
+	}()
+
+	return stream
+}
+
+func (st *statsStream) finish() {
+	st.mu.Lock()
+	st.done = true
+	st.latest = nil
+	st.mu.Unlock()
+}
+
+func (st *statsStream) isDone() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.done
+}
@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+func TestStatsStreamer_Latest(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/containers/abc/stats") {
+			http.NotFound(w, r)
+			return
+		}
+
+		var stats container.StatsResponse
+		stats.Read = time.Now()
+		stats.MemoryStats.Usage = 1024
+		json.NewEncoder(w).Encode(stats)
+		w.(http.Flusher).Flush()
+
+		// Hold the stream open like the daemon does
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+server.Listener.Addr().String()), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer cli.Close()
+
+	streamer := NewStatsStreamer(cli)
+	defer streamer.Close()
+
+	// First call opens the stream and has nothing to serve yet
+	if _, ok := streamer.Latest("abc"); ok {
+		t.Error("Expected no sample before the stream delivered one")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if stats, ok := streamer.Latest("abc"); ok {
+			if stats.MemoryStats.Usage != 1024 {
+				t.Errorf("Expected memory usage 1024, got %d", stats.MemoryStats.Usage)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Expected a streamed sample within 2s")
+}
+
+func TestStatsStreamer_Prune(t *testing.T) {
+	streamer := &StatsStreamer{streams: make(map[string]*statsStream)}
+
+	cancelled := map[string]bool{}
+	for _, id := range []string{"a", "b"} {
+		id := id
+		streamer.streams[id] = &statsStream{cancel: func() { cancelled[id] = true }}
+	}
+
+	streamer.Prune(map[string]bool{"a": true})
+
+	if _, exists := streamer.streams["a"]; !exists {
+		t.Error("Expected stream for running container to be kept")
+	}
+	if _, exists := streamer.streams["b"]; exists {
+		t.Error("Expected stream for stopped container to be removed")
+	}
+	if !cancelled["b"] || cancelled["a"] {
+		t.Errorf("Expected only stream b to be cancelled, got %v", cancelled)
+	}
+}
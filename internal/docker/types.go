@@ -18,7 +18,18 @@ type ContainerInfo struct {
 	ExitCode      int       `json:"exit_code"`      // Exit code when stopped
 	OOMKilled     bool      `json:"oom_killed"`     // Was killed due to OOM
 	RestartCount  int       `json:"restart_count"`  // Number of times restarted
+	StateError    string    `json:"state_error,omitempty"` // Daemon error from the last exit, if any
 	
+	// OOM kills seen on the events stream in the last hour
+	OOMKillsLastHour int `json:"oom_kills_last_hour,omitempty"`
+
+	// Last health check result (only populated when unhealthy)
+	HealthCheckExitCode int    `json:"health_check_exit_code,omitempty"`
+	HealthCheckOutput   string `json:"health_check_output,omitempty"`
+
+	// Registry has a newer image for this tag (only when update checks are enabled)
+	UpdateAvailable bool `json:"update_available,omitempty"`
+
 	// Timestamps
 	Created   time.Time `json:"created"`
 	StartedAt time.Time `json:"started_at"`
@@ -42,6 +53,20 @@ type ContainerInfo struct {
 	PIDs uint64 `json:"pids"` // Number of processes in container
 }
 
+// DaemonInfo represents Docker daemon-level information
+type DaemonInfo struct {
+	Version           string
+	APIVersion        string
+	StorageDriver     string
+	Containers        int
+	ContainersRunning int
+	ContainersPaused  int
+	ContainersStopped int
+	Images            int
+	Goroutines        int
+	DataRoot          string
+}
+
 // FilterConfig defines container filtering options
 type FilterConfig struct {
 	// Monitor all containers (default: true)
@@ -55,6 +80,16 @@ type FilterConfig struct {
 
 	// Filter by image patterns (e.g., "mycompany/*", "nginx:*")
 	Images []string
+
+	// Exclude containers with these labels ("key" or "key=value"), applied
+	// after the include filters and also when monitoring all containers
+	ExcludeLabels []string
+
+	// Exclude containers matching name patterns (e.g., "buildkit*", "tmp-*")
+	ExcludeNames []string
+
+	// Exclude containers matching image patterns (e.g., "moby/buildkit:*")
+	ExcludeImages []string
 }
 
 // AlertConfig defines alert thresholds for containers
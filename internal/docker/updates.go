@@ -0,0 +1,116 @@
+package docker
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// imageUpdateResult is a cached registry comparison for one image
+type imageUpdateResult struct {
+	updateAvailable bool
+	checkedAt       time.Time
+	seenAt          time.Time // last time a collection asked about this image
+}
+
+// ImageUpdateChecker compares local image digests against the registry's
+// digest for the same tag. Results are cached so the registry is queried
+// at most once per interval for each image.
+type ImageUpdateChecker struct {
+	cli      *client.Client
+	interval time.Duration
+
+	mu    sync.Mutex
+	cache map[string]imageUpdateResult // key: image ref + local image ID
+}
+
+// NewImageUpdateChecker creates a new image update checker
+func NewImageUpdateChecker(cli *client.Client, interval time.Duration) *ImageUpdateChecker {
+	return &ImageUpdateChecker{
+		cli:      cli,
+		interval: interval,
+		cache:    make(map[string]imageUpdateResult),
+	}
+}
+
+// UpdateAvailable reports whether the registry has a newer image for imageRef
+// than the local image identified by imageID. Images pinned by digest and
+// locally built images (no repo digests) are never reported as outdated.
+func (u *ImageUpdateChecker) UpdateAvailable(ctx context.Context, imageRef, imageID string) bool {
+	if strings.Contains(imageRef, "@sha256:") {
+		return false
+	}
+
+	key := imageRef + "|" + imageID
+
+	now := time.Now()
+
+	u.mu.Lock()
+	cached, exists := u.cache[key]
+	if exists && now.Sub(cached.checkedAt) < u.interval {
+		cached.seenAt = now
+		u.cache[key] = cached
+		u.mu.Unlock()
+		return cached.updateAvailable
+	}
+	u.mu.Unlock()
+
+	result := imageUpdateResult{checkedAt: now, seenAt: now}
+	if available, err := u.compare(ctx, imageRef, imageID); err == nil {
+		result.updateAvailable = available
+	} else if exists {
+		// Keep the last known answer if the registry is unreachable
+		result.updateAvailable = cached.updateAvailable
+	}
+
+	u.mu.Lock()
+	u.cache[key] = result
+	u.mu.Unlock()
+
+	return result.updateAvailable
+}
+
+// Prune drops cached results for images no collection has asked about
+// since the given time, and results older than the check interval, so
+// containers that were removed or re-pulled don't keep entries forever
+func (u *ImageUpdateChecker) Prune(since time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now()
+	for key, result := range u.cache {
+		if result.seenAt.Before(since) || now.Sub(result.checkedAt) >= u.interval {
+			delete(u.cache, key)
+		}
+	}
+}
+
+// compare fetches the local repo digests and the remote digest for the tag
+func (u *ImageUpdateChecker) compare(ctx context.Context, imageRef, imageID string) (bool, error) {
+	local, _, err := u.cli.ImageInspectWithRaw(ctx, imageID)
+	if err != nil {
+		return false, err
+	}
+
+	// Locally built images have nothing to compare against
+	if len(local.RepoDigests) == 0 {
+		return false, nil
+	}
+
+	remote, err := u.cli.DistributionInspect(ctx, imageRef, "")
+	if err != nil {
+		return false, err
+	}
+
+	remoteDigest := remote.Descriptor.Digest.String()
+	for _, repoDigest := range local.RepoDigests {
+		if strings.HasSuffix(repoDigest, "@"+remoteDigest) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,196 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+const (
+	localDigest  = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	remoteDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// fakeRegistry serves the daemon's image inspect and distribution endpoints
+type fakeRegistry struct {
+	mu       sync.Mutex
+	digest   string // digest the registry reports for the tag
+	fail     bool   // return 500 from the distribution endpoint
+	requests int    // distribution requests served
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case strings.Contains(r.URL.Path, "/images/"):
+		fmt.Fprintf(w, `{"Id":"sha256:abc","RepoDigests":["nginx@%s"]}`, localDigest)
+	case strings.Contains(r.URL.Path, "/distribution/"):
+		f.requests++
+		if f.fail {
+			http.Error(w, `{"message":"registry unavailable"}`, http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"Descriptor":{"mediaType":"application/vnd.oci.image.index.v1+json","digest":"%s","size":1}}`, f.digest)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeRegistry) set(digest string, fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.digest = digest
+	f.fail = fail
+}
+
+func (f *fakeRegistry) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func newTestUpdateChecker(t *testing.T, registry *fakeRegistry, interval time.Duration) *ImageUpdateChecker {
+	server := httptest.NewServer(registry)
+	t.Cleanup(server.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+server.Listener.Addr().String()), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { cli.Close() })
+
+	return NewImageUpdateChecker(cli, interval)
+}
+
+// expire backdates every cached result past the check interval
+func expire(u *ImageUpdateChecker) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for key, result := range u.cache {
+		result.checkedAt = result.checkedAt.Add(-2 * u.interval)
+		u.cache[key] = result
+	}
+}
+
+func TestImageUpdateChecker_Cache(t *testing.T) {
+	registry := &fakeRegistry{digest: remoteDigest}
+	checker := newTestUpdateChecker(t, registry, time.Hour)
+	ctx := context.Background()
+
+	if !checker.UpdateAvailable(ctx, "nginx:latest", "sha256:abc") {
+		t.Error("Expected update available when the registry digest differs")
+	}
+	if !checker.UpdateAvailable(ctx, "nginx:latest", "sha256:abc") {
+		t.Error("Expected cached result to report update available")
+	}
+
+	if registry.count() != 1 {
+		t.Errorf("Expected 1 registry request within the interval, got %d", registry.count())
+	}
+}
+
+func TestImageUpdateChecker_UpToDate(t *testing.T) {
+	registry := &fakeRegistry{digest: localDigest}
+	checker := newTestUpdateChecker(t, registry, time.Hour)
+
+	if checker.UpdateAvailable(context.Background(), "nginx:latest", "sha256:abc") {
+		t.Error("Expected no update when the registry digest matches a local repo digest")
+	}
+}
+
+func TestImageUpdateChecker_TTL(t *testing.T) {
+	registry := &fakeRegistry{digest: localDigest}
+	checker := newTestUpdateChecker(t, registry, time.Hour)
+	ctx := context.Background()
+
+	if checker.UpdateAvailable(ctx, "nginx:latest", "sha256:abc") {
+		t.Fatal("Expected no update on the first check")
+	}
+
+	// A new image is pushed to the tag, but the cached answer still holds
+	registry.set(remoteDigest, false)
+	if checker.UpdateAvailable(ctx, "nginx:latest", "sha256:abc") {
+		t.Error("Expected cached result before the interval elapsed")
+	}
+
+	expire(checker)
+	if !checker.UpdateAvailable(ctx, "nginx:latest", "sha256:abc") {
+		t.Error("Expected the registry to be re-checked after the interval")
+	}
+
+	if registry.count() != 2 {
+		t.Errorf("Expected 2 registry requests, got %d", registry.count())
+	}
+}
+
+func TestImageUpdateChecker_RegistryError(t *testing.T) {
+	registry := &fakeRegistry{digest: remoteDigest}
+	checker := newTestUpdateChecker(t, registry, time.Hour)
+	ctx := context.Background()
+
+	if !checker.UpdateAvailable(ctx, "nginx:latest", "sha256:abc") {
+		t.Fatal("Expected update available on the first check")
+	}
+
+	// Registry goes down: the last known answer is kept
+	registry.set(remoteDigest, true)
+	expire(checker)
+	if !checker.UpdateAvailable(ctx, "nginx:latest", "sha256:abc") {
+		t.Error("Expected last known result to be kept when the registry fails")
+	}
+
+	// With nothing cached, a registry error reports no update
+	if checker.UpdateAvailable(ctx, "redis:latest", "sha256:abc") {
+		t.Error("Expected no update for an uncached image when the registry fails")
+	}
+}
+
+func TestImageUpdateChecker_PinnedDigest(t *testing.T) {
+	registry := &fakeRegistry{digest: remoteDigest}
+	checker := newTestUpdateChecker(t, registry, time.Hour)
+
+	if checker.UpdateAvailable(context.Background(), "nginx@"+localDigest, "sha256:abc") {
+		t.Error("Expected images pinned by digest to never report an update")
+	}
+	if registry.count() != 0 {
+		t.Errorf("Expected no registry requests for a pinned image, got %d", registry.count())
+	}
+}
+
+func TestImageUpdateChecker_Prune(t *testing.T) {
+	registry := &fakeRegistry{digest: remoteDigest}
+	checker := newTestUpdateChecker(t, registry, time.Hour)
+	ctx := context.Background()
+
+	checker.UpdateAvailable(ctx, "nginx:latest", "sha256:old")
+	checker.UpdateAvailable(ctx, "redis:latest", "sha256:abc")
+
+	// Next collection only sees nginx, re-pulled to a new image ID, and redis
+	since := time.Now()
+	checker.UpdateAvailable(ctx, "nginx:latest", "sha256:new")
+	checker.UpdateAvailable(ctx, "redis:latest", "sha256:abc")
+	checker.Prune(since)
+
+	if len(checker.cache) != 2 {
+		t.Errorf("Expected 2 cached results, got %d", len(checker.cache))
+	}
+	if _, exists := checker.cache["nginx:latest|sha256:old"]; exists {
+		t.Error("Expected result for the replaced image to be pruned")
+	}
+
+	// Results past the interval are dropped even if recently seen
+	expire(checker)
+	checker.Prune(since)
+	if len(checker.cache) != 0 {
+		t.Errorf("Expected expired results to be pruned, got %d", len(checker.cache))
+	}
+}
@@ -0,0 +1,189 @@
+// Package events publishes alert lifecycle and agent status changes to
+// message brokers, so downstream automation can consume a durable event
+// stream instead of polling the API.
+package events
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// Event types
+const (
+	AlertTriggered    = "alert.triggered"
+	AlertAcknowledged = "alert.acknowledged"
+	AlertAssigned     = "alert.assigned"
+	AlertResolved     = "alert.resolved"
+	AgentRegistered   = "agent.registered"
+	AgentStatus       = "agent.status_changed"
+	AgentRemoved      = "agent.removed"
+)
+
+// pollInterval is how often the store is checked for changes
+const pollInterval = time.Second
+
+// maxPending bounds the events kept for a publisher that is failing; the
+// oldest are dropped beyond it
+const maxPending = 10000
+
+// Event is one change, published as JSON
+type Event struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	AgentName string    `json:"agent_name"`
+
+	// Agent events
+	Status         string `json:"status,omitempty"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+	StatusReason   string `json:"status_reason,omitempty"`
+
+	// Alert events
+	Alert *server.Alert `json:"alert,omitempty"`
+}
+
+// Publisher delivers events to a broker
+type Publisher interface {
+	// Name identifies the publisher in logs
+	Name() string
+	// Publish delivers events in order. On error none of them are assumed
+	// delivered and they are retried.
+	Publish(ctx context.Context, events []Event) error
+}
+
+// watcher finds the changes between two looks at the store
+type watcher struct {
+	store    *server.StateStore
+	revision uint64
+	agents   map[string]server.AgentStatus
+	alerts   map[string]*server.Alert // Copies by alert ID
+}
+
+// newWatcher starts from the store's current state, which isn't published
+func newWatcher(store *server.StateStore) *watcher {
+	w := &watcher{store: store}
+	w.poll(time.Now())
+	return w
+}
+
+// poll returns what changed since the last poll
+func (w *watcher) poll(now time.Time) []Event {
+	revision := w.store.Revision()
+	if w.agents != nil && revision == w.revision {
+		return nil
+	}
+	w.revision = revision
+
+	agents := w.store.AgentStatuses()
+	alerts := make(map[string]*server.Alert)
+	names := make([]string, 0, len(agents))
+	for name := range agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var events []Event
+	for _, name := range names {
+		status := agents[name]
+		previous, known := w.agents[name]
+		switch {
+		case w.agents == nil:
+		case !known:
+			events = append(events, Event{Type: AgentRegistered, Time: now, AgentName: name,
+				Status: status.Status, StatusReason: status.Reason})
+		case previous.Status != status.Status:
+			events = append(events, Event{Type: AgentStatus, Time: now, AgentName: name,
+				Status: status.Status, PreviousStatus: previous.Status, StatusReason: status.Reason})
+		}
+	}
+	for name := range w.agents {
+		if _, exists := agents[name]; !exists {
+			events = append(events, Event{Type: AgentRemoved, Time: now, AgentName: name})
+		}
+	}
+
+	for _, alert := range w.store.GetAlertsByStatus("all") {
+		alerts[alert.ID] = alert
+		if w.alerts == nil {
+			continue
+		}
+		event := Event{Time: now, AgentName: alert.AgentName, Alert: alert}
+		previous, known := w.alerts[alert.ID]
+		if !known {
+			// Alerts can change again before the next poll
+			event.Type = AlertTriggered
+			events = append(events, event)
+			if alert.Status == "active" {
+				continue
+			}
+			previous = &server.Alert{Status: "active"}
+		}
+		if previous.Status != alert.Status {
+			switch alert.Status {
+			case "acknowledged":
+				event.Type = AlertAcknowledged
+				events = append(events, event)
+			case "resolved":
+				event.Type = AlertResolved
+				events = append(events, event)
+			}
+		}
+		if previous.Assignee != alert.Assignee {
+			event.Type = AlertAssigned
+			events = append(events, event)
+		}
+	}
+
+	w.agents = agents
+	w.alerts = alerts
+	return events
+}
+
+// Run publishes changes to the store until ctx is done. Events that fail to
+// publish are retried with the next ones.
+func Run(ctx context.Context, store *server.StateStore, publisher Publisher) {
+	logger := logging.Component("events").With("publisher", publisher.Name())
+	logger.Info("Publishing events")
+
+	w := newWatcher(store)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var pending []Event
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			pending = append(pending, w.poll(now)...)
+			if len(pending) == 0 {
+				continue
+			}
+			if dropped := len(pending) - maxPending; dropped > 0 {
+				logger.Warn("Dropping events the publisher couldn't take", "dropped", dropped)
+				pending = pending[dropped:]
+			}
+
+			publishCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			err := publisher.Publish(publishCtx, pending)
+			cancel()
+			if err != nil {
+				// Log once per outage rather than every second
+				if !failing {
+					logger.Warn("Publishing events failed, retrying", logging.Err(err))
+				}
+				failing = true
+				continue
+			}
+			if failing {
+				logger.Info("Publishing events recovered")
+			}
+			failing = false
+			pending = nil
+		}
+	}
+}
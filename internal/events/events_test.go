@@ -0,0 +1,141 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func addAgent(store *server.StateStore, name string) {
+	store.UpdateAgent(&server.ServerState{
+		AgentName:     name,
+		SystemMetrics: metrics.SystemMetrics{Timestamp: time.Now()},
+	})
+}
+
+func eventTypes(events []Event) []string {
+	types := make([]string, len(events))
+	for i, e := range events {
+		types[i] = e.Type + " " + e.AgentName
+	}
+	return types
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	store := server.NewStateStore()
+	addAgent(store, "web-1")
+	w := newWatcher(store)
+
+	// What the store held to begin with isn't published
+	if events := w.poll(time.Now()); len(events) != 0 {
+		t.Errorf("Expected no events without changes, got %v", eventTypes(events))
+	}
+
+	addAgent(store, "web-2")
+	store.MarkStopped("web-1", false)
+	store.AddAlert(&server.Alert{ID: "a1", AgentName: "web-2", AlertType: "cpu", Status: "active"})
+	events := w.poll(time.Now())
+
+	want := []string{"agent.status_changed web-1", "agent.registered web-2", "alert.triggered web-2"}
+	if got := eventTypes(events); len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	} else {
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Expected %v, got %v", want, got)
+				break
+			}
+		}
+	}
+	if events[0].Status != "stopped" || events[0].PreviousStatus != "online" {
+		t.Errorf("Expected online to stopped, got %s to %s", events[0].PreviousStatus, events[0].Status)
+	}
+	if events[2].Alert == nil || events[2].Alert.ID != "a1" {
+		t.Errorf("Expected the alert in the event, got %+v", events[2].Alert)
+	}
+
+	store.AcknowledgeAlert("a1")
+	store.AssignAlert("a1", "alice")
+	events = w.poll(time.Now())
+	if got := eventTypes(events); len(got) != 2 || got[0] != "alert.acknowledged web-2" || got[1] != "alert.assigned web-2" {
+		t.Errorf("Expected acknowledged and assigned, got %v", got)
+	}
+
+	store.ResolveAlert("a1")
+	store.RemoveAgent("web-1")
+	events = w.poll(time.Now())
+	if got := eventTypes(events); len(got) != 2 || got[0] != "agent.removed web-1" || got[1] != "alert.resolved web-2" {
+		t.Errorf("Expected removed and resolved, got %v", got)
+	}
+}
+
+func TestWatcher_AlertChangedBeforePoll(t *testing.T) {
+	store := server.NewStateStore()
+	w := newWatcher(store)
+
+	store.AddAlert(&server.Alert{ID: "a1", AgentName: "web-1", Status: "active"})
+	store.ResolveAlert("a1")
+
+	events := w.poll(time.Now())
+	if got := eventTypes(events); len(got) != 2 || got[0] != "alert.triggered web-1" || got[1] != "alert.resolved web-1" {
+		t.Errorf("Expected triggered and resolved, got %v", got)
+	}
+}
+
+// flakyPublisher fails until told otherwise
+type flakyPublisher struct {
+	mu        sync.Mutex
+	fail      bool
+	published []Event
+}
+
+func (p *flakyPublisher) Name() string { return "test" }
+
+func (p *flakyPublisher) Publish(ctx context.Context, events []Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail {
+		return errors.New("broker down")
+	}
+	p.published = append(p.published, events...)
+	return nil
+}
+
+func (p *flakyPublisher) get() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Event{}, p.published...)
+}
+
+func TestRun_RetriesFailedEvents(t *testing.T) {
+	store := server.NewStateStore()
+	publisher := &flakyPublisher{fail: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, store, publisher)
+	time.Sleep(100 * time.Millisecond)
+
+	addAgent(store, "web-1")
+	time.Sleep(1500 * time.Millisecond)
+	if len(publisher.get()) != 0 {
+		t.Fatal("Expected nothing published while the broker is down")
+	}
+
+	publisher.mu.Lock()
+	publisher.fail = false
+	publisher.mu.Unlock()
+	addAgent(store, "web-2")
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(publisher.get()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got := eventTypes(publisher.get()); len(got) != 2 || got[0] != "agent.registered web-1" || got[1] != "agent.registered web-2" {
+		t.Errorf("Expected both registrations in order, got %v", got)
+	}
+}
@@ -0,0 +1,366 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Kafka API keys and the versions used, both supported since Kafka 0.11
+const (
+	kafkaProduce         = 0
+	kafkaProduceVersion  = 3
+	kafkaMetadata        = 3
+	kafkaMetadataVersion = 1
+)
+
+// kafkaBatchEvents keeps requests under the brokers' default 1MB limit
+const kafkaBatchEvents = 200
+
+// kafkaMaxResponse guards against reading a bogus length as a huge response
+const kafkaMaxResponse = 64 * 1024 * 1024
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// KafkaPublisher produces events to a Kafka topic as JSON, keyed by agent
+// name. All events of an agent go to one partition, so consumers see them
+// in order. It speaks the Kafka protocol directly, without TLS or SASL.
+type KafkaPublisher struct {
+	brokers  []string // Bootstrap brokers, host:port
+	topic    string
+	clientID string
+}
+
+// NewKafkaPublisher creates a publisher for topic on the cluster of the
+// given bootstrap brokers
+func NewKafkaPublisher(brokers []string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{brokers: brokers, topic: topic, clientID: "saviour-server"}
+}
+
+// Name implements Publisher
+func (k *KafkaPublisher) Name() string {
+	return "Kafka"
+}
+
+// kafkaPartition is a partition of the topic and the broker leading it
+type kafkaPartition struct {
+	id     int32
+	leader string // host:port, "" while one is elected
+}
+
+// Publish implements Publisher. It looks up the topic's partition leaders
+// and waits for all in-sync replicas to take the events.
+func (k *KafkaPublisher) Publish(ctx context.Context, events []Event) error {
+	partitions, err := k.metadata(ctx)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(events); start += kafkaBatchEvents {
+		end := min(start+kafkaBatchEvents, len(events))
+		if err := k.publish(ctx, partitions, events[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publish produces events to the leaders of their partitions
+func (k *KafkaPublisher) publish(ctx context.Context, partitions []kafkaPartition, events []Event) error {
+	// Group the events by leader, then by partition
+	byLeader := make(map[string]map[int32][]Event)
+	for _, event := range events {
+		h := fnv.New32a()
+		h.Write([]byte(event.AgentName))
+		p := partitions[h.Sum32()%uint32(len(partitions))]
+		if p.leader == "" {
+			return fmt.Errorf("producing to %s partition %d: %w", k.topic, p.id, kafkaError(5))
+		}
+		if byLeader[p.leader] == nil {
+			byLeader[p.leader] = make(map[int32][]Event)
+		}
+		byLeader[p.leader][p.id] = append(byLeader[p.leader][p.id], event)
+	}
+
+	for leader, byPartition := range byLeader {
+		if err := k.produce(ctx, leader, byPartition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metadata returns the topic's partitions, asking each bootstrap broker in
+// turn
+func (k *KafkaPublisher) metadata(ctx context.Context) ([]kafkaPartition, error) {
+	var req kafkaEncoder
+	req.int32(1)
+	req.string(k.topic)
+
+	var lastErr error
+	for _, broker := range k.brokers {
+		resp, err := k.roundTrip(ctx, broker, kafkaMetadata, kafkaMetadataVersion, req.buf)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return k.parseMetadata(resp)
+	}
+	return nil, fmt.Errorf("no Kafka broker reachable: %w", lastErr)
+}
+
+func (k *KafkaPublisher) parseMetadata(resp []byte) ([]kafkaPartition, error) {
+	d := kafkaDecoder{buf: resp}
+	brokers := make(map[int32]string)
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // Rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // Controller
+
+	var partitions []kafkaPartition
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		code := d.int16()
+		name := d.string()
+		d.int8() // Internal
+		if name == k.topic && code != 0 {
+			return nil, fmt.Errorf("metadata for topic %s: %w", k.topic, kafkaError(code))
+		}
+		for m := d.int32(); m > 0 && d.err == nil; m-- {
+			d.int16() // Partition error, e.g. no leader while one is elected
+			id := d.int32()
+			leader := d.int32()
+			d.int32Array() // Replicas
+			d.int32Array() // In-sync replicas
+			if name == k.topic {
+				partitions = append(partitions, kafkaPartition{id: id, leader: brokers[leader]})
+			}
+		}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("invalid metadata response: %w", d.err)
+	}
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("topic %s has no partitions", k.topic)
+	}
+	// Keep the partition for an agent stable across metadata lookups
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].id < partitions[j].id })
+	return partitions, nil
+}
+
+// produce sends the events of each partition to their leader
+func (k *KafkaPublisher) produce(ctx context.Context, leader string, byPartition map[int32][]Event) error {
+	var req kafkaEncoder
+	req.int16(-1)    // No transactional ID
+	req.int16(-1)    // acks=all
+	req.int32(10000) // Timeout in milliseconds
+	req.int32(1)
+	req.string(k.topic)
+	req.int32(int32(len(byPartition)))
+	for partition, events := range byPartition {
+		batch, err := recordBatch(events)
+		if err != nil {
+			return err
+		}
+		req.int32(partition)
+		req.int32(int32(len(batch)))
+		req.buf = append(req.buf, batch...)
+	}
+
+	resp, err := k.roundTrip(ctx, leader, kafkaProduce, kafkaProduceVersion, req.buf)
+	if err != nil {
+		return err
+	}
+
+	d := kafkaDecoder{buf: resp}
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		d.string() // Topic
+		for m := d.int32(); m > 0 && d.err == nil; m-- {
+			partition := d.int32()
+			code := d.int16()
+			d.int64() // Base offset
+			d.int64() // Log append time
+			if code != 0 {
+				return fmt.Errorf("producing to %s partition %d: %w", k.topic, partition, kafkaError(code))
+			}
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("invalid produce response: %w", d.err)
+	}
+	return nil
+}
+
+// roundTrip sends one request on a new connection and returns the response
+// body after the correlation ID. Events are infrequent, so connections
+// aren't kept.
+func (k *KafkaPublisher) roundTrip(ctx context.Context, broker string, apiKey, version int16, body []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", broker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Kafka broker %s: %w", broker, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	const correlationID = 1
+	var header kafkaEncoder
+	header.int16(apiKey)
+	header.int16(version)
+	header.int32(correlationID)
+	header.string(k.clientID)
+
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(header.buf)+len(body)))
+	frame = append(append(frame, header.buf...), body...)
+	if _, err := conn.Write(frame); err != nil {
+		return nil, fmt.Errorf("failed to send to Kafka broker %s: %w", broker, err)
+	}
+
+	reader := bufio.NewReader(conn)
+	var size [4]byte
+	if _, err := io.ReadFull(reader, size[:]); err != nil {
+		return nil, fmt.Errorf("failed to read from Kafka broker %s: %w", broker, err)
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length < 4 || length > kafkaMaxResponse {
+		return nil, fmt.Errorf("Kafka broker %s sent a response of %d bytes", broker, length)
+	}
+	resp := make([]byte, length)
+	if _, err := io.ReadFull(reader, resp); err != nil {
+		return nil, fmt.Errorf("failed to read from Kafka broker %s: %w", broker, err)
+	}
+	if binary.BigEndian.Uint32(resp) != correlationID {
+		return nil, fmt.Errorf("Kafka broker %s answered another request", broker)
+	}
+	return resp[4:], nil
+}
+
+// recordBatch encodes events as a v2 record batch, keyed by agent name
+func recordBatch(events []Event) ([]byte, error) {
+	now := time.Now().UnixMilli()
+
+	var records []byte
+	for i, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal event: %w", err)
+		}
+		var record []byte
+		record = append(record, 0)                     // Attributes
+		record = binary.AppendVarint(record, 0)        // Timestamp delta
+		record = binary.AppendVarint(record, int64(i)) // Offset delta
+		record = binary.AppendVarint(record, int64(len(event.AgentName)))
+		record = append(record, event.AgentName...)
+		record = binary.AppendVarint(record, int64(len(value)))
+		record = append(record, value...)
+		record = binary.AppendVarint(record, 0) // Headers
+
+		records = binary.AppendVarint(records, int64(len(record)))
+		records = append(records, record...)
+	}
+
+	// Everything from the attributes on is covered by the CRC
+	var tail kafkaEncoder
+	tail.int16(0) // Attributes: no compression
+	tail.int32(int32(len(events) - 1))
+	tail.int64(now) // First timestamp
+	tail.int64(now) // Max timestamp
+	tail.int64(-1)  // Producer ID
+	tail.int16(-1)  // Producer epoch
+	tail.int32(-1)  // Base sequence
+	tail.int32(int32(len(events)))
+	tail.buf = append(tail.buf, records...)
+
+	var batch kafkaEncoder
+	batch.int64(0)                                // Base offset
+	batch.int32(int32(4 + 1 + 4 + len(tail.buf))) // Length after this field
+	batch.int32(-1)                               // Partition leader epoch
+	batch.int8(2)                                 // Magic
+	batch.buf = binary.BigEndian.AppendUint32(batch.buf, crc32.Checksum(tail.buf, castagnoli))
+	batch.buf = append(batch.buf, tail.buf...)
+	return batch.buf, nil
+}
+
+// kafkaEncoder appends Kafka's big-endian primitives
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) int8(v int8)   { e.buf = append(e.buf, byte(v)) }
+func (e *kafkaEncoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *kafkaEncoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *kafkaEncoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// kafkaDecoder reads Kafka's big-endian primitives. After the first short
+// read it returns zero values and keeps the error.
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) take(n int) []byte {
+	if d.err != nil || n < 0 || len(d.buf) < n {
+		d.err = errors.New("truncated response")
+		return make([]byte, max(n, 0))
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8   { return int8(d.take(1)[0]) }
+func (d *kafkaDecoder) int16() int16 { return int16(binary.BigEndian.Uint16(d.take(2))) }
+func (d *kafkaDecoder) int32() int32 { return int32(binary.BigEndian.Uint32(d.take(4))) }
+func (d *kafkaDecoder) int64() int64 { return int64(binary.BigEndian.Uint64(d.take(8))) }
+
+// string reads a nullable string, null reading as ""
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+func (d *kafkaDecoder) int32Array() {
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		d.int32()
+	}
+}
+
+// kafkaError describes a Kafka error code
+func kafkaError(code int16) error {
+	names := map[int16]string{
+		3:  "unknown topic or partition",
+		5:  "leader not available",
+		6:  "not leader for partition",
+		7:  "request timed out",
+		10: "message too large",
+		19: "not enough replicas",
+		20: "not enough replicas after append",
+		29: "topic authorization failed",
+	}
+	if name, known := names[code]; known {
+		return fmt.Errorf("Kafka error %d (%s)", code, name)
+	}
+	return fmt.Errorf("Kafka error %d", code)
+}
@@ -0,0 +1,222 @@
+package events
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeKafka is a single broker leading every partition of one topic. It
+// checks the record batches it is sent and records their keys and values.
+type fakeKafka struct {
+	t          *testing.T
+	listener   net.Listener
+	topic      string
+	partitions int32
+	errorCode  int16 // Returned for produce requests
+	records    chan [2]string
+}
+
+func newFakeKafka(t *testing.T, topic string, partitions int32) *fakeKafka {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	f := &fakeKafka{t: t, listener: listener, topic: topic, partitions: partitions, records: make(chan [2]string, 100)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeKafka) serve(conn net.Conn) {
+	defer conn.Close()
+	var size [4]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return
+	}
+	req := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return
+	}
+	d := kafkaDecoder{buf: req}
+	apiKey := d.int16()
+	version := d.int16()
+	correlationID := d.int32()
+	d.string() // Client ID
+
+	var resp kafkaEncoder
+	resp.int32(correlationID)
+	switch {
+	case apiKey == kafkaMetadata && version == kafkaMetadataVersion:
+		host, port, _ := net.SplitHostPort(f.listener.Addr().String())
+		portNumber, _ := strconv.Atoi(port)
+		resp.int32(1) // Brokers
+		resp.int32(1)
+		resp.string(host)
+		resp.int32(int32(portNumber))
+		resp.int16(-1) // No rack
+		resp.int32(1)  // Controller
+		resp.int32(1)  // Topics
+		resp.int16(0)
+		resp.string(f.topic)
+		resp.int8(0)
+		resp.int32(f.partitions)
+		for i := int32(0); i < f.partitions; i++ {
+			resp.int16(0)
+			resp.int32(i)
+			resp.int32(1) // Leader
+			resp.int32(1)
+			resp.int32(1) // Replicas
+			resp.int32(1)
+			resp.int32(1) // In-sync replicas
+		}
+	case apiKey == kafkaProduce && version == kafkaProduceVersion:
+		d.string() // Transactional ID
+		if acks := d.int16(); acks != -1 {
+			f.t.Errorf("Expected acks=all, got %d", acks)
+		}
+		d.int32() // Timeout
+		resp.int32(1)
+		for topics := d.int32(); topics > 0; topics-- {
+			resp.string(d.string())
+			partitions := d.int32()
+			resp.int32(partitions)
+			for ; partitions > 0; partitions-- {
+				partition := d.int32()
+				f.readBatch(d.take(int(d.int32())))
+				resp.int32(partition)
+				resp.int16(f.errorCode)
+				resp.int64(0)
+				resp.int64(-1)
+			}
+		}
+		resp.int32(0) // Throttle time
+	default:
+		f.t.Errorf("Unexpected request: API %d version %d", apiKey, version)
+		return
+	}
+
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(resp.buf)))
+	conn.Write(append(frame, resp.buf...))
+}
+
+// readBatch checks a v2 record batch and records its records
+func (f *fakeKafka) readBatch(batch []byte) {
+	d := kafkaDecoder{buf: batch}
+	d.int64() // Base offset
+	if length := d.int32(); int(length) != len(batch)-12 {
+		f.t.Errorf("Batch length %d doesn't match the %d bytes sent", length, len(batch)-12)
+	}
+	d.int32() // Partition leader epoch
+	if magic := d.int8(); magic != 2 {
+		f.t.Errorf("Expected magic 2, got %d", magic)
+	}
+	crc := uint32(d.int32())
+	if crc != crc32.Checksum(d.buf, crc32.MakeTable(crc32.Castagnoli)) {
+		f.t.Error("Batch CRC doesn't match")
+	}
+	d.int16() // Attributes
+	d.int32() // Last offset delta
+	d.int64() // First timestamp
+	d.int64() // Max timestamp
+	d.int64() // Producer ID
+	d.int16() // Producer epoch
+	d.int32() // Base sequence
+
+	records := d.int32()
+	rest := d.buf
+	varint := func() int64 {
+		v, n := binary.Varint(rest)
+		rest = rest[n:]
+		return v
+	}
+	for ; records > 0; records-- {
+		varint() // Length
+		rest = rest[1:]
+		varint() // Timestamp delta
+		varint() // Offset delta
+		keyLength := varint()
+		key := string(rest[:keyLength])
+		rest = rest[keyLength:]
+		valueLength := varint()
+		value := string(rest[:valueLength])
+		rest = rest[valueLength:]
+		varint() // Headers
+		f.records <- [2]string{key, value}
+	}
+}
+
+func TestKafkaPublisher_Publish(t *testing.T) {
+	broker := newFakeKafka(t, "saviour-events", 3)
+	publisher := NewKafkaPublisher([]string{"127.0.0.1:1", broker.listener.Addr().String()}, "saviour-events")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := []Event{
+		{Type: AgentStatus, AgentName: "web-1", Status: "offline"},
+		{Type: AlertTriggered, AgentName: "web-1"},
+		{Type: AgentRegistered, AgentName: "db-1"},
+	}
+	if err := publisher.Publish(ctx, events); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	byAgent := make(map[string][]string)
+	for range events {
+		record := <-broker.records
+		var event Event
+		if err := json.Unmarshal([]byte(record[1]), &event); err != nil {
+			t.Fatalf("Invalid event JSON: %v", err)
+		}
+		if record[0] != event.AgentName {
+			t.Errorf("Expected the record keyed by agent %s, got %s", event.AgentName, record[0])
+		}
+		byAgent[record[0]] = append(byAgent[record[0]], event.Type)
+	}
+	// An agent's events share a partition, so keep their order
+	if got := byAgent["web-1"]; len(got) != 2 || got[0] != AgentStatus || got[1] != AlertTriggered {
+		t.Errorf("Expected web-1's events in order, got %v", got)
+	}
+	if got := byAgent["db-1"]; len(got) != 1 || got[0] != AgentRegistered {
+		t.Errorf("Expected db-1's registration, got %v", got)
+	}
+}
+
+func TestKafkaPublisher_ProduceError(t *testing.T) {
+	broker := newFakeKafka(t, "saviour-events", 1)
+	broker.errorCode = 19
+	publisher := NewKafkaPublisher([]string{broker.listener.Addr().String()}, "saviour-events")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := publisher.Publish(ctx, []Event{{Type: AgentRemoved, AgentName: "web-1"}})
+	if err == nil {
+		t.Fatal("Expected an error when the broker rejects the batch")
+	}
+	if want := "not enough replicas"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected %q in the error, got %v", want, err)
+	}
+}
+
+func TestKafkaPublisher_NoBroker(t *testing.T) {
+	publisher := NewKafkaPublisher([]string{"127.0.0.1:1"}, "saviour-events")
+	if err := publisher.Publish(context.Background(), []Event{{Type: AgentRemoved}}); err == nil {
+		t.Error("Expected an error without a reachable broker")
+	}
+}
@@ -0,0 +1,155 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+)
+
+// mqttMessage is a PUBLISH the fake broker received
+type mqttMessage struct {
+	topic   string
+	qos     byte
+	retain  bool
+	payload string
+}
+
+// fakeMQTT accepts one client, acknowledges its CONNECT with returnCode and
+// records what it publishes
+type fakeMQTT struct {
+	listener   net.Listener
+	returnCode byte
+	connect    chan []byte
+	messages   chan mqttMessage
+}
+
+func newFakeMQTT(t *testing.T) *fakeMQTT {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return &fakeMQTT{listener: listener, connect: make(chan []byte, 1), messages: make(chan mqttMessage, 100)}
+}
+
+func (f *fakeMQTT) serve() {
+	conn, err := f.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		header, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		length, _ := binary.ReadUvarint(reader)
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+
+		switch header & 0xf0 {
+		case mqttConnect:
+			f.connect <- body
+			conn.Write([]byte{mqttConnAck, 2, 0, f.returnCode})
+		case mqttPublish:
+			qos := header >> 1 & 0x03
+			topicLength := int(binary.BigEndian.Uint16(body))
+			msg := mqttMessage{topic: string(body[2 : 2+topicLength]), qos: qos, retain: header&0x01 != 0}
+			rest := body[2+topicLength:]
+			if qos > 0 {
+				conn.Write([]byte{mqttPubAck, 2, rest[0], rest[1]})
+				rest = rest[2:]
+			}
+			msg.payload = string(rest)
+			f.messages <- msg
+		}
+	}
+}
+
+func TestMQTTPublisher_Publish(t *testing.T) {
+	broker := newFakeMQTT(t)
+	go broker.serve()
+
+	url := "mqtt://edge:secret@" + broker.listener.Addr().String()
+	publisher, err := NewMQTTPublisher(url, "saviour-test", "fleet/{agent}/alerts", "fleet/{agent}/status", 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := []Event{
+		{Type: AgentStatus, AgentName: "pump/7", Status: "offline", PreviousStatus: "online"},
+		{Type: AlertTriggered, AgentName: "pump/7", Alert: &server.Alert{ID: "a1", AgentName: "pump/7"}},
+		{Type: AgentRemoved, AgentName: "pump-8"},
+	}
+	if err := publisher.Publish(ctx, events); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	connect := string(<-broker.connect)
+	for _, want := range []string{"MQTT", "saviour-test", "edge", "secret"} {
+		if !strings.Contains(connect, want) {
+			t.Errorf("Expected %q in CONNECT", want)
+		}
+	}
+
+	status := <-broker.messages
+	if status.topic != "fleet/pump_7/status" || !status.retain || status.qos != 1 {
+		t.Errorf("Expected a retained QoS 1 status on fleet/pump_7/status, got %+v", status)
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(status.payload), &event); err != nil {
+		t.Fatalf("Invalid event JSON: %v", err)
+	}
+	if event.Status != "offline" {
+		t.Errorf("Expected status offline, got %s", event.Status)
+	}
+
+	alert := <-broker.messages
+	if alert.topic != "fleet/pump_7/alerts" || alert.retain {
+		t.Errorf("Expected an unretained alert on fleet/pump_7/alerts, got %+v", alert)
+	}
+
+	removed := <-broker.messages
+	if removed.topic != "fleet/pump-8/status" || !removed.retain || removed.payload != "" {
+		t.Errorf("Expected an empty retained status to clear pump-8, got %+v", removed)
+	}
+}
+
+func TestMQTTPublisher_ConnectionRefused(t *testing.T) {
+	broker := newFakeMQTT(t)
+	broker.returnCode = 5
+	go broker.serve()
+
+	publisher, _ := NewMQTTPublisher("mqtt://"+broker.listener.Addr().String(), "saviour-test", "a/{agent}", "s/{agent}", 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := publisher.Publish(ctx, []Event{{Type: AgentRemoved, AgentName: "web-1"}})
+	if err == nil || !strings.Contains(err.Error(), "not authorized") {
+		t.Errorf("Expected the broker to refuse the connection, got %v", err)
+	}
+}
+
+func TestNewMQTTPublisher_Invalid(t *testing.T) {
+	if _, err := NewMQTTPublisher("http://broker", "c", "a", "s", 0); err == nil {
+		t.Error("Expected an error for an http URL")
+	}
+	if _, err := NewMQTTPublisher("mqtt://broker", "c", "a", "s", 2); err == nil {
+		t.Error("Expected an error for QoS 2")
+	}
+}
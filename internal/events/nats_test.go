@@ -0,0 +1,160 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNATS accepts one client and records its CONNECT and published
+// messages by subject
+type fakeNATS struct {
+	listener net.Listener
+	connect  chan map[string]interface{}
+	messages chan [2]string // Subject and payload
+	reject   string         // Sent as -ERR instead of the PONG after CONNECT
+}
+
+func newFakeNATS(t *testing.T) *fakeNATS {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return &fakeNATS{
+		listener: listener,
+		connect:  make(chan map[string]interface{}, 1),
+		messages: make(chan [2]string, 100),
+	}
+}
+
+func (f *fakeNATS) url(userinfo string) string {
+	return "nats://" + userinfo + f.listener.Addr().String()
+}
+
+func (f *fakeNATS) serve() {
+	conn, err := f.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte(`INFO {"server_id":"test","max_payload":1048576}` + "\r\n"))
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "CONNECT "):
+			var opts map[string]interface{}
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &opts)
+			f.connect <- opts
+			if f.reject != "" {
+				conn.Write([]byte("-ERR '" + f.reject + "'\r\n"))
+				return
+			}
+		case fields[0] == "PUB":
+			size, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			f.messages <- [2]string{fields[1], string(payload[:size])}
+		case fields[0] == "PING":
+			conn.Write([]byte("PONG\r\n"))
+		}
+	}
+}
+
+func TestNATSPublisher_Publish(t *testing.T) {
+	server := newFakeNATS(t)
+	go server.serve()
+
+	publisher, err := NewNATSPublisher(server.url("ops:secret@"), "saviour.events.")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := []Event{
+		{Type: AgentStatus, AgentName: "web-1", Status: "offline", PreviousStatus: "online"},
+		{Type: AlertTriggered, AgentName: "web-1"},
+	}
+	if err := publisher.Publish(ctx, events); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	opts := <-server.connect
+	if opts["user"] != "ops" || opts["pass"] != "secret" || opts["verbose"] != false {
+		t.Errorf("Unexpected CONNECT options: %v", opts)
+	}
+
+	msg := <-server.messages
+	if msg[0] != "saviour.events.agent.status_changed" {
+		t.Errorf("Expected subject saviour.events.agent.status_changed, got %s", msg[0])
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(msg[1]), &event); err != nil {
+		t.Fatalf("Invalid event JSON: %v", err)
+	}
+	if event.AgentName != "web-1" || event.Status != "offline" || event.PreviousStatus != "online" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if msg := <-server.messages; msg[0] != "saviour.events.alert.triggered" {
+		t.Errorf("Expected subject saviour.events.alert.triggered, got %s", msg[0])
+	}
+
+	// The connection is kept for the next publish
+	if err := publisher.Publish(ctx, events[:1]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if msg := <-server.messages; msg[0] != "saviour.events.agent.status_changed" {
+		t.Errorf("Expected subject saviour.events.agent.status_changed, got %s", msg[0])
+	}
+}
+
+func TestNATSPublisher_Token(t *testing.T) {
+	server := newFakeNATS(t)
+	go server.serve()
+
+	publisher, _ := NewNATSPublisher(server.url("s3cr3t@"), "saviour.events")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := publisher.Publish(ctx, []Event{{Type: AgentRemoved, AgentName: "web-1"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts := <-server.connect; opts["auth_token"] != "s3cr3t" {
+		t.Errorf("Expected auth_token s3cr3t, got %v", opts["auth_token"])
+	}
+}
+
+func TestNATSPublisher_Rejected(t *testing.T) {
+	server := newFakeNATS(t)
+	server.reject = "Authorization Violation"
+	go server.serve()
+
+	publisher, _ := NewNATSPublisher(server.url(""), "saviour.events")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := publisher.Publish(ctx, []Event{{Type: AgentRemoved, AgentName: "web-1"}})
+	if err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("Expected the server's error, got %v", err)
+	}
+}
+
+func TestNewNATSPublisher_InvalidURL(t *testing.T) {
+	if _, err := NewNATSPublisher("http://nats:4222", "saviour.events"); err == nil {
+		t.Error("Expected an error for an http URL")
+	}
+}
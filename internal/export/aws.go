@@ -0,0 +1,217 @@
+package export
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// IMDSv2 endpoint for instance role credentials, overridable as in the
+	// AWS SDKs
+	defaultIMDSEndpoint = "http://169.254.169.254"
+	imdsEndpointEnv     = "AWS_EC2_METADATA_SERVICE_ENDPOINT"
+	imdsTokenPath       = "/latest/api/token"
+	imdsCredentialsPath = "/latest/meta-data/iam/security-credentials/"
+
+	// Refresh instance role credentials this long before they expire
+	credentialsRenewBefore = 5 * time.Minute
+)
+
+// awsCredentials sign requests to AWS APIs
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expires         time.Time // Zero for static credentials
+}
+
+// awsCredentialSource resolves credentials from $AWS_ACCESS_KEY_ID and
+// friends, falling back to the EC2 instance role, which is cached until
+// shortly before it expires
+type awsCredentialSource struct {
+	client       *http.Client
+	imdsEndpoint string
+
+	mu     sync.Mutex
+	cached awsCredentials
+}
+
+func newAWSCredentialSource() *awsCredentialSource {
+	endpoint := os.Getenv(imdsEndpointEnv)
+	if endpoint == "" {
+		endpoint = defaultIMDSEndpoint
+	}
+	return &awsCredentialSource{
+		client:       &http.Client{Timeout: 2 * time.Second},
+		imdsEndpoint: strings.TrimSuffix(endpoint, "/"),
+	}
+}
+
+func (s *awsCredentialSource) get(ctx context.Context) (awsCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return awsCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached.AccessKeyID != "" && time.Until(s.cached.Expires) > credentialsRenewBefore {
+		return s.cached, nil
+	}
+	creds, err := s.fetchInstanceRole(ctx)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("no AWS credentials in the environment or instance role: %w", err)
+	}
+	s.cached = creds
+	return creds, nil
+}
+
+// fetchInstanceRole reads the instance role's credentials from IMDSv2
+func (s *awsCredentialSource) fetchInstanceRole(ctx context.Context) (awsCredentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.imdsEndpoint+imdsTokenPath, nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+	token, err := s.read(req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to get IMDS token: %w", err)
+	}
+
+	role, err := s.fetch(ctx, imdsCredentialsPath, token)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to get instance role: %w", err)
+	}
+	role, _, _ = strings.Cut(strings.TrimSpace(role), "\n")
+	if role == "" {
+		return awsCredentials{}, fmt.Errorf("instance has no role")
+	}
+
+	data, err := s.fetch(ctx, imdsCredentialsPath+role, token)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to get credentials for role %s: %w", role, err)
+	}
+	var resp struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to parse credentials for role %s: %w", role, err)
+	}
+	return awsCredentials{
+		AccessKeyID:     resp.AccessKeyID,
+		SecretAccessKey: resp.SecretAccessKey,
+		SessionToken:    resp.Token,
+		Expires:         resp.Expiration,
+	}, nil
+}
+
+func (s *awsCredentialSource) fetch(ctx context.Context, path, token string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.imdsEndpoint+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	return s.read(req)
+}
+
+func (s *awsCredentialSource) read(req *http.Request) (string, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("IMDS returned status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// signV4 signs req with AWS Signature Version 4. It signs the host,
+// Content-Type and X-Amz-* headers; body must be what req will send.
+func signV4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignV4(t *testing.T) {
+	// get-vanilla from the AWS Signature Version 4 test suite
+	req := httptest.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	req.Header = http.Header{}
+	creds := awsCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+
+	signV4(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Expected Authorization\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestAWSCredentialSource_Environment(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "token")
+
+	creds, err := newAWSCredentialSource().get(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get credentials: %v", err)
+	}
+	if creds.AccessKeyID != "AKID" || creds.SecretAccessKey != "secret" || creds.SessionToken != "token" {
+		t.Errorf("Expected credentials from the environment, got %+v", creds)
+	}
+}
+
+func TestAWSCredentialSource_InstanceRole(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	requests := 0
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case imdsTokenPath:
+			w.Write([]byte("imds-token"))
+		case imdsCredentialsPath:
+			requests++
+			w.Write([]byte("saviour-server\n"))
+		case imdsCredentialsPath + "saviour-server":
+			if r.Header.Get("X-aws-ec2-metadata-token") != "imds-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"AccessKeyId":     "ASIA",
+				"SecretAccessKey": "role-secret",
+				"Token":           "role-token",
+				"Expiration":      time.Now().Add(time.Hour),
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer imds.Close()
+	t.Setenv(imdsEndpointEnv, imds.URL)
+
+	source := newAWSCredentialSource()
+	for i := 0; i < 2; i++ {
+		creds, err := source.get(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to get credentials: %v", err)
+		}
+		if creds.AccessKeyID != "ASIA" || creds.SessionToken != "role-token" {
+			t.Errorf("Expected instance role credentials, got %+v", creds)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected credentials to be cached, fetched %d times", requests)
+	}
+}
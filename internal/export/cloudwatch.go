@@ -0,0 +1,144 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cloudWatchBatchSize is the most metrics PutMetricData accepts per call
+const cloudWatchBatchSize = 1000
+
+// CloudWatchExporter publishes per-agent metrics as CloudWatch custom
+// metrics:
+//
+//   - CPUUtilization and MemoryUtilization (Percent) by Agent
+//   - DiskUtilization (Percent) by Agent and MountPoint
+//   - ActiveAlerts (Count) by Agent, and for the whole fleet
+//
+// Credentials come from $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and
+// $AWS_SESSION_TOKEN, or the EC2 instance role, which needs
+// cloudwatch:PutMetricData.
+type CloudWatchExporter struct {
+	region    string
+	namespace string
+	endpoint  string
+	client    *http.Client
+	creds     *awsCredentialSource
+}
+
+// NewCloudWatchExporter creates a CloudWatch exporter. An empty endpoint
+// uses the regional CloudWatch endpoint.
+func NewCloudWatchExporter(region, namespace, endpoint string) *CloudWatchExporter {
+	if endpoint == "" {
+		endpoint = "https://monitoring." + region + ".amazonaws.com"
+	}
+	return &CloudWatchExporter{
+		region:    region,
+		namespace: namespace,
+		endpoint:  strings.TrimSuffix(endpoint, "/") + "/",
+		client:    &http.Client{Timeout: 10 * time.Second},
+		creds:     newAWSCredentialSource(),
+	}
+}
+
+// Name implements Exporter
+func (c *CloudWatchExporter) Name() string {
+	return "CloudWatch"
+}
+
+// cloudWatchDatum is one value of PutMetricData's MetricData
+type cloudWatchDatum struct {
+	name       string
+	unit       string
+	value      float64
+	dimensions [][2]string // Name, value
+}
+
+// Export implements Exporter
+func (c *CloudWatchExporter) Export(ctx context.Context, snapshot *Snapshot) error {
+	data := cloudWatchData(snapshot)
+	for start := 0; start < len(data); start += cloudWatchBatchSize {
+		end := min(start+cloudWatchBatchSize, len(data))
+		if err := c.put(ctx, data[start:end], snapshot.Time); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloudWatchData builds the metrics for a snapshot
+func cloudWatchData(snapshot *Snapshot) []cloudWatchDatum {
+	data := make([]cloudWatchDatum, 0)
+	alerts := 0
+	for _, agent := range snapshot.Agents {
+		alerts += len(agent.ActiveAlerts)
+		byAgent := [][2]string{{"Agent", agent.AgentName}}
+		data = append(data, cloudWatchDatum{"ActiveAlerts", "Count", float64(len(agent.ActiveAlerts)), byAgent})
+
+		if !reporting(agent) {
+			continue
+		}
+		m := agent.SystemMetrics
+		data = append(data,
+			cloudWatchDatum{"CPUUtilization", "Percent", m.CPU.UsagePercent, byAgent},
+			cloudWatchDatum{"MemoryUtilization", "Percent", m.Memory.UsedPercent, byAgent},
+		)
+		for _, disk := range m.Disk {
+			data = append(data, cloudWatchDatum{"DiskUtilization", "Percent", disk.UsedPercent,
+				[][2]string{{"Agent", agent.AgentName}, {"MountPoint", disk.MountPoint}}})
+		}
+	}
+	return append(data, cloudWatchDatum{"ActiveAlerts", "Count", float64(alerts), nil})
+}
+
+// put sends one PutMetricData call using the query API
+func (c *CloudWatchExporter) put(ctx context.Context, data []cloudWatchDatum, at time.Time) error {
+	form := url.Values{}
+	form.Set("Action", "PutMetricData")
+	form.Set("Version", "2010-08-01")
+	form.Set("Namespace", c.namespace)
+	timestamp := at.UTC().Format(time.RFC3339)
+	for i, d := range data {
+		prefix := "MetricData.member." + strconv.Itoa(i+1) + "."
+		form.Set(prefix+"MetricName", d.name)
+		form.Set(prefix+"Unit", d.unit)
+		form.Set(prefix+"Value", strconv.FormatFloat(d.value, 'f', -1, 64))
+		form.Set(prefix+"Timestamp", timestamp)
+		for j, dim := range d.dimensions {
+			dimPrefix := prefix + "Dimensions.member." + strconv.Itoa(j+1) + "."
+			form.Set(dimPrefix+"Name", dim[0])
+			form.Set(dimPrefix+"Value", dim[1])
+		}
+	}
+	body := []byte(form.Encode())
+
+	creds, err := c.creds.get(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signV4(req, body, creds, c.region, "monitoring", time.Now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("PutMetricData returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package export
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func testSnapshot() *Snapshot {
+	now := time.Now()
+	return &Snapshot{
+		Time: now,
+		Agents: []*server.ServerState{
+			{
+				AgentName:     "web-1",
+				Status:        "online",
+				LastMetricsAt: &now,
+				SystemMetrics: metrics.SystemMetrics{
+					CPU:    metrics.CPUMetrics{UsagePercent: 42.5},
+					Memory: metrics.MemoryMetrics{UsedPercent: 61},
+					Disk:   []metrics.DiskMetrics{{MountPoint: "/", UsedPercent: 70}},
+				},
+				ActiveAlerts: []server.Alert{{ID: "a1"}, {ID: "a2"}},
+			},
+			{
+				// Stale metrics aren't published, alert counts are
+				AgentName:     "web-2",
+				Status:        "offline",
+				LastMetricsAt: &now,
+				SystemMetrics: metrics.SystemMetrics{CPU: metrics.CPUMetrics{UsagePercent: 99}},
+				ActiveAlerts:  []server.Alert{{ID: "a3"}},
+			},
+		},
+	}
+}
+
+func TestCloudWatchExporter_Export(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	var form url.Values
+	var auth string
+	cw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		form, _ = url.ParseQuery(string(body))
+	}))
+	defer cw.Close()
+
+	exporter := NewCloudWatchExporter("eu-west-1", "Saviour", cw.URL)
+	if err := exporter.Export(context.Background(), testSnapshot()); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/monitoring/aws4_request") {
+		t.Errorf("Expected a SigV4 signature for monitoring in eu-west-1, got %q", auth)
+	}
+	if form.Get("Action") != "PutMetricData" || form.Get("Namespace") != "Saviour" {
+		t.Errorf("Expected PutMetricData to namespace Saviour, got %v", form)
+	}
+
+	// web-1: alerts, CPU, memory, disk; web-2: alerts; fleet alerts
+	values := map[string]string{}
+	for i := 1; form.Get("MetricData.member."+strconv.Itoa(i)+".MetricName") != ""; i++ {
+		prefix := "MetricData.member." + strconv.Itoa(i) + "."
+		key := form.Get(prefix + "MetricName")
+		for j := 1; form.Get(prefix+"Dimensions.member."+strconv.Itoa(j)+".Name") != ""; j++ {
+			key += ":" + form.Get(prefix+"Dimensions.member."+strconv.Itoa(j)+".Value")
+		}
+		values[key] = form.Get(prefix + "Value")
+	}
+	want := map[string]string{
+		"ActiveAlerts:web-1":      "2",
+		"CPUUtilization:web-1":    "42.5",
+		"MemoryUtilization:web-1": "61",
+		"DiskUtilization:web-1:/": "70",
+		"ActiveAlerts:web-2":      "1",
+		"ActiveAlerts":            "3",
+	}
+	if len(values) != len(want) {
+		t.Errorf("Expected %d metrics, got %v", len(want), values)
+	}
+	for key, value := range want {
+		if values[key] != value {
+			t.Errorf("%s: expected %s, got %q", key, value, values[key])
+		}
+	}
+}
+
+func TestCloudWatchExporter_Error(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	cw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	}))
+	defer cw.Close()
+
+	exporter := NewCloudWatchExporter("eu-west-1", "Saviour", cw.URL)
+	err := exporter.Export(context.Background(), testSnapshot())
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Expected the CloudWatch error to be returned, got %v", err)
+	}
+}
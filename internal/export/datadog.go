@@ -0,0 +1,184 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// datadogBatchSize keeps each submission well under the series API's
+	// 5MB limit
+	datadogBatchSize = 1000
+
+	// datadogGauge is the gauge metric type in the v2 series API
+	datadogGauge = 3
+)
+
+// DatadogExporter forwards fleet metrics to the Datadog series API. Every
+// series is tagged with the agent and where it runs (provider, region,
+// zone, instance type and the instance's tags), plus any static tags:
+//
+//   - saviour.agent.up: 1 while the agent reports, 0 otherwise
+//   - saviour.cpu.usage_percent, saviour.memory.used_percent
+//   - saviour.disk.used_percent, tagged mount
+//   - saviour.container.cpu_percent and saviour.container.memory_percent,
+//     tagged container
+//   - saviour.alerts.active
+type DatadogExporter struct {
+	apiKey   string
+	endpoint string
+	tags     []string
+	client   *http.Client
+}
+
+// NewDatadogExporter creates a Datadog exporter for a site such as
+// datadoghq.com or datadoghq.eu. tags are added to every series.
+func NewDatadogExporter(apiKey, site string, tags []string) *DatadogExporter {
+	return &DatadogExporter{
+		apiKey:   apiKey,
+		endpoint: "https://api." + site + "/api/v2/series",
+		tags:     tags,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name implements Exporter
+func (d *DatadogExporter) Name() string {
+	return "Datadog"
+}
+
+// datadogSeries is one series of the v2 series API
+type datadogSeries struct {
+	Metric    string            `json:"metric"`
+	Type      int               `json:"type"`
+	Points    []datadogPoint    `json:"points"`
+	Tags      []string          `json:"tags,omitempty"`
+	Resources []datadogResource `json:"resources,omitempty"`
+}
+
+type datadogPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+type datadogResource struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Export implements Exporter
+func (d *DatadogExporter) Export(ctx context.Context, snapshot *Snapshot) error {
+	series := d.series(snapshot)
+	for start := 0; start < len(series); start += datadogBatchSize {
+		end := min(start+datadogBatchSize, len(series))
+		if err := d.submit(ctx, series[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// series builds the series for a snapshot
+func (d *DatadogExporter) series(snapshot *Snapshot) []datadogSeries {
+	timestamp := snapshot.Time.Unix()
+	series := make([]datadogSeries, 0)
+	for _, agent := range snapshot.Agents {
+		tags := append(datadogTags(agentTags(agent)), "agent:"+datadogTag(agent.AgentName))
+		tags = append(tags, d.tags...)
+		host := []datadogResource{{Name: agent.AgentName, Type: "host"}}
+		gauge := func(metric string, value float64, extra ...string) {
+			series = append(series, datadogSeries{
+				Metric:    metric,
+				Type:      datadogGauge,
+				Points:    []datadogPoint{{Timestamp: timestamp, Value: value}},
+				Tags:      append(append([]string{}, tags...), extra...),
+				Resources: host,
+			})
+		}
+
+		up := 0.0
+		if reporting(agent) {
+			up = 1
+		}
+		gauge("saviour.agent.up", up)
+		gauge("saviour.alerts.active", float64(len(agent.ActiveAlerts)))
+		if !reporting(agent) {
+			continue
+		}
+
+		m := agent.SystemMetrics
+		gauge("saviour.cpu.usage_percent", m.CPU.UsagePercent)
+		gauge("saviour.memory.used_percent", m.Memory.UsedPercent)
+		for _, disk := range m.Disk {
+			gauge("saviour.disk.used_percent", disk.UsedPercent, "mount:"+datadogTag(disk.MountPoint))
+		}
+		for _, c := range agent.Containers {
+			if c.State != "running" {
+				continue
+			}
+			container := "container:" + datadogTag(c.Name)
+			gauge("saviour.container.cpu_percent", c.CPUPercent, container)
+			gauge("saviour.container.memory_percent", c.MemoryPercent, container)
+		}
+	}
+	return series
+}
+
+// submit posts one batch of series
+func (d *DatadogExporter) submit(ctx context.Context, series []datadogSeries) error {
+	body, err := json.Marshal(map[string]interface{}{"series": series})
+	if err != nil {
+		return fmt.Errorf("failed to marshal series: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("DD-API-KEY", d.apiKey)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send series: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Datadog returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// datadogTags turns key/value tags into sorted key:value Datadog tags
+func datadogTags(tags map[string]string) []string {
+	out := make([]string, 0, len(tags))
+	for k, v := range tags {
+		out = append(out, datadogTag(k)+":"+datadogTag(v))
+	}
+	sort.Strings(out)
+	return out
+}
+
+// datadogTag lowercases a tag key or value and replaces characters Datadog
+// doesn't allow with underscores
+func datadogTag(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', strings.ContainsRune("_-:./", r):
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anurag/saviour/internal/server"
+)
+
+func TestDatadogExporter_Export(t *testing.T) {
+	var apiKey string
+	var body struct {
+		Series []datadogSeries `json:"series"`
+	}
+	dd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("DD-API-KEY")
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer dd.Close()
+
+	snapshot := testSnapshot()
+	snapshot.Agents[0].Cloud = &server.CloudMetadata{
+		Provider: "aws",
+		Region:   "eu-west-1",
+		Tags:     map[string]string{"Team": "Payments API"},
+	}
+	snapshot.Agents[0].Containers = []server.ContainerState{
+		{Name: "api", State: "running", CPUPercent: 12},
+		{Name: "old", State: "exited"},
+	}
+
+	exporter := NewDatadogExporter("dd-key", "datadoghq.eu", []string{"env:prod"})
+	exporter.endpoint = dd.URL
+	if err := exporter.Export(context.Background(), snapshot); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	if apiKey != "dd-key" {
+		t.Errorf("Expected DD-API-KEY header, got %q", apiKey)
+	}
+
+	series := map[string]datadogSeries{}
+	for _, s := range body.Series {
+		key := s.Metric
+		for _, tag := range s.Tags {
+			if strings.HasPrefix(tag, "agent:") || strings.HasPrefix(tag, "mount:") || strings.HasPrefix(tag, "container:") {
+				key += "," + tag
+			}
+		}
+		series[key] = s
+	}
+
+	cpu, ok := series["saviour.cpu.usage_percent,agent:web-1"]
+	if !ok || cpu.Points[0].Value != 42.5 || cpu.Type != datadogGauge {
+		t.Fatalf("Expected a CPU gauge of 42.5 for web-1, got %+v", cpu)
+	}
+	for _, tag := range []string{"provider:aws", "region:eu-west-1", "team:payments_api", "env:prod"} {
+		if !containsTag(cpu.Tags, tag) {
+			t.Errorf("Expected tag %s, got %v", tag, cpu.Tags)
+		}
+	}
+	if len(cpu.Resources) != 1 || cpu.Resources[0].Name != "web-1" {
+		t.Errorf("Expected host resource web-1, got %v", cpu.Resources)
+	}
+
+	if _, ok := series["saviour.disk.used_percent,agent:web-1,mount:/"]; !ok {
+		t.Error("Expected a disk gauge tagged with its mount")
+	}
+	if _, ok := series["saviour.container.cpu_percent,agent:web-1,container:api"]; !ok {
+		t.Error("Expected a gauge for the running container")
+	}
+	if _, ok := series["saviour.container.cpu_percent,agent:web-1,container:old"]; ok {
+		t.Error("Expected no gauge for the exited container")
+	}
+
+	// Offline agents are down, with no stale metrics
+	if up := series["saviour.agent.up,agent:web-2"]; len(up.Points) != 1 || up.Points[0].Value != 0 {
+		t.Errorf("Expected web-2 down, got %+v", up)
+	}
+	if _, ok := series["saviour.cpu.usage_percent,agent:web-2"]; ok {
+		t.Error("Expected no CPU gauge for the offline agent")
+	}
+}
+
+func TestDatadogExporter_Error(t *testing.T) {
+	dd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors":["Forbidden"]}`, http.StatusForbidden)
+	}))
+	defer dd.Close()
+
+	exporter := NewDatadogExporter("bad-key", "datadoghq.com", nil)
+	exporter.endpoint = dd.URL
+	if err := exporter.Export(context.Background(), testSnapshot()); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Expected a 403 error, got %v", err)
+	}
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+// Package export publishes fleet metrics from the server's state store to
+// external monitoring systems, so existing dashboards and alarms can use
+// Saviour data.
+package export
+
+import (
+	"context"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// Exporter publishes a snapshot of the fleet to an external system
+type Exporter interface {
+	// Name identifies the exporter in logs
+	Name() string
+	Export(ctx context.Context, snapshot *Snapshot) error
+}
+
+// Snapshot is the fleet as of one export
+type Snapshot struct {
+	Time   time.Time
+	Agents []*server.ServerState
+}
+
+// TakeSnapshot copies the current state of every agent
+func TakeSnapshot(store *server.StateStore) *Snapshot {
+	return &Snapshot{
+		Time:   time.Now(),
+		Agents: store.GetAllAgents(),
+	}
+}
+
+// reporting is true for agents whose metrics are current. Offline and
+// stopped agents keep their last metrics, which shouldn't be published as
+// if they were new.
+func reporting(agent *server.ServerState) bool {
+	return agent.LastMetricsAt != nil && (agent.Status == "online" || agent.Status == "degraded")
+}
+
+// agentTags describes where an agent runs: its cloud provider, region,
+// zone and instance type, plus the instance's tags
+func agentTags(agent *server.ServerState) map[string]string {
+	tags := make(map[string]string)
+	if agent.Cloud == nil {
+		return tags
+	}
+	for k, v := range agent.Cloud.Tags {
+		tags[k] = v
+	}
+	for k, v := range map[string]string{
+		"provider":      agent.Cloud.Provider,
+		"region":        agent.Cloud.Region,
+		"zone":          agent.Cloud.Zone,
+		"instance_type": agent.Cloud.InstanceType,
+	} {
+		if v != "" {
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+// Run exports a snapshot of the store every interval until ctx is done.
+// Failures are logged and retried at the next interval.
+func Run(ctx context.Context, store *server.StateStore, exporter Exporter, interval time.Duration) {
+	logger := logging.Component("export").With("exporter", exporter.Name())
+	logger.Info("Exporting fleet metrics", "interval", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			exportCtx, cancel := context.WithTimeout(ctx, interval)
+			if err := exporter.Export(exportCtx, TakeSnapshot(store)); err != nil {
+				logger.Error("Export failed", logging.Err(err))
+			}
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// GraphiteExporter sends fleet metrics to a Graphite carbon daemon over the
+// plaintext protocol. Paths are <prefix>.<agent>.<metric>:
+//
+//   - up: 1 while the agent reports, 0 otherwise
+//   - alerts.active
+//   - cpu.usage_percent, load.1, load.5, load.15
+//   - memory.used_percent, swap.used_percent
+//   - disk.<mount>.used_percent, "/" being root
+//   - containers.<name>.cpu_percent and containers.<name>.memory_percent
+type GraphiteExporter struct {
+	address string
+	prefix  string
+}
+
+// NewGraphiteExporter creates an exporter for the carbon daemon at
+// host:port. prefix starts every path, e.g. "saviour".
+func NewGraphiteExporter(host string, port int, prefix string) *GraphiteExporter {
+	return &GraphiteExporter{
+		address: net.JoinHostPort(host, strconv.Itoa(port)),
+		prefix:  strings.Trim(prefix, "."),
+	}
+}
+
+// Name implements Exporter
+func (g *GraphiteExporter) Name() string {
+	return "Graphite"
+}
+
+// Export implements Exporter. Each export uses its own connection, carbon
+// handles short-lived ones fine and there is nothing to keep alive between
+// intervals.
+func (g *GraphiteExporter) Export(ctx context.Context, snapshot *Snapshot) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", g.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to carbon: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	w := bufio.NewWriter(conn)
+	g.write(w, snapshot)
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to send metrics: %w", err)
+	}
+	return nil
+}
+
+// write writes a snapshot as plaintext protocol lines
+func (g *GraphiteExporter) write(w *bufio.Writer, snapshot *Snapshot) {
+	timestamp := strconv.FormatInt(snapshot.Time.Unix(), 10)
+	for _, agent := range snapshot.Agents {
+		base := graphitePath(agent.AgentName)
+		if g.prefix != "" {
+			base = g.prefix + "." + base
+		}
+		line := func(path string, value float64) {
+			w.WriteString(base + "." + path + " " + strconv.FormatFloat(value, 'f', -1, 64) + " " + timestamp + "\n")
+		}
+
+		up := 0.0
+		if reporting(agent) {
+			up = 1
+		}
+		line("up", up)
+		line("alerts.active", float64(len(agent.ActiveAlerts)))
+		if !reporting(agent) {
+			continue
+		}
+
+		m := agent.SystemMetrics
+		line("cpu.usage_percent", m.CPU.UsagePercent)
+		line("load.1", m.CPU.LoadAvg1)
+		line("load.5", m.CPU.LoadAvg5)
+		line("load.15", m.CPU.LoadAvg15)
+		line("memory.used_percent", m.Memory.UsedPercent)
+		line("swap.used_percent", m.Memory.SwapPercent)
+		for _, disk := range m.Disk {
+			mount := strings.Trim(disk.MountPoint, "/")
+			if mount == "" {
+				mount = "root"
+			}
+			line("disk."+graphitePath(mount)+".used_percent", disk.UsedPercent)
+		}
+		for _, c := range agent.Containers {
+			if c.State != "running" {
+				continue
+			}
+			container := "containers." + graphitePath(c.Name)
+			line(container+".cpu_percent", c.CPUPercent)
+			line(container+".memory_percent", c.MemoryPercent)
+		}
+	}
+}
+
+// graphitePath makes a name usable as one path node: dots would split it
+// and whitespace would end the path, so they and other awkward characters
+// become underscores
+func graphitePath(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,93 @@
+package export
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func TestGraphiteExporter_Export(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	snapshot := testSnapshot()
+	snapshot.Agents[0].AgentName = "web-1.prod"
+	snapshot.Agents[0].SystemMetrics.Disk = append(snapshot.Agents[0].SystemMetrics.Disk,
+		metrics.DiskMetrics{MountPoint: "/var/lib/docker", UsedPercent: 30})
+	snapshot.Agents[0].Containers = []server.ContainerState{
+		{Name: "api", State: "running", CPUPercent: 12},
+		{Name: "job", State: "exited"},
+	}
+
+	addr := listener.Addr().(*net.TCPAddr)
+	exporter := NewGraphiteExporter("127.0.0.1", addr.Port, "saviour.")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := exporter.Export(ctx, snapshot); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var text string
+	select {
+	case text = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for metrics")
+	}
+
+	ts := " " + strconv.FormatInt(snapshot.Time.Unix(), 10) + "\n"
+	for _, want := range []string{
+		"saviour.web-1_prod.up 1" + ts,
+		"saviour.web-1_prod.alerts.active 2" + ts,
+		"saviour.web-1_prod.cpu.usage_percent 42.5" + ts,
+		"saviour.web-1_prod.disk.root.used_percent 70" + ts,
+		"saviour.web-1_prod.disk.var_lib_docker.used_percent 30" + ts,
+		"saviour.web-1_prod.containers.api.cpu_percent 12" + ts,
+		"saviour.web-2.up 0" + ts,
+		"saviour.web-2.alerts.active 1" + ts,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "saviour.web-2.cpu") {
+		t.Error("Expected no metrics for an agent that isn't reporting")
+	}
+	if strings.Contains(text, "containers.job") {
+		t.Error("Expected no metrics for a stopped container")
+	}
+}
+
+func TestGraphiteExporter_ConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	exporter := NewGraphiteExporter("127.0.0.1", port, "saviour")
+	if err := exporter.Export(context.Background(), testSnapshot()); err == nil {
+		t.Error("Expected an error when carbon is unreachable")
+	}
+}
@@ -0,0 +1,252 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+const (
+	// influxQueueSize bounds the pushes waiting to be written; beyond it
+	// pushes are dropped rather than blocking the push handler
+	influxQueueSize = 1024
+
+	// Points are written in batches of up to influxBatchLines, at least
+	// every influxFlushInterval
+	influxBatchLines    = 5000
+	influxFlushInterval = time.Second
+)
+
+// InfluxWriter writes every accepted metrics push to an InfluxDB v2 bucket
+// as line protocol. Points are tagged with the agent and where it runs:
+//
+//   - saviour_system: CPU, load, memory and swap
+//   - saviour_disk: usage per mount, tagged mount
+//   - saviour_container: CPU, memory and restarts per container, tagged
+//     container and image
+type InfluxWriter struct {
+	endpoint string
+	token    string
+	store    *server.StateStore
+	client   *http.Client
+	queue    chan string // Agents that pushed
+}
+
+// NewInfluxWriter creates a writer for the bucket in org at url, e.g.
+// http://influxdb:8086
+func NewInfluxWriter(rawURL, org, bucket, token string, store *server.StateStore) *InfluxWriter {
+	query := url.Values{}
+	query.Set("org", org)
+	query.Set("bucket", bucket)
+	query.Set("precision", "ns")
+	return &InfluxWriter{
+		endpoint: strings.TrimSuffix(rawURL, "/") + "/api/v2/write?" + query.Encode(),
+		token:    token,
+		store:    store,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		queue:    make(chan string, influxQueueSize),
+	}
+}
+
+// Enqueue schedules an agent's latest push to be written. It never blocks,
+// so it can be registered with the handler's OnMetricsPush.
+func (w *InfluxWriter) Enqueue(agentName string) {
+	select {
+	case w.queue <- agentName:
+	default:
+		logging.Component("export").Warn("InfluxDB write queue full, dropping push", "agent", agentName)
+	}
+}
+
+// Run writes queued pushes until ctx is done
+func (w *InfluxWriter) Run(ctx context.Context) {
+	logger := logging.Component("export").With("exporter", "influxdb")
+	logger.Info("Writing metrics pushes to InfluxDB")
+
+	ticker := time.NewTicker(influxFlushInterval)
+	defer ticker.Stop()
+
+	var batch bytes.Buffer
+	lines := 0
+	flush := func() {
+		if lines == 0 {
+			return
+		}
+		if err := w.write(ctx, batch.Bytes()); err != nil {
+			logger.Error("InfluxDB write failed", "points", lines, logging.Err(err))
+		}
+		batch.Reset()
+		lines = 0
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case agentName := <-w.queue:
+			agent, exists := w.store.GetAgent(agentName)
+			if !exists {
+				continue
+			}
+			lines += writeInfluxLines(&batch, agent)
+			if lines >= influxBatchLines {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// write sends a batch of line protocol
+func (w *InfluxWriter) write(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Authorization", "Token "+w.token)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send points: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("InfluxDB returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// writeInfluxLines appends an agent's latest metrics as line protocol and
+// returns how many lines it wrote
+func writeInfluxLines(buf *bytes.Buffer, agent *server.ServerState) int {
+	if agent.LastMetricsAt == nil {
+		return 0
+	}
+	m := agent.SystemMetrics
+	at := m.Timestamp
+	if at.IsZero() {
+		at = *agent.LastMetricsAt
+	}
+	timestamp := strconv.FormatInt(at.UnixNano(), 10)
+
+	tags := agentTags(agent)
+	tags["agent"] = agent.AgentName
+	lines := 0
+	line := func(measurement string, extraTags map[string]string, fields []influxField) {
+		buf.WriteString(influxEscape(measurement, ", "))
+		for _, kv := range influxTagSet(tags, extraTags) {
+			buf.WriteString("," + kv)
+		}
+		for i, f := range fields {
+			if i == 0 {
+				buf.WriteByte(' ')
+			} else {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(influxEscape(f.key, ",= ") + "=" + f.value)
+		}
+		buf.WriteString(" " + timestamp + "\n")
+		lines++
+	}
+
+	line("saviour_system", nil, []influxField{
+		floatField("cpu_usage_percent", m.CPU.UsagePercent),
+		floatField("load_avg_1", m.CPU.LoadAvg1),
+		floatField("load_avg_5", m.CPU.LoadAvg5),
+		floatField("load_avg_15", m.CPU.LoadAvg15),
+		floatField("memory_used_percent", m.Memory.UsedPercent),
+		intField("memory_used_bytes", int64(m.Memory.Used)),
+		intField("memory_total_bytes", int64(m.Memory.Total)),
+		floatField("swap_used_percent", m.Memory.SwapPercent),
+	})
+	for _, disk := range m.Disk {
+		line("saviour_disk", map[string]string{"mount": disk.MountPoint}, []influxField{
+			floatField("used_percent", disk.UsedPercent),
+			intField("used_bytes", int64(disk.Used)),
+			intField("total_bytes", int64(disk.Total)),
+		})
+	}
+	for _, c := range agent.Containers {
+		line("saviour_container", map[string]string{"container": c.Name, "image": c.Image}, []influxField{
+			floatField("cpu_percent", c.CPUPercent),
+			floatField("memory_percent", c.MemoryPercent),
+			intField("memory_usage_bytes", int64(c.MemoryUsage)),
+			intField("restart_count", int64(c.RestartCount)),
+			stringField("state", c.State),
+		})
+	}
+	return lines
+}
+
+// influxField is a field key and its formatted value
+type influxField struct {
+	key   string
+	value string
+}
+
+func floatField(key string, v float64) influxField {
+	return influxField{key, strconv.FormatFloat(v, 'f', -1, 64)}
+}
+
+func intField(key string, v int64) influxField {
+	return influxField{key, strconv.FormatInt(v, 10) + "i"}
+}
+
+func stringField(key, v string) influxField {
+	return influxField{key, `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`}
+}
+
+// influxTagSet returns escaped key=value pairs sorted by key, as InfluxDB
+// recommends. Empty values aren't allowed and are left out.
+func influxTagSet(tags, extra map[string]string) []string {
+	merged := make(map[string]string, len(tags)+len(extra))
+	for k, v := range tags {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+
+	keys := make([]string, 0, len(merged))
+	for k, v := range merged {
+		if k != "" && v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	set := make([]string, len(keys))
+	for i, k := range keys {
+		set[i] = influxEscape(k, ",= ") + "=" + influxEscape(merged[k], ",= ")
+	}
+	return set
+}
+
+// influxEscape backslash-escapes the given characters, and backslashes
+func influxEscape(s, chars string) string {
+	if !strings.ContainsAny(s, chars+`\`) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\\' || strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
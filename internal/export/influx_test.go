@@ -0,0 +1,98 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func TestWriteInfluxLines(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	agent := &server.ServerState{
+		AgentName:     "web 1",
+		LastMetricsAt: &at,
+		Cloud:         &server.CloudMetadata{Region: "eu-west-1", Tags: map[string]string{"Team": "a,b=c"}},
+		SystemMetrics: metrics.SystemMetrics{
+			Timestamp: at,
+			CPU:       metrics.CPUMetrics{UsagePercent: 42.5},
+			Memory:    metrics.MemoryMetrics{UsedPercent: 61, Used: 1024},
+			Disk:      []metrics.DiskMetrics{{MountPoint: "/data", UsedPercent: 70}},
+		},
+		Containers: []server.ContainerState{{Name: "api", Image: "api:1", State: "running", CPUPercent: 12, RestartCount: 2}},
+	}
+
+	var buf bytes.Buffer
+	if n := writeInfluxLines(&buf, agent); n != 3 {
+		t.Fatalf("Expected 3 lines, got %d", n)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	system := lines[0]
+	if !strings.HasPrefix(system, `saviour_system,Team=a\,b\=c,agent=web\ 1,region=eu-west-1 cpu_usage_percent=42.5,`) {
+		t.Errorf("Unexpected system line: %s", system)
+	}
+	if !strings.Contains(system, "memory_used_bytes=1024i") || !strings.HasSuffix(system, " 1700000000000000000") {
+		t.Errorf("Expected integer fields and a nanosecond timestamp: %s", system)
+	}
+	if !strings.HasPrefix(lines[1], `saviour_disk,Team=a\,b\=c,agent=web\ 1,mount=/data,region=eu-west-1 used_percent=70,`) {
+		t.Errorf("Unexpected disk line: %s", lines[1])
+	}
+	if !strings.Contains(lines[2], "container=api,image=api:1,") || !strings.Contains(lines[2], `restart_count=2i,state="running"`) {
+		t.Errorf("Unexpected container line: %s", lines[2])
+	}
+
+	// Agents that never pushed have nothing to write
+	buf.Reset()
+	if n := writeInfluxLines(&buf, &server.ServerState{AgentName: "idle"}); n != 0 || buf.Len() != 0 {
+		t.Errorf("Expected no lines for an agent without metrics, got %q", buf.String())
+	}
+}
+
+func TestInfluxWriter_Run(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	influx := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer influx.Close()
+
+	store := server.NewStateStore()
+	store.UpdateAgent(&server.ServerState{
+		AgentName:     "web-1",
+		SystemMetrics: metrics.SystemMetrics{Timestamp: time.Now(), CPU: metrics.CPUMetrics{UsagePercent: 10}},
+	})
+
+	writer := NewInfluxWriter(influx.URL, "ops", "saviour", "secret", store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go writer.Run(ctx)
+
+	writer.Enqueue("web-1")
+	writer.Enqueue("unknown")
+
+	select {
+	case r := <-received:
+		if r.Header.Get("Authorization") != "Token secret" {
+			t.Errorf("Expected token auth, got %q", r.Header.Get("Authorization"))
+		}
+		if q := r.URL.Query(); q.Get("org") != "ops" || q.Get("bucket") != "saviour" || q.Get("precision") != "ns" {
+			t.Errorf("Unexpected write query: %s", r.URL.RawQuery)
+		}
+		if body := <-bodies; !strings.HasPrefix(body, "saviour_system,agent=web-1 cpu_usage_percent=10,") {
+			t.Errorf("Unexpected points: %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected points to be written")
+	}
+}
@@ -0,0 +1,134 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// promFamily is one metric family of the Prometheus text format
+type promFamily struct {
+	name    string
+	help    string
+	samples []string
+}
+
+// FleetMetricsHandler serves the latest gauges of every agent in the
+// Prometheus text format, so one scrape covers the whole fleet. Every
+// series is labelled agent; disk series add mount, container series add
+// container. Agents that aren't reporting only get saviour_agent_up 0.
+func FleetMetricsHandler(store *server.StateStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		buf := bufio.NewWriter(w)
+		writeFleetMetrics(buf, TakeSnapshot(store))
+		if err := buf.Flush(); err != nil {
+			logging.Component("export").Error("Error writing fleet metrics", logging.Err(err))
+		}
+	})
+}
+
+// writeFleetMetrics writes a snapshot in the Prometheus text format
+func writeFleetMetrics(w *bufio.Writer, snapshot *Snapshot) {
+	families := []*promFamily{
+		{name: "saviour_agent_up", help: "1 while the agent reports metrics, 0 otherwise."},
+		{name: "saviour_alerts_active", help: "Active alerts of the agent."},
+		{name: "saviour_agent_health_score", help: "0-100 health score of the agent, 0 while it isn't reporting."},
+		{name: "saviour_cpu_usage_percent", help: "CPU usage in percent."},
+		{name: "saviour_load1", help: "1-minute load average."},
+		{name: "saviour_load5", help: "5-minute load average."},
+		{name: "saviour_load15", help: "15-minute load average."},
+		{name: "saviour_memory_used_percent", help: "Memory used in percent."},
+		{name: "saviour_memory_used_bytes", help: "Memory used in bytes."},
+		{name: "saviour_memory_total_bytes", help: "Total memory in bytes."},
+		{name: "saviour_swap_used_percent", help: "Swap used in percent."},
+		{name: "saviour_disk_used_percent", help: "Disk used in percent, by mount."},
+		{name: "saviour_disk_used_bytes", help: "Disk used in bytes, by mount."},
+		{name: "saviour_disk_total_bytes", help: "Disk size in bytes, by mount."},
+		{name: "saviour_container_state", help: "1 for the container's current state (running, exited, ...)."},
+		{name: "saviour_container_cpu_percent", help: "Container CPU usage in percent."},
+		{name: "saviour_container_memory_percent", help: "Container memory used in percent of its limit."},
+		{name: "saviour_container_memory_usage_bytes", help: "Container memory used in bytes."},
+		{name: "saviour_container_restarts", help: "Container restart count."},
+	}
+	byName := make(map[string]*promFamily, len(families))
+	for _, f := range families {
+		byName[f.name] = f
+	}
+	add := func(name string, value float64, labels ...string) {
+		f := byName[name]
+		f.samples = append(f.samples, name+promLabels(labels)+" "+strconv.FormatFloat(value, 'g', -1, 64))
+	}
+
+	for _, agent := range snapshot.Agents {
+		a := agent.AgentName
+		up := 0.0
+		if reporting(agent) {
+			up = 1
+		}
+		add("saviour_agent_up", up, "agent", a)
+		add("saviour_alerts_active", float64(len(agent.ActiveAlerts)), "agent", a)
+		add("saviour_agent_health_score", float64(agent.HealthAt(snapshot.Time)), "agent", a)
+		if !reporting(agent) {
+			continue
+		}
+
+		m := agent.SystemMetrics
+		add("saviour_cpu_usage_percent", m.CPU.UsagePercent, "agent", a)
+		add("saviour_load1", m.CPU.LoadAvg1, "agent", a)
+		add("saviour_load5", m.CPU.LoadAvg5, "agent", a)
+		add("saviour_load15", m.CPU.LoadAvg15, "agent", a)
+		add("saviour_memory_used_percent", m.Memory.UsedPercent, "agent", a)
+		add("saviour_memory_used_bytes", float64(m.Memory.Used), "agent", a)
+		add("saviour_memory_total_bytes", float64(m.Memory.Total), "agent", a)
+		add("saviour_swap_used_percent", m.Memory.SwapPercent, "agent", a)
+		for _, disk := range m.Disk {
+			add("saviour_disk_used_percent", disk.UsedPercent, "agent", a, "mount", disk.MountPoint)
+			add("saviour_disk_used_bytes", float64(disk.Used), "agent", a, "mount", disk.MountPoint)
+			add("saviour_disk_total_bytes", float64(disk.Total), "agent", a, "mount", disk.MountPoint)
+		}
+		for _, c := range agent.Containers {
+			add("saviour_container_state", 1, "agent", a, "container", c.Name, "state", c.State)
+			add("saviour_container_restarts", float64(c.RestartCount), "agent", a, "container", c.Name)
+			if c.State != "running" {
+				continue
+			}
+			add("saviour_container_cpu_percent", c.CPUPercent, "agent", a, "container", c.Name)
+			add("saviour_container_memory_percent", c.MemoryPercent, "agent", a, "container", c.Name)
+			add("saviour_container_memory_usage_bytes", float64(c.MemoryUsage), "agent", a, "container", c.Name)
+		}
+	}
+
+	for _, f := range families {
+		if len(f.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", f.name, f.help, f.name)
+		for _, sample := range f.samples {
+			w.WriteString(sample + "\n")
+		}
+	}
+}
+
+// promLabels formats name/value pairs as a label set
+func promLabels(pairs []string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+escape.Replace(pairs[i+1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
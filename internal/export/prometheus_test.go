@@ -0,0 +1,83 @@
+package export
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func TestWriteFleetMetrics(t *testing.T) {
+	snapshot := testSnapshot()
+	snapshot.Agents[0].Containers = []server.ContainerState{
+		{Name: "api", State: "running", CPUPercent: 12, RestartCount: 1},
+		{Name: `odd"name`, State: "exited"},
+	}
+
+	var out strings.Builder
+	buf := bufio.NewWriter(&out)
+	writeFleetMetrics(buf, snapshot)
+	buf.Flush()
+	text := out.String()
+
+	for _, want := range []string{
+		"# TYPE saviour_agent_up gauge\n",
+		`saviour_agent_up{agent="web-1"} 1`,
+		`saviour_agent_up{agent="web-2"} 0`,
+		`saviour_alerts_active{agent="web-2"} 1`,
+		`saviour_cpu_usage_percent{agent="web-1"} 42.5`,
+		`saviour_disk_used_percent{agent="web-1",mount="/"} 70`,
+		`saviour_container_state{agent="web-1",container="api",state="running"} 1`,
+		`saviour_container_cpu_percent{agent="web-1",container="api"} 12`,
+		`saviour_container_restarts{agent="web-1",container="api"} 1`,
+		`saviour_container_state{agent="web-1",container="odd\"name",state="exited"} 1`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, text)
+		}
+	}
+
+	if strings.Contains(text, `saviour_cpu_usage_percent{agent="web-2"}`) {
+		t.Error("Expected no metrics for an agent that isn't reporting")
+	}
+	if strings.Contains(text, `saviour_container_cpu_percent{agent="web-1",container="odd\"name"}`) {
+		t.Error("Expected no usage metrics for a stopped container")
+	}
+
+	// Each family is written once, with all of its samples together
+	if n := strings.Count(text, "# TYPE saviour_agent_up "); n != 1 {
+		t.Errorf("Expected saviour_agent_up to be declared once, got %d", n)
+	}
+}
+
+func TestFleetMetricsHandler(t *testing.T) {
+	store := server.NewStateStore()
+	store.UpdateAgent(&server.ServerState{
+		AgentName:     "db-1",
+		SystemMetrics: metrics.SystemMetrics{Timestamp: time.Now(), CPU: metrics.CPUMetrics{UsagePercent: 5}},
+	})
+	handler := FleetMetricsHandler(store)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics/fleet", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Expected the Prometheus text format, got %s", ct)
+	}
+	if !strings.Contains(rec.Body.String(), `saviour_cpu_usage_percent{agent="db-1"} 5`) {
+		t.Errorf("Expected db-1 CPU usage, got:\n%s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/metrics/fleet", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,209 @@
+// Package federation relays the pushes and heartbeats a regional server
+// receives to an upstream Saviour server, so a global server can show every
+// region without agents sending to both. The upstream sees forwarded agents
+// as if they pushed to it directly.
+package federation
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// queueSize bounds what waits to be forwarded; beyond it pushes and
+// heartbeats are dropped rather than blocking the handler
+const queueSize = 1024
+
+// Upstream is the server to forward to, and what to forward
+type Upstream struct {
+	URL         string        // Base URL, e.g. https://saviour-global:8080
+	APIKey      string        // Needs the metrics:write and heartbeat:write scopes
+	Agents      []string      // Agent name patterns to forward, empty for all
+	AgentPrefix string        // Prepended to agent names upstream, e.g. "eu-west-1/"
+	Interval    time.Duration // Forward an agent's metrics at most this often (0 = every push)
+	Timeout     time.Duration // Per request
+}
+
+// item is a metrics push, by agent, or a heartbeat waiting to be forwarded
+type item struct {
+	agentName string
+	heartbeat *server.HeartbeatPayload
+}
+
+// Forwarder relays pushes and heartbeats to an upstream server
+type Forwarder struct {
+	logger   *slog.Logger
+	upstream Upstream
+	store    *server.StateStore
+	client   *http.Client
+	queue    chan item
+
+	lastForwarded map[string]time.Time // Per agent, for Interval; only used by Run
+	failing       bool                 // Last request failed; only used by Run
+}
+
+// New creates a forwarder reading pushed metrics from store
+func New(upstream Upstream, store *server.StateStore) *Forwarder {
+	upstream.URL = strings.TrimSuffix(upstream.URL, "/")
+	return &Forwarder{
+		logger:        logging.Component("federation"),
+		upstream:      upstream,
+		store:         store,
+		client:        &http.Client{Timeout: upstream.Timeout},
+		queue:         make(chan item, queueSize),
+		lastForwarded: make(map[string]time.Time),
+	}
+}
+
+// forwards reports whether an agent is forwarded
+func (f *Forwarder) forwards(agentName string) bool {
+	if len(f.upstream.Agents) == 0 {
+		return true
+	}
+	for _, pattern := range f.upstream.Agents {
+		if matched, _ := filepath.Match(pattern, agentName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// EnqueueMetrics schedules an agent's latest push to be forwarded. It never
+// blocks, so it can be registered with the handler's OnMetricsPush.
+func (f *Forwarder) EnqueueMetrics(agentName string) {
+	if f.forwards(agentName) {
+		f.enqueue(item{agentName: agentName})
+	}
+}
+
+// EnqueueHeartbeat schedules a heartbeat to be forwarded. It never blocks,
+// so it can be registered with the handler's OnHeartbeat.
+func (f *Forwarder) EnqueueHeartbeat(heartbeat server.HeartbeatPayload) {
+	if f.forwards(heartbeat.AgentName) {
+		f.enqueue(item{agentName: heartbeat.AgentName, heartbeat: &heartbeat})
+	}
+}
+
+func (f *Forwarder) enqueue(it item) {
+	select {
+	case f.queue <- it:
+	default:
+		f.logger.Warn("Federation queue full, dropping", "agent", it.agentName)
+	}
+}
+
+// Run forwards queued pushes and heartbeats until ctx is done. Failed ones
+// are dropped; the next push or heartbeat from the agent supersedes them.
+func (f *Forwarder) Run(ctx context.Context) {
+	f.logger.Info("Forwarding to upstream server", "url", f.upstream.URL, "interval", f.upstream.Interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case it := <-f.queue:
+			f.forward(ctx, it)
+		}
+	}
+}
+
+// forward sends one push or heartbeat upstream
+func (f *Forwarder) forward(ctx context.Context, it item) {
+	var path string
+	var body interface{}
+	if it.heartbeat != nil {
+		heartbeat := *it.heartbeat
+		heartbeat.AgentName = f.upstream.AgentPrefix + heartbeat.AgentName
+		path, body = "/api/v1/heartbeat", heartbeat
+	} else {
+		if last, ok := f.lastForwarded[it.agentName]; ok && time.Since(last) < f.upstream.Interval {
+			return
+		}
+		agent, exists := f.store.GetAgent(it.agentName)
+		if !exists || agent.LastMetricsAt == nil {
+			return
+		}
+		path, body = "/api/v1/metrics/push", f.payload(agent)
+	}
+
+	// Heartbeats are small, and sent plain like the agents send them
+	err := f.post(ctx, path, body, it.heartbeat == nil)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		if !f.failing {
+			f.logger.Warn("Failed to forward to upstream server", "url", f.upstream.URL, "agent", it.agentName, logging.Err(err))
+		}
+		f.failing = true
+		return
+	}
+	if f.failing {
+		f.logger.Info("Forwarding to upstream server again", "url", f.upstream.URL)
+	}
+	f.failing = false
+	if it.heartbeat == nil {
+		f.lastForwarded[it.agentName] = time.Now()
+	}
+	f.logger.Debug("Forwarded to upstream server", "path", path, "agent", it.agentName)
+}
+
+// payload rebuilds an agent's latest push from its state, with every
+// container, so the upstream never needs the push a delta was based on
+func (f *Forwarder) payload(agent *server.ServerState) server.MetricsPushPayload {
+	return server.MetricsPushPayload{
+		AgentName:     f.upstream.AgentPrefix + agent.AgentName,
+		Timestamp:     agent.SystemMetrics.Timestamp,
+		EC2Metadata:   agent.Cloud.EC2Metadata(),
+		CloudMetadata: agent.Cloud,
+		SystemMetrics: agent.SystemMetrics,
+		Sequence:      agent.PushSequence,
+	}
+}
+
+// post sends a body upstream as JSON, gzipped if compress is set
+func (f *Forwarder) post(ctx context.Context, path string, body interface{}, compress bool) error {
+	var buf bytes.Buffer
+	if compress {
+		gz := gzip.NewWriter(&buf)
+		if err := json.NewEncoder(gz).Encode(body); err != nil {
+			return fmt.Errorf("failed to encode: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress: %w", err)
+		}
+	} else if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return fmt.Errorf("failed to encode: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.upstream.URL+path, &buf)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("Authorization", "Bearer "+f.upstream.APIKey)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("upstream returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package federation
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+// upstream records what is forwarded to it with the API key "secret"
+type upstream struct {
+	mu         sync.Mutex
+	pushes     []server.MetricsPushPayload
+	heartbeats []server.HeartbeatPayload
+}
+
+func newUpstream(t *testing.T) (*upstream, string) {
+	t.Helper()
+	u := &upstream{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("Invalid gzip body: %v", err)
+				return
+			}
+			body = gz
+		}
+
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v1/metrics/push":
+			var payload server.MetricsPushPayload
+			json.NewDecoder(body).Decode(&payload)
+			u.pushes = append(u.pushes, payload)
+		case "/api/v1/heartbeat":
+			var payload server.HeartbeatPayload
+			json.NewDecoder(body).Decode(&payload)
+			u.heartbeats = append(u.heartbeats, payload)
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return u, ts.URL
+}
+
+// pushed stores a push from an agent with two containers
+func pushed(store *server.StateStore, agentName string) {
+	store.UpdateAgent(&server.ServerState{
+		AgentName: agentName,
+		SystemMetrics: metrics.SystemMetrics{
+			AgentName:  agentName,
+			Timestamp:  time.Now(),
+			CPU:        metrics.CPUMetrics{UsagePercent: 42},
+			Containers: []metrics.ContainerMetrics{{ID: "c1", Name: "api"}, {ID: "c2", Name: "worker"}},
+		},
+		Cloud:    &server.CloudMetadata{Provider: "aws", Region: "eu-west-1"},
+		LastSeen: time.Now(),
+	})
+}
+
+func TestForward_Metrics(t *testing.T) {
+	up, url := newUpstream(t)
+	store := server.NewStateStore()
+	f := New(Upstream{URL: url + "/", APIKey: "secret", AgentPrefix: "eu/", Interval: time.Minute, Timeout: 5 * time.Second}, store)
+
+	pushed(store, "web-1")
+	f.EnqueueMetrics("web-1")
+	f.forward(context.Background(), <-f.queue)
+
+	if len(up.pushes) != 1 {
+		t.Fatalf("Expected 1 forwarded push, got %d", len(up.pushes))
+	}
+	push := up.pushes[0]
+	if push.AgentName != "eu/web-1" || push.Delta || len(push.SystemMetrics.Containers) != 2 {
+		t.Errorf("Expected a full push for eu/web-1, got %s delta=%v with %d containers", push.AgentName, push.Delta, len(push.SystemMetrics.Containers))
+	}
+	if push.CloudMetadata == nil || push.CloudMetadata.Region != "eu-west-1" || push.EC2Metadata == nil {
+		t.Errorf("Expected the cloud metadata forwarded, got %+v", push.CloudMetadata)
+	}
+
+	// Pushes within the interval are not forwarded
+	pushed(store, "web-1")
+	f.EnqueueMetrics("web-1")
+	f.forward(context.Background(), <-f.queue)
+	if len(up.pushes) != 1 {
+		t.Errorf("Expected the second push downsampled, got %d forwarded", len(up.pushes))
+	}
+}
+
+func TestForward_Heartbeat(t *testing.T) {
+	up, url := newUpstream(t)
+	f := New(Upstream{URL: url, APIKey: "secret", AgentPrefix: "eu/", Timeout: 5 * time.Second}, server.NewStateStore())
+
+	f.EnqueueHeartbeat(server.HeartbeatPayload{AgentName: "web-1", Status: "terminating", Reason: "spot interruption"})
+	f.forward(context.Background(), <-f.queue)
+
+	if len(up.heartbeats) != 1 {
+		t.Fatalf("Expected 1 forwarded heartbeat, got %d", len(up.heartbeats))
+	}
+	if hb := up.heartbeats[0]; hb.AgentName != "eu/web-1" || hb.Status != "terminating" {
+		t.Errorf("Expected the terminating heartbeat for eu/web-1, got %+v", hb)
+	}
+}
+
+func TestForward_AgentFilter(t *testing.T) {
+	f := New(Upstream{URL: "http://upstream", Agents: []string{"web-*"}}, server.NewStateStore())
+
+	f.EnqueueMetrics("db-1")
+	f.EnqueueHeartbeat(server.HeartbeatPayload{AgentName: "db-1"})
+	f.EnqueueMetrics("web-1")
+	if len(f.queue) != 1 {
+		t.Errorf("Expected only web-1 queued, got %d items", len(f.queue))
+	}
+}
+
+func TestForward_Failure(t *testing.T) {
+	_, url := newUpstream(t)
+	store := server.NewStateStore()
+	f := New(Upstream{URL: url, APIKey: "wrong", Interval: time.Minute, Timeout: 5 * time.Second}, store)
+
+	pushed(store, "web-1")
+	f.EnqueueMetrics("web-1")
+	f.forward(context.Background(), <-f.queue)
+	if !f.failing {
+		t.Error("Expected the forwarder to record the failure")
+	}
+	if _, ok := f.lastForwarded["web-1"]; ok {
+		t.Error("Expected a failed push not to count towards the interval")
+	}
+}
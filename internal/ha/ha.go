@@ -0,0 +1,258 @@
+// Package ha elects one of several redundant servers as leader, so that
+// servers accepting the same pushes notify once. Servers grant each other a
+// time-limited lease over POST /api/v1/ha/lease, and a server leads while
+// every peer it can reach has granted it the lease. Unreachable peers are
+// taken to be down, so a network partition leaves a leader on each side
+// rather than none.
+package ha
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+)
+
+// Status is a server's view of the election
+type Status struct {
+	NodeID   string `json:"node_id"`
+	Leader   bool   `json:"leader"`
+	LeaderID string `json:"leader_id,omitempty"` // Empty until known
+	Peers    int    `json:"peers"`
+}
+
+// leaseRequest asks a peer for the lease; a zero duration releases it
+type leaseRequest struct {
+	NodeID     string `json:"node_id"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// leaseResponse says whether the lease was granted, and who holds it
+type leaseResponse struct {
+	Granted bool   `json:"granted"`
+	Holder  string `json:"holder"`
+}
+
+// Elector takes part in the election for one server
+type Elector struct {
+	logger *slog.Logger
+	nodeID string
+	peers  []string // Peer base URLs
+	token  string   // Shared by all peers
+	lease  time.Duration
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.Mutex
+	holder  string    // Who this server granted the lease to, itself included
+	expires time.Time // When the holder's lease runs out
+	leading bool      // Every reachable peer granted this server the lease
+	leader  string    // Last known leader
+}
+
+// New creates an elector for the server nodeID, which must be unique among
+// its peers
+func New(nodeID string, peers []string, token string, lease time.Duration) *Elector {
+	return &Elector{
+		logger: logging.Component("ha"),
+		nodeID: nodeID,
+		peers:  peers,
+		token:  token,
+		lease:  lease,
+		client: &http.Client{Timeout: lease / 3},
+		now:    time.Now,
+	}
+}
+
+// IsLeader reports whether this server currently leads
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leading
+}
+
+// Status returns this server's view of the election
+func (e *Elector) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return Status{NodeID: e.nodeID, Leader: e.leading, LeaderID: e.leader, Peers: len(e.peers)}
+}
+
+// Run campaigns for the lease, renewing it while leading, until ctx is
+// done; then it hands the lease back so a peer takes over straight away
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.lease / 3)
+	defer ticker.Stop()
+
+	e.logger.Info("Joining leader election", "node_id", e.nodeID, "peers", len(e.peers), "lease", e.lease)
+	for {
+		e.campaign(ctx)
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign claims the lease locally and asks every peer for it
+func (e *Elector) campaign(ctx context.Context) {
+	if granted, holder := e.grant(e.nodeID, e.lease); !granted {
+		e.follow(holder)
+		return
+	}
+
+	for _, peer := range e.peers {
+		resp, err := e.requestLease(ctx, peer, e.lease)
+		if err != nil {
+			e.logger.Debug("Peer unreachable, taking it to be down", "peer", peer, logging.Err(err))
+			continue
+		}
+		if !resp.Granted {
+			// The peer granted the lease to another server; give up the claim
+			e.mu.Lock()
+			if e.holder == e.nodeID {
+				e.holder = ""
+			}
+			e.mu.Unlock()
+			e.follow(resp.Holder)
+			return
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.leading {
+		e.logger.Info("Became leader", "node_id", e.nodeID)
+	}
+	e.leading, e.leader = true, e.nodeID
+}
+
+// follow records another server as leader
+func (e *Elector) follow(leader string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.leading {
+		e.logger.Warn("Lost leadership", "node_id", e.nodeID, "leader", leader)
+	}
+	e.leading, e.leader = false, leader
+}
+
+// resign releases the lease on this server and its peers
+func (e *Elector) resign() {
+	e.mu.Lock()
+	leading := e.leading
+	if e.holder == e.nodeID {
+		e.holder = ""
+	}
+	e.leading = false
+	e.mu.Unlock()
+	if !leading {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.lease/3)
+	defer cancel()
+	for _, peer := range e.peers {
+		if _, err := e.requestLease(ctx, peer, 0); err != nil {
+			e.logger.Warn("Failed to release lease", "peer", peer, logging.Err(err))
+		}
+	}
+	e.logger.Info("Resigned leadership", "node_id", e.nodeID)
+}
+
+// grant gives the lease to node for duration, unless another server holds
+// it. Servers campaigning at once, neither leading yet, yield to the lower
+// node ID. A zero duration releases node's lease.
+func (e *Elector) grant(node string, duration time.Duration) (bool, string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	if duration <= 0 {
+		if e.holder == node {
+			e.holder = ""
+		}
+		return true, e.holder
+	}
+
+	switch {
+	case e.holder == "" || e.holder == node || !now.Before(e.expires):
+	case e.holder == e.nodeID && !e.leading && node < e.nodeID:
+	default:
+		return false, e.holder
+	}
+
+	e.holder, e.expires = node, now.Add(duration)
+	if node != e.nodeID {
+		e.leading, e.leader = false, node
+	}
+	return true, node
+}
+
+// requestLease asks a peer for the lease
+func (e *Elector) requestLease(ctx context.Context, peer string, duration time.Duration) (*leaseResponse, error) {
+	body, err := json.Marshal(leaseRequest{NodeID: e.nodeID, DurationMs: duration.Milliseconds()})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(peer, "/")+"/api/v1/ha/lease", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.token)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("peer returned status %d", resp.StatusCode)
+	}
+
+	var lease leaseResponse
+	if err := json.NewDecoder(resp.Body).Decode(&lease); err != nil {
+		return nil, fmt.Errorf("invalid lease response: %w", err)
+	}
+	return &lease, nil
+}
+
+// ServeHTTP handles POST /api/v1/ha/lease from peers
+func (e *Elector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(e.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req leaseRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil || req.NodeID == "" || req.DurationMs < 0 {
+		http.Error(w, "Invalid lease request", http.StatusBadRequest)
+		return
+	}
+	if req.NodeID == e.nodeID {
+		http.Error(w, "Peer has this server's node_id", http.StatusConflict)
+		return
+	}
+
+	granted, holder := e.grant(req.NodeID, time.Duration(req.DurationMs)*time.Millisecond)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(leaseResponse{Granted: granted, Holder: holder}); err != nil {
+		e.logger.Error("Error encoding lease response", logging.Err(err))
+	}
+}
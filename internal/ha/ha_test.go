@@ -0,0 +1,146 @@
+package ha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGrant(t *testing.T) {
+	now := time.Now()
+	e := New("server-b", nil, "secret", 15*time.Second)
+	e.now = func() time.Time { return now }
+
+	if granted, holder := e.grant("server-c", 15*time.Second); !granted || holder != "server-c" {
+		t.Fatalf("Expected a free lease to be granted, got %v (holder %q)", granted, holder)
+	}
+	if granted, holder := e.grant("server-a", 15*time.Second); granted || holder != "server-c" {
+		t.Errorf("Expected a held lease to be refused, got %v (holder %q)", granted, holder)
+	}
+	if granted, _ := e.grant("server-c", 15*time.Second); !granted {
+		t.Error("Expected the holder to renew its lease")
+	}
+	if status := e.Status(); status.Leader || status.LeaderID != "server-c" {
+		t.Errorf("Expected server-c as leader, got %+v", status)
+	}
+
+	now = now.Add(16 * time.Second)
+	if granted, _ := e.grant("server-a", 15*time.Second); !granted {
+		t.Error("Expected an expired lease to be granted")
+	}
+
+	if granted, _ := e.grant("server-a", 0); !granted {
+		t.Error("Expected the holder to release its lease")
+	}
+	if granted, _ := e.grant("server-c", 15*time.Second); !granted {
+		t.Error("Expected a released lease to be granted")
+	}
+}
+
+func TestGrant_StartupTie(t *testing.T) {
+	e := New("server-b", nil, "secret", 15*time.Second)
+
+	// server-b has claimed the lease for itself but not yet won it
+	if granted, _ := e.grant("server-b", 15*time.Second); !granted {
+		t.Fatal("Expected a free lease to be granted")
+	}
+	if granted, _ := e.grant("server-c", 15*time.Second); granted {
+		t.Error("Expected server-b to keep its claim against a higher node ID")
+	}
+	if granted, _ := e.grant("server-a", 15*time.Second); !granted {
+		t.Error("Expected server-b to yield its claim to a lower node ID")
+	}
+}
+
+// peer serves an elector's lease endpoint
+func peer(t *testing.T, e *Elector) string {
+	t.Helper()
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestCampaign(t *testing.T) {
+	b := New("server-b", nil, "secret", 15*time.Second)
+	a := New("server-a", []string{peer(t, b)}, "secret", 15*time.Second)
+	b.peers = []string{peer(t, a)}
+
+	a.campaign(context.Background())
+	if !a.IsLeader() {
+		t.Fatal("Expected server-a to lead")
+	}
+	b.campaign(context.Background())
+	if b.IsLeader() {
+		t.Fatal("Expected server-b to follow")
+	}
+	if status := b.Status(); status.LeaderID != "server-a" {
+		t.Errorf("Expected server-b to follow server-a, got %q", status.LeaderID)
+	}
+
+	// Renewing keeps the leader in place
+	a.campaign(context.Background())
+	b.campaign(context.Background())
+	if !a.IsLeader() || b.IsLeader() {
+		t.Errorf("Expected server-a to keep leading, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+
+	// Resigning hands leadership over straight away
+	a.resign()
+	b.campaign(context.Background())
+	if a.IsLeader() || !b.IsLeader() {
+		t.Errorf("Expected server-b to take over, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+}
+
+func TestCampaign_PeerDown(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	e := New("server-b", []string{srv.URL}, "secret", 15*time.Second)
+	e.campaign(context.Background())
+	if !e.IsLeader() {
+		t.Error("Expected to lead while the only peer is down")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	e := New("server-b", nil, "secret", 15*time.Second)
+
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{"valid", "secret", `{"node_id":"server-a","duration_ms":15000}`, http.StatusOK},
+		{"wrong token", "wrong", `{"node_id":"server-a","duration_ms":15000}`, http.StatusUnauthorized},
+		{"missing node_id", "secret", `{"duration_ms":15000}`, http.StatusBadRequest},
+		{"same node_id", "secret", `{"node_id":"server-b","duration_ms":15000}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/ha/lease", bytes.NewBufferString(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp leaseResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if !resp.Granted || resp.Holder != "server-a" {
+				t.Errorf("Expected the lease granted to server-a, got %+v", resp)
+			}
+		})
+	}
+}
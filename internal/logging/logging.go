@@ -0,0 +1,120 @@
+// Package logging sets up the structured logger shared by the server and the
+// agent. Records use the same keys everywhere so they can be queried across
+// both:
+//
+//	component  part of the binary, e.g. api, alerting or collector
+//	agent      agent name
+//	container  container name
+//	alert_id   alert ID
+//	alert_type alert type
+//	error      the error being reported
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Config selects the log level, format and destination
+type Config struct {
+	Level  string `yaml:"level"`  // debug, info (default), warn or error
+	Format string `yaml:"format"` // text (default) or json
+
+	// Write to this file instead of stdout, rotating it once it reaches
+	// MaxSizeMB (0 = DefaultMaxSizeMB)
+	File       string        `yaml:"file"`
+	MaxSizeMB  int           `yaml:"max_size_mb"`
+	MaxAge     time.Duration `yaml:"max_age"`     // Remove rotated files older than this (0 = never)
+	MaxBackups int           `yaml:"max_backups"` // Keep at most this many rotated files (0 = all)
+	Compress   bool          `yaml:"compress"`    // Gzip rotated files
+}
+
+// Validate checks the level and format
+func (c Config) Validate() error {
+	if _, err := ParseLevel(c.Level); err != nil {
+		return err
+	}
+	switch c.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("log format must be text or json, got: %s", c.Format)
+	}
+	if c.MaxSizeMB < 0 {
+		return fmt.Errorf("log max_size_mb must be >= 0, got: %d", c.MaxSizeMB)
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("log max_age must be >= 0, got: %v", c.MaxAge)
+	}
+	if c.MaxBackups < 0 {
+		return fmt.Errorf("log max_backups must be >= 0, got: %d", c.MaxBackups)
+	}
+	return nil
+}
+
+// ParseLevel parses a level name, empty meaning info
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("log level must be debug, info, warn or error, got: %s", level)
+	}
+}
+
+// New creates a logger writing to w as configured, with attrs on every record
+func New(w io.Writer, c Config, attrs ...interface{}) (*slog.Logger, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	level, _ := ParseLevel(c.Level)
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if c.Format == "json" {
+		handler = slog.NewJSONHandler(w, options)
+	} else {
+		handler = slog.NewTextHandler(w, options)
+	}
+	return slog.New(handler).With(attrs...), nil
+}
+
+// Setup makes a logger created by New the default, which the log package
+// also writes through. It writes to w unless a file is configured.
+func Setup(w io.Writer, c Config, attrs ...interface{}) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if c.File != "" {
+		file, err := OpenFile(c)
+		if err != nil {
+			return err
+		}
+		w = file
+	}
+
+	logger, err := New(w, c, attrs...)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// Component returns the default logger tagged with a component
+func Component(name string) *slog.Logger {
+	return slog.Default().With("component", name)
+}
+
+// Err is the attribute for an error, under the same key everywhere
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
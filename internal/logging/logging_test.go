@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "warn", Format: "json"}, "component", "agent")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	logger.Info("Dropped")
+	logger.Warn("Push failed", "agent", "web-1", Err(errors.New("timeout")))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 record at warn level, got %d: %q", len(lines), buf.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got %q: %v", lines[0], err)
+	}
+	for key, want := range map[string]string{
+		"level":     "WARN",
+		"msg":       "Push failed",
+		"component": "agent",
+		"agent":     "web-1",
+		"error":     "timeout",
+	} {
+		if record[key] != want {
+			t.Errorf("Expected %s=%q, got %v", key, want, record[key])
+		}
+	}
+}
+
+func TestNew_TextByDefault(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	logger.Debug("Hidden")
+	logger.Info("Started", "port", 8080)
+
+	if got := buf.String(); !strings.Contains(got, "level=INFO msg=Started port=8080") || strings.Contains(got, "Hidden") {
+		t.Errorf("Unexpected text output: %q", got)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		config  Config
+		wantErr bool
+	}{
+		{Config{}, false},
+		{Config{Level: "DEBUG", Format: "json"}, false},
+		{Config{Level: "warning", Format: "text"}, false},
+		{Config{Level: "verbose"}, true},
+		{Config{Format: "logfmt"}, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.config, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("error")
+	if err != nil || level != slog.LevelError {
+		t.Errorf("Expected error level, got %v (%v)", level, err)
+	}
+}
@@ -0,0 +1,211 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxSizeMB is the size a log file grows to before it is rotated
+const DefaultMaxSizeMB = 100
+
+// backupTimeFormat stamps rotated files, e.g. server-2024-05-01T12-00-00.000.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile is a log file that is renamed with a timestamp and started
+// afresh once it reaches its maximum size. Rotated files past the age or
+// count limits are removed, and the rest are optionally gzipped.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration // 0 keeps rotated files regardless of age
+	maxBackups int           // 0 keeps any number of rotated files
+	compress   bool
+	now        func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenFile opens the log file configured in c for appending, creating it and
+// its directory if needed
+func OpenFile(c Config) (*RotatingFile, error) {
+	maxSize := c.MaxSizeMB
+	if maxSize == 0 {
+		maxSize = DefaultMaxSizeMB
+	}
+	f := &RotatingFile{
+		path:       c.File,
+		maxSize:    int64(maxSize) * 1024 * 1024,
+		maxAge:     c.MaxAge,
+		maxBackups: c.MaxBackups,
+		compress:   c.Compress,
+		now:        time.Now,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file at path, picking up the size of what it already holds
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating first if p would take it past the
+// maximum size. A record is never split across files.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// rotate renames the current file to a timestamped backup, opens a new one
+// and prunes the backups
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	if err := os.Rename(f.path, f.backupName(f.now())); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.prune()
+}
+
+// backupName is the name the current file is rotated to
+func (f *RotatingFile) backupName(at time.Time) string {
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-" + at.UTC().Format(backupTimeFormat) + ext
+}
+
+// backup is a rotated file and when it was rotated
+type backup struct {
+	path      string
+	rotatedAt time.Time
+}
+
+// backups lists the rotated files, newest first
+func (f *RotatingFile) backups() ([]backup, error) {
+	dir := filepath.Dir(f.path)
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		rotatedAt, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			continue // Not one of ours
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, name), rotatedAt: rotatedAt})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].rotatedAt.After(backups[j].rotatedAt)
+	})
+	return backups, nil
+}
+
+// prune removes backups past the count and age limits and compresses the
+// rest if configured
+func (f *RotatingFile) prune() error {
+	backups, err := f.backups()
+	if err != nil {
+		return fmt.Errorf("failed to list rotated log files: %w", err)
+	}
+
+	now := f.now()
+	for i, b := range backups {
+		expired := f.maxAge > 0 && now.Sub(b.rotatedAt) > f.maxAge
+		if expired || (f.maxBackups > 0 && i >= f.maxBackups) {
+			if err := os.Remove(b.path); err != nil {
+				return fmt.Errorf("failed to remove rotated log file: %w", err)
+			}
+			continue
+		}
+		if f.compress && !strings.HasSuffix(b.path, ".gz") {
+			if err := compressFile(b.path); err != nil {
+				return fmt.Errorf("failed to compress rotated log file: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// compressFile gzips path to path.gz and removes the original
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	in.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestFile opens a rotating file with a tiny size limit and a clock
+// that advances a second per rotation
+func openTestFile(t *testing.T, c Config) *RotatingFile {
+	t.Helper()
+	f, err := OpenFile(c)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	f.maxSize = 10
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	return f
+}
+
+func TestRotatingFile_RotatesAtMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "server.log")
+	f := openTestFile(t, Config{File: path})
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	current, _ := os.ReadFile(path)
+	if string(current) != "third\n" {
+		t.Errorf("Expected current file to hold the last record, got %q", current)
+	}
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 rotated files, got %d", len(backups))
+	}
+	newest, _ := os.ReadFile(backups[0].path)
+	if string(newest) != "second\n" || !strings.HasPrefix(filepath.Base(backups[0].path), "server-2024-05-01T12-00-") {
+		t.Errorf("Unexpected newest backup %s: %q", backups[0].path, newest)
+	}
+}
+
+func TestRotatingFile_PrunesBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.log")
+	f := openTestFile(t, Config{File: path, MaxBackups: 2})
+
+	for i := 0; i < 5; i++ {
+		f.Write([]byte("0123456789\n"))
+	}
+
+	backups, _ := f.backups()
+	if len(backups) != 2 {
+		t.Errorf("Expected 2 rotated files kept, got %d", len(backups))
+	}
+
+	// Files that aren't rotated logs are left alone
+	other := filepath.Join(dir, "agent-notes.log")
+	os.WriteFile(other, []byte("keep"), 0644)
+	f.maxAge = time.Millisecond
+	f.Write([]byte("0123456789\n"))
+	backups, _ = f.backups()
+	if len(backups) != 0 {
+		t.Errorf("Expected expired rotated files removed, got %d", len(backups))
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("Expected unrelated file kept, got %v", err)
+	}
+}
+
+func TestRotatingFile_Compresses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	f := openTestFile(t, Config{File: path, Compress: true})
+
+	f.Write([]byte("0123456789\n"))
+	f.Write([]byte("next\n"))
+
+	backups, _ := f.backups()
+	if len(backups) != 1 || !strings.HasSuffix(backups[0].path, ".log.gz") {
+		t.Fatalf("Expected 1 gzipped backup, got %+v", backups)
+	}
+	file, err := os.Open(backups[0].path)
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Backup is not gzipped: %v", err)
+	}
+	data, _ := io.ReadAll(gz)
+	if string(data) != "0123456789\n" {
+		t.Errorf("Expected backup contents preserved, got %q", data)
+	}
+}
+
+func TestRotatingFile_AppendsToExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	os.WriteFile(path, []byte("old\n"), 0644)
+
+	f, err := OpenFile(Config{File: path})
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.Write([]byte("new\n"))
+	f.Close()
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "old\nnew\n" || f.size != 8 {
+		t.Errorf("Expected appended file of 8 bytes, got %q (%d)", data, f.size)
+	}
+}
@@ -0,0 +1,625 @@
+package msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxDepth bounds nesting, so a hostile body can't exhaust the stack
+	maxDepth = 10000
+
+	// maxPrealloc bounds what a length header alone can make the decoder
+	// allocate; longer strings and arrays grow as they are read
+	maxPrealloc = 4096
+)
+
+// Unmarshal decodes the MessagePack value in data into v, which must be a
+// non-nil pointer
+func Unmarshal(data []byte, v interface{}) error {
+	d := NewDecoder(bytes.NewReader(data))
+	if err := d.Decode(v); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if _, err := d.r.ReadByte(); err != io.EOF {
+		return errors.New("msgpack: trailing data after value")
+	}
+	return nil
+}
+
+// Decoder reads successive MessagePack values from a stream
+type Decoder struct {
+	r     *bufio.Reader
+	depth int
+}
+
+// NewDecoder creates a decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next value into v, which must be a non-nil pointer. It
+// returns io.EOF if the stream ends before the value starts. Errors reading
+// the stream are wrapped, so errors.As finds them.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("msgpack: Decode needs a non-nil pointer")
+	}
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	d.depth = 0
+	return d.decode(b, rv.Elem())
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+func (d *Decoder) readN(n int) ([]byte, error) {
+	if n <= maxPrealloc {
+		buf := make([]byte, n)
+		_, err := io.ReadFull(d.r, buf)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return buf, err
+	}
+	buf, err := io.ReadAll(io.LimitReader(d.r, int64(n)))
+	if err == nil && len(buf) < n {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf, err
+}
+
+func (d *Decoder) readUint(size int) (uint64, error) {
+	buf, err := d.readN(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(buf[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(buf)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(buf)), nil
+	}
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+// kind is the family of a MessagePack value, from its first byte
+type kind int
+
+const (
+	kindNil kind = iota
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+	kindString
+	kindBinary
+	kindArray
+	kindMap
+	kindExt
+)
+
+var kindNames = [...]string{"nil", "bool", "integer", "integer", "float", "string", "binary", "array", "map", "extension"}
+
+func (k kind) String() string { return kindNames[k] }
+
+// header is a value's first byte worked out: its kind, then for strings,
+// binaries, arrays, maps and extensions the length, for booleans and
+// fixints the value, and how many more bytes hold the length or value
+type header struct {
+	kind  kind
+	value uint64 // Fixint or bool value, or a length once read
+	size  int    // Bytes after the first holding the value or length
+}
+
+func parseHeader(b byte) (header, error) {
+	switch {
+	case b <= 0x7f:
+		return header{kind: kindUint, value: uint64(b)}, nil
+	case b >= 0xe0:
+		return header{kind: kindInt, value: uint64(int64(int8(b)))}, nil
+	case b >= 0x80 && b <= 0x8f:
+		return header{kind: kindMap, value: uint64(b & 0x0f)}, nil
+	case b >= 0x90 && b <= 0x9f:
+		return header{kind: kindArray, value: uint64(b & 0x0f)}, nil
+	case b >= 0xa0 && b <= 0xbf:
+		return header{kind: kindString, value: uint64(b & 0x1f)}, nil
+	}
+
+	switch b {
+	case 0xc0:
+		return header{kind: kindNil}, nil
+	case 0xc2, 0xc3:
+		return header{kind: kindBool, value: uint64(b & 1)}, nil
+	case 0xc4, 0xc5, 0xc6:
+		return header{kind: kindBinary, size: 1 << (b - 0xc4)}, nil
+	case 0xc7, 0xc8, 0xc9:
+		return header{kind: kindExt, size: 1 << (b - 0xc7)}, nil
+	case 0xca:
+		return header{kind: kindFloat, size: 4}, nil
+	case 0xcb:
+		return header{kind: kindFloat, size: 8}, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return header{kind: kindUint, size: 1 << (b - 0xcc)}, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		return header{kind: kindInt, size: 1 << (b - 0xd0)}, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		// Fixext: the size is the data length, there is no length to read
+		return header{kind: kindExt, value: 1 << (b - 0xd4)}, nil
+	case 0xd9, 0xda, 0xdb:
+		return header{kind: kindString, size: 1 << (b - 0xd9)}, nil
+	case 0xdc, 0xdd:
+		return header{kind: kindArray, size: 2 << (b - 0xdc)}, nil
+	case 0xde, 0xdf:
+		return header{kind: kindMap, size: 2 << (b - 0xde)}, nil
+	}
+	return header{}, fmt.Errorf("msgpack: invalid format byte 0x%02x", b)
+}
+
+// decode decodes the value starting with b into v
+func (d *Decoder) decode(b byte, v reflect.Value) error {
+	h, err := parseHeader(b)
+	if err != nil {
+		return err
+	}
+	if h.size > 0 {
+		if h.kind == kindInt || h.kind == kindUint || h.kind == kindFloat {
+			buf, err := d.readN(h.size)
+			if err != nil {
+				return fmt.Errorf("msgpack: %w", err)
+			}
+			return d.decodeNumber(h, buf, v)
+		}
+		if h.value, err = d.readUint(h.size); err != nil {
+			return fmt.Errorf("msgpack: %w", err)
+		}
+	}
+
+	if h.kind == kindNil {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+	return d.decodeHeader(h, v)
+}
+
+// decodeHeader decodes a non-nil value whose header has been read
+func (d *Decoder) decodeHeader(h header, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeHeader(h, v.Elem())
+	}
+	if v.Kind() == reflect.Interface {
+		if v.NumMethod() > 0 {
+			return fmt.Errorf("msgpack: cannot decode into %s", v.Type())
+		}
+		value, err := d.decodeAny(h)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(value))
+		}
+		return nil
+	}
+
+	switch h.kind {
+	case kindBool:
+		if v.Kind() != reflect.Bool {
+			return mismatch(h, v)
+		}
+		v.SetBool(h.value == 1)
+		return nil
+	case kindInt, kindUint:
+		return d.decodeNumber(h, nil, v)
+	case kindString, kindBinary:
+		data, err := d.readN(int(h.value))
+		if err != nil {
+			return fmt.Errorf("msgpack: %w", err)
+		}
+		return setBytes(h, data, v)
+	case kindArray:
+		return d.decodeArray(int(h.value), v)
+	case kindMap:
+		if v.Kind() == reflect.Struct && v.Type() != timeType {
+			return d.decodeStruct(int(h.value), v)
+		}
+		return d.decodeMap(int(h.value), v)
+	case kindExt:
+		if v.Type() != timeType {
+			return mismatch(h, v)
+		}
+		t, err := d.decodeTime(int(h.value))
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	return mismatch(h, v)
+}
+
+func mismatch(h header, v reflect.Value) error {
+	return fmt.Errorf("msgpack: cannot decode %s into %s", h.kind, v.Type())
+}
+
+// decodeNumber sets v to an integer or float, from buf or a fixint
+func (d *Decoder) decodeNumber(h header, buf []byte, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeNumber(h, buf, v.Elem())
+	}
+	value, err := numberValue(h, buf)
+	if err != nil {
+		return err
+	}
+	if v.Kind() == reflect.Interface {
+		if v.NumMethod() > 0 {
+			return mismatch(h, v)
+		}
+		v.Set(reflect.ValueOf(value))
+		return nil
+	}
+	switch n := value.(type) {
+	case float64:
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(n)
+			return nil
+		}
+	case int64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.OverflowInt(n) {
+				return fmt.Errorf("msgpack: %d overflows %s", n, v.Type())
+			}
+			v.SetInt(n)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if n < 0 || v.OverflowUint(uint64(n)) {
+				return fmt.Errorf("msgpack: %d overflows %s", n, v.Type())
+			}
+			v.SetUint(uint64(n))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(n))
+			return nil
+		}
+	case uint64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n > math.MaxInt64 || v.OverflowInt(int64(n)) {
+				return fmt.Errorf("msgpack: %d overflows %s", n, v.Type())
+			}
+			v.SetInt(int64(n))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if v.OverflowUint(n) {
+				return fmt.Errorf("msgpack: %d overflows %s", n, v.Type())
+			}
+			v.SetUint(n)
+			return nil
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(n))
+			return nil
+		}
+	}
+	return mismatch(h, v)
+}
+
+// numberValue is an integer or float as int64 (or uint64 beyond it) or
+// float64
+func numberValue(h header, buf []byte) (interface{}, error) {
+	if buf == nil {
+		// Fixint, already sign-extended
+		return int64(h.value), nil
+	}
+	switch h.kind {
+	case kindFloat:
+		if len(buf) == 4 {
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(buf))), nil
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(buf)), nil
+	case kindInt:
+		switch len(buf) {
+		case 1:
+			return int64(int8(buf[0])), nil
+		case 2:
+			return int64(int16(binary.BigEndian.Uint16(buf))), nil
+		case 4:
+			return int64(int32(binary.BigEndian.Uint32(buf))), nil
+		}
+		return int64(binary.BigEndian.Uint64(buf)), nil
+	}
+	var u uint64
+	switch len(buf) {
+	case 1:
+		u = uint64(buf[0])
+	case 2:
+		u = uint64(binary.BigEndian.Uint16(buf))
+	case 4:
+		u = uint64(binary.BigEndian.Uint32(buf))
+	default:
+		u = binary.BigEndian.Uint64(buf)
+	}
+	if u > math.MaxInt64 {
+		return u, nil
+	}
+	return int64(u), nil
+}
+
+// setBytes sets a string or []byte from a str or bin value
+func setBytes(h header, data []byte, v reflect.Value) error {
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(string(data))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		v.SetBytes(data)
+	case v.Type() == timeType && h.kind == kindString:
+		t, err := time.Parse(time.RFC3339Nano, string(data))
+		if err != nil {
+			return fmt.Errorf("msgpack: %w", err)
+		}
+		v.Set(reflect.ValueOf(t))
+	default:
+		return mismatch(h, v)
+	}
+	return nil
+}
+
+// next reads and decodes one nested value into v
+func (d *Decoder) next(v reflect.Value) error {
+	b, err := d.readByte()
+	if err != nil {
+		return fmt.Errorf("msgpack: %w", err)
+	}
+	return d.decode(b, v)
+}
+
+// skip reads and discards one nested value
+func (d *Decoder) skip() error {
+	var discard interface{}
+	return d.next(reflect.ValueOf(&discard).Elem())
+}
+
+func (d *Decoder) enter() error {
+	if d.depth++; d.depth > maxDepth {
+		return errors.New("msgpack: exceeded max depth")
+	}
+	return nil
+}
+
+func (d *Decoder) decodeArray(n int, v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+
+	switch v.Kind() {
+	case reflect.Slice:
+		elem := v.Type().Elem()
+		s := reflect.MakeSlice(v.Type(), 0, min(n, maxPrealloc))
+		for i := 0; i < n; i++ {
+			s = reflect.Append(s, reflect.Zero(elem))
+			if err := d.next(s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		for i := 0; i < n; i++ {
+			var err error
+			if i < v.Len() {
+				err = d.next(v.Index(i))
+			} else {
+				err = d.skip()
+			}
+			if err != nil {
+				return err
+			}
+		}
+		for i := n; i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot decode array into %s", v.Type())
+	}
+	return nil
+}
+
+// readKey reads a map key, which must be a string or an integer
+func (d *Decoder) readKey() (interface{}, error) {
+	b, err := d.readByte()
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	var key interface{}
+	if err := d.decode(b, reflect.ValueOf(&key).Elem()); err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case string, int64, uint64:
+		return key, nil
+	}
+	return nil, fmt.Errorf("msgpack: unsupported map key %v", key)
+}
+
+func (d *Decoder) decodeMap(n int, v reflect.Value) error {
+	if v.Kind() != reflect.Map {
+		return fmt.Errorf("msgpack: cannot decode map into %s", v.Type())
+	}
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), min(n, maxPrealloc)))
+	}
+	keyType, elemType := v.Type().Key(), v.Type().Elem()
+	for i := 0; i < n; i++ {
+		raw, err := d.readKey()
+		if err != nil {
+			return err
+		}
+		key := reflect.New(keyType).Elem()
+		if err := setKey(raw, key); err != nil {
+			return err
+		}
+		elem := reflect.New(elemType).Elem()
+		if err := d.next(elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+// setKey converts a map key to the map's key type; integer key types take
+// integers or the strings JSON-style encoders turn them into
+func setKey(raw interface{}, key reflect.Value) error {
+	s := fmt.Sprint(raw)
+	switch key.Kind() {
+	case reflect.String:
+		key.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || key.OverflowInt(i) {
+			return fmt.Errorf("msgpack: invalid map key %q for %s", s, key.Type())
+		}
+		key.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || key.OverflowUint(u) {
+			return fmt.Errorf("msgpack: invalid map key %q for %s", s, key.Type())
+		}
+		key.SetUint(u)
+		return nil
+	}
+	return fmt.Errorf("msgpack: unsupported map key type %s", key.Type())
+}
+
+// decodeStruct sets fields by name; unknown names are skipped
+func (d *Decoder) decodeStruct(n int, v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+
+	info := cachedStruct(v.Type())
+	for i := 0; i < n; i++ {
+		raw, err := d.readKey()
+		if err != nil {
+			return err
+		}
+		name, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("msgpack: struct %s has non-string key %v", v.Type(), raw)
+		}
+		f, ok := info.lookup(name)
+		if !ok {
+			if err := d.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		fv, _ := fieldByIndex(v, f.index, true)
+		if err := d.next(fv); err != nil {
+			return fmt.Errorf("%w (field %s)", err, name)
+		}
+	}
+	return nil
+}
+
+// decodeTime reads the data of a timestamp extension
+func (d *Decoder) decodeTime(n int) (time.Time, error) {
+	data, err := d.readN(n + 1)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("msgpack: %w", err)
+	}
+	if int8(data[0]) != timestampExt {
+		return time.Time{}, fmt.Errorf("msgpack: unsupported extension type %d", int8(data[0]))
+	}
+	data = data[1:]
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)).UTC(), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data)
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		return time.Unix(sec, int64(nsec)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("msgpack: invalid timestamp length %d", n)
+}
+
+// decodeAny decodes a value for an interface{}: nil, bool, int64 (uint64
+// beyond it), float64, string, []byte, time.Time, []interface{} or
+// map[string]interface{}
+func (d *Decoder) decodeAny(h header) (interface{}, error) {
+	switch h.kind {
+	case kindNil:
+		return nil, nil
+	case kindBool:
+		return h.value == 1, nil
+	case kindInt, kindUint:
+		return numberValue(h, nil)
+	case kindString:
+		data, err := d.readN(int(h.value))
+		if err != nil {
+			return nil, fmt.Errorf("msgpack: %w", err)
+		}
+		return string(data), nil
+	case kindBinary:
+		data, err := d.readN(int(h.value))
+		if err != nil {
+			return nil, fmt.Errorf("msgpack: %w", err)
+		}
+		return data, nil
+	case kindArray:
+		var s []interface{}
+		err := d.decodeArray(int(h.value), reflect.ValueOf(&s).Elem())
+		return s, err
+	case kindMap:
+		m := make(map[string]interface{}, min(int(h.value), maxPrealloc))
+		err := d.decodeMap(int(h.value), reflect.ValueOf(m))
+		return m, err
+	case kindExt:
+		return d.decodeTime(int(h.value))
+	}
+	return nil, fmt.Errorf("msgpack: cannot decode %s", h.kind)
+}
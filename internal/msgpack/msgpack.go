@@ -0,0 +1,147 @@
+// Package msgpack encodes and decodes MessagePack, a binary form of JSON's
+// data model that is smaller and cheaper to encode and decode. Struct fields
+// are named by their json tags, with the same omitempty and embedding rules,
+// so a type pushed as JSON can be pushed as MessagePack unchanged. Times are
+// encoded with the MessagePack timestamp extension.
+package msgpack
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is the media type of MessagePack request bodies
+const ContentType = "application/msgpack"
+
+// IsContentType reports whether a media type, without parameters, is one of
+// the names MessagePack goes by
+func IsContentType(mediaType string) bool {
+	switch mediaType {
+	case ContentType, "application/x-msgpack", "application/vnd.msgpack":
+		return true
+	}
+	return false
+}
+
+// timestampExt is the extension type of timestamps
+const timestampExt = -1
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// field is a struct field as encoded
+type field struct {
+	name      string
+	index     []int // Through embedded structs
+	omitEmpty bool
+}
+
+// structInfo is how a struct type is encoded
+type structInfo struct {
+	fields []field
+	byName map[string]int
+}
+
+var structCache sync.Map // reflect.Type -> *structInfo
+
+func cachedStruct(t reflect.Type) *structInfo {
+	if info, ok := structCache.Load(t); ok {
+		return info.(*structInfo)
+	}
+	info, _ := structCache.LoadOrStore(t, typeStruct(t))
+	return info.(*structInfo)
+}
+
+// typeStruct works out a struct's fields the way encoding/json does: named
+// by their json tag, skipped with "-", and with untagged embedded structs'
+// fields promoted. Of fields with the same name the shallowest wins.
+func typeStruct(t reflect.Type) *structInfo {
+	info := &structInfo{byName: make(map[string]int)}
+
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			fieldIndex := append(append([]int(nil), index...), i)
+
+			if sf.Anonymous && name == "" {
+				ft := sf.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					walk(ft, fieldIndex)
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+
+			f := field{name: name, index: fieldIndex, omitEmpty: hasOption(opts, "omitempty")}
+			if existing, ok := info.byName[name]; ok {
+				if len(info.fields[existing].index) > len(fieldIndex) {
+					info.fields[existing] = f
+				}
+				continue
+			}
+			info.byName[name] = len(info.fields)
+			info.fields = append(info.fields, f)
+		}
+	}
+	walk(t, nil)
+	return info
+}
+
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup finds a field by name, falling back to a case-insensitive match
+// like encoding/json
+func (info *structInfo) lookup(name string) (field, bool) {
+	if i, ok := info.byName[name]; ok {
+		return info.fields[i], true
+	}
+	for _, f := range info.fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// isEmpty reports whether omitempty leaves a value out
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func TestRoundTrip_Payload(t *testing.T) {
+	now := time.Now()
+	payload := server.MetricsPushPayload{
+		AgentName: "web-1",
+		Timestamp: now,
+		CloudMetadata: &server.CloudMetadata{
+			Provider: "aws",
+			Region:   "eu-west-1",
+			Tags:     map[string]string{"team": "payments"},
+		},
+		SystemMetrics: metrics.SystemMetrics{
+			AgentName: "web-1",
+			Timestamp: now,
+			CPU:       metrics.CPUMetrics{UsagePercent: 42.5, LoadAvg1: 0.75},
+			Containers: []metrics.ContainerMetrics{
+				{ID: "c1", Name: "api", MemoryUsage: 3 << 30},
+				{ID: "c2", Name: "worker"},
+			},
+		},
+		Sequence:          math.MaxUint64,
+		RemovedContainers: []string{"c3"},
+	}
+
+	data, err := Marshal(payload)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var got server.MetricsPushPayload
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if !got.Timestamp.Equal(now) || !got.SystemMetrics.Timestamp.Equal(now) {
+		t.Errorf("Expected timestamp %v, got %v", now, got.Timestamp)
+	}
+	// Times decode as UTC; compare the rest through JSON
+	got.Timestamp, got.SystemMetrics.Timestamp = payload.Timestamp, payload.SystemMetrics.Timestamp
+	want, _ := json.Marshal(payload)
+	have, _ := json.Marshal(got)
+	if !bytes.Equal(want, have) {
+		t.Errorf("Expected %s, got %s", want, have)
+	}
+
+	if jsonData, _ := json.Marshal(payload); len(data) >= len(jsonData) {
+		t.Errorf("Expected MessagePack smaller than JSON, got %d vs %d bytes", len(data), len(jsonData))
+	}
+}
+
+func TestMarshal_Encoding(t *testing.T) {
+	type inner struct {
+		B int `json:"b"`
+	}
+	type outer struct {
+		inner
+		A    string `json:"a,omitempty"`
+		Skip string `json:"-"`
+		Nil  *inner `json:"nil"`
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []byte
+	}{
+		{"fixint", 5, []byte{0x05}},
+		{"negative fixint", -3, []byte{0xfd}},
+		{"uint16", 300, []byte{0xcd, 0x01, 0x2c}},
+		{"int8", -100, []byte{0xd0, 0x9c}},
+		{"float64", 1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"string", "hi", []byte{0xa2, 'h', 'i'}},
+		{"nil slice", []string(nil), []byte{0xc0}},
+		{"array", []int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{"bytes", []byte{1}, []byte{0xc4, 0x01, 0x01}},
+		{"map", map[string]bool{"x": true}, []byte{0x81, 0xa1, 'x', 0xc3}},
+		// Embedded fields promoted, empty and skipped fields left out
+		{"struct", outer{inner: inner{B: 1}}, []byte{0x82, 0xa1, 'b', 0x01, 0xa3, 'n', 'i', 'l', 0xc0}},
+		{"timestamp32", time.Unix(1, 0), []byte{0xd6, 0xff, 0, 0, 0, 1}},
+	}
+
+	for _, tt := range tests {
+		got, err := Marshal(tt.value)
+		if err != nil {
+			t.Errorf("%s: Marshal failed: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: expected % x, got % x", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestUnmarshal_Times(t *testing.T) {
+	for _, want := range []time.Time{
+		time.Unix(1700000000, 0),
+		time.Unix(1700000000, 123456789),
+		time.Unix(1<<35, 1),
+		{},
+	} {
+		data, _ := Marshal(want)
+		var got time.Time
+		if err := Unmarshal(data, &got); err != nil {
+			t.Errorf("%v: Unmarshal failed: %v", want, err)
+		} else if !got.Equal(want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestUnmarshal_Interface(t *testing.T) {
+	data, _ := Marshal(map[string]interface{}{"n": 1, "f": 0.5, "s": []interface{}{"a", true, nil}})
+	var got interface{}
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := map[string]interface{}{"n": int64(1), "f": 0.5, "s": []interface{}{"a", true, nil}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestUnmarshal_Errors(t *testing.T) {
+	var payload server.HeartbeatPayload
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated", []byte{0x81, 0xaa, 'a', 'g'}},
+		{"wrong type", []byte{0x81, 0xaa, 'a', 'g', 'e', 'n', 't', '_', 'n', 'a', 'm', 'e', 0x05}},
+		{"overflow", []byte{0x81, 0xda, 0x00, 0x1a, 'h', 'e', 'a', 'r', 't', 'b', 'e', 'a', 't', '_', 'i', 'n', 't', 'e', 'r', 'v', 'a', 'l', '_', 's', 'e', 'c', 'o', 'n', 'd', 's', 0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"invalid byte", []byte{0xc1}},
+		{"huge array", []byte{0xdd, 0xff, 0xff, 0xff, 0xff}},
+		{"trailing data", []byte{0x80, 0x80}},
+	}
+
+	for _, tt := range tests {
+		if err := Unmarshal(tt.data, &payload); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestDecoder_Stream(t *testing.T) {
+	var buf bytes.Buffer
+	for _, name := range []string{"web-1", "web-2"} {
+		data, _ := Marshal(server.HeartbeatPayload{AgentName: name})
+		buf.Write(data)
+	}
+
+	d := NewDecoder(&buf)
+	var names []string
+	for {
+		var payload server.HeartbeatPayload
+		err := d.Decode(&payload)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		names = append(names, payload.AgentName)
+	}
+	if len(names) != 2 || names[0] != "web-1" || names[1] != "web-2" {
+		t.Errorf("Expected web-1 and web-2, got %v", names)
+	}
+}
@@ -0,0 +1,128 @@
+package promwrite
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var errTruncated = errors.New("protobuf: truncated message")
+
+// TimeSeries is one series of a remote_write request
+type TimeSeries struct {
+	Labels  map[string]string // Including __name__
+	Samples []Sample
+}
+
+// Sample is a value at a time in milliseconds since the epoch
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// decodeWriteRequest decodes the series of a prometheus.WriteRequest.
+// Metadata and exemplars are skipped.
+func decodeWriteRequest(data []byte) ([]TimeSeries, error) {
+	series := make([]TimeSeries, 0)
+	err := forEachField(data, func(field int, value []byte, _ uint64) error {
+		if field != 1 {
+			return nil
+		}
+		ts, err := decodeTimeSeries(value)
+		if err != nil {
+			return err
+		}
+		series = append(series, ts)
+		return nil
+	})
+	return series, err
+}
+
+func decodeTimeSeries(data []byte) (TimeSeries, error) {
+	ts := TimeSeries{Labels: make(map[string]string)}
+	err := forEachField(data, func(field int, value []byte, _ uint64) error {
+		switch field {
+		case 1: // Label
+			var name, labelValue string
+			err := forEachField(value, func(field int, value []byte, _ uint64) error {
+				switch field {
+				case 1:
+					name = string(value)
+				case 2:
+					labelValue = string(value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ts.Labels[name] = labelValue
+		case 2: // Sample
+			var sample Sample
+			err := forEachField(value, func(field int, value []byte, number uint64) error {
+				switch field {
+				case 1:
+					sample.Value = math.Float64frombits(number)
+				case 2:
+					sample.Timestamp = int64(number)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, sample)
+		}
+		return nil
+	})
+	return ts, err
+}
+
+// forEachField walks the fields of a protobuf message. Length-delimited
+// fields are passed as value, numeric ones as number.
+func forEachField(data []byte, fn func(field int, value []byte, number uint64) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+
+		var value []byte
+		var number uint64
+		switch key & 0x07 {
+		case 0: // Varint
+			number, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errTruncated
+			}
+			data = data[n:]
+		case 1: // 64-bit
+			if len(data) < 8 {
+				return errTruncated
+			}
+			number = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case 2: // Length-delimited
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return errTruncated
+			}
+			value = data[n : n+int(length)]
+			data = data[n+int(length):]
+		case 5: // 32-bit
+			if len(data) < 4 {
+				return errTruncated
+			}
+			number = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		default:
+			return errors.New("protobuf: unsupported wire type")
+		}
+
+		if err := fn(int(key>>3), value, number); err != nil {
+			return err
+		}
+	}
+	return nil
+}
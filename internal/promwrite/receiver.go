@@ -0,0 +1,409 @@
+// Package promwrite accepts Prometheus remote_write requests and maps
+// well-known node_exporter and cAdvisor series into agent state, so hosts
+// that already run exporters show up without installing the agent.
+package promwrite
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+// MaxDecodedSize caps the uncompressed size of a remote_write request
+const MaxDecodedSize = 64 * 1024 * 1024
+
+// AgentLabel names the agent a series belongs to. Without it, the host part
+// of the instance label is used.
+const AgentLabel = "saviour_agent"
+
+// staleAfter drops series a host hasn't sent for this long, e.g. removed
+// containers or unmounted filesystems
+const staleAfter = 10 * time.Minute
+
+// forgetAfter drops hosts nothing was written for in this long, so
+// relabeling or instance churn doesn't grow a Receiver without bound
+const forgetAfter = time.Hour
+
+// wantedSeries are the series mapped into agent state; everything else in a
+// request is ignored
+var wantedSeries = map[string]bool{
+	"node_cpu_seconds_total":            true,
+	"node_load1":                        true,
+	"node_load5":                        true,
+	"node_load15":                       true,
+	"node_memory_MemTotal_bytes":        true,
+	"node_memory_MemAvailable_bytes":    true,
+	"node_memory_SwapTotal_bytes":       true,
+	"node_memory_SwapFree_bytes":        true,
+	"node_filesystem_size_bytes":        true,
+	"node_filesystem_avail_bytes":       true,
+	"node_filesystem_free_bytes":        true,
+	"node_uname_info":                   true,
+	"node_boot_time_seconds":            true,
+	"container_cpu_usage_seconds_total": true,
+	"container_memory_usage_bytes":      true,
+	"container_spec_memory_limit_bytes": true,
+}
+
+// pseudoFilesystems aren't reported as disks, as the agent does
+var pseudoFilesystems = map[string]bool{
+	"tmpfs": true, "devtmpfs": true, "overlay": true, "squashfs": true,
+	"proc": true, "sysfs": true, "cgroup": true, "cgroup2": true, "nsfs": true,
+	"autofs": true, "rpc_pipefs": true, "fuse.lxcfs": true,
+}
+
+// Receiver remembers the latest value of every mapped series per host.
+// remote_write spreads a host's series over many requests, so each request
+// updates the remembered values and the host's state is rebuilt from all
+// of them.
+type Receiver struct {
+	mu        sync.Mutex
+	hosts     map[string]*host // key: agent name
+	lastPrune time.Time
+}
+
+// host is what a Receiver remembers about one agent
+type host struct {
+	series  map[string]series // key: series identity
+	written time.Time         // Last request with series for it
+
+	// Counter readings of the last rebuild, to turn counters into rates
+	cpu          counterReading
+	cpuPercent   float64
+	containerCPU map[string]counterReading // key: container name
+	containerPct map[string]float64
+}
+
+type series struct {
+	name   string
+	labels map[string]string
+	value  float64
+	at     time.Time
+}
+
+type counterReading struct {
+	value float64
+	idle  float64 // Node CPU only
+	at    time.Time
+}
+
+// NewReceiver creates an empty receiver
+func NewReceiver() *Receiver {
+	return &Receiver{hosts: make(map[string]*host)}
+}
+
+// Decode decompresses and decodes a snappy-compressed remote_write body
+func Decode(body []byte) ([]TimeSeries, error) {
+	data, err := decodeSnappy(body, MaxDecodedSize)
+	if err != nil {
+		return nil, err
+	}
+	series, err := decodeWriteRequest(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WriteRequest: %w", err)
+	}
+	return series, nil
+}
+
+// Apply records the series of one request and returns the rebuilt state
+// of every host they touched, ready for StateStore.UpdateAgent
+func (r *Receiver) Apply(timeSeries []TimeSeries, now time.Time) []*server.ServerState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	touched := make(map[string]bool)
+	for _, ts := range timeSeries {
+		name := ts.Labels["__name__"]
+		agentName := agentOf(ts.Labels)
+		if !wantedSeries[name] || agentName == "" || len(ts.Samples) == 0 {
+			continue
+		}
+
+		latest := ts.Samples[0]
+		for _, s := range ts.Samples[1:] {
+			if s.Timestamp > latest.Timestamp {
+				latest = s
+			}
+		}
+
+		h, exists := r.hosts[agentName]
+		if !exists {
+			h = &host{
+				series:       make(map[string]series),
+				containerCPU: make(map[string]counterReading),
+				containerPct: make(map[string]float64),
+			}
+			r.hosts[agentName] = h
+		}
+		key := seriesKey(ts.Labels)
+		at := time.UnixMilli(latest.Timestamp)
+		if previous, exists := h.series[key]; !exists || !at.Before(previous.at) {
+			h.series[key] = series{name: name, labels: ts.Labels, value: latest.Value, at: at}
+		}
+		h.written = now
+		touched[agentName] = true
+	}
+	r.prune(now)
+
+	states := make([]*server.ServerState, 0, len(touched))
+	for agentName := range touched {
+		states = append(states, r.hosts[agentName].build(agentName, now))
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].AgentName < states[j].AgentName })
+	return states
+}
+
+// prune forgets hosts nothing was written for in forgetAfter, checking at
+// most once a minute. Callers hold r.mu.
+func (r *Receiver) prune(now time.Time) {
+	if now.Sub(r.lastPrune) < time.Minute {
+		return
+	}
+	r.lastPrune = now
+
+	for agentName, h := range r.hosts {
+		if now.Sub(h.written) > forgetAfter {
+			delete(r.hosts, agentName)
+		}
+	}
+}
+
+// agentOf returns the agent a series belongs to
+func agentOf(labels map[string]string) string {
+	if name := labels[AgentLabel]; name != "" {
+		return name
+	}
+	instance := labels["instance"]
+	if hostname, _, err := net.SplitHostPort(instance); err == nil {
+		return hostname
+	}
+	return instance
+}
+
+// seriesKey identifies a series by its sorted labels
+func seriesKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + "=" + labels[name] + "\xff")
+	}
+	return b.String()
+}
+
+// filesystem gathers the node_filesystem_* series of one mount
+type filesystem struct {
+	device, fsType    string
+	size, avail, free float64
+}
+
+// container gathers the container_* series of one container
+type container struct {
+	id, image         string
+	cpuSeconds        float64
+	cpuAt             time.Time
+	memory, memoryMax float64
+}
+
+// build turns the remembered series into agent state
+func (h *host) build(agentName string, now time.Time) *server.ServerState {
+	m := metrics.SystemMetrics{AgentName: agentName}
+	var cpuTotal, cpuIdle, memTotal, memAvailable, swapTotal, swapFree float64
+	var cpuAt time.Time
+	filesystems := make(map[string]*filesystem)
+	containers := make(map[string]*container)
+
+	newest := time.Time{}
+	for _, s := range h.series {
+		if s.at.After(newest) {
+			newest = s.at
+		}
+	}
+
+	for key, s := range h.series {
+		if newest.Sub(s.at) > staleAfter {
+			delete(h.series, key)
+			continue
+		}
+
+		switch s.name {
+		case "node_cpu_seconds_total":
+			cpuTotal += s.value
+			if s.labels["mode"] == "idle" {
+				cpuIdle += s.value
+			}
+			if s.at.After(cpuAt) {
+				cpuAt = s.at
+			}
+		case "node_load1":
+			m.CPU.LoadAvg1 = s.value
+		case "node_load5":
+			m.CPU.LoadAvg5 = s.value
+		case "node_load15":
+			m.CPU.LoadAvg15 = s.value
+		case "node_memory_MemTotal_bytes":
+			memTotal = s.value
+		case "node_memory_MemAvailable_bytes":
+			memAvailable = s.value
+		case "node_memory_SwapTotal_bytes":
+			swapTotal = s.value
+		case "node_memory_SwapFree_bytes":
+			swapFree = s.value
+		case "node_uname_info":
+			m.SystemInfo.Hostname = s.labels["nodename"]
+			m.SystemInfo.OS = strings.ToLower(s.labels["sysname"])
+			m.SystemInfo.KernelVersion = s.labels["release"]
+		case "node_boot_time_seconds":
+			if boot := time.Unix(int64(s.value), 0); boot.Before(now) {
+				m.SystemInfo.Uptime = uint64(now.Sub(boot).Seconds())
+			}
+		case "node_filesystem_size_bytes", "node_filesystem_avail_bytes", "node_filesystem_free_bytes":
+			mount := s.labels["mountpoint"]
+			if mount == "" || pseudoFilesystems[s.labels["fstype"]] {
+				continue
+			}
+			fs, exists := filesystems[mount]
+			if !exists {
+				fs = &filesystem{device: s.labels["device"], fsType: s.labels["fstype"]}
+				filesystems[mount] = fs
+			}
+			switch s.name {
+			case "node_filesystem_size_bytes":
+				fs.size = s.value
+			case "node_filesystem_avail_bytes":
+				fs.avail = s.value
+			default:
+				fs.free = s.value
+			}
+		case "container_cpu_usage_seconds_total", "container_memory_usage_bytes", "container_spec_memory_limit_bytes":
+			// cAdvisor also reports cgroups that aren't containers, without a name
+			name := s.labels["name"]
+			if name == "" {
+				continue
+			}
+			c, exists := containers[name]
+			if !exists {
+				c = &container{id: s.labels["id"], image: s.labels["image"]}
+				containers[name] = c
+			}
+			switch s.name {
+			case "container_cpu_usage_seconds_total":
+				c.cpuSeconds += s.value
+				if s.at.After(c.cpuAt) {
+					c.cpuAt = s.at
+				}
+			case "container_memory_usage_bytes":
+				c.memory = s.value
+			default:
+				c.memoryMax = s.value
+			}
+		}
+	}
+
+	m.Timestamp = newest
+
+	// CPU usage is the share of non-idle time since the last rebuild
+	if cpuTotal > 0 {
+		if h.cpu.value > 0 && cpuTotal > h.cpu.value {
+			busy := 1 - (cpuIdle-h.cpu.idle)/(cpuTotal-h.cpu.value)
+			h.cpuPercent = min(max(busy*100, 0), 100)
+		}
+		h.cpu = counterReading{value: cpuTotal, idle: cpuIdle, at: cpuAt}
+	}
+	m.CPU.UsagePercent = h.cpuPercent
+
+	if memTotal > 0 {
+		m.Memory.Total = uint64(memTotal)
+		m.Memory.Available = uint64(memAvailable)
+		m.Memory.Used = uint64(memTotal - memAvailable)
+		m.Memory.UsedPercent = (memTotal - memAvailable) / memTotal * 100
+	}
+	if swapTotal > 0 {
+		m.Memory.SwapTotal = uint64(swapTotal)
+		m.Memory.SwapUsed = uint64(swapTotal - swapFree)
+		m.Memory.SwapPercent = (swapTotal - swapFree) / swapTotal * 100
+	}
+
+	mounts := make([]string, 0, len(filesystems))
+	for mount := range filesystems {
+		mounts = append(mounts, mount)
+	}
+	sort.Strings(mounts)
+	for _, mount := range mounts {
+		fs := filesystems[mount]
+		if fs.size <= 0 {
+			continue
+		}
+		used := fs.size - fs.free
+		disk := metrics.DiskMetrics{
+			MountPoint: mount,
+			Device:     fs.device,
+			FSType:     fs.fsType,
+			Total:      uint64(fs.size),
+			Used:       uint64(used),
+			Free:       uint64(fs.avail),
+		}
+		// As df: the share of what non-root users can use
+		if used+fs.avail > 0 {
+			disk.UsedPercent = used / (used + fs.avail) * 100
+		}
+		m.Disk = append(m.Disk, disk)
+	}
+
+	names := make([]string, 0, len(containers))
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	states := make([]server.ContainerState, 0, len(names))
+	for _, name := range names {
+		c := containers[name]
+		if previous, exists := h.containerCPU[name]; exists && c.cpuAt.After(previous.at) && c.cpuSeconds >= previous.value {
+			h.containerPct[name] = (c.cpuSeconds - previous.value) / c.cpuAt.Sub(previous.at).Seconds() * 100
+		}
+		h.containerCPU[name] = counterReading{value: c.cpuSeconds, at: c.cpuAt}
+
+		id := c.id
+		if id == "" {
+			id = name
+		}
+		state := server.ContainerState{
+			ID:          id,
+			Name:        name,
+			Image:       c.image,
+			State:       "running",
+			CPUPercent:  h.containerPct[name],
+			MemoryUsage: uint64(c.memory),
+		}
+		// Unlimited containers report a limit near the maximum int64
+		if c.memoryMax > 0 && c.memoryMax < 1<<60 {
+			state.MemoryLimit = uint64(c.memoryMax)
+			state.MemoryPercent = c.memory / c.memoryMax * 100
+		}
+		states = append(states, state)
+	}
+	for name := range h.containerCPU {
+		if _, exists := containers[name]; !exists {
+			delete(h.containerCPU, name)
+			delete(h.containerPct, name)
+		}
+	}
+
+	return &server.ServerState{
+		AgentName:     agentName,
+		LastSeen:      now,
+		SystemMetrics: m,
+		Containers:    states,
+		ActiveAlerts:  []server.Alert{},
+	}
+}
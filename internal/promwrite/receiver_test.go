@@ -0,0 +1,260 @@
+package promwrite
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"testing"
+	"time"
+)
+
+// encodeRequest encodes series as a WriteRequest in a snappy block of
+// literals, as a remote_write client would send them
+func encodeRequest(series []TimeSeries) []byte {
+	var req []byte
+	for _, ts := range series {
+		var msg []byte
+		names := make([]string, 0, len(ts.Labels))
+		for name := range ts.Labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			label := appendBytesField(nil, 1, []byte(name))
+			label = appendBytesField(label, 2, []byte(ts.Labels[name]))
+			msg = appendBytesField(msg, 1, label)
+		}
+		for _, s := range ts.Samples {
+			sample := binary.AppendUvarint(nil, 1<<3|1)
+			sample = binary.LittleEndian.AppendUint64(sample, math.Float64bits(s.Value))
+			sample = binary.AppendUvarint(sample, 2<<3|0)
+			sample = binary.AppendUvarint(sample, uint64(s.Timestamp))
+			msg = appendBytesField(msg, 2, sample)
+		}
+		req = appendBytesField(req, 1, msg)
+	}
+
+	block := binary.AppendUvarint(nil, uint64(len(req)))
+	for len(req) > 0 {
+		n := min(len(req), 1<<16)
+		block = append(block, 61<<2, byte(n-1), byte((n-1)>>8))
+		block = append(block, req[:n]...)
+		req = req[n:]
+	}
+	return block
+}
+
+func appendBytesField(b []byte, field int, value []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// gauge is a single-sample series
+func gauge(name string, value float64, at time.Time, labels ...string) TimeSeries {
+	ts := TimeSeries{
+		Labels:  map[string]string{"__name__": name, "instance": "db-1:9100", "job": "node"},
+		Samples: []Sample{{Value: value, Timestamp: at.UnixMilli()}},
+	}
+	for i := 0; i+1 < len(labels); i += 2 {
+		ts.Labels[labels[i]] = labels[i+1]
+	}
+	return ts
+}
+
+func TestDecode(t *testing.T) {
+	at := time.UnixMilli(1700000000000)
+	body := encodeRequest([]TimeSeries{
+		gauge("node_load1", 1.5, at),
+		{
+			Labels: map[string]string{"__name__": "up"},
+			Samples: []Sample{
+				{Value: 1, Timestamp: 1000},
+				{Value: 0, Timestamp: 2000},
+			},
+		},
+	})
+
+	series, err := Decode(body)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 series, got %d", len(series))
+	}
+	if series[0].Labels["__name__"] != "node_load1" || series[0].Labels["instance"] != "db-1:9100" {
+		t.Errorf("Unexpected labels: %v", series[0].Labels)
+	}
+	if len(series[0].Samples) != 1 || series[0].Samples[0].Value != 1.5 || series[0].Samples[0].Timestamp != at.UnixMilli() {
+		t.Errorf("Unexpected samples: %v", series[0].Samples)
+	}
+	if len(series[1].Samples) != 2 || series[1].Samples[1].Timestamp != 2000 {
+		t.Errorf("Unexpected samples: %v", series[1].Samples)
+	}
+
+	if _, err := Decode([]byte{0x05, 0x04 << 2, 0xff, 0xff, 0xff, 0xff, 0xff}); err == nil {
+		t.Error("Expected an error for an invalid WriteRequest")
+	}
+}
+
+func TestApply_NodeExporter(t *testing.T) {
+	r := NewReceiver()
+	now := time.Now()
+	t1 := now.Add(-15 * time.Second)
+
+	states := r.Apply([]TimeSeries{
+		gauge("node_cpu_seconds_total", 100, t1, "cpu", "0", "mode", "idle"),
+		gauge("node_cpu_seconds_total", 100, t1, "cpu", "0", "mode", "user"),
+		gauge("node_load1", 0.5, t1),
+		gauge("node_memory_MemTotal_bytes", 1000, t1),
+		gauge("node_memory_MemAvailable_bytes", 250, t1),
+		gauge("node_filesystem_size_bytes", 100, t1, "mountpoint", "/", "fstype", "ext4", "device", "/dev/sda1"),
+		gauge("node_filesystem_free_bytes", 40, t1, "mountpoint", "/", "fstype", "ext4", "device", "/dev/sda1"),
+		gauge("node_filesystem_avail_bytes", 30, t1, "mountpoint", "/", "fstype", "ext4", "device", "/dev/sda1"),
+		gauge("node_filesystem_size_bytes", 100, t1, "mountpoint", "/run", "fstype", "tmpfs"),
+		gauge("node_uname_info", 1, t1, "nodename", "db-1.internal", "sysname", "Linux", "release", "6.1.0"),
+		gauge("node_network_receive_bytes_total", 1, t1, "device", "eth0"),
+	}, now)
+
+	if len(states) != 1 {
+		t.Fatalf("Expected 1 state, got %d", len(states))
+	}
+	state := states[0]
+	if state.AgentName != "db-1" {
+		t.Errorf("Expected agent db-1, got %s", state.AgentName)
+	}
+	m := state.SystemMetrics
+	if m.CPU.UsagePercent != 0 {
+		t.Errorf("Expected no CPU usage before a second reading, got %f", m.CPU.UsagePercent)
+	}
+	if m.CPU.LoadAvg1 != 0.5 {
+		t.Errorf("Expected load 0.5, got %f", m.CPU.LoadAvg1)
+	}
+	if m.Memory.Used != 750 || m.Memory.UsedPercent != 75 {
+		t.Errorf("Expected 750 bytes (75%%) of memory used, got %d (%f%%)", m.Memory.Used, m.Memory.UsedPercent)
+	}
+	if len(m.Disk) != 1 {
+		t.Fatalf("Expected 1 disk without tmpfs, got %d", len(m.Disk))
+	}
+	// 60 used of the 90 available to users
+	if disk := m.Disk[0]; disk.MountPoint != "/" || disk.Used != 60 || math.Abs(disk.UsedPercent-66.67) > 0.01 {
+		t.Errorf("Unexpected disk: %+v", disk)
+	}
+	if m.SystemInfo.Hostname != "db-1.internal" || m.SystemInfo.OS != "linux" || m.SystemInfo.KernelVersion != "6.1.0" {
+		t.Errorf("Unexpected system info: %+v", m.SystemInfo)
+	}
+	if !m.Timestamp.Equal(time.UnixMilli(t1.UnixMilli())) {
+		t.Errorf("Expected timestamp of the newest sample, got %s", m.Timestamp)
+	}
+
+	// A later write with only the CPU counters keeps the rest
+	t2 := t1.Add(15 * time.Second)
+	states = r.Apply([]TimeSeries{
+		gauge("node_cpu_seconds_total", 110, t2, "cpu", "0", "mode", "idle"),
+		gauge("node_cpu_seconds_total", 130, t2, "cpu", "0", "mode", "user"),
+	}, now)
+	m = states[0].SystemMetrics
+	// 30 of the 40 seconds since were busy
+	if m.CPU.UsagePercent != 75 {
+		t.Errorf("Expected 75%% CPU usage, got %f", m.CPU.UsagePercent)
+	}
+	if m.Memory.UsedPercent != 75 || len(m.Disk) != 1 {
+		t.Error("Expected earlier series to be kept")
+	}
+}
+
+func TestApply_AgentLabel(t *testing.T) {
+	r := NewReceiver()
+	now := time.Now()
+
+	states := r.Apply([]TimeSeries{
+		gauge("node_load1", 1, now, AgentLabel, "payments-db"),
+		gauge("node_load1", 2, now, "instance", "cache-1"),
+	}, now)
+
+	if len(states) != 2 {
+		t.Fatalf("Expected 2 states, got %d", len(states))
+	}
+	if states[0].AgentName != "cache-1" || states[1].AgentName != "payments-db" {
+		t.Errorf("Expected agents cache-1 and payments-db, got %s and %s", states[0].AgentName, states[1].AgentName)
+	}
+}
+
+func TestApply_Containers(t *testing.T) {
+	r := NewReceiver()
+	now := time.Now()
+	t1 := now.Add(-10 * time.Second)
+
+	r.Apply([]TimeSeries{
+		gauge("container_cpu_usage_seconds_total", 10, t1, "name", "api", "id", "/docker/abc", "image", "api:1.2", "cpu", "cpu0"),
+		gauge("container_cpu_usage_seconds_total", 10, t1, "name", "api", "id", "/docker/abc", "image", "api:1.2", "cpu", "cpu1"),
+		gauge("container_cpu_usage_seconds_total", 500, t1, "id", "/system.slice"),
+	}, now)
+
+	t2 := t1.Add(10 * time.Second)
+	states := r.Apply([]TimeSeries{
+		gauge("container_cpu_usage_seconds_total", 13, t2, "name", "api", "id", "/docker/abc", "image", "api:1.2", "cpu", "cpu0"),
+		gauge("container_cpu_usage_seconds_total", 12, t2, "name", "api", "id", "/docker/abc", "image", "api:1.2", "cpu", "cpu1"),
+		gauge("container_memory_usage_bytes", 256, t2, "name", "api", "id", "/docker/abc", "image", "api:1.2"),
+		gauge("container_spec_memory_limit_bytes", 1024, t2, "name", "api", "id", "/docker/abc", "image", "api:1.2"),
+	}, now)
+
+	containers := states[0].Containers
+	if len(containers) != 1 {
+		t.Fatalf("Expected 1 container without the unnamed cgroup, got %d", len(containers))
+	}
+	c := containers[0]
+	if c.Name != "api" || c.ID != "/docker/abc" || c.Image != "api:1.2" || c.State != "running" {
+		t.Errorf("Unexpected container: %+v", c)
+	}
+	// 5 CPU seconds over 10 seconds
+	if c.CPUPercent != 50 {
+		t.Errorf("Expected 50%% CPU, got %f", c.CPUPercent)
+	}
+	if c.MemoryUsage != 256 || c.MemoryLimit != 1024 || c.MemoryPercent != 25 {
+		t.Errorf("Expected 256 of 1024 bytes (25%%), got %d of %d (%f%%)", c.MemoryUsage, c.MemoryLimit, c.MemoryPercent)
+	}
+}
+
+func TestApply_DropsStaleSeries(t *testing.T) {
+	r := NewReceiver()
+	now := time.Now()
+	old := now.Add(-time.Hour)
+
+	r.Apply([]TimeSeries{
+		gauge("node_filesystem_size_bytes", 100, old, "mountpoint", "/mnt/usb", "fstype", "ext4"),
+		gauge("node_filesystem_avail_bytes", 50, old, "mountpoint", "/mnt/usb", "fstype", "ext4"),
+	}, old)
+	states := r.Apply([]TimeSeries{gauge("node_load1", 1, now)}, now)
+
+	if len(states[0].SystemMetrics.Disk) != 0 {
+		t.Errorf("Expected the unmounted filesystem to be dropped, got %v", states[0].SystemMetrics.Disk)
+	}
+}
+
+func TestApply_ForgetsIdleHosts(t *testing.T) {
+	r := NewReceiver()
+	start := time.Now()
+
+	r.Apply([]TimeSeries{
+		gauge("node_load1", 1, start, "instance", "old-1:9100"),
+		gauge("node_load1", 1, start, "instance", "db-1:9100"),
+	}, start)
+
+	// db-1 keeps writing, old-1 was relabeled away
+	later := start.Add(30 * time.Minute)
+	r.Apply([]TimeSeries{gauge("node_load1", 1, later)}, later)
+	if len(r.hosts) != 2 {
+		t.Fatalf("Expected both hosts kept within forgetAfter, got %d", len(r.hosts))
+	}
+
+	later = start.Add(forgetAfter + time.Minute)
+	r.Apply([]TimeSeries{gauge("node_load1", 1, later)}, later)
+
+	if _, exists := r.hosts["old-1"]; exists {
+		t.Error("Expected the idle host to be forgotten")
+	}
+	if _, exists := r.hosts["db-1"]; !exists {
+		t.Error("Expected the host still writing to be kept")
+	}
+}
@@ -0,0 +1,86 @@
+package promwrite
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var errCorrupt = errors.New("snappy: corrupt input")
+
+// decodeSnappy decodes a snappy block, the compression remote_write uses.
+// It refuses blocks that claim to decode to more than maxSize bytes.
+func decodeSnappy(src []byte, maxSize int) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, errCorrupt
+	}
+	if length > uint64(maxSize) {
+		return nil, fmt.Errorf("snappy: decoded size %d exceeds the limit of %d bytes", length, maxSize)
+	}
+	src = src[n:]
+	dst := make([]byte, 0, length)
+
+	for len(src) > 0 {
+		tag := src[0]
+		var literal, copyLength, offset int
+		switch tag & 0x03 {
+		case 0x00: // Literal, its length-1 in the tag or the next 1-4 bytes
+			literal = int(tag >> 2)
+			src = src[1:]
+			if literal >= 60 {
+				extra := literal - 59
+				if len(src) < extra {
+					return nil, errCorrupt
+				}
+				literal = 0
+				for i := extra - 1; i >= 0; i-- {
+					literal = literal<<8 | int(src[i])
+				}
+				src = src[extra:]
+			}
+			literal++
+			if literal > len(src) || len(dst)+literal > int(length) {
+				return nil, errCorrupt
+			}
+			dst = append(dst, src[:literal]...)
+			src = src[literal:]
+			continue
+		case 0x01: // Copy with a 1-byte offset
+			if len(src) < 2 {
+				return nil, errCorrupt
+			}
+			copyLength = 4 + int(tag>>2)&0x07
+			offset = int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+		case 0x02: // Copy with a 2-byte offset
+			if len(src) < 3 {
+				return nil, errCorrupt
+			}
+			copyLength = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 0x03: // Copy with a 4-byte offset
+			if len(src) < 5 {
+				return nil, errCorrupt
+			}
+			copyLength = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) || len(dst)+copyLength > int(length) {
+			return nil, errCorrupt
+		}
+		// Byte by byte, copies may overlap what they append
+		start := len(dst) - offset
+		for i := 0; i < copyLength; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+
+	if len(dst) != int(length) {
+		return nil, errCorrupt
+	}
+	return dst, nil
+}
@@ -0,0 +1,69 @@
+package promwrite
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeSnappy(t *testing.T) {
+	tests := []struct {
+		name  string
+		block []byte
+		want  string
+	}{
+		{"empty", []byte{0x00}, ""},
+		{"literal", []byte{0x05, 0x04 << 2, 'h', 'e', 'l', 'l', 'o'}, "hello"},
+		{
+			// "abcd" then a 1-byte offset copy of 4 bytes from 4 back
+			"copy",
+			[]byte{0x08, 0x03 << 2, 'a', 'b', 'c', 'd', 0x01, 0x04},
+			"abcdabcd",
+		},
+		{
+			// "ab" then a 2-byte offset copy of 6 bytes from 2 back, overlapping
+			"overlapping copy",
+			[]byte{0x08, 0x01 << 2, 'a', 'b', 0x02 | 5<<2, 0x02, 0x00},
+			"abababab",
+		},
+		{
+			// A literal whose length is in the following byte
+			"long literal",
+			append([]byte{0x46, 60 << 2, 69}, bytes.Repeat([]byte{'x'}, 70)...),
+			string(bytes.Repeat([]byte{'x'}, 70)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeSnappy(tt.block, 1024)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDecodeSnappy_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		block []byte
+	}{
+		{"no length", []byte{}},
+		{"short literal", []byte{0x05, 0x04 << 2, 'h', 'i'}},
+		{"offset before start", []byte{0x08, 0x00, 'a', 0x01, 0x04}},
+		{"longer than declared", []byte{0x01, 0x01 << 2, 'a', 'b'}},
+		{"shorter than declared", []byte{0x03, 0x00, 'a'}},
+		{"over the limit", []byte{0x80, 0x10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeSnappy(tt.block, 1024); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
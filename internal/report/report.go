@@ -0,0 +1,224 @@
+// Package report builds fleet summary reports and posts them to a
+// notification channel on a daily or weekly schedule.
+package report
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// Sender posts a report to a notification channel
+type Sender interface {
+	SendReport(title, text string) error
+}
+
+// Report summarises the fleet over a period
+type Report struct {
+	Title string    `json:"title"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+
+	Agents   int            `json:"agents"`
+	Statuses map[string]int `json:"statuses"` // Agents by current status
+
+	// Nil when the server keeps no history. Its agents are only the least
+	// available ones below 100%.
+	Availability *server.FleetUptime `json:"availability,omitempty"`
+
+	// Highest average usage over the period, or current usage without
+	// history
+	TopCPU    []Ranked `json:"top_cpu"`
+	TopMemory []Ranked `json:"top_memory"`
+
+	Alerts         int      `json:"alerts"` // Triggered during the period
+	AlertsByType   []Ranked `json:"alerts_by_type"`
+	NoisiestAgents []Ranked `json:"noisiest_agents"`
+}
+
+// Ranked is a name and its value in a top list
+type Ranked struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// Build summarises the period from..to. history may be nil. Top lists hold
+// at most top entries.
+func Build(store *server.StateStore, history *server.History, title string, from, to time.Time, top int) *Report {
+	r := &Report{Title: title, From: from, To: to, Statuses: make(map[string]int)}
+
+	var cpu, memory []Ranked
+	for _, agent := range store.GetAllAgents() {
+		r.Agents++
+		r.Statuses[agent.Status]++
+
+		cpuValue, memoryValue := agent.SystemMetrics.CPU.UsagePercent, agent.SystemMetrics.Memory.UsedPercent
+		if history != nil {
+			samples := history.Samples(agent.AgentName, from, to)
+			if len(samples) == 0 {
+				continue
+			}
+			cpuValue, memoryValue = 0, 0
+			for _, s := range samples {
+				cpuValue += s.CPUPercent
+				memoryValue += s.MemoryPercent
+			}
+			cpuValue /= float64(len(samples))
+			memoryValue /= float64(len(samples))
+		}
+		cpu = append(cpu, Ranked{Name: agent.AgentName, Value: cpuValue})
+		memory = append(memory, Ranked{Name: agent.AgentName, Value: memoryValue})
+	}
+	r.TopCPU = topOf(cpu, top)
+	r.TopMemory = topOf(memory, top)
+
+	if history != nil {
+		availability := history.FleetUptime(from, to)
+		n := 0
+		for n < len(availability.Agents) && n < top && availability.Agents[n].AvailabilityPercent < 100 {
+			n++
+		}
+		availability.Agents = availability.Agents[:n]
+		r.Availability = &availability
+	}
+
+	byType := make(map[string]float64)
+	byAgent := make(map[string]float64)
+	for _, alert := range store.GetAlertsByStatus("all") {
+		if alert.TriggeredAt.Before(from) || alert.TriggeredAt.After(to) {
+			continue
+		}
+		r.Alerts++
+		byType[alert.AlertType]++
+		byAgent[alert.AgentName]++
+	}
+	r.AlertsByType = topOf(ranked(byType), top)
+	r.NoisiestAgents = topOf(ranked(byAgent), top)
+	return r
+}
+
+// ranked turns counts into a list for topOf
+func ranked(counts map[string]float64) []Ranked {
+	list := make([]Ranked, 0, len(counts))
+	for name, count := range counts {
+		list = append(list, Ranked{Name: name, Value: count})
+	}
+	return list
+}
+
+// topOf returns the n highest values, ties by name
+func topOf(list []Ranked, n int) []Ranked {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Value != list[j].Value {
+			return list[i].Value > list[j].Value
+		}
+		return list[i].Name < list[j].Name
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// Text formats the report for a chat message
+func (r *Report) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s – %s\n", r.From.Format("Mon 2 Jan 15:04"), r.To.Format("Mon 2 Jan 15:04"))
+
+	statuses := make([]string, 0, len(r.Statuses))
+	for status, count := range r.Statuses {
+		statuses = append(statuses, fmt.Sprintf("%d %s", count, status))
+	}
+	sort.Strings(statuses)
+	fmt.Fprintf(&b, "\n*Fleet*: %d agents", r.Agents)
+	if len(statuses) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(statuses, ", "))
+	}
+	b.WriteString("\n")
+
+	if a := r.Availability; a != nil {
+		fmt.Fprintf(&b, "*Availability*: %.3f%%, %d offline incidents", a.AvailabilityPercent, a.Incidents)
+		if a.MTTRSeconds > 0 {
+			fmt.Fprintf(&b, ", MTTR %s", (time.Duration(a.MTTRSeconds) * time.Second).Round(time.Second))
+		}
+		b.WriteString("\n")
+		for _, agent := range a.Agents {
+			fmt.Fprintf(&b, "• %s %.3f%%\n", agent.AgentName, agent.AvailabilityPercent)
+		}
+	}
+
+	writeRanked(&b, "Top CPU", r.TopCPU, "%.1f%%")
+	writeRanked(&b, "Top memory", r.TopMemory, "%.1f%%")
+
+	fmt.Fprintf(&b, "\n*Alerts*: %d\n", r.Alerts)
+	writeRanked(&b, "By type", r.AlertsByType, "%.0f")
+	writeRanked(&b, "Noisiest agents", r.NoisiestAgents, "%.0f")
+	return b.String()
+}
+
+func writeRanked(b *strings.Builder, title string, list []Ranked, format string) {
+	if len(list) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n*%s*\n", title)
+	for _, entry := range list {
+		fmt.Fprintf(b, "• %s "+format+"\n", entry.Name, entry.Value)
+	}
+}
+
+// Schedule is when reports go out, in the server's local time
+type Schedule struct {
+	Weekly  bool
+	Weekday time.Weekday // Weekly reports only
+	Hour    int
+	Minute  int
+}
+
+// Period is how far back each report looks
+func (s Schedule) Period() time.Duration {
+	if s.Weekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Next returns the first time a report is due after t
+func (s Schedule) Next(t time.Time) time.Time {
+	next := time.Date(t.Year(), t.Month(), t.Day(), s.Hour, s.Minute, 0, 0, t.Location())
+	for !next.After(t) || (s.Weekly && next.Weekday() != s.Weekday) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Run posts a report on schedule until ctx is done
+func Run(ctx context.Context, store *server.StateStore, history *server.History, sender Sender, schedule Schedule, top int) {
+	title := "📊 Saviour daily report"
+	if schedule.Weekly {
+		title = "📊 Saviour weekly report"
+	}
+
+	logger := logging.Component("report")
+	for {
+		next := schedule.Next(time.Now())
+		logger.Info("Next summary report", "at", next)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		to := time.Now()
+		report := Build(store, history, title, to.Add(-schedule.Period()), to, top)
+		if err := sender.SendReport(report.Title, report.Text()); err != nil {
+			logger.Error("Failed to send summary report", logging.Err(err))
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		want     time.Time
+	}{
+		{"daily later today", Schedule{Hour: 18}, time.Date(2024, 1, 3, 18, 0, 0, 0, time.UTC)},
+		{"daily tomorrow", Schedule{Hour: 9, Minute: 30}, time.Date(2024, 1, 4, 9, 30, 0, 0, time.UTC)},
+		{"daily exactly now", Schedule{Hour: 10}, time.Date(2024, 1, 4, 10, 0, 0, 0, time.UTC)},
+		{"weekly", Schedule{Weekly: true, Weekday: time.Monday, Hour: 9}, time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)},
+		{"weekly later today", Schedule{Weekly: true, Weekday: time.Wednesday, Hour: 11}, time.Date(2024, 1, 3, 11, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Next(now); !got.Equal(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBuild_CurrentUsage(t *testing.T) {
+	store := server.NewStateStore()
+	for name, cpu := range map[string]float64{"web-1": 90, "web-2": 10, "db-1": 50} {
+		store.UpdateAgent(&server.ServerState{
+			AgentName: name,
+			Status:    "online",
+			SystemMetrics: metrics.SystemMetrics{
+				CPU:    metrics.CPUMetrics{UsagePercent: cpu},
+				Memory: metrics.MemoryMetrics{UsedPercent: 100 - cpu},
+			},
+		})
+	}
+
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	store.AddAlert(&server.Alert{ID: "1", AgentName: "web-1", AlertType: "cpu_high", TriggeredAt: to.Add(-time.Hour)})
+	store.AddAlert(&server.Alert{ID: "2", AgentName: "web-1", AlertType: "cpu_high", TriggeredAt: to.Add(-2 * time.Hour)})
+	store.AddAlert(&server.Alert{ID: "3", AgentName: "db-1", AlertType: "disk_high", TriggeredAt: to.Add(-3 * time.Hour)})
+	// Before the period
+	store.AddAlert(&server.Alert{ID: "4", AgentName: "db-1", AlertType: "disk_high", TriggeredAt: from.Add(-time.Hour)})
+
+	r := Build(store, nil, "Daily", from, to, 2)
+
+	if r.Agents != 3 || r.Statuses["online"] != 3 {
+		t.Errorf("Expected 3 online agents, got %d (%v)", r.Agents, r.Statuses)
+	}
+	if r.Availability != nil {
+		t.Error("Expected no availability without history")
+	}
+	if len(r.TopCPU) != 2 || r.TopCPU[0].Name != "web-1" || r.TopCPU[1].Name != "db-1" {
+		t.Errorf("Expected web-1 and db-1 as top CPU, got %+v", r.TopCPU)
+	}
+	if len(r.TopMemory) != 2 || r.TopMemory[0].Name != "web-2" {
+		t.Errorf("Expected web-2 as top memory, got %+v", r.TopMemory)
+	}
+	if r.Alerts != 3 {
+		t.Errorf("Expected 3 alerts in the period, got %d", r.Alerts)
+	}
+	if len(r.AlertsByType) != 2 || r.AlertsByType[0] != (Ranked{Name: "cpu_high", Value: 2}) {
+		t.Errorf("Expected cpu_high first with 2 alerts, got %+v", r.AlertsByType)
+	}
+	if len(r.NoisiestAgents) != 2 || r.NoisiestAgents[0] != (Ranked{Name: "web-1", Value: 2}) {
+		t.Errorf("Expected web-1 as noisiest agent, got %+v", r.NoisiestAgents)
+	}
+
+	text := r.Text()
+	for _, want := range []string{"*Fleet*: 3 agents (3 online)", "*Top CPU*", "• web-1 90.0%", "*Alerts*: 3", "• cpu_high 2"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected report text to contain %q, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestBuild_History(t *testing.T) {
+	store := server.NewStateStore()
+	store.UpdateAgent(&server.ServerState{AgentName: "web-1", Status: "online"})
+	store.UpdateAgent(&server.ServerState{AgentName: "web-2", Status: "online"})
+
+	history := server.NewHistory(24*time.Hour, 0)
+	start := time.Now().Truncate(time.Minute).Add(-4 * time.Hour)
+	history.Record("web-1", server.HistorySample{Time: start, CPUPercent: 20})
+	history.Record("web-1", server.HistorySample{Time: start.Add(time.Minute), CPUPercent: 40})
+	history.StatusChanged("web-1", "", "online", start, start)
+	history.StatusChanged("web-2", "", "online", start, start)
+	history.StatusChanged("web-2", "online", "offline", start.Add(time.Hour), start.Add(time.Hour))
+	history.StatusChanged("web-2", "offline", "online", start.Add(2*time.Hour), start.Add(2*time.Hour))
+
+	r := Build(store, history, "Daily", start, start.Add(4*time.Hour), 5)
+
+	// web-2 has no samples in the period
+	if len(r.TopCPU) != 1 || r.TopCPU[0] != (Ranked{Name: "web-1", Value: 30}) {
+		t.Errorf("Expected web-1 averaging 30%%, got %+v", r.TopCPU)
+	}
+	if r.Availability == nil {
+		t.Fatal("Expected availability with history")
+	}
+	if r.Availability.Incidents != 1 {
+		t.Errorf("Expected 1 incident, got %d", r.Availability.Incidents)
+	}
+	// Only agents below 100% are listed
+	if len(r.Availability.Agents) != 1 || r.Availability.Agents[0].AgentName != "web-2" {
+		t.Errorf("Expected only web-2 listed, got %+v", r.Availability.Agents)
+	}
+	if text := r.Text(); !strings.Contains(text, "1 offline incidents, MTTR 1h0m0s") {
+		t.Errorf("Expected availability with MTTR in report text, got:\n%s", text)
+	}
+}
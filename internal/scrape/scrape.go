@@ -0,0 +1,140 @@
+// Package scrape fetches metrics from agents running in pull mode, for
+// hosts that can't connect out to the server. A successful scrape counts
+// as the agent's heartbeat, and its metrics are stored like a push.
+package scrape
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/internal/tracing"
+)
+
+// maxResponseSize caps a scrape response, like server.max_request_size
+// caps a push
+const maxResponseSize = 10 * 1024 * 1024
+
+// Target is an agent serving its metrics for the server to scrape
+type Target struct {
+	URL      string // Agent base URL
+	Token    string // Sent as a bearer token
+	Interval time.Duration
+	Timeout  time.Duration
+	CAFile   string // CA to verify the agent's certificate with (empty = system roots)
+}
+
+// Ingest stores scraped metrics the way pushed ones are, see
+// api.Handler.IngestMetrics
+type Ingest func(ctx context.Context, payload *server.MetricsPushPayload, receivedAt time.Time) error
+
+// Scraper scrapes one agent
+type Scraper struct {
+	logger *slog.Logger
+	target Target
+	client *http.Client
+	store  *server.StateStore
+	ingest Ingest
+}
+
+// New creates a scraper for a target, storing what it scrapes through
+// ingest
+func New(target Target, store *server.StateStore, ingest Ingest) (*Scraper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if target.CAFile != "" {
+		pem, err := os.ReadFile(target.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA file %s", target.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+
+	return &Scraper{
+		logger: logging.Component("scrape"),
+		target: target,
+		client: &http.Client{Timeout: target.Timeout, Transport: transport},
+		store:  store,
+		ingest: ingest,
+	}, nil
+}
+
+// Run scrapes the agent every interval until ctx is done
+func (s *Scraper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.target.Interval)
+	defer ticker.Stop()
+
+	s.logger.Info("Scraping agent", "url", s.target.URL, "interval", s.target.Interval)
+	for {
+		if err := s.Scrape(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Warn("Scrape failed", "url", s.target.URL, logging.Err(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scrape fetches the agent's latest metrics and stores them, recording the
+// scrape as a heartbeat
+func (s *Scraper) Scrape(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "agent.scrape", tracing.KindClient)
+	span.SetAttr("url.full", s.target.URL)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.target.URL, "/")+"/api/v1/metrics", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.target.Token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("agent returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var scraped server.ScrapeResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&scraped); err != nil {
+		return fmt.Errorf("invalid scrape response: %w", err)
+	}
+	agentName := scraped.AgentName
+	if agentName == "" {
+		return fmt.Errorf("scrape response has no agent_name")
+	}
+	span.SetAttr("agent.name", agentName)
+
+	if err := s.ingest(ctx, &scraped.MetricsPushPayload, time.Now()); err != nil {
+		return fmt.Errorf("rejected metrics of %s: %w", agentName, err)
+	}
+	s.store.UpdateHeartbeat(agentName)
+	s.store.SetHeartbeatInterval(agentName, s.target.Interval)
+	if scraped.AgentVersion != "" {
+		s.store.SetAgentVersion(agentName, scraped.AgentVersion)
+	}
+	s.logger.Debug("Scraped agent", "agent", agentName, "url", s.target.URL)
+	return nil
+}
@@ -0,0 +1,83 @@
+package scrape
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/server"
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+// agent serves a scrape response to requests with the token "secret"
+func agent(t *testing.T, resp server.ScrapeResponse) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/metrics" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestScrape(t *testing.T) {
+	ts := agent(t, server.ScrapeResponse{
+		MetricsPushPayload: server.MetricsPushPayload{
+			AgentName:     "db-1",
+			SystemMetrics: metrics.SystemMetrics{AgentName: "db-1", CPU: metrics.CPUMetrics{UsagePercent: 42}},
+		},
+		AgentVersion: "v1.2.0",
+	})
+	store := server.NewStateStore()
+	var ingested []*server.MetricsPushPayload
+	ingest := func(ctx context.Context, payload *server.MetricsPushPayload, receivedAt time.Time) error {
+		ingested = append(ingested, payload)
+		store.UpdateAgent(&server.ServerState{AgentName: payload.AgentName, SystemMetrics: payload.SystemMetrics, LastSeen: receivedAt})
+		return nil
+	}
+
+	scraper, err := New(Target{URL: ts.URL + "/", Token: "secret", Interval: time.Minute, Timeout: 5 * time.Second}, store, ingest)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := scraper.Scrape(context.Background()); err != nil {
+		t.Fatalf("Scrape failed: %v", err)
+	}
+
+	if len(ingested) != 1 || ingested[0].SystemMetrics.CPU.UsagePercent != 42 {
+		t.Fatalf("Expected the scraped metrics ingested, got %+v", ingested)
+	}
+	state, exists := store.GetAgent("db-1")
+	if !exists || state.Status != "online" || state.AgentVersion != "v1.2.0" || state.LastHeartbeatAt.IsZero() {
+		t.Errorf("Expected an online agent with version and heartbeat, got %+v", state)
+	}
+}
+
+func TestScrape_Unauthorized(t *testing.T) {
+	ts := agent(t, server.ScrapeResponse{})
+	scraper, err := New(Target{URL: ts.URL, Token: "wrong", Interval: time.Minute, Timeout: 5 * time.Second}, server.NewStateStore(), nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	err = scraper.Scrape(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected a 401 error, got %v", err)
+	}
+}
+
+func TestNew_InvalidCAFile(t *testing.T) {
+	if _, err := New(Target{URL: "https://db-1:9150", CAFile: "/nonexistent/ca.pem"}, server.NewStateStore(), nil); err == nil {
+		t.Error("Expected error for a missing CA file")
+	}
+}
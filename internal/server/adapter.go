@@ -29,6 +29,15 @@ func (a *AlertingAdapter) GetAllAgents() []*alerting.ServerState {
 	return result
 }
 
+// GetAgent returns one agent in alerting format
+func (a *AlertingAdapter) GetAgent(agentName string) (*alerting.ServerState, bool) {
+	agent, exists := a.store.GetAgent(agentName)
+	if !exists {
+		return nil, false
+	}
+	return a.convertServerState(agent), true
+}
+
 // CheckOfflineAgents checks for offline agents
 func (a *AlertingAdapter) CheckOfflineAgents(timeout time.Duration) []*alerting.ServerState {
 	offline := a.store.CheckOfflineAgents(timeout)
@@ -41,6 +50,18 @@ func (a *AlertingAdapter) CheckOfflineAgents(timeout time.Duration) []*alerting.
 	return result
 }
 
+// ExpireAgents removes agents offline for longer than retention
+func (a *AlertingAdapter) ExpireAgents(retention time.Duration) []*alerting.ServerState {
+	expired := a.store.ExpireAgents(retention)
+	result := make([]*alerting.ServerState, len(expired))
+
+	for i, agent := range expired {
+		result[i] = a.convertServerState(agent)
+	}
+
+	return result
+}
+
 // AddAlert adds an alert
 func (a *AlertingAdapter) AddAlert(alert *alerting.Alert) {
 	serverAlert := &Alert{
@@ -54,23 +75,73 @@ func (a *AlertingAdapter) AddAlert(alert *alerting.Alert) {
 		ResolvedAt:  alert.ResolvedAt,
 		Status:      alert.Status,
 		NotifiedAt:  alert.NotifiedAt,
+		Annotations: alert.Annotations,
 	}
 	a.store.AddAlert(serverAlert)
 }
 
+// IsSilenced reports whether notifications for an alert are muted
+func (a *AlertingAdapter) IsSilenced(agentName, alertType string) bool {
+	return a.store.IsSilenced(agentName, alertType)
+}
+
+// AlertOwner returns who owns an open alert of this type for the agent
+func (a *AlertingAdapter) AlertOwner(agentName, alertType string) string {
+	return a.store.AlertOwner(agentName, alertType)
+}
+
+// CustomMetrics returns the custom metrics in alerting format
+func (a *AlertingAdapter) CustomMetrics() []alerting.CustomMetric {
+	metrics := a.store.CustomMetrics()
+	result := make([]alerting.CustomMetric, len(metrics))
+
+	for i := range metrics {
+		result[i] = metrics[i].AlertingMetric()
+	}
+
+	return result
+}
+
+// AlertingMetric returns the metric in alerting format
+func (m *CustomMetric) AlertingMetric() alerting.CustomMetric {
+	return alerting.CustomMetric{
+		Name:      m.Name,
+		Type:      m.Type,
+		Value:     m.Value,
+		Labels:    m.Labels,
+		AgentName: m.AgentName,
+		Service:   m.Service,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
 // convertServerState converts server.ServerState to alerting.ServerState
 func (a *AlertingAdapter) convertServerState(state *ServerState) *alerting.ServerState {
 	containers := make([]alerting.ContainerState, len(state.Containers))
 	for i, c := range state.Containers {
 		containers[i] = alerting.ContainerState{
-			ID:            c.ID,
-			Name:          c.Name,
-			State:         c.State,
-			PreviousState: c.PreviousState,
-			Health:        c.Health,
-			CPUPercent:    c.CPUPercent,
-			MemoryPercent: c.MemoryPercent,
-			RestartCount:  c.RestartCount,
+			ID:                  c.ID,
+			Name:                c.Name,
+			Image:               c.Image,
+			State:               c.State,
+			PreviousState:       c.PreviousState,
+			Health:              c.Health,
+			HealthCheckExitCode: c.HealthCheckExitCode,
+			HealthCheckOutput:   c.HealthCheckOutput,
+			CPUPercent:          c.CPUPercent,
+			MemoryPercent:       c.MemoryPercent,
+			RestartCount:        c.RestartCount,
+			UpdateAvailable:     c.UpdateAvailable,
+			Remediation:         convertRemediation(c.Remediation),
+			ExitCode:            c.ExitCode,
+			OOMKilled:           c.OOMKilled,
+			FinishedAt:          c.FinishedAt,
+			StateError:          c.StateError,
+			OOMKillsLastHour:    c.OOMKillsLastHour,
+		}
+		if c.Thresholds != nil {
+			containers[i].CPUThreshold = c.Thresholds.CPUThreshold
+			containers[i].MemoryThreshold = c.Thresholds.MemoryThreshold
 		}
 	}
 
@@ -87,6 +158,7 @@ func (a *AlertingAdapter) convertServerState(state *ServerState) *alerting.Serve
 			ResolvedAt:  a.ResolvedAt,
 			Status:      a.Status,
 			NotifiedAt:  a.NotifiedAt,
+			Annotations: a.Annotations,
 		}
 	}
 
@@ -94,6 +166,9 @@ func (a *AlertingAdapter) convertServerState(state *ServerState) *alerting.Serve
 		AgentName: state.AgentName,
 		Status:    state.Status,
 		LastSeen:  state.LastSeen,
+		ClockSkew: time.Duration(state.ClockSkewSeconds * float64(time.Second)),
+
+		InMaintenance: state.InMaintenance(time.Now()),
 		SystemMetrics: alerting.SystemMetrics{
 			CPU: alerting.CPUMetrics{
 				UsagePercent: state.SystemMetrics.CPU.UsagePercent,
@@ -101,7 +176,8 @@ func (a *AlertingAdapter) convertServerState(state *ServerState) *alerting.Serve
 			Memory: alerting.MemoryMetrics{
 				UsedPercent: state.SystemMetrics.Memory.UsedPercent,
 			},
-			Disk: a.convertDiskMetrics(state.SystemMetrics.Disk),
+			Disk:   a.convertDiskMetrics(state.SystemMetrics.Disk),
+			Docker: a.convertDockerMetrics(state.SystemMetrics.Docker),
 		},
 		Containers:   containers,
 		ActiveAlerts: alerts,
@@ -119,3 +195,31 @@ func (a *AlertingAdapter) convertDiskMetrics(disks []metrics.DiskMetrics) []aler
 	}
 	return result
 }
+
+// convertDockerMetrics converts Docker daemon metrics from metrics package
+func (a *AlertingAdapter) convertDockerMetrics(docker *metrics.DockerMetrics) *alerting.DockerMetrics {
+	if docker == nil {
+		return nil
+	}
+	return &alerting.DockerMetrics{
+		Goroutines:          docker.Goroutines,
+		DataRoot:            docker.DataRoot,
+		DataRootUsedPercent: docker.DataRootUsedPercent,
+		Error:               docker.Error,
+	}
+}
+
+// convertRemediation converts an agent remediation action to alerting format
+func convertRemediation(action *metrics.RemediationAction) *alerting.Remediation {
+	if action == nil {
+		return nil
+	}
+	return &alerting.Remediation{
+		Action:      action.Action,
+		Attempt:     action.Attempt,
+		MaxAttempts: action.MaxAttempts,
+		Success:     action.Success,
+		Error:       action.Error,
+		Timestamp:   action.Timestamp,
+	}
+}
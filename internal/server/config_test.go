@@ -3,8 +3,11 @@ package server
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/anurag/saviour/internal/logging"
 )
 
 func TestLoadConfig_ValidFile(t *testing.T) {
@@ -116,18 +119,45 @@ auth:
 	}
 
 	// Verify defaults applied
+	if want := filepath.Join(tmpDir, "alerting-settings.json"); cfg.Alerting.SettingsFile != want {
+		t.Errorf("Default alerting settings file = %v, want %v", cfg.Alerting.SettingsFile, want)
+	}
 	if cfg.Server.Host != "0.0.0.0" {
 		t.Errorf("Default host = %v, want 0.0.0.0", cfg.Server.Host)
 	}
 	if cfg.Server.Port != 8080 {
 		t.Errorf("Default port = %v, want 8080", cfg.Server.Port)
 	}
+	if cfg.Server.MaxInflightPushes != 512 {
+		t.Errorf("Default MaxInflightPushes = %v, want 512", cfg.Server.MaxInflightPushes)
+	}
+	if cfg.Server.MetricsTimeout != 5*time.Minute {
+		t.Errorf("Default MetricsTimeout = %v, want 5m", cfg.Server.MetricsTimeout)
+	}
+	if cfg.Server.ShutdownGracePeriod != 30*time.Minute {
+		t.Errorf("Default ShutdownGracePeriod = %v, want 30m", cfg.Server.ShutdownGracePeriod)
+	}
+	if cfg.Server.MaxRequestSize != 10*1024*1024 {
+		t.Errorf("Default MaxRequestSize = %v, want 10MB", cfg.Server.MaxRequestSize)
+	}
+	if cfg.Server.MaxContainersPerPush != 1000 {
+		t.Errorf("Default MaxContainersPerPush = %v, want 1000", cfg.Server.MaxContainersPerPush)
+	}
+	if cfg.Server.MaxLabelsPerContainer != 256 {
+		t.Errorf("Default MaxLabelsPerContainer = %v, want 256", cfg.Server.MaxLabelsPerContainer)
+	}
 	if cfg.Alerting.CheckInterval != 30*time.Second {
 		t.Errorf("Default CheckInterval = %v, want 30s", cfg.Alerting.CheckInterval)
 	}
 	if cfg.Alerting.HeartbeatTimeout != 2*time.Minute {
 		t.Errorf("Default HeartbeatTimeout = %v, want 2m", cfg.Alerting.HeartbeatTimeout)
 	}
+	if cfg.Alerting.ClockSkewThreshold != 30*time.Second {
+		t.Errorf("Default ClockSkewThreshold = %v, want 30s", cfg.Alerting.ClockSkewThreshold)
+	}
+	if cfg.Alerting.AgentRetention != 7*24*time.Hour {
+		t.Errorf("Default AgentRetention = %v, want 168h", cfg.Alerting.AgentRetention)
+	}
 	if cfg.Alerting.DeduplicationWindow != 5*time.Minute {
 		t.Errorf("Default DeduplicationWindow = %v, want 5m", cfg.Alerting.DeduplicationWindow)
 	}
@@ -140,6 +170,69 @@ auth:
 	if cfg.Alerting.SystemDiskThreshold != 90.0 {
 		t.Errorf("Default SystemDiskThreshold = %v, want 90.0", cfg.Alerting.SystemDiskThreshold)
 	}
+	if cfg.Alerting.ImageUpdateDigestInterval != 7*24*time.Hour {
+		t.Errorf("Default ImageUpdateDigestInterval = %v, want 168h", cfg.Alerting.ImageUpdateDigestInterval)
+	}
+	if cfg.Exporters.CloudWatch.Namespace != "Saviour" {
+		t.Errorf("Default CloudWatch namespace = %v, want Saviour", cfg.Exporters.CloudWatch.Namespace)
+	}
+	if cfg.Exporters.CloudWatch.Interval != time.Minute {
+		t.Errorf("Default CloudWatch interval = %v, want 1m", cfg.Exporters.CloudWatch.Interval)
+	}
+	if cfg.Exporters.Datadog.Site != "datadoghq.com" {
+		t.Errorf("Default Datadog site = %v, want datadoghq.com", cfg.Exporters.Datadog.Site)
+	}
+	if cfg.Exporters.Graphite.Port != 2003 {
+		t.Errorf("Default Graphite port = %v, want 2003", cfg.Exporters.Graphite.Port)
+	}
+	if cfg.Exporters.Graphite.Prefix != "saviour" {
+		t.Errorf("Default Graphite prefix = %v, want saviour", cfg.Exporters.Graphite.Prefix)
+	}
+	if cfg.Exporters.Graphite.Interval != time.Minute {
+		t.Errorf("Default Graphite interval = %v, want 1m", cfg.Exporters.Graphite.Interval)
+	}
+	if cfg.Events.Kafka.Topic != "saviour-events" {
+		t.Errorf("Default Kafka topic = %v, want saviour-events", cfg.Events.Kafka.Topic)
+	}
+	if cfg.Events.NATS.Subject != "saviour.events" {
+		t.Errorf("Default NATS subject = %v, want saviour.events", cfg.Events.NATS.Subject)
+	}
+	if cfg.Events.MQTT.AlertTopic != "saviour/{agent}/alerts" {
+		t.Errorf("Default MQTT alert topic = %v, want saviour/{agent}/alerts", cfg.Events.MQTT.AlertTopic)
+	}
+	if cfg.Events.MQTT.StatusTopic != "saviour/{agent}/status" {
+		t.Errorf("Default MQTT status topic = %v, want saviour/{agent}/status", cfg.Events.MQTT.StatusTopic)
+	}
+	if cfg.Reports.Schedule != "daily" || cfg.Reports.At != "09:00" || cfg.Reports.Weekday != "monday" || cfg.Reports.Top != 5 {
+		t.Errorf("Default reports = %+v, want daily at 09:00, monday, top 5", cfg.Reports)
+	}
+	if cfg.History.Retention != 24*time.Hour {
+		t.Errorf("Default history retention = %v, want 24h", cfg.History.Retention)
+	}
+	if cfg.History.RollupRetention != 30*24*time.Hour {
+		t.Errorf("Default history rollup retention = %v, want 720h", cfg.History.RollupRetention)
+	}
+	if cfg.Alerting.ContainerCPUThreshold != 90.0 {
+		t.Errorf("Default ContainerCPUThreshold = %v, want 90", cfg.Alerting.ContainerCPUThreshold)
+	}
+	if cfg.Alerting.ContainerMemoryThreshold != 95.0 {
+		t.Errorf("Default ContainerMemoryThreshold = %v, want 95", cfg.Alerting.ContainerMemoryThreshold)
+	}
+	if cfg.Alerting.ContainerOOMKillThreshold != 3 {
+		t.Errorf("Default ContainerOOMKillThreshold = %d, want 3", cfg.Alerting.ContainerOOMKillThreshold)
+	}
+	if cfg.Alerting.ChannelCheckInterval != 5*time.Minute {
+		t.Errorf("Default ChannelCheckInterval = %v, want 5m", cfg.Alerting.ChannelCheckInterval)
+	}
+	if cfg.Alerting.ChannelAlertAfter != 15*time.Minute {
+		t.Errorf("Default ChannelAlertAfter = %v, want 15m", cfg.Alerting.ChannelAlertAfter)
+	}
+	if cfg.Tracing.ServiceName != "saviour-server" {
+		t.Errorf("Default Tracing.ServiceName = %q, want saviour-server", cfg.Tracing.ServiceName)
+	}
+	if cfg.Tracing.SampleRatio != 1 {
+		t.Errorf("Default Tracing.SampleRatio = %v, want 1", cfg.Tracing.SampleRatio)
+	}
 }
 
 func TestLoadConfig_FileNotFound(t *testing.T) {
@@ -281,6 +374,56 @@ func TestValidate_APIKeyMissingName(t *testing.T) {
 	}
 }
 
+func TestValidate_Webhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook WebhookConfig
+		wantErr bool
+	}{
+		{"alertmanager", WebhookConfig{Enabled: true, URL: "https://hooks.example.com/am", Format: "alertmanager"}, false},
+		{"disabled", WebhookConfig{URL: "not a url"}, false},
+		{"no url", WebhookConfig{Enabled: true, Format: "saviour"}, true},
+		{"bad scheme", WebhookConfig{Enabled: true, URL: "ftp://hooks.example.com", Format: "saviour"}, true},
+		{"bad format", WebhookConfig{Enabled: true, URL: "https://hooks.example.com", Format: "slack"}, true},
+		{"negative timeout", WebhookConfig{Enabled: true, URL: "https://hooks.example.com", Format: "saviour", Timeout: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{
+			Server:  ServerConfig{Port: 8080},
+			Auth:    AuthConfig{APIKeys: []APIKey{{Key: "test", Name: "test"}}},
+			Webhook: tt.webhook,
+		}
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestPingsConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   PingCheck
+		wantErr bool
+	}{
+		{"valid", PingCheck{Name: "backup", Period: time.Hour, Severity: "critical"}, false},
+		{"configured token", PingCheck{Name: "backup", Period: time.Hour, Severity: "critical", Token: "0123456789abcdef"}, false},
+		{"no name", PingCheck{Period: time.Hour, Severity: "critical"}, true},
+		{"no period", PingCheck{Name: "backup", Severity: "critical"}, true},
+		{"negative grace", PingCheck{Name: "backup", Period: time.Hour, Grace: -time.Minute, Severity: "critical"}, true},
+		{"bad severity", PingCheck{Name: "backup", Period: time.Hour, Severity: "fatal"}, true},
+		{"short token", PingCheck{Name: "backup", Period: time.Hour, Severity: "critical", Token: "abc"}, true},
+		{"token with slash", PingCheck{Name: "backup", Period: time.Hour, Severity: "critical", Token: "0123456789/abcdef"}, true},
+	}
+
+	for _, tt := range tests {
+		pings := PingsConfig{Checks: []PingCheck{tt.check}}
+		if err := pings.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
 func TestValidate_GoogleChatEnabledWithoutWebhook(t *testing.T) {
 	cfg := &Config{
 		Server: ServerConfig{Port: 8080},
@@ -299,6 +442,144 @@ func TestValidate_GoogleChatEnabledWithoutWebhook(t *testing.T) {
 	}
 }
 
+func TestValidate_CloudWatchEnabledWithoutRegion(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Port: 8080},
+		Auth: AuthConfig{
+			APIKeys: []APIKey{{Key: "test", Name: "test"}},
+		},
+		Exporters: ExportersConfig{
+			CloudWatch: CloudWatchConfig{Enabled: true, Interval: time.Minute},
+		},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Error("Expected validation error for CloudWatch enabled without a region")
+	}
+}
+
+func TestValidate_DatadogEnabledWithoutAPIKey(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Port: 8080},
+		Auth: AuthConfig{
+			APIKeys: []APIKey{{Key: "test", Name: "test"}},
+		},
+		Exporters: ExportersConfig{
+			Datadog: DatadogConfig{Enabled: true, Site: "datadoghq.com", Interval: time.Minute},
+		},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Error("Expected validation error for Datadog enabled without an API key")
+	}
+}
+
+func TestValidate_InfluxDBIncomplete(t *testing.T) {
+	tests := []struct {
+		name   string
+		influx InfluxDBConfig
+	}{
+		{"no bucket", InfluxDBConfig{Enabled: true, URL: "http://influxdb:8086", Org: "ops", Token: "t"}},
+		{"no token", InfluxDBConfig{Enabled: true, URL: "http://influxdb:8086", Org: "ops", Bucket: "saviour"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Port: 8080},
+				Auth: AuthConfig{
+					APIKeys: []APIKey{{Key: "test", Name: "test"}},
+				},
+				Exporters: ExportersConfig{InfluxDB: tt.influx},
+			}
+
+			if err := cfg.Validate(); err == nil {
+				t.Error("Expected validation error for incomplete InfluxDB settings")
+			}
+		})
+	}
+}
+
+func TestValidate_GraphiteEnabledWithoutHost(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Port: 8080},
+		Auth: AuthConfig{
+			APIKeys: []APIKey{{Key: "test", Name: "test"}},
+		},
+		Exporters: ExportersConfig{
+			Graphite: GraphiteConfig{Enabled: true, Port: 2003, Interval: time.Minute},
+		},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Error("Expected validation error for Graphite enabled without a host")
+	}
+}
+
+func TestValidate_Events(t *testing.T) {
+	tests := []struct {
+		name   string
+		events EventsConfig
+	}{
+		{"kafka without brokers", EventsConfig{Kafka: KafkaConfig{Enabled: true, Topic: "saviour-events"}}},
+		{"nats without url", EventsConfig{NATS: NATSConfig{Enabled: true, Subject: "saviour.events"}}},
+		{"nats with http url", EventsConfig{NATS: NATSConfig{Enabled: true, URL: "http://nats:4222", Subject: "saviour.events"}}},
+		{"mqtt without url", EventsConfig{MQTT: MQTTConfig{Enabled: true}}},
+		{"mqtt with qos 2", EventsConfig{MQTT: MQTTConfig{Enabled: true, URL: "mqtt://broker:1883", QoS: 2}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Port: 8080},
+				Auth: AuthConfig{
+					APIKeys: []APIKey{{Key: "test", Name: "test"}},
+				},
+				Events: tt.events,
+			}
+
+			if err := cfg.Validate(); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
+func TestValidate_Reports(t *testing.T) {
+	valid := ReportsConfig{Enabled: true, Schedule: "weekly", At: "09:30", Weekday: "Fri", Top: 5}
+	tests := []struct {
+		name    string
+		reports func(*ReportsConfig)
+		wantErr bool
+	}{
+		{"valid", func(r *ReportsConfig) {}, false},
+		{"unknown schedule", func(r *ReportsConfig) { r.Schedule = "hourly" }, true},
+		{"invalid time", func(r *ReportsConfig) { r.At = "9am" }, true},
+		{"unknown weekday", func(r *ReportsConfig) { r.Weekday = "someday" }, true},
+		{"no top entries", func(r *ReportsConfig) { r.Top = -1 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Port: 8080},
+				Auth: AuthConfig{
+					APIKeys: []APIKey{{Key: "test", Name: "test"}},
+				},
+				Reports: valid,
+			}
+			tt.reports(&cfg.Reports)
+
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidate_AlertingInvalidCheckInterval(t *testing.T) {
 	cfg := &Config{
 		Server: ServerConfig{Port: 8080},
@@ -336,6 +617,70 @@ func TestValidate_AlertingInvalidHeartbeatTimeout(t *testing.T) {
 	}
 }
 
+func TestValidate_AlertingInvalidHeartbeatTimeoutOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		override HeartbeatTimeoutOverride
+	}{
+		{"empty pattern", HeartbeatTimeoutOverride{Timeout: time.Minute}},
+		{"bad pattern", HeartbeatTimeoutOverride{Agent: "batch-[", Timeout: time.Minute}},
+		{"no timeout", HeartbeatTimeoutOverride{Agent: "batch-*"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Port: 8080},
+				Auth: AuthConfig{
+					APIKeys: []APIKey{{Key: "test", Name: "test"}},
+				},
+				Alerting: AlertingConfig{
+					Enabled:                   true,
+					CheckInterval:             30 * time.Second,
+					HeartbeatTimeout:          2 * time.Minute,
+					HeartbeatTimeoutOverrides: []HeartbeatTimeoutOverride{tt.override},
+				},
+			}
+
+			if err := cfg.Validate(); err == nil {
+				t.Error("Expected validation error for invalid heartbeat timeout override")
+			}
+		})
+	}
+}
+
+func TestValidate_AlertingInvalidContainerThresholdOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		override ContainerThresholdOverride
+	}{
+		{"empty pattern", ContainerThresholdOverride{CPUThreshold: 95}},
+		{"bad pattern", ContainerThresholdOverride{Name: "db-[", CPUThreshold: 95}},
+		{"threshold above 100", ContainerThresholdOverride{Name: "db-*", MemoryThreshold: 120}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Port: 8080},
+				Auth: AuthConfig{
+					APIKeys: []APIKey{{Key: "test", Name: "test"}},
+				},
+				Alerting: AlertingConfig{
+					Enabled:                     true,
+					CheckInterval:               30 * time.Second,
+					HeartbeatTimeout:            2 * time.Minute,
+					ContainerThresholdOverrides: []ContainerThresholdOverride{tt.override},
+				},
+			}
+
+			if err := cfg.Validate(); err == nil {
+				t.Error("Expected validation error for invalid container threshold override")
+			}
+		})
+	}
+}
+
 func TestValidate_AlertingInvalidDeduplicationWindow(t *testing.T) {
 	cfg := &Config{
 		Server: ServerConfig{Port: 8080},
@@ -444,6 +789,44 @@ func TestValidate_CORSDevModeWithoutOrigins(t *testing.T) {
 	}
 }
 
+func TestValidate_CORSOrigins(t *testing.T) {
+	tests := []struct {
+		origin  string
+		wantErr bool
+	}{
+		{"https://dashboard.example.com", false},
+		{"http://localhost:3000", false},
+		{"https://*.corp.example.com", false},
+		{"https://app.*.example.com", true},
+		{"*.example.com", true},
+		{"https://example.com/", true},
+		{"https://example.com/dashboard", true},
+		{"ftp://example.com", true},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{
+			Server: ServerConfig{Port: 8080},
+			Auth:   AuthConfig{APIKeys: []APIKey{{Key: "test", Name: "test"}}},
+			CORS:   CORSConfig{Enabled: true, AllowedOrigins: []string{tt.origin}},
+		}
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.origin, tt.wantErr, err)
+		}
+	}
+}
+
+func TestValidate_CORSDevModeWithCredentials(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Port: 8080},
+		Auth:   AuthConfig{APIKeys: []APIKey{{Key: "test", Name: "test"}}},
+		CORS:   CORSConfig{Enabled: true, DevMode: true, AllowCredentials: true},
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "allow_credentials") {
+		t.Errorf("Expected dev_mode with allow_credentials rejected, got %v", err)
+	}
+}
+
 func TestValidate_AlertingDisabled(t *testing.T) {
 	cfg := &Config{
 		Server: ServerConfig{Port: 8080},
@@ -553,3 +936,280 @@ func TestValidate_EdgeCaseThresholds(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate_Timezone(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Port: 8080},
+		Auth: AuthConfig{
+			APIKeys: []APIKey{{Key: "test", Name: "test"}},
+		},
+		Alerting: AlertingConfig{Timezone: "Europe/Berlin"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected Europe/Berlin to be valid, got %v", err)
+	}
+
+	cfg.GoogleChat.Timezone = "Berlin"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for unknown google_chat timezone")
+	}
+}
+
+func TestValidate_Synthetic(t *testing.T) {
+	valid := SyntheticCheck{Name: "api", Type: "http", Target: "https://api.example.com/health", Interval: time.Minute, Timeout: 10 * time.Second, Severity: "critical", BodyMatch: `"ok"`}
+	tests := []struct {
+		name    string
+		check   func(*SyntheticCheck)
+		wantErr bool
+	}{
+		{"valid", func(c *SyntheticCheck) {}, false},
+		{"tcp", func(c *SyntheticCheck) { c.Type, c.Target, c.BodyMatch = "tcp", "db.internal:5432", "" }, false},
+		{"icmp", func(c *SyntheticCheck) { c.Type, c.Target, c.BodyMatch = "icmp", "10.0.0.1", "" }, false},
+		{"no name", func(c *SyntheticCheck) { c.Name = "" }, true},
+		{"unknown type", func(c *SyntheticCheck) { c.Type = "dns" }, true},
+		{"http without URL", func(c *SyntheticCheck) { c.Target = "api.example.com" }, true},
+		{"tcp without port", func(c *SyntheticCheck) { c.Type, c.Target, c.BodyMatch = "tcp", "db.internal", "" }, true},
+		{"body match on tcp", func(c *SyntheticCheck) { c.Type, c.Target = "tcp", "db.internal:5432" }, true},
+		{"invalid body match", func(c *SyntheticCheck) { c.BodyMatch = "(" }, true},
+		{"timeout over interval", func(c *SyntheticCheck) { c.Timeout = 2 * time.Minute }, true},
+		{"unknown severity", func(c *SyntheticCheck) { c.Severity = "page" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := valid
+			tt.check(&check)
+			cfg := &Config{
+				Server: ServerConfig{Port: 8080},
+				Auth: AuthConfig{
+					APIKeys: []APIKey{{Key: "test", Name: "test"}},
+				},
+				Synthetic: SyntheticConfig{Checks: []SyntheticCheck{check}},
+			}
+
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_Scrape(t *testing.T) {
+	valid := ScrapeTarget{URL: "https://10.0.3.17:9150", Token: "secret", Interval: 30 * time.Second, Timeout: 10 * time.Second}
+	tests := []struct {
+		name    string
+		target  func(*ScrapeTarget)
+		wantErr bool
+	}{
+		{"valid", func(t *ScrapeTarget) {}, false},
+		{"no scheme", func(t *ScrapeTarget) { t.URL = "10.0.3.17:9150" }, true},
+		{"no token", func(t *ScrapeTarget) { t.Token = "" }, true},
+		{"timeout over interval", func(t *ScrapeTarget) { t.Timeout = time.Minute }, true},
+		{"missing CA file", func(t *ScrapeTarget) { t.CAFile = "/nonexistent/ca.pem" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := valid
+			tt.target(&target)
+			cfg := &Config{
+				Server: ServerConfig{Port: 8080},
+				Auth: AuthConfig{
+					APIKeys: []APIKey{{Key: "test", Name: "test"}},
+				},
+				Scrape: ScrapeConfig{Targets: []ScrapeTarget{target}},
+			}
+
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_HA(t *testing.T) {
+	valid := HAConfig{
+		Enabled:       true,
+		NodeID:        "server-a",
+		Peers:         []string{"http://10.0.1.2:8080"},
+		Token:         "0123456789abcdef",
+		LeaseDuration: 15 * time.Second,
+	}
+	tests := []struct {
+		name    string
+		ha      func(*HAConfig)
+		wantErr bool
+	}{
+		{"valid", func(h *HAConfig) {}, false},
+		{"disabled", func(h *HAConfig) { *h = HAConfig{} }, false},
+		{"no peers", func(h *HAConfig) { h.Peers = nil }, true},
+		{"peer not a URL", func(h *HAConfig) { h.Peers = []string{"10.0.1.2:8080"} }, true},
+		{"short token", func(h *HAConfig) { h.Token = "secret" }, true},
+		{"short lease", func(h *HAConfig) { h.LeaseDuration = time.Second }, true},
+		{"redis", func(h *HAConfig) {
+			h.Redis = RedisConfig{Enabled: true, URL: "redis://:secret@redis:6379/0", SyncInterval: 5 * time.Second, Timeout: 2 * time.Second}
+		}, false},
+		{"redis not a URL", func(h *HAConfig) {
+			h.Redis = RedisConfig{Enabled: true, URL: "redis:6379", SyncInterval: 5 * time.Second, Timeout: 2 * time.Second}
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ha := valid
+			tt.ha(&ha)
+			cfg := &Config{
+				Server: ServerConfig{Port: 8080},
+				Auth: AuthConfig{
+					APIKeys: []APIKey{{Key: "test", Name: "test"}},
+				},
+				HA: ha,
+			}
+
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_Federation(t *testing.T) {
+	valid := FederationConfig{Enabled: true, URL: "https://saviour-global:8080", APIKey: "key", Interval: time.Minute, Timeout: 10 * time.Second}
+	tests := []struct {
+		name       string
+		federation func(*FederationConfig)
+		wantErr    bool
+	}{
+		{"valid", func(f *FederationConfig) {}, false},
+		{"every push", func(f *FederationConfig) { f.Interval = 0 }, false},
+		{"no scheme", func(f *FederationConfig) { f.URL = "saviour-global:8080" }, true},
+		{"no api key", func(f *FederationConfig) { f.APIKey = "" }, true},
+		{"invalid pattern", func(f *FederationConfig) { f.Agents = []string{"web-["} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			federation := valid
+			tt.federation(&federation)
+			cfg := &Config{
+				Server: ServerConfig{Port: 8080},
+				Auth: AuthConfig{
+					APIKeys: []APIKey{{Key: "test", Name: "test"}},
+				},
+				Federation: federation,
+			}
+
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_Logging(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Port: 8080},
+		Auth: AuthConfig{
+			APIKeys: []APIKey{{Key: "test", Name: "test"}},
+		},
+		Logging: logging.Config{Level: "debug", Format: "json"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected debug/json logging to be valid, got %v", err)
+	}
+
+	cfg.Logging.Format = "logfmt"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for unknown log format")
+	}
+}
+
+func TestValidate_Tracing(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		ratio    float64
+		wantErr  bool
+	}{
+		{"valid", "http://otel-collector:4318", 0.5, false},
+		{"no endpoint", "", 1, true},
+		{"not http", "grpc://otel-collector:4317", 1, true},
+		{"ratio above 1", "http://otel-collector:4318", 1.5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Port: 8080},
+				Auth: AuthConfig{
+					APIKeys: []APIKey{{Key: "test", Name: "test"}},
+				},
+				Tracing: TracingConfig{Enabled: true, Endpoint: tt.endpoint, SampleRatio: tt.ratio},
+			}
+
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_AlertRules(t *testing.T) {
+	valid := AlertRule{Name: "backlog", Metric: "queue_depth", Service: "billing-*", Operator: ">", Threshold: 1000, Severity: "warning"}
+	tests := []struct {
+		name    string
+		rule    func(*AlertRule)
+		wantErr bool
+	}{
+		{"valid", func(r *AlertRule) {}, false},
+		{"no name", func(r *AlertRule) { r.Name = "" }, true},
+		{"no metric", func(r *AlertRule) { r.Metric = "" }, true},
+		{"invalid pattern", func(r *AlertRule) { r.Agent = "web-[" }, true},
+		{"unknown operator", func(r *AlertRule) { r.Operator = "=>" }, true},
+		{"unknown severity", func(r *AlertRule) { r.Severity = "page" }, true},
+		{"annotations", func(r *AlertRule) {
+			r.Annotations = map[string]string{"runbook_url": "https://wiki.example.com/backlog", "team": "billing"}
+		}, false},
+		{"relative runbook", func(r *AlertRule) { r.Annotations = map[string]string{"runbook_url": "wiki/backlog"} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := valid
+			tt.rule(&rule)
+			cfg := &Config{
+				Server: ServerConfig{Port: 8080},
+				Auth: AuthConfig{
+					APIKeys: []APIKey{{Key: "test", Name: "test"}},
+				},
+				Alerting: AlertingConfig{
+					Enabled:          true,
+					CheckInterval:    30 * time.Second,
+					HeartbeatTimeout: 2 * time.Minute,
+					Rules:            []AlertRule{rule},
+				},
+			}
+
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Rule names are alert types, so they must be unique
+	cfg := &Config{
+		Server:   ServerConfig{Port: 8080},
+		Auth:     AuthConfig{APIKeys: []APIKey{{Key: "test", Name: "test"}}},
+		Alerting: AlertingConfig{Enabled: true, CheckInterval: time.Second, HeartbeatTimeout: time.Minute, Rules: []AlertRule{valid, valid}},
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for duplicate rule names")
+	}
+
+	// Annotations by alert type are checked the same way
+	cfg.Alerting.Rules = nil
+	cfg.Alerting.Annotations = map[string]map[string]string{"system_cpu_high": {"runbook_url": "ftp://wiki.example.com/cpu"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for a runbook_url that isn't http")
+	}
+}
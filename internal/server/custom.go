@@ -0,0 +1,120 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Custom metric types
+const (
+	CustomGauge   = "gauge"   // Pushes replace the value
+	CustomCounter = "counter" // Pushes add to the value
+)
+
+// MaxCustomSeries caps how many custom metric series the server keeps, so
+// a misbehaving application can't grow it without bound
+const MaxCustomSeries = 10000
+
+// CustomMetric is the latest value of an application metric pushed to
+// POST /api/v1/metrics/custom. It belongs to an agent or, for applications
+// spread over several hosts, to a logical service.
+type CustomMetric struct {
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	Value     float64           `json:"value"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	AgentName string            `json:"agent_name,omitempty"`
+	Service   string            `json:"service,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Owner is the agent or service the metric belongs to
+func (m *CustomMetric) Owner() string {
+	if m.Service != "" {
+		return m.Service
+	}
+	return m.AgentName
+}
+
+// customSeriesKey identifies a series by owner, name and labels, with the
+// labels in a stable order
+func customSeriesKey(agentName, service, name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\x00%s\x00%s", agentName, service, name)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\x00%s=%s", k, labels[k])
+	}
+	return b.String()
+}
+
+// PushCustomMetrics stores the metrics of a push at the given time: gauges
+// take the pushed value, counters add it. Nothing is stored if a metric
+// changes type or the push would take the store past MaxCustomSeries.
+func (s *StateStore) PushCustomMetrics(req CustomMetricsRequest, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newSeries := make(map[string]bool)
+	for _, sample := range req.Metrics {
+		key := customSeriesKey(req.AgentName, req.Service, sample.Name, sample.Labels)
+		existing, exists := s.customMetrics[key]
+		if !exists {
+			newSeries[key] = true
+			continue
+		}
+		if existing.Type != sample.Type {
+			return fmt.Errorf("metric %q is a %s, not a %s", sample.Name, existing.Type, sample.Type)
+		}
+	}
+	if len(s.customMetrics)+len(newSeries) > MaxCustomSeries {
+		return fmt.Errorf("too many custom metric series, the limit is %d", MaxCustomSeries)
+	}
+
+	for _, sample := range req.Metrics {
+		key := customSeriesKey(req.AgentName, req.Service, sample.Name, sample.Labels)
+		metric, exists := s.customMetrics[key]
+		if !exists {
+			metric = &CustomMetric{
+				Name:      sample.Name,
+				Type:      sample.Type,
+				Labels:    sample.Labels,
+				AgentName: req.AgentName,
+				Service:   req.Service,
+			}
+			s.customMetrics[key] = metric
+		}
+		if metric.Type == CustomCounter {
+			metric.Value += sample.Value
+		} else {
+			metric.Value = sample.Value
+		}
+		metric.UpdatedAt = at
+	}
+	s.revision.Add(1)
+	return nil
+}
+
+// CustomMetrics returns every custom metric, ordered by agent, service, name
+// and labels (returns copies to prevent data races)
+func (s *StateStore) CustomMetrics() []CustomMetric {
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.customMetrics))
+	for key := range s.customMetrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	metrics := make([]CustomMetric, len(keys))
+	for i, key := range keys {
+		metrics[i] = *s.customMetrics[key]
+	}
+	s.mu.RUnlock()
+	return metrics
+}
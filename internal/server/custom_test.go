@@ -0,0 +1,74 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStateStore_PushCustomMetrics(t *testing.T) {
+	store := NewStateStore()
+	now := time.Now()
+
+	push := CustomMetricsRequest{Service: "billing", Metrics: []CustomMetricSample{
+		{Name: "queue_depth", Type: CustomGauge, Value: 10, Labels: map[string]string{"queue": "invoices"}},
+		{Name: "invoices_sent", Type: CustomCounter, Value: 5},
+	}}
+	if err := store.PushCustomMetrics(push, now); err != nil {
+		t.Fatalf("PushCustomMetrics() error = %v", err)
+	}
+	push.Metrics[0].Value = 7
+	push.Metrics[1].Value = 3
+	if err := store.PushCustomMetrics(push, now.Add(time.Minute)); err != nil {
+		t.Fatalf("PushCustomMetrics() error = %v", err)
+	}
+
+	// The same name for an agent is another series
+	err := store.PushCustomMetrics(CustomMetricsRequest{AgentName: "web-1", Metrics: []CustomMetricSample{
+		{Name: "queue_depth", Type: CustomGauge, Value: 1, Labels: map[string]string{"queue": "invoices"}},
+	}}, now)
+	if err != nil {
+		t.Fatalf("PushCustomMetrics() error = %v", err)
+	}
+
+	metrics := store.CustomMetrics()
+	if len(metrics) != 3 {
+		t.Fatalf("Expected 3 series, got %d", len(metrics))
+	}
+	// Service series, without an agent, come first
+	sent, depth := metrics[0], metrics[1]
+	if sent.Name != "invoices_sent" || sent.Value != 8 {
+		t.Errorf("Expected the counter to add up to 8, got %+v", sent)
+	}
+	if depth.Name != "queue_depth" || depth.Value != 7 || depth.Owner() != "billing" {
+		t.Errorf("Expected the gauge to hold the last value 7, got %+v", depth)
+	}
+	if !depth.UpdatedAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("Expected updated at %v, got %v", now.Add(time.Minute), depth.UpdatedAt)
+	}
+	if metrics[2].AgentName != "web-1" || metrics[2].Owner() != "web-1" || metrics[2].Value != 1 {
+		t.Errorf("Unexpected agent series: %+v", metrics[2])
+	}
+}
+
+func TestStateStore_PushCustomMetrics_TypeChange(t *testing.T) {
+	store := NewStateStore()
+	now := time.Now()
+
+	gauge := CustomMetricsRequest{Service: "billing", Metrics: []CustomMetricSample{{Name: "jobs", Type: CustomGauge, Value: 3}}}
+	if err := store.PushCustomMetrics(gauge, now); err != nil {
+		t.Fatalf("PushCustomMetrics() error = %v", err)
+	}
+
+	// Nothing of a push with a type change is stored
+	mixed := CustomMetricsRequest{Service: "billing", Metrics: []CustomMetricSample{
+		{Name: "errors", Type: CustomCounter, Value: 1},
+		{Name: "jobs", Type: CustomCounter, Value: 1},
+	}}
+	if err := store.PushCustomMetrics(mixed, now); err == nil {
+		t.Fatal("Expected an error for a gauge pushed as a counter")
+	}
+	metrics := store.CustomMetrics()
+	if len(metrics) != 1 || metrics[0].Value != 3 {
+		t.Errorf("Expected only the original gauge, got %+v", metrics)
+	}
+}
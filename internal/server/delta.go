@@ -0,0 +1,54 @@
+package server
+
+import "github.com/anurag/saviour/pkg/metrics"
+
+// ExpandDelta turns a delta push into a full one by merging its changed
+// containers into the agent's stored containers. It returns false when the
+// store doesn't hold the push the delta is based on (e.g. after a server
+// restart), in which case the agent must send a full push.
+func (s *StateStore) ExpandDelta(payload *MetricsPushPayload) bool {
+	if !payload.Delta {
+		return true
+	}
+
+	shard := s.shardFor(payload.AgentName)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	existing, exists := shard.agents[payload.AgentName]
+	if !exists || payload.BaseSequence == 0 || existing.PushSequence != payload.BaseSequence {
+		return false
+	}
+
+	changed := make(map[string]metrics.ContainerMetrics, len(payload.SystemMetrics.Containers))
+	for _, c := range payload.SystemMetrics.Containers {
+		changed[c.ID] = c
+	}
+	removed := make(map[string]bool, len(payload.RemovedContainers))
+	for _, id := range payload.RemovedContainers {
+		removed[id] = true
+	}
+
+	// Keep the stored order, then append containers new since the base
+	base := existing.SystemMetrics.Containers
+	containers := make([]metrics.ContainerMetrics, 0, len(base)+len(changed))
+	for _, c := range base {
+		if removed[c.ID] {
+			continue
+		}
+		if update, ok := changed[c.ID]; ok {
+			c = update
+			delete(changed, c.ID)
+		}
+		containers = append(containers, c)
+	}
+	for _, c := range payload.SystemMetrics.Containers {
+		if _, isNew := changed[c.ID]; isNew {
+			containers = append(containers, c)
+		}
+	}
+
+	payload.SystemMetrics.Containers = containers
+	payload.Delta = false
+	return true
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func TestExpandDelta(t *testing.T) {
+	store := NewStateStore()
+	store.UpdateAgent(&ServerState{
+		AgentName:    "test-agent",
+		PushSequence: 7,
+		SystemMetrics: metrics.SystemMetrics{
+			Containers: []metrics.ContainerMetrics{
+				{ID: "web", State: "running"},
+				{ID: "db", State: "running"},
+				{ID: "cache", State: "running"},
+			},
+		},
+	})
+
+	payload := &MetricsPushPayload{
+		AgentName:    "test-agent",
+		Sequence:     8,
+		Delta:        true,
+		BaseSequence: 7,
+		SystemMetrics: metrics.SystemMetrics{
+			Containers: []metrics.ContainerMetrics{
+				{ID: "db", State: "exited"},
+				{ID: "worker", State: "running"},
+			},
+		},
+		RemovedContainers: []string{"cache"},
+	}
+
+	if !store.ExpandDelta(payload) {
+		t.Fatal("Expected delta based on the stored push to expand")
+	}
+	if payload.Delta {
+		t.Error("Expected expanded payload to be a full one")
+	}
+
+	want := []struct{ id, state string }{{"web", "running"}, {"db", "exited"}, {"worker", "running"}}
+	containers := payload.SystemMetrics.Containers
+	if len(containers) != len(want) {
+		t.Fatalf("Expected %d containers, got %d", len(want), len(containers))
+	}
+	for i, w := range want {
+		if containers[i].ID != w.id || containers[i].State != w.state {
+			t.Errorf("Container %d: expected %s %s, got %s %s", i, w.id, w.state, containers[i].ID, containers[i].State)
+		}
+	}
+}
+
+func TestExpandDelta_MissingBase(t *testing.T) {
+	store := NewStateStore()
+	store.UpdateAgent(&ServerState{AgentName: "known", PushSequence: 3})
+	store.UpdateHeartbeat("heartbeat-only")
+
+	tests := []struct {
+		name    string
+		payload MetricsPushPayload
+	}{
+		{"unknown agent", MetricsPushPayload{AgentName: "unknown", Delta: true, BaseSequence: 3}},
+		{"heartbeat only", MetricsPushPayload{AgentName: "heartbeat-only", Delta: true}},
+		{"stale base", MetricsPushPayload{AgentName: "known", Delta: true, BaseSequence: 2}},
+	}
+
+	for _, tt := range tests {
+		if store.ExpandDelta(&tt.payload) {
+			t.Errorf("%s: expected delta to be rejected", tt.name)
+		}
+	}
+
+	full := MetricsPushPayload{AgentName: "unknown"}
+	if !store.ExpandDelta(&full) {
+		t.Error("Expected full pushes to pass through")
+	}
+}
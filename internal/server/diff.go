@@ -0,0 +1,177 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AgentDiff compares two agents, such as replicas that should be alike
+type AgentDiff struct {
+	A string `json:"a"`
+	B string `json:"b"`
+
+	// System info and configuration that differs
+	System []FieldDiff `json:"system"`
+
+	// Key metrics of both, whether or not they differ
+	Metrics []MetricDiff `json:"metrics"`
+
+	// Containers only one agent runs, and those on both that differ.
+	// Containers match by compose service or name.
+	OnlyA      []string        `json:"only_a"`
+	OnlyB      []string        `json:"only_b"`
+	Containers []ContainerDiff `json:"containers"`
+}
+
+// FieldDiff is a value that differs between the agents
+type FieldDiff struct {
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+}
+
+// MetricDiff is a metric of both agents; Delta is B minus A
+type MetricDiff struct {
+	Metric string  `json:"metric"`
+	A      float64 `json:"a"`
+	B      float64 `json:"b"`
+	Delta  float64 `json:"delta"`
+}
+
+// ContainerDiff is a container both agents run, and how it differs
+type ContainerDiff struct {
+	Container string      `json:"container"`
+	Fields    []FieldDiff `json:"fields"`
+}
+
+// DiffAgents compares agents a and b
+func DiffAgents(a, b *ServerState) AgentDiff {
+	diff := AgentDiff{
+		A:          a.AgentName,
+		B:          b.AgentName,
+		System:     diffFields(systemFields(a), systemFields(b)),
+		OnlyA:      []string{},
+		OnlyB:      []string{},
+		Containers: []ContainerDiff{},
+	}
+
+	metricsA, metricsB := keyMetrics(a), keyMetrics(b)
+	for _, metric := range metricsA {
+		for _, other := range metricsB {
+			if other.Metric == metric.Metric {
+				diff.Metrics = append(diff.Metrics, MetricDiff{Metric: metric.Metric, A: metric.A, B: other.A, Delta: other.A - metric.A})
+			}
+		}
+	}
+
+	containersB := make(map[string]ContainerState, len(b.Containers))
+	for _, c := range b.Containers {
+		containersB[containerIdentity(c)] = c
+	}
+	for _, c := range a.Containers {
+		identity := containerIdentity(c)
+		other, exists := containersB[identity]
+		if !exists {
+			diff.OnlyA = append(diff.OnlyA, c.Name)
+			continue
+		}
+		delete(containersB, identity)
+		if fields := diffFields(containerFields(c), containerFields(other)); len(fields) > 0 {
+			diff.Containers = append(diff.Containers, ContainerDiff{Container: c.Name, Fields: fields})
+		}
+	}
+	for _, c := range containersB {
+		diff.OnlyB = append(diff.OnlyB, c.Name)
+	}
+	sort.Strings(diff.OnlyA)
+	sort.Strings(diff.OnlyB)
+	sort.Slice(diff.Containers, func(i, j int) bool {
+		return diff.Containers[i].Container < diff.Containers[j].Container
+	})
+	return diff
+}
+
+// field is a named value for diffFields
+type field struct {
+	name  string
+	value string
+}
+
+// diffFields returns the fields whose values differ; both lists hold the
+// same fields in the same order
+func diffFields(a, b []field) []FieldDiff {
+	diffs := []FieldDiff{}
+	for i := range a {
+		if a[i].value != b[i].value {
+			diffs = append(diffs, FieldDiff{Field: a[i].name, A: a[i].value, B: b[i].value})
+		}
+	}
+	return diffs
+}
+
+// systemFields are the agent's system info and configuration
+func systemFields(s *ServerState) []field {
+	m := s.SystemMetrics
+	var cloud CloudMetadata
+	if s.Cloud != nil {
+		cloud = *s.Cloud
+	}
+	mounts := make([]string, 0, len(m.Disk))
+	for _, disk := range m.Disk {
+		mounts = append(mounts, disk.MountPoint)
+	}
+	sort.Strings(mounts)
+
+	return []field{
+		{"status", s.Status},
+		{"agent_version", s.AgentVersion},
+		{"os", m.SystemInfo.OS},
+		{"platform", m.SystemInfo.Platform},
+		{"platform_version", m.SystemInfo.PlatformVersion},
+		{"kernel_version", m.SystemInfo.KernelVersion},
+		{"cpu_cores", fmt.Sprint(len(m.CPU.PerCorePercent))},
+		{"memory_total", fmt.Sprint(m.Memory.Total)},
+		{"swap_total", fmt.Sprint(m.Memory.SwapTotal)},
+		{"mounts", strings.Join(mounts, ",")},
+		{"cloud_provider", cloud.Provider},
+		{"instance_type", cloud.InstanceType},
+		{"region", cloud.Region},
+		{"zone", cloud.Zone},
+		{"lifecycle", cloud.Lifecycle},
+		{"heartbeat_interval", s.HeartbeatInterval.String()},
+	}
+}
+
+// containerFields are what should match between replicas of a container
+func containerFields(c ContainerState) []field {
+	return []field{
+		{"image", c.Image},
+		{"image_id", c.ImageID},
+		{"state", c.State},
+		{"health", c.Health},
+		{"restart_count", fmt.Sprint(c.RestartCount)},
+		{"memory_limit", fmt.Sprint(c.MemoryLimit)},
+	}
+}
+
+// keyMetrics are the agent's main gauges, with the value in A
+func keyMetrics(s *ServerState) []MetricDiff {
+	m := s.SystemMetrics
+	sample := historySampleOf(s, time.Time{})
+	metrics := []MetricDiff{
+		{Metric: "health_score", A: float64(s.HealthAt(time.Now()))},
+		{Metric: "cpu_percent", A: m.CPU.UsagePercent},
+		{Metric: "load1", A: m.CPU.LoadAvg1},
+		{Metric: "load5", A: m.CPU.LoadAvg5},
+		{Metric: "memory_percent", A: m.Memory.UsedPercent},
+		{Metric: "swap_percent", A: m.Memory.SwapPercent},
+		{Metric: "containers", A: sample.Containers},
+		{Metric: "containers_running", A: sample.ContainersRunning},
+	}
+	for _, disk := range m.Disk {
+		metrics = append(metrics, MetricDiff{Metric: "disk_percent:" + disk.MountPoint, A: disk.UsedPercent})
+	}
+	return metrics
+}
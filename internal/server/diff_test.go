@@ -0,0 +1,82 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func TestDiffAgents(t *testing.T) {
+	a := &ServerState{
+		AgentName:    "web-1",
+		Status:       "online",
+		AgentVersion: "1.2.0",
+		SystemMetrics: metrics.SystemMetrics{
+			CPU:        metrics.CPUMetrics{UsagePercent: 20},
+			Disk:       []metrics.DiskMetrics{{MountPoint: "/", UsedPercent: 40}},
+			SystemInfo: metrics.SystemInfo{KernelVersion: "6.1.0", Platform: "ubuntu"},
+		},
+		Containers: []ContainerState{
+			{Name: "api", Image: "shop/api:2", State: "running"},
+			{Name: "shop-worker-1", ComposeService: "shop/worker", ComposeNumber: "1", Image: "shop/worker:2", State: "running"},
+			{Name: "debug", Image: "busybox", State: "exited"},
+		},
+	}
+	b := &ServerState{
+		AgentName:    "web-2",
+		Status:       "online",
+		AgentVersion: "1.2.0",
+		SystemMetrics: metrics.SystemMetrics{
+			CPU:        metrics.CPUMetrics{UsagePercent: 90},
+			Disk:       []metrics.DiskMetrics{{MountPoint: "/", UsedPercent: 45}},
+			SystemInfo: metrics.SystemInfo{KernelVersion: "5.15.0", Platform: "ubuntu"},
+		},
+		Containers: []ContainerState{
+			{Name: "api", Image: "shop/api:1", State: "running", RestartCount: 4},
+			{Name: "shop-worker-1", ComposeService: "shop/worker", ComposeNumber: "1", Image: "shop/worker:2", State: "running"},
+			{Name: "sidecar", Image: "envoy", State: "running"},
+		},
+	}
+
+	diff := DiffAgents(a, b)
+
+	if len(diff.System) != 1 || diff.System[0] != (FieldDiff{Field: "kernel_version", A: "6.1.0", B: "5.15.0"}) {
+		t.Errorf("Expected only the kernel version to differ, got %+v", diff.System)
+	}
+
+	metricsByName := make(map[string]MetricDiff)
+	for _, m := range diff.Metrics {
+		metricsByName[m.Metric] = m
+	}
+	if cpu := metricsByName["cpu_percent"]; cpu.A != 20 || cpu.B != 90 || cpu.Delta != 70 {
+		t.Errorf("Expected CPU 20 vs 90, got %+v", cpu)
+	}
+	if disk, ok := metricsByName["disk_percent:/"]; !ok || disk.Delta != 5 {
+		t.Errorf("Expected / 5 points fuller on web-2, got %+v", disk)
+	}
+
+	if len(diff.OnlyA) != 1 || diff.OnlyA[0] != "debug" || len(diff.OnlyB) != 1 || diff.OnlyB[0] != "sidecar" {
+		t.Errorf("Expected debug only on web-1 and sidecar only on web-2, got %v and %v", diff.OnlyA, diff.OnlyB)
+	}
+	if len(diff.Containers) != 1 || diff.Containers[0].Container != "api" {
+		t.Fatalf("Expected only api to differ, got %+v", diff.Containers)
+	}
+	fields := diff.Containers[0].Fields
+	if len(fields) != 2 || fields[0].Field != "image" || fields[1] != (FieldDiff{Field: "restart_count", A: "0", B: "4"}) {
+		t.Errorf("Expected api's image and restart count to differ, got %+v", fields)
+	}
+}
+
+func TestDiffAgents_Identical(t *testing.T) {
+	a := &ServerState{AgentName: "web-1", Status: "online", HeartbeatInterval: 30 * time.Second}
+	diff := DiffAgents(a, a)
+	if len(diff.System) != 0 || len(diff.Containers) != 0 || len(diff.OnlyA) != 0 || len(diff.OnlyB) != 0 {
+		t.Errorf("Expected no differences, got %+v", diff)
+	}
+	for _, m := range diff.Metrics {
+		if m.Delta != 0 {
+			t.Errorf("Expected no metric deltas, got %+v", m)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Percentiles of one metric over a window
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// AgentPercentiles are an agent's CPU and memory percentiles over a window
+type AgentPercentiles struct {
+	AgentName string      `json:"agent_name"`
+	Samples   int         `json:"samples"`
+	CPU       Percentiles `json:"cpu"`
+	Memory    Percentiles `json:"memory"`
+}
+
+// Heatmap counts samples by time and value: a column per step, a count per
+// value bucket in each
+type Heatmap struct {
+	Metric      string          `json:"metric"`
+	From        time.Time       `json:"from"`
+	To          time.Time       `json:"to"`
+	StepSeconds float64         `json:"step_seconds"`
+	Buckets     []float64       `json:"buckets"` // Upper bound of each bucket
+	Columns     []HeatmapColumn `json:"columns"`
+}
+
+// HeatmapColumn counts the samples from Time until the next column
+type HeatmapColumn struct {
+	Time   time.Time `json:"time"`
+	Counts []int     `json:"counts"`
+}
+
+// Percentiles returns the agent's CPU and memory percentiles between from
+// and to. Hourly history holds averages, so windows reaching past the
+// minute samples flatten the peaks somewhat. Returns false if the agent has
+// no samples in the window.
+func (h *History) Percentiles(agentName string, from, to time.Time) (AgentPercentiles, bool) {
+	samples := h.Samples(agentName, from, to)
+	if len(samples) == 0 {
+		return AgentPercentiles{}, false
+	}
+
+	cpu := make([]float64, len(samples))
+	memory := make([]float64, len(samples))
+	for i, s := range samples {
+		cpu[i] = s.CPUPercent
+		memory[i] = s.MemoryPercent
+	}
+	return AgentPercentiles{
+		AgentName: agentName,
+		Samples:   len(samples),
+		CPU:       percentilesOf(cpu),
+		Memory:    percentilesOf(memory),
+	}, true
+}
+
+// percentilesOf sorts values and takes their percentiles
+func percentilesOf(values []float64) Percentiles {
+	sort.Float64s(values)
+	return Percentiles{
+		P50: percentile(values, 50),
+		P95: percentile(values, 95),
+		P99: percentile(values, 99),
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// Heatmap buckets one of HistoryMetrics for the given agents between from
+// and to. Percentages are bucketed from 0 to 100, other metrics from 0 to
+// the highest value seen. Columns past the minute samples count one hourly
+// average per agent and hour.
+func (h *History) Heatmap(metric string, agents []string, from, to time.Time, step time.Duration, buckets int) Heatmap {
+	heatmap := Heatmap{Metric: metric, From: from, To: to, StepSeconds: step.Seconds()}
+
+	start := from.Truncate(step)
+	for at := start; !at.After(to); at = at.Add(step) {
+		heatmap.Columns = append(heatmap.Columns, HeatmapColumn{Time: at, Counts: make([]int, buckets)})
+	}
+
+	var samples []HistorySample
+	for _, agent := range agents {
+		samples = append(samples, h.Samples(agent, from, to)...)
+	}
+
+	upper := 100.0
+	if !strings.HasSuffix(metric, "_percent") {
+		upper = 1
+		for _, s := range samples {
+			value, _ := s.Value(metric)
+			upper = math.Max(upper, value)
+		}
+	}
+	width := upper / float64(buckets)
+	for i := 1; i <= buckets; i++ {
+		heatmap.Buckets = append(heatmap.Buckets, width*float64(i))
+	}
+
+	for _, s := range samples {
+		column := int(s.Time.Sub(start) / step)
+		if column < 0 || column >= len(heatmap.Columns) {
+			continue
+		}
+		value, _ := s.Value(metric)
+		// Buckets run up to and including their upper bound
+		bucket := min(max(int(math.Ceil(value/width))-1, 0), buckets-1)
+		heatmap.Columns[column].Counts[bucket]++
+	}
+	return heatmap
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistory_Percentiles(t *testing.T) {
+	history := NewHistory(24*time.Hour, 0)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// CPU 1..100, memory constant
+	for i := 0; i < 100; i++ {
+		history.Record("web-1", HistorySample{Time: start.Add(time.Duration(i) * time.Minute), CPUPercent: float64(i + 1), MemoryPercent: 40})
+	}
+
+	p, ok := history.Percentiles("web-1", start, start.Add(2*time.Hour))
+	if !ok {
+		t.Fatal("Expected percentiles for web-1")
+	}
+	if p.Samples != 100 {
+		t.Errorf("Expected 100 samples, got %d", p.Samples)
+	}
+	if p.CPU != (Percentiles{P50: 50, P95: 95, P99: 99}) {
+		t.Errorf("Expected CPU p50/p95/p99 of 50/95/99, got %+v", p.CPU)
+	}
+	if p.Memory != (Percentiles{P50: 40, P95: 40, P99: 40}) {
+		t.Errorf("Expected memory at 40 throughout, got %+v", p.Memory)
+	}
+
+	if _, ok := history.Percentiles("web-1", start.Add(3*time.Hour), start.Add(4*time.Hour)); ok {
+		t.Error("Expected no percentiles for a window without samples")
+	}
+}
+
+func TestPercentile_Single(t *testing.T) {
+	if got := percentile([]float64{7}, 99); got != 7 {
+		t.Errorf("Expected 7, got %v", got)
+	}
+	if got := percentile([]float64{1, 2}, 1); got != 1 {
+		t.Errorf("Expected 1, got %v", got)
+	}
+}
+
+func TestHistory_Heatmap(t *testing.T) {
+	history := NewHistory(24*time.Hour, 0)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	history.Record("web-1", HistorySample{Time: start, CPUPercent: 5, Load1: 4})
+	history.Record("web-2", HistorySample{Time: start.Add(5 * time.Minute), CPUPercent: 100, Load1: 1})
+	history.Record("web-1", HistorySample{Time: start.Add(10 * time.Minute), CPUPercent: 55, Load1: 2})
+
+	heatmap := history.Heatmap("cpu_percent", []string{"web-1", "web-2"}, start, start.Add(15*time.Minute), 10*time.Minute, 10)
+	if len(heatmap.Columns) != 2 || len(heatmap.Buckets) != 10 || heatmap.Buckets[9] != 100 {
+		t.Fatalf("Expected 2 columns of 10 buckets up to 100, got %+v", heatmap)
+	}
+	first, second := heatmap.Columns[0].Counts, heatmap.Columns[1].Counts
+	if first[0] != 1 || first[9] != 1 {
+		t.Errorf("Expected 5%% and 100%% in the first column, got %v", first)
+	}
+	if second[5] != 1 {
+		t.Errorf("Expected 55%% in the second column, got %v", second)
+	}
+
+	// Other metrics are bucketed up to the highest value
+	heatmap = history.Heatmap("load1", []string{"web-1"}, start, start.Add(15*time.Minute), 10*time.Minute, 4)
+	if heatmap.Buckets[3] != 4 || heatmap.Columns[0].Counts[3] != 1 || heatmap.Columns[1].Counts[1] != 1 {
+		t.Errorf("Expected load bucketed up to 4, got %+v", heatmap)
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"math"
+	"time"
+)
+
+// How the health score's 100 points are split
+const (
+	healthResourcePoints  = 40 // Headroom on the busiest of CPU, memory and disk
+	healthAlertPoints     = 25 // Fewer and less severe active alerts
+	healthContainerPoints = 20 // Share of containers without problems
+	healthHeartbeatPoints = 15 // Heartbeats arriving on time
+)
+
+// Resource usage up to healthyUsagePercent costs no points; beyond it the
+// resource points fall linearly to nothing at 100%
+const healthyUsagePercent = 60
+
+// heartbeatLatenessDecay is how much each heartbeat moves the lateness
+// average, so one late heartbeat only dents the score for a while
+const heartbeatLatenessDecay = 0.2
+
+// HealthAt rates the agent from 0 to 100 at the given time, from its
+// resource headroom, active alerts, container health and how regularly its
+// heartbeats arrive. Agents that aren't reporting, whether offline or shut
+// down, score 0.
+func (s *ServerState) HealthAt(now time.Time) int {
+	if s.Status != "online" && s.Status != "degraded" {
+		return 0
+	}
+
+	score := healthResourcePoints * s.resourceHealth()
+	score += healthAlertPoints * s.alertHealth()
+	score += healthContainerPoints * s.containerHealth()
+	score += healthHeartbeatPoints * s.heartbeatHealth(now)
+	return int(math.Round(score))
+}
+
+// resourceHealth is 1 while CPU, memory and every disk are at most
+// healthyUsagePercent used, falling to 0 as the fullest reaches 100%
+func (s *ServerState) resourceHealth() float64 {
+	used := math.Max(s.SystemMetrics.CPU.UsagePercent, s.SystemMetrics.Memory.UsedPercent)
+	for _, disk := range s.SystemMetrics.Disk {
+		used = math.Max(used, disk.UsedPercent)
+	}
+	if used <= healthyUsagePercent {
+		return 1
+	}
+	return math.Max(0, (100-used)/(100-healthyUsagePercent))
+}
+
+// alertHealth is 1 without active alerts; a critical alert takes away more
+// than half of it, a warning less than a third
+func (s *ServerState) alertHealth() float64 {
+	health := 1.0
+	for _, alert := range s.ActiveAlerts {
+		if alert.Status == "resolved" {
+			continue
+		}
+		switch alert.Severity {
+		case "critical":
+			health -= 0.6
+		case "warning":
+			health -= 0.3
+		default:
+			health -= 0.1
+		}
+	}
+	return math.Max(0, health)
+}
+
+// containerHealth is the share of containers without problems, 1 on hosts
+// without containers
+func (s *ServerState) containerHealth() float64 {
+	if len(s.Containers) == 0 {
+		return 1
+	}
+	healthy := 0
+	for _, c := range s.Containers {
+		if !c.troubled() {
+			healthy++
+		}
+	}
+	return float64(healthy) / float64(len(s.Containers))
+}
+
+// troubled reports whether the container is failing: unhealthy, crash
+// looping, killed for memory or exited with an error
+func (c ContainerState) troubled() bool {
+	switch {
+	case c.Health == "unhealthy", c.State == "restarting", c.State == "dead":
+		return true
+	case c.OOMKilled, c.OOMKillsLastHour > 0:
+		return true
+	case c.State == "exited" && c.ExitCode != 0:
+		return true
+	}
+	return false
+}
+
+// heartbeatHealth is 1 for an agent whose heartbeats arrive on time. Late
+// heartbeats lower it, as does the current one being overdue. Agents that
+// don't report their heartbeat interval can't be judged and get 1.
+func (s *ServerState) heartbeatHealth(now time.Time) float64 {
+	if s.HeartbeatInterval <= 0 || s.LastHeartbeatAt.IsZero() {
+		return 1
+	}
+	lateness := math.Max(s.HeartbeatLateness, heartbeatLateness(now.Sub(s.LastHeartbeatAt), s.HeartbeatInterval))
+	return 1 - lateness
+}
+
+// heartbeatLateness rates the gap between two heartbeats from 0, up to half
+// an interval late, to 1 when so many were missed that the agent would be
+// considered offline
+func heartbeatLateness(gap, interval time.Duration) float64 {
+	grace := interval * 3 / 2
+	late := gap - grace
+	if late <= 0 {
+		return 0
+	}
+	return math.Min(1, float64(late)/float64(missedHeartbeats*interval-grace))
+}
+
+// recordHeartbeat updates the agent's heartbeat lateness average with a
+// heartbeat that arrived at
+func (s *ServerState) recordHeartbeat(at time.Time) {
+	if s.HeartbeatInterval > 0 && !s.LastHeartbeatAt.IsZero() && at.After(s.LastHeartbeatAt) {
+		lateness := heartbeatLateness(at.Sub(s.LastHeartbeatAt), s.HeartbeatInterval)
+		s.HeartbeatLateness += heartbeatLatenessDecay * (lateness - s.HeartbeatLateness)
+	}
+	if at.After(s.LastHeartbeatAt) {
+		s.LastHeartbeatAt = at
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func TestServerState_HealthAt(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		state ServerState
+		want  int
+	}{
+		{"idle", ServerState{Status: "online"}, 100},
+		{"offline", ServerState{Status: "offline"}, 0},
+		{"stopped", ServerState{Status: "stopped"}, 0},
+		{
+			"busy CPU",
+			ServerState{Status: "online", SystemMetrics: metrics.SystemMetrics{CPU: metrics.CPUMetrics{UsagePercent: 80}}},
+			80, // Half the resource points
+		},
+		{
+			"full disk",
+			ServerState{Status: "online", SystemMetrics: metrics.SystemMetrics{Disk: []metrics.DiskMetrics{{MountPoint: "/", UsedPercent: 100}}}},
+			60,
+		},
+		{
+			"critical and warning alerts",
+			ServerState{Status: "degraded", ActiveAlerts: []Alert{{Severity: "critical"}, {Severity: "warning"}}},
+			78, // 10% of the alert points left
+		},
+		{
+			"one of two containers unhealthy",
+			ServerState{Status: "online", Containers: []ContainerState{{State: "running"}, {State: "running", Health: "unhealthy"}}},
+			90,
+		},
+		{
+			"container exited cleanly",
+			ServerState{Status: "online", Containers: []ContainerState{{State: "exited"}}},
+			100,
+		},
+		{
+			"heartbeat overdue",
+			ServerState{Status: "online", HeartbeatInterval: 10 * time.Second, LastHeartbeatAt: now.Add(-30 * time.Second)},
+			85,
+		},
+		{
+			"heartbeat just slightly late",
+			ServerState{Status: "online", HeartbeatInterval: 10 * time.Second, LastHeartbeatAt: now.Add(-12 * time.Second)},
+			100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.state.HealthAt(now); got != tt.want {
+				t.Errorf("Expected health %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestServerState_RecordHeartbeat(t *testing.T) {
+	state := &ServerState{Status: "online", HeartbeatInterval: 10 * time.Second}
+	start := time.Now().Add(-time.Hour)
+
+	state.recordHeartbeat(start)
+	state.recordHeartbeat(start.Add(10 * time.Second))
+	if state.HeartbeatLateness != 0 {
+		t.Errorf("Expected no lateness for on-time heartbeats, got %v", state.HeartbeatLateness)
+	}
+
+	// Missed enough heartbeats to count as offline
+	state.recordHeartbeat(start.Add(50 * time.Second))
+	if state.HeartbeatLateness != heartbeatLatenessDecay {
+		t.Errorf("Expected lateness %v after one missed gap, got %v", heartbeatLatenessDecay, state.HeartbeatLateness)
+	}
+	late := state.HeartbeatLateness
+
+	// Back on time, the average recovers
+	state.recordHeartbeat(start.Add(60 * time.Second))
+	if state.HeartbeatLateness >= late {
+		t.Errorf("Expected lateness to fall below %v, got %v", late, state.HeartbeatLateness)
+	}
+	if score := state.HealthAt(start.Add(61 * time.Second)); score >= 100 || score < 85 {
+		t.Errorf("Expected a slightly lowered health score, got %d", score)
+	}
+}
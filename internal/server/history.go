@@ -0,0 +1,294 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HistoryResolution is the spacing of recent history samples; older history
+// is kept as hourly averages
+const HistoryResolution = time.Minute
+
+// Default history retention
+const (
+	DefaultHistoryRetention       = 24 * time.Hour
+	DefaultHistoryRollupRetention = 30 * 24 * time.Hour
+)
+
+// HistoryMetrics are the metrics a HistorySample holds, by name
+var HistoryMetrics = []string{
+	"cpu_percent",
+	"memory_percent",
+	"swap_percent",
+	"load1",
+	"disk_percent",
+	"containers_running",
+	"containers",
+}
+
+// HistorySample is an agent's core metrics at one point in time. Hourly
+// samples hold averages over the hour starting at Time.
+type HistorySample struct {
+	Time              time.Time `json:"time"`
+	CPUPercent        float64   `json:"cpu_percent"`
+	MemoryPercent     float64   `json:"memory_percent"`
+	SwapPercent       float64   `json:"swap_percent"`
+	Load1             float64   `json:"load1"`
+	DiskPercent       float64   `json:"disk_percent"` // Fullest disk
+	ContainersRunning float64   `json:"containers_running"`
+	Containers        float64   `json:"containers"`
+}
+
+// Value returns one of the HistoryMetrics
+func (s HistorySample) Value(metric string) (float64, bool) {
+	switch metric {
+	case "cpu_percent":
+		return s.CPUPercent, true
+	case "memory_percent":
+		return s.MemoryPercent, true
+	case "swap_percent":
+		return s.SwapPercent, true
+	case "load1":
+		return s.Load1, true
+	case "disk_percent":
+		return s.DiskPercent, true
+	case "containers_running":
+		return s.ContainersRunning, true
+	case "containers":
+		return s.Containers, true
+	}
+	return 0, false
+}
+
+// historySampleOf takes the history sample of an agent's state
+func historySampleOf(state *ServerState, at time.Time) HistorySample {
+	m := state.SystemMetrics
+	sample := HistorySample{
+		Time:          at,
+		CPUPercent:    m.CPU.UsagePercent,
+		MemoryPercent: m.Memory.UsedPercent,
+		SwapPercent:   m.Memory.SwapPercent,
+		Load1:         m.CPU.LoadAvg1,
+		Containers:    float64(len(state.Containers)),
+	}
+	for _, disk := range m.Disk {
+		sample.DiskPercent = max(sample.DiskPercent, disk.UsedPercent)
+	}
+	for _, c := range state.Containers {
+		if c.State == "running" {
+			sample.ContainersRunning++
+		}
+	}
+	return sample
+}
+
+// History keeps the metrics of every agent in memory: a sample per
+// HistoryResolution for the retention period, then hourly averages for the
+// rollup period. Memory use is fixed per agent, about 100KB a day of
+// samples and 50KB a month of rollups. It also keeps the periods agents
+// were offline for, see uptime.go.
+type History struct {
+	recentSize, hourlySize int
+	keep                   time.Duration // The longer of the two retentions
+
+	mu     sync.RWMutex
+	agents map[string]*agentHistory // key: agent_name
+}
+
+// agentHistory is the history of one agent
+type agentHistory struct {
+	mu     sync.Mutex
+	recent sampleRing
+	hourly sampleRing
+
+	// Running sums of the hour being rolled up
+	hour      HistorySample
+	hourCount int
+
+	// See uptime.go
+	firstSeen time.Time
+	incidents []Incident
+}
+
+// NewHistory creates a history that keeps samples for retention and hourly
+// averages for rollupRetention (0 disables them)
+func NewHistory(retention, rollupRetention time.Duration) *History {
+	return &History{
+		recentSize: max(int(retention/HistoryResolution), 1),
+		hourlySize: max(int(rollupRetention/time.Hour), 0),
+		keep:       max(retention, rollupRetention),
+		agents:     make(map[string]*agentHistory),
+	}
+}
+
+// agent returns an agent's history, creating it first seen at the given time
+func (h *History) agent(agentName string, at time.Time) *agentHistory {
+	h.mu.RLock()
+	agent, exists := h.agents[agentName]
+	h.mu.RUnlock()
+	if exists {
+		return agent
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if agent, exists = h.agents[agentName]; !exists {
+		agent = &agentHistory{
+			recent:    sampleRing{samples: make([]HistorySample, 0, h.recentSize)},
+			hourly:    sampleRing{samples: make([]HistorySample, 0, h.hourlySize)},
+			firstSeen: at,
+		}
+		h.agents[agentName] = agent
+	}
+	return agent
+}
+
+// Record adds a sample. Samples within the same HistoryResolution replace
+// each other, so the latest one is kept; samples older than the latest are
+// dropped.
+func (h *History) Record(agentName string, sample HistorySample) {
+	agent := h.agent(agentName, sample.Time)
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	sample.Time = sample.Time.Truncate(HistoryResolution)
+	if last := agent.recent.last(); last != nil {
+		if sample.Time.Before(last.Time) {
+			return
+		}
+		if sample.Time.Equal(last.Time) {
+			*last = sample
+		} else {
+			agent.recent.push(sample)
+		}
+	} else {
+		agent.recent.push(sample)
+	}
+
+	// Roll the previous hour up once a sample of a later one arrives
+	hour := sample.Time.Truncate(time.Hour)
+	if agent.hourCount > 0 && !agent.hour.Time.Equal(hour) {
+		agent.hourly.push(agent.hour.average(agent.hourCount))
+		agent.hour, agent.hourCount = HistorySample{}, 0
+	}
+	agent.hour.add(sample)
+	agent.hour.Time = hour
+	agent.hourCount++
+}
+
+// Samples returns an agent's samples between from and to, oldest first:
+// hourly averages up to where the recent samples start, then the recent
+// samples
+func (h *History) Samples(agentName string, from, to time.Time) []HistorySample {
+	h.mu.RLock()
+	agent, exists := h.agents[agentName]
+	h.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	recent := agent.recent.ordered()
+	var samples []HistorySample
+	for _, s := range agent.hourly.ordered() {
+		if len(recent) > 0 && !s.Time.Before(recent[0].Time.Truncate(time.Hour)) {
+			break
+		}
+		if !s.Time.Before(from.Truncate(time.Hour)) && !s.Time.After(to) {
+			samples = append(samples, s)
+		}
+	}
+	for _, s := range recent {
+		if !s.Time.Before(from) && !s.Time.After(to) {
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+// Agents returns the names of the agents with history, sorted
+func (h *History) Agents() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	names := make([]string, 0, len(h.agents))
+	for name := range h.agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Forget drops an agent's history
+func (h *History) Forget(agentName string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.agents, agentName)
+}
+
+// add sums a sample into s, for averaging
+func (s *HistorySample) add(o HistorySample) {
+	s.CPUPercent += o.CPUPercent
+	s.MemoryPercent += o.MemoryPercent
+	s.SwapPercent += o.SwapPercent
+	s.Load1 += o.Load1
+	s.DiskPercent += o.DiskPercent
+	s.ContainersRunning += o.ContainersRunning
+	s.Containers += o.Containers
+}
+
+// average divides summed samples by their count
+func (s HistorySample) average(count int) HistorySample {
+	n := float64(count)
+	return HistorySample{
+		Time:              s.Time,
+		CPUPercent:        s.CPUPercent / n,
+		MemoryPercent:     s.MemoryPercent / n,
+		SwapPercent:       s.SwapPercent / n,
+		Load1:             s.Load1 / n,
+		DiskPercent:       s.DiskPercent / n,
+		ContainersRunning: s.ContainersRunning / n,
+		Containers:        s.Containers / n,
+	}
+}
+
+// sampleRing keeps the latest samples up to its capacity
+type sampleRing struct {
+	samples []HistorySample
+	next    int // Where the next sample goes once full
+}
+
+func (r *sampleRing) push(s HistorySample) {
+	switch {
+	case cap(r.samples) == 0:
+	case len(r.samples) < cap(r.samples):
+		r.samples = append(r.samples, s)
+	default:
+		r.samples[r.next] = s
+		r.next = (r.next + 1) % len(r.samples)
+	}
+}
+
+// last returns the newest sample, or nil if there are none
+func (r *sampleRing) last() *HistorySample {
+	if len(r.samples) == 0 {
+		return nil
+	}
+	if len(r.samples) < cap(r.samples) {
+		return &r.samples[len(r.samples)-1]
+	}
+	return &r.samples[(r.next+len(r.samples)-1)%len(r.samples)]
+}
+
+// ordered returns a copy of the samples, oldest first
+func (r *sampleRing) ordered() []HistorySample {
+	samples := make([]HistorySample, 0, len(r.samples))
+	if len(r.samples) < cap(r.samples) {
+		return append(samples, r.samples...)
+	}
+	samples = append(samples, r.samples[r.next:]...)
+	return append(samples, r.samples[:r.next]...)
+}
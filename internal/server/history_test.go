@@ -0,0 +1,127 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/pkg/metrics"
+)
+
+func TestHistory_RecordsOneSamplePerMinute(t *testing.T) {
+	history := NewHistory(time.Hour, 0)
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	history.Record("web-1", HistorySample{Time: start, CPUPercent: 10})
+	history.Record("web-1", HistorySample{Time: start.Add(30 * time.Second), CPUPercent: 20})
+	history.Record("web-1", HistorySample{Time: start.Add(time.Minute), CPUPercent: 30})
+	// Late samples are dropped
+	history.Record("web-1", HistorySample{Time: start, CPUPercent: 99})
+
+	samples := history.Samples("web-1", start, start.Add(time.Hour))
+	if len(samples) != 2 {
+		t.Fatalf("Expected 2 samples, got %d", len(samples))
+	}
+	if samples[0].CPUPercent != 20 || samples[1].CPUPercent != 30 {
+		t.Errorf("Expected the latest sample of each minute, got %v and %v", samples[0].CPUPercent, samples[1].CPUPercent)
+	}
+	if !samples[0].Time.Equal(start) {
+		t.Errorf("Expected samples at whole minutes, got %v", samples[0].Time)
+	}
+
+	if samples := history.Samples("web-1", start.Add(time.Minute), start.Add(time.Hour)); len(samples) != 1 {
+		t.Errorf("Expected 1 sample from the second minute, got %d", len(samples))
+	}
+	if samples := history.Samples("unknown", start, start.Add(time.Hour)); len(samples) != 0 {
+		t.Errorf("Expected no samples for an unknown agent, got %d", len(samples))
+	}
+}
+
+func TestHistory_RollsUpOlderSamples(t *testing.T) {
+	// Recent samples cover 30 minutes, older ones only hourly averages
+	history := NewHistory(30*time.Minute, 24*time.Hour)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3*60; i++ {
+		hour := i / 60
+		history.Record("db-1", HistorySample{
+			Time:          start.Add(time.Duration(i) * time.Minute),
+			MemoryPercent: float64(10 * (hour + 1)),
+			Load1:         float64(i % 2), // Averages to 0.5
+		})
+	}
+
+	samples := history.Samples("db-1", start, start.Add(3*time.Hour))
+	// Hours 0 and 1 as averages, then the last 30 minutes of hour 2
+	if len(samples) != 2+30 {
+		t.Fatalf("Expected 32 samples, got %d", len(samples))
+	}
+	if samples[0].MemoryPercent != 10 || samples[1].MemoryPercent != 20 {
+		t.Errorf("Expected hourly averages of 10 and 20, got %v and %v", samples[0].MemoryPercent, samples[1].MemoryPercent)
+	}
+	if samples[0].Load1 != 0.5 {
+		t.Errorf("Expected averaged load of 0.5, got %v", samples[0].Load1)
+	}
+	if !samples[1].Time.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected the second rollup at 01:00, got %v", samples[1].Time)
+	}
+	if !samples[2].Time.Equal(start.Add(150 * time.Minute)) {
+		t.Errorf("Expected recent samples to start at 02:30, got %v", samples[2].Time)
+	}
+	for i := 1; i < len(samples); i++ {
+		if !samples[i].Time.After(samples[i-1].Time) {
+			t.Fatalf("Expected samples oldest first, got %v after %v", samples[i].Time, samples[i-1].Time)
+		}
+	}
+}
+
+func TestHistory_Value(t *testing.T) {
+	sample := HistorySample{CPUPercent: 1, MemoryPercent: 2, SwapPercent: 3, Load1: 4, DiskPercent: 5, ContainersRunning: 6, Containers: 7}
+	for i, metric := range HistoryMetrics {
+		value, ok := sample.Value(metric)
+		if !ok || value != float64(i+1) {
+			t.Errorf("%s: expected %d, got %v (%v)", metric, i+1, value, ok)
+		}
+	}
+	if _, ok := sample.Value("unknown"); ok {
+		t.Error("Expected unknown metrics to be rejected")
+	}
+}
+
+func TestStateStore_RecordsHistory(t *testing.T) {
+	store := NewStateStore()
+	history := NewHistory(time.Hour, 0)
+	store.SetHistory(history)
+
+	store.UpdateAgent(&ServerState{
+		AgentName: "web-1",
+		SystemMetrics: metrics.SystemMetrics{
+			CPU:    metrics.CPUMetrics{UsagePercent: 42},
+			Memory: metrics.MemoryMetrics{UsedPercent: 60},
+			Disk: []metrics.DiskMetrics{
+				{MountPoint: "/", UsedPercent: 40},
+				{MountPoint: "/data", UsedPercent: 80},
+			},
+		},
+		Containers: []ContainerState{
+			{ID: "a", Name: "app", State: "running"},
+			{ID: "b", Name: "job", State: "exited"},
+		},
+	})
+
+	samples := history.Samples("web-1", time.Now().Add(-time.Hour), time.Now())
+	if len(samples) != 1 {
+		t.Fatalf("Expected 1 sample, got %d", len(samples))
+	}
+	s := samples[0]
+	if s.CPUPercent != 42 || s.MemoryPercent != 60 || s.DiskPercent != 80 {
+		t.Errorf("Unexpected sample: %+v", s)
+	}
+	if s.ContainersRunning != 1 || s.Containers != 2 {
+		t.Errorf("Expected 1 of 2 containers running, got %v of %v", s.ContainersRunning, s.Containers)
+	}
+
+	store.RemoveAgent("web-1")
+	if agents := history.Agents(); len(agents) != 0 {
+		t.Errorf("Expected history of removed agents to be dropped, got %v", agents)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AlertFirings counts how often one condition fired
+type AlertFirings struct {
+	Fingerprint   string    `json:"fingerprint"`
+	AgentName     string    `json:"agent_name"`
+	AlertType     string    `json:"alert_type"`
+	Subject       string    `json:"subject,omitempty"` // Container or mount point, if any
+	Firings       int       `json:"firings"`
+	LastTriggered time.Time `json:"last_triggered"`
+}
+
+// AgentFirings counts how often an agent's alerts fired
+type AgentFirings struct {
+	AgentName    string `json:"agent_name"`
+	Firings      int    `json:"firings"`
+	Fingerprints int    `json:"fingerprints"` // Distinct conditions that fired
+}
+
+// NoisyAlerts ranks the conditions and agents that fired most often
+type NoisyAlerts struct {
+	From         time.Time      `json:"from"`
+	To           time.Time      `json:"to"`
+	Firings      int            `json:"firings"` // All alerts triggered in the window
+	Fingerprints []AlertFirings `json:"fingerprints"`
+	Agents       []AgentFirings `json:"agents"`
+}
+
+// Fingerprint identifies the condition an alert is about: the same
+// fingerprint firing again is the same problem coming back
+func (a *Alert) Fingerprint() string {
+	if subject := a.subject(); subject != "" {
+		return fmt.Sprintf("%s:%s:%s", a.AlertType, a.AgentName, subject)
+	}
+	return fmt.Sprintf("%s:%s", a.AlertType, a.AgentName)
+}
+
+// subject is the container or disk the alert is about, if any
+func (a *Alert) subject() string {
+	for _, key := range []string{"container_name", "mount_point"} {
+		if value, ok := a.Details[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// NoisyAlerts returns the top conditions and agents by alerts triggered
+// between from and to
+func (s *StateStore) NoisyAlerts(from, to time.Time, top int) NoisyAlerts {
+	noisy := NoisyAlerts{From: from, To: to}
+	fingerprints := make(map[string]*AlertFirings)
+	agents := make(map[string]*AgentFirings)
+
+	for _, alert := range s.GetAlertsByStatus("all") {
+		if alert.TriggeredAt.Before(from) || alert.TriggeredAt.After(to) {
+			continue
+		}
+		noisy.Firings++
+
+		fingerprint := alert.Fingerprint()
+		firings, exists := fingerprints[fingerprint]
+		if !exists {
+			firings = &AlertFirings{
+				Fingerprint: fingerprint,
+				AgentName:   alert.AgentName,
+				AlertType:   alert.AlertType,
+				Subject:     alert.subject(),
+			}
+			fingerprints[fingerprint] = firings
+		}
+		firings.Firings++
+		if alert.TriggeredAt.After(firings.LastTriggered) {
+			firings.LastTriggered = alert.TriggeredAt
+		}
+
+		agent, exists := agents[alert.AgentName]
+		if !exists {
+			agent = &AgentFirings{AgentName: alert.AgentName}
+			agents[alert.AgentName] = agent
+		}
+		agent.Firings++
+		if firings.Firings == 1 {
+			agent.Fingerprints++
+		}
+	}
+
+	noisy.Fingerprints = make([]AlertFirings, 0, len(fingerprints))
+	for _, firings := range fingerprints {
+		noisy.Fingerprints = append(noisy.Fingerprints, *firings)
+	}
+	sort.Slice(noisy.Fingerprints, func(i, j int) bool {
+		a, b := noisy.Fingerprints[i], noisy.Fingerprints[j]
+		if a.Firings != b.Firings {
+			return a.Firings > b.Firings
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+	if len(noisy.Fingerprints) > top {
+		noisy.Fingerprints = noisy.Fingerprints[:top]
+	}
+
+	noisy.Agents = make([]AgentFirings, 0, len(agents))
+	for _, agent := range agents {
+		noisy.Agents = append(noisy.Agents, *agent)
+	}
+	sort.Slice(noisy.Agents, func(i, j int) bool {
+		a, b := noisy.Agents[i], noisy.Agents[j]
+		if a.Firings != b.Firings {
+			return a.Firings > b.Firings
+		}
+		return a.AgentName < b.AgentName
+	})
+	if len(noisy.Agents) > top {
+		noisy.Agents = noisy.Agents[:top]
+	}
+	return noisy
+}
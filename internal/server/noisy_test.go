@@ -0,0 +1,68 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlert_Fingerprint(t *testing.T) {
+	tests := []struct {
+		alert Alert
+		want  string
+	}{
+		{Alert{AgentName: "web-1", AlertType: "system_cpu"}, "system_cpu:web-1"},
+		{Alert{AgentName: "web-1", AlertType: "system_disk", Details: map[string]interface{}{"mount_point": "/data"}}, "system_disk:web-1:/data"},
+		{Alert{AgentName: "web-1", AlertType: "container_stopped", Details: map[string]interface{}{"container_id": "abc123", "container_name": "nginx"}}, "container_stopped:web-1:nginx"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.alert.Fingerprint(); got != tt.want {
+			t.Errorf("Expected fingerprint %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestStateStore_NoisyAlerts(t *testing.T) {
+	store := NewStateStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	container := func(name string) map[string]interface{} {
+		return map[string]interface{}{"container_name": name}
+	}
+	alerts := []*Alert{
+		{ID: "1", AgentName: "web-1", AlertType: "container_stopped", Details: container("nginx"), TriggeredAt: start.Add(time.Hour)},
+		{ID: "2", AgentName: "web-1", AlertType: "container_stopped", Details: container("nginx"), TriggeredAt: start.Add(2 * time.Hour)},
+		{ID: "3", AgentName: "web-1", AlertType: "container_stopped", Details: container("nginx"), TriggeredAt: start.Add(3 * time.Hour)},
+		{ID: "4", AgentName: "web-1", AlertType: "container_stopped", Details: container("worker"), TriggeredAt: start.Add(time.Hour)},
+		{ID: "5", AgentName: "db-1", AlertType: "system_memory", TriggeredAt: start.Add(time.Hour)},
+		{ID: "6", AgentName: "db-1", AlertType: "system_memory", TriggeredAt: start.Add(2 * time.Hour)},
+		// Outside the window
+		{ID: "7", AgentName: "db-1", AlertType: "system_memory", TriggeredAt: start.Add(-time.Hour)},
+	}
+	for _, alert := range alerts {
+		store.AddAlert(alert)
+	}
+
+	noisy := store.NoisyAlerts(start, start.Add(24*time.Hour), 2)
+
+	if noisy.Firings != 6 {
+		t.Errorf("Expected 6 firings, got %d", noisy.Firings)
+	}
+	if len(noisy.Fingerprints) != 2 {
+		t.Fatalf("Expected the top 2 fingerprints, got %d", len(noisy.Fingerprints))
+	}
+	first := noisy.Fingerprints[0]
+	if first.Fingerprint != "container_stopped:web-1:nginx" || first.Firings != 3 || first.Subject != "nginx" {
+		t.Errorf("Expected nginx on web-1 with 3 firings first, got %+v", first)
+	}
+	if !first.LastTriggered.Equal(start.Add(3 * time.Hour)) {
+		t.Errorf("Expected last triggered at 03:00, got %v", first.LastTriggered)
+	}
+	if noisy.Fingerprints[1].Fingerprint != "system_memory:db-1" || noisy.Fingerprints[1].Firings != 2 {
+		t.Errorf("Expected system_memory:db-1 with 2 firings second, got %+v", noisy.Fingerprints[1])
+	}
+
+	if len(noisy.Agents) != 2 || noisy.Agents[0] != (AgentFirings{AgentName: "web-1", Firings: 4, Fingerprints: 2}) {
+		t.Errorf("Expected web-1 with 4 firings over 2 fingerprints first, got %+v", noisy.Agents)
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anurag/saviour/internal/alerting"
+)
+
+// AlertingSettings are the alert engine settings that can be tuned at
+// runtime through /api/v1/admin/alerting. Once changed they are saved to
+// alerting.settings_file and override the config file on later starts.
+type AlertingSettings struct {
+	HeartbeatTimeout          string  `json:"heartbeat_timeout"` // Go duration, e.g. "2m"
+	DeduplicationEnabled      bool    `json:"deduplication_enabled"`
+	DeduplicationWindow       string  `json:"deduplication_window"` // Go duration
+	SystemCPUThreshold        float64 `json:"system_cpu_threshold"`
+	SystemMemoryThreshold     float64 `json:"system_memory_threshold"`
+	SystemDiskThreshold       float64 `json:"system_disk_threshold"`
+	ContainerCPUThreshold     float64 `json:"container_cpu_threshold"`
+	ContainerMemoryThreshold  float64 `json:"container_memory_threshold"`
+	ContainerOOMKillThreshold int     `json:"container_oom_kill_threshold"`
+	DockerGoroutineThreshold  int     `json:"docker_goroutine_threshold"`
+	ClockSkewThreshold        string  `json:"clock_skew_threshold"` // Go duration, "0s" disables it
+
+	UpdatedAt *time.Time `json:"updated_at,omitempty"` // Last runtime change, if any
+}
+
+// AlertingSettingsOf returns the tunable settings of an engine configuration
+func AlertingSettingsOf(c alerting.Config) AlertingSettings {
+	return AlertingSettings{
+		HeartbeatTimeout:          c.HeartbeatTimeout.String(),
+		DeduplicationEnabled:      c.DeduplicationEnabled,
+		DeduplicationWindow:       c.DeduplicationWindow.String(),
+		SystemCPUThreshold:        c.SystemCPUThreshold,
+		SystemMemoryThreshold:     c.SystemMemoryThreshold,
+		SystemDiskThreshold:       c.SystemDiskThreshold,
+		ContainerCPUThreshold:     c.ContainerCPUThreshold,
+		ContainerMemoryThreshold:  c.ContainerMemoryThreshold,
+		ContainerOOMKillThreshold: c.ContainerOOMKillThreshold,
+		DockerGoroutineThreshold:  c.DockerGoroutineThreshold,
+		ClockSkewThreshold:        c.ClockSkewThreshold.String(),
+	}
+}
+
+// Apply validates the settings and sets them on an engine configuration,
+// which is left unchanged if they are invalid
+func (s AlertingSettings) Apply(c *alerting.Config) error {
+	heartbeatTimeout, err := time.ParseDuration(s.HeartbeatTimeout)
+	if err != nil || heartbeatTimeout <= 0 {
+		return fmt.Errorf("heartbeat_timeout must be a duration > 0, got: %q", s.HeartbeatTimeout)
+	}
+	deduplicationWindow, err := time.ParseDuration(s.DeduplicationWindow)
+	if err != nil || (s.DeduplicationEnabled && deduplicationWindow <= 0) {
+		return fmt.Errorf("deduplication_window must be a duration > 0, got: %q", s.DeduplicationWindow)
+	}
+	clockSkewThreshold, err := time.ParseDuration(s.ClockSkewThreshold)
+	if err != nil || clockSkewThreshold < 0 {
+		return fmt.Errorf("clock_skew_threshold must be a duration >= 0, got: %q", s.ClockSkewThreshold)
+	}
+	for _, t := range []struct {
+		name  string
+		value float64
+	}{
+		{"system_cpu_threshold", s.SystemCPUThreshold},
+		{"system_memory_threshold", s.SystemMemoryThreshold},
+		{"system_disk_threshold", s.SystemDiskThreshold},
+		{"container_cpu_threshold", s.ContainerCPUThreshold},
+		{"container_memory_threshold", s.ContainerMemoryThreshold},
+	} {
+		if t.value < 0 || t.value > 100 {
+			return fmt.Errorf("%s must be between 0 and 100, got: %.2f", t.name, t.value)
+		}
+	}
+	if s.ContainerOOMKillThreshold < 0 {
+		return fmt.Errorf("container_oom_kill_threshold must be >= 0, got: %d", s.ContainerOOMKillThreshold)
+	}
+	if s.DockerGoroutineThreshold < 0 {
+		return fmt.Errorf("docker_goroutine_threshold must be >= 0, got: %d", s.DockerGoroutineThreshold)
+	}
+
+	c.HeartbeatTimeout = heartbeatTimeout
+	c.DeduplicationEnabled = s.DeduplicationEnabled
+	c.DeduplicationWindow = deduplicationWindow
+	c.SystemCPUThreshold = s.SystemCPUThreshold
+	c.SystemMemoryThreshold = s.SystemMemoryThreshold
+	c.SystemDiskThreshold = s.SystemDiskThreshold
+	c.ContainerCPUThreshold = s.ContainerCPUThreshold
+	c.ContainerMemoryThreshold = s.ContainerMemoryThreshold
+	c.ContainerOOMKillThreshold = s.ContainerOOMKillThreshold
+	c.DockerGoroutineThreshold = s.DockerGoroutineThreshold
+	c.ClockSkewThreshold = clockSkewThreshold
+	return nil
+}
+
+// LoadAlertingSettings reads settings saved by SaveAlertingSettings. Returns
+// nil without an error if none were saved.
+func LoadAlertingSettings(path string) (*AlertingSettings, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alerting settings: %w", err)
+	}
+
+	var settings AlertingSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse alerting settings %s: %w", path, err)
+	}
+	return &settings, nil
+}
+
+// SaveAlertingSettings writes settings to path, replacing the previous file
+// in one step so a crash can't leave it half written
+func SaveAlertingSettings(path string, settings AlertingSettings) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".alerting-settings-*")
+	if err != nil {
+		return fmt.Errorf("failed to save alerting settings: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save alerting settings: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save alerting settings: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save alerting settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/alerting"
+)
+
+func TestAlertingSettings_Apply(t *testing.T) {
+	config := alerting.Config{
+		HeartbeatTimeout:      2 * time.Minute,
+		DeduplicationEnabled:  true,
+		DeduplicationWindow:   5 * time.Minute,
+		SystemCPUThreshold:    80,
+		SystemMemoryThreshold: 85,
+		SystemDiskThreshold:   90,
+		ClockSkewThreshold:    30 * time.Second,
+		Rules:                 []alerting.Rule{{Name: "backlog"}},
+	}
+
+	settings := AlertingSettingsOf(config)
+	if settings.HeartbeatTimeout != "2m0s" || settings.SystemCPUThreshold != 80 {
+		t.Errorf("Unexpected settings: %+v", settings)
+	}
+
+	settings.SystemCPUThreshold = 95
+	settings.DeduplicationWindow = "30m"
+	settings.ClockSkewThreshold = "0s"
+	if err := settings.Apply(&config); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if config.SystemCPUThreshold != 95 || config.DeduplicationWindow != 30*time.Minute || config.ClockSkewThreshold != 0 {
+		t.Errorf("Settings not applied: %+v", config)
+	}
+	if len(config.Rules) != 1 {
+		t.Error("Expected settings to leave the rest of the configuration alone")
+	}
+
+	tests := []struct {
+		name   string
+		change func(*AlertingSettings)
+	}{
+		{"invalid duration", func(s *AlertingSettings) { s.HeartbeatTimeout = "soon" }},
+		{"zero heartbeat timeout", func(s *AlertingSettings) { s.HeartbeatTimeout = "0s" }},
+		{"zero deduplication window", func(s *AlertingSettings) { s.DeduplicationWindow = "0s" }},
+		{"negative clock skew", func(s *AlertingSettings) { s.ClockSkewThreshold = "-1s" }},
+		{"threshold above 100", func(s *AlertingSettings) { s.ContainerMemoryThreshold = 101 }},
+		{"negative count", func(s *AlertingSettings) { s.ContainerOOMKillThreshold = -1 }},
+	}
+	for _, tt := range tests {
+		invalid := AlertingSettingsOf(config)
+		tt.change(&invalid)
+		before := config
+		if err := invalid.Apply(&config); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+		if config.HeartbeatTimeout != before.HeartbeatTimeout || config.SystemCPUThreshold != before.SystemCPUThreshold {
+			t.Errorf("%s: expected the configuration to be left unchanged", tt.name)
+		}
+	}
+}
+
+func TestAlertingSettings_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerting-settings.json")
+
+	saved, err := LoadAlertingSettings(path)
+	if err != nil || saved != nil {
+		t.Fatalf("Expected no settings before saving, got %+v, %v", saved, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	settings := AlertingSettings{HeartbeatTimeout: "5m", DeduplicationWindow: "10m", ClockSkewThreshold: "30s", SystemCPUThreshold: 90, UpdatedAt: &now}
+	if err := SaveAlertingSettings(path, settings); err != nil {
+		t.Fatalf("SaveAlertingSettings() error = %v", err)
+	}
+
+	saved, err = LoadAlertingSettings(path)
+	if err != nil {
+		t.Fatalf("LoadAlertingSettings() error = %v", err)
+	}
+	if saved == nil || saved.HeartbeatTimeout != "5m" || saved.SystemCPUThreshold != 90 || !saved.UpdatedAt.Equal(now) {
+		t.Errorf("Expected the saved settings back, got %+v", saved)
+	}
+}
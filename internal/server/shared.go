@@ -0,0 +1,94 @@
+package server
+
+import "time"
+
+// AlertAck is who acknowledged or took an open alert. Servers sharing state
+// number their alerts separately, so acks are shared by agent and alert type.
+type AlertAck struct {
+	AgentName    string     `json:"agent_name"`
+	AlertType    string     `json:"alert_type"`
+	Acknowledged bool       `json:"acknowledged"`
+	Assignee     string     `json:"assignee,omitempty"`
+	AssignedAt   *time.Time `json:"assigned_at,omitempty"`
+}
+
+// SharedState receives the silences and acks changed on this server, for
+// redundant servers to make the same notification decisions. Its methods
+// are called with the store locked and must not block.
+type SharedState interface {
+	PutSilence(silence Silence)
+	DeleteSilence(silenceID string)
+	PutAck(ack AlertAck)
+	DeleteAck(agentName, alertType string)
+}
+
+// SetSharedState sets where silence and ack changes are sent. Call it
+// before serving requests.
+func (s *StateStore) SetSharedState(shared SharedState) {
+	s.shared = shared
+}
+
+// ackKey identifies the alerts an ack applies to
+func ackKey(agentName, alertType string) string {
+	return agentName + "\x00" + alertType
+}
+
+// ackOf returns an alert's ack
+func ackOf(alert *Alert) AlertAck {
+	return AlertAck{
+		AgentName:    alert.AgentName,
+		AlertType:    alert.AlertType,
+		Acknowledged: alert.Status == "acknowledged",
+		Assignee:     alert.Assignee,
+		AssignedAt:   alert.AssignedAt,
+	}
+}
+
+// ApplySharedState replaces the silences with the shared ones and applies
+// the shared acks to open alerts, as changed on any of the servers
+func (s *StateStore) ApplySharedState(silences []Silence, acks []AlertAck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.silences = make(map[string]*Silence, len(silences))
+	for i := range silences {
+		s.silences[silences[i].ID] = &silences[i]
+	}
+	s.sharedAcks = make(map[string]AlertAck, len(acks))
+	for _, ack := range acks {
+		s.sharedAcks[ackKey(ack.AgentName, ack.AlertType)] = ack
+	}
+
+	changed := false
+	for _, alert := range s.alerts {
+		ack, exists := s.sharedAcks[ackKey(alert.AgentName, alert.AlertType)]
+		if !exists || alert.Status == "resolved" {
+			continue
+		}
+		if (ack.Acknowledged && alert.Status == "active") || ack.Assignee != alert.Assignee {
+			if ack.Acknowledged {
+				alert.Status = "acknowledged"
+			}
+			alert.Assignee, alert.AssignedAt = ack.Assignee, ack.AssignedAt
+			s.syncAgentAlert(alert)
+			changed = true
+		}
+	}
+	if changed {
+		s.changed()
+	}
+}
+
+// syncAgentAlert updates the agent's copy of an open alert. Callers hold mu.
+func (s *StateStore) syncAgentAlert(alert *Alert) {
+	shard := s.shardFor(alert.AgentName)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if state, exists := shard.agents[alert.AgentName]; exists {
+		for i := range state.ActiveAlerts {
+			if state.ActiveAlerts[i].ID == alert.ID {
+				state.ActiveAlerts[i] = *alert
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Silence mutes notifications for matching alerts during a time window.
+// Alerts are still recorded, only the notification is skipped.
+type Silence struct {
+	ID        string    `json:"id"`
+	AgentName string    `json:"agent_name,omitempty"` // Glob pattern, empty matches all agents
+	AlertType string    `json:"alert_type,omitempty"` // Exact type, empty matches all types
+	Comment   string    `json:"comment,omitempty"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+}
+
+// Matches reports whether the silence covers an alert at the given time
+func (s *Silence) Matches(agentName, alertType string, at time.Time) bool {
+	if at.Before(s.StartsAt) || !at.Before(s.EndsAt) {
+		return false
+	}
+	if s.AlertType != "" && s.AlertType != alertType {
+		return false
+	}
+	if s.AgentName != "" {
+		if matched, _ := filepath.Match(s.AgentName, agentName); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// AddSilence stores a silence, assigning an ID and defaulting the start to
+// now. Returns a copy of the stored silence.
+func (s *StateStore) AddSilence(silence Silence) *Silence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	silence.ID = uuid.New().String()
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = time.Now()
+	}
+	s.silences[silence.ID] = &silence
+	if s.shared != nil {
+		s.shared.PutSilence(silence)
+	}
+
+	silenceCopy := silence
+	return &silenceCopy
+}
+
+// GetSilences returns silences that have not yet expired, dropping expired
+// ones (returns copies to prevent data races)
+func (s *StateStore) GetSilences() []*Silence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	silences := make([]*Silence, 0, len(s.silences))
+	for id, silence := range s.silences {
+		if !now.Before(silence.EndsAt) {
+			delete(s.silences, id)
+			continue
+		}
+		silenceCopy := *silence
+		silences = append(silences, &silenceCopy)
+	}
+
+	sort.Slice(silences, func(i, j int) bool {
+		return silences[i].EndsAt.Before(silences[j].EndsAt)
+	})
+	return silences
+}
+
+// DeleteSilence removes a silence. Returns false if it is unknown.
+func (s *StateStore) DeleteSilence(silenceID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.silences[silenceID]; !exists {
+		return false
+	}
+	delete(s.silences, silenceID)
+	if s.shared != nil {
+		s.shared.DeleteSilence(silenceID)
+	}
+	return true
+}
+
+// IsSilenced reports whether notifications for an alert are currently muted
+func (s *StateStore) IsSilenced(agentName, alertType string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	for _, silence := range s.silences {
+		if silence.Matches(agentName, alertType, now) {
+			return true
+		}
+	}
+	return false
+}
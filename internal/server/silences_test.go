@@ -0,0 +1,83 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSilence_Matches(t *testing.T) {
+	now := time.Now()
+	silence := &Silence{
+		AgentName: "web-*",
+		AlertType: "system_cpu_high",
+		StartsAt:  now.Add(-time.Minute),
+		EndsAt:    now.Add(time.Hour),
+	}
+
+	tests := []struct {
+		agent     string
+		alertType string
+		at        time.Time
+		want      bool
+	}{
+		{"web-1", "system_cpu_high", now, true},
+		{"db-1", "system_cpu_high", now, false},
+		{"web-1", "agent_offline", now, false},
+		{"web-1", "system_cpu_high", now.Add(-time.Hour), false},
+		{"web-1", "system_cpu_high", now.Add(2 * time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		if got := silence.Matches(tt.agent, tt.alertType, tt.at); got != tt.want {
+			t.Errorf("Matches(%s, %s, %v) = %v, want %v", tt.agent, tt.alertType, tt.at, got, tt.want)
+		}
+	}
+
+	// Empty matchers cover everything
+	all := &Silence{StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Minute)}
+	if !all.Matches("any", "any", now) {
+		t.Error("Expected silence without matchers to match all alerts")
+	}
+}
+
+func TestStateStore_Silences(t *testing.T) {
+	store := NewStateStore()
+
+	silence := store.AddSilence(Silence{
+		AgentName: "web-1",
+		EndsAt:    time.Now().Add(time.Hour),
+	})
+	if silence.ID == "" {
+		t.Fatal("Expected silence ID to be assigned")
+	}
+	if silence.StartsAt.IsZero() {
+		t.Error("Expected start to default to now")
+	}
+
+	// An expired silence is dropped from listings
+	store.AddSilence(Silence{
+		StartsAt: time.Now().Add(-2 * time.Hour),
+		EndsAt:   time.Now().Add(-time.Hour),
+	})
+
+	if got := store.GetSilences(); len(got) != 1 {
+		t.Fatalf("Expected 1 silence, got %d", len(got))
+	}
+
+	if !store.IsSilenced("web-1", "agent_offline") {
+		t.Error("Expected web-1 alerts to be silenced")
+	}
+	if store.IsSilenced("web-2", "agent_offline") {
+		t.Error("Expected web-2 alerts not to be silenced")
+	}
+
+	if !store.DeleteSilence(silence.ID) {
+		t.Error("Expected silence to be deleted")
+	}
+	if store.DeleteSilence(silence.ID) {
+		t.Error("Expected false deleting an unknown silence")
+	}
+	if store.IsSilenced("web-1", "agent_offline") {
+		t.Error("Expected web-1 alerts not to be silenced after delete")
+	}
+}
@@ -1,57 +1,362 @@
 package server
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/anurag/saviour/pkg/metrics"
 )
 
+// Number of agent shards, comfortably above typical core counts
+const agentShards = 32
+
+// missedHeartbeats is how many reported heartbeat intervals an agent may go
+// quiet for before the offline check gives up on it
+const missedHeartbeats = 3
+
+// DefaultMetricsTimeout is how long an agent that still heartbeats may go
+// without pushing metrics before it is reported as degraded
+const DefaultMetricsTimeout = 5 * time.Minute
+
+// maxPushLateness is how far a push's collection time may lag the stored
+// metrics and still be taken for a late retry; the agent's retries give up
+// well within it. A push further behind means the agent's clock stepped
+// back, and is kept so its metrics don't freeze until the clock catches up.
+const maxPushLateness = 2 * time.Minute
+
+// DefaultShutdownGracePeriod is how long an agent that announced a clean
+// shutdown may stay away before it is reported offline
+const DefaultShutdownGracePeriod = 30 * time.Minute
+
 // StateStore manages the in-memory state of all agents
+//
+// Agents are sharded by name so pushes from different agents don't contend
+// with each other or with readers. mu guards alerts and silences; code that
+// needs both takes mu before a shard lock.
 type StateStore struct {
+	shards   [agentShards]*agentShard
+	mu       sync.RWMutex
+	alerts   map[string]*Alert   // key: alert_id
+	silences map[string]*Silence // key: silence_id
+
+	customMetrics map[string]*CustomMetric // key: see customSeriesKey
+
+	// Bumped on every agent or alert change, see Revision
+	revision atomic.Uint64
+
+	metricsTimeout   time.Duration              // See SetMetricsTimeout
+	shutdownGrace    time.Duration              // See SetShutdownGracePeriod
+	timeoutOverrides []HeartbeatTimeoutOverride // See SetHeartbeatTimeoutOverrides
+	history          *History                   // See SetHistory
+
+	shared     SharedState         // See SetSharedState; nil unless sharing
+	sharedAcks map[string]AlertAck // key: see ackKey
+
+	refreshes sync.Map // Agent names asked to push right away, see RequestRefresh
+	refreshed sync.Map // Agent name -> time of the last refresh asked for
+}
+
+// agentShard holds the agents whose names hash to it
+type agentShard struct {
 	mu     sync.RWMutex
 	agents map[string]*ServerState // key: agent_name
-	alerts map[string]*Alert       // key: alert_id
 }
 
 // NewStateStore creates a new in-memory state store
 func NewStateStore() *StateStore {
-	return &StateStore{
-		agents: make(map[string]*ServerState),
-		alerts: make(map[string]*Alert),
+	s := &StateStore{
+		alerts:         make(map[string]*Alert),
+		silences:       make(map[string]*Silence),
+		customMetrics:  make(map[string]*CustomMetric),
+		metricsTimeout: DefaultMetricsTimeout,
+		shutdownGrace:  DefaultShutdownGracePeriod,
+	}
+	for i := range s.shards {
+		s.shards[i] = &agentShard{agents: make(map[string]*ServerState)}
 	}
+	return s
 }
 
-// UpdateAgent updates or creates agent state
-func (s *StateStore) UpdateAgent(state *ServerState) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// shardFor returns the shard holding an agent (FNV-1a of the name)
+func (s *StateStore) shardFor(agentName string) *agentShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(agentName); i++ {
+		h ^= uint32(agentName[i])
+		h *= 16777619
+	}
+	return s.shards[h%agentShards]
+}
+
+// SetMetricsTimeout sets how long an agent may go without pushing metrics
+// before heartbeats alone leave it degraded (0 disables the check). Call it
+// before the store is shared.
+func (s *StateStore) SetMetricsTimeout(timeout time.Duration) {
+	s.metricsTimeout = timeout
+}
+
+// SetShutdownGracePeriod sets how long an agent that announced a clean
+// shutdown may stay away before it is reported offline (negative = never).
+// Call it before the store is shared.
+func (s *StateStore) SetShutdownGracePeriod(grace time.Duration) {
+	s.shutdownGrace = grace
+}
+
+// SetHeartbeatTimeoutOverrides sets per-agent offline timeouts, which take
+// precedence over both the global timeout and the agent's reported interval.
+// The first matching override wins. Call it before the store is shared.
+func (s *StateStore) SetHeartbeatTimeoutOverrides(overrides []HeartbeatTimeoutOverride) {
+	s.timeoutOverrides = overrides
+}
+
+// SetHistory records every metrics update in h, and drops the history of
+// agents as they are removed. Call it before the store is shared.
+func (s *StateStore) SetHistory(h *History) {
+	s.history = h
+}
+
+// statusChanged records an agent's status change in the history, for uptime
+// reports
+func (s *StateStore) statusChanged(state *ServerState, previous string, at time.Time) {
+	if s.history != nil {
+		s.history.StatusChanged(state.AgentName, previous, state.Status, state.LastSeen, at)
+	}
+}
+
+// heartbeatTimeout returns how long an agent may go unseen before it is
+// offline: an override if one matches, otherwise the given default stretched
+// to cover a few of the agent's heartbeat intervals
+func (s *StateStore) heartbeatTimeout(state *ServerState, timeout time.Duration) time.Duration {
+	for _, o := range s.timeoutOverrides {
+		if matched, _ := filepath.Match(o.Agent, state.AgentName); matched {
+			return o.Timeout
+		}
+	}
+	if derived := missedHeartbeats * state.HeartbeatInterval; derived > timeout {
+		return derived
+	}
+	return timeout
+}
+
+// liveStatus works out the status of an agent that just reported in. It is
+// degraded, rather than online, while its metrics pushes have stopped or its
+// collectors are failing.
+func (s *StateStore) liveStatus(state *ServerState, now time.Time) (status, reason string) {
+	if state.Status == "terminating" {
+		return state.Status, state.StatusReason
+	}
+	if errs := state.SystemMetrics.CollectorErrors; len(errs) > 0 {
+		return "degraded", "collector errors: " + strings.Join(errs, "; ")
+	}
+	if s.metricsTimeout > 0 && state.LastMetricsAt != nil {
+		if since := now.Sub(*state.LastMetricsAt); since > s.metricsTimeout {
+			return "degraded", fmt.Sprintf("no metrics for %s", since.Truncate(time.Second))
+		}
+	}
+	return "online", ""
+}
+
+// Revision returns a counter that changes whenever agent or alert state
+// does. Readers that poll can skip copying the store when it is unchanged;
+// read the revision before the data so a concurrent change is never missed.
+func (s *StateStore) Revision() uint64 {
+	return s.revision.Load()
+}
+
+// changed records a mutation for Revision
+func (s *StateStore) changed() {
+	s.revision.Add(1)
+}
+
+// markSeen moves an agent's LastSeen forward to at, never back, so a request
+// that was delayed on its way in can't make a live agent look stale
+func markSeen(state *ServerState, at time.Time) {
+	if at.After(state.LastSeen) {
+		state.LastSeen = at
+	}
+}
 
-	existing, exists := s.agents[state.AgentName]
+// UpdateAgent updates or creates agent state. state.LastSeen should be the
+// time the push was received; it defaults to now.
+//
+// Pushes slightly older than the stored metrics, going by the agent's own
+// collection timestamps, are retries that arrived late: they count as a
+// sign of life but their metrics are dropped. UpdateAgent reports whether
+// they were kept.
+func (s *StateStore) UpdateAgent(state *ServerState) bool {
+	receivedAt := state.LastSeen
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+
+	shard := s.shardFor(state.AgentName)
+	shard.mu.Lock()
+
+	// Remember which remediations were already known for this agent
+	knownRemediations := make(map[string]time.Time)
+
+	var previousStatus string
+	existing, exists := shard.agents[state.AgentName]
+	collectedAt := state.SystemMetrics.Timestamp
+	if exists && !collectedAt.IsZero() && collectedAt.Before(existing.SystemMetrics.Timestamp) &&
+		existing.SystemMetrics.Timestamp.Sub(collectedAt) <= maxPushLateness {
+		markSeen(existing, receivedAt)
+		shard.mu.Unlock()
+		s.changed()
+		return false
+	}
 	if exists {
+		previousStatus = existing.Status
+		for _, c := range existing.Containers {
+			if c.Remediation != nil {
+				knownRemediations[c.ID] = c.Remediation.Timestamp
+			}
+		}
+
 		// Preserve previous container states for change detection
 		state.Containers = s.mergeContainerStates(existing.Containers, state.Containers)
 		
 		// Preserve active alerts from previous state
 		state.ActiveAlerts = existing.ActiveAlerts
+
+		// The version and heartbeat interval only arrive with heartbeats
+		if state.AgentVersion == "" {
+			state.AgentVersion = existing.AgentVersion
+		}
+		state.HeartbeatInterval = existing.HeartbeatInterval
+		state.LastHeartbeatAt = existing.LastHeartbeatAt
+		state.HeartbeatLateness = existing.HeartbeatLateness
+		state.ClockSkewSeconds = existing.ClockSkewSeconds
+
+		// Maintenance is set through the API, drop it once it has ended
+		if existing.InMaintenance(receivedAt) {
+			state.MaintenanceUntil = existing.MaintenanceUntil
+			state.MaintenanceReason = existing.MaintenanceReason
+		}
 	}
 
-	// Update status based on last seen
-	state.Status = "online"
-	state.LastSeen = time.Now()
+	// Update status based on last seen, an instance on its way out stays that way
+	if exists && existing.Status == "terminating" {
+		state.Status = existing.Status
+		state.StatusReason = existing.StatusReason
+	}
+	state.LastSeen = receivedAt
+	if exists {
+		markSeen(state, existing.LastSeen)
+	}
+	state.LastMetricsAt = &receivedAt
+	state.Status, state.StatusReason = s.liveStatus(state, state.LastSeen)
+
+	shard.agents[state.AgentName] = state
+	s.statusChanged(state, previousStatus, receivedAt)
+	var sample HistorySample
+	if s.history != nil {
+		sample = historySampleOf(state, receivedAt)
+	}
 
-	s.agents[state.AgentName] = state
+	// Collect new agent remediations to record on the alerts they relate to
+	var remediated []ContainerState
+	for _, c := range state.Containers {
+		if c.Remediation == nil {
+			continue
+		}
+		if known, ok := knownRemediations[c.ID]; !ok || !known.Equal(c.Remediation.Timestamp) {
+			remediated = append(remediated, c)
+		}
+	}
+	shard.mu.Unlock()
+	s.changed()
+	if s.history != nil {
+		s.history.Record(state.AgentName, sample)
+	}
+
+	if len(remediated) == 0 {
+		return true
+	}
+
+	// Alerts are locked before shards, so this waits until the shard is released
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range remediated {
+		s.annotateRemediation(state.AgentName, c.ID, c.Remediation)
+	}
+	s.changed()
+	return true
+}
+
+// annotateRemediation records a remediation action on the container's active
+// container_stopped alerts. Caller must hold the alerts write lock.
+func (s *StateStore) annotateRemediation(agentName, containerID string, action *metrics.RemediationAction) {
+	for _, alert := range s.alerts {
+		if alert.AgentName != agentName || alert.Status != "active" || alert.AlertType != "container_stopped" {
+			continue
+		}
+		if id, _ := alert.Details["container_id"].(string); id != containerID {
+			continue
+		}
+
+		// Replace rather than mutate the map, readers may hold a shallow copy
+		details := make(map[string]interface{}, len(alert.Details)+1)
+		for k, v := range alert.Details {
+			details[k] = v
+		}
+		details["remediation"] = convertRemediation(action).Details()
+		alert.Details = details
+
+		shard := s.shardFor(agentName)
+		shard.mu.Lock()
+		if state, exists := shard.agents[agentName]; exists {
+			for i := range state.ActiveAlerts {
+				if state.ActiveAlerts[i].ID == alert.ID {
+					state.ActiveAlerts[i].Details = details
+				}
+			}
+		}
+		shard.mu.Unlock()
+	}
 }
 
 // mergeContainerStates merges previous and current container states
 // to detect state changes
+//
+// Containers are matched by ID, then by identity: a recreated container
+// (docker compose up -d, or docker rm and run under the same name) gets a new
+// ID but keeps its state history and restart count.
 func (s *StateStore) mergeContainerStates(previous, current []ContainerState) []ContainerState {
 	prevMap := make(map[string]ContainerState)
 	for _, c := range previous {
 		prevMap[c.ID] = c
 	}
 
+	// Candidates for recreation. The replaced container may still be listed
+	// for a push or two, stopped and renamed, while compose swaps it out.
+	replaced := make(map[string]ContainerState, len(previous))
+	for _, c := range previous {
+		replaced[containerIdentity(c)] = c
+	}
+
 	merged := make([]ContainerState, 0, len(current))
 	for _, curr := range current {
-		if prev, exists := prevMap[curr.ID]; exists {
+		prev, exists := prevMap[curr.ID]
+		if exists {
+			curr.PreviousID = prev.PreviousID
+			curr.Recreations = prev.Recreations
+			curr.PriorRestarts = prev.PriorRestarts
+		} else if prev, exists = replaced[containerIdentity(curr)]; exists {
+			curr.PreviousID = prev.ID
+			curr.Recreations = prev.Recreations + 1
+			curr.PriorRestarts = prev.PriorRestarts + prev.RestartCount
+		}
+
+		if exists {
+			// Keep the last remediation until the agent reports a newer one
+			if curr.Remediation == nil {
+				curr.Remediation = prev.Remediation
+			}
+
 			// Check if state changed
 			if curr.State != prev.State {
 				curr.PreviousState = prev.State
@@ -70,12 +375,23 @@ func (s *StateStore) mergeContainerStates(previous, current []ContainerState) []
 	return merged
 }
 
+// containerIdentity is what a container keeps when it is recreated: its
+// compose service and replica number, or else its name. Compose renames the
+// old container while recreating, so the name alone isn't enough there.
+func containerIdentity(c ContainerState) string {
+	if c.ComposeService != "" {
+		return "compose:" + c.ComposeService + "#" + c.ComposeNumber
+	}
+	return "name:" + c.Name
+}
+
 // GetAgent retrieves agent state by name (returns a copy to prevent data races)
 func (s *StateStore) GetAgent(agentName string) (*ServerState, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	shard := s.shardFor(agentName)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
 
-	state, exists := s.agents[agentName]
+	state, exists := shard.agents[agentName]
 	if !exists {
 		return nil, false
 	}
@@ -86,52 +402,358 @@ func (s *StateStore) GetAgent(agentName string) (*ServerState, bool) {
 
 // GetAllAgents returns all agent states (returns copies to prevent data races)
 func (s *StateStore) GetAllAgents() []*ServerState {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	states := make([]*ServerState, 0, len(s.agents))
-	for _, state := range s.agents {
-		// Return deep copies to prevent data races
-		states = append(states, state.Clone())
+	states := make([]*ServerState, 0)
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		for _, state := range shard.agents {
+			// Return deep copies to prevent data races
+			states = append(states, state.Clone())
+		}
+		shard.mu.RUnlock()
 	}
 	return states
 }
 
+// AgentStatus is an agent's status and why it is in it
+type AgentStatus struct {
+	Status string
+	Reason string
+}
+
+// AgentStatuses returns the status of every agent by name, for callers that
+// don't need the cost of copying each agent's metrics
+func (s *StateStore) AgentStatuses() map[string]AgentStatus {
+	statuses := make(map[string]AgentStatus)
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		for name, state := range shard.agents {
+			statuses[name] = AgentStatus{Status: state.Status, Reason: state.StatusReason}
+		}
+		shard.mu.RUnlock()
+	}
+	return statuses
+}
+
+// ForEachAgent calls fn with a copy of each agent, one at a time, so large
+// responses can be streamed without copying the whole fleet up front.
+// Iteration stops at the first error, which is returned. No lock is held
+// while fn runs; agents added or removed meanwhile may or may not be seen.
+func (s *StateStore) ForEachAgent(fn func(*ServerState) error) error {
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		names := make([]string, 0, len(shard.agents))
+		for name := range shard.agents {
+			names = append(names, name)
+		}
+		shard.mu.RUnlock()
+
+		for _, name := range names {
+			shard.mu.RLock()
+			state, exists := shard.agents[name]
+			if exists {
+				state = state.Clone()
+			}
+			shard.mu.RUnlock()
+
+			if !exists {
+				continue
+			}
+			if err := fn(state); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // UpdateHeartbeat updates the last seen timestamp for an agent
 func (s *StateStore) UpdateHeartbeat(agentName string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	shard := s.shardFor(agentName)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	defer s.changed()
 
-	state, exists := s.agents[agentName]
+	state, exists := shard.agents[agentName]
 	if !exists {
 		// Create minimal state for heartbeat-only agents
 		state = &ServerState{
 			AgentName: agentName,
 			Status:    "online",
 		}
-		s.agents[agentName] = state
+		shard.agents[agentName] = state
 	}
 
-	state.LastSeen = time.Now()
-	state.Status = "online"
+	previous := state.Status
+	now := time.Now()
+	state.recordHeartbeat(now)
+	markSeen(state, now)
+	state.Status, state.StatusReason = s.liveStatus(state, state.LastSeen)
+	s.statusChanged(state, previous, state.LastSeen)
 }
 
-// CheckOfflineAgents marks agents as offline if they haven't sent heartbeat
-func (s *StateStore) CheckOfflineAgents(timeout time.Duration) []*ServerState {
+// SetAgentVersion records the version an agent reported
+func (s *StateStore) SetAgentVersion(agentName, version string) {
+	shard := s.shardFor(agentName)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if state, exists := shard.agents[agentName]; exists && state.AgentVersion != version {
+		state.AgentVersion = version
+		s.changed()
+	}
+}
+
+// SetHeartbeatInterval records the heartbeat interval an agent reported
+func (s *StateStore) SetHeartbeatInterval(agentName string, interval time.Duration) {
+	shard := s.shardFor(agentName)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if state, exists := shard.agents[agentName]; exists {
+		state.HeartbeatInterval = interval
+	}
+}
+
+// SetClockSkew records how far an agent's clock is ahead of the server's
+func (s *StateStore) SetClockSkew(agentName string, skew time.Duration) {
+	shard := s.shardFor(agentName)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	seconds := skew.Round(time.Millisecond).Seconds()
+	if state, exists := shard.agents[agentName]; exists && state.ClockSkewSeconds != seconds {
+		state.ClockSkewSeconds = seconds
+		s.changed()
+	}
+}
+
+// SetMaintenance puts an agent in maintenance until the given time, or ends
+// its maintenance early when until is zero. Returns false if the agent is
+// unknown.
+func (s *StateStore) SetMaintenance(agentName string, until time.Time, reason string) bool {
+	shard := s.shardFor(agentName)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	state, exists := shard.agents[agentName]
+	if !exists {
+		return false
+	}
+
+	if until.IsZero() {
+		state.MaintenanceUntil = nil
+		state.MaintenanceReason = ""
+	} else {
+		state.MaintenanceUntil = &until
+		state.MaintenanceReason = reason
+	}
+	s.changed()
+	return true
+}
+
+// MarkTerminating records that an agent's instance is being shut down on
+// purpose, so it is not reported as offline when it stops reporting
+func (s *StateStore) MarkTerminating(agentName, reason string) {
+	shard := s.shardFor(agentName)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	defer s.changed()
+
+	state, exists := shard.agents[agentName]
+	if !exists {
+		state = &ServerState{AgentName: agentName}
+		shard.agents[agentName] = state
+	}
+
+	previous := state.Status
+	markSeen(state, time.Now())
+	state.Status = "terminating"
+	state.StatusReason = reason
+	s.statusChanged(state, previous, state.LastSeen)
+}
+
+// MarkStopped records that an agent shut down cleanly. A stopped agent is
+// only reported offline if it stays away past the shutdown grace period; a
+// decommissioned one never is. Either goes back online when it reports in.
+func (s *StateStore) MarkStopped(agentName string, decommission bool) {
+	shard := s.shardFor(agentName)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	defer s.changed()
+
+	state, exists := shard.agents[agentName]
+	if !exists {
+		state = &ServerState{AgentName: agentName}
+		shard.agents[agentName] = state
+	}
+
+	previous := state.Status
+	markSeen(state, time.Now())
+	// The instance going away explains the shutdown better
+	if state.Status == "terminating" {
+		return
+	}
+	defer s.statusChanged(state, previous, state.LastSeen)
+	if decommission {
+		state.Status = "decommissioned"
+		state.StatusReason = "decommissioned by the agent on shutdown"
+	} else {
+		state.Status = "stopped"
+		state.StatusReason = "agent shut down"
+	}
+}
+
+// minRefreshInterval is how often an agent can be asked to refresh; each
+// refresh costs it a full collection and push
+const minRefreshInterval = 10 * time.Second
+
+// RequestRefresh asks an agent to collect and push right away, e.g. because
+// someone clicked refresh on the dashboard. The agent is told in the
+// response to its next push or heartbeat. Requests within
+// minRefreshInterval of the last one are absorbed by it. It reports false
+// for unknown agents.
+func (s *StateStore) RequestRefresh(agentName string) bool {
+	if _, exists := s.GetAgent(agentName); !exists {
+		return false
+	}
+
+	now := time.Now()
+	if last, ok := s.refreshed.Load(agentName); ok && now.Sub(last.(time.Time)) < minRefreshInterval {
+		return true
+	}
+	s.refreshed.Store(agentName, now)
+	s.refreshes.Store(agentName, struct{}{})
+	return true
+}
+
+// TakeRefresh reports whether a refresh was requested for an agent since
+// the last call, clearing the request
+func (s *StateStore) TakeRefresh(agentName string) bool {
+	_, requested := s.refreshes.LoadAndDelete(agentName)
+	return requested
+}
+
+// RemoveAgent deletes an agent and resolves its active alerts. Returns false
+// if the agent is unknown.
+func (s *StateStore) RemoveAgent(agentName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shard := s.shardFor(agentName)
+	shard.mu.Lock()
+	_, exists := shard.agents[agentName]
+	delete(shard.agents, agentName)
+	shard.mu.Unlock()
+	if !exists {
+		return false
+	}
+	s.refreshes.Delete(agentName)
+	s.refreshed.Delete(agentName)
+	defer s.changed()
+
+	if s.history != nil {
+		s.history.Forget(agentName)
+	}
+	s.resolveAgentAlerts(agentName, time.Now())
+	return true
+}
+
+// ExpireAgents removes agents that have been offline, terminated, stopped
+// or decommissioned for longer than retention, resolving their active alerts, and returns them.
+// Agents that come back later simply register again.
+func (s *StateStore) ExpireAgents(retention time.Duration) []*ServerState {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	expired := make([]*ServerState, 0)
+	now := time.Now()
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		for name, state := range shard.agents {
+			switch state.Status {
+			case "offline", "terminated", "stopped", "decommissioned":
+			default:
+				continue
+			}
+			if now.Sub(state.LastSeen) <= retention {
+				continue
+			}
+			delete(shard.agents, name)
+			expired = append(expired, state)
+		}
+		shard.mu.Unlock()
+	}
+
+	if len(expired) == 0 {
+		return expired
+	}
+	for _, state := range expired {
+		if s.history != nil {
+			s.history.Forget(state.AgentName)
+		}
+		s.resolveAgentAlerts(state.AgentName, now)
+	}
+	s.changed()
+	return expired
+}
+
+// resolveAgentAlerts resolves an agent's active alerts. Callers hold s.mu.
+func (s *StateStore) resolveAgentAlerts(agentName string, now time.Time) {
+	for _, alert := range s.alerts {
+		if alert.AgentName == agentName && alert.Status == "active" {
+			alert.ResolvedAt = &now
+			alert.Status = "resolved"
+		}
+	}
+}
+
+// CheckOfflineAgents marks agents as offline if they haven't sent heartbeat.
+// timeout applies to agents without an override or reported interval, see
+// heartbeatTimeout.
+func (s *StateStore) CheckOfflineAgents(timeout time.Duration) []*ServerState {
 	offline := make([]*ServerState, 0)
 	now := time.Now()
+	changed := false
 
-	for _, state := range s.agents {
-		if state.Status == "online" && now.Sub(state.LastSeen) > timeout {
-			state.Status = "offline"
-			// Return a deep copy to prevent data races
-			offline = append(offline, state.Clone())
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		for _, state := range shard.agents {
+			if now.Sub(state.LastSeen) <= s.heartbeatTimeout(state, timeout) {
+				continue
+			}
+
+			switch state.Status {
+			case "online", "degraded":
+				previous := state.Status
+				state.Status = "offline"
+				state.StatusReason = "" // Why it was degraded no longer applies
+				s.statusChanged(state, previous, now)
+				// Return a deep copy to prevent data races
+				offline = append(offline, state.Clone())
+				changed = true
+			case "terminating":
+				// Expected to go away, don't alert
+				state.Status = "terminated"
+				s.statusChanged(state, "terminating", now)
+				changed = true
+			case "stopped":
+				// Shut down on purpose, alert only if it doesn't come back
+				if s.shutdownGrace < 0 || now.Sub(state.LastSeen) <= s.shutdownGrace {
+					continue
+				}
+				state.Status = "offline"
+				state.StatusReason = fmt.Sprintf("not back within %s of shutting down", s.shutdownGrace)
+				s.statusChanged(state, "stopped", now)
+				offline = append(offline, state.Clone())
+				changed = true
+			}
 		}
+		shard.mu.Unlock()
 	}
 
+	if changed {
+		s.changed()
+	}
 	return offline
 }
 
@@ -141,34 +763,153 @@ func (s *StateStore) AddAlert(alert *Alert) {
 	defer s.mu.Unlock()
 
 	s.alerts[alert.ID] = alert
+	defer s.changed()
 
 	// Add to agent's active alerts
-	if state, exists := s.agents[alert.AgentName]; exists {
+	shard := s.shardFor(alert.AgentName)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if state, exists := shard.agents[alert.AgentName]; exists {
 		state.ActiveAlerts = append(state.ActiveAlerts, *alert)
 	}
 }
 
-// ResolveAlert marks an alert as resolved
-func (s *StateStore) ResolveAlert(alertID string) {
+// ResolveAlert marks an alert as resolved. Returns false if the alert is
+// unknown.
+func (s *StateStore) ResolveAlert(alertID string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if alert, exists := s.alerts[alertID]; exists {
+	alert, exists := s.alerts[alertID]
+	if !exists {
+		return false
+	}
+
+	now := time.Now()
+	alert.ResolvedAt = &now
+	alert.Status = "resolved"
+	defer s.changed()
+	if s.shared != nil {
+		delete(s.sharedAcks, ackKey(alert.AgentName, alert.AlertType))
+		s.shared.DeleteAck(alert.AgentName, alert.AlertType)
+	}
+
+	// Remove from agent's active alerts
+	shard := s.shardFor(alert.AgentName)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if state, exists := shard.agents[alert.AgentName]; exists {
+		activeAlerts := make([]Alert, 0)
+		for _, a := range state.ActiveAlerts {
+			if a.ID != alertID {
+				activeAlerts = append(activeAlerts, a)
+			}
+		}
+		state.ActiveAlerts = activeAlerts
+	}
+
+	return true
+}
+
+// AcknowledgeAlert marks an active alert as acknowledged, meaning someone
+// is looking at it. Returns false if the alert is unknown or resolved.
+func (s *StateStore) AcknowledgeAlert(alertID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alert, exists := s.alerts[alertID]
+	if !exists || alert.Status == "resolved" {
+		return false
+	}
+	alert.Status = "acknowledged"
+	defer s.changed()
+	if s.shared != nil {
+		s.shared.PutAck(ackOf(alert))
+	}
+
+	// Keep the agent's copy in sync
+	shard := s.shardFor(alert.AgentName)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if state, exists := shard.agents[alert.AgentName]; exists {
+		for i := range state.ActiveAlerts {
+			if state.ActiveAlerts[i].ID == alertID {
+				state.ActiveAlerts[i].Status = "acknowledged"
+			}
+		}
+	}
+
+	return true
+}
+
+// AssignAlert sets who owns an alert, or clears the owner if assignee is
+// empty. Returns false if the alert is unknown or already resolved.
+func (s *StateStore) AssignAlert(alertID, assignee string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alert, exists := s.alerts[alertID]
+	if !exists || alert.Status == "resolved" {
+		return false
+	}
+	var assignedAt *time.Time
+	if assignee != "" {
 		now := time.Now()
-		alert.ResolvedAt = &now
-		alert.Status = "resolved"
-
-		// Remove from agent's active alerts
-		if state, exists := s.agents[alert.AgentName]; exists {
-			activeAlerts := make([]Alert, 0)
-			for _, a := range state.ActiveAlerts {
-				if a.ID != alertID {
-					activeAlerts = append(activeAlerts, a)
-				}
+		assignedAt = &now
+	}
+	alert.Assignee = assignee
+	alert.AssignedAt = assignedAt
+	defer s.changed()
+	if s.shared != nil {
+		s.shared.PutAck(ackOf(alert))
+	}
+
+	// Keep the agent's copy in sync
+	shard := s.shardFor(alert.AgentName)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if state, exists := shard.agents[alert.AgentName]; exists {
+		for i := range state.ActiveAlerts {
+			if state.ActiveAlerts[i].ID == alertID {
+				state.ActiveAlerts[i].Assignee = assignee
+				state.ActiveAlerts[i].AssignedAt = assignedAt
 			}
-			state.ActiveAlerts = activeAlerts
 		}
 	}
+
+	return true
+}
+
+// AlertOwner returns who owns an open alert of this type for the agent, or
+// "" if no such alert is assigned
+func (s *StateStore) AlertOwner(agentName, alertType string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, alert := range s.alerts {
+		if alert.AgentName == agentName && alert.AlertType == alertType &&
+			alert.Status != "resolved" && alert.Assignee != "" {
+			return alert.Assignee
+		}
+	}
+	// Taken on another server, which raised the alert
+	return s.sharedAcks[ackKey(agentName, alertType)].Assignee
+}
+
+// GetAlertsByStatus returns alerts with the given status, or all alerts for
+// "all" (returns copies to prevent data races)
+func (s *StateStore) GetAlertsByStatus(status string) []*Alert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	alerts := make([]*Alert, 0)
+	for _, alert := range s.alerts {
+		if status == "all" || alert.Status == status {
+			alertCopy := *alert
+			alerts = append(alerts, &alertCopy)
+		}
+	}
+	return alerts
 }
 
 // GetActiveAlerts returns all active alerts (returns copies to prevent data races)
@@ -1,6 +1,9 @@
 package server
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -8,6 +11,12 @@ import (
 	"github.com/anurag/saviour/pkg/metrics"
 )
 
+// rawAgent returns the stored state itself, not a copy, for tests that need
+// to backdate it
+func rawAgent(store *StateStore, agentName string) *ServerState {
+	return store.shardFor(agentName).agents[agentName]
+}
+
 func TestNewStateStore(t *testing.T) {
 	store := NewStateStore()
 
@@ -15,16 +24,18 @@ func TestNewStateStore(t *testing.T) {
 		t.Fatal("NewStateStore returned nil")
 	}
 
-	if store.agents == nil {
-		t.Error("agents map not initialized")
+	for i, shard := range store.shards {
+		if shard == nil || shard.agents == nil {
+			t.Fatalf("agent shard %d not initialized", i)
+		}
 	}
 
 	if store.alerts == nil {
 		t.Error("alerts map not initialized")
 	}
 
-	if len(store.agents) != 0 {
-		t.Errorf("agents map should be empty, got %d entries", len(store.agents))
+	if agents := store.GetAllAgents(); len(agents) != 0 {
+		t.Errorf("store should have no agents, got %d", len(agents))
 	}
 
 	if len(store.alerts) != 0 {
@@ -144,6 +155,70 @@ func TestUpdateAgent_PreservesActiveAlerts(t *testing.T) {
 	}
 }
 
+func TestUpdateAgent_OutOfOrderPush(t *testing.T) {
+	store := NewStateStore()
+	collected := time.Now().Add(-time.Minute)
+
+	newer := &ServerState{AgentName: "test-agent", SystemMetrics: metrics.SystemMetrics{Timestamp: collected}}
+	newer.SystemMetrics.CPU.UsagePercent = 20
+	if !store.UpdateAgent(newer) {
+		t.Fatal("Expected the first push to be kept")
+	}
+
+	// A retry of an earlier push, arriving late
+	older := &ServerState{AgentName: "test-agent", SystemMetrics: metrics.SystemMetrics{Timestamp: collected.Add(-30 * time.Second)}}
+	older.SystemMetrics.CPU.UsagePercent = 90
+	if store.UpdateAgent(older) {
+		t.Error("Expected the late push to be dropped")
+	}
+
+	state, _ := store.GetAgent("test-agent")
+	if state.SystemMetrics.CPU.UsagePercent != 20 {
+		t.Errorf("Expected the newer metrics to be kept, got CPU %.0f%%", state.SystemMetrics.CPU.UsagePercent)
+	}
+}
+
+func TestUpdateAgent_ClockSteppedBack(t *testing.T) {
+	store := NewStateStore()
+	collected := time.Now()
+
+	// The agent's clock ran an hour fast, then NTP corrected it
+	fast := &ServerState{AgentName: "test-agent", SystemMetrics: metrics.SystemMetrics{Timestamp: collected.Add(time.Hour)}}
+	store.UpdateAgent(fast)
+
+	corrected := &ServerState{AgentName: "test-agent", SystemMetrics: metrics.SystemMetrics{Timestamp: collected}}
+	corrected.SystemMetrics.CPU.UsagePercent = 40
+	if !store.UpdateAgent(corrected) {
+		t.Fatal("Expected the push after the clock stepped back to be kept")
+	}
+
+	state, _ := store.GetAgent("test-agent")
+	if state.SystemMetrics.CPU.UsagePercent != 40 || !state.SystemMetrics.Timestamp.Equal(collected) {
+		t.Errorf("Expected the corrected metrics stored, got CPU %.0f%% at %v", state.SystemMetrics.CPU.UsagePercent, state.SystemMetrics.Timestamp)
+	}
+
+	// Late retries are still told apart from the new timeline
+	late := &ServerState{AgentName: "test-agent", SystemMetrics: metrics.SystemMetrics{Timestamp: collected.Add(-20 * time.Second)}}
+	if store.UpdateAgent(late) {
+		t.Error("Expected a late retry after the step to be dropped")
+	}
+}
+
+func TestUpdateAgent_LastSeenMonotonic(t *testing.T) {
+	store := NewStateStore()
+	now := time.Now()
+
+	store.UpdateAgent(&ServerState{AgentName: "test-agent", LastSeen: now})
+
+	// Received earlier but stored later, e.g. held up behind a slow request
+	store.UpdateAgent(&ServerState{AgentName: "test-agent", LastSeen: now.Add(-10 * time.Second)})
+
+	state, _ := store.GetAgent("test-agent")
+	if !state.LastSeen.Equal(now) {
+		t.Errorf("LastSeen went backwards: got %v, want %v", state.LastSeen, now)
+	}
+}
+
 func TestMergeContainerStates_NewContainer(t *testing.T) {
 	store := NewStateStore()
 
@@ -217,6 +292,65 @@ func TestMergeContainerStates_StateUnchanged(t *testing.T) {
 	}
 }
 
+func TestMergeContainerStates_RecreatedByName(t *testing.T) {
+	store := NewStateStore()
+
+	baseTime := time.Now().Add(-time.Hour)
+	previous := []ContainerState{
+		{ID: "old", Name: "web", State: "running", PreviousState: "restarting", LastStateChange: baseTime, RestartCount: 4},
+	}
+	current := []ContainerState{
+		{ID: "new", Name: "web", State: "running", RestartCount: 1},
+	}
+
+	merged := store.mergeContainerStates(previous, current)
+
+	c := merged[0]
+	if c.PreviousID != "old" || c.Recreations != 1 {
+		t.Errorf("Expected recreation of 'old' to be recorded, got PreviousID=%q Recreations=%d", c.PreviousID, c.Recreations)
+	}
+	if c.PriorRestarts != 4 {
+		t.Errorf("PriorRestarts = %d, want 4", c.PriorRestarts)
+	}
+	if c.PreviousState != "restarting" || !c.LastStateChange.Equal(baseTime) {
+		t.Error("State history should survive recreation")
+	}
+
+	// Carried along on later pushes
+	merged = store.mergeContainerStates(merged, []ContainerState{{ID: "new", Name: "web", State: "exited"}})
+	if merged[0].Recreations != 1 || merged[0].PriorRestarts != 4 || merged[0].PreviousState != "running" {
+		t.Errorf("Expected recreation history to be kept, got %+v", merged[0])
+	}
+}
+
+func TestMergeContainerStates_RecreatedByCompose(t *testing.T) {
+	store := NewStateStore()
+
+	// While recreating, compose renames the old container before starting
+	// the new one under the original name
+	previous := []ContainerState{
+		{ID: "old", Name: "shop-web-1", State: "running", ComposeService: "shop/web", ComposeNumber: "1", RestartCount: 2},
+		{ID: "db", Name: "shop-db-1", State: "running", ComposeService: "shop/db", ComposeNumber: "1"},
+	}
+	current := []ContainerState{
+		{ID: "old", Name: "0123abcd_shop-web-1", State: "exited", ComposeService: "shop/web", ComposeNumber: "1", RestartCount: 2},
+		{ID: "new", Name: "shop-web-1", State: "running", ComposeService: "shop/web", ComposeNumber: "1"},
+		{ID: "db2", Name: "shop-db-2", State: "running", ComposeService: "shop/db", ComposeNumber: "2"},
+	}
+
+	merged := store.mergeContainerStates(previous, current)
+
+	if merged[0].PreviousState != "running" || merged[0].Recreations != 0 {
+		t.Errorf("Old container should be tracked as itself, got %+v", merged[0])
+	}
+	if merged[1].PreviousID != "old" || merged[1].PriorRestarts != 2 {
+		t.Errorf("New container should inherit from 'old', got PreviousID=%q PriorRestarts=%d", merged[1].PreviousID, merged[1].PriorRestarts)
+	}
+	if merged[2].PreviousID != "" {
+		t.Errorf("A new replica is not a recreation, got PreviousID=%q", merged[2].PreviousID)
+	}
+}
+
 func TestGetAgent_NotFound(t *testing.T) {
 	store := NewStateStore()
 
@@ -304,6 +438,40 @@ func TestGetAllAgents_EmptyStore(t *testing.T) {
 	}
 }
 
+func TestForEachAgent(t *testing.T) {
+	store := NewStateStore()
+	for i := 0; i < 10; i++ {
+		store.UpdateAgent(&ServerState{AgentName: fmt.Sprintf("agent%d", i)})
+	}
+
+	seen := make(map[string]bool)
+	err := store.ForEachAgent(func(state *ServerState) error {
+		seen[state.AgentName] = true
+		// Copies must not write through to the store
+		state.Status = "modified"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(seen) != 10 {
+		t.Errorf("Expected 10 agents, got %d", len(seen))
+	}
+	if agent, _ := store.GetAgent("agent0"); agent.Status != "online" {
+		t.Errorf("Expected stored status to be unchanged, got %s", agent.Status)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = store.ForEachAgent(func(state *ServerState) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Expected iteration to stop at the first error, got %v after %d calls", err, calls)
+	}
+}
+
 func TestUpdateHeartbeat_NewAgent(t *testing.T) {
 	store := NewStateStore()
 
@@ -327,6 +495,25 @@ func TestUpdateHeartbeat_NewAgent(t *testing.T) {
 	}
 }
 
+func TestSetAgentVersion_SurvivesMetricsPush(t *testing.T) {
+	store := NewStateStore()
+
+	store.UpdateHeartbeat("test-agent")
+	store.SetAgentVersion("test-agent", "v1.4.0")
+	store.UpdateAgent(&ServerState{AgentName: "test-agent"})
+
+	state, _ := store.GetAgent("test-agent")
+	if state.AgentVersion != "v1.4.0" {
+		t.Errorf("AgentVersion = %v, want v1.4.0", state.AgentVersion)
+	}
+
+	// Unknown agents are not created
+	store.SetAgentVersion("unknown", "v1.4.0")
+	if _, exists := store.GetAgent("unknown"); exists {
+		t.Error("SetAgentVersion should not create agents")
+	}
+}
+
 func TestUpdateHeartbeat_ExistingAgent(t *testing.T) {
 	store := NewStateStore()
 
@@ -352,6 +539,76 @@ func TestUpdateHeartbeat_ExistingAgent(t *testing.T) {
 	}
 }
 
+func TestUpdateHeartbeat_MetricsStopped(t *testing.T) {
+	store := NewStateStore()
+	store.SetMetricsTimeout(time.Minute)
+
+	store.UpdateAgent(&ServerState{AgentName: "test-agent"})
+	stale := time.Now().Add(-2 * time.Minute)
+	rawAgent(store, "test-agent").LastMetricsAt = &stale
+
+	store.UpdateHeartbeat("test-agent")
+
+	state, _ := store.GetAgent("test-agent")
+	if state.Status != "degraded" {
+		t.Fatalf("Status = %v, want degraded", state.Status)
+	}
+	if !strings.HasPrefix(state.StatusReason, "no metrics for 2m") {
+		t.Errorf("StatusReason = %q, want it to say how long metrics stopped", state.StatusReason)
+	}
+
+	// The next push brings it back
+	store.UpdateAgent(&ServerState{AgentName: "test-agent"})
+	state, _ = store.GetAgent("test-agent")
+	if state.Status != "online" || state.StatusReason != "" {
+		t.Errorf("Status = %v (%q), want online after a push", state.Status, state.StatusReason)
+	}
+}
+
+func TestUpdateHeartbeat_HeartbeatOnlyAgent(t *testing.T) {
+	store := NewStateStore()
+	store.SetMetricsTimeout(time.Nanosecond)
+
+	// Never pushed metrics, so there is nothing to have stopped
+	store.UpdateHeartbeat("test-agent")
+	time.Sleep(time.Millisecond)
+	store.UpdateHeartbeat("test-agent")
+
+	state, _ := store.GetAgent("test-agent")
+	if state.Status != "online" {
+		t.Errorf("Status = %v, want online", state.Status)
+	}
+}
+
+func TestUpdateAgent_CollectorErrors(t *testing.T) {
+	store := NewStateStore()
+
+	store.UpdateAgent(&ServerState{
+		AgentName: "test-agent",
+		SystemMetrics: metrics.SystemMetrics{
+			CollectorErrors: []string{"docker: permission denied"},
+		},
+	})
+
+	state, _ := store.GetAgent("test-agent")
+	if state.Status != "degraded" {
+		t.Fatalf("Status = %v, want degraded", state.Status)
+	}
+	if state.StatusReason != "collector errors: docker: permission denied" {
+		t.Errorf("StatusReason = %q", state.StatusReason)
+	}
+
+	// Degraded agents still go offline when heartbeats stop
+	rawAgent(store, "test-agent").LastSeen = time.Now().Add(-5 * time.Minute)
+	offline := store.CheckOfflineAgents(2 * time.Minute)
+	if len(offline) != 1 {
+		t.Fatalf("Expected 1 offline agent, got %d", len(offline))
+	}
+	if offline[0].Status != "offline" || offline[0].StatusReason != "" {
+		t.Errorf("Status = %v (%q), want offline without the degraded reason", offline[0].Status, offline[0].StatusReason)
+	}
+}
+
 func TestCheckOfflineAgents(t *testing.T) {
 	store := NewStateStore()
 
@@ -360,16 +617,16 @@ func TestCheckOfflineAgents(t *testing.T) {
 
 	// Agent 1: Recent heartbeat (online)
 	store.UpdateAgent(&ServerState{AgentName: "agent1"})
-	store.agents["agent1"].LastSeen = now
+	rawAgent(store, "agent1").LastSeen = now
 
 	// Agent 2: Old heartbeat (should be offline)
 	store.UpdateAgent(&ServerState{AgentName: "agent2"})
-	store.agents["agent2"].LastSeen = now.Add(-5 * time.Minute)
+	rawAgent(store, "agent2").LastSeen = now.Add(-5 * time.Minute)
 
 	// Agent 3: Already offline (shouldn't be returned again)
 	store.UpdateAgent(&ServerState{AgentName: "agent3"})
-	store.agents["agent3"].LastSeen = now.Add(-10 * time.Minute)
-	store.agents["agent3"].Status = "offline"
+	rawAgent(store, "agent3").LastSeen = now.Add(-10 * time.Minute)
+	rawAgent(store, "agent3").Status = "offline"
 
 	// Check with 2 minute timeout
 	offline := store.CheckOfflineAgents(2 * time.Minute)
@@ -395,6 +652,281 @@ func TestCheckOfflineAgents(t *testing.T) {
 	}
 }
 
+func TestCheckOfflineAgents_ReportedInterval(t *testing.T) {
+	store := NewStateStore()
+
+	// Heartbeats every 10 minutes, so 5 minutes of silence is expected
+	store.UpdateHeartbeat("batch-host")
+	store.SetHeartbeatInterval("batch-host", 10*time.Minute)
+	store.UpdateAgent(&ServerState{AgentName: "batch-host"})
+	rawAgent(store, "batch-host").LastSeen = time.Now().Add(-5 * time.Minute)
+
+	if offline := store.CheckOfflineAgents(2 * time.Minute); len(offline) != 0 {
+		t.Fatalf("Expected no offline agents within 3 intervals, got %d", len(offline))
+	}
+
+	rawAgent(store, "batch-host").LastSeen = time.Now().Add(-31 * time.Minute)
+	if offline := store.CheckOfflineAgents(2 * time.Minute); len(offline) != 1 {
+		t.Errorf("Expected the agent offline after 3 missed intervals, got %d offline", len(offline))
+	}
+}
+
+func TestCheckOfflineAgents_Overrides(t *testing.T) {
+	store := NewStateStore()
+	store.SetHeartbeatTimeoutOverrides([]HeartbeatTimeoutOverride{
+		{Agent: "batch-*", Timeout: time.Hour},
+		{Agent: "edge-1", Timeout: 30 * time.Second},
+	})
+
+	for _, name := range []string{"batch-1", "edge-1", "web-1"} {
+		store.UpdateAgent(&ServerState{AgentName: name})
+		rawAgent(store, name).LastSeen = time.Now().Add(-time.Minute)
+	}
+	// Overrides win over a reported interval
+	store.SetHeartbeatInterval("edge-1", time.Hour)
+
+	offline := store.CheckOfflineAgents(2 * time.Minute)
+
+	if len(offline) != 1 || offline[0].AgentName != "edge-1" {
+		t.Fatalf("Expected only edge-1 offline, got %v", offline)
+	}
+}
+
+func TestCheckOfflineAgents_TerminatingAgent(t *testing.T) {
+	store := NewStateStore()
+
+	store.UpdateAgent(&ServerState{AgentName: "spot-1"})
+	store.MarkTerminating("spot-1", "spot instance terminate")
+
+	// Later heartbeats and pushes must not clear the terminating flag
+	store.UpdateHeartbeat("spot-1")
+	store.UpdateAgent(&ServerState{AgentName: "spot-1"})
+
+	agent, _ := store.GetAgent("spot-1")
+	if agent.Status != "terminating" {
+		t.Fatalf("Expected status 'terminating', got '%s'", agent.Status)
+	}
+	if agent.StatusReason != "spot instance terminate" {
+		t.Errorf("Expected status reason to be kept, got '%s'", agent.StatusReason)
+	}
+
+	rawAgent(store, "spot-1").LastSeen = time.Now().Add(-5 * time.Minute)
+
+	offline := store.CheckOfflineAgents(2 * time.Minute)
+	if len(offline) != 0 {
+		t.Errorf("Expected no offline agents, got %d", len(offline))
+	}
+
+	agent, _ = store.GetAgent("spot-1")
+	if agent.Status != "terminated" {
+		t.Errorf("Expected status 'terminated', got '%s'", agent.Status)
+	}
+}
+
+func TestCheckOfflineAgents_TerminatedRecordedInHistory(t *testing.T) {
+	store := NewStateStore()
+	store.UpdateAgent(&ServerState{AgentName: "spot-1"})
+	store.MarkTerminating("spot-1", "spot instance terminate")
+
+	// Only the transition to terminated can reach this history
+	history := NewHistory(time.Hour, 0)
+	store.SetHistory(history)
+
+	rawAgent(store, "spot-1").LastSeen = time.Now().Add(-5 * time.Minute)
+	store.CheckOfflineAgents(2 * time.Minute)
+
+	uptime, ok := history.Uptime("spot-1", time.Now().Add(-time.Hour), time.Now())
+	if !ok {
+		t.Fatal("Expected the terminated transition to be recorded")
+	}
+	if len(uptime.Incidents) != 0 || uptime.AvailabilityPercent != 100 {
+		t.Errorf("Expected termination not to count as downtime, got %+v", uptime)
+	}
+}
+
+func TestCheckOfflineAgents_StoppedAgent(t *testing.T) {
+	store := NewStateStore()
+	store.SetShutdownGracePeriod(30 * time.Minute)
+
+	store.UpdateAgent(&ServerState{AgentName: "web-1"})
+	store.MarkStopped("web-1", false)
+
+	agent, _ := store.GetAgent("web-1")
+	if agent.Status != "stopped" {
+		t.Fatalf("Expected status 'stopped', got '%s'", agent.Status)
+	}
+
+	// Within the grace period
+	rawAgent(store, "web-1").LastSeen = time.Now().Add(-10 * time.Minute)
+	if offline := store.CheckOfflineAgents(2 * time.Minute); len(offline) != 0 {
+		t.Fatalf("Expected no offline agents within the grace period, got %d", len(offline))
+	}
+
+	// Never came back
+	rawAgent(store, "web-1").LastSeen = time.Now().Add(-time.Hour)
+	offline := store.CheckOfflineAgents(2 * time.Minute)
+	if len(offline) != 1 || offline[0].AgentName != "web-1" {
+		t.Fatalf("Expected web-1 offline after the grace period, got %v", offline)
+	}
+	if offline[0].StatusReason == "" {
+		t.Error("Expected a status reason explaining the offline agent")
+	}
+}
+
+func TestCheckOfflineAgents_StoppedAgentReturns(t *testing.T) {
+	store := NewStateStore()
+
+	store.UpdateAgent(&ServerState{AgentName: "web-1"})
+	store.MarkStopped("web-1", false)
+	store.UpdateHeartbeat("web-1")
+
+	agent, _ := store.GetAgent("web-1")
+	if agent.Status != "online" {
+		t.Errorf("Expected status 'online' after restarting, got '%s'", agent.Status)
+	}
+}
+
+func TestCheckOfflineAgents_DecommissionedAgent(t *testing.T) {
+	store := NewStateStore()
+
+	store.UpdateAgent(&ServerState{AgentName: "old-1"})
+	store.MarkStopped("old-1", true)
+
+	rawAgent(store, "old-1").LastSeen = time.Now().Add(-48 * time.Hour)
+	if offline := store.CheckOfflineAgents(2 * time.Minute); len(offline) != 0 {
+		t.Errorf("Expected decommissioned agents never to go offline, got %d", len(offline))
+	}
+
+	agent, _ := store.GetAgent("old-1")
+	if agent.Status != "decommissioned" {
+		t.Errorf("Expected status 'decommissioned', got '%s'", agent.Status)
+	}
+
+	if expired := store.ExpireAgents(24 * time.Hour); len(expired) != 1 {
+		t.Errorf("Expected the decommissioned agent to expire, got %d", len(expired))
+	}
+}
+
+func TestMarkStopped_KeepsTerminating(t *testing.T) {
+	store := NewStateStore()
+
+	store.MarkTerminating("spot-1", "spot instance terminate")
+	store.MarkStopped("spot-1", false)
+
+	agent, _ := store.GetAgent("spot-1")
+	if agent.Status != "terminating" {
+		t.Errorf("Expected status 'terminating', got '%s'", agent.Status)
+	}
+}
+
+func TestRemoveAgent(t *testing.T) {
+	store := NewStateStore()
+
+	store.UpdateAgent(&ServerState{AgentName: "agent1"})
+	store.AddAlert(&Alert{ID: "alert-1", AgentName: "agent1", Status: "active"})
+
+	if !store.RemoveAgent("agent1") {
+		t.Fatal("Expected agent1 to be removed")
+	}
+
+	if _, exists := store.GetAgent("agent1"); exists {
+		t.Error("Expected agent1 to be gone")
+	}
+
+	alert, _ := store.GetAlert("alert-1")
+	if alert.Status != "resolved" || alert.ResolvedAt == nil {
+		t.Errorf("Expected alert to be resolved, got status '%s'", alert.Status)
+	}
+
+	if store.RemoveAgent("agent1") {
+		t.Error("Expected removing an unknown agent to return false")
+	}
+}
+
+func TestRequestRefresh(t *testing.T) {
+	store := NewStateStore()
+	store.UpdateAgent(&ServerState{AgentName: "agent1"})
+
+	if store.RequestRefresh("unknown") {
+		t.Error("Expected no refresh for an unknown agent")
+	}
+	if store.TakeRefresh("agent1") {
+		t.Error("Expected no refresh before one is requested")
+	}
+	if !store.RequestRefresh("agent1") {
+		t.Fatal("Expected the refresh requested")
+	}
+	if !store.TakeRefresh("agent1") {
+		t.Error("Expected the requested refresh")
+	}
+	if store.TakeRefresh("agent1") {
+		t.Error("Expected the refresh taken only once")
+	}
+
+	// Repeated requests are absorbed by the last one for a while
+	if !store.RequestRefresh("agent1") {
+		t.Fatal("Expected a repeated request to be accepted")
+	}
+	if store.TakeRefresh("agent1") {
+		t.Error("Expected no new refresh within the minimum interval")
+	}
+
+	store.refreshed.Store("agent1", time.Now().Add(-minRefreshInterval))
+	store.RequestRefresh("agent1")
+	if !store.TakeRefresh("agent1") {
+		t.Error("Expected a refresh once the minimum interval passed")
+	}
+}
+
+func TestExpireAgents(t *testing.T) {
+	store := NewStateStore()
+	old := time.Now().Add(-48 * time.Hour)
+
+	// Long gone, offline and terminated
+	store.UpdateAgent(&ServerState{AgentName: "gone"})
+	rawAgent(store, "gone").Status = "offline"
+	rawAgent(store, "gone").LastSeen = old
+	store.AddAlert(&Alert{ID: "alert-1", AgentName: "gone", Status: "active"})
+
+	store.UpdateAgent(&ServerState{AgentName: "spot"})
+	rawAgent(store, "spot").Status = "terminated"
+	rawAgent(store, "spot").LastSeen = old
+
+	// Offline, but within the retention
+	store.UpdateAgent(&ServerState{AgentName: "recent"})
+	rawAgent(store, "recent").Status = "offline"
+	rawAgent(store, "recent").LastSeen = time.Now().Add(-time.Hour)
+
+	// Old LastSeen but still online, i.e. not yet checked; never removed here
+	store.UpdateAgent(&ServerState{AgentName: "online"})
+	rawAgent(store, "online").LastSeen = old
+
+	expired := store.ExpireAgents(24 * time.Hour)
+
+	if len(expired) != 2 {
+		t.Fatalf("Expected 2 expired agents, got %d", len(expired))
+	}
+	for _, name := range []string{"gone", "spot"} {
+		if _, exists := store.GetAgent(name); exists {
+			t.Errorf("Expected %s to be removed", name)
+		}
+	}
+	for _, name := range []string{"recent", "online"} {
+		if _, exists := store.GetAgent(name); !exists {
+			t.Errorf("Expected %s to be kept", name)
+		}
+	}
+
+	alert, _ := store.GetAlert("alert-1")
+	if alert.Status != "resolved" {
+		t.Errorf("Expected the expired agent's alert to be resolved, got status '%s'", alert.Status)
+	}
+
+	if expired := store.ExpireAgents(24 * time.Hour); len(expired) != 0 {
+		t.Errorf("Expected nothing left to expire, got %d", len(expired))
+	}
+}
+
 func TestAddAlert(t *testing.T) {
 	store := NewStateStore()
 
@@ -453,6 +985,59 @@ func TestAddAlert_AgentNotFound(t *testing.T) {
 	}
 }
 
+func TestUpdateAgent_RecordsRemediationOnAlert(t *testing.T) {
+	store := NewStateStore()
+
+	store.UpdateAgent(&ServerState{
+		AgentName:  "test-agent",
+		Containers: []ContainerState{{ID: "c1", Name: "api", State: "exited"}},
+	})
+
+	store.AddAlert(&Alert{
+		ID:        "alert1",
+		AgentName: "test-agent",
+		AlertType: "container_stopped",
+		Status:    "active",
+		Details:   map[string]interface{}{"container_id": "c1"},
+	})
+
+	action := &metrics.RemediationAction{
+		Action:      "restart",
+		Attempt:     1,
+		MaxAttempts: 3,
+		Success:     true,
+		Timestamp:   time.Now(),
+	}
+	store.UpdateAgent(&ServerState{
+		AgentName:  "test-agent",
+		Containers: []ContainerState{{ID: "c1", Name: "api", State: "running", Remediation: action}},
+	})
+
+	alert, _ := store.GetAlert("alert1")
+	remediation, ok := alert.Details["remediation"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected remediation details on alert, got %v", alert.Details)
+	}
+	if remediation["action"] != "restart" || remediation["success"] != true {
+		t.Errorf("Unexpected remediation details: %v", remediation)
+	}
+
+	state, _ := store.GetAgent("test-agent")
+	if _, ok := state.ActiveAlerts[0].Details["remediation"]; !ok {
+		t.Error("Expected remediation details on agent's active alert")
+	}
+
+	// Remediation is kept when the next push doesn't carry one
+	store.UpdateAgent(&ServerState{
+		AgentName:  "test-agent",
+		Containers: []ContainerState{{ID: "c1", Name: "api", State: "running"}},
+	})
+	state, _ = store.GetAgent("test-agent")
+	if state.Containers[0].Remediation == nil {
+		t.Error("Expected last remediation to be preserved")
+	}
+}
+
 func TestResolveAlert(t *testing.T) {
 	store := NewStateStore()
 
@@ -490,8 +1075,92 @@ func TestResolveAlert(t *testing.T) {
 func TestResolveAlert_NotFound(t *testing.T) {
 	store := NewStateStore()
 
-	// Should not panic
-	store.ResolveAlert("nonexistent")
+	if store.ResolveAlert("nonexistent") {
+		t.Error("Expected false for unknown alert")
+	}
+}
+
+func TestAcknowledgeAlert(t *testing.T) {
+	store := NewStateStore()
+
+	store.UpdateAgent(&ServerState{AgentName: "test-agent"})
+	store.AddAlert(&Alert{ID: "alert1", AgentName: "test-agent", Status: "active"})
+
+	if !store.AcknowledgeAlert("alert1") {
+		t.Fatal("Expected alert to be acknowledged")
+	}
+
+	retrieved, _ := store.GetAlert("alert1")
+	if retrieved.Status != "acknowledged" {
+		t.Errorf("Alert status = %v, want acknowledged", retrieved.Status)
+	}
+
+	// Still listed on the agent, with the new status
+	state, _ := store.GetAgent("test-agent")
+	if len(state.ActiveAlerts) != 1 || state.ActiveAlerts[0].Status != "acknowledged" {
+		t.Errorf("Expected acknowledged alert on agent, got %+v", state.ActiveAlerts)
+	}
+
+	if got := store.GetAlertsByStatus("acknowledged"); len(got) != 1 {
+		t.Errorf("Expected 1 acknowledged alert, got %d", len(got))
+	}
+	if got := store.GetActiveAlerts(); len(got) != 0 {
+		t.Errorf("Expected 0 active alerts, got %d", len(got))
+	}
+
+	// Resolved alerts can't be acknowledged
+	store.ResolveAlert("alert1")
+	if store.AcknowledgeAlert("alert1") {
+		t.Error("Expected resolved alert not to be acknowledged")
+	}
+	if store.AcknowledgeAlert("nonexistent") {
+		t.Error("Expected false for unknown alert")
+	}
+}
+
+func TestAssignAlert(t *testing.T) {
+	store := NewStateStore()
+
+	store.UpdateAgent(&ServerState{AgentName: "test-agent"})
+	store.AddAlert(&Alert{ID: "alert1", AgentName: "test-agent", AlertType: "agent_offline", Status: "active"})
+
+	if owner := store.AlertOwner("test-agent", "agent_offline"); owner != "" {
+		t.Errorf("Expected no owner before assignment, got %q", owner)
+	}
+	if !store.AssignAlert("alert1", "alice") {
+		t.Fatal("Expected alert to be assigned")
+	}
+
+	retrieved, _ := store.GetAlert("alert1")
+	if retrieved.Assignee != "alice" || retrieved.AssignedAt == nil {
+		t.Errorf("Expected alert assigned to alice with a time, got %q at %v", retrieved.Assignee, retrieved.AssignedAt)
+	}
+	state, _ := store.GetAgent("test-agent")
+	if len(state.ActiveAlerts) != 1 || state.ActiveAlerts[0].Assignee != "alice" {
+		t.Errorf("Expected assigned alert on agent, got %+v", state.ActiveAlerts)
+	}
+	if owner := store.AlertOwner("test-agent", "agent_offline"); owner != "alice" {
+		t.Errorf("Expected owner alice, got %q", owner)
+	}
+
+	// Unassigning clears the owner
+	store.AssignAlert("alert1", "")
+	if owner := store.AlertOwner("test-agent", "agent_offline"); owner != "" {
+		t.Errorf("Expected no owner after unassigning, got %q", owner)
+	}
+
+	// Resolved alerts can't be assigned and don't count as owned
+	store.AssignAlert("alert1", "bob")
+	store.ResolveAlert("alert1")
+	if store.AssignAlert("alert1", "alice") {
+		t.Error("Expected resolved alert not to be assigned")
+	}
+	if owner := store.AlertOwner("test-agent", "agent_offline"); owner != "" {
+		t.Errorf("Expected resolved alerts not to count as owned, got %q", owner)
+	}
+	if store.AssignAlert("nonexistent", "alice") {
+		t.Error("Expected false for unknown alert")
+	}
 }
 
 func TestGetActiveAlerts(t *testing.T) {
@@ -555,6 +1224,75 @@ func TestGetAlert_NotFound(t *testing.T) {
 	}
 }
 
+func TestRevision(t *testing.T) {
+	store := NewStateStore()
+	last := store.Revision()
+
+	expectChange := func(what string, changed bool) {
+		t.Helper()
+		revision := store.Revision()
+		if changed && revision == last {
+			t.Errorf("Expected revision to change after %s", what)
+		}
+		if !changed && revision != last {
+			t.Errorf("Expected revision unchanged after %s, got %d -> %d", what, last, revision)
+		}
+		last = revision
+	}
+
+	store.UpdateAgent(&ServerState{AgentName: "agent1"})
+	expectChange("UpdateAgent", true)
+
+	store.GetAllAgents()
+	store.GetAgent("agent1")
+	store.GetActiveAlerts()
+	expectChange("reads", false)
+
+	store.UpdateHeartbeat("agent1")
+	expectChange("UpdateHeartbeat", true)
+
+	store.SetAgentVersion("agent1", "v1.2.0")
+	expectChange("SetAgentVersion", true)
+	store.SetAgentVersion("agent1", "v1.2.0")
+	expectChange("SetAgentVersion with the same version", false)
+
+	store.AddAlert(&Alert{ID: "alert1", AgentName: "agent1", Status: "active"})
+	expectChange("AddAlert", true)
+
+	store.AcknowledgeAlert("alert1")
+	expectChange("AcknowledgeAlert", true)
+
+	store.ResolveAlert("alert1")
+	expectChange("ResolveAlert", true)
+
+	store.CheckOfflineAgents(time.Hour)
+	expectChange("CheckOfflineAgents with nothing offline", false)
+
+	store.RemoveAgent("agent1")
+	expectChange("RemoveAgent", true)
+
+	store.RemoveAgent("agent1")
+	expectChange("RemoveAgent of an unknown agent", false)
+}
+
+func TestShardFor_Spreads(t *testing.T) {
+	store := NewStateStore()
+
+	used := make(map[*agentShard]bool)
+	for i := 0; i < 1000; i++ {
+		name := fmt.Sprintf("agent-%d", i)
+		shard := store.shardFor(name)
+		if shard != store.shardFor(name) {
+			t.Fatalf("Expected %s to always map to the same shard", name)
+		}
+		used[shard] = true
+	}
+
+	if len(used) != agentShards {
+		t.Errorf("Expected 1000 agents to use all %d shards, used %d", agentShards, len(used))
+	}
+}
+
 // TestConcurrency verifies thread-safety of StateStore
 func TestConcurrency(t *testing.T) {
 	store := NewStateStore()
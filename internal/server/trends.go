@@ -0,0 +1,137 @@
+package server
+
+import (
+	"math"
+	"time"
+)
+
+// TrendWindows are the periods trends are fitted over, by name
+var TrendWindows = []struct {
+	Name   string
+	Period time.Duration
+}{
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+// TrendHorizon is how far ahead trends are projected
+const TrendHorizon = 30 * 24 * time.Hour
+
+// minTrendSpan is the least history a trend is fitted on; anything shorter
+// mostly measures daily swings
+const minTrendSpan = 6 * time.Hour
+
+// Trend is the growth of one metric, fitted by least squares over hourly
+// averages
+type Trend struct {
+	Current      float64 `json:"current"`        // Latest hourly average
+	GrowthPerDay float64 `json:"growth_per_day"` // Percentage points or containers
+	Projected    float64 `json:"projected"`      // Where the growth leads by TrendHorizon
+
+	// Days until a percentage reaches 100, if it is growing
+	DaysUntilFull *float64 `json:"days_until_full,omitempty"`
+}
+
+// TrendWindow holds the trends fitted over one of TrendWindows. Trends are
+// nil while the agent has less than minTrendSpan of history in it.
+type TrendWindow struct {
+	Window     string    `json:"window"`
+	From       time.Time `json:"from"` // Oldest history used
+	To         time.Time `json:"to"`
+	Hours      int       `json:"hours"` // Hourly averages fitted
+	Disk       *Trend    `json:"disk"`  // Fullest disk
+	Memory     *Trend    `json:"memory"`
+	Containers *Trend    `json:"containers"`
+}
+
+// Trends are an agent's growth rates and projections, for capacity planning
+type Trends struct {
+	AgentName   string        `json:"agent_name"`
+	HorizonDays int           `json:"horizon_days"`
+	Windows     []TrendWindow `json:"windows"`
+}
+
+// Trends fits the agent's disk, memory and container count growth over
+// each of TrendWindows up to now. Returns false for agents without history.
+func (h *History) Trends(agentName string, now time.Time) (Trends, bool) {
+	h.mu.RLock()
+	_, exists := h.agents[agentName]
+	h.mu.RUnlock()
+	if !exists {
+		return Trends{}, false
+	}
+
+	trends := Trends{AgentName: agentName, HorizonDays: int(TrendHorizon / (24 * time.Hour))}
+	for _, window := range TrendWindows {
+		hours := hourlyAverages(h.Samples(agentName, now.Add(-window.Period), now))
+		tw := TrendWindow{Window: window.Name, To: now, Hours: len(hours)}
+		if len(hours) > 0 {
+			tw.From = hours[0].Time
+		}
+		if len(hours) >= 2 && hours[len(hours)-1].Time.Sub(hours[0].Time) >= minTrendSpan {
+			tw.Disk = fitTrend(hours, "disk_percent", true)
+			tw.Memory = fitTrend(hours, "memory_percent", true)
+			tw.Containers = fitTrend(hours, "containers", false)
+		}
+		trends.Windows = append(trends.Windows, tw)
+	}
+	return trends, true
+}
+
+// hourlyAverages averages samples by hour, so minute samples of the last day
+// don't outweigh the hourly history before them
+func hourlyAverages(samples []HistorySample) []HistorySample {
+	var hours []HistorySample
+	var sum HistorySample
+	count := 0
+	for _, s := range samples {
+		hour := s.Time.Truncate(time.Hour)
+		if count > 0 && !hour.Equal(sum.Time) {
+			hours = append(hours, sum.average(count))
+			count = 0
+		}
+		if count == 0 {
+			sum = HistorySample{Time: hour}
+		}
+		sum.add(s)
+		count++
+	}
+	if count > 0 {
+		hours = append(hours, sum.average(count))
+	}
+	return hours
+}
+
+// fitTrend fits a line through one metric of hourly averages. Percentages
+// growing towards 100 get the days until they get there.
+func fitTrend(hours []HistorySample, metric string, percent bool) *Trend {
+	origin := hours[0].Time
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range hours {
+		x := s.Time.Sub(origin).Hours() / 24
+		y, _ := s.Value(metric)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(hours))
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+
+	current, _ := hours[len(hours)-1].Value(metric)
+	trend := &Trend{
+		Current:      current,
+		GrowthPerDay: slope,
+		Projected:    current + slope*TrendHorizon.Hours()/24,
+	}
+	if percent {
+		trend.Projected = math.Min(100, math.Max(0, trend.Projected))
+		if slope > 0 && current < 100 {
+			days := (100 - current) / slope
+			trend.DaysUntilFull = &days
+		}
+	} else {
+		trend.Projected = math.Max(0, trend.Projected)
+	}
+	return trend
+}
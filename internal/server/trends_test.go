@@ -0,0 +1,87 @@
+package server
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistory_Trends(t *testing.T) {
+	history := NewHistory(time.Hour, 30*24*time.Hour)
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	// Disk grows 1 point a day from 50%, memory is flat, a container is
+	// added every other day
+	start := now.Add(-6 * 24 * time.Hour)
+	for at := start; !at.After(now); at = at.Add(time.Hour) {
+		days := at.Sub(start).Hours() / 24
+		history.Record("web-1", HistorySample{
+			Time:          at,
+			DiskPercent:   50 + days,
+			MemoryPercent: 40,
+			Containers:    math.Floor(days / 2),
+		})
+	}
+
+	trends, ok := history.Trends("web-1", now)
+	if !ok {
+		t.Fatal("Expected trends for web-1")
+	}
+	if len(trends.Windows) != 2 || trends.Windows[0].Window != "7d" || trends.HorizonDays != 30 {
+		t.Fatalf("Expected 7d and 30d windows over 30 days, got %+v", trends)
+	}
+
+	week := trends.Windows[0]
+	if week.Disk == nil {
+		t.Fatal("Expected a disk trend")
+	}
+	if math.Abs(week.Disk.GrowthPerDay-1) > 0.01 {
+		t.Errorf("Expected disk growth of 1%%/day, got %v", week.Disk.GrowthPerDay)
+	}
+	if math.Abs(week.Disk.Current-56) > 0.01 || math.Abs(week.Disk.Projected-86) > 0.01 {
+		t.Errorf("Expected disk at 56%% heading for 86%%, got %+v", week.Disk)
+	}
+	if week.Disk.DaysUntilFull == nil || math.Abs(*week.Disk.DaysUntilFull-44) > 0.1 {
+		t.Errorf("Expected the disk full in 44 days, got %v", week.Disk.DaysUntilFull)
+	}
+	if week.Memory.GrowthPerDay != 0 || week.Memory.DaysUntilFull != nil {
+		t.Errorf("Expected flat memory, got %+v", week.Memory)
+	}
+	if week.Containers.GrowthPerDay < 0.4 || week.Containers.GrowthPerDay > 0.6 {
+		t.Errorf("Expected about half a container a day, got %v", week.Containers.GrowthPerDay)
+	}
+
+	// Both windows cover the same 6 days of history
+	if trends.Windows[1].Hours != week.Hours {
+		t.Errorf("Expected the 30d window to use all %d hours, got %d", week.Hours, trends.Windows[1].Hours)
+	}
+
+	if _, ok := history.Trends("unknown", now); ok {
+		t.Error("Expected no trends for an unknown agent")
+	}
+}
+
+func TestHistory_TrendsNeedHistory(t *testing.T) {
+	history := NewHistory(time.Hour, 30*24*time.Hour)
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	history.Record("web-1", HistorySample{Time: now.Add(-2 * time.Hour), DiskPercent: 10})
+	history.Record("web-1", HistorySample{Time: now, DiskPercent: 90})
+
+	trends, _ := history.Trends("web-1", now)
+	if trends.Windows[0].Disk != nil {
+		t.Errorf("Expected no trend from 2 hours of history, got %+v", trends.Windows[0].Disk)
+	}
+}
+
+func TestHourlyAverages(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	hours := hourlyAverages([]HistorySample{
+		{Time: start, CPUPercent: 10},
+		{Time: start.Add(30 * time.Minute), CPUPercent: 30},
+		{Time: start.Add(time.Hour), CPUPercent: 50},
+	})
+
+	if len(hours) != 2 || hours[0].CPUPercent != 20 || !hours[1].Time.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected 2 hourly averages starting with 20%%, got %+v", hours)
+	}
+}
@@ -11,7 +11,36 @@ type ServerState struct {
 	AgentName     string    `json:"agent_name"`
 	EC2InstanceID string    `json:"ec2_instance_id,omitempty"`
 	LastSeen      time.Time `json:"last_seen"`
-	Status        string    `json:"status"` // online, offline, degraded
+	Status        string    `json:"status"` // online, offline, degraded, terminating, terminated, stopped, decommissioned
+	StatusReason  string    `json:"status_reason,omitempty"`
+	AgentVersion  string    `json:"agent_version,omitempty"` // Reported in heartbeats
+
+	// Last metrics push, nil for agents that only ever sent heartbeats
+	LastMetricsAt *time.Time `json:"last_metrics_at,omitempty"`
+
+	// Heartbeat interval the agent reported, which stretches its offline
+	// timeout (0 = not reported)
+	HeartbeatInterval time.Duration `json:"-"`
+
+	// When the last heartbeat arrived, and a moving average of how late
+	// heartbeats have been from 0 (on time) to 1 (missed), for the health score
+	LastHeartbeatAt   time.Time `json:"-"`
+	HeartbeatLateness float64   `json:"-"`
+
+	// 0-100 rating of the agent, see HealthAt. It depends on the time, so
+	// it is set when a response is built, not kept in the store.
+	HealthScore int `json:"health_score"`
+
+	// How far the agent's clock is ahead of the server's (negative when
+	// behind), measured on its last heartbeat
+	ClockSkewSeconds float64 `json:"clock_skew_seconds,omitempty"`
+
+	// While in maintenance the alert engine skips the agent entirely
+	MaintenanceUntil  *time.Time `json:"maintenance_until,omitempty"`
+	MaintenanceReason string     `json:"maintenance_reason,omitempty"`
+
+	// Cloud instance the agent runs on, if detected
+	Cloud *CloudMetadata `json:"cloud,omitempty"`
 
 	// Latest metrics
 	SystemMetrics metrics.SystemMetrics `json:"system_metrics"`
@@ -19,6 +48,14 @@ type ServerState struct {
 
 	// Alert states
 	ActiveAlerts []Alert `json:"active_alerts"`
+
+	// Sequence number of the last metrics push, the base for delta pushes
+	PushSequence uint64 `json:"-"`
+}
+
+// InMaintenance reports whether the agent is in maintenance at the given time
+func (s *ServerState) InMaintenance(at time.Time) bool {
+	return s.MaintenanceUntil != nil && at.Before(*s.MaintenanceUntil)
 }
 
 // DiskMetrics represents disk metrics for a mount point
@@ -34,11 +71,22 @@ func (s *ServerState) Clone() *ServerState {
 	}
 
 	clone := &ServerState{
-		AgentName:     s.AgentName,
-		EC2InstanceID: s.EC2InstanceID,
-		LastSeen:      s.LastSeen,
-		Status:        s.Status,
-		SystemMetrics: s.SystemMetrics, // SystemMetrics contains primitives and can be copied
+		AgentName:         s.AgentName,
+		EC2InstanceID:     s.EC2InstanceID,
+		LastSeen:          s.LastSeen,
+		LastMetricsAt:     s.LastMetricsAt, // Replaced, never modified in place
+		Status:            s.Status,
+		StatusReason:      s.StatusReason,
+		AgentVersion:      s.AgentVersion,
+		HeartbeatInterval: s.HeartbeatInterval,
+		LastHeartbeatAt:   s.LastHeartbeatAt,
+		HeartbeatLateness: s.HeartbeatLateness,
+		ClockSkewSeconds:  s.ClockSkewSeconds,
+		MaintenanceUntil:  s.MaintenanceUntil, // Replaced, never modified in place
+		MaintenanceReason: s.MaintenanceReason,
+		PushSequence:      s.PushSequence,
+		SystemMetrics:     s.SystemMetrics, // SystemMetrics contains primitives and can be copied
+		Cloud:             s.Cloud.Clone(),
 	}
 
 	// Deep copy containers slice
@@ -65,21 +113,81 @@ func (s *ServerState) Clone() *ServerState {
 	return clone
 }
 
+// FleetContainer is a container and the agent it runs on
+type FleetContainer struct {
+	AgentName   string `json:"agent_name"`
+	AgentStatus string `json:"agent_status"` // Containers of agents not reporting may be stale
+	ContainerState
+}
+
+// ImageInventory is one image repository in use across the fleet
+type ImageInventory struct {
+	Repository string         `json:"repository"` // Image without tag or digest
+	Containers int            `json:"containers"`
+	Agents     int            `json:"agents"`
+	Versions   []ImageVersion `json:"versions"`
+}
+
+// ImageVersion is one tag or digest of an image repository in use
+type ImageVersion struct {
+	Image           string   `json:"image"` // As containers reference it, e.g. nginx:1.19
+	Tag             string   `json:"tag,omitempty"`
+	Digest          string   `json:"digest,omitempty"`
+	ImageIDs        []string `json:"image_ids"` // More than one when a tag moved between pulls
+	Containers      int      `json:"containers"`
+	Running         int      `json:"running"`
+	Agents          []string `json:"agents"`
+	UpdateAvailable bool     `json:"update_available,omitempty"` // The registry has a newer image for the tag
+}
+
 // ContainerState tracks container state for change detection
 type ContainerState struct {
-	ID              string    `json:"id"`
-	Name            string    `json:"name"`
-	Image           string    `json:"image"`
-	State           string    `json:"state"`
-	PreviousState   string    `json:"previous_state"`
-	LastStateChange time.Time `json:"last_state_change"`
-	RestartCount    int       `json:"restart_count"`
-	AlertState      string    `json:"alert_state"` // ok, warning, critical
-	Health          string    `json:"health"`
-	CPUPercent      float64   `json:"cpu_percent"`
-	MemoryPercent   float64   `json:"memory_percent"`
-	MemoryUsage     uint64    `json:"memory_usage"`
-	MemoryLimit     uint64    `json:"memory_limit"`
+	ID                  string    `json:"id"`
+	Name                string    `json:"name"`
+	Image               string    `json:"image"`
+	ImageID             string    `json:"image_id,omitempty"`
+	State               string    `json:"state"`
+	PreviousState       string    `json:"previous_state"`
+	LastStateChange     time.Time `json:"last_state_change"`
+	RestartCount        int       `json:"restart_count"`
+	AlertState          string    `json:"alert_state"` // ok, warning, critical
+	Health              string    `json:"health"`
+	HealthCheckExitCode int       `json:"health_check_exit_code,omitempty"`
+	HealthCheckOutput   string    `json:"health_check_output,omitempty"`
+	CPUPercent          float64   `json:"cpu_percent"`
+	MemoryPercent       float64   `json:"memory_percent"`
+	MemoryUsage         uint64    `json:"memory_usage"`
+	MemoryLimit         uint64    `json:"memory_limit"`
+	UpdateAvailable     bool      `json:"update_available,omitempty"`
+
+	// How the container last exited
+	ExitCode   int       `json:"exit_code"`
+	OOMKilled  bool      `json:"oom_killed"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	StateError string    `json:"state_error,omitempty"`
+
+	OOMKillsLastHour int `json:"oom_kills_last_hour,omitempty"`
+
+	// Last remediation the agent performed on this container
+	Remediation *metrics.RemediationAction `json:"remediation,omitempty"`
+
+	// Alert thresholds set via container labels
+	Thresholds *metrics.ContainerThresholds `json:"thresholds,omitempty"`
+
+	// Replaced on every push, never modified in place
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Compose service ("project/service") and replica number, from the
+	// container's labels. With the name they identify a container across
+	// recreation, see mergeContainerStates.
+	ComposeService string `json:"compose_service,omitempty"`
+	ComposeNumber  string `json:"-"`
+
+	// Set once the container has been recreated: the ID it replaced, how
+	// often it was recreated and restarts of its earlier incarnations
+	PreviousID    string `json:"previous_id,omitempty"`
+	Recreations   int    `json:"recreations,omitempty"`
+	PriorRestarts int    `json:"prior_restarts,omitempty"`
 }
 
 // Alert represents an active or historical alert
@@ -94,6 +202,13 @@ type Alert struct {
 	ResolvedAt  *time.Time             `json:"resolved_at,omitempty"`
 	Status      string                 `json:"status"` // active, resolved, acknowledged
 	NotifiedAt  *time.Time             `json:"notified_at,omitempty"`
+
+	// Annotations such as runbook_url, team and priority
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Who is looking into the alert, set through the API
+	Assignee   string     `json:"assignee,omitempty"`
+	AssignedAt *time.Time `json:"assigned_at,omitempty"`
 }
 
 // MetricsPushPayload is what agents send to the server
@@ -101,7 +216,67 @@ type MetricsPushPayload struct {
 	AgentName     string                `json:"agent_name"`
 	Timestamp     time.Time             `json:"timestamp"`
 	EC2Metadata   *EC2Metadata          `json:"ec2_metadata,omitempty"`
+	CloudMetadata *CloudMetadata        `json:"cloud_metadata,omitempty"`
 	SystemMetrics metrics.SystemMetrics `json:"system_metrics"`
+
+	// Agents number their pushes. A delta push only carries containers that
+	// changed since the push numbered BaseSequence, plus the IDs of those
+	// that went away; the server fills in the rest, see ExpandDelta.
+	Sequence          uint64   `json:"sequence,omitempty"`
+	Delta             bool     `json:"delta,omitempty"`
+	BaseSequence      uint64   `json:"base_sequence,omitempty"`
+	RemovedContainers []string `json:"removed_containers,omitempty"`
+}
+
+// ScrapeResponse is what an agent in pull mode serves on GET /api/v1/metrics:
+// the payload it would otherwise push, plus what heartbeats carry
+type ScrapeResponse struct {
+	MetricsPushPayload
+	AgentVersion string `json:"agent_version,omitempty"`
+}
+
+// CloudMetadata describes the cloud instance an agent runs on, independent
+// of the provider
+type CloudMetadata struct {
+	Provider     string            `json:"provider"` // aws, gcp, azure
+	InstanceID   string            `json:"instance_id"`
+	InstanceType string            `json:"instance_type"`
+	Region       string            `json:"region"`
+	Zone         string            `json:"zone"`
+	Lifecycle    string            `json:"lifecycle,omitempty"` // spot, on-demand
+	Tags         map[string]string `json:"tags,omitempty"`      // EC2/Azure tags, GCE metadata attributes
+}
+
+// EC2Metadata converts AWS metadata back to the legacy EC2 form, returning
+// nil for other providers
+func (c *CloudMetadata) EC2Metadata() *EC2Metadata {
+	if c == nil || c.Provider != "aws" {
+		return nil
+	}
+	return &EC2Metadata{
+		InstanceID:        c.InstanceID,
+		InstanceType:      c.InstanceType,
+		Region:            c.Region,
+		AvailabilityZone:  c.Zone,
+		Tags:              c.Tags,
+		InstanceLifecycle: c.Lifecycle,
+	}
+}
+
+// Clone returns a deep copy of the metadata
+func (c *CloudMetadata) Clone() *CloudMetadata {
+	if c == nil {
+		return nil
+	}
+
+	clone := *c
+	if c.Tags != nil {
+		clone.Tags = make(map[string]string, len(c.Tags))
+		for k, v := range c.Tags {
+			clone.Tags[k] = v
+		}
+	}
+	return &clone
 }
 
 // EC2Metadata contains EC2 instance information
@@ -111,10 +286,85 @@ type EC2Metadata struct {
 	Region           string            `json:"region"`
 	AvailabilityZone string            `json:"availability_zone"`
 	Tags             map[string]string `json:"tags,omitempty"`
+
+	InstanceLifecycle string `json:"instance_lifecycle,omitempty"` // spot, on-demand
+}
+
+// CloudMetadata converts EC2 metadata to the provider-agnostic form
+func (m *EC2Metadata) CloudMetadata() *CloudMetadata {
+	if m == nil {
+		return nil
+	}
+	return &CloudMetadata{
+		Provider:     "aws",
+		InstanceID:   m.InstanceID,
+		InstanceType: m.InstanceType,
+		Region:       m.Region,
+		Zone:         m.AvailabilityZone,
+		Lifecycle:    m.InstanceLifecycle,
+		Tags:         m.Tags,
+	}
+}
+
+// MaintenanceRequest is the body of PUT /api/v1/agents/{name}/maintenance
+type MaintenanceRequest struct {
+	Duration string `json:"duration"` // Go duration, e.g. "2h"
+	Reason   string `json:"reason,omitempty"`
+}
+
+// AlertAssignment is the body of PUT /api/v1/alerts/{id}/assign
+type AlertAssignment struct {
+	Assignee string `json:"assignee"` // Empty to unassign
+}
+
+// ExternalAlertRequest is the body of POST /api/v1/alerts/external, an
+// alert reported by another system
+type ExternalAlertRequest struct {
+	AgentName string                 `json:"agent_name"`           // Required, the host it concerns
+	Message   string                 `json:"message"`              // Required
+	AlertType string                 `json:"alert_type,omitempty"` // Default: external
+	Severity  string                 `json:"severity,omitempty"`   // Default: warning
+	Source    string                 `json:"source,omitempty"`     // Default: external
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// CustomMetricsRequest is the body of POST /api/v1/metrics/custom, metrics
+// an application pushes for an agent or a logical service
+type CustomMetricsRequest struct {
+	AgentName string               `json:"agent_name,omitempty"` // One of agent_name and service is required
+	Service   string               `json:"service,omitempty"`
+	Metrics   []CustomMetricSample `json:"metrics"`
+}
+
+// CustomMetricSample is one metric of a CustomMetricsRequest
+type CustomMetricSample struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type,omitempty"` // gauge (default) or counter
+	Value  float64           `json:"value"`          // Counters: the increment, >= 0
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// NotificationChannelStatus is the health of a notification channel, as
+// reported by GET /api/v1/health
+type NotificationChannelStatus struct {
+	Channel             string     `json:"channel"`
+	Healthy             bool       `json:"healthy"`
+	LastCheck           *time.Time `json:"last_check,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	FailingSince        *time.Time `json:"failing_since,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
 }
 
 // HeartbeatPayload is a minimal payload for heartbeat checks
 type HeartbeatPayload struct {
 	AgentName string    `json:"agent_name"`
 	Timestamp time.Time `json:"timestamp"`
+	Status    string    `json:"status,omitempty"` // online (default), terminating, stopping
+	Reason    string    `json:"reason,omitempty"` // Why the agent is terminating; shutdown or decommission when stopping
+
+	AgentVersion string `json:"agent_version,omitempty"`
+
+	// How often the agent heartbeats, in seconds (0 = not reported)
+	HeartbeatIntervalSeconds int `json:"heartbeat_interval_seconds,omitempty"`
 }
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"sort"
+	"time"
+)
+
+// Incident is a period an agent was offline, from when it was last heard
+// from until it reported in again
+type Incident struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"` // nil while it is still offline
+}
+
+// Uptime is an agent's availability over a window. Only being offline counts
+// against it: agents that shut down cleanly or whose instance was terminated
+// are down on purpose. Only the part of the window since the agent was
+// first seen counts, and history doesn't survive a server restart.
+type Uptime struct {
+	AgentName           string     `json:"agent_name"`
+	From                time.Time  `json:"from"`
+	To                  time.Time  `json:"to"`
+	ObservedSeconds     float64    `json:"observed_seconds"`
+	DowntimeSeconds     float64    `json:"downtime_seconds"`
+	AvailabilityPercent float64    `json:"availability_percent"`
+	Incidents           []Incident `json:"incidents"`
+	// Mean time to recovery of the incidents that have ended
+	MTTRSeconds float64 `json:"mttr_seconds"`
+}
+
+// StatusChanged records an agent's status change at the given time: going
+// offline opens an incident starting when it was last seen, any other status
+// after offline closes it
+func (h *History) StatusChanged(agentName, previous, status string, lastSeen, at time.Time) {
+	wentOffline := status == "offline" && previous != "offline"
+	cameBack := previous == "offline" && status != "offline"
+
+	agent := h.agent(agentName, at)
+	if !wentOffline && !cameBack {
+		return
+	}
+
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	if wentOffline {
+		start := lastSeen
+		if start.IsZero() || start.Before(agent.firstSeen) {
+			start = agent.firstSeen
+		}
+		agent.incidents = append(agent.incidents, Incident{Start: start})
+	} else if n := len(agent.incidents); n > 0 && agent.incidents[n-1].End == nil {
+		end := at
+		agent.incidents[n-1].End = &end
+	}
+
+	// Drop incidents that ended before anything else still kept
+	cutoff := at.Add(-h.keep)
+	for len(agent.incidents) > 0 && agent.incidents[0].End != nil && agent.incidents[0].End.Before(cutoff) {
+		agent.incidents = agent.incidents[1:]
+	}
+}
+
+// Uptime works out an agent's availability between from and to. It returns
+// false for agents without history.
+func (h *History) Uptime(agentName string, from, to time.Time) (Uptime, bool) {
+	h.mu.RLock()
+	agent, exists := h.agents[agentName]
+	h.mu.RUnlock()
+	if !exists {
+		return Uptime{}, false
+	}
+
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	uptime := Uptime{AgentName: agentName, From: from, To: to, Incidents: make([]Incident, 0)}
+	observedFrom := from
+	if agent.firstSeen.After(observedFrom) {
+		observedFrom = agent.firstSeen
+	}
+	if to.After(observedFrom) {
+		uptime.ObservedSeconds = to.Sub(observedFrom).Seconds()
+	}
+
+	var recovered int
+	var recoverySeconds float64
+	for _, incident := range agent.incidents {
+		end := to
+		if incident.End != nil {
+			end = *incident.End
+		}
+		if !end.After(observedFrom) || !incident.Start.Before(to) {
+			continue
+		}
+		uptime.Incidents = append(uptime.Incidents, incident)
+
+		start := incident.Start
+		if start.Before(observedFrom) {
+			start = observedFrom
+		}
+		if end.After(to) {
+			end = to
+		}
+		uptime.DowntimeSeconds += end.Sub(start).Seconds()
+		if incident.End != nil {
+			recovered++
+			recoverySeconds += incident.End.Sub(incident.Start).Seconds()
+		}
+	}
+
+	uptime.AvailabilityPercent = 100
+	if uptime.ObservedSeconds > 0 {
+		uptime.AvailabilityPercent = (1 - uptime.DowntimeSeconds/uptime.ObservedSeconds) * 100
+	}
+	if recovered > 0 {
+		uptime.MTTRSeconds = recoverySeconds / float64(recovered)
+	}
+	return uptime, true
+}
+
+// FleetUptime rolls the uptime of every agent up over a window
+type FleetUptime struct {
+	From                time.Time `json:"from"`
+	To                  time.Time `json:"to"`
+	AvailabilityPercent float64   `json:"availability_percent"` // Weighted by observed time
+	DowntimeSeconds     float64   `json:"downtime_seconds"`
+	Incidents           int       `json:"incidents"`
+	MTTRSeconds         float64   `json:"mttr_seconds"`
+
+	// Per agent, least available first
+	Agents []AgentUptimeSummary `json:"agents"`
+}
+
+// AgentUptimeSummary is one agent's line in a FleetUptime
+type AgentUptimeSummary struct {
+	AgentName           string  `json:"agent_name"`
+	AvailabilityPercent float64 `json:"availability_percent"`
+	DowntimeSeconds     float64 `json:"downtime_seconds"`
+	Incidents           int     `json:"incidents"`
+	MTTRSeconds         float64 `json:"mttr_seconds"`
+}
+
+// FleetUptime works out the availability of every agent between from and to
+func (h *History) FleetUptime(from, to time.Time) FleetUptime {
+	fleet := FleetUptime{From: from, To: to, AvailabilityPercent: 100, Agents: make([]AgentUptimeSummary, 0)}
+
+	var observed float64
+	var recovered int
+	var recoverySeconds float64
+	for _, name := range h.Agents() {
+		uptime, ok := h.Uptime(name, from, to)
+		if !ok {
+			continue
+		}
+		observed += uptime.ObservedSeconds
+		fleet.DowntimeSeconds += uptime.DowntimeSeconds
+		fleet.Incidents += len(uptime.Incidents)
+		for _, incident := range uptime.Incidents {
+			if incident.End != nil {
+				recovered++
+				recoverySeconds += incident.End.Sub(incident.Start).Seconds()
+			}
+		}
+		fleet.Agents = append(fleet.Agents, AgentUptimeSummary{
+			AgentName:           name,
+			AvailabilityPercent: uptime.AvailabilityPercent,
+			DowntimeSeconds:     uptime.DowntimeSeconds,
+			Incidents:           len(uptime.Incidents),
+			MTTRSeconds:         uptime.MTTRSeconds,
+		})
+	}
+
+	if observed > 0 {
+		fleet.AvailabilityPercent = (1 - fleet.DowntimeSeconds/observed) * 100
+	}
+	if recovered > 0 {
+		fleet.MTTRSeconds = recoverySeconds / float64(recovered)
+	}
+	sort.SliceStable(fleet.Agents, func(i, j int) bool {
+		return fleet.Agents[i].AvailabilityPercent < fleet.Agents[j].AvailabilityPercent
+	})
+	return fleet
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistory_Uptime(t *testing.T) {
+	history := NewHistory(time.Hour, 24*time.Hour)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	history.StatusChanged("web-1", "", "online", start, start)
+	// Offline from 01:00 (noticed at 01:02) until 01:30
+	history.StatusChanged("web-1", "online", "offline", start.Add(time.Hour), start.Add(62*time.Minute))
+	history.StatusChanged("web-1", "offline", "online", start.Add(90*time.Minute), start.Add(90*time.Minute))
+	// Offline from 09:00 and still offline
+	history.StatusChanged("web-1", "online", "offline", start.Add(9*time.Hour), start.Add(9*time.Hour))
+
+	uptime, ok := history.Uptime("web-1", start, start.Add(10*time.Hour))
+	if !ok {
+		t.Fatal("Expected uptime for web-1")
+	}
+	if len(uptime.Incidents) != 2 {
+		t.Fatalf("Expected 2 incidents, got %d", len(uptime.Incidents))
+	}
+	if uptime.DowntimeSeconds != 90*60 {
+		t.Errorf("Expected 90m of downtime, got %vs", uptime.DowntimeSeconds)
+	}
+	if want := (1 - 1.5/10) * 100; math.Abs(uptime.AvailabilityPercent-want) > 1e-9 {
+		t.Errorf("Expected availability %.2f%%, got %.2f%%", want, uptime.AvailabilityPercent)
+	}
+	// Only the incident that ended counts towards MTTR
+	if uptime.MTTRSeconds != 30*60 {
+		t.Errorf("Expected MTTR of 30m, got %vs", uptime.MTTRSeconds)
+	}
+
+	// Only the part of the window since the agent was first seen counts
+	uptime, _ = history.Uptime("web-1", start.Add(-10*time.Hour), start.Add(2*time.Hour))
+	if uptime.ObservedSeconds != 2*3600 || len(uptime.Incidents) != 1 {
+		t.Errorf("Expected 2h observed with 1 incident, got %vs and %d", uptime.ObservedSeconds, len(uptime.Incidents))
+	}
+
+	if _, ok := history.Uptime("unknown", start, start.Add(time.Hour)); ok {
+		t.Error("Expected no uptime for an unknown agent")
+	}
+}
+
+func TestHistory_FleetUptime(t *testing.T) {
+	history := NewHistory(time.Hour, 24*time.Hour)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	history.StatusChanged("web-1", "", "online", start, start)
+	history.StatusChanged("web-2", "", "online", start, start)
+	history.StatusChanged("web-2", "online", "offline", start.Add(time.Hour), start.Add(time.Hour))
+	history.StatusChanged("web-2", "offline", "online", start.Add(2*time.Hour), start.Add(2*time.Hour))
+
+	fleet := history.FleetUptime(start, start.Add(4*time.Hour))
+	if fleet.Incidents != 1 || fleet.DowntimeSeconds != 3600 || fleet.MTTRSeconds != 3600 {
+		t.Errorf("Unexpected fleet uptime: %+v", fleet)
+	}
+	// 1h down out of 8 agent-hours
+	if fleet.AvailabilityPercent != 87.5 {
+		t.Errorf("Expected 87.5%% availability, got %v", fleet.AvailabilityPercent)
+	}
+	if len(fleet.Agents) != 2 || fleet.Agents[0].AgentName != "web-2" || fleet.Agents[0].AvailabilityPercent != 75 {
+		t.Errorf("Expected web-2 first at 75%%, got %+v", fleet.Agents)
+	}
+}
+
+func TestStateStore_RecordsOfflineIncidents(t *testing.T) {
+	store := NewStateStore()
+	history := NewHistory(time.Hour, 0)
+	store.SetHistory(history)
+
+	store.UpdateHeartbeat("web-1")
+	store.UpdateHeartbeat("web-2")
+	time.Sleep(20 * time.Millisecond)
+	store.CheckOfflineAgents(10 * time.Millisecond)
+
+	// web-1 comes back, web-2 shuts down cleanly
+	store.UpdateHeartbeat("web-1")
+	store.MarkStopped("web-2", false)
+
+	now := time.Now()
+	uptime, _ := history.Uptime("web-1", now.Add(-time.Hour), now)
+	if len(uptime.Incidents) != 1 || uptime.Incidents[0].End == nil {
+		t.Fatalf("Expected 1 ended incident for web-1, got %+v", uptime.Incidents)
+	}
+	if uptime.AvailabilityPercent >= 100 {
+		t.Errorf("Expected availability below 100%%, got %v", uptime.AvailabilityPercent)
+	}
+
+	uptime, _ = history.Uptime("web-2", now.Add(-time.Hour), now)
+	if len(uptime.Incidents) != 1 || uptime.Incidents[0].End == nil {
+		t.Errorf("Expected stopping to end web-2's incident, got %+v", uptime.Incidents)
+	}
+}
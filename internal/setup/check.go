@@ -0,0 +1,81 @@
+package setup
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets in a printed configuration
+const redacted = "<redacted>"
+
+// secretKeys are the config keys whose values are secrets
+var secretKeys = map[string]bool{
+	"key":         true,
+	"api_key":     true,
+	"token":       true,
+	"secret":      true,
+	"password":    true,
+	"webhook_url": true, // Google Chat's carries its key
+	"headers":     true, // e.g. Authorization
+}
+
+// PrintConfig writes the effective configuration cfg, after defaults and
+// environment expansion, as YAML to w for --check-config. Secrets are
+// redacted, as are passwords in URLs, so the output is safe in CI logs.
+func PrintConfig(w io.Writer, cfg interface{}) error {
+	var doc yaml.Node
+	if err := doc.Encode(cfg); err != nil {
+		return err
+	}
+	redact(&doc)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// redact replaces the secrets in a YAML tree
+func redact(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if secretKeys[node.Content[i].Value] {
+				redactAll(node.Content[i+1])
+			} else {
+				redact(node.Content[i+1])
+			}
+		}
+	case yaml.SequenceNode, yaml.DocumentNode:
+		for _, child := range node.Content {
+			redact(child)
+		}
+	case yaml.ScalarNode:
+		if u, err := url.Parse(node.Value); err == nil && u.User != nil && strings.Contains(node.Value, "://") {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				node.Value = u.Redacted()
+			}
+		}
+	}
+}
+
+// redactAll replaces every non-empty value under node
+func redactAll(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode {
+		if node.Value != "" && node.Tag != "!!null" {
+			node.Value, node.Tag, node.Style = redacted, "!!str", 0
+		}
+		return
+	}
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && i%2 == 0 {
+			continue // Keep the keys, e.g. header names
+		}
+		redactAll(child)
+	}
+}
@@ -0,0 +1,109 @@
+// Package setup generates starter configuration files for the agent and
+// server, used by their init subcommands.
+package setup
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// GenerateAPIKey returns a random 256-bit API key, hex encoded
+func GenerateAPIKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// WriteConfig writes a generated config file. The file is only readable by
+// the owner since it contains API keys. An existing file is never replaced
+// unless force is set.
+func WriteConfig(path string, data []byte, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%s already exists (use -force to overwrite)", path)
+		}
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// Prompter asks questions on a terminal. When not interactive, every
+// question silently takes its default.
+type Prompter struct {
+	in          *bufio.Reader
+	out         io.Writer
+	interactive bool
+}
+
+// NewPrompter creates a prompter reading answers from in
+func NewPrompter(in io.Reader, out io.Writer, interactive bool) *Prompter {
+	return &Prompter{
+		in:          bufio.NewReader(in),
+		out:         out,
+		interactive: interactive,
+	}
+}
+
+// IsTerminal reports whether f is an interactive terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// String asks for a value, returning def when the answer is empty
+func (p *Prompter) String(question, def string) string {
+	if !p.interactive {
+		return def
+	}
+
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+
+	answer, _ := p.in.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def
+	}
+	return answer
+}
+
+// Bool asks a yes/no question, returning def when the answer is empty or
+// not understood
+func (p *Prompter) Bool(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+
+	switch strings.ToLower(p.String(question+" ("+hint+")", "")) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
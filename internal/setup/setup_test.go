@@ -0,0 +1,203 @@
+package setup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anurag/saviour/internal/config"
+	"github.com/anurag/saviour/internal/server"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	a, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	b, _ := GenerateAPIKey()
+
+	if len(a) != 64 {
+		t.Errorf("Expected 64 hex characters, got %d", len(a))
+	}
+	if a == b {
+		t.Error("Expected distinct keys")
+	}
+}
+
+func TestAgentConfig_LoadsAndValidates(t *testing.T) {
+	data, err := AgentConfig(AgentOptions{
+		Name:          `web "01"`,
+		ServerURL:     "https://saviour.example.com",
+		APIKey:        "agent-key",
+		CloudProvider: "auto",
+		Docker:        true,
+		DockerSocket:  "/var/run/docker.sock",
+	})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	if err := WriteConfig(path, data, false); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Generated config failed to load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Generated config is invalid: %v", err)
+	}
+
+	if cfg.Agent.Name != `web "01"` {
+		t.Errorf("Expected name to round-trip, got %q", cfg.Agent.Name)
+	}
+	if cfg.Agent.APIKey != "agent-key" {
+		t.Errorf("Expected api key agent-key, got %q", cfg.Agent.APIKey)
+	}
+	if !cfg.Metrics.Docker.Enabled || !cfg.Metrics.Docker.MonitorAll {
+		t.Error("Expected docker monitoring of all containers")
+	}
+}
+
+func TestServerConfig_LoadsAndValidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    ServerOptions
+		devMode bool
+	}{
+		{
+			name:    "console notifier, dev CORS",
+			opts:    ServerOptions{Port: 8080, AgentKey: "a", DashboardKey: "d", OperatorKey: "o"},
+			devMode: true,
+		},
+		{
+			name: "google chat, production CORS",
+			opts: ServerOptions{
+				Port: 9090, AgentKey: "a", DashboardKey: "d", OperatorKey: "o",
+				WebhookURL:     "https://chat.googleapis.com/v1/spaces/x/messages?key=k&token=t",
+				DashboardURL:   "https://saviour.example.com",
+				AllowedOrigins: []string{"https://saviour.example.com"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ServerConfig(tt.opts)
+			if err != nil {
+				t.Fatalf("Failed to render: %v", err)
+			}
+
+			path := filepath.Join(t.TempDir(), "server.yaml")
+			if err := WriteConfig(path, data, false); err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+
+			cfg, err := server.LoadConfig(path)
+			if err != nil {
+				t.Fatalf("Generated config failed to load: %v", err)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Generated config is invalid: %v", err)
+			}
+
+			if cfg.Server.Port != tt.opts.Port {
+				t.Errorf("Expected port %d, got %d", tt.opts.Port, cfg.Server.Port)
+			}
+			if len(cfg.Auth.APIKeys) != 3 {
+				t.Errorf("Expected 3 API keys, got %d", len(cfg.Auth.APIKeys))
+			}
+			if cfg.GoogleChat.Enabled != (tt.opts.WebhookURL != "") {
+				t.Errorf("Expected google chat enabled=%v", tt.opts.WebhookURL != "")
+			}
+			if cfg.GoogleChat.WebhookURL != tt.opts.WebhookURL {
+				t.Errorf("Expected webhook %q, got %q", tt.opts.WebhookURL, cfg.GoogleChat.WebhookURL)
+			}
+			if cfg.CORS.DevMode != tt.devMode {
+				t.Errorf("Expected CORS dev mode %v, got %v", tt.devMode, cfg.CORS.DevMode)
+			}
+		})
+	}
+}
+
+func TestWriteConfig_RefusesOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.yaml")
+	if err := os.WriteFile(path, []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteConfig(path, []byte("new"), false); err == nil {
+		t.Fatal("Expected error overwriting existing file")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "existing" {
+		t.Errorf("Expected file untouched, got %q", data)
+	}
+
+	if err := WriteConfig(path, []byte("new"), true); err != nil {
+		t.Fatalf("Expected overwrite with force, got %v", err)
+	}
+	info, _ := os.Stat(path)
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("Expected new contents, got %q", data)
+	}
+	if info.Mode().Perm() != 0644 {
+		// Permissions of an existing file are kept on overwrite
+		t.Errorf("Expected mode 0644, got %v", info.Mode().Perm())
+	}
+}
+
+func TestPrompter(t *testing.T) {
+	var out bytes.Buffer
+	p := NewPrompter(strings.NewReader("custom\n\ny\nmaybe\n"), &out, true)
+
+	if got := p.String("Name", "default"); got != "custom" {
+		t.Errorf("Expected custom, got %q", got)
+	}
+	if got := p.String("Name", "default"); got != "default" {
+		t.Errorf("Expected default for empty answer, got %q", got)
+	}
+	if !p.Bool("Docker?", false) {
+		t.Error("Expected yes")
+	}
+	if !p.Bool("Docker?", true) {
+		t.Error("Expected default for unrecognised answer")
+	}
+	if !strings.Contains(out.String(), "Name [default]: ") {
+		t.Errorf("Expected prompt with default, got %q", out.String())
+	}
+
+	quiet := NewPrompter(strings.NewReader("ignored\n"), &out, false)
+	if got := quiet.String("Name", "default"); got != "default" {
+		t.Errorf("Expected default when not interactive, got %q", got)
+	}
+}
+
+func TestPrintConfig_RedactsSecrets(t *testing.T) {
+	cfg := &server.Config{
+		Auth:       server.AuthConfig{APIKeys: []server.APIKey{{Key: "sk_secret", Name: "agents"}}},
+		GoogleChat: server.GoogleChatConfig{WebhookURL: "https://chat.googleapis.com/v1/spaces/x?key=chatkey", DashboardURL: "https://saviour.example.com"},
+		Webhook:    server.WebhookConfig{Headers: map[string]string{"Authorization": "Bearer hooktoken"}},
+		HA:         server.HAConfig{Redis: server.RedisConfig{URL: "redis://:hunter2@redis:6379/0"}},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintConfig(&buf, cfg); err != nil {
+		t.Fatalf("PrintConfig failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, secret := range []string{"sk_secret", "chatkey", "hooktoken", "hunter2"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Expected %s redacted, got:\n%s", secret, out)
+		}
+	}
+	for _, kept := range []string{"name: agents", "Authorization: <redacted>", "https://saviour.example.com", "redis://:xxxxx@redis:6379/0"} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("Expected %q in output, got:\n%s", kept, out)
+		}
+	}
+}
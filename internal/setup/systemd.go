@@ -0,0 +1,245 @@
+package setup
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// DefaultUnitDir is where install writes unit files
+const DefaultUnitDir = "/etc/systemd/system"
+
+// Service describes a hardened systemd unit for one of the Saviour binaries
+type Service struct {
+	Name        string // Unit name without .service, e.g. saviour-agent
+	Description string
+	ExecPath    string   // Absolute path to the binary
+	ConfigPath  string   // Absolute path to its config file
+	User        string   // Created as a system user if missing
+	Groups      []string // Supplementary groups, e.g. docker for the agent
+	After       []string // Units to start after, besides network-online.target
+	UnitDir     string
+
+	// Runs external commands (useradd, systemctl); replaced in tests
+	run func(name string, args ...string) error
+}
+
+// NewService creates a service definition with the default unit directory
+func NewService(name, description string) *Service {
+	return &Service{
+		Name:        name,
+		Description: description,
+		UnitDir:     DefaultUnitDir,
+		run:         runCommand,
+	}
+}
+
+var unitTemplate = template.Must(template.New("unit").Parse(`# Installed by "{{.Name}} install"; remove with "{{.Name}} uninstall"
+[Unit]
+Description={{.Description}}
+Wants=network-online.target
+After=network-online.target{{range .After}} {{.}}{{end}}
+
+[Service]
+Type=simple
+User={{.User}}
+{{- if .Groups}}
+SupplementaryGroups={{range $i, $g := .Groups}}{{if $i}} {{end}}{{$g}}{{end}}
+{{- end}}
+ExecStart={{.ExecPath}} -config {{.ConfigPath}}
+Restart=on-failure
+RestartSec=10
+
+# Hardening: read-only system, no privilege escalation
+NoNewPrivileges=yes
+ProtectSystem=strict
+ProtectHome=read-only
+PrivateTmp=yes
+PrivateDevices=yes
+ProtectKernelTunables=yes
+ProtectKernelModules=yes
+ProtectControlGroups=yes
+RestrictSUIDSGID=yes
+RestrictNamespaces=yes
+LockPersonality=yes
+RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// Unit renders the systemd unit file
+func (s *Service) Unit() ([]byte, error) {
+	return render(unitTemplate, s)
+}
+
+// UnitPath returns where the unit file is installed
+func (s *Service) UnitPath() string {
+	return filepath.Join(s.UnitDir, s.Name+".service")
+}
+
+// Install creates the service user, makes the config readable by it,
+// writes the unit and enables it. With start, the service is also
+// (re)started.
+func (s *Service) Install(start bool) error {
+	if !filepath.IsAbs(s.ExecPath) || !filepath.IsAbs(s.ConfigPath) {
+		return fmt.Errorf("binary and config paths must be absolute")
+	}
+	if _, err := os.Stat(s.ConfigPath); err != nil {
+		return fmt.Errorf("config file: %w (create one with %s init)", err, s.Name)
+	}
+
+	if err := s.ensureUser(); err != nil {
+		return err
+	}
+	if err := s.shareConfig(); err != nil {
+		return err
+	}
+
+	unit, err := s.Unit()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.UnitPath(), unit, 0644); err != nil {
+		return fmt.Errorf("failed to write unit file: %w", err)
+	}
+
+	if err := s.run("systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	if err := s.run("systemctl", "enable", s.Name); err != nil {
+		return err
+	}
+	if start {
+		return s.run("systemctl", "restart", s.Name)
+	}
+	return nil
+}
+
+// Uninstall stops and disables the service and removes its unit file. The
+// service user and config are left in place.
+func (s *Service) Uninstall() error {
+	if _, err := os.Stat(s.UnitPath()); os.IsNotExist(err) {
+		return fmt.Errorf("%s is not installed", s.UnitPath())
+	}
+
+	if err := s.run("systemctl", "disable", "--now", s.Name); err != nil {
+		return err
+	}
+	if err := os.Remove(s.UnitPath()); err != nil {
+		return fmt.Errorf("failed to remove unit file: %w", err)
+	}
+	return s.run("systemctl", "daemon-reload")
+}
+
+// ensureUser creates the service user as a system account if needed
+func (s *Service) ensureUser() error {
+	if _, err := user.Lookup(s.User); err == nil {
+		return nil
+	}
+	return s.run("useradd", "--system", "--no-create-home", "--shell", "/usr/sbin/nologin", s.User)
+}
+
+// shareConfig lets the service user's group read the config, which init
+// writes owner-only because it contains API keys
+func (s *Service) shareConfig() error {
+	u, err := user.Lookup(s.User)
+	if err != nil {
+		return fmt.Errorf("failed to look up user %s: %w", s.User, err)
+	}
+	if u.Uid == "0" {
+		return nil
+	}
+
+	info, err := os.Stat(s.ConfigPath)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0040 != 0 {
+		return nil
+	}
+
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("unexpected gid %q for user %s", u.Gid, s.User)
+	}
+	if err := os.Chown(s.ConfigPath, -1, gid); err != nil {
+		return fmt.Errorf("failed to share config with %s: %w", s.User, err)
+	}
+	return os.Chmod(s.ConfigPath, info.Mode().Perm()|0040)
+}
+
+// GroupExists reports whether a local group exists
+func GroupExists(name string) bool {
+	_, err := user.LookupGroup(name)
+	return err == nil
+}
+
+func runCommand(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// RunServiceCommand implements the install and uninstall subcommands
+// shared by the agent and server binaries
+func RunServiceCommand(svc *Service, command string, args []string, defaultConfig string) error {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	configPath := flags.String("config", defaultConfig, "path to configuration file")
+	flags.StringVar(&svc.User, "user", "saviour", "user the service runs as")
+	flags.StringVar(&svc.UnitDir, "unit-dir", DefaultUnitDir, "systemd unit directory")
+	noStart := flags.Bool("no-start", false, "enable the service without starting it")
+	printUnit := flags.Bool("print", false, "print the unit file instead of installing it")
+	flags.Parse(args)
+
+	var err error
+	if svc.ConfigPath, err = filepath.Abs(*configPath); err != nil {
+		return err
+	}
+	if svc.ExecPath, err = os.Executable(); err != nil {
+		return err
+	}
+	if svc.ExecPath, err = filepath.EvalSymlinks(svc.ExecPath); err != nil {
+		return err
+	}
+
+	if command == "install" && *printUnit {
+		unit, err := svc.Unit()
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(unit))
+		return nil
+	}
+
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("%s must run as root (try sudo)", command)
+	}
+
+	if command == "uninstall" {
+		if err := svc.Uninstall(); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Stopped %s and removed %s (config and user %s kept)\n", svc.Name, svc.UnitPath(), svc.User)
+		return nil
+	}
+
+	if err := svc.Install(!*noStart); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Installed %s\n", svc.UnitPath())
+	if *noStart {
+		fmt.Printf("✓ Enabled %s; start it with: systemctl start %s\n", svc.Name, svc.Name)
+	} else {
+		fmt.Printf("✓ Started %s; follow logs with: journalctl -u %s -f\n", svc.Name, svc.Name)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package setup
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testService returns a service running as the current user, installed
+// into a temp dir, with external commands recorded instead of run
+func testService(t *testing.T) (*Service, *[]string) {
+	t.Helper()
+
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("No current user: %v", err)
+	}
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "agent.yaml")
+	if err := os.WriteFile(configPath, []byte("agent: {}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var commands []string
+	svc := NewService("saviour-agent", "Saviour Monitoring Agent")
+	svc.ExecPath = "/usr/local/bin/saviour-agent"
+	svc.ConfigPath = configPath
+	svc.User = current.Username
+	svc.UnitDir = dir
+	svc.run = func(name string, args ...string) error {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return nil
+	}
+	return svc, &commands
+}
+
+func TestServiceUnit(t *testing.T) {
+	svc := NewService("saviour-agent", "Saviour Monitoring Agent")
+	svc.ExecPath = "/usr/local/bin/saviour-agent"
+	svc.ConfigPath = "/etc/saviour/agent.yaml"
+	svc.User = "saviour"
+	svc.Groups = []string{"docker"}
+	svc.After = []string{"docker.service"}
+
+	data, err := svc.Unit()
+	if err != nil {
+		t.Fatalf("Failed to render unit: %v", err)
+	}
+	unit := string(data)
+
+	for _, want := range []string{
+		"Description=Saviour Monitoring Agent\n",
+		"After=network-online.target docker.service\n",
+		"User=saviour\n",
+		"SupplementaryGroups=docker\n",
+		"ExecStart=/usr/local/bin/saviour-agent -config /etc/saviour/agent.yaml\n",
+		"Restart=on-failure\n",
+		"ProtectSystem=strict\n",
+		"NoNewPrivileges=yes\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("Expected unit to contain %q, got:\n%s", want, unit)
+		}
+	}
+
+	svc.Groups, svc.After = nil, nil
+	data, _ = svc.Unit()
+	if strings.Contains(string(data), "SupplementaryGroups") {
+		t.Error("Expected no SupplementaryGroups line without groups")
+	}
+}
+
+func TestServiceInstall(t *testing.T) {
+	svc, commands := testService(t)
+
+	if err := svc.Install(true); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	if _, err := os.Stat(svc.UnitPath()); err != nil {
+		t.Errorf("Expected unit file written: %v", err)
+	}
+	want := []string{
+		"systemctl daemon-reload",
+		"systemctl enable saviour-agent",
+		"systemctl restart saviour-agent",
+	}
+	if !reflect.DeepEqual(*commands, want) {
+		t.Errorf("Expected commands %v, got %v", want, *commands)
+	}
+
+	// The owner-only config is made readable by the service user's group
+	if info, _ := os.Stat(svc.ConfigPath); svc.User != "root" && info.Mode().Perm() != 0640 {
+		t.Errorf("Expected config mode 0640, got %v", info.Mode().Perm())
+	}
+}
+
+func TestServiceInstall_NoStart(t *testing.T) {
+	svc, commands := testService(t)
+
+	if err := svc.Install(false); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	for _, cmd := range *commands {
+		if strings.Contains(cmd, "restart") {
+			t.Errorf("Expected service not started, got %q", cmd)
+		}
+	}
+}
+
+func TestServiceInstall_MissingConfig(t *testing.T) {
+	svc, commands := testService(t)
+	svc.ConfigPath = filepath.Join(svc.UnitDir, "missing.yaml")
+
+	if err := svc.Install(true); err == nil {
+		t.Fatal("Expected error for missing config")
+	}
+	if len(*commands) != 0 {
+		t.Errorf("Expected no commands run, got %v", *commands)
+	}
+}
+
+func TestServiceUninstall(t *testing.T) {
+	svc, commands := testService(t)
+
+	if err := svc.Uninstall(); err == nil {
+		t.Fatal("Expected error uninstalling a service that isn't installed")
+	}
+
+	if err := svc.Install(false); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	*commands = nil
+
+	if err := svc.Uninstall(); err != nil {
+		t.Fatalf("Uninstall failed: %v", err)
+	}
+	if _, err := os.Stat(svc.UnitPath()); !os.IsNotExist(err) {
+		t.Error("Expected unit file removed")
+	}
+	want := []string{"systemctl disable --now saviour-agent", "systemctl daemon-reload"}
+	if !reflect.DeepEqual(*commands, want) {
+		t.Errorf("Expected commands %v, got %v", want, *commands)
+	}
+}
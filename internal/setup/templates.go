@@ -0,0 +1,155 @@
+package setup
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// AgentOptions are the values filled into a generated agent config
+type AgentOptions struct {
+	Name          string // Empty = hostname at startup
+	ServerURL     string
+	APIKey        string
+	CloudProvider string
+	Docker        bool
+	DockerSocket  string
+}
+
+// ServerOptions are the values filled into a generated server config
+type ServerOptions struct {
+	Port           int
+	AgentKey       string
+	DashboardKey   string
+	OperatorKey    string
+	WebhookURL     string // Google Chat; empty = console notifications
+	DashboardURL   string
+	AllowedOrigins []string // Empty = CORS in dev mode
+}
+
+var agentTemplate = template.Must(template.New("agent").Parse(`# Saviour Agent Configuration
+# Generated by "saviour-agent init". See examples/agent-docker.yaml for
+# every available option.
+
+agent:
+  # Agent name shown on the dashboard (empty = hostname)
+  name: {{printf "%q" .Name}}
+
+  # Central server URL and the API key it issued for agents
+  # (scopes metrics:write and heartbeat:write)
+  server_url: {{printf "%q" .ServerURL}}
+  api_key: {{printf "%q" .APIKey}}
+
+  # How often to collect metrics, push them, and send heartbeats
+  collect_interval: 30s
+  push_interval: 30s
+  heartbeat_interval: 30s
+
+  # Cloud instance metadata: auto, ecs, aws, gcp, azure or none
+  cloud_provider: {{.CloudProvider}}
+
+metrics:
+  # Collect system metrics (CPU, memory, disk, network)
+  system: true
+
+  # Disk mount points to monitor (empty = all mounts)
+  disk_mounts:
+    - "/"
+
+  docker:
+    enabled: {{.Docker}}
+    socket: {{printf "%q" .DockerSocket}}
+
+    # Monitor every container; add filters to narrow this down
+    monitor_all: true
+
+    alerts:
+      default:
+        cpu_threshold: 80.0
+        memory_threshold: 90.0
+        restart_threshold: 5
+        restart_window: "300s"
+
+# System alert thresholds
+alerts:
+  cpu_threshold: 80.0
+  memory_threshold: 85.0
+  disk_threshold: 90.0
+`))
+
+var serverTemplate = template.Must(template.New("server").Parse(`# Saviour Server Configuration
+# Generated by "saviour-server init". Keep this file private: it contains
+# the API keys agents and operators authenticate with.
+
+server:
+  host: "0.0.0.0"
+  port: {{.Port}}
+
+auth:
+  api_keys:
+    # Shared by all agents (saviour-agent init -api-key ...)
+    - key: {{printf "%q" .AgentKey}}
+      name: "agents"
+      scopes: ["metrics:write", "heartbeat:write"]
+
+    # Read-only access for the dashboard and scripts
+    - key: {{printf "%q" .DashboardKey}}
+      name: "dashboard"
+      scopes: ["metrics:read", "alerts:read"]
+
+    # Operators using saviourctl to acknowledge alerts and manage silences
+    - key: {{printf "%q" .OperatorKey}}
+      name: "operators"
+      scopes: ["alerts:write"]
+
+alerting:
+  enabled: true
+  check_interval: 30s
+  heartbeat_timeout: 2m
+
+  # Suppress repeats of the same alert within the window
+  deduplication_enabled: true
+  deduplication_window: 5m
+
+  system_cpu_threshold: 80.0
+  system_memory_threshold: 85.0
+  system_disk_threshold: 90.0
+
+# Google Chat notifications (disabled = alerts are logged to the console)
+google_chat:
+  enabled: {{if .WebhookURL}}true{{else}}false{{end}}
+  webhook_url: {{printf "%q" .WebhookURL}}
+  dashboard_url: {{printf "%q" .DashboardURL}}
+
+cors:
+  enabled: true
+{{- if .AllowedOrigins}}
+  dev_mode: false
+  allowed_origins:
+{{- range .AllowedOrigins}}
+    - {{printf "%q" .}}
+{{- end}}
+{{- else}}
+  # Allows any origin; list your dashboard origins and disable for production
+  dev_mode: true
+  allowed_origins: []
+{{- end}}
+`))
+
+// AgentConfig renders a commented agent config file
+func AgentConfig(opts AgentOptions) ([]byte, error) {
+	return render(agentTemplate, opts)
+}
+
+// ServerConfig renders a commented server config file
+func ServerConfig(opts ServerOptions) ([]byte, error) {
+	return render(serverTemplate, opts)
+}
+
+func render(tmpl *template.Template, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s config: %w", tmpl.Name(), err)
+	}
+	return buf.Bytes(), nil
+}
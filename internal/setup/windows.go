@@ -0,0 +1,150 @@
+//go:build windows
+
+package setup
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// RunWindowsServiceCommand implements the install and uninstall subcommands
+// on Windows, registering the binary with the service control manager. Like
+// the systemd unit, the service starts at boot and is restarted 10 seconds
+// after it fails.
+func RunWindowsServiceCommand(service *Service, command string, args []string, defaultConfig string) error {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	configPath := flags.String("config", defaultConfig, "path to configuration file")
+	noStart := flags.Bool("no-start", false, "register the service without starting it")
+	flags.Parse(args)
+
+	var err error
+	if service.ConfigPath, err = filepath.Abs(*configPath); err != nil {
+		return err
+	}
+	if service.ExecPath, err = os.Executable(); err != nil {
+		return err
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager (run as Administrator): %w", err)
+	}
+	defer m.Disconnect()
+
+	if command == "uninstall" {
+		s, err := m.OpenService(service.Name)
+		if err != nil {
+			return fmt.Errorf("%s is not installed", service.Name)
+		}
+		defer s.Close()
+		// Not running is fine
+		s.Control(svc.Stop)
+		if err := s.Delete(); err != nil {
+			return fmt.Errorf("failed to remove service: %w", err)
+		}
+		fmt.Printf("✓ Removed service %s (config kept)\n", service.Name)
+		return nil
+	}
+
+	if _, err := os.Stat(service.ConfigPath); err != nil {
+		return fmt.Errorf("config file: %w (create one with %s init)", err, service.Name)
+	}
+	if s, err := m.OpenService(service.Name); err == nil {
+		s.Close()
+		return fmt.Errorf("%s is already installed (remove it with %s uninstall)", service.Name, service.Name)
+	}
+
+	s, err := m.CreateService(service.Name, service.ExecPath, mgr.Config{
+		DisplayName: service.Description,
+		Description: service.Description,
+		StartType:   mgr.StartAutomatic,
+	}, "-config", service.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer s.Close()
+
+	restart := []mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: 10 * time.Second}}
+	if err := s.SetRecoveryActions(restart, uint32((24 * time.Hour).Seconds())); err != nil {
+		return fmt.Errorf("failed to set restart policy: %w", err)
+	}
+	// Also restart after exiting with an error, not only after crashes
+	if err := s.SetRecoveryActionsOnNonCrashFailures(true); err != nil {
+		return fmt.Errorf("failed to set restart policy: %w", err)
+	}
+
+	fmt.Printf("✓ Installed service %s\n", service.Name)
+	if *noStart {
+		fmt.Printf("✓ Start it with: sc.exe start %s\n", service.Name)
+		return nil
+	}
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+	fmt.Printf("✓ Started %s\n", service.Name)
+	return nil
+}
+
+// RunAsService calls run under the service control manager when the process
+// was started as a Windows service, cancelling its context when the service is
+// stopped or Windows shuts down. Otherwise run is just called with ctx.
+func RunAsService(ctx context.Context, name string, run func(context.Context) error) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return fmt.Errorf("failed to detect service mode: %w", err)
+	}
+	if !isService {
+		return run(ctx)
+	}
+
+	h := &serviceHandler{ctx: ctx, run: run}
+	if err := svc.Run(name, h); err != nil {
+		return err
+	}
+	return h.err
+}
+
+// serviceHandler runs a function as a Windows service
+type serviceHandler struct {
+	ctx context.Context
+	run func(context.Context) error
+	err error // What run returned
+}
+
+func (h *serviceHandler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(h.ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- h.run(ctx) }()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			if err != nil && !errors.Is(err, context.Canceled) {
+				h.err = err
+				// A service-specific exit code, so recovery restarts it
+				return true, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Package shared keeps the state redundant servers need to make the same
+// notification decisions in Redis: when each alert was last sent, the
+// silences, and who acknowledged or took each open alert. Deduplication is
+// read through on every alert; silences and acks are written behind and
+// synced every few seconds, so a Redis outage leaves each server working on
+// its own copy.
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// Store shares state through Redis under keys starting with its prefix
+type Store struct {
+	logger  *slog.Logger
+	client  *redisClient
+	prefix  string
+	timeout time.Duration // Per Redis command
+
+	// Changes not yet written to Redis; nil deletes
+	mu       sync.Mutex
+	silences map[string]*server.Silence  // key: silence ID
+	acks     map[string]*server.AlertAck // key: see ackField
+	healthy  bool                        // Last sync succeeded
+}
+
+// New creates a store for a Redis URL such as redis://:pass@redis:6379/0
+func New(rawURL, prefix string, timeout time.Duration) (*Store, error) {
+	client, err := newRedisClient(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		logger:   logging.Component("shared"),
+		client:   client,
+		prefix:   prefix,
+		timeout:  timeout,
+		silences: make(map[string]*server.Silence),
+		acks:     make(map[string]*server.AlertAck),
+		healthy:  true,
+	}, nil
+}
+
+// LastSent implements alerting.DedupStore
+func (s *Store) LastSent(alertKey string) (time.Time, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	reply, err := s.client.do(ctx, "GET", s.prefix+"dedup:"+alertKey)
+	if err == errNil {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	nanos, err := strconv.ParseInt(fmt.Sprint(reply), 10, 64)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid deduplication entry for %s: %w", alertKey, err)
+	}
+	return time.Unix(0, nanos), true, nil
+}
+
+// MarkSent implements alerting.DedupStore
+func (s *Store) MarkSent(alertKey string, at time.Time, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	_, err := s.client.do(ctx, "SET", s.prefix+"dedup:"+alertKey, strconv.FormatInt(at.UnixNano(), 10),
+		"PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	return err
+}
+
+// PutSilence implements server.SharedState
+func (s *Store) PutSilence(silence server.Silence) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.silences[silence.ID] = &silence
+}
+
+// DeleteSilence implements server.SharedState
+func (s *Store) DeleteSilence(silenceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.silences[silenceID] = nil
+}
+
+// PutAck implements server.SharedState
+func (s *Store) PutAck(ack server.AlertAck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acks[ackField(ack.AgentName, ack.AlertType)] = &ack
+}
+
+// DeleteAck implements server.SharedState
+func (s *Store) DeleteAck(agentName, alertType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acks[ackField(agentName, alertType)] = nil
+}
+
+// ackField is the hash field an ack is stored under
+func ackField(agentName, alertType string) string {
+	return agentName + ":" + alertType
+}
+
+// Run syncs silences and acks with state every interval until ctx is done
+func (s *Store) Run(ctx context.Context, state *server.StateStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := s.sync(ctx, state)
+		s.mu.Lock()
+		if err != nil && s.healthy {
+			s.logger.Warn("Shared state unavailable, servers may notify separately", logging.Err(err))
+		} else if err == nil && !s.healthy {
+			s.logger.Info("Shared state available again")
+		}
+		s.healthy = err == nil
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync writes this server's changes to Redis, then applies everyone's.
+// Changes made meanwhile are kept over what was read.
+func (s *Store) sync(ctx context.Context, state *server.StateStore) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := s.flush(ctx); err != nil {
+		return err
+	}
+
+	silences, err := s.loadSilences(ctx)
+	if err != nil {
+		return err
+	}
+	acks, err := s.loadAcks(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for id, silence := range s.silences {
+		if silence == nil {
+			delete(silences, id)
+		} else {
+			silences[id] = *silence
+		}
+	}
+	for field, ack := range s.acks {
+		if ack == nil {
+			delete(acks, field)
+		} else {
+			acks[field] = *ack
+		}
+	}
+	s.mu.Unlock()
+
+	silenceList := make([]server.Silence, 0, len(silences))
+	for _, silence := range silences {
+		silenceList = append(silenceList, silence)
+	}
+	ackList := make([]server.AlertAck, 0, len(acks))
+	for _, ack := range acks {
+		ackList = append(ackList, ack)
+	}
+	state.ApplySharedState(silenceList, ackList)
+	return nil
+}
+
+// flush writes pending changes, keeping those that fail or change meanwhile
+func (s *Store) flush(ctx context.Context) error {
+	s.mu.Lock()
+	silences := make(map[string]*server.Silence, len(s.silences))
+	for id, silence := range s.silences {
+		silences[id] = silence
+	}
+	acks := make(map[string]*server.AlertAck, len(s.acks))
+	for field, ack := range s.acks {
+		acks[field] = ack
+	}
+	s.mu.Unlock()
+
+	for id, silence := range silences {
+		if err := s.write(ctx, "silences", id, silence, silence == nil); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		if s.silences[id] == silence {
+			delete(s.silences, id)
+		}
+		s.mu.Unlock()
+	}
+	for field, ack := range acks {
+		if err := s.write(ctx, "acks", field, ack, ack == nil); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		if s.acks[field] == ack {
+			delete(s.acks, field)
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// write sets a hash field to value as JSON, or deletes the field
+func (s *Store) write(ctx context.Context, hash, field string, value interface{}, remove bool) error {
+	key := s.prefix + hash
+	if remove {
+		_, err := s.client.do(ctx, "HDEL", key, field)
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.do(ctx, "HSET", key, field, string(data))
+	return err
+}
+
+// loadSilences reads the shared silences, removing expired ones
+func (s *Store) loadSilences(ctx context.Context) (map[string]server.Silence, error) {
+	fields, err := s.hgetall(ctx, s.prefix+"silences")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	silences := make(map[string]server.Silence, len(fields))
+	for id, data := range fields {
+		var silence server.Silence
+		if err := json.Unmarshal([]byte(data), &silence); err != nil {
+			s.logger.Warn("Ignoring invalid shared silence", "silence_id", id, logging.Err(err))
+			continue
+		}
+		if !now.Before(silence.EndsAt) {
+			if _, err := s.client.do(ctx, "HDEL", s.prefix+"silences", id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		silences[id] = silence
+	}
+	return silences, nil
+}
+
+// loadAcks reads the shared acks
+func (s *Store) loadAcks(ctx context.Context) (map[string]server.AlertAck, error) {
+	fields, err := s.hgetall(ctx, s.prefix+"acks")
+	if err != nil {
+		return nil, err
+	}
+
+	acks := make(map[string]server.AlertAck, len(fields))
+	for field, data := range fields {
+		var ack server.AlertAck
+		if err := json.Unmarshal([]byte(data), &ack); err != nil {
+			s.logger.Warn("Ignoring invalid shared ack", "field", field, logging.Err(err))
+			continue
+		}
+		acks[field] = ack
+	}
+	return acks, nil
+}
+
+// hgetall reads every field of a hash
+func (s *Store) hgetall(ctx context.Context, key string) (map[string]string, error) {
+	reply, err := s.client.do(ctx, "HGETALL", key)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok || len(items)%2 != 0 {
+		return nil, fmt.Errorf("unexpected HGETALL reply for %s", key)
+	}
+	fields := make(map[string]string, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		field, _ := items[i].(string)
+		value, _ := items[i+1].(string)
+		fields[field] = value
+	}
+	return fields, nil
+}
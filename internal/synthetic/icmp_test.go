@@ -0,0 +1,35 @@
+package synthetic
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestEchoRequest(t *testing.T) {
+	msg := echoRequest(0x1234, 7)
+	if msg[0] != icmpEchoRequest || binary.BigEndian.Uint16(msg[4:]) != 0x1234 || binary.BigEndian.Uint16(msg[6:]) != 7 {
+		t.Errorf("Unexpected echo request header: % x", msg[:8])
+	}
+	// A message with a valid checksum sums to zero
+	if sum := icmpChecksum(msg); sum != 0 {
+		t.Errorf("Expected valid checksum, got residue %#x", sum)
+	}
+}
+
+func TestIsEchoReply(t *testing.T) {
+	reply := echoRequest(0x1234, 7)
+	reply[0] = icmpEchoReply
+
+	if !isEchoReply(reply, 0x1234, 7) {
+		t.Error("Expected reply to match its request")
+	}
+	if isEchoReply(reply, 0x1234, 8) {
+		t.Error("Expected reply to another sequence not to match")
+	}
+	if isEchoReply(echoRequest(0x1234, 7), 0x1234, 7) {
+		t.Error("Expected our own request not to count as a reply")
+	}
+	if isEchoReply(reply[:4], 0x1234, 7) {
+		t.Error("Expected a truncated message not to match")
+	}
+}
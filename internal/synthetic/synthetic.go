@@ -0,0 +1,248 @@
+// Package synthetic probes endpoints from the server on a schedule, so
+// services without an agent installed are monitored too. Results are
+// recorded as custom metrics of the virtual agent "synthetic", and failing
+// checks raise alerts that resolve once the check passes again.
+package synthetic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/anurag/saviour/internal/alerting"
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+// AgentName is the virtual agent check results are recorded under
+const AgentName = "synthetic"
+
+// Check types
+const (
+	HTTP = "http" // GET a URL, optionally matching status and body
+	TCP  = "tcp"  // Connect to host:port
+	ICMP = "icmp" // Ping a host
+)
+
+// maxBodySize caps how much of an HTTP response is read for BodyMatch
+const maxBodySize = 1024 * 1024
+
+// Check probes one endpoint
+type Check struct {
+	Name       string
+	Type       string // HTTP, TCP or ICMP
+	Target     string // URL for HTTP, host:port for TCP, host for ICMP
+	Interval   time.Duration
+	Timeout    time.Duration
+	Severity   string         // Of the alert raised when the check fails
+	MaxLatency time.Duration  // Fail when slower (0 = no limit)
+	Status     int            // HTTP: expected status (0 = any 2xx or 3xx)
+	BodyMatch  *regexp.Regexp // HTTP: must match the response body (nil = any)
+}
+
+// Result is the outcome of probing a check once
+type Result struct {
+	Check      string
+	Success    bool
+	Latency    time.Duration
+	StatusCode int    // HTTP only
+	Error      string // Why the check failed
+	CheckedAt  time.Time
+}
+
+// Probe runs a check once
+func Probe(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	result := Result{Check: check.Name, CheckedAt: time.Now()}
+	var err error
+	switch check.Type {
+	case HTTP:
+		result.StatusCode, err = probeHTTP(ctx, check)
+	case TCP:
+		err = probeTCP(ctx, check.Target)
+	case ICMP:
+		err = probeICMP(ctx, check.Target)
+	default:
+		err = fmt.Errorf("unknown check type %q", check.Type)
+	}
+	result.Latency = time.Since(result.CheckedAt)
+
+	if err == nil && check.MaxLatency > 0 && result.Latency > check.MaxLatency {
+		err = fmt.Errorf("took %s, over the %s limit", result.Latency.Round(time.Millisecond), check.MaxLatency)
+	}
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// probeHTTP requests the check's URL and verifies the status and body
+func probeHTTP(ctx context.Context, check Check) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.Target, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "saviour-synthetic/1.0")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if check.Status != 0 && resp.StatusCode != check.Status {
+		return resp.StatusCode, fmt.Errorf("status %d, expected %d", resp.StatusCode, check.Status)
+	}
+	if check.Status == 0 && resp.StatusCode >= 400 {
+		return resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if check.BodyMatch != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+		if err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to read body: %w", err)
+		}
+		if !check.BodyMatch.Match(body) {
+			return resp.StatusCode, fmt.Errorf("body does not match %q", check.BodyMatch)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// probeTCP connects to an address and hangs up
+func probeTCP(ctx context.Context, address string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Run probes each check on its interval until ctx is done. Failing checks
+// are alerted through raise (nil to only record them).
+func Run(ctx context.Context, store *server.StateStore, checks []Check, raise func(alerting.ExternalAlert) *alerting.Alert) {
+	if len(checks) == 0 {
+		return
+	}
+
+	// The virtual agent stays online as long as its most frequent check runs
+	shortest := checks[0].Interval
+	for _, check := range checks {
+		shortest = min(shortest, check.Interval)
+	}
+
+	logger := logging.Component("synthetic")
+	logger.Info("Starting synthetic checks", "checks", len(checks))
+
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		p := &prober{logger: logger, store: store, check: check, raise: raise, heartbeatInterval: shortest}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// prober runs one check and tracks the alert it raised
+type prober struct {
+	logger            *slog.Logger
+	store             *server.StateStore
+	check             Check
+	raise             func(alerting.ExternalAlert) *alerting.Alert
+	heartbeatInterval time.Duration
+	alertID           string // Active alert of the failing check, if any
+}
+
+// run probes the check every interval until ctx is done
+func (p *prober) run(ctx context.Context) {
+	ticker := time.NewTicker(p.check.Interval)
+	defer ticker.Stop()
+
+	for {
+		result := Probe(ctx, p.check)
+		if ctx.Err() != nil {
+			return // Cut short by shutdown, not a failure
+		}
+		p.observe(result)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// observe records a result, and raises or resolves the check's alert
+func (p *prober) observe(result Result) {
+	p.store.UpdateHeartbeat(AgentName)
+	p.store.SetHeartbeatInterval(AgentName, p.heartbeatInterval)
+
+	labels := map[string]string{"check": p.check.Name, "type": p.check.Type}
+	success := 0.0
+	if result.Success {
+		success = 1
+	}
+	samples := []server.CustomMetricSample{
+		{Name: "probe_success", Type: server.CustomGauge, Value: success, Labels: labels},
+		{Name: "probe_duration_seconds", Type: server.CustomGauge, Value: result.Latency.Seconds(), Labels: labels},
+	}
+	if result.StatusCode != 0 {
+		samples = append(samples, server.CustomMetricSample{Name: "probe_http_status_code", Type: server.CustomGauge, Value: float64(result.StatusCode), Labels: labels})
+	}
+	if err := p.store.PushCustomMetrics(server.CustomMetricsRequest{AgentName: AgentName, Metrics: samples}, result.CheckedAt); err != nil {
+		p.logger.Error("Failed to record synthetic check", "check", p.check.Name, logging.Err(err))
+	}
+
+	if result.Success {
+		p.logger.Debug("Synthetic check passed", "check", p.check.Name, "latency", result.Latency)
+		if p.alertID != "" {
+			p.logger.Info("Synthetic check recovered", "check", p.check.Name)
+			p.store.ResolveAlert(p.alertID)
+			p.alertID = ""
+		}
+		return
+	}
+
+	p.logger.Warn("Synthetic check failed", "check", p.check.Name, "target", p.check.Target, "error", result.Error)
+	if p.raise == nil {
+		return
+	}
+	if alert, ok := p.store.GetAlert(p.alertID); ok && alert.Status != "resolved" {
+		return // Still open from an earlier failure
+	}
+	details := map[string]interface{}{
+		"check":      p.check.Name,
+		"type":       p.check.Type,
+		"target":     p.check.Target,
+		"latency_ms": result.Latency.Milliseconds(),
+		"error":      result.Error,
+	}
+	if result.StatusCode != 0 {
+		details["status_code"] = result.StatusCode
+	}
+	alert := p.raise(alerting.ExternalAlert{
+		Source:    "synthetic",
+		AgentName: AgentName,
+		AlertType: "synthetic_check_failed",
+		Key:       p.check.Name,
+		Severity:  p.check.Severity,
+		Message:   fmt.Sprintf("Synthetic check %s failed: %s\nTarget: %s", p.check.Name, result.Error, p.check.Target),
+		Details:   details,
+	})
+	if alert != nil {
+		p.alertID = alert.ID
+	}
+}
@@ -0,0 +1,120 @@
+package synthetic
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anurag/saviour/internal/alerting"
+	"github.com/anurag/saviour/internal/logging"
+	"github.com/anurag/saviour/internal/server"
+)
+
+func TestProbe_HTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		check   Check
+		success bool
+		status  int
+	}{
+		{"up", Check{Target: ts.URL}, true, 200},
+		{"expected status", Check{Target: ts.URL + "/missing", Status: 404}, true, 404},
+		{"error status", Check{Target: ts.URL + "/missing"}, false, 404},
+		{"wrong status", Check{Target: ts.URL, Status: 204}, false, 200},
+		{"body match", Check{Target: ts.URL, BodyMatch: regexp.MustCompile(`"status":"ok"`)}, true, 200},
+		{"body mismatch", Check{Target: ts.URL, BodyMatch: regexp.MustCompile(`degraded`)}, false, 200},
+		{"too slow", Check{Target: ts.URL, MaxLatency: time.Nanosecond}, false, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check.Name, tt.check.Type, tt.check.Timeout = tt.name, HTTP, 5*time.Second
+			result := Probe(context.Background(), tt.check)
+			if result.Success != tt.success || result.StatusCode != tt.status {
+				t.Errorf("Expected success=%v status=%d, got %+v", tt.success, tt.status, result)
+			}
+			if !result.Success && result.Error == "" {
+				t.Error("Expected an error for a failed check")
+			}
+		})
+	}
+}
+
+func TestProbe_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+
+	check := Check{Name: "db", Type: TCP, Target: address, Timeout: 5 * time.Second}
+	if result := Probe(context.Background(), check); !result.Success {
+		t.Errorf("Expected connect to succeed, got %+v", result)
+	}
+
+	listener.Close()
+	if result := Probe(context.Background(), check); result.Success {
+		t.Error("Expected connect to a closed port to fail")
+	}
+}
+
+func TestProber_RecordsAndAlerts(t *testing.T) {
+	store := server.NewStateStore()
+	var raised []alerting.ExternalAlert
+	raise := func(ext alerting.ExternalAlert) *alerting.Alert {
+		raised = append(raised, ext)
+		store.AddAlert(&server.Alert{ID: "alert-" + ext.Key, AgentName: ext.AgentName, AlertType: ext.AlertType, Status: "active", TriggeredAt: time.Now()})
+		return &alerting.Alert{ID: "alert-" + ext.Key}
+	}
+	p := &prober{
+		logger:            logging.Component("synthetic"),
+		store:             store,
+		check:             Check{Name: "api", Type: HTTP, Target: "https://api.example.com/health", Severity: "critical"},
+		raise:             raise,
+		heartbeatInterval: 30 * time.Second,
+	}
+
+	p.observe(Result{Check: "api", Error: "status 503", StatusCode: 503, Latency: 80 * time.Millisecond, CheckedAt: time.Now()})
+	p.observe(Result{Check: "api", Error: "status 503", StatusCode: 503, Latency: 90 * time.Millisecond, CheckedAt: time.Now()})
+
+	agent, exists := store.GetAgent(AgentName)
+	if !exists || agent.Status != "online" {
+		t.Fatalf("Expected the online virtual agent %q, got %+v", AgentName, agent)
+	}
+	values := make(map[string]float64)
+	for _, m := range store.CustomMetrics() {
+		if m.AgentName == AgentName && m.Labels["check"] == "api" {
+			values[m.Name] = m.Value
+		}
+	}
+	if values["probe_success"] != 0 || values["probe_http_status_code"] != 503 || values["probe_duration_seconds"] != 0.09 {
+		t.Errorf("Unexpected recorded metrics: %v", values)
+	}
+
+	// The alert stays open while the check keeps failing
+	if len(raised) != 1 {
+		t.Fatalf("Expected 1 raised alert, got %d", len(raised))
+	}
+	if raised[0].AlertType != "synthetic_check_failed" || raised[0].Key != "api" || !strings.Contains(raised[0].Message, "status 503") {
+		t.Errorf("Unexpected alert: %+v", raised[0])
+	}
+
+	p.observe(Result{Check: "api", Success: true, StatusCode: 200, CheckedAt: time.Now()})
+	if alert, _ := store.GetAlert("alert-api"); alert.Status != "resolved" {
+		t.Errorf("Expected the alert resolved on recovery, got %s", alert.Status)
+	}
+}
@@ -0,0 +1,275 @@
+// Package tracing records spans for request handling, alert engine cycles
+// and notifications, and exports them to an OpenTelemetry collector over
+// OTLP/HTTP with the JSON encoding, without pulling in the OTel SDK.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anurag/saviour/internal/logging"
+)
+
+// SpanKind says what side of an operation a span covers, as in OTLP
+type SpanKind int
+
+// Span kinds
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// maxQueuedSpans bounds the spans held between exports; more are dropped
+const maxQueuedSpans = 4096
+
+// Span is a timed operation within a trace. A nil *Span is valid and
+// records nothing, so callers needn't check whether tracing is enabled.
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte // Zero for a root span
+	sampled  bool
+
+	name  string
+	kind  SpanKind
+	start time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes map[string]interface{}
+	err        string
+}
+
+// SetAttr attaches an attribute, e.g. http.status_code or alert.type
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]interface{})
+	}
+	s.attributes[key] = value
+}
+
+// SetName renames the span, e.g. once the route serving a request is known
+func (s *Span) SetName(name string) {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(err error) {
+	if s == nil || !s.sampled || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.mu.Lock()
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+// TraceParent is the W3C traceparent header continuing this span's trace
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]), flags)
+}
+
+// Tracer creates spans and exports them in batches
+type Tracer struct {
+	service  string
+	ratio    float64 // Share of new traces recorded, 0-1
+	exporter *otlpExporter
+
+	mu      sync.Mutex
+	queue   []*Span
+	dropped int
+}
+
+// New creates a tracer for a service, recording ratio of new traces and
+// exporting them to an OTLP/HTTP endpoint such as http://collector:4318
+func New(service, endpoint string, headers map[string]string, ratio float64) *Tracer {
+	return &Tracer{
+		service:  service,
+		ratio:    ratio,
+		exporter: newOTLPExporter(endpoint, headers),
+	}
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+// SetDefault makes t the tracer Start records with; nil disables tracing
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+// Start begins a span as a child of the span in ctx, or a new trace, with
+// the default tracer. It returns nil while tracing is disabled.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	t := defaultTracer.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	parent, _ := ctx.Value(spanKey{}).(*Span)
+	span := t.newSpan(name, kind, parent)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// StartRemote begins a server span continuing the trace of a W3C
+// traceparent header, or a new trace if it is missing or invalid
+func StartRemote(ctx context.Context, name, traceParent string) (context.Context, *Span) {
+	t := defaultTracer.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	parent, ok := parseTraceParent(traceParent)
+	if !ok {
+		return Start(ctx, name, KindServer)
+	}
+	parent.tracer = t
+	span := t.newSpan(name, KindServer, parent)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext returns the current span in ctx, or nil
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// newSpan starts a span, sampling new traces by the tracer's ratio and
+// following the parent's decision otherwise
+func (t *Tracer) newSpan(name string, kind SpanKind, parent *Span) *Span {
+	span := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	rand.Read(span.spanID[:])
+	if parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+		span.sampled = parent.sampled
+		return span
+	}
+	rand.Read(span.traceID[:])
+	span.sampled = sampled(span.traceID, t.ratio)
+	return span
+}
+
+// sampled decides from the trace ID, so every service sampling by the same
+// ratio keeps the same traces
+func sampled(traceID [16]byte, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	return float64(binary.BigEndian.Uint64(traceID[8:])>>11) < ratio*(1<<53)
+}
+
+// parseTraceParent reads a W3C traceparent header
+// (version-traceid-spanid-flags)
+func parseTraceParent(header string) (*Span, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return nil, false
+	}
+	var span Span
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return nil, false
+	}
+	if _, err := hex.Decode(span.traceID[:], []byte(parts[1])); err != nil || span.traceID == [16]byte{} {
+		return nil, false
+	}
+	if _, err := hex.Decode(span.spanID[:], []byte(parts[2])); err != nil || span.spanID == [8]byte{} {
+		return nil, false
+	}
+	span.sampled = flags[0]&1 == 1
+	return &span, true
+}
+
+// enqueue holds a finished span for the next export
+func (t *Tracer) enqueue(span *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) >= maxQueuedSpans {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, span)
+}
+
+// Run exports queued spans every interval until ctx is done, then exports
+// what is left
+func (t *Tracer) Run(ctx context.Context, interval time.Duration) {
+	logger := logging.Component("tracing")
+	logger.Info("Exporting traces", "endpoint", t.exporter.url, "interval", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := t.Flush(flushCtx); err != nil {
+				logger.Warn("Trace export failed", logging.Err(err))
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := t.Flush(ctx); err != nil {
+				logger.Warn("Trace export failed", logging.Err(err))
+			}
+		}
+	}
+}
+
+// Flush exports the queued spans. Spans that fail to export are dropped
+// rather than retried, so an unreachable collector can't grow the queue.
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	spans, dropped := t.queue, t.dropped
+	t.queue, t.dropped = nil, 0
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+	if err := t.exporter.export(ctx, t.service, spans); err != nil {
+		return fmt.Errorf("failed to export %d spans: %w", len(spans), err)
+	}
+	if dropped > 0 {
+		return fmt.Errorf("dropped %d spans over the queue limit of %d", dropped, maxQueuedSpans)
+	}
+	return nil
+}
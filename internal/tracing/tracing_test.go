@@ -0,0 +1,138 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// collector records the export requests it receives
+func collector(t *testing.T) (*httptest.Server, *[]otlpRequest) {
+	t.Helper()
+	var requests []otlpRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("Unexpected export request: %s %s %v", r.Method, r.URL.Path, r.Header)
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode export request: %v", err)
+		}
+		requests = append(requests, req)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestStart_Disabled(t *testing.T) {
+	SetDefault(nil)
+	ctx, span := Start(context.Background(), "noop", KindInternal)
+	if span != nil || FromContext(ctx) != nil {
+		t.Fatal("Expected no span while tracing is disabled")
+	}
+	// A nil span is safe to use
+	span.SetAttr("key", "value")
+	span.SetError(errors.New("failed"))
+	span.End()
+}
+
+func TestTracer_ExportsSpans(t *testing.T) {
+	server, requests := collector(t)
+	tracer := New("saviour-test", server.URL, map[string]string{"X-Api-Key": "secret"}, 1)
+	SetDefault(tracer)
+	defer SetDefault(nil)
+
+	ctx, parent := StartRemote(context.Background(), "POST /api/v1/metrics/push", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, child := Start(ctx, "state.update", KindInternal)
+	child.SetAttr("agents", 3)
+	child.SetError(errors.New("store unavailable"))
+	child.End()
+	parent.End()
+
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("Expected 1 export, got %d", len(*requests))
+	}
+	resource := (*requests)[0].ResourceSpans[0]
+	if service := resource.Resource.Attributes[0]; service.Key != "service.name" || service.Value["stringValue"] != "saviour-test" {
+		t.Errorf("Unexpected resource attribute: %+v", service)
+	}
+	spans := resource.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+
+	exportedChild, exportedParent := spans[0], spans[1]
+	if exportedParent.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || exportedParent.ParentSpanID != "00f067aa0ba902b7" || exportedParent.Kind != KindServer {
+		t.Errorf("Expected server span continuing the remote trace, got %+v", exportedParent)
+	}
+	if exportedChild.TraceID != exportedParent.TraceID || exportedChild.ParentSpanID != exportedParent.SpanID {
+		t.Errorf("Expected child of the server span, got %+v", exportedChild)
+	}
+	if exportedChild.Status.Code != 2 || exportedChild.Status.Message != "store unavailable" {
+		t.Errorf("Expected error status, got %+v", exportedChild.Status)
+	}
+	if len(exportedChild.Attributes) != 1 || exportedChild.Attributes[0].Value["intValue"] != "3" {
+		t.Errorf("Expected agents=3 attribute, got %+v", exportedChild.Attributes)
+	}
+
+	// Nothing left to export
+	if err := tracer.Flush(context.Background()); err != nil || len(*requests) != 1 {
+		t.Errorf("Expected no second export, got %d (%v)", len(*requests), err)
+	}
+}
+
+func TestTracer_Sampling(t *testing.T) {
+	server, requests := collector(t)
+	tracer := New("saviour-test", server.URL, map[string]string{"X-Api-Key": "secret"}, 0)
+	SetDefault(tracer)
+	defer SetDefault(nil)
+
+	ctx, root := Start(context.Background(), "unsampled", KindInternal)
+	_, child := Start(ctx, "child", KindInternal)
+	child.End()
+	root.End()
+
+	// A sampled caller's trace is kept whatever the ratio
+	_, remote := StartRemote(context.Background(), "GET", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	remote.End()
+
+	tracer.Flush(context.Background())
+	if len(*requests) != 1 || len((*requests)[0].ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Errorf("Expected only the remotely sampled span exported, got %+v", *requests)
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		header  string
+		valid   bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-zzzzzzzzzzzzzzzz-01", false, false},
+		{"", false, false},
+	}
+
+	for _, tt := range tests {
+		span, ok := parseTraceParent(tt.header)
+		if ok != tt.valid {
+			t.Errorf("parseTraceParent(%q) valid = %v, want %v", tt.header, ok, tt.valid)
+			continue
+		}
+		if ok && span.sampled != tt.sampled {
+			t.Errorf("parseTraceParent(%q) sampled = %v, want %v", tt.header, span.sampled, tt.sampled)
+		}
+		if ok && span.TraceParent() != tt.header {
+			t.Errorf("Expected %q to round trip, got %q", tt.header, span.TraceParent())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Package version holds build information embedded at link time:
+//
+//	go build -ldflags "-X github.com/anurag/saviour/internal/version.Version=v1.2.0 \
+//	  -X github.com/anurag/saviour/internal/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/anurag/saviour/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set via -ldflags; see the package comment
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info describes the running binary
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information. Without ldflags, the commit and date
+// fall back to the VCS stamp Go records in plain "go build" binaries.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" && len(setting.Value) >= 7 {
+					info.Commit = setting.Value[:7]
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+
+	return info
+}
+
+// String formats the build information for -version output
+func String(binary string) string {
+	info := Get()
+	s := fmt.Sprintf("%s %s", binary, info.Version)
+	if info.Commit != "" {
+		s += " (" + info.Commit
+		if info.BuildDate != "" {
+			s += ", built " + info.BuildDate
+		}
+		s += ")"
+	}
+	return s + " " + info.GoVersion
+}
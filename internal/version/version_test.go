@@ -0,0 +1,26 @@
+package version
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	defer func(v, c, d string) { Version, Commit, BuildDate = v, c, d }(Version, Commit, BuildDate)
+
+	Version, Commit, BuildDate = "v1.2.0", "abc1234", "2024-05-01T10:00:00Z"
+
+	got := String("saviour-agent")
+	if !strings.HasPrefix(got, "saviour-agent v1.2.0 (abc1234, built 2024-05-01T10:00:00Z) go") {
+		t.Errorf("Unexpected version string: %q", got)
+	}
+}
+
+func TestGet_LinkerValuesWin(t *testing.T) {
+	defer func(c string) { Commit = c }(Commit)
+
+	Commit = "abc1234"
+	if info := Get(); info.Commit != "abc1234" {
+		t.Errorf("Expected commit abc1234, got %s", info.Commit)
+	}
+}
@@ -12,6 +12,12 @@ type SystemMetrics struct {
 	Network     NetworkMetrics     `json:"network"`
 	SystemInfo  SystemInfo         `json:"system_info"`
 	Containers  []ContainerMetrics `json:"containers,omitempty"` // Docker container metrics
+	Docker      *DockerMetrics     `json:"docker,omitempty"`     // Docker daemon metrics
+	Processes   []WatchedProcess   `json:"processes,omitempty"`  // Processes the agent watches
+
+	// Collectors that failed this round, "name: error"; the server reports
+	// the agent as degraded while any are listed
+	CollectorErrors []string `json:"collector_errors,omitempty"`
 }
 
 // CPUMetrics contains CPU usage information
@@ -48,7 +54,8 @@ type DiskMetrics struct {
 	InodesFree  uint64  `json:"inodes_free"`  // Free inodes
 }
 
-// NetworkMetrics contains network statistics
+// NetworkMetrics contains network statistics. Totals count since boot;
+// rates are zero after a counter reset as well as on the first sample.
 type NetworkMetrics struct {
 	BytesSent   uint64 `json:"bytes_sent"`   // Total bytes sent
 	BytesRecv   uint64 `json:"bytes_recv"`   // Total bytes received
@@ -58,6 +65,11 @@ type NetworkMetrics struct {
 	ErrorsOut   uint64 `json:"errors_out"`   // Output errors
 	DropsIn     uint64 `json:"drops_in"`     // Dropped input packets
 	DropsOut    uint64 `json:"drops_out"`    // Dropped output packets
+
+	BytesSentPerSec   float64 `json:"bytes_sent_per_sec"`   // Bytes/s sent since the previous collection, 0 on the first sample
+	BytesRecvPerSec   float64 `json:"bytes_recv_per_sec"`   // Bytes/s received since the previous collection, 0 on the first sample
+	PacketsSentPerSec float64 `json:"packets_sent_per_sec"` // Packets/s sent since the previous collection, 0 on the first sample
+	PacketsRecvPerSec float64 `json:"packets_recv_per_sec"` // Packets/s received since the previous collection, 0 on the first sample
 }
 
 // SystemInfo contains general system information
@@ -96,7 +108,18 @@ type ContainerMetrics struct {
 	ExitCode      int       `json:"exit_code"`      // Exit code when stopped
 	OOMKilled     bool      `json:"oom_killed"`     // Was killed due to OOM
 	RestartCount  int       `json:"restart_count"`  // Number of times restarted
+	StateError    string    `json:"state_error,omitempty"` // Daemon error from the last exit, if any
 	
+	// OOM kills seen on the events stream in the last hour
+	OOMKillsLastHour int `json:"oom_kills_last_hour,omitempty"`
+
+	// Last health check result (only populated when unhealthy)
+	HealthCheckExitCode int    `json:"health_check_exit_code,omitempty"`
+	HealthCheckOutput   string `json:"health_check_output,omitempty"`
+
+	// Registry has a newer image for this tag (only when update checks are enabled)
+	UpdateAvailable bool `json:"update_available,omitempty"`
+
 	// Timestamps
 	Created   time.Time `json:"created"`
 	StartedAt time.Time `json:"started_at"`
@@ -118,4 +141,66 @@ type ContainerMetrics struct {
 
 	// PIDs
 	PIDs uint64 `json:"pids"` // Number of processes in container
+
+	// Last remediation performed by the agent (only set when remediation is enabled)
+	Remediation *RemediationAction `json:"remediation,omitempty"`
+
+	// Alert thresholds set via saviour.* container labels
+	Thresholds *ContainerThresholds `json:"thresholds,omitempty"`
+}
+
+// ContainerThresholds holds per-container alert thresholds (zero = not set)
+type ContainerThresholds struct {
+	CPUThreshold     float64 `json:"cpu_threshold,omitempty"`
+	MemoryThreshold  float64 `json:"memory_threshold,omitempty"`
+	RestartThreshold int     `json:"restart_threshold,omitempty"`
+}
+
+// WatchedProcess is the state of a process the agent watches
+type WatchedProcess struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+	Count   int    `json:"count"` // Matching processes
+
+	// Last restart by the watchdog, until the process has stayed up for the
+	// cooloff after it
+	Remediation *RemediationAction `json:"remediation,omitempty"`
+}
+
+// RemediationAction records an automatic action taken by the agent on a
+// container or process
+type RemediationAction struct {
+	Action      string    `json:"action"`       // restart
+	Attempt     int       `json:"attempt"`      // 1-based attempt number
+	MaxAttempts int       `json:"max_attempts"` // Attempts allowed by the policy
+	Success     bool      `json:"success"`
+	Error       string    `json:"error,omitempty"`
+	ExitCode    int       `json:"exit_code"` // Exit code that triggered the action
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// DockerMetrics contains Docker daemon-level information
+type DockerMetrics struct {
+	Version       string `json:"version"`
+	APIVersion    string `json:"api_version"`
+	StorageDriver string `json:"storage_driver"`
+
+	// Object counts
+	Containers        int `json:"containers"`
+	ContainersRunning int `json:"containers_running"`
+	ContainersPaused  int `json:"containers_paused"`
+	ContainersStopped int `json:"containers_stopped"`
+	Images            int `json:"images"`
+
+	// Daemon internals
+	Goroutines int `json:"goroutines"`
+
+	// Data root (e.g. /var/lib/docker) disk usage
+	DataRoot            string  `json:"data_root"`
+	DataRootTotal       uint64  `json:"data_root_total"`        // bytes
+	DataRootUsed        uint64  `json:"data_root_used"`         // bytes
+	DataRootUsedPercent float64 `json:"data_root_used_percent"` // Used percentage
+
+	// Set when the daemon could not be queried
+	Error string `json:"error,omitempty"`
 }
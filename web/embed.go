@@ -0,0 +1,23 @@
+// Package web embeds the built dashboard (web/dist) into the server binary.
+// Run "make build-web" before building the server; without a build the
+// server answers with a hint instead of the dashboard.
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// dist always holds .gitkeep, so this compiles before the dashboard is built
+//
+//go:embed all:dist
+var dist embed.FS
+
+// Dist returns the embedded dashboard files
+func Dist() fs.FS {
+	sub, err := fs.Sub(dist, "dist")
+	if err != nil {
+		panic(err) // "dist" is a valid path, so this can't happen
+	}
+	return sub
+}
@@ -1,6 +1,27 @@
 import { useEffect, useState, useRef } from 'react';
 import { SSEUpdate } from '../types/api';
-import { API_ENDPOINTS } from '../lib/config';
+import { API_ENDPOINTS, API_KEY } from '../lib/config';
+
+// How long to wait before reconnecting a dropped stream
+const RECONNECT_DELAY_MS = 5000;
+
+// eventsURL returns the event stream URL. With an API key configured it
+// carries a freshly minted stream token, since EventSource can't send an
+// Authorization header and tokens expire after a few minutes.
+async function eventsURL(): Promise<string> {
+  if (!API_KEY) {
+    return API_ENDPOINTS.EVENTS;
+  }
+  const response = await fetch(API_ENDPOINTS.STREAM_TOKEN, {
+    method: 'POST',
+    headers: { Authorization: `Bearer ${API_KEY}` },
+  });
+  if (!response.ok) {
+    throw new Error(`Failed to get a stream token: ${response.status} ${response.statusText}`);
+  }
+  const { token } = (await response.json()) as { token: string };
+  return `${API_ENDPOINTS.EVENTS}?token=${encodeURIComponent(token)}`;
+}
 
 export function useSSE() {
   const [data, setData] = useState<SSEUpdate | null>(null);
@@ -9,9 +30,30 @@ export function useSSE() {
   const eventSourceRef = useRef<EventSource | null>(null);
 
   useEffect(() => {
-    const connect = () => {
+    let stopped = false;
+    let reconnectTimer: ReturnType<typeof setTimeout> | undefined;
+
+    const reconnect = () => {
+      if (!stopped) {
+        reconnectTimer = setTimeout(connect, RECONNECT_DELAY_MS);
+      }
+    };
+
+    const connect = async () => {
+      let url: string;
       try {
-        const eventSource = new EventSource(API_ENDPOINTS.EVENTS);
+        url = await eventsURL();
+      } catch (err) {
+        setError(err as Error);
+        reconnect();
+        return;
+      }
+      if (stopped) {
+        return;
+      }
+
+      try {
+        const eventSource = new EventSource(url);
         eventSourceRef.current = eventSource;
 
         eventSource.onopen = () => {
@@ -28,19 +70,17 @@ export function useSSE() {
           }
         };
 
-        eventSource.onerror = (err) => {
+        eventSource.onerror = () => {
           setIsConnected(false);
-          const errorMsg = eventSource.readyState === EventSource.CLOSED 
+          const errorMsg = eventSource.readyState === EventSource.CLOSED
             ? 'SSE connection closed. Check CORS and server configuration.'
             : 'SSE connection error';
           setError(new Error(errorMsg));
-          
-          // Only reconnect if connection was established before
-          if (eventSource.readyState === EventSource.CLOSED) {
-            eventSource.close();
-            // Reconnect after 5 seconds
-            setTimeout(connect, 5000);
-          }
+
+          // Reconnect ourselves rather than let the browser retry the same
+          // URL, whose stream token may have expired
+          eventSource.close();
+          reconnect();
         };
       } catch (err) {
         setError(err as Error);
@@ -50,6 +90,8 @@ export function useSSE() {
     connect();
 
     return () => {
+      stopped = true;
+      clearTimeout(reconnectTimer);
       if (eventSourceRef.current) {
         eventSourceRef.current.close();
       }
@@ -1,9 +1,17 @@
 export const API_BASE_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080';
 
+// API key the dashboard mints stream tokens and asks agents to refresh
+// with; stream tokens need it when the server sets
+// auth.stream_tokens.required. It ships in the bundle, so use a key with
+// only the agents:refresh scope.
+export const API_KEY = import.meta.env.VITE_API_KEY || '';
+
 export const API_ENDPOINTS = {
   AGENTS: `${API_BASE_URL}/api/v1/agents`,
   AGENT: (name: string) => `${API_BASE_URL}/api/v1/agents/${name}`,
+  AGENT_REFRESH: (name: string) => `${API_BASE_URL}/api/v1/agents/${encodeURIComponent(name)}/refresh`,
   ALERTS: `${API_BASE_URL}/api/v1/alerts`,
   EVENTS: `${API_BASE_URL}/api/v1/events`,
+  STREAM_TOKEN: `${API_BASE_URL}/api/v1/auth/stream-token`,
   HEALTH: `${API_BASE_URL}/api/v1/health`,
 } as const;
@@ -46,6 +46,7 @@ export function getStatusColor(status: string): string {
     case 'exited':
     case 'dead':
       return 'var(--status-error)';
+    case 'degraded':
     case 'unhealthy':
     case 'starting':
     case 'restarting':
@@ -1,8 +1,9 @@
-import React from 'react';
+import React, { useState } from 'react';
 import { ServerState } from '../types/api';
 import { MetricCard } from '../components/MetricCard';
 import { StatusBadge } from '../components/StatusBadge';
 import { formatUptime, formatPercentage, formatTimestamp } from '../lib/utils';
+import { API_ENDPOINTS, API_KEY } from '../lib/config';
 import './AgentOverview.css';
 
 interface AgentOverviewProps {
@@ -10,6 +11,8 @@ interface AgentOverviewProps {
 }
 
 export const AgentOverview: React.FC<AgentOverviewProps> = ({ agents }) => {
+  const [sortBy, setSortBy] = useState<'name' | 'health'>('name');
+  const [refreshing, setRefreshing] = useState<Set<string>>(new Set());
   const onlineCount = agents.filter(a => a.status === 'online').length;
   const totalContainers = agents.reduce((sum, a) => sum + (a.containers?.length || 0), 0);
   const runningContainers = agents.reduce(
@@ -17,6 +20,43 @@ export const AgentOverview: React.FC<AgentOverviewProps> = ({ agents }) => {
     0
   );
 
+  // Most common agent version; agents on any other version are flagged
+  const versionCounts = new Map<string, number>();
+  agents.forEach(a => {
+    if (a.agent_version) {
+      versionCounts.set(a.agent_version, (versionCounts.get(a.agent_version) || 0) + 1);
+    }
+  });
+  const fleetVersion = [...versionCounts.entries()].sort((a, b) => b[1] - a[1])[0]?.[0];
+
+  // Average health of the agents that are reporting; the rest score 0
+  const reporting = agents.filter(a => a.status === 'online' || a.status === 'degraded');
+  const fleetHealth = reporting.length > 0
+    ? Math.round(reporting.reduce((sum, a) => sum + a.health_score, 0) / reporting.length)
+    : 0;
+
+  // Least healthy first when sorting by health
+  const sortedAgents = [...agents].sort((a, b) =>
+    sortBy === 'health'
+      ? a.health_score - b.health_score || a.agent_name.localeCompare(b.agent_name)
+      : a.agent_name.localeCompare(b.agent_name)
+  );
+
+  // Ask an agent to push now; its update arrives over the event stream.
+  // The button stays disabled until the agent has had time to hear of it.
+  const refresh = (name: string) => {
+    setRefreshing(prev => new Set(prev).add(name));
+    fetch(API_ENDPOINTS.AGENT_REFRESH(name), {
+      method: 'POST',
+      headers: { Authorization: `Bearer ${API_KEY}` },
+    }).catch(() => {});
+    setTimeout(() => setRefreshing(prev => {
+      const next = new Set(prev);
+      next.delete(name);
+      return next;
+    }), 10000);
+  };
+
   return (
     <div className="agent-overview">
       <div className="page-header">
@@ -41,21 +81,66 @@ export const AgentOverview: React.FC<AgentOverviewProps> = ({ agents }) => {
           value={String(agents.reduce((sum, a) => sum + (a.active_alerts?.length || 0), 0))}
           status="neutral"
         />
+        <MetricCard
+          label="Fleet Health"
+          value={String(fleetHealth)}
+          sublabel="average score"
+          status={healthStatus(fleetHealth)}
+        />
+      </div>
+
+      <div className="filter-group agents-sort">
+        <button
+          className={`filter-btn ${sortBy === 'name' ? 'filter-btn--active' : ''}`}
+          onClick={() => setSortBy('name')}
+        >
+          By name
+        </button>
+        <button
+          className={`filter-btn ${sortBy === 'health' ? 'filter-btn--active' : ''}`}
+          onClick={() => setSortBy('health')}
+        >
+          By health
+        </button>
       </div>
 
       <div className="agents-grid">
-        {agents.map((agent) => (
+        {sortedAgents.map((agent) => (
           <div key={agent.agent_name} className="agent-card">
             <div className="agent-card__header">
               <div>
                 <h3 className="agent-card__name">{agent.agent_name}</h3>
                 <div className="agent-card__meta">
                   <StatusBadge status={agent.status} size="sm" />
+                  {agent.maintenance_until && new Date(agent.maintenance_until) > new Date() && (
+                    <span
+                      className="agent-card__maintenance"
+                      title={`In maintenance until ${new Date(agent.maintenance_until).toLocaleString()}${agent.maintenance_reason ? `: ${agent.maintenance_reason}` : ''}`}
+                    >
+                      🔧
+                    </span>
+                  )}
                   <span className="agent-card__timestamp">
                     {formatTimestamp(agent.last_seen)}
                   </span>
+                  {API_KEY && (
+                    <button
+                      className="agent-card__refresh"
+                      onClick={() => refresh(agent.agent_name)}
+                      disabled={refreshing.has(agent.agent_name)}
+                      title="Ask the agent to push its metrics now"
+                    >
+                      ↻
+                    </button>
+                  )}
                 </div>
               </div>
+              <span
+                className={`agent-card__health agent-card__health--${healthStatus(agent.health_score)}`}
+                title="Health score: resource headroom, active alerts, container health and heartbeat regularity"
+              >
+                {agent.health_score}
+              </span>
             </div>
 
             <div className="agent-card__metrics">
@@ -133,6 +218,17 @@ export const AgentOverview: React.FC<AgentOverviewProps> = ({ agents }) => {
                 <span>↑ {formatUptime(agent.system_metrics?.system_info?.uptime || 0)}</span>
                 <span>•</span>
                 <span>{agent.containers?.length || 0} containers</span>
+                {agent.agent_version && (
+                  <>
+                    <span>•</span>
+                    <span
+                      className={agent.agent_version !== fleetVersion ? 'agent-card__version--skewed' : undefined}
+                      title={agent.agent_version !== fleetVersion ? `Most agents run ${fleetVersion}` : undefined}
+                    >
+                      {agent.agent_version}
+                    </span>
+                  </>
+                )}
               </div>
             </div>
           </div>
@@ -141,3 +237,10 @@ export const AgentOverview: React.FC<AgentOverviewProps> = ({ agents }) => {
     </div>
   );
 };
+
+// healthStatus colours a 0-100 health score
+function healthStatus(score: number): 'success' | 'warning' | 'error' {
+  if (score >= 80) return 'success';
+  if (score >= 50) return 'warning';
+  return 'error';
+}
@@ -57,6 +57,30 @@ export const Alerts: React.FC<AlertsProps> = ({ alerts }) => {
                 <div className="alert-card__type">{alert.alert_type}</div>
                 <div className="alert-card__message">{alert.message}</div>
                 <div className="alert-card__agent">Agent: {alert.agent_name}</div>
+                {alert.assignee && (
+                  <div className="alert-card__assignee">👤 {alert.assignee}</div>
+                )}
+                {alert.annotations && (
+                  <div className="alert-card__annotations">
+                    {alert.annotations.runbook_url && (
+                      <a
+                        className="alert-card__runbook"
+                        href={alert.annotations.runbook_url}
+                        target="_blank"
+                        rel="noopener noreferrer"
+                      >
+                        📖 Runbook
+                      </a>
+                    )}
+                    {Object.entries(alert.annotations)
+                      .filter(([key]) => key !== 'runbook_url')
+                      .map(([key, value]) => (
+                        <span key={key} className="alert-annotation">
+                          {key}: {value}
+                        </span>
+                      ))}
+                  </div>
+                )}
               </div>
 
               {Object.keys(alert.details).length > 0 && (
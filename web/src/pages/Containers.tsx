@@ -41,7 +41,14 @@ export const Containers: React.FC<ContainersProps> = ({ agents }) => {
       render: (c: ContainerState & { agent_name: string }) => (
         <div className="container-cell">
           <div className="container-cell__name">{c.name}</div>
-          <div className="container-cell__image">{c.image}</div>
+          <div className="container-cell__image">
+            {c.image}
+            {c.update_available && (
+              <span className="container-cell__update" title="A newer image is available in the registry">
+                update available
+              </span>
+            )}
+          </div>
         </div>
       ),
     },
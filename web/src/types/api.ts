@@ -38,6 +38,10 @@ export interface NetworkMetrics {
   errors_out: number;
   drops_in: number;
   drops_out: number;
+  bytes_sent_per_sec: number;
+  bytes_recv_per_sec: number;
+  packets_sent_per_sec: number;
+  packets_recv_per_sec: number;
 }
 
 export interface SystemInfo {
@@ -49,6 +53,23 @@ export interface SystemInfo {
   uptime: number;
 }
 
+export interface DockerMetrics {
+  version: string;
+  api_version: string;
+  storage_driver: string;
+  containers: number;
+  containers_running: number;
+  containers_paused: number;
+  containers_stopped: number;
+  images: number;
+  goroutines: number;
+  data_root: string;
+  data_root_total: number;
+  data_root_used: number;
+  data_root_used_percent: number;
+  error?: string;
+}
+
 export interface SystemMetrics {
   timestamp: string;
   agent_name: string;
@@ -57,6 +78,18 @@ export interface SystemMetrics {
   disk: DiskMetrics[];
   network: NetworkMetrics;
   system_info: SystemInfo;
+  docker?: DockerMetrics;
+  collector_errors?: string[];
+}
+
+export interface RemediationAction {
+  action: string;
+  attempt: number;
+  max_attempts: number;
+  success: boolean;
+  error?: string;
+  exit_code: number;
+  timestamp: string;
 }
 
 export interface ContainerState {
@@ -68,8 +101,12 @@ export interface ContainerState {
   state: string;
   status: string;
   health: string;
+  health_check_exit_code?: number;
+  health_check_output?: string;
   exit_code: number;
   oom_killed: boolean;
+  state_error?: string;
+  oom_kills_last_hour?: number;
   restart_count: number;
   created: string;
   started_at: string;
@@ -83,8 +120,21 @@ export interface ContainerState {
   block_read_bytes: number;
   block_write_bytes: number;
   pids: number;
+  update_available?: boolean;
+  remediation?: RemediationAction;
+  thresholds?: {
+    cpu_threshold?: number;
+    memory_threshold?: number;
+    restart_threshold?: number;
+  };
+  labels?: Record<string, string>;
+  image_id?: string;
   previous_state?: string;
   last_state_change?: string;
+  compose_service?: string;
+  previous_id?: string;
+  recreations?: number;
+  prior_restarts?: number;
 }
 
 export interface Alert {
@@ -98,13 +148,34 @@ export interface Alert {
   resolved_at?: string;
   status: string;
   notified_at?: string;
+  annotations?: Record<string, string>;
+  assignee?: string;
+  assigned_at?: string;
+}
+
+export interface CloudMetadata {
+  provider: string;
+  instance_id: string;
+  instance_type: string;
+  region: string;
+  zone: string;
+  lifecycle?: string;
+  tags?: Record<string, string>;
 }
 
 export interface ServerState {
   agent_name: string;
   ec2_instance_id: string;
-  status: 'online' | 'offline';
+  cloud?: CloudMetadata;
+  status: 'online' | 'degraded' | 'offline' | 'terminating' | 'terminated' | 'stopped' | 'decommissioned';
+  status_reason?: string;
+  agent_version?: string;
   last_seen: string;
+  last_metrics_at?: string;
+  clock_skew_seconds?: number;
+  maintenance_until?: string;
+  maintenance_reason?: string;
+  health_score: number;
   system_metrics: SystemMetrics;
   containers: ContainerState[];
   active_alerts: Alert[];